@@ -0,0 +1,13 @@
+package accept_test
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"testing"
+)
+
+func TestAccept(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "Accept Suite")
+}
@@ -0,0 +1,131 @@
+// Package accept accepts the conns of a listener with a pool of goroutines.
+// A worker hitting a temporary error backs off instead of retrying at once,
+// so a burst of failing accepts, such as while the process is out of file
+// descriptors, neither spins a CPU nor holds up the other workers. Accepted
+// conns and accept errors are counted per listener.
+package accept
+
+import (
+	"net"
+	"sync"
+	"time"
+
+	"github.com/uber-go/zap"
+
+	"code.cloudfoundry.org/gorouter/config"
+	"code.cloudfoundry.org/gorouter/logger"
+	"code.cloudfoundry.org/gorouter/metrics"
+)
+
+// minBackoff is the first wait after a temporary accept error
+const minBackoff = 5 * time.Millisecond
+
+type listener struct {
+	net.Listener
+	name       string
+	maxBackoff time.Duration
+	reporter   metrics.AcceptReporter
+	logger     logger.Logger
+
+	conns     chan net.Conn
+	done      chan struct{}
+	err       error
+	closeOnce sync.Once
+}
+
+// NewListener accepts the conns of l with the workers of c. name identifies
+// the listener in metrics and logs.
+func NewListener(l net.Listener, name string, c config.AcceptConfig, reporter metrics.AcceptReporter, logger logger.Logger) net.Listener {
+	workers := c.Workers
+	if workers <= 0 {
+		workers = 1
+	}
+	a := &listener{
+		Listener:   l,
+		name:       name,
+		maxBackoff: c.MaxBackoff,
+		reporter:   reporter,
+		logger:     logger,
+		conns:      make(chan net.Conn),
+		done:       make(chan struct{}),
+	}
+	for i := 0; i < workers; i++ {
+		go a.work()
+	}
+	return a
+}
+
+func (l *listener) work() {
+	var backoff time.Duration
+	for {
+		conn, err := l.Listener.Accept()
+		if err != nil {
+			ne, ok := err.(net.Error)
+			temporary := ok && ne.Temporary()
+			l.reporter.CaptureAcceptError(l.name, temporary)
+			if !temporary {
+				l.stop(err)
+				return
+			}
+
+			backoff = nextBackoff(backoff, l.maxBackoff)
+			if backoff == minBackoff {
+				l.logger.Error("accept-error", zap.String("listener", l.name), zap.Error(err))
+			}
+			select {
+			case <-time.After(backoff):
+				continue
+			case <-l.done:
+				return
+			}
+		}
+
+		if backoff > 0 {
+			l.logger.Info("accept-recovered", zap.String("listener", l.name))
+			backoff = 0
+		}
+		l.reporter.CaptureAccept(l.name)
+
+		select {
+		case l.conns <- conn:
+		case <-l.done:
+			conn.Close()
+			return
+		}
+	}
+}
+
+func nextBackoff(backoff, max time.Duration) time.Duration {
+	if backoff == 0 {
+		return minBackoff
+	}
+	backoff *= 2
+	if max > 0 && backoff > max {
+		return max
+	}
+	return backoff
+}
+
+// stop makes Accept return err, once the first worker fails or the listener
+// is closed
+func (l *listener) stop(err error) {
+	l.closeOnce.Do(func() {
+		l.err = err
+		close(l.done)
+	})
+}
+
+func (l *listener) Accept() (net.Conn, error) {
+	select {
+	case conn := <-l.conns:
+		return conn, nil
+	case <-l.done:
+		return nil, l.err
+	}
+}
+
+func (l *listener) Close() error {
+	err := l.Listener.Close()
+	l.stop(&net.OpError{Op: "accept", Net: "tcp", Addr: l.Listener.Addr(), Err: net.ErrClosed})
+	return err
+}
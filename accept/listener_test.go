@@ -0,0 +1,159 @@
+package accept_test
+
+import (
+	"errors"
+	"net"
+	"sync"
+	"time"
+
+	"code.cloudfoundry.org/gorouter/accept"
+	"code.cloudfoundry.org/gorouter/config"
+	"code.cloudfoundry.org/gorouter/metrics/fakes"
+	"code.cloudfoundry.org/gorouter/test_util"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+type temporaryError struct{}
+
+func (temporaryError) Error() string   { return "too many open files" }
+func (temporaryError) Timeout() bool   { return false }
+func (temporaryError) Temporary() bool { return true }
+
+// scriptedListener returns the errors it is given before accepting conns from
+// the listener it wraps
+type scriptedListener struct {
+	net.Listener
+
+	lock     sync.Mutex
+	errs     []error
+	attempts []time.Time
+}
+
+func (l *scriptedListener) Accept() (net.Conn, error) {
+	l.lock.Lock()
+	l.attempts = append(l.attempts, time.Now())
+	if len(l.errs) > 0 {
+		err := l.errs[0]
+		l.errs = l.errs[1:]
+		l.lock.Unlock()
+		return nil, err
+	}
+	l.lock.Unlock()
+	return l.Listener.Accept()
+}
+
+func (l *scriptedListener) Attempts() []time.Time {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	return append([]time.Time(nil), l.attempts...)
+}
+
+var _ = Describe("Listener", func() {
+	var (
+		inner    *scriptedListener
+		listener net.Listener
+		reporter *fakes.FakeAcceptReporter
+		cfg      config.AcceptConfig
+	)
+
+	BeforeEach(func() {
+		ln, err := net.Listen("tcp", "127.0.0.1:0")
+		Expect(err).NotTo(HaveOccurred())
+		inner = &scriptedListener{Listener: ln}
+		reporter = new(fakes.FakeAcceptReporter)
+		cfg = config.AcceptConfig{Workers: 1, MaxBackoff: 40 * time.Millisecond}
+	})
+
+	JustBeforeEach(func() {
+		listener = accept.NewListener(inner, "http", cfg, reporter, test_util.NewTestZapLogger("accept"))
+	})
+
+	AfterEach(func() {
+		listener.Close()
+	})
+
+	dial := func() net.Conn {
+		conn, err := net.Dial("tcp", listener.Addr().String())
+		Expect(err).NotTo(HaveOccurred())
+		return conn
+	}
+
+	It("accepts conns", func() {
+		client := dial()
+		defer client.Close()
+
+		conn, err := listener.Accept()
+		Expect(err).NotTo(HaveOccurred())
+		defer conn.Close()
+		Expect(conn.RemoteAddr().String()).To(Equal(client.LocalAddr().String()))
+		Expect(reporter.CaptureAcceptArgsForCall(0)).To(Equal("http"))
+	})
+
+	Context("when accepting fails temporarily", func() {
+		BeforeEach(func() {
+			inner.errs = []error{temporaryError{}, temporaryError{}, temporaryError{}, temporaryError{}, temporaryError{}}
+		})
+
+		It("backs off before retrying, up to the maximum", func() {
+			client := dial()
+			defer client.Close()
+
+			conn, err := listener.Accept()
+			Expect(err).NotTo(HaveOccurred())
+			conn.Close()
+
+			attempts := inner.Attempts()
+			Expect(len(attempts)).To(BeNumerically(">=", 6))
+			Expect(attempts[1].Sub(attempts[0])).To(BeNumerically(">=", 5*time.Millisecond))
+			Expect(attempts[4].Sub(attempts[3])).To(BeNumerically(">=", 40*time.Millisecond))
+			Expect(attempts[5].Sub(attempts[4])).To(BeNumerically("<", 80*time.Millisecond))
+
+			Expect(reporter.CaptureAcceptErrorCallCount()).To(Equal(5))
+			name, temporary := reporter.CaptureAcceptErrorArgsForCall(0)
+			Expect(name).To(Equal("http"))
+			Expect(temporary).To(BeTrue())
+		})
+	})
+
+	Context("when accepting fails for good", func() {
+		BeforeEach(func() {
+			inner.errs = []error{errors.New("broken")}
+		})
+
+		It("returns the error", func() {
+			_, err := listener.Accept()
+			Expect(err).To(MatchError("broken"))
+
+			_, temporary := reporter.CaptureAcceptErrorArgsForCall(0)
+			Expect(temporary).To(BeFalse())
+		})
+	})
+
+	Context("with several workers", func() {
+		BeforeEach(func() {
+			cfg.Workers = 4
+		})
+
+		It("accepts every conn", func() {
+			for i := 0; i < 10; i++ {
+				client := dial()
+				defer client.Close()
+			}
+			for i := 0; i < 10; i++ {
+				conn, err := listener.Accept()
+				Expect(err).NotTo(HaveOccurred())
+				conn.Close()
+			}
+			Expect(reporter.CaptureAcceptCallCount()).To(Equal(10))
+		})
+	})
+
+	It("fails to accept once closed", func() {
+		Expect(listener.Close()).To(Succeed())
+
+		_, err := listener.Accept()
+		Expect(err).To(HaveOccurred())
+	})
+})
@@ -33,15 +33,27 @@ func (x *NullAccessLogger) Log(schema.AccessLogRecord) {}
 
 type FileAndLoggregatorAccessLogger struct {
 	dropsondeSourceInstance string
-	channel                 chan schema.AccessLogRecord
+	ring                    *recordRing
 	stopCh                  chan struct{}
 	writer                  io.Writer
 	writerCount             int
+	flushes                 []func() error
+	timestampFormat         string
 	logger                  logger.Logger
 }
 
 func CreateRunningAccessLogger(logger logger.Logger, config *config.Config) (AccessLogger, error) {
 
+	if len(config.AccessLog.Sinks) > 0 {
+		sinks, err := CreateSinks(logger, config)
+		if err != nil {
+			return nil, err
+		}
+		sinkManager := NewSinkManager(logger, sinks...)
+		go sinkManager.Run()
+		return sinkManager, nil
+	}
+
 	if config.AccessLog.File == "" && !config.Logging.LoggregatorEnabled {
 		return &NullAccessLogger{}, nil
 	}
@@ -73,6 +85,7 @@ func CreateRunningAccessLogger(logger logger.Logger, config *config.Config) (Acc
 	}
 
 	accessLogger := NewFileAndLoggregatorAccessLogger(logger, dropsondeSourceInstance, writers...)
+	accessLogger.timestampFormat = config.AccessLog.TimestampFormat
 	go accessLogger.Run()
 	return accessLogger, nil
 }
@@ -80,7 +93,7 @@ func CreateRunningAccessLogger(logger logger.Logger, config *config.Config) (Acc
 func NewFileAndLoggregatorAccessLogger(logger logger.Logger, dropsondeSourceInstance string, ws ...io.Writer) *FileAndLoggregatorAccessLogger {
 	a := &FileAndLoggregatorAccessLogger{
 		dropsondeSourceInstance: dropsondeSourceInstance,
-		channel:                 make(chan schema.AccessLogRecord, 1024),
+		ring:                    newRecordRing(logger),
 		stopCh:                  make(chan struct{}),
 		logger:                  logger,
 	}
@@ -89,22 +102,33 @@ func NewFileAndLoggregatorAccessLogger(logger logger.Logger, dropsondeSourceInst
 }
 
 func (x *FileAndLoggregatorAccessLogger) Run() {
-	for {
-		select {
-		case record := <-x.channel:
-			if x.writer != nil {
-				_, err := record.WriteTo(x.writer)
-				if err != nil {
-					x.logger.Error("error-emitting-access-log-to-writers", zap.Error(err))
-				}
-			}
-			if x.dropsondeSourceInstance != "" && record.ApplicationID() != "" {
-				logs.SendAppLog(record.ApplicationID(), record.LogMessage(), "RTR", x.dropsondeSourceInstance)
-			}
-		case <-x.stopCh:
-			return
+	x.ring.consume(x.stopCh, x.emit, x.flush)
+}
+
+func (x *FileAndLoggregatorAccessLogger) emit(record *schema.AccessLogRecord) {
+	record.TimestampFormat = x.timestampFormat
+	if x.writer != nil {
+		_, err := record.WriteTo(x.writer)
+		if err != nil {
+			x.logger.Error("error-emitting-access-log-to-writers", zap.Error(err))
 		}
 	}
+	if x.dropsondeSourceInstance != "" && record.ApplicationID() != "" {
+		logs.SendAppLog(record.ApplicationID(), record.LogMessage(), "RTR", x.dropsondeSourceInstance)
+	}
+}
+
+func (x *FileAndLoggregatorAccessLogger) flush() {
+	for _, flush := range x.flushes {
+		if err := flush(); err != nil {
+			x.logger.Error("error-flushing-access-log", zap.Error(err))
+		}
+	}
+}
+
+// Dropped returns the number of records dropped as the writers fell behind
+func (x *FileAndLoggregatorAccessLogger) Dropped() uint64 {
+	return x.ring.Dropped()
 }
 
 func (x *FileAndLoggregatorAccessLogger) FileWriter() io.Writer {
@@ -122,8 +146,9 @@ func (x *FileAndLoggregatorAccessLogger) Stop() {
 	close(x.stopCh)
 }
 
+// Log queues r to be written, dropping it when the writers fall too far behind
 func (x *FileAndLoggregatorAccessLogger) Log(r schema.AccessLogRecord) {
-	x.channel <- r
+	x.ring.push(&r)
 }
 
 var ipAddressRegex, _ = regexp.Compile(`^(([0-9]|[1-9][0-9]|1[0-9]{2}|2[0-4][0-9]|25[0-5])\.){3}([0-9]|[1-9][0-9]|1[0-9]{2}|2[0-4][0-9]|25[0-5])(:[0-9]{1,5}){1}$`)
@@ -137,7 +162,9 @@ func configureWriters(a *FileAndLoggregatorAccessLogger, ws []io.Writer) {
 	var multiws []io.Writer
 	for _, w := range ws {
 		if w != nil {
+			w, flush := bufferFile(w)
 			multiws = append(multiws, w)
+			a.flushes = append(a.flushes, flush)
 			a.writerCount++
 		}
 	}
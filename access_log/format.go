@@ -0,0 +1,70 @@
+package access_log
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"text/template"
+
+	"code.cloudfoundry.org/gorouter/access_log/schema"
+	"code.cloudfoundry.org/gorouter/config"
+)
+
+// Formatter renders an access log record as a single line
+type Formatter interface {
+	Format(record *schema.AccessLogRecord) ([]byte, error)
+}
+
+// NewFormatter returns the formatter for one of config.AccessLogFormats. The
+// template is only used by the template format and is executed against
+// schema.AccessLogFields.
+func NewFormatter(format, tmpl string) (Formatter, error) {
+	switch format {
+	case "", config.ACCESS_LOG_FORMAT_CLASSIC:
+		return classicFormatter{}, nil
+	case config.ACCESS_LOG_FORMAT_JSON:
+		return jsonFormatter{}, nil
+	case config.ACCESS_LOG_FORMAT_TEMPLATE:
+		t, err := template.New("access_log").Option("missingkey=error").Parse(tmpl)
+		if err != nil {
+			return nil, err
+		}
+		return &templateFormatter{template: t}, nil
+	default:
+		return nil, fmt.Errorf("unknown access log format: %s", format)
+	}
+}
+
+type classicFormatter struct{}
+
+func (classicFormatter) Format(record *schema.AccessLogRecord) ([]byte, error) {
+	var b bytes.Buffer
+	_, err := record.WriteTo(&b)
+	return b.Bytes(), err
+}
+
+type jsonFormatter struct{}
+
+func (jsonFormatter) Format(record *schema.AccessLogRecord) ([]byte, error) {
+	line, err := json.Marshal(record.Fields())
+	if err != nil {
+		return nil, err
+	}
+	return append(line, '\n'), nil
+}
+
+type templateFormatter struct {
+	template *template.Template
+}
+
+func (f *templateFormatter) Format(record *schema.AccessLogRecord) ([]byte, error) {
+	var b bytes.Buffer
+	err := f.template.Execute(&b, record.Fields())
+	if err != nil {
+		return nil, err
+	}
+	if b.Len() == 0 || b.Bytes()[b.Len()-1] != '\n' {
+		b.WriteByte('\n')
+	}
+	return b.Bytes(), nil
+}
@@ -0,0 +1,182 @@
+package access_log
+
+import (
+	"bufio"
+	"io"
+	"os"
+	"sync/atomic"
+	"time"
+
+	"github.com/cloudfoundry/dropsonde/metrics"
+	"github.com/uber-go/zap"
+
+	"code.cloudfoundry.org/gorouter/access_log/schema"
+	"code.cloudfoundry.org/gorouter/logger"
+)
+
+const (
+	// ringSize is the number of records waiting to be written before new
+	// ones are dropped. It must be a power of two.
+	ringSize = 1024
+
+	// maxBatch bounds the records written between two flushes
+	maxBatch = 256
+
+	// fileBufferSize is the size of the buffers batching the writes to files
+	fileBufferSize = 64 * 1024
+
+	droppedLogInterval = 10 * time.Second
+
+	// DroppedRecords counts the records dropped because the ring was full
+	DroppedRecords = "access_log_records_dropped"
+)
+
+type recordSlot struct {
+	// seq is the position the slot is free to be written at, or that plus
+	// one once it holds the record of that position
+	seq    uint64
+	record schema.AccessLogRecord
+}
+
+// recordRing is a bounded lock-free queue of records, written by the request
+// goroutines and read by a single one. A record logged while the ring is full
+// is dropped and counted rather than waited for, so that a slow disk never
+// holds up requests.
+type recordRing struct {
+	head    uint64
+	dropped uint64
+
+	// tail is only used by the reader
+	tail  uint64
+	mask  uint64
+	slots []recordSlot
+
+	notify chan struct{}
+
+	logger        logger.Logger
+	droppedLogged time.Time
+	droppedSince  uint64
+}
+
+func newRecordRing(logger logger.Logger) *recordRing {
+	r := &recordRing{
+		mask:   ringSize - 1,
+		slots:  make([]recordSlot, ringSize),
+		notify: make(chan struct{}, 1),
+		logger: logger,
+	}
+	for i := range r.slots {
+		r.slots[i].seq = uint64(i)
+	}
+	return r
+}
+
+// push queues record, or drops it when the ring is full
+func (r *recordRing) push(record *schema.AccessLogRecord) bool {
+	for {
+		pos := atomic.LoadUint64(&r.head)
+		slot := &r.slots[pos&r.mask]
+		seq := atomic.LoadUint64(&slot.seq)
+
+		switch {
+		case seq == pos:
+			if !atomic.CompareAndSwapUint64(&r.head, pos, pos+1) {
+				continue
+			}
+			slot.record = *record
+			atomic.StoreUint64(&slot.seq, pos+1)
+
+			select {
+			case r.notify <- struct{}{}:
+			default:
+			}
+			return true
+		case seq < pos:
+			// the slot still holds the record written a lap ago
+			atomic.AddUint64(&r.dropped, 1)
+			return false
+		}
+		// another writer took pos
+	}
+}
+
+// pop moves the oldest record into record. It must only be called by the
+// reader.
+func (r *recordRing) pop(record *schema.AccessLogRecord) bool {
+	slot := &r.slots[r.tail&r.mask]
+	if atomic.LoadUint64(&slot.seq) != r.tail+1 {
+		return false
+	}
+
+	*record = slot.record
+	slot.record = schema.AccessLogRecord{}
+	atomic.StoreUint64(&slot.seq, r.tail+uint64(len(r.slots)))
+	r.tail++
+	return true
+}
+
+// Dropped returns the number of records dropped since the ring was created
+func (r *recordRing) Dropped() uint64 {
+	return atomic.LoadUint64(&r.dropped)
+}
+
+// consume emits the records pushed until stopCh is closed, calling flush
+// after each batch. The records still queued when stopped are emitted.
+func (r *recordRing) consume(stopCh <-chan struct{}, emit func(*schema.AccessLogRecord), flush func()) {
+	var record schema.AccessLogRecord
+	for {
+		for r.drain(&record, emit, flush) {
+		}
+
+		select {
+		case <-r.notify:
+		case <-stopCh:
+			for r.drain(&record, emit, flush) {
+			}
+			return
+		}
+	}
+}
+
+// drain emits up to a batch of records and flushes them, reporting whether
+// the batch was full
+func (r *recordRing) drain(record *schema.AccessLogRecord, emit func(*schema.AccessLogRecord), flush func()) bool {
+	n := 0
+	for ; n < maxBatch && r.pop(record); n++ {
+		emit(record)
+	}
+	if n > 0 {
+		flush()
+	}
+	r.reportDropped()
+	return n == maxBatch
+}
+
+// reportDropped counts the records dropped since the last report, and logs
+// them at most once every droppedLogInterval
+func (r *recordRing) reportDropped() {
+	dropped := atomic.LoadUint64(&r.dropped)
+	reported := r.droppedSince
+	if dropped == reported {
+		return
+	}
+	metrics.AddToCounter(DroppedRecords, dropped-reported)
+	r.droppedSince = dropped
+
+	now := time.Now()
+	if now.Sub(r.droppedLogged) < droppedLogInterval {
+		return
+	}
+	r.logger.Error("access-log-records-dropped", zap.Uint64("dropped", dropped))
+	r.droppedLogged = now
+}
+
+// bufferFile batches the writes to w when it is a file, returning the writer
+// to use and the function flushing it
+func bufferFile(w io.Writer) (io.Writer, func() error) {
+	if _, ok := w.(*os.File); !ok {
+		return w, func() error { return nil }
+	}
+	buffered := bufio.NewWriterSize(w, fileBufferSize)
+	return buffered, buffered.Flush
+}
@@ -0,0 +1,107 @@
+package access_log_test
+
+import (
+	"bytes"
+	"sync"
+
+	. "code.cloudfoundry.org/gorouter/access_log"
+	"code.cloudfoundry.org/gorouter/test_util"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/onsi/gomega/gbytes"
+)
+
+// blockingWriter blocks every write until released, like a stalled disk
+type blockingWriter struct {
+	release chan struct{}
+
+	lock  sync.Mutex
+	lines int
+}
+
+func (w *blockingWriter) Write(b []byte) (int, error) {
+	<-w.release
+	w.lock.Lock()
+	w.lines += bytes.Count(b, []byte("\n"))
+	w.lock.Unlock()
+	return len(b), nil
+}
+
+func (w *blockingWriter) Lines() int {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+	return w.lines
+}
+
+var _ = Describe("Access log buffering", func() {
+	var (
+		logger *test_util.TestZapLogger
+		writer *blockingWriter
+	)
+
+	BeforeEach(func() {
+		logger = test_util.NewTestZapLogger("test")
+		writer = &blockingWriter{release: make(chan struct{})}
+	})
+
+	It("drops and counts the records logged while the writers are stalled", func() {
+		accessLogger := NewFileAndLoggregatorAccessLogger(logger, "", writer)
+		go accessLogger.Run()
+		defer accessLogger.Stop()
+
+		done := make(chan struct{})
+		go func() {
+			for i := 0; i < 3000; i++ {
+				accessLogger.Log(*CreateAccessLogRecord())
+			}
+			close(done)
+		}()
+		Eventually(done).Should(BeClosed())
+		Expect(accessLogger.Dropped()).To(BeNumerically(">", 0))
+
+		close(writer.release)
+		Eventually(writer.Lines).Should(BeNumerically("==", 3000-int(accessLogger.Dropped())))
+		Eventually(logger).Should(gbytes.Say("access-log-records-dropped"))
+	})
+
+	It("writes the records queued when stopped", func() {
+		close(writer.release)
+		sinkManager := NewSinkManager(logger, NewWriterSink("stalled", writer, mustFormatter("classic"), 1, false))
+		for i := 0; i < 10; i++ {
+			sinkManager.Log(*CreateAccessLogRecord())
+		}
+		sinkManager.Stop()
+		sinkManager.Run()
+
+		Expect(writer.Lines()).To(Equal(10))
+		Expect(sinkManager.Dropped()).To(BeZero())
+	})
+
+	It("accepts records from many goroutines", func() {
+		close(writer.release)
+		accessLogger := NewFileAndLoggregatorAccessLogger(logger, "", writer)
+		go accessLogger.Run()
+		defer accessLogger.Stop()
+
+		var wg sync.WaitGroup
+		for g := 0; g < 8; g++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				for i := 0; i < 100; i++ {
+					accessLogger.Log(*CreateAccessLogRecord())
+				}
+			}()
+		}
+		wg.Wait()
+
+		Eventually(writer.Lines).Should(Equal(800 - int(accessLogger.Dropped())))
+	})
+})
+
+func mustFormatter(format string) Formatter {
+	formatter, err := NewFormatter(format, "")
+	Expect(err).NotTo(HaveOccurred())
+	return formatter
+}
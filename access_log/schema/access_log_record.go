@@ -86,22 +86,31 @@ type AccessLogRecord struct {
 	FinishedAt           time.Time
 	BodyBytesSent        int
 	RequestBytesReceived int
+	ContentRange         string
+	GeoCountry           string
+	GeoRegion            string
 	ExtraHeadersToLog    []string
-	record               []byte
+
+	// TimestampFormat is the format of StartedAt, see FormatTimestamp
+	TimestampFormat string
+
+	record          []byte
+	recordTimestamp string
 }
 
 func (r *AccessLogRecord) formatStartedAt() string {
-	return r.StartedAt.Format("2006-01-02T15:04:05.000-0700")
+	return FormatTimestamp(r.StartedAt, r.TimestampFormat)
 }
 
 func (r *AccessLogRecord) responseTime() float64 {
 	return float64(r.FinishedAt.UnixNano()-r.StartedAt.UnixNano()) / float64(time.Second)
 }
 
-// getRecord memoizes makeRecord()
+// getRecord memoizes makeRecord() for the current TimestampFormat
 func (r *AccessLogRecord) getRecord() []byte {
-	if len(r.record) == 0 {
+	if len(r.record) == 0 || r.recordTimestamp != r.TimestampFormat {
 		r.record = r.makeRecord()
+		r.recordTimestamp = r.TimestampFormat
 	}
 
 	return r.record
@@ -151,6 +160,8 @@ func (r *AccessLogRecord) makeRecord() []byte {
 	b.WriteString(`app_index:`)
 	b.WriteDashOrStringValue(appIndex)
 
+	r.addRange(b)
+	r.addGeo(b)
 	r.addExtraHeaders(b)
 
 	b.WriteByte('\n')
@@ -182,6 +193,32 @@ func (r *AccessLogRecord) LogMessage() string {
 	return string(r.getRecord())
 }
 
+// addRange is only written for range requests and partial responses so the
+// common log line keeps its shape
+func (r *AccessLogRecord) addRange(b *recordBuffer) {
+	rangeHeader := r.Request.Header.Get("Range")
+	if rangeHeader == "" && r.StatusCode != http.StatusPartialContent {
+		return
+	}
+
+	b.WriteString(` range:`)
+	b.WriteDashOrStringValue(rangeHeader)
+	b.WriteString(` content_range:`)
+	b.WriteDashOrStringValue(r.ContentRange)
+}
+
+// addGeo is only written when the client was located, like addRange
+func (r *AccessLogRecord) addGeo(b *recordBuffer) {
+	if r.GeoCountry == "" && r.GeoRegion == "" {
+		return
+	}
+
+	b.WriteString(` geo_country:`)
+	b.WriteDashOrStringValue(r.GeoCountry)
+	b.WriteString(` geo_region:`)
+	b.WriteDashOrStringValue(r.GeoRegion)
+}
+
 func (r *AccessLogRecord) addExtraHeaders(b *recordBuffer) {
 	if r.ExtraHeadersToLog == nil {
 		return
@@ -150,6 +150,44 @@ var _ = Describe("AccessLogRecord", func() {
 			})
 		})
 
+		Context("with a range request", func() {
+			BeforeEach(func() {
+				record.Request.Header.Set("Range", "bytes=0-99")
+				record.StatusCode = http.StatusPartialContent
+				record.ContentRange = "bytes 0-99/1000"
+			})
+
+			It("appends the range fields", func() {
+				Expect(record.LogMessage()).To(HaveSuffix(
+					`app_index:"3" ` +
+						`range:"bytes=0-99" ` +
+						`content_range:"bytes 0-99/1000"` +
+						"\n"))
+			})
+		})
+
+		Context("with a partial response to a request without a range", func() {
+			BeforeEach(func() {
+				record.StatusCode = http.StatusPartialContent
+			})
+
+			It("writes dashes for the missing range fields", func() {
+				Expect(record.LogMessage()).To(HaveSuffix(`range:"-" content_range:"-"` + "\n"))
+			})
+		})
+
+		Context("with a located client", func() {
+			BeforeEach(func() {
+				record.GeoCountry = "GB"
+				record.GeoRegion = "ENG"
+			})
+
+			It("appends the geo fields", func() {
+				Expect(record.LogMessage()).To(HaveSuffix(
+					`app_index:"3" geo_country:"GB" geo_region:"ENG"` + "\n"))
+			})
+		})
+
 		Context("when extra headers is an empty slice", func() {
 			It("Makes a record with all values", func() {
 				record := schema.AccessLogRecord{
@@ -201,6 +239,29 @@ var _ = Describe("AccessLogRecord", func() {
 		})
 	})
 
+	Describe("TimestampFormat", func() {
+		It("writes the start time in the format", func() {
+			record.TimestampFormat = "rfc3339nano"
+			Expect(record.LogMessage()).To(HavePrefix("FakeRequestHost - [2000-01-01T00:00:00Z] "))
+			Expect(record.Fields().StartedAt).To(Equal("2000-01-01T00:00:00Z"))
+
+			record.TimestampFormat = "unix_millis"
+			Expect(record.LogMessage()).To(HavePrefix("FakeRequestHost - [946684800000] "))
+		})
+	})
+
+	Describe("FormatTimestamp", func() {
+		t := time.Date(2000, time.January, 1, 0, 0, 0, 123456789, time.UTC)
+
+		It("renders each format", func() {
+			Expect(schema.FormatTimestamp(t, "epoch")).To(HavePrefix("946684800.123456"))
+			Expect(schema.FormatTimestamp(t, "rfc3339")).To(Equal("2000-01-01T00:00:00Z"))
+			Expect(schema.FormatTimestamp(t, "rfc3339nano")).To(Equal("2000-01-01T00:00:00.123456789Z"))
+			Expect(schema.FormatTimestamp(t, "unix_millis")).To(Equal("946684800123"))
+			Expect(schema.FormatTimestamp(t, "")).To(Equal("2000-01-01T00:00:00.123+0000"))
+		})
+	})
+
 	Describe("WriteTo", func() {
 		It("writes the correct log line to the io.Writer", func() {
 			recordString := "FakeRequestHost - " +
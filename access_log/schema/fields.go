@@ -0,0 +1,73 @@
+package schema
+
+import "strings"
+
+// AccessLogFields holds the values of an access log record for the
+// structured and templated access log formats
+type AccessLogFields struct {
+	Host            string            `json:"host"`
+	StartedAt       string            `json:"started_at"`
+	Method          string            `json:"method"`
+	URI             string            `json:"uri"`
+	Protocol        string            `json:"protocol"`
+	StatusCode      int               `json:"status_code"`
+	BytesReceived   int               `json:"bytes_received"`
+	BytesSent       int               `json:"bytes_sent"`
+	Referer         string            `json:"referer"`
+	UserAgent       string            `json:"user_agent"`
+	RemoteAddr      string            `json:"remote_addr"`
+	BackendAddr     string            `json:"backend_addr"`
+	XForwardedFor   string            `json:"x_forwarded_for"`
+	XForwardedProto string            `json:"x_forwarded_proto"`
+	VcapRequestID   string            `json:"vcap_request_id"`
+	ResponseTime    float64           `json:"response_time"`
+	AppID           string            `json:"app_id"`
+	AppIndex        string            `json:"app_index"`
+	Range           string            `json:"range,omitempty"`
+	ContentRange    string            `json:"content_range,omitempty"`
+	GeoCountry      string            `json:"geo_country,omitempty"`
+	GeoRegion       string            `json:"geo_region,omitempty"`
+	ExtraHeaders    map[string]string `json:"extra_headers,omitempty"`
+}
+
+// Fields returns the values written to the access log line, keyed for the
+// structured formats
+func (r *AccessLogRecord) Fields() AccessLogFields {
+	f := AccessLogFields{
+		Host:            r.Request.Host,
+		StartedAt:       r.formatStartedAt(),
+		Method:          r.Request.Method,
+		URI:             r.Request.URL.RequestURI(),
+		Protocol:        r.Request.Proto,
+		StatusCode:      r.StatusCode,
+		BytesReceived:   r.RequestBytesReceived,
+		BytesSent:       r.BodyBytesSent,
+		Referer:         r.Request.Header.Get("Referer"),
+		UserAgent:       r.Request.Header.Get("User-Agent"),
+		RemoteAddr:      r.Request.RemoteAddr,
+		XForwardedFor:   r.Request.Header.Get("X-Forwarded-For"),
+		XForwardedProto: r.Request.Header.Get("X-Forwarded-Proto"),
+		VcapRequestID:   r.Request.Header.Get("X-Vcap-Request-Id"),
+		ResponseTime:    r.responseTime(),
+		Range:           r.Request.Header.Get("Range"),
+		ContentRange:    r.ContentRange,
+		GeoCountry:      r.GeoCountry,
+		GeoRegion:       r.GeoRegion,
+	}
+
+	if r.RouteEndpoint != nil {
+		f.AppID = r.RouteEndpoint.ApplicationId
+		f.AppIndex = r.RouteEndpoint.PrivateInstanceIndex
+		f.BackendAddr = r.RouteEndpoint.CanonicalAddr()
+	}
+
+	if len(r.ExtraHeadersToLog) > 0 {
+		f.ExtraHeaders = make(map[string]string, len(r.ExtraHeadersToLog))
+		for _, header := range r.ExtraHeadersToLog {
+			name := strings.Replace(strings.ToLower(header), "-", "_", -1)
+			f.ExtraHeaders[name] = r.Request.Header.Get(header)
+		}
+	}
+
+	return f
+}
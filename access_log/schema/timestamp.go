@@ -0,0 +1,27 @@
+package schema
+
+import (
+	"strconv"
+	"time"
+)
+
+// classicTimestampLayout is the layout of the access log timestamps when no
+// format is configured
+const classicTimestampLayout = "2006-01-02T15:04:05.000-0700"
+
+// FormatTimestamp renders t in one of the formats of config.TimestampFormats,
+// or in the classic layout for any other format
+func FormatTimestamp(t time.Time, format string) string {
+	switch format {
+	case "epoch":
+		return strconv.FormatFloat(float64(t.UnixNano())/float64(time.Second), 'f', -1, 64)
+	case "rfc3339":
+		return t.Format(time.RFC3339)
+	case "rfc3339nano":
+		return t.Format(time.RFC3339Nano)
+	case "unix_millis":
+		return strconv.FormatInt(t.UnixNano()/int64(time.Millisecond), 10)
+	default:
+		return t.Format(classicTimestampLayout)
+	}
+}
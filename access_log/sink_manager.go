@@ -0,0 +1,182 @@
+package access_log
+
+import (
+	"fmt"
+	"io"
+	"log/syslog"
+	"math/rand"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/cloudfoundry/dropsonde/logs"
+	"github.com/uber-go/zap"
+
+	"code.cloudfoundry.org/gorouter/access_log/schema"
+	"code.cloudfoundry.org/gorouter/config"
+	"code.cloudfoundry.org/gorouter/logger"
+)
+
+// Sink is a single access log destination with its own format and sampling
+// policy
+type Sink struct {
+	Name            string
+	Formatter       Formatter
+	SampleRate      float64
+	AlwaysLogErrors bool
+	TimestampFormat string
+	write           func(record *schema.AccessLogRecord, line []byte) error
+	flush           func() error
+}
+
+// NewWriterSink returns a sink writing formatted lines to w
+func NewWriterSink(name string, w io.Writer, formatter Formatter, sampleRate float64, alwaysLogErrors bool) *Sink {
+	w, flush := bufferFile(w)
+	return &Sink{
+		Name:            name,
+		Formatter:       formatter,
+		SampleRate:      sampleRate,
+		AlwaysLogErrors: alwaysLogErrors,
+		write: func(_ *schema.AccessLogRecord, line []byte) error {
+			_, err := w.Write(line)
+			return err
+		},
+		flush: flush,
+	}
+}
+
+// NewLoggregatorSink returns a sink emitting formatted lines as app logs for
+// the application that served the request
+func NewLoggregatorSink(name, sourceInstance string, formatter Formatter, sampleRate float64, alwaysLogErrors bool) *Sink {
+	return &Sink{
+		Name:            name,
+		Formatter:       formatter,
+		SampleRate:      sampleRate,
+		AlwaysLogErrors: alwaysLogErrors,
+		write: func(record *schema.AccessLogRecord, line []byte) error {
+			if appID := record.ApplicationID(); appID != "" {
+				return logs.SendAppLog(appID, string(line), "RTR", sourceInstance)
+			}
+			return nil
+		},
+		flush: func() error { return nil },
+	}
+}
+
+func (s *Sink) sampled(record *schema.AccessLogRecord, rng *rand.Rand) bool {
+	if s.AlwaysLogErrors && record.StatusCode >= 500 {
+		return true
+	}
+	return s.SampleRate >= 1 || rng.Float64() < s.SampleRate
+}
+
+// SinkManager is an AccessLogger fanning records out to several sinks
+type SinkManager struct {
+	sinks  []*Sink
+	ring   *recordRing
+	stopCh chan struct{}
+	rng    *rand.Rand
+	logger logger.Logger
+}
+
+// NewSinkManager returns a SinkManager for the given sinks
+func NewSinkManager(logger logger.Logger, sinks ...*Sink) *SinkManager {
+	return &SinkManager{
+		sinks:  sinks,
+		ring:   newRecordRing(logger),
+		stopCh: make(chan struct{}),
+		rng:    rand.New(rand.NewSource(time.Now().UnixNano())),
+		logger: logger,
+	}
+}
+
+// CreateSinks opens the sinks configured in access_log.sinks
+func CreateSinks(logger logger.Logger, c *config.Config) ([]*Sink, error) {
+	sinks := make([]*Sink, 0, len(c.AccessLog.Sinks))
+	for i, sc := range c.AccessLog.Sinks {
+		name := fmt.Sprintf("%d-%s", i, sc.Type)
+
+		formatter, err := NewFormatter(sc.Format, sc.Template)
+		if err != nil {
+			logger.Error("error-parsing-access-log-format", zap.String("sink", name), zap.Error(err))
+			return nil, err
+		}
+
+		var w io.Writer
+		switch sc.Type {
+		case config.ACCESS_LOG_SINK_FILE:
+			w, err = os.OpenFile(sc.Path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0666)
+			if err != nil {
+				logger.Error("error-creating-accesslog-file", zap.String("filename", sc.Path), zap.Error(err))
+				return nil, err
+			}
+		case config.ACCESS_LOG_SINK_SYSLOG:
+			w, err = syslog.Dial("", "", syslog.LOG_INFO, c.Logging.Syslog)
+			if err != nil {
+				logger.Error("error-creating-syslog-writer", zap.Error(err))
+				return nil, err
+			}
+		case config.ACCESS_LOG_SINK_STDOUT:
+			w = os.Stdout
+		case config.ACCESS_LOG_SINK_LOGGREGATOR:
+			sourceInstance := strconv.FormatUint(uint64(c.Index), 10)
+			sink := NewLoggregatorSink(name, sourceInstance, formatter, sc.SampleRate, sc.AlwaysLogErrors)
+			sink.TimestampFormat = sc.TimestampFormat
+			sinks = append(sinks, sink)
+			continue
+		default:
+			return nil, fmt.Errorf("unknown access log sink type: %s", sc.Type)
+		}
+
+		sink := NewWriterSink(name, w, formatter, sc.SampleRate, sc.AlwaysLogErrors)
+		sink.TimestampFormat = sc.TimestampFormat
+		sinks = append(sinks, sink)
+	}
+	return sinks, nil
+}
+
+func (m *SinkManager) Run() {
+	m.ring.consume(m.stopCh, m.emit, m.flush)
+}
+
+func (m *SinkManager) emit(record *schema.AccessLogRecord) {
+	for _, s := range m.sinks {
+		if !s.sampled(record, m.rng) {
+			continue
+		}
+
+		record.TimestampFormat = s.TimestampFormat
+		line, err := s.Formatter.Format(record)
+		if err != nil {
+			m.logger.Error("error-formatting-access-log", zap.String("sink", s.Name), zap.Error(err))
+			continue
+		}
+
+		err = s.write(record, line)
+		if err != nil {
+			m.logger.Error("error-emitting-access-log", zap.String("sink", s.Name), zap.Error(err))
+		}
+	}
+}
+
+func (m *SinkManager) flush() {
+	for _, s := range m.sinks {
+		if err := s.flush(); err != nil {
+			m.logger.Error("error-flushing-access-log", zap.String("sink", s.Name), zap.Error(err))
+		}
+	}
+}
+
+func (m *SinkManager) Stop() {
+	close(m.stopCh)
+}
+
+// Log queues r for the sinks, dropping it when they fall too far behind
+func (m *SinkManager) Log(r schema.AccessLogRecord) {
+	m.ring.push(&r)
+}
+
+// Dropped returns the number of records dropped as the sinks fell behind
+func (m *SinkManager) Dropped() uint64 {
+	return m.ring.Dropped()
+}
@@ -0,0 +1,123 @@
+package access_log_test
+
+import (
+	"encoding/json"
+	"net/http"
+
+	. "code.cloudfoundry.org/gorouter/access_log"
+	"code.cloudfoundry.org/gorouter/access_log/schema"
+	"code.cloudfoundry.org/gorouter/logger"
+	"code.cloudfoundry.org/gorouter/test_util"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/onsi/gomega/gbytes"
+)
+
+var _ = Describe("SinkManager", func() {
+	var (
+		logger      logger.Logger
+		classic     *gbytes.Buffer
+		structured  *gbytes.Buffer
+		sinkManager *SinkManager
+	)
+
+	BeforeEach(func() {
+		logger = test_util.NewTestZapLogger("test")
+		classic = gbytes.NewBuffer()
+		structured = gbytes.NewBuffer()
+	})
+
+	AfterEach(func() {
+		sinkManager.Stop()
+	})
+
+	startSinkManager := func(sinks ...*Sink) {
+		sinkManager = NewSinkManager(logger, sinks...)
+		go sinkManager.Run()
+	}
+
+	It("writes each record to every sink in its own format", func() {
+		classicFormatter, err := NewFormatter("classic", "")
+		Expect(err).NotTo(HaveOccurred())
+		jsonFormatter, err := NewFormatter("json", "")
+		Expect(err).NotTo(HaveOccurred())
+
+		startSinkManager(
+			NewWriterSink("classic", classic, classicFormatter, 1, false),
+			NewWriterSink("json", structured, jsonFormatter, 1, false),
+		)
+		sinkManager.Log(*CreateAccessLogRecord())
+
+		Eventually(classic).Should(gbytes.Say(`foo.bar - \[.*\] "GET /quz\?wat HTTP/1.1" 200`))
+		Eventually(structured).Should(gbytes.Say(`\n`))
+
+		var fields schema.AccessLogFields
+		Expect(json.Unmarshal(structured.Contents(), &fields)).To(Succeed())
+		Expect(fields.Host).To(Equal("foo.bar"))
+		Expect(fields.URI).To(Equal("/quz?wat"))
+		Expect(fields.StatusCode).To(Equal(http.StatusOK))
+		Expect(fields.AppID).To(Equal("my_awesome_id"))
+	})
+
+	It("renders the template format", func() {
+		formatter, err := NewFormatter("template", `{{.Method}} {{.Host}}{{.URI}} {{.StatusCode}}`)
+		Expect(err).NotTo(HaveOccurred())
+
+		startSinkManager(NewWriterSink("template", classic, formatter, 1, false))
+		sinkManager.Log(*CreateAccessLogRecord())
+
+		Eventually(classic).Should(gbytes.Say("GET foo.bar/quz\\?wat 200\n"))
+	})
+
+	Context("when a sink samples records", func() {
+		var sampled *gbytes.Buffer
+
+		BeforeEach(func() {
+			sampled = gbytes.NewBuffer()
+			formatter, err := NewFormatter("classic", "")
+			Expect(err).NotTo(HaveOccurred())
+
+			startSinkManager(
+				NewWriterSink("all", classic, formatter, 1, false),
+				NewWriterSink("sampled", sampled, formatter, 0.000001, true),
+			)
+		})
+
+		It("skips records that are not sampled", func() {
+			sinkManager.Log(*CreateAccessLogRecord())
+
+			Eventually(classic).Should(gbytes.Say("foo.bar"))
+			Consistently(sampled.Contents).Should(BeEmpty())
+		})
+
+		It("always writes server errors when configured to", func() {
+			record := CreateAccessLogRecord()
+			record.StatusCode = http.StatusBadGateway
+			sinkManager.Log(*record)
+
+			Eventually(classic).Should(gbytes.Say("foo.bar"))
+			Eventually(sampled).Should(gbytes.Say(`" 502 `))
+		})
+	})
+
+	It("writes the timestamps of each sink in its format", func() {
+		formatter, err := NewFormatter("template", `{{.StartedAt}}`)
+		Expect(err).NotTo(HaveOccurred())
+
+		millis := NewWriterSink("millis", classic, formatter, 1, false)
+		millis.TimestampFormat = "unix_millis"
+		rfc3339 := NewWriterSink("rfc3339", structured, formatter, 1, false)
+		rfc3339.TimestampFormat = "rfc3339"
+		startSinkManager(millis, rfc3339)
+		sinkManager.Log(*CreateAccessLogRecord())
+
+		Eventually(classic).Should(gbytes.Say(`^\d{13}\n`))
+		Eventually(structured).Should(gbytes.Say(`^\d{4}-\d{2}-\d{2}T`))
+	})
+
+	It("rejects an invalid template", func() {
+		_, err := NewFormatter("template", "{{.Method")
+		Expect(err).To(HaveOccurred())
+	})
+})
@@ -0,0 +1,146 @@
+package audit
+
+import (
+	"io"
+	"log/syslog"
+	"os"
+	"sync/atomic"
+	"time"
+
+	"github.com/cloudfoundry/dropsonde/metrics"
+	"github.com/uber-go/zap"
+
+	"code.cloudfoundry.org/gorouter/config"
+	"code.cloudfoundry.org/gorouter/logger"
+)
+
+const (
+	// bufferSize is the number of records waiting to be written before new
+	// ones are dropped
+	bufferSize = 1024
+
+	droppedLogInterval = 10 * time.Second
+
+	// DroppedRecords counts the records dropped because the sinks fell behind
+	DroppedRecords = "audit_log_records_dropped"
+)
+
+//go:generate counterfeiter -o fakes/fake_audit_logger.go . Logger
+type Logger interface {
+	Run()
+	Stop()
+	Log(record Record)
+}
+
+type NullLogger struct{}
+
+func (x *NullLogger) Run()       {}
+func (x *NullLogger) Stop()      {}
+func (x *NullLogger) Log(Record) {}
+
+// WriterLogger writes audit records to a file and/or syslog, separately from
+// the router and access logs
+type WriterLogger struct {
+	channel chan Record
+	stopCh  chan struct{}
+	writer  io.Writer
+	logger  logger.Logger
+	dropped uint64
+
+	// droppedSince and droppedLogged are only used by Run
+	droppedSince  uint64
+	droppedLogged time.Time
+}
+
+// CreateRunningLogger returns a running audit logger for the configured sinks,
+// or a NullLogger when no sink is configured
+func CreateRunningLogger(logger logger.Logger, config *config.Config) (Logger, error) {
+	if config.AuditLog.File == "" && !config.AuditLog.EnableStreaming {
+		return &NullLogger{}, nil
+	}
+
+	var writers []io.Writer
+	if config.AuditLog.File != "" {
+		file, err := os.OpenFile(config.AuditLog.File, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0600)
+		if err != nil {
+			logger.Error("error-creating-audit-log-file", zap.String("filename", config.AuditLog.File), zap.Error(err))
+			return nil, err
+		}
+		writers = append(writers, file)
+	}
+
+	if config.AuditLog.EnableStreaming {
+		syslogWriter, err := syslog.Dial("", "", syslog.LOG_INFO|syslog.LOG_AUTHPRIV, config.AuditLog.SyslogTag)
+		if err != nil {
+			logger.Error("error-creating-audit-syslog-writer", zap.Error(err))
+			return nil, err
+		}
+		writers = append(writers, syslogWriter)
+	}
+
+	auditLogger := NewWriterLogger(logger, writers...)
+	go auditLogger.Run()
+	return auditLogger, nil
+}
+
+func NewWriterLogger(logger logger.Logger, ws ...io.Writer) *WriterLogger {
+	return &WriterLogger{
+		channel: make(chan Record, bufferSize),
+		stopCh:  make(chan struct{}),
+		writer:  io.MultiWriter(ws...),
+		logger:  logger,
+	}
+}
+
+func (x *WriterLogger) Run() {
+	for {
+		select {
+		case record := <-x.channel:
+			_, err := record.WriteTo(x.writer)
+			if err != nil {
+				x.logger.Error("error-emitting-audit-log", zap.Error(err))
+			}
+			x.reportDropped()
+		case <-x.stopCh:
+			return
+		}
+	}
+}
+
+func (x *WriterLogger) Stop() {
+	close(x.stopCh)
+}
+
+// Log queues a record. Records logged while the queue is full, or once the
+// logger stopped, are dropped and counted rather than waited for, as the
+// registry logs some of them holding its lock.
+func (x *WriterLogger) Log(r Record) {
+	select {
+	case x.channel <- r:
+	default:
+		atomic.AddUint64(&x.dropped, 1)
+	}
+}
+
+// Dropped returns the number of records dropped since the logger was created
+func (x *WriterLogger) Dropped() uint64 {
+	return atomic.LoadUint64(&x.dropped)
+}
+
+// reportDropped counts the records dropped since the last report, and logs
+// them at most once every droppedLogInterval
+func (x *WriterLogger) reportDropped() {
+	dropped := atomic.LoadUint64(&x.dropped)
+	if dropped == x.droppedSince {
+		return
+	}
+	metrics.AddToCounter(DroppedRecords, dropped-x.droppedSince)
+	x.droppedSince = dropped
+
+	now := time.Now()
+	if now.Sub(x.droppedLogged) < droppedLogInterval {
+		return
+	}
+	x.logger.Error("audit-log-records-dropped", zap.Uint64("dropped", dropped))
+	x.droppedLogged = now
+}
@@ -0,0 +1,109 @@
+package audit_test
+
+import (
+	"encoding/json"
+	"time"
+
+	"code.cloudfoundry.org/gorouter/audit"
+	"code.cloudfoundry.org/gorouter/config"
+	"code.cloudfoundry.org/gorouter/route"
+	"code.cloudfoundry.org/gorouter/test_util"
+	"code.cloudfoundry.org/routing-api/models"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/onsi/gomega/gbytes"
+)
+
+var _ = Describe("AuditLogger", func() {
+	var record audit.Record
+
+	BeforeEach(func() {
+		record = audit.Record{
+			Time:   time.Date(2017, time.March, 1, 12, 0, 0, 0, time.UTC),
+			Action: audit.ActionRegister,
+			Source: audit.SourceNATS,
+			URI:    "foo.example.com",
+			Endpoint: route.NewEndpoint("app-id", "192.168.1.1", 1234, "instance-id", "0", nil, -1, "",
+				models.ModificationTag{Guid: "abc", Index: 3}, "iso-seg"),
+		}
+	})
+
+	Describe("Record", func() {
+		It("writes a single JSON line", func() {
+			buffer := gbytes.NewBuffer()
+			_, err := record.WriteTo(buffer)
+			Expect(err).NotTo(HaveOccurred())
+
+			contents := buffer.Contents()
+			Expect(contents).To(HaveSuffix("\n"))
+
+			var line map[string]interface{}
+			Expect(json.Unmarshal(contents, &line)).To(Succeed())
+			Expect(line).To(HaveKeyWithValue("timestamp", "2017-03-01T12:00:00Z"))
+			Expect(line).To(HaveKeyWithValue("action", "register"))
+			Expect(line).To(HaveKeyWithValue("source", "nats"))
+			Expect(line).To(HaveKeyWithValue("uri", "foo.example.com"))
+			Expect(line).To(HaveKeyWithValue("app_id", "app-id"))
+			Expect(line).To(HaveKeyWithValue("backend", "192.168.1.1:1234"))
+			Expect(line).To(HaveKeyWithValue("private_instance_id", "instance-id"))
+			Expect(line).To(HaveKeyWithValue("isolation_segment", "iso-seg"))
+			Expect(line).To(HaveKeyWithValue("modification_tag", map[string]interface{}{"guid": "abc", "index": float64(3)}))
+		})
+	})
+
+	Describe("WriterLogger", func() {
+		It("writes logged records to its writers", func() {
+			buffer := gbytes.NewBuffer()
+			auditLogger := audit.NewWriterLogger(test_util.NewTestZapLogger("test"), buffer)
+			go auditLogger.Run()
+			defer auditLogger.Stop()
+
+			auditLogger.Log(record)
+
+			Eventually(buffer).Should(gbytes.Say(`"action":"register","source":"nats","uri":"foo.example.com"`))
+		})
+
+		It("drops the records logged while the queue is full rather than block", func() {
+			auditLogger := audit.NewWriterLogger(test_util.NewTestZapLogger("test"), gbytes.NewBuffer())
+
+			done := make(chan struct{})
+			go func() {
+				defer close(done)
+				for i := 0; i < 1025; i++ {
+					auditLogger.Log(record)
+				}
+			}()
+
+			Eventually(done).Should(BeClosed())
+			Expect(auditLogger.Dropped()).To(BeEquivalentTo(1))
+		})
+
+		It("drops the records logged once stopped", func() {
+			auditLogger := audit.NewWriterLogger(test_util.NewTestZapLogger("test"), gbytes.NewBuffer())
+			go auditLogger.Run()
+			auditLogger.Stop()
+
+			for i := 0; i < 2048; i++ {
+				auditLogger.Log(record)
+			}
+			Expect(auditLogger.Dropped()).To(BeNumerically(">=", 1024))
+		})
+	})
+
+	Describe("CreateRunningLogger", func() {
+		It("returns a NullLogger when no sink is configured", func() {
+			auditLogger, err := audit.CreateRunningLogger(test_util.NewTestZapLogger("test"), config.DefaultConfig())
+			Expect(err).NotTo(HaveOccurred())
+			Expect(auditLogger).To(BeAssignableToTypeOf(&audit.NullLogger{}))
+		})
+
+		It("returns an error when the file cannot be opened", func() {
+			cfg := config.DefaultConfig()
+			cfg.AuditLog.File = "/this/path/does/not/exist/audit.log"
+
+			_, err := audit.CreateRunningLogger(test_util.NewTestZapLogger("test"), cfg)
+			Expect(err).To(HaveOccurred())
+		})
+	})
+})
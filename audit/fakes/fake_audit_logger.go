@@ -0,0 +1,106 @@
+// This file was generated by counterfeiter
+package fakes
+
+import (
+	"sync"
+
+	"code.cloudfoundry.org/gorouter/audit"
+)
+
+type FakeLogger struct {
+	RunStub         func()
+	runMutex        sync.RWMutex
+	runArgsForCall  []struct{}
+	StopStub        func()
+	stopMutex       sync.RWMutex
+	stopArgsForCall []struct{}
+	LogStub         func(record audit.Record)
+	logMutex        sync.RWMutex
+	logArgsForCall  []struct {
+		record audit.Record
+	}
+	invocations      map[string][][]interface{}
+	invocationsMutex sync.RWMutex
+}
+
+func (fake *FakeLogger) Run() {
+	fake.runMutex.Lock()
+	fake.runArgsForCall = append(fake.runArgsForCall, struct{}{})
+	fake.recordInvocation("Run", []interface{}{})
+	fake.runMutex.Unlock()
+	if fake.RunStub != nil {
+		fake.RunStub()
+	}
+}
+
+func (fake *FakeLogger) RunCallCount() int {
+	fake.runMutex.RLock()
+	defer fake.runMutex.RUnlock()
+	return len(fake.runArgsForCall)
+}
+
+func (fake *FakeLogger) Stop() {
+	fake.stopMutex.Lock()
+	fake.stopArgsForCall = append(fake.stopArgsForCall, struct{}{})
+	fake.recordInvocation("Stop", []interface{}{})
+	fake.stopMutex.Unlock()
+	if fake.StopStub != nil {
+		fake.StopStub()
+	}
+}
+
+func (fake *FakeLogger) StopCallCount() int {
+	fake.stopMutex.RLock()
+	defer fake.stopMutex.RUnlock()
+	return len(fake.stopArgsForCall)
+}
+
+func (fake *FakeLogger) Log(record audit.Record) {
+	fake.logMutex.Lock()
+	fake.logArgsForCall = append(fake.logArgsForCall, struct {
+		record audit.Record
+	}{record})
+	fake.recordInvocation("Log", []interface{}{record})
+	fake.logMutex.Unlock()
+	if fake.LogStub != nil {
+		fake.LogStub(record)
+	}
+}
+
+func (fake *FakeLogger) LogCallCount() int {
+	fake.logMutex.RLock()
+	defer fake.logMutex.RUnlock()
+	return len(fake.logArgsForCall)
+}
+
+func (fake *FakeLogger) LogArgsForCall(i int) audit.Record {
+	fake.logMutex.RLock()
+	defer fake.logMutex.RUnlock()
+	return fake.logArgsForCall[i].record
+}
+
+func (fake *FakeLogger) Invocations() map[string][][]interface{} {
+	fake.invocationsMutex.RLock()
+	defer fake.invocationsMutex.RUnlock()
+	fake.runMutex.RLock()
+	defer fake.runMutex.RUnlock()
+	fake.stopMutex.RLock()
+	defer fake.stopMutex.RUnlock()
+	fake.logMutex.RLock()
+	defer fake.logMutex.RUnlock()
+	return fake.invocations
+}
+
+func (fake *FakeLogger) recordInvocation(key string, args []interface{}) {
+	fake.invocationsMutex.Lock()
+	defer fake.invocationsMutex.Unlock()
+	if fake.invocations == nil {
+		fake.invocations = map[string][][]interface{}{}
+	}
+	if fake.invocations[key] == nil {
+		fake.invocations[key] = [][]interface{}{}
+	}
+	fake.invocations[key] = append(fake.invocations[key], args)
+}
+
+var _ audit.Logger = new(FakeLogger)
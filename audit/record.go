@@ -0,0 +1,85 @@
+package audit
+
+import (
+	"encoding/json"
+	"io"
+	"time"
+
+	"code.cloudfoundry.org/gorouter/route"
+)
+
+// Actions recorded in the audit log
+const (
+	ActionRegister   = "register"
+	ActionUnregister = "unregister"
+	ActionPrune      = "prune"
+)
+
+// Sources of routing table mutations
+const (
+	SourceNATS       = "nats"
+	SourceRoutingAPI = "routing_api"
+	SourceAdminAPI   = "admin_api"
+	SourcePruner     = "pruner"
+	// SourceRouteTableOwner marks mutations received from the process that
+	// owns a shared routing table
+	SourceRouteTableOwner = "route_table_owner"
+	SourceStaticRoutes    = "static_routes"
+	SourceKubernetes      = "kubernetes"
+	SourceConsul          = "consul"
+)
+
+// Record represents a single mutation of the routing table
+type Record struct {
+	Time     time.Time
+	Action   string
+	Source   string
+	URI      route.Uri
+	Endpoint *route.Endpoint
+}
+
+type modificationTag struct {
+	Guid  string `json:"guid"`
+	Index uint32 `json:"index"`
+}
+
+type recordJSON struct {
+	Timestamp         string          `json:"timestamp"`
+	Action            string          `json:"action"`
+	Source            string          `json:"source"`
+	URI               string          `json:"uri"`
+	AppID             string          `json:"app_id,omitempty"`
+	Backend           string          `json:"backend,omitempty"`
+	PrivateInstanceID string          `json:"private_instance_id,omitempty"`
+	IsolationSegment  string          `json:"isolation_segment,omitempty"`
+	ModificationTag   modificationTag `json:"modification_tag"`
+}
+
+// WriteTo writes the record as a single line of JSON
+func (r Record) WriteTo(w io.Writer) (int64, error) {
+	j := recordJSON{
+		Timestamp: r.Time.UTC().Format(time.RFC3339Nano),
+		Action:    r.Action,
+		Source:    r.Source,
+		URI:       r.URI.String(),
+	}
+	if r.Endpoint != nil {
+		j.AppID = r.Endpoint.ApplicationId
+		j.Backend = r.Endpoint.CanonicalAddr()
+		j.PrivateInstanceID = r.Endpoint.PrivateInstanceId
+		j.IsolationSegment = r.Endpoint.IsolationSegment
+		j.ModificationTag = modificationTag{
+			Guid:  r.Endpoint.ModificationTag.Guid,
+			Index: r.Endpoint.ModificationTag.Index,
+		}
+	}
+
+	b, err := json.Marshal(j)
+	if err != nil {
+		return 0, err
+	}
+	b = append(b, '\n')
+
+	n, err := w.Write(b)
+	return int64(n), err
+}
@@ -0,0 +1,300 @@
+// Package bench measures the routing table and proxy in-process with
+// synthetic routes, for tracking performance regressions between builds.
+package bench
+
+import (
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"runtime"
+	"sort"
+	"sync"
+	"time"
+
+	"code.cloudfoundry.org/gorouter/access_log"
+	"code.cloudfoundry.org/gorouter/config"
+	"code.cloudfoundry.org/gorouter/logger"
+	"code.cloudfoundry.org/gorouter/metrics"
+	"code.cloudfoundry.org/gorouter/proxy"
+	"code.cloudfoundry.org/gorouter/registry"
+	"code.cloudfoundry.org/gorouter/route"
+	"code.cloudfoundry.org/gorouter/routeservice"
+	"code.cloudfoundry.org/gorouter/varz"
+	"code.cloudfoundry.org/routing-api/models"
+	"github.com/cloudfoundry/dropsonde"
+	"github.com/cloudfoundry/dropsonde/metric_sender"
+	"github.com/cloudfoundry/dropsonde/metricbatcher"
+)
+
+// proxyHost is the route registered for the dummy backend
+const proxyHost = "bench-backend.gorouter.bench"
+
+// Options size the synthetic routing table and the measured workloads
+type Options struct {
+	Routes            int
+	EndpointsPerRoute int
+	Lookups           int
+	ProxyRequests     int
+	Concurrency       int
+}
+
+// Report is the machine-readable result of a benchmark run
+type Report struct {
+	Routes          int                  `json:"routes"`
+	Endpoints       int                  `json:"endpoints"`
+	RegisterSeconds float64              `json:"register_seconds"`
+	Lookup          LookupReport         `json:"lookup"`
+	Proxy           ProxyReport          `json:"proxy"`
+	Memory          MemoryReport         `json:"memory"`
+	GoVersion       string               `json:"go_version"`
+	NumCPU          int                  `json:"num_cpu"`
+	GoMaxProcs      int                  `json:"gomaxprocs"`
+	RegistryMemory  registry.MemoryUsage `json:"registry_memory"`
+}
+
+type LookupReport struct {
+	Count       int     `json:"count"`
+	Seconds     float64 `json:"seconds"`
+	PerSecond   float64 `json:"per_second"`
+	NsPerLookup float64 `json:"ns_per_lookup"`
+}
+
+type ProxyReport struct {
+	Requests    int     `json:"requests"`
+	Errors      int     `json:"errors"`
+	Concurrency int     `json:"concurrency"`
+	Seconds     float64 `json:"seconds"`
+	PerSecond   float64 `json:"per_second"`
+	P50Ms       float64 `json:"p50_ms"`
+	P90Ms       float64 `json:"p90_ms"`
+	P99Ms       float64 `json:"p99_ms"`
+}
+
+type MemoryReport struct {
+	HeapAllocBytes uint64 `json:"heap_alloc_bytes"`
+	RegistryBytes  uint64 `json:"registry_heap_bytes"`
+	NumGC          uint32 `json:"num_gc"`
+}
+
+// Run builds a registry with the synthetic routes and measures route lookups
+// and proxying to a local dummy backend
+func Run(logger logger.Logger, opts Options) (Report, error) {
+	if opts.Routes <= 0 || opts.EndpointsPerRoute <= 0 {
+		return Report{}, errors.New("routes and endpoints per route must be positive")
+	}
+	if opts.Concurrency <= 0 {
+		opts.Concurrency = 1
+	}
+
+	report := Report{
+		Routes:     opts.Routes,
+		Endpoints:  opts.Routes * opts.EndpointsPerRoute,
+		GoVersion:  runtime.Version(),
+		NumCPU:     runtime.NumCPU(),
+		GoMaxProcs: runtime.GOMAXPROCS(0),
+	}
+
+	c := config.DefaultConfig()
+	// Without dropsonde.Initialize the autowired emitter discards everything
+	sender := metric_sender.NewMetricSender(dropsonde.AutowiredEmitter())
+	batcher := metricbatcher.New(sender, 5*time.Second)
+	defer batcher.Close()
+	reporter := metrics.NewMetricsReporter(sender, batcher)
+
+	heapBefore := heapAlloc()
+	r := registry.NewRouteRegistry(logger.Session("registry"), c, reporter)
+
+	uris := make([]route.Uri, opts.Routes)
+	start := time.Now()
+	for i := range uris {
+		uris[i] = route.Uri(fmt.Sprintf("app-%d.gorouter.bench", i))
+		for j := 0; j < opts.EndpointsPerRoute; j++ {
+			r.Register(uris[i], route.NewEndpoint(
+				fmt.Sprintf("app-%d", i), syntheticHost(i), uint16(1024+j),
+				fmt.Sprintf("app-%d-%d", i, j), fmt.Sprintf("%d", j),
+				nil, -1, "", models.ModificationTag{}, "",
+			))
+		}
+	}
+	report.RegisterSeconds = time.Since(start).Seconds()
+
+	heapAfter := heapAlloc()
+	if heapAfter > heapBefore {
+		report.Memory.RegistryBytes = heapAfter - heapBefore
+	}
+	report.RegistryMemory, _ = r.MemoryUsage()
+
+	report.Lookup = measureLookups(r, uris, opts.Lookups)
+
+	if opts.ProxyRequests > 0 {
+		proxyReport, err := measureProxy(logger, c, r, reporter, opts)
+		if err != nil {
+			return report, err
+		}
+		report.Proxy = proxyReport
+	}
+
+	var m runtime.MemStats
+	runtime.ReadMemStats(&m)
+	report.Memory.HeapAllocBytes = m.HeapAlloc
+	report.Memory.NumGC = m.NumGC
+
+	return report, nil
+}
+
+func measureLookups(r *registry.RouteRegistry, uris []route.Uri, count int) LookupReport {
+	start := time.Now()
+	for i := 0; i < count; i++ {
+		r.Lookup(uris[i%len(uris)])
+	}
+	elapsed := time.Since(start)
+
+	report := LookupReport{Count: count, Seconds: elapsed.Seconds()}
+	if count > 0 && elapsed > 0 {
+		report.PerSecond = float64(count) / elapsed.Seconds()
+		report.NsPerLookup = float64(elapsed.Nanoseconds()) / float64(count)
+	}
+	return report
+}
+
+func measureProxy(
+	logger logger.Logger,
+	c *config.Config,
+	r *registry.RouteRegistry,
+	reporter *metrics.MetricsReporter,
+	opts Options,
+) (ProxyReport, error) {
+	backend, err := serve(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		_, _ = io.WriteString(w, "ok")
+	}))
+	if err != nil {
+		return ProxyReport{}, err
+	}
+	defer backend.Close()
+
+	addr := backend.Addr().(*net.TCPAddr)
+	r.Register(proxyHost, route.NewEndpoint(
+		"bench-backend", addr.IP.String(), uint16(addr.Port), "bench-backend-0", "0",
+		nil, -1, "", models.ModificationTag{}, "",
+	))
+
+	heartbeatOK := int32(1)
+	p := proxy.NewProxy(logger.Session("proxy"), &access_log.NullAccessLogger{}, c, r,
+		metrics.NewCompositeReporter(varz.NewVarz(r), reporter),
+		&routeservice.RouteServiceConfig{}, &tls.Config{}, &heartbeatOK, nil, nil)
+	front, err := serve(p)
+	if err != nil {
+		return ProxyReport{}, err
+	}
+	defer front.Close()
+
+	client := &http.Client{
+		Transport: &http.Transport{MaxIdleConnsPerHost: opts.Concurrency},
+	}
+	url := "http://" + front.Addr().String() + "/"
+
+	var (
+		lock      sync.Mutex
+		latencies = make([]time.Duration, 0, opts.ProxyRequests)
+		errCount  int
+		wg        sync.WaitGroup
+	)
+	work := make(chan struct{})
+
+	start := time.Now()
+	for i := 0; i < opts.Concurrency; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for range work {
+				latency, err := proxyRequest(client, url)
+				lock.Lock()
+				if err != nil {
+					errCount++
+				} else {
+					latencies = append(latencies, latency)
+				}
+				lock.Unlock()
+			}
+		}()
+	}
+	for i := 0; i < opts.ProxyRequests; i++ {
+		work <- struct{}{}
+	}
+	close(work)
+	wg.Wait()
+	elapsed := time.Since(start)
+
+	sort.Sort(durations(latencies))
+	return ProxyReport{
+		Requests:    opts.ProxyRequests,
+		Errors:      errCount,
+		Concurrency: opts.Concurrency,
+		Seconds:     elapsed.Seconds(),
+		PerSecond:   float64(len(latencies)) / elapsed.Seconds(),
+		P50Ms:       percentileMs(latencies, 0.50),
+		P90Ms:       percentileMs(latencies, 0.90),
+		P99Ms:       percentileMs(latencies, 0.99),
+	}, nil
+}
+
+func proxyRequest(client *http.Client, url string) (time.Duration, error) {
+	req, err := http.NewRequest("GET", url, nil)
+	if err != nil {
+		return 0, err
+	}
+	req.Host = proxyHost
+
+	start := time.Now()
+	resp, err := client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	_, _ = io.Copy(ioutil.Discard, resp.Body)
+	_ = resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return 0, fmt.Errorf("unexpected status %d", resp.StatusCode)
+	}
+	return time.Since(start), nil
+}
+
+func serve(handler http.Handler) (net.Listener, error) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return nil, err
+	}
+	go func() {
+		_ = http.Serve(l, handler)
+	}()
+	return l, nil
+}
+
+// syntheticHost spreads the synthetic endpoints over 10.0.0.0/8
+func syntheticHost(i int) string {
+	return fmt.Sprintf("10.%d.%d.%d", (i>>16)&0xff, (i>>8)&0xff, i&0xff)
+}
+
+func heapAlloc() uint64 {
+	runtime.GC()
+	var m runtime.MemStats
+	runtime.ReadMemStats(&m)
+	return m.HeapAlloc
+}
+
+type durations []time.Duration
+
+func (d durations) Len() int           { return len(d) }
+func (d durations) Less(i, j int) bool { return d[i] < d[j] }
+func (d durations) Swap(i, j int)      { d[i], d[j] = d[j], d[i] }
+
+func percentileMs(sorted []time.Duration, p float64) float64 {
+	if len(sorted) == 0 {
+		return 0
+	}
+	i := int(float64(len(sorted)-1) * p)
+	return float64(sorted[i]) / float64(time.Millisecond)
+}
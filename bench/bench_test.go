@@ -0,0 +1,58 @@
+package bench_test
+
+import (
+	"bytes"
+	"encoding/json"
+
+	"code.cloudfoundry.org/gorouter/bench"
+	"code.cloudfoundry.org/gorouter/test_util"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Bench", func() {
+	It("reports registry, lookup and proxy measurements", func() {
+		report, err := bench.Run(test_util.NewTestZapLogger("bench"), bench.Options{
+			Routes:            50,
+			EndpointsPerRoute: 3,
+			Lookups:           500,
+			ProxyRequests:     20,
+			Concurrency:       4,
+		})
+		Expect(err).NotTo(HaveOccurred())
+
+		Expect(report.Routes).To(Equal(50))
+		Expect(report.Endpoints).To(Equal(150))
+		Expect(report.RegistryMemory.Endpoints).To(Equal(150))
+		Expect(report.Lookup.Count).To(Equal(500))
+		Expect(report.Lookup.PerSecond).To(BeNumerically(">", 0))
+		Expect(report.Proxy.Requests).To(Equal(20))
+		Expect(report.Proxy.Errors).To(BeZero())
+		Expect(report.Proxy.P99Ms).To(BeNumerically(">=", report.Proxy.P50Ms))
+		Expect(report.Memory.HeapAllocBytes).To(BeNumerically(">", 0))
+	})
+
+	It("skips the proxy benchmark without requests", func() {
+		report, err := bench.Run(test_util.NewTestZapLogger("bench"), bench.Options{Routes: 1, EndpointsPerRoute: 1})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(report.Proxy.Requests).To(BeZero())
+	})
+
+	It("requires routes and endpoints", func() {
+		_, err := bench.Run(test_util.NewTestZapLogger("bench"), bench.Options{})
+		Expect(err).To(HaveOccurred())
+	})
+
+	It("prints the report as JSON", func() {
+		var stdout, stderr bytes.Buffer
+		code := bench.Main([]string{"-routes", "10", "-endpoints", "1", "-lookups", "10", "-requests", "0"}, &stdout, &stderr)
+		Expect(code).To(Equal(0))
+
+		var report map[string]interface{}
+		Expect(json.Unmarshal(stdout.Bytes(), &report)).To(Succeed())
+		Expect(report).To(HaveKeyWithValue("routes", BeNumerically("==", 10)))
+		Expect(report).To(HaveKey("lookup"))
+		Expect(report).To(HaveKey("registry_memory"))
+	})
+})
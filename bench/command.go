@@ -0,0 +1,43 @@
+package bench
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+
+	"code.cloudfoundry.org/gorouter/logger"
+	"github.com/uber-go/zap"
+)
+
+// Main implements `gorouter bench [flags]`, printing the JSON report to
+// stdout. It returns the exit code.
+func Main(args []string, stdout, stderr io.Writer) int {
+	flags := flag.NewFlagSet("bench", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+
+	var opts Options
+	flags.IntVar(&opts.Routes, "routes", 10000, "Number of synthetic routes to register")
+	flags.IntVar(&opts.EndpointsPerRoute, "endpoints", 2, "Number of endpoints registered for each route")
+	flags.IntVar(&opts.Lookups, "lookups", 1000000, "Number of route lookups to measure")
+	flags.IntVar(&opts.ProxyRequests, "requests", 10000, "Number of requests proxied to the dummy backend; 0 skips the proxy benchmark")
+	flags.IntVar(&opts.Concurrency, "concurrency", 16, "Number of proxied requests in flight at once")
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+
+	lggr := logger.NewLogger("gorouter.bench", zap.ErrorLevel, zap.Output(zap.AddSync(stderr)))
+	report, err := Run(lggr, opts)
+	if err != nil {
+		fmt.Fprintln(stderr, err)
+		return 1
+	}
+
+	enc := json.NewEncoder(stdout)
+	err = enc.Encode(report)
+	if err != nil {
+		fmt.Fprintln(stderr, err)
+		return 1
+	}
+	return 0
+}
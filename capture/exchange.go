@@ -0,0 +1,96 @@
+package capture
+
+import (
+	"bytes"
+	"net/http"
+	"time"
+)
+
+// redactedHeaders are recorded without their values so a capture never holds
+// client credentials
+var redactedHeaders = []string{"Authorization", "Proxy-Authorization", "Cookie", "Set-Cookie"}
+
+// Exchange is the recorded metadata of one request and its response
+type Exchange struct {
+	StartedAt             time.Time   `json:"started_at"`
+	ResponseTime          float64     `json:"response_time"`
+	Method                string      `json:"method"`
+	Host                  string      `json:"host"`
+	URI                   string      `json:"uri"`
+	Protocol              string      `json:"protocol"`
+	RemoteAddr            string      `json:"remote_addr"`
+	RequestHeaders        http.Header `json:"request_headers"`
+	RequestBody           string      `json:"request_body,omitempty"`
+	RequestBodyTruncated  bool        `json:"request_body_truncated,omitempty"`
+	StatusCode            int         `json:"status_code"`
+	ResponseHeaders       http.Header `json:"response_headers"`
+	ResponseBody          string      `json:"response_body,omitempty"`
+	ResponseBodyTruncated bool        `json:"response_body_truncated,omitempty"`
+	BytesSent             int         `json:"bytes_sent"`
+	AppID                 string      `json:"app_id,omitempty"`
+	Backend               string      `json:"backend,omitempty"`
+}
+
+// NewExchange records the request line and headers of req
+func NewExchange(req *http.Request, startedAt time.Time) *Exchange {
+	return &Exchange{
+		StartedAt:      startedAt,
+		Method:         req.Method,
+		Host:           req.Host,
+		URI:            req.URL.RequestURI(),
+		Protocol:       req.Proto,
+		RemoteAddr:     req.RemoteAddr,
+		RequestHeaders: redact(req.Header),
+	}
+}
+
+// SetResponse records the response status and headers
+func (e *Exchange) SetResponse(statusCode int, header http.Header, bytesSent int, finishedAt time.Time) {
+	e.StatusCode = statusCode
+	e.ResponseHeaders = redact(header)
+	e.BytesSent = bytesSent
+	e.ResponseTime = finishedAt.Sub(e.StartedAt).Seconds()
+}
+
+func redact(header http.Header) http.Header {
+	h := make(http.Header, len(header))
+	for k, v := range header {
+		h[k] = append([]string(nil), v...)
+	}
+	for _, k := range redactedHeaders {
+		if _, ok := h[k]; ok {
+			h[k] = []string{"[REDACTED]"}
+		}
+	}
+	return h
+}
+
+// BodyBuffer keeps the first Limit bytes written to it and discards the rest
+type BodyBuffer struct {
+	Limit     int
+	buf       bytes.Buffer
+	truncated bool
+}
+
+func (b *BodyBuffer) Write(p []byte) (int, error) {
+	n := len(p)
+	if room := b.Limit - b.buf.Len(); n > room {
+		if room > 0 {
+			b.buf.Write(p[:room])
+		}
+		b.truncated = true
+		return n, nil
+	}
+	b.buf.Write(p)
+	return n, nil
+}
+
+// String returns the kept bytes
+func (b *BodyBuffer) String() string {
+	return b.buf.String()
+}
+
+// Truncated reports whether bytes past the limit were discarded
+func (b *BodyBuffer) Truncated() bool {
+	return b.truncated
+}
@@ -0,0 +1,65 @@
+package capture
+
+import (
+	"encoding/json"
+	"net/http"
+	"time"
+)
+
+// StartRequest is the body of a POST to the capture endpoint. Duration is a
+// Go duration string such as "5m".
+type StartRequest struct {
+	Filter
+	Duration string `json:"duration"`
+}
+
+// ServeHTTP exposes the recorder on the status server: GET returns the
+// capture status and exchanges, POST starts a capture and DELETE clears it
+func (r *Recorder) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	w.Header().Set("Connection", "close")
+
+	switch req.Method {
+	case "GET":
+		writeJSON(w, http.StatusOK, r.Status())
+	case "POST":
+		var start StartRequest
+		err := json.NewDecoder(req.Body).Decode(&start)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		var duration time.Duration
+		if start.Duration != "" {
+			duration, err = time.ParseDuration(start.Duration)
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+		}
+
+		err = r.Start(start.Filter, duration)
+		switch err {
+		case nil:
+		case ErrCaptureDisabled:
+			http.Error(w, err.Error(), http.StatusForbidden)
+			return
+		default:
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		writeJSON(w, http.StatusCreated, r.Status())
+	case "DELETE":
+		r.Stop()
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		w.Header().Set("Allow", "GET, POST, DELETE")
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	}
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(v)
+}
@@ -0,0 +1,218 @@
+package capture
+
+import (
+	"errors"
+	"math/rand"
+	"net/http"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Filter selects the requests recorded by a capture. All set fields must match.
+type Filter struct {
+	Host          string  `json:"host,omitempty"`
+	Header        string  `json:"header,omitempty"`
+	HeaderValue   string  `json:"header_value,omitempty"`
+	Percentage    float64 `json:"percentage,omitempty"`
+	IncludeBodies bool    `json:"include_bodies,omitempty"`
+}
+
+func (f *Filter) matches(req *http.Request, rng *rand.Rand) bool {
+	if f.Host != "" && !strings.EqualFold(hostWithoutPort(req.Host), f.Host) {
+		return false
+	}
+	if f.Header != "" {
+		values, ok := req.Header[http.CanonicalHeaderKey(f.Header)]
+		if !ok {
+			return false
+		}
+		if f.HeaderValue != "" && !containsValue(values, f.HeaderValue) {
+			return false
+		}
+	}
+	if f.Percentage > 0 && f.Percentage < 100 {
+		return rng.Float64()*100 < f.Percentage
+	}
+	return true
+}
+
+var (
+	ErrCaptureDisabled = errors.New("request capture is disabled")
+	ErrInvalidFilter   = errors.New("percentage must be between 0 and 100")
+)
+
+// Status describes the current or most recent capture
+type Status struct {
+	Active    bool       `json:"active"`
+	Filter    *Filter    `json:"filter,omitempty"`
+	StartedAt *time.Time `json:"started_at,omitempty"`
+	ExpiresAt *time.Time `json:"expires_at,omitempty"`
+	Matched   int        `json:"matched"`
+	Exchanges []Exchange `json:"exchanges"`
+}
+
+type session struct {
+	filter    Filter
+	startedAt time.Time
+	expiresAt time.Time
+	ring      []Exchange
+	next      int
+	matched   int
+}
+
+// Recorder keeps the exchanges matching the current capture filter in a ring
+// buffer. A capture stops on its own once it expires; the exchanges it
+// recorded stay readable until the next capture starts or it is cleared.
+type Recorder struct {
+	enabled      bool
+	maxEntries   int
+	maxBodyBytes int
+	maxDuration  time.Duration
+
+	active  int32
+	lock    sync.Mutex
+	session *session
+	rng     *rand.Rand
+}
+
+// NewRecorder returns a Recorder keeping at most maxEntries exchanges, with
+// bodies truncated to maxBodyBytes. Captures last at most maxDuration.
+func NewRecorder(enabled bool, maxEntries, maxBodyBytes int, maxDuration time.Duration) *Recorder {
+	return &Recorder{
+		enabled:      enabled,
+		maxEntries:   maxEntries,
+		maxBodyBytes: maxBodyBytes,
+		maxDuration:  maxDuration,
+		rng:          rand.New(rand.NewSource(time.Now().UnixNano())),
+	}
+}
+
+// Start begins a new capture, replacing any previous one. A zero or too long
+// duration is capped at the configured maximum.
+func (r *Recorder) Start(filter Filter, duration time.Duration) error {
+	if !r.enabled {
+		return ErrCaptureDisabled
+	}
+	if filter.Percentage < 0 || filter.Percentage > 100 {
+		return ErrInvalidFilter
+	}
+	if duration <= 0 || duration > r.maxDuration {
+		duration = r.maxDuration
+	}
+
+	now := time.Now()
+	r.lock.Lock()
+	r.session = &session{
+		filter:    filter,
+		startedAt: now,
+		expiresAt: now.Add(duration),
+		ring:      make([]Exchange, 0, r.maxEntries),
+	}
+	r.lock.Unlock()
+	atomic.StoreInt32(&r.active, 1)
+	return nil
+}
+
+// Stop ends the current capture and discards what it recorded
+func (r *Recorder) Stop() {
+	atomic.StoreInt32(&r.active, 0)
+	r.lock.Lock()
+	r.session = nil
+	r.lock.Unlock()
+}
+
+// Match reports whether req should be recorded and, if so, how many body
+// bytes to keep
+func (r *Recorder) Match(req *http.Request) (bodyLimit int, ok bool) {
+	if atomic.LoadInt32(&r.active) == 0 {
+		return 0, false
+	}
+
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	s := r.session
+	if s == nil || r.expire(s, time.Now()) {
+		return 0, false
+	}
+	if !s.filter.matches(req, r.rng) {
+		return 0, false
+	}
+	if s.filter.IncludeBodies {
+		bodyLimit = r.maxBodyBytes
+	}
+	return bodyLimit, true
+}
+
+// Record adds an exchange to the ring buffer, overwriting the oldest one when
+// it is full
+func (r *Recorder) Record(e *Exchange) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	s := r.session
+	if s == nil || e.StartedAt.Before(s.startedAt) {
+		return
+	}
+
+	s.matched++
+	if len(s.ring) < r.maxEntries {
+		s.ring = append(s.ring, *e)
+		return
+	}
+	s.ring[s.next] = *e
+	s.next = (s.next + 1) % r.maxEntries
+}
+
+// Status returns the capture with its exchanges, oldest first
+func (r *Recorder) Status() Status {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	s := r.session
+	if s == nil {
+		return Status{Exchanges: []Exchange{}}
+	}
+
+	filter := s.filter
+	startedAt, expiresAt := s.startedAt, s.expiresAt
+	exchanges := make([]Exchange, 0, len(s.ring))
+	exchanges = append(exchanges, s.ring[s.next:]...)
+	exchanges = append(exchanges, s.ring[:s.next]...)
+
+	return Status{
+		Active:    !r.expire(s, time.Now()),
+		Filter:    &filter,
+		StartedAt: &startedAt,
+		ExpiresAt: &expiresAt,
+		Matched:   s.matched,
+		Exchanges: exchanges,
+	}
+}
+
+// expire must be called with the lock held
+func (r *Recorder) expire(s *session, now time.Time) bool {
+	if now.Before(s.expiresAt) {
+		return false
+	}
+	atomic.StoreInt32(&r.active, 0)
+	return true
+}
+
+func hostWithoutPort(host string) string {
+	if pos := strings.Index(host, ":"); pos >= 0 {
+		return host[:pos]
+	}
+	return host
+}
+
+func containsValue(values []string, v string) bool {
+	for _, value := range values {
+		if value == v {
+			return true
+		}
+	}
+	return false
+}
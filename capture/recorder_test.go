@@ -0,0 +1,180 @@
+package capture_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"time"
+
+	"code.cloudfoundry.org/gorouter/capture"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Recorder", func() {
+	var (
+		recorder *capture.Recorder
+		req      *http.Request
+	)
+
+	record := func(req *http.Request) {
+		e := capture.NewExchange(req, time.Now())
+		e.SetResponse(http.StatusOK, http.Header{"Set-Cookie": []string{"session=secret"}}, 2, time.Now())
+		recorder.Record(e)
+	}
+
+	BeforeEach(func() {
+		recorder = capture.NewRecorder(true, 2, 16, time.Hour)
+		req = httptest.NewRequest("GET", "http://foo.example.com:8080/bar?baz=1", nil)
+		req.Header.Set("X-Debug", "on")
+		req.Header.Set("Authorization", "bearer secret")
+	})
+
+	It("does not match requests before a capture starts", func() {
+		_, ok := recorder.Match(req)
+		Expect(ok).To(BeFalse())
+	})
+
+	It("refuses to start when disabled", func() {
+		recorder = capture.NewRecorder(false, 2, 16, time.Hour)
+		Expect(recorder.Start(capture.Filter{}, time.Minute)).To(Equal(capture.ErrCaptureDisabled))
+	})
+
+	It("rejects a percentage above 100", func() {
+		Expect(recorder.Start(capture.Filter{Percentage: 150}, time.Minute)).To(Equal(capture.ErrInvalidFilter))
+	})
+
+	Context("with a host and header filter", func() {
+		BeforeEach(func() {
+			Expect(recorder.Start(capture.Filter{Host: "foo.example.com", Header: "X-Debug", HeaderValue: "on"}, time.Minute)).To(Succeed())
+		})
+
+		It("matches requests for the host with the header", func() {
+			bodyLimit, ok := recorder.Match(req)
+			Expect(ok).To(BeTrue())
+			Expect(bodyLimit).To(BeZero())
+		})
+
+		It("does not match other hosts", func() {
+			req.Host = "other.example.com"
+			_, ok := recorder.Match(req)
+			Expect(ok).To(BeFalse())
+		})
+
+		It("does not match a different header value", func() {
+			req.Header.Set("X-Debug", "off")
+			_, ok := recorder.Match(req)
+			Expect(ok).To(BeFalse())
+		})
+	})
+
+	It("returns the body limit when bodies are included", func() {
+		Expect(recorder.Start(capture.Filter{IncludeBodies: true}, time.Minute)).To(Succeed())
+		bodyLimit, ok := recorder.Match(req)
+		Expect(ok).To(BeTrue())
+		Expect(bodyLimit).To(Equal(16))
+	})
+
+	It("keeps the most recent exchanges, oldest first", func() {
+		Expect(recorder.Start(capture.Filter{}, time.Minute)).To(Succeed())
+		for _, path := range []string{"/one", "/two", "/three"} {
+			record(httptest.NewRequest("GET", "http://foo.example.com"+path, nil))
+		}
+
+		status := recorder.Status()
+		Expect(status.Active).To(BeTrue())
+		Expect(status.Matched).To(Equal(3))
+		Expect(status.Exchanges).To(HaveLen(2))
+		Expect(status.Exchanges[0].URI).To(Equal("/two"))
+		Expect(status.Exchanges[1].URI).To(Equal("/three"))
+	})
+
+	It("redacts credentials", func() {
+		Expect(recorder.Start(capture.Filter{}, time.Minute)).To(Succeed())
+		record(req)
+
+		exchange := recorder.Status().Exchanges[0]
+		Expect(exchange.RequestHeaders.Get("Authorization")).To(Equal("[REDACTED]"))
+		Expect(exchange.RequestHeaders.Get("X-Debug")).To(Equal("on"))
+		Expect(exchange.ResponseHeaders.Get("Set-Cookie")).To(Equal("[REDACTED]"))
+	})
+
+	It("stops matching once the capture expires", func() {
+		Expect(recorder.Start(capture.Filter{}, 10*time.Millisecond)).To(Succeed())
+		record(req)
+
+		Eventually(func() bool {
+			_, ok := recorder.Match(req)
+			return ok
+		}).Should(BeFalse())
+
+		status := recorder.Status()
+		Expect(status.Active).To(BeFalse())
+		Expect(status.Exchanges).To(HaveLen(1))
+	})
+
+	It("discards the capture when stopped", func() {
+		Expect(recorder.Start(capture.Filter{}, time.Minute)).To(Succeed())
+		record(req)
+		recorder.Stop()
+
+		_, ok := recorder.Match(req)
+		Expect(ok).To(BeFalse())
+		Expect(recorder.Status().Exchanges).To(BeEmpty())
+	})
+
+	Describe("ServeHTTP", func() {
+		serve := func(method, body string) *httptest.ResponseRecorder {
+			w := httptest.NewRecorder()
+			recorder.ServeHTTP(w, httptest.NewRequest(method, "/capture", strings.NewReader(body)))
+			return w
+		}
+
+		It("starts a capture on POST", func() {
+			w := serve("POST", `{"host":"foo.example.com","percentage":50,"duration":"5m"}`)
+			Expect(w.Code).To(Equal(http.StatusCreated))
+
+			var status capture.Status
+			Expect(json.Unmarshal(w.Body.Bytes(), &status)).To(Succeed())
+			Expect(status.Active).To(BeTrue())
+			Expect(status.Filter.Host).To(Equal("foo.example.com"))
+			Expect(status.ExpiresAt.Sub(*status.StartedAt)).To(Equal(5 * time.Minute))
+		})
+
+		It("caps the duration at the configured maximum", func() {
+			serve("POST", `{"duration":"48h"}`)
+			status := recorder.Status()
+			Expect(status.ExpiresAt.Sub(*status.StartedAt)).To(Equal(time.Hour))
+		})
+
+		It("rejects an invalid duration", func() {
+			Expect(serve("POST", `{"duration":"soon"}`).Code).To(Equal(http.StatusBadRequest))
+		})
+
+		It("is forbidden when capturing is disabled", func() {
+			recorder = capture.NewRecorder(false, 2, 16, time.Hour)
+			Expect(serve("POST", `{}`).Code).To(Equal(http.StatusForbidden))
+		})
+
+		It("returns the exchanges on GET", func() {
+			serve("POST", `{}`)
+			record(req)
+
+			w := serve("GET", "")
+			Expect(w.Code).To(Equal(http.StatusOK))
+
+			var status capture.Status
+			Expect(json.Unmarshal(w.Body.Bytes(), &status)).To(Succeed())
+			Expect(status.Exchanges).To(HaveLen(1))
+			Expect(status.Exchanges[0].Host).To(Equal("foo.example.com:8080"))
+		})
+
+		It("clears the capture on DELETE", func() {
+			serve("POST", `{}`)
+			Expect(serve("DELETE", "").Code).To(Equal(http.StatusNoContent))
+			Expect(recorder.Status().Filter).To(BeNil())
+		})
+	})
+})
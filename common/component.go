@@ -82,12 +82,20 @@ func (p *ProcessStatus) StopUpdate() {
 
 var procStat *ProcessStatus
 
+// ETagger is implemented by the info routes able to tell whether their
+// content changed without marshaling it, which are then served with an ETag
+// and answer 304 to requests carrying it in If-None-Match
+type ETagger interface {
+	ETag() string
+}
+
 type VcapComponent struct {
 	Config     interface{}     `json:"-"`
 	Varz       *health.Varz    `json:"-"`
 	Healthz    *health.Healthz `json:"-"`
 	Health     http.Handler
 	InfoRoutes map[string]json.Marshaler `json:"-"`
+	Handlers   map[string]http.Handler   `json:"-"`
 	Logger     logger.Logger             `json:"-"`
 
 	listener net.Listener
@@ -100,6 +108,7 @@ type RouterStart struct {
 	Hosts                            []string `json:"hosts"`
 	MinimumRegisterIntervalInSeconds int      `json:"minimumRegisterIntervalInSeconds"`
 	PruneThresholdInSeconds          int      `json:"pruneThresholdInSeconds"`
+	Capabilities                     []string `json:"capabilities,omitempty"`
 }
 
 func (c *VcapComponent) UpdateVarz() {
@@ -235,6 +244,20 @@ func (c *VcapComponent) ListenAndServe() {
 		m := marshaler
 		hs.HandleFunc(path, func(w http.ResponseWriter, req *http.Request) {
 			w.Header().Set("Connection", "close")
+			// info routes that are handlers too serve themselves, such as
+			// those negotiating their encoding
+			if handler, ok := m.(http.Handler); ok {
+				handler.ServeHTTP(w, req)
+				return
+			}
+			if tagger, ok := m.(ETagger); ok {
+				etag := tagger.ETag()
+				w.Header().Set("ETag", etag)
+				if ETagMatches(req.Header.Get("If-None-Match"), etag) {
+					w.WriteHeader(http.StatusNotModified)
+					return
+				}
+			}
 			w.Header().Set("Content-Type", "application/json")
 			w.WriteHeader(http.StatusOK)
 
@@ -243,6 +266,10 @@ func (c *VcapComponent) ListenAndServe() {
 		})
 	}
 
+	for path, handler := range c.Handlers {
+		hs.Handle(path, handler)
+	}
+
 	f := func(user, password string) bool {
 		return user == c.Varz.Credentials[0] && password == c.Varz.Credentials[1]
 	}
@@ -28,6 +28,15 @@ func (m *MarshalableValue) MarshalJSON() ([]byte, error) {
 	return json.Marshal(m.Value)
 }
 
+type TaggedValue struct {
+	MarshalableValue
+	Tag string
+}
+
+func (t *TaggedValue) ETag() string {
+	return t.Tag
+}
+
 var _ = Describe("Component", func() {
 	var (
 		component *VcapComponent
@@ -118,6 +127,36 @@ var _ = Describe("Component", func() {
 		Expect(body).To(Equal(`{"key":"value"}` + "\n"))
 	})
 
+	It("serves info routes conditionally on their etag", func() {
+		path := "/test"
+
+		component.InfoRoutes = map[string]json.Marshaler{
+			path: &TaggedValue{MarshalableValue: MarshalableValue{Value: map[string]string{"key": "value"}}, Tag: `"v1"`},
+		}
+		serveComponent(component)
+
+		req := buildGetRequest(component, path)
+		req.SetBasicAuth("username", "password")
+		code, header, body := doGetRequest(req)
+		Expect(code).To(Equal(200))
+		Expect(header.Get("ETag")).To(Equal(`"v1"`))
+		Expect(body).To(Equal(`{"key":"value"}` + "\n"))
+
+		req = buildGetRequest(component, path)
+		req.SetBasicAuth("username", "password")
+		req.Header.Set("If-None-Match", `"v0", W/"v1"`)
+		code, header, body = doGetRequest(req)
+		Expect(code).To(Equal(304))
+		Expect(header.Get("ETag")).To(Equal(`"v1"`))
+		Expect(body).To(BeEmpty())
+
+		req = buildGetRequest(component, path)
+		req.SetBasicAuth("username", "password")
+		req.Header.Set("If-None-Match", `"v0"`)
+		code, _, _ = doGetRequest(req)
+		Expect(code).To(Equal(200))
+	})
+
 	It("updates the uptime statistic", func() {
 		stringMap := make(map[string]interface{})
 		path := "/varz"
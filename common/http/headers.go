@@ -1,6 +1,9 @@
 package http
 
-import "net/http"
+import (
+	"net/http"
+	"strings"
+)
 
 const (
 	VcapBackendHeader     = "X-Vcap-Backend"
@@ -10,10 +13,44 @@ const (
 	CfInstanceIdHeader    = "X-CF-InstanceID"
 	CfAppInstance         = "X-CF-APP-INSTANCE"
 	CfRouterError         = "X-Cf-RouterError"
+	// CfRouteServiceForwarded marks responses to requests a route service
+	// forwarded, so that responses the route service sent itself can be told
+	// apart. It is removed before the response reaches the client.
+	CfRouteServiceForwarded = "X-Cf-Route-Service-Forwarded"
+	// GeoCountryHeader carries the ISO country code of the client to backends
+	GeoCountryHeader = "X-Geo-Country"
+	// EarlyDataHeader is set to "1" by a TLS terminator forwarding a request
+	// it received as 0-RTT early data (RFC 8470)
+	EarlyDataHeader = "Early-Data"
+	// CfRouteDebug asks, with the trace key as value, for a summary of how
+	// the request was routed in the response header of the same name
+	CfRouteDebug = "X-Cf-Route-Debug"
+	// ExperimentBucketHeader carries the bucket of an experiment the client
+	// was assigned to to backends
+	ExperimentBucketHeader = "X-Experiment-Bucket"
 )
 
+// StatusClientClosedRequest is recorded, as nginx does, for the requests
+// whose client went away before the response started
+const StatusClientClosedRequest = 499
+
 func SetTraceHeaders(responseWriter http.ResponseWriter, routerIp, addr string) {
 	responseWriter.Header().Set(VcapRouterHeader, routerIp)
 	responseWriter.Header().Set(VcapBackendHeader, addr)
 	responseWriter.Header().Set(CfRouteEndpointHeader, addr)
 }
+
+// ETagMatches reports whether an If-None-Match header value lists etag,
+// weakly compared, or is "*"
+func ETagMatches(ifNoneMatch, etag string) bool {
+	if ifNoneMatch == "" {
+		return false
+	}
+	for _, candidate := range strings.Split(ifNoneMatch, ",") {
+		candidate = strings.TrimSpace(candidate)
+		if candidate == "*" || strings.TrimPrefix(candidate, "W/") == strings.TrimPrefix(etag, "W/") {
+			return true
+		}
+	}
+	return false
+}
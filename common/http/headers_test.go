@@ -32,4 +32,14 @@ var _ = Describe("Headers", func() {
 			Expect(respWriter.Header().Get(commonhttp.CfRouteEndpointHeader)).To(Equal("example.com"))
 		})
 	})
+
+	Describe("ETagMatches", func() {
+		It("matches the listed etags, weakly compared", func() {
+			Expect(commonhttp.ETagMatches(`"a", "b"`, `"b"`)).To(BeTrue())
+			Expect(commonhttp.ETagMatches(`W/"b"`, `"b"`)).To(BeTrue())
+			Expect(commonhttp.ETagMatches("*", `"b"`)).To(BeTrue())
+			Expect(commonhttp.ETagMatches(`"a"`, `"b"`)).To(BeFalse())
+			Expect(commonhttp.ETagMatches("", `"b"`)).To(BeFalse())
+		})
+	})
 })
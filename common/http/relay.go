@@ -0,0 +1,14 @@
+package http
+
+import "io"
+
+// ReadFrom copies r to w with the ReadFrom of w when it has one. Conns
+// wrapping another call it from their own ReadFrom so as not to hide the
+// ReadFrom of the conn they wrap, with which responses and upgraded
+// connections are relayed with splice or sendfile.
+func ReadFrom(w io.Writer, r io.Reader) (int64, error) {
+	if rf, ok := w.(io.ReaderFrom); ok {
+		return rf.ReadFrom(r)
+	}
+	return io.Copy(w, r)
+}
@@ -0,0 +1,40 @@
+package http_test
+
+import (
+	"bytes"
+	"io"
+	"strings"
+
+	commonhttp "code.cloudfoundry.org/gorouter/common/http"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+type readerFrom struct {
+	bytes.Buffer
+	called bool
+}
+
+func (r *readerFrom) ReadFrom(src io.Reader) (int64, error) {
+	r.called = true
+	return r.Buffer.ReadFrom(src)
+}
+
+var _ = Describe("ReadFrom", func() {
+	It("copies with the ReadFrom of the writer", func() {
+		w := &readerFrom{}
+		n, err := commonhttp.ReadFrom(w, strings.NewReader("some body"))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(n).To(BeEquivalentTo(9))
+		Expect(w.called).To(BeTrue())
+		Expect(w.String()).To(Equal("some body"))
+	})
+
+	It("copies to writers without one", func() {
+		var buf bytes.Buffer
+		n, err := commonhttp.ReadFrom(struct{ io.Writer }{&buf}, strings.NewReader("some body"))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(n).To(BeEquivalentTo(9))
+		Expect(buf.String()).To(Equal("some body"))
+	})
+})
@@ -2,7 +2,10 @@ package config
 
 import (
 	"crypto/tls"
+	"crypto/x509"
+	"errors"
 	"fmt"
+	"net"
 	"net/url"
 
 	"io/ioutil"
@@ -16,12 +19,72 @@ import (
 
 const LOAD_BALANCE_RR string = "round-robin"
 const LOAD_BALANCE_LC string = "least-connection"
+const LOAD_BALANCE_CH string = "consistent-hash"
 const SHARD_ALL string = "all"
 const SHARD_SEGMENTS string = "segments"
 const SHARD_SHARED_AND_SEGMENTS string = "shared-and-segments"
+const STALE_UPDATE_DROP string = "drop"
+const STALE_UPDATE_OVERWRITE string = "overwrite"
+const STALE_UPDATE_LOG_ONLY string = "log-only"
+const ROUTE_TABLE_SHARING_OWNER string = "owner"
+const ROUTE_TABLE_SHARING_FOLLOWER string = "follower"
+const ACCESS_LOG_SINK_FILE string = "file"
+const ACCESS_LOG_SINK_SYSLOG string = "syslog"
+const ACCESS_LOG_SINK_STDOUT string = "stdout"
+const ACCESS_LOG_SINK_LOGGREGATOR string = "loggregator"
+const ACCESS_LOG_FORMAT_CLASSIC string = "classic"
+const ACCESS_LOG_FORMAT_JSON string = "json"
+const ACCESS_LOG_FORMAT_TEMPLATE string = "template"
+const EXTERNAL_PLUGIN_STAGE_PRE_LOOKUP string = "pre_lookup"
+const EXTERNAL_PLUGIN_STAGE_POST_LOOKUP string = "post_lookup"
+const EXTERNAL_PLUGIN_STAGE_PRE_PROXY string = "pre_proxy"
+const EXTERNAL_PLUGIN_FAIL_OPEN string = "fail_open"
+const EXTERNAL_PLUGIN_FAIL_CLOSED string = "fail_closed"
+const PRIORITY_HIGH string = "high"
+const PRIORITY_NORMAL string = "normal"
+const PRIORITY_LOW string = "low"
+const REQUEST_VALIDATION_OFF string = "off"
+const REQUEST_VALIDATION_MONITOR string = "monitor"
+const REQUEST_VALIDATION_NORMALIZE string = "normalize"
+const REQUEST_VALIDATION_REJECT string = "reject"
+const URL_PERCENT_DECODING_NONE string = "none"
+const URL_PERCENT_DECODING_UNRESERVED string = "unreserved"
+const ROUTE_VISIBILITY_EXTERNAL string = "external"
+const ROUTE_VISIBILITY_INTERNAL string = "internal"
+const LOG_OUTPUT_FILE string = "file"
+const LOG_OUTPUT_STDOUT string = "stdout"
+const TIMESTAMP_FORMAT_EPOCH string = "epoch"
+const TIMESTAMP_FORMAT_RFC3339 string = "rfc3339"
+const TIMESTAMP_FORMAT_RFC3339_NANO string = "rfc3339nano"
+const TIMESTAMP_FORMAT_UNIX_MILLIS string = "unix_millis"
+const CONNECTION_LIMIT_POLICY_TOO_MANY_REQUESTS string = "too_many_requests"
+const CONNECTION_LIMIT_POLICY_RESET string = "reset"
+const FORWARDED_URL_QUERY_INCLUDE string = "include"
+const FORWARDED_URL_QUERY_STRIP string = "strip"
+const DOMAIN_ACCESS_LOG_ALL string = "all"
+const DOMAIN_ACCESS_LOG_ERRORS string = "errors"
+const DOMAIN_ACCESS_LOG_NONE string = "none"
+const HEALTHCHECK_LISTENER_HTTP string = "http"
+const HEALTHCHECK_LISTENER_HTTPS string = "https"
+const HEALTHCHECK_LISTENER_INTERNAL string = "internal"
 
-var LoadBalancingStrategies = []string{LOAD_BALANCE_RR, LOAD_BALANCE_LC}
+var LoadBalancingStrategies = []string{LOAD_BALANCE_RR, LOAD_BALANCE_LC, LOAD_BALANCE_CH}
 var AllowedShardingModes = []string{SHARD_ALL, SHARD_SEGMENTS, SHARD_SHARED_AND_SEGMENTS}
+var StaleUpdatePolicies = []string{STALE_UPDATE_DROP, STALE_UPDATE_OVERWRITE, STALE_UPDATE_LOG_ONLY}
+var AccessLogSinkTypes = []string{ACCESS_LOG_SINK_FILE, ACCESS_LOG_SINK_SYSLOG, ACCESS_LOG_SINK_STDOUT, ACCESS_LOG_SINK_LOGGREGATOR}
+var AccessLogFormats = []string{ACCESS_LOG_FORMAT_CLASSIC, ACCESS_LOG_FORMAT_JSON, ACCESS_LOG_FORMAT_TEMPLATE}
+var ExternalPluginStages = []string{EXTERNAL_PLUGIN_STAGE_PRE_LOOKUP, EXTERNAL_PLUGIN_STAGE_POST_LOOKUP, EXTERNAL_PLUGIN_STAGE_PRE_PROXY}
+var ExternalPluginFailurePolicies = []string{EXTERNAL_PLUGIN_FAIL_OPEN, EXTERNAL_PLUGIN_FAIL_CLOSED}
+var LogOutputTypes = []string{LOG_OUTPUT_FILE, LOG_OUTPUT_STDOUT}
+var HealthCheckListeners = []string{HEALTHCHECK_LISTENER_HTTP, HEALTHCHECK_LISTENER_HTTPS, HEALTHCHECK_LISTENER_INTERNAL}
+var ConnectionLimitPolicies = []string{CONNECTION_LIMIT_POLICY_TOO_MANY_REQUESTS, CONNECTION_LIMIT_POLICY_RESET}
+var TimestampFormats = []string{TIMESTAMP_FORMAT_EPOCH, TIMESTAMP_FORMAT_RFC3339, TIMESTAMP_FORMAT_RFC3339_NANO, TIMESTAMP_FORMAT_UNIX_MILLIS}
+var Priorities = []string{PRIORITY_HIGH, PRIORITY_NORMAL, PRIORITY_LOW}
+var RequestValidationLevels = []string{REQUEST_VALIDATION_OFF, REQUEST_VALIDATION_MONITOR, REQUEST_VALIDATION_NORMALIZE, REQUEST_VALIDATION_REJECT}
+var URLPercentDecodingPolicies = []string{URL_PERCENT_DECODING_NONE, URL_PERCENT_DECODING_UNRESERVED}
+var RouteServiceResponseLogLevels = []string{"debug", "info"}
+var ForwardedURLQueryPolicies = []string{FORWARDED_URL_QUERY_INCLUDE, FORWARDED_URL_QUERY_STRIP}
+var DomainAccessLogPolicies = []string{DOMAIN_ACCESS_LOG_ALL, DOMAIN_ACCESS_LOG_ERRORS, DOMAIN_ACCESS_LOG_NONE}
 
 type StatusConfig struct {
 	Host string `yaml:"host"`
@@ -48,6 +111,10 @@ type RoutingApiConfig struct {
 	Uri          string `yaml:"uri"`
 	Port         int    `yaml:"port"`
 	AuthDisabled bool   `yaml:"auth_disabled"`
+	// ReconcileInterval, when positive, is how often the registry is
+	// compared with the routing API, and RepairDrift repairs the differences
+	ReconcileInterval time.Duration `yaml:"reconcile_interval"`
+	RepairDrift       bool          `yaml:"repair_drift"`
 }
 
 var defaultNatsConfig = NatsConfig{
@@ -72,194 +139,1988 @@ type LoggingConfig struct {
 	LoggregatorEnabled bool   `yaml:"loggregator_enabled"`
 	MetronAddress      string `yaml:"metron_address"`
 
+	// Components overrides Level for the loggers of components, such as
+	// registry, proxy, route-services or mbus
+	Components map[string]string `yaml:"components"`
+	Sampling   LogSamplingConfig `yaml:"sampling"`
+
+	// Outputs replace stdout as the destinations of the logs
+	Outputs []LogOutput `yaml:"outputs"`
+
+	// TimestampFormat is one of TimestampFormats
+	TimestampFormat string `yaml:"timestamp_format"`
+
+	EnvelopeBatching EnvelopeBatchingConfig `yaml:"envelope_batching"`
+
 	// This field is populated by the `Process` function.
 	JobName string `yaml:"-"`
 }
 
+// EnvelopeBatchingConfig has the metric and log envelopes sent to the
+// loggregator agent from a goroutine of their own, every FlushInterval or once
+// MaxBatchSize envelopes are waiting. Envelopes emitted while a full batch
+// waits to be sent are dropped.
+type EnvelopeBatchingConfig struct {
+	Enabled       bool          `yaml:"enabled"`
+	FlushInterval time.Duration `yaml:"flush_interval"`
+	MaxBatchSize  int           `yaml:"max_batch_size"`
+}
+
+var defaultEnvelopeBatchingConfig = EnvelopeBatchingConfig{
+	FlushInterval: 250 * time.Millisecond,
+	MaxBatchSize:  1000,
+}
+
+// LogSamplingConfig limits the listed messages, when logged at debug or info
+// level, to the First entries of every second and every Thereafter-th entry
+// after them
+type LogSamplingConfig struct {
+	Messages   []string `yaml:"messages"`
+	First      int      `yaml:"first"`
+	Thereafter int      `yaml:"thereafter"`
+}
+
+// LogOutput is one destination for the router's logs. File outputs rotate
+// themselves when Rotation is set.
+type LogOutput struct {
+	Type     string            `yaml:"type"`
+	Path     string            `yaml:"path"`
+	Rotation LogRotationConfig `yaml:"rotation"`
+}
+
+// LogRotationConfig rotates a log file before it grows beyond MaxSize bytes
+// and every Interval, keeping the newest MaxBackups rotated files. Zero
+// disables each of them.
+type LogRotationConfig struct {
+	MaxSize    int64         `yaml:"max_size"`
+	Interval   time.Duration `yaml:"interval"`
+	MaxBackups int           `yaml:"max_backups"`
+}
+
 type AccessLog struct {
+	File            string          `yaml:"file"`
+	EnableStreaming bool            `yaml:"enable_streaming"`
+	Sinks           []AccessLogSink `yaml:"sinks"`
+
+	// TimestampFormat is one of TimestampFormats, or empty for the classic
+	// layout. It is the default of the sinks.
+	TimestampFormat string `yaml:"timestamp_format"`
+}
+
+// AccessLogSink is one destination for access log lines. When any sinks are
+// configured they replace the file and enable_streaming settings.
+type AccessLogSink struct {
+	Type            string  `yaml:"type"`
+	Path            string  `yaml:"path"`
+	Format          string  `yaml:"format"`
+	Template        string  `yaml:"template"`
+	SampleRate      float64 `yaml:"sample_rate"`
+	AlwaysLogErrors bool    `yaml:"always_log_errors"`
+	TimestampFormat string  `yaml:"timestamp_format"`
+}
+
+// defaultAccessLogSink logs every request, so that a sample_rate of 0 can
+// mean logging none
+var defaultAccessLogSink = AccessLogSink{
+	SampleRate: 1,
+}
+
+// UnmarshalYAML starts every sink from defaultAccessLogSink, as the defaults
+// of the sinks cannot be set in defaultConfig
+func (s *AccessLogSink) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	type plain AccessLogSink
+	sink := plain(defaultAccessLogSink)
+	if err := unmarshal(&sink); err != nil {
+		return err
+	}
+	*s = AccessLogSink(sink)
+	return nil
+}
+
+// AuditLog configures the sinks recording every mutation of the routing table
+type AuditLog struct {
 	File            string `yaml:"file"`
 	EnableStreaming bool   `yaml:"enable_streaming"`
+	SyslogTag       string `yaml:"syslog_tag"`
 }
 
-type Tracing struct {
-	EnableZipkin bool `yaml:"enable_zipkin"`
+// ConsistentHashConfig selects the request attribute used as the hash key by
+// the consistent-hash load balancing algorithm. The header is preferred over
+// the cookie; when neither is present on a request the client IP is used.
+type ConsistentHashConfig struct {
+	Header   string `yaml:"header"`
+	Cookie   string `yaml:"cookie"`
+	Replicas int    `yaml:"replicas"`
 }
 
-var defaultLoggingConfig = LoggingConfig{
-	Level:         "debug",
-	MetronAddress: "localhost:3457",
+var defaultConsistentHashConfig = ConsistentHashConfig{
+	Replicas: 100,
 }
 
-type Config struct {
-	Status                   StatusConfig  `yaml:"status"`
-	Nats                     []NatsConfig  `yaml:"nats"`
-	Logging                  LoggingConfig `yaml:"logging"`
-	Port                     uint16        `yaml:"port"`
-	Index                    uint          `yaml:"index"`
-	Zone                     string        `yaml:"zone"`
-	GoMaxProcs               int           `yaml:"go_max_procs,omitempty"`
-	Tracing                  Tracing       `yaml:"tracing"`
-	TraceKey                 string        `yaml:"trace_key"`
-	AccessLog                AccessLog     `yaml:"access_log"`
-	EnableAccessLogStreaming bool          `yaml:"enable_access_log_streaming"`
-	DebugAddr                string        `yaml:"debug_addr"`
-	EnablePROXY              bool          `yaml:"enable_proxy"`
-	EnableSSL                bool          `yaml:"enable_ssl"`
-	SSLPort                  uint16        `yaml:"ssl_port"`
-	SSLCertPath              string        `yaml:"ssl_cert_path"`
-	SSLKeyPath               string        `yaml:"ssl_key_path"`
-	SSLCertificate           tls.Certificate
-	SkipSSLValidation        bool     `yaml:"skip_ssl_validation"`
-	ForceForwardedProtoHttps bool     `yaml:"force_forwarded_proto_https"`
-	IsolationSegments        []string `yaml:"isolation_segments"`
-	RoutingTableShardingMode string   `yaml:"routing_table_sharding_mode"`
+// UpgradeProtocolsConfig restricts which Upgrade protocols are forwarded to
+// backends. Routes are keyed by host and replace the global list for that host.
+// An empty list allows every protocol.
+type UpgradeProtocolsConfig struct {
+	Allowed []string            `yaml:"allowed"`
+	Routes  map[string][]string `yaml:"routes"`
+}
 
-	CipherString string `yaml:"cipher_suites"`
-	CipherSuites []uint16
+// CanaryAnalysisConfig splits response metrics into endpoint groups by the
+// value of an endpoint tag, so that a canary group can be compared with the
+// stable one. Endpoints without the tag belong to DefaultGroup.
+type CanaryAnalysisConfig struct {
+	Tag          string `yaml:"tag"`
+	DefaultGroup string `yaml:"default_group"`
+}
 
-	LoadBalancerHealthyThreshold    time.Duration `yaml:"load_balancer_healthy_threshold"`
-	PublishStartMessageInterval     time.Duration `yaml:"publish_start_message_interval"`
-	SuspendPruningIfNatsUnavailable bool          `yaml:"suspend_pruning_if_nats_unavailable"`
-	PruneStaleDropletsInterval      time.Duration `yaml:"prune_stale_droplets_interval"`
-	DropletStaleThreshold           time.Duration `yaml:"droplet_stale_threshold"`
-	PublishActiveAppsInterval       time.Duration `yaml:"publish_active_apps_interval"`
-	StartResponseDelayInterval      time.Duration `yaml:"start_response_delay_interval"`
-	EndpointTimeout                 time.Duration `yaml:"endpoint_timeout"`
-	RouteServiceTimeout             time.Duration `yaml:"route_services_timeout"`
+// ExperimentBucket receives a share of the clients of an experiment
+// proportional to its Weight, 1 by default
+type ExperimentBucket struct {
+	Name   string `yaml:"name"`
+	Weight int    `yaml:"weight"`
+}
 
-	DrainWait            time.Duration `yaml:"drain_wait,omitempty"`
-	DrainTimeout         time.Duration `yaml:"drain_timeout,omitempty"`
-	SecureCookies        bool          `yaml:"secure_cookies"`
-	HealthCheckUserAgent string        `yaml:"healthcheck_user_agent,omitempty"`
+// ExperimentConfig assigns the clients of the routes of Host, a host name or
+// a wildcard such as "*.apps.example.com", to one of Buckets by the hash of
+// their Cookie or, without it, of their IP, so that clients keep their
+// bucket. With Tag, requests are routed to the endpoints whose Tag names the
+// bucket of the client, when there are any.
+type ExperimentConfig struct {
+	Name    string             `yaml:"name"`
+	Host    string             `yaml:"host"`
+	Cookie  string             `yaml:"cookie"`
+	Tag     string             `yaml:"tag"`
+	Buckets []ExperimentBucket `yaml:"buckets"`
+}
 
-	OAuth                      OAuthConfig      `yaml:"oauth"`
-	RoutingApi                 RoutingApiConfig `yaml:"routing_api"`
-	RouteServiceSecret         string           `yaml:"route_services_secret"`
-	RouteServiceSecretPrev     string           `yaml:"route_services_secret_decrypt_only"`
-	RouteServiceRecommendHttps bool             `yaml:"route_services_recommend_https"`
-	// These fields are populated by the `Process` function.
-	Ip                     string        `yaml:"-"`
-	RouteServiceEnabled    bool          `yaml:"-"`
-	NatsClientPingInterval time.Duration `yaml:"-"`
+// UpstreamProxy is a proxy backends can be dialed through, such as a
+// corporate egress gateway. URL is either http://host:port, for a proxy
+// supporting CONNECT, or socks5://host:port, with the credentials of the
+// proxy as its user info when it requires any.
+type UpstreamProxy struct {
+	Name string `yaml:"name"`
+	URL  string `yaml:"url"`
+}
 
-	ExtraHeadersToLog []string `yaml:"extra_headers_to_log"`
+// UpstreamProxiesConfig lists the upstream proxies endpoints may name in
+// their registration. The backends of the endpoints naming none are dialed
+// through the Default proxy, or directly without one.
+type UpstreamProxiesConfig struct {
+	Proxies []UpstreamProxy `yaml:"proxies"`
+	Default string          `yaml:"default"`
+}
 
-	TokenFetcherMaxRetries                    uint32        `yaml:"token_fetcher_max_retries"`
-	TokenFetcherRetryInterval                 time.Duration `yaml:"token_fetcher_retry_interval"`
-	TokenFetcherExpirationBufferTimeInSeconds int64         `yaml:"token_fetcher_expiration_buffer_time"`
+// RouteRegistrationAuthConfig requires router.register and router.unregister
+// messages to carry an HMAC-SHA256 signature. Messages naming a key_id are
+// checked against PublisherKeys, all others against SharedKey.
+type RouteRegistrationAuthConfig struct {
+	Enabled       bool              `yaml:"enabled"`
+	SharedKey     string            `yaml:"shared_key"`
+	PublisherKeys map[string]string `yaml:"publisher_keys"`
+}
 
-	PidFile     string `yaml:"pid_file"`
-	LoadBalance string `yaml:"balancing_algorithm"`
+// RouteTableSharingConfig lets several gorouter processes on one VM share a
+// routing table. The owner syncs from NATS and the routing API and streams the
+// table over a unix socket; followers read it from there instead.
+type RouteTableSharingConfig struct {
+	Mode          string        `yaml:"mode"`
+	Socket        string        `yaml:"socket"`
+	RetryInterval time.Duration `yaml:"retry_interval"`
+}
 
-	DisableKeepAlives   bool `yaml:"disable_keep_alives"`
-	MaxIdleConns        int  `yaml:"max_idle_conns"`
-	MaxIdleConnsPerHost int  `yaml:"max_idle_conns_per_host"`
+var defaultRouteTableSharingConfig = RouteTableSharingConfig{
+	RetryInterval: 1 * time.Second,
 }
 
-var defaultConfig = Config{
-	Status:  defaultStatusConfig,
-	Nats:    []NatsConfig{defaultNatsConfig},
-	Logging: defaultLoggingConfig,
+// RequestCaptureConfig bounds the debugging captures started through the
+// status server's /capture endpoint. Captures are refused unless enabled.
+type RequestCaptureConfig struct {
+	Enabled      bool          `yaml:"enabled"`
+	MaxEntries   int           `yaml:"max_entries"`
+	MaxBodyBytes int           `yaml:"max_body_bytes"`
+	MaxDuration  time.Duration `yaml:"max_duration"`
+}
 
-	Port:        8081,
-	Index:       0,
-	GoMaxProcs:  -1,
-	EnablePROXY: false,
-	EnableSSL:   false,
-	SSLPort:     443,
+var defaultRequestCaptureConfig = RequestCaptureConfig{
+	MaxEntries:   1000,
+	MaxBodyBytes: 4096,
+	MaxDuration:  30 * time.Minute,
+}
 
-	EndpointTimeout:     60 * time.Second,
-	RouteServiceTimeout: 60 * time.Second,
+// SlowRequestLogConfig logs requests whose total time, or time spent waiting
+// for backends across all attempts, reaches a threshold. A zero threshold is
+// not checked.
+type SlowRequestLogConfig struct {
+	TotalThreshold   time.Duration `yaml:"total_threshold"`
+	BackendThreshold time.Duration `yaml:"backend_threshold"`
+}
 
-	PublishStartMessageInterval:               30 * time.Second,
-	PruneStaleDropletsInterval:                30 * time.Second,
-	DropletStaleThreshold:                     120 * time.Second,
-	PublishActiveAppsInterval:                 0 * time.Second,
-	StartResponseDelayInterval:                5 * time.Second,
-	TokenFetcherMaxRetries:                    3,
-	TokenFetcherRetryInterval:                 5 * time.Second,
-	TokenFetcherExpirationBufferTimeInSeconds: 30,
+// AnomalyDetectionConfig enables alerting on routes whose 5xx rate in a window
+// exceeds their baseline by more than Sigma standard deviations
+type AnomalyDetectionConfig struct {
+	Enabled         bool          `yaml:"enabled"`
+	Window          time.Duration `yaml:"window"`
+	BaselineWindows int           `yaml:"baseline_windows"`
+	Sigma           float64       `yaml:"sigma"`
+	MinRequests     int           `yaml:"min_requests"`
+	MaxRoutes       int           `yaml:"max_routes"`
+}
 
-	HealthCheckUserAgent: "HTTP-Monitor/1.1",
-	LoadBalance:          LOAD_BALANCE_RR,
+var defaultAnomalyDetectionConfig = AnomalyDetectionConfig{
+	Window:          time.Minute,
+	BaselineWindows: 30,
+	Sigma:           3,
+	MinRequests:     20,
+	MaxRoutes:       10000,
+}
 
-	RoutingTableShardingMode: "all",
+// AuthFailureMetricsConfig enables the per-route counters of backend 401 and
+// 403 responses served on the status server's /metrics/auth_failures
+// endpoint. Routes whose backends answer Threshold requests or more with 401
+// or 403 within a Window are logged and counted. A zero Threshold never
+// alerts.
+type AuthFailureMetricsConfig struct {
+	Enabled   bool          `yaml:"enabled"`
+	Window    time.Duration `yaml:"window"`
+	Threshold int           `yaml:"threshold"`
+	MaxRoutes int           `yaml:"max_routes"`
+}
 
-	DisableKeepAlives:   true,
-	MaxIdleConns:        100,
-	MaxIdleConnsPerHost: 2,
+var defaultAuthFailureMetricsConfig = AuthFailureMetricsConfig{
+	Window:    time.Minute,
+	Threshold: 100,
+	MaxRoutes: 10000,
 }
 
-func DefaultConfig() *Config {
-	c := defaultConfig
-	c.Process()
+// LoadSheddingConfig sets budgets for the router's goroutines and open file
+// descriptors. Requests are rejected with a 503 once usage reaches a budget,
+// before the process runs into its limits. A budget of 0 is not enforced.
+type LoadSheddingConfig struct {
+	MaxGoroutines int           `yaml:"max_goroutines"`
+	MaxOpenFiles  int           `yaml:"max_open_files"`
+	CheckInterval time.Duration `yaml:"check_interval"`
+	RetryAfter    time.Duration `yaml:"retry_after"`
+}
 
-	return &c
+var defaultLoadSheddingConfig = LoadSheddingConfig{
+	CheckInterval: time.Second,
+	RetryAfter:    5 * time.Second,
 }
 
-func (c *Config) Process() {
-	var err error
+// ConcurrencyLimits bound the adaptive concurrency limit of a route. The limit
+// shrinks once the latency of recent requests exceeds Tolerance times the
+// route's long-term latency.
+type ConcurrencyLimits struct {
+	InitialLimit int     `yaml:"initial_limit"`
+	MinLimit     int     `yaml:"min_limit"`
+	MaxLimit     int     `yaml:"max_limit"`
+	Tolerance    float64 `yaml:"tolerance"`
+}
 
-	if c.GoMaxProcs == -1 {
-		c.GoMaxProcs = runtime.NumCPU()
+// AdaptiveConcurrencyConfig limits the requests in flight to each route to a
+// limit that adapts to the latency of its backends. Requests beyond the limit
+// are rejected with a 503. Routes are keyed by host and override the default
+// limits for that host; fields left out fall back to the defaults.
+//
+// When QueueSize is set, normal and high priority requests beyond the limit
+// wait up to QueueTimeout for a slot, high priority ones first.
+type AdaptiveConcurrencyConfig struct {
+	Enabled      bool                         `yaml:"enabled"`
+	Limits       ConcurrencyLimits            `yaml:"limits"`
+	Routes       map[string]ConcurrencyLimits `yaml:"routes"`
+	MaxRoutes    int                          `yaml:"max_routes"`
+	RetryAfter   time.Duration                `yaml:"retry_after"`
+	QueueSize    int                          `yaml:"queue_size"`
+	QueueTimeout time.Duration                `yaml:"queue_timeout"`
+}
+
+var defaultAdaptiveConcurrencyConfig = AdaptiveConcurrencyConfig{
+	Limits: ConcurrencyLimits{
+		InitialLimit: 20,
+		MinLimit:     5,
+		MaxLimit:     1000,
+		Tolerance:    1.5,
+	},
+	MaxRoutes:    10000,
+	RetryAfter:   time.Second,
+	QueueTimeout: 100 * time.Millisecond,
+}
+
+// RequestPriorityConfig classifies requests as high, normal or low priority.
+// Under overload low priority requests are shed first and high priority ones
+// last. Routes are keyed by host; other hosts get Default. When Header is set,
+// requests from TrustedSources (CIDRs) may name their class in it.
+type RequestPriorityConfig struct {
+	Default        string            `yaml:"default"`
+	Routes         map[string]string `yaml:"routes"`
+	Header         string            `yaml:"header"`
+	TrustedSources []string          `yaml:"trusted_sources"`
+
+	// Populated by process from TrustedSources
+	TrustedNetworks []*net.IPNet `yaml:"-"`
+}
+
+var defaultRequestPriorityConfig = RequestPriorityConfig{
+	Default: PRIORITY_NORMAL,
+}
+
+// RouteServiceBypassConfig lets requests skip the route service bound to their
+// route and go straight to its backends. Requests qualify when they come from
+// one of the TrustedSources (CIDRs), or carry an X-Cf-Route-Service-Bypass
+// header signed with Secret no longer than MaxAge ago.
+type RouteServiceBypassConfig struct {
+	TrustedSources []string      `yaml:"trusted_sources"`
+	Secret         string        `yaml:"secret"`
+	MaxAge         time.Duration `yaml:"max_age"`
+
+	// Populated by process from TrustedSources
+	TrustedNetworks []*net.IPNet `yaml:"-"`
+}
+
+var defaultRouteServiceBypassConfig = RouteServiceBypassConfig{
+	MaxAge: 5 * time.Minute,
+}
+
+// RouteServiceResponsesConfig sets how gorouter treats responses a route
+// service sent itself instead of forwarding the request to the route's
+// backends. Non-2xx direct responses are logged at LogLevel, or at error level
+// once their status reaches ErrorStatus; 0 never logs them as errors. With
+// PassThrough gorouter adds no trace headers to them.
+type RouteServiceResponsesConfig struct {
+	LogLevel    string `yaml:"log_level"`
+	ErrorStatus int    `yaml:"error_status"`
+	PassThrough bool   `yaml:"pass_through"`
+}
+
+var defaultRouteServiceResponsesConfig = RouteServiceResponsesConfig{
+	LogLevel: "info",
+}
+
+// RouteServiceForwardedURLConfig shapes the URL of the request a route service
+// is sent, which it calls back to reach the backends. By default it has the
+// scheme recommended by route_services_recommend_https, the host without its
+// port and the query of the request. PreserveScheme keeps the scheme the client
+// used, from X-Forwarded-Proto when set, and PreservePort the port of the Host
+// header. Query strips the query with "strip". The URL is sent in Header.
+type RouteServiceForwardedURLConfig struct {
+	PreserveScheme bool   `yaml:"preserve_scheme"`
+	PreservePort   bool   `yaml:"preserve_port"`
+	Query          string `yaml:"query"`
+	Header         string `yaml:"header"`
+}
+
+var defaultRouteServiceForwardedURLConfig = RouteServiceForwardedURLConfig{
+	Query:  FORWARDED_URL_QUERY_INCLUDE,
+	Header: "X-CF-Forwarded-Url",
+}
+
+// RetriesConfig bounds the attempts to proxy a request to MaxAttempts. With
+// LimitToPoolSize a request is also attempted at most once per endpoint of
+// its route, so that a route with a single endpoint is not retried on it.
+type RetriesConfig struct {
+	MaxAttempts     int  `yaml:"max_attempts"`
+	LimitToPoolSize bool `yaml:"limit_to_pool_size"`
+}
+
+var defaultRetriesConfig = RetriesConfig{
+	MaxAttempts:     3,
+	LimitToPoolSize: true,
+}
+
+// HeaderPolicy removes the headers listed in Remove from a request or
+// response, then sets those in Set.
+type HeaderPolicy struct {
+	Remove []string          `yaml:"remove"`
+	Set    map[string]string `yaml:"set"`
+}
+
+// DomainProfile holds the policies of the requests for Domain and its
+// subdomains, so that system and app domains can be treated differently
+// without metadata on each route. EndpointTimeout, when set, bounds the time
+// to proxy a request, and can only shorten endpoint_timeout. MaxAttempts,
+// when set, replaces the number of endpoints tried for a request. AccessLog
+// logs all requests, only those failing with "errors", or none of them.
+type DomainProfile struct {
+	Domain          string        `yaml:"domain"`
+	EndpointTimeout time.Duration `yaml:"endpoint_timeout"`
+	MaxAttempts     int           `yaml:"max_attempts"`
+	RequestHeaders  HeaderPolicy  `yaml:"request_headers"`
+	ResponseHeaders HeaderPolicy  `yaml:"response_headers"`
+	AccessLog       string        `yaml:"access_log"`
+}
+
+// Matches reports whether host, without its port, is the profile's domain or
+// one of its subdomains
+func (d *DomainProfile) Matches(host string) bool {
+	return inDomain(host, d.Domain)
+}
+
+func inDomain(host, domain string) bool {
+	if len(host) == len(domain) {
+		return strings.EqualFold(host, domain)
 	}
+	return len(host) > len(domain) &&
+		host[len(host)-len(domain)-1] == '.' &&
+		strings.EqualFold(host[len(host)-len(domain):], domain)
+}
 
-	c.Logging.JobName = "gorouter"
-	if c.StartResponseDelayInterval > c.DropletStaleThreshold {
-		c.DropletStaleThreshold = c.StartResponseDelayInterval
+// StaleThresholdClass replaces droplet_stale_threshold for a class of
+// endpoints whose emitters refresh them at their own cadence, such as system
+// components: those registered for Domain or its subdomains, when set, and
+// carrying all of Tags. Endpoints registering a shorter threshold keep it. The
+// first class matching an endpoint applies. Backends are all reached over
+// plaintext, so classes cannot select on TLS; tags can stand in for it.
+type StaleThresholdClass struct {
+	Name      string            `yaml:"name"`
+	Domain    string            `yaml:"domain"`
+	Tags      map[string]string `yaml:"tags"`
+	Threshold time.Duration     `yaml:"threshold"`
+}
+
+// Matches reports whether the endpoints of host, without its port, carrying
+// tags are of the class
+func (s *StaleThresholdClass) Matches(host string, tags map[string]string) bool {
+	if s.Domain != "" && !inDomain(host, s.Domain) {
+		return false
+	}
+	for name, value := range s.Tags {
+		if v, ok := tags[name]; !ok || v != value {
+			return false
+		}
 	}
+	return true
+}
 
-	// To avoid routes getting purged because of unresponsive NATS server
-	// we need to set the ping interval of nats client such that it fails over
-	// to next NATS server before dropletstalethreshold is hit. We are hardcoding the ping interval
-	// to 20 sec because the operators cannot set the value of DropletStaleThreshold and StartResponseDelayInterval
-	// ping_interval = ((DropletStaleThreshold- StartResponseDelayInterval)-minimumRegistrationInterval+(2 * number_of_nats_servers))/3
-	c.NatsClientPingInterval = 20 * time.Second
+// DNSCacheConfig enables caching the addresses of the host names gorouter
+// dials: route service hosts and backends registered by host name. Addresses
+// are kept for TTL and failed lookups for NegativeTTL.
+type DNSCacheConfig struct {
+	Enabled     bool          `yaml:"enabled"`
+	TTL         time.Duration `yaml:"ttl"`
+	NegativeTTL time.Duration `yaml:"negative_ttl"`
+	MaxEntries  int           `yaml:"max_entries"`
+}
 
-	if c.DrainTimeout == 0 || c.DrainTimeout == defaultConfig.EndpointTimeout {
-		c.DrainTimeout = c.EndpointTimeout
+var defaultDNSCacheConfig = DNSCacheConfig{
+	TTL:         30 * time.Second,
+	NegativeTTL: 5 * time.Second,
+	MaxEntries:  10000,
+}
+
+// StaticRoutesConfig registers the routes listed in File, a YAML or JSON
+// file polled every PollInterval, for deployments without NATS or the
+// routing API
+type StaticRoutesConfig struct {
+	File         string        `yaml:"file"`
+	PollInterval time.Duration `yaml:"poll_interval"`
+}
+
+var defaultStaticRoutesConfig = StaticRoutesConfig{
+	PollInterval: 5 * time.Second,
+}
+
+// KubernetesConfig registers the ready endpoints of Kubernetes Services
+// carrying the gorouter.cloudfoundry.org/routes annotation. The defaults
+// suit a gorouter running in the cluster under a service account.
+type KubernetesConfig struct {
+	Enabled        bool          `yaml:"enabled"`
+	APIServer      string        `yaml:"api_server"`
+	TokenFile      string        `yaml:"token_file"`
+	CAFile         string        `yaml:"ca_file"`
+	Namespace      string        `yaml:"namespace"`
+	ResyncInterval time.Duration `yaml:"resync_interval"`
+	RetryInterval  time.Duration `yaml:"retry_interval"`
+}
+
+var defaultKubernetesConfig = KubernetesConfig{
+	APIServer:      "https://kubernetes.default.svc",
+	TokenFile:      "/var/run/secrets/kubernetes.io/serviceaccount/token",
+	CAFile:         "/var/run/secrets/kubernetes.io/serviceaccount/ca.crt",
+	ResyncInterval: 30 * time.Second,
+	RetryInterval:  5 * time.Second,
+}
+
+// ConsulConfig syncs the instances of the services in a Consul catalog. Each
+// instance is routed under RouteTemplate, in which {service}, {datacenter}
+// and {domain} are expanded.
+type ConsulConfig struct {
+	Enabled        bool          `yaml:"enabled"`
+	Address        string        `yaml:"address"`
+	Token          string        `yaml:"token"`
+	Datacenter     string        `yaml:"datacenter"`
+	Domain         string        `yaml:"domain"`
+	RouteTemplate  string        `yaml:"route_template"`
+	Tag            string        `yaml:"tag"`
+	PassingOnly    bool          `yaml:"passing_only"`
+	ResyncInterval time.Duration `yaml:"resync_interval"`
+	RetryInterval  time.Duration `yaml:"retry_interval"`
+}
+
+var defaultConsulConfig = ConsulConfig{
+	Address:        "http://127.0.0.1:8500",
+	Domain:         "consul",
+	RouteTemplate:  "{service}.{datacenter}.{domain}",
+	PassingOnly:    true,
+	ResyncInterval: 30 * time.Second,
+	RetryInterval:  5 * time.Second,
+}
+
+// XdsConfig serves the routing table to Envoy over gRPC on Address, as EDS
+// clusters and the route configuration RouteConfigName
+type XdsConfig struct {
+	Enabled         bool          `yaml:"enabled"`
+	Address         string        `yaml:"address"`
+	RefreshInterval time.Duration `yaml:"refresh_interval"`
+	RouteConfigName string        `yaml:"route_config_name"`
+	ConnectTimeout  time.Duration `yaml:"connect_timeout"`
+}
+
+var defaultXdsConfig = XdsConfig{
+	Address:         "127.0.0.1:18000",
+	RefreshInterval: 1 * time.Second,
+	RouteConfigName: "gorouter",
+	ConnectTimeout:  5 * time.Second,
+}
+
+// ErrorPagesConfig names the static pages a route registration can select to
+// be served instead of the generic 502 when none of the route's endpoints
+// could handle a request. Pages sent inline with a registration are dropped
+// when they are larger than MaxInlineBytes.
+type ErrorPagesConfig struct {
+	Files          map[string]string `yaml:"files"`
+	MaxInlineBytes int               `yaml:"max_inline_bytes"`
+
+	// Populated by process from Files
+	Pages map[string][]byte `yaml:"-"`
+}
+
+var defaultErrorPagesConfig = ErrorPagesConfig{
+	MaxInlineBytes: 16 * 1024,
+}
+
+// GeoIPConfig locates clients in the MaxMind DB file Database, checked for
+// changes every ReloadInterval, adding their country and region to the access
+// log. With AddHeader backends receive the country in X-Geo-Country. Clients
+// are located by the first X-Forwarded-For address when UseXForwardedFor is
+// set, which a client can forge, and by the connection's address otherwise.
+type GeoIPConfig struct {
+	Database         string        `yaml:"database"`
+	ReloadInterval   time.Duration `yaml:"reload_interval"`
+	AddHeader        bool          `yaml:"add_header"`
+	UseXForwardedFor bool          `yaml:"use_x_forwarded_for"`
+}
+
+var defaultGeoIPConfig = GeoIPConfig{
+	ReloadInterval: 1 * time.Minute,
+}
+
+// TLSSessionConfig controls how clients resume TLS sessions. Without
+// TicketKeysFile each router encrypts session tickets with keys of its own,
+// so a client only resumes on the router it last reached; with it every
+// router sharing the file accepts the tickets of the others. The file is
+// checked for rotated keys every TicketKeysReloadInterval.
+//
+// With EarlyData, requests a TLS terminator in front of the router accepted
+// as 0-RTT early data, marked by "Early-Data: 1", are served when their
+// method is idempotent. Otherwise such requests are answered with 425 Too
+// Early, and the client retries once its handshake completes.
+type TLSSessionConfig struct {
+	DisableTickets           bool          `yaml:"disable_tickets"`
+	TicketKeysFile           string        `yaml:"ticket_keys_file"`
+	TicketKeysReloadInterval time.Duration `yaml:"ticket_keys_reload_interval"`
+	EarlyData                bool          `yaml:"early_data"`
+}
+
+var defaultTLSSessionConfig = TLSSessionConfig{
+	TicketKeysReloadInterval: 1 * time.Minute,
+}
+
+// TLS policy presets
+const (
+	TLSPresetModern       = "modern"
+	TLSPresetIntermediate = "intermediate"
+	TLSPresetCustom       = "custom"
+)
+
+// TLS connections a policy can be overridden for: the SSL listener, and the
+// router's own connections to backends and route services
+const (
+	TLSListenerFrontend = "frontend"
+	TLSListenerBackend  = "backend"
+)
+
+// TLSPolicy selects the TLS versions and cipher suites of a connection. The
+// modern preset only allows TLS 1.3, whose cipher suites Go does not let be
+// configured; intermediate also allows TLS 1.2 with forward secret AEAD
+// cipher suites; custom takes CipherSuites by their Go names. MinVersion and
+// MaxVersion, "1.0" to "1.3", and CipherSuites override those of a preset.
+type TLSPolicy struct {
+	Preset       string   `yaml:"preset"`
+	MinVersion   string   `yaml:"min_version"`
+	MaxVersion   string   `yaml:"max_version"`
+	CipherSuites []string `yaml:"cipher_suites"`
+}
+
+// TLSPolicyConfig is the policy of every TLS connection, overridden per
+// connection in Listeners. An override naming a preset replaces the policy;
+// one that does not changes the fields it sets.
+type TLSPolicyConfig struct {
+	TLSPolicy `yaml:",inline"`
+	Listeners map[string]TLSPolicy `yaml:"listeners"`
+}
+
+// ResolvedTLSPolicy is a TLSPolicy checked against the Go TLS stack
+type ResolvedTLSPolicy struct {
+	Preset       string
+	MinVersion   uint16
+	MaxVersion   uint16
+	CipherSuites []uint16
+}
+
+// Apply sets the versions and cipher suites of the policy on c
+func (p ResolvedTLSPolicy) Apply(c *tls.Config) {
+	if p.MinVersion != 0 {
+		c.MinVersion = p.MinVersion
+	}
+	if p.MaxVersion != 0 {
+		c.MaxVersion = p.MaxVersion
 	}
+	c.CipherSuites = p.CipherSuites
+}
 
-	c.Ip, err = localip.LocalIP()
-	if err != nil {
-		panic(err)
+var tlsVersions = map[string]uint16{
+	"1.0": tls.VersionTLS10,
+	"1.1": tls.VersionTLS11,
+	"1.2": tls.VersionTLS12,
+	"1.3": tls.VersionTLS13,
+}
+
+var intermediateCipherSuites = []uint16{
+	tls.TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256,
+	tls.TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256,
+	tls.TLS_ECDHE_ECDSA_WITH_AES_256_GCM_SHA384,
+	tls.TLS_ECDHE_RSA_WITH_AES_256_GCM_SHA384,
+	tls.TLS_ECDHE_ECDSA_WITH_CHACHA20_POLY1305_SHA256,
+	tls.TLS_ECDHE_RSA_WITH_CHACHA20_POLY1305_SHA256,
+}
+
+// override returns p changed by o
+func (p TLSPolicy) override(o TLSPolicy) TLSPolicy {
+	if o.Preset != "" {
+		return o
+	}
+	if o.MinVersion != "" {
+		p.MinVersion = o.MinVersion
+	}
+	if o.MaxVersion != "" {
+		p.MaxVersion = o.MaxVersion
 	}
+	if len(o.CipherSuites) > 0 {
+		p.CipherSuites = o.CipherSuites
+	}
+	return p
+}
 
-	if c.EnableSSL {
-		c.CipherSuites = c.processCipherSuites()
-		cert, err := tls.LoadX509KeyPair(c.SSLCertPath, c.SSLKeyPath)
-		if err != nil {
-			panic(err)
-		}
-		c.SSLCertificate = cert
+func (p TLSPolicy) resolve() (ResolvedTLSPolicy, error) {
+	r := ResolvedTLSPolicy{Preset: p.Preset, MinVersion: tls.VersionTLS12, MaxVersion: tls.VersionTLS13}
+	if r.Preset == "" {
+		r.Preset = TLSPresetCustom
 	}
 
-	if c.RouteServiceSecret != "" {
-		c.RouteServiceEnabled = true
+	switch r.Preset {
+	case TLSPresetModern:
+		r.MinVersion = tls.VersionTLS13
+	case TLSPresetIntermediate:
+		r.CipherSuites = intermediateCipherSuites
+	case TLSPresetCustom:
+		if len(p.CipherSuites) == 0 {
+			return r, errors.New("the custom preset requires cipher_suites")
+		}
+	default:
+		return r, fmt.Errorf("unknown preset %q, choose modern, intermediate or custom", r.Preset)
 	}
 
-	// check if valid load balancing strategy
-	validLb := false
-	for _, lb := range LoadBalancingStrategies {
-		if c.LoadBalance == lb {
-			validLb = true
-			break
+	for _, v := range []struct {
+		name    string
+		value   string
+		version *uint16
+	}{{"min_version", p.MinVersion, &r.MinVersion}, {"max_version", p.MaxVersion, &r.MaxVersion}} {
+		if v.value == "" {
+			continue
+		}
+		version, ok := tlsVersions[v.value]
+		if !ok {
+			return r, fmt.Errorf("invalid %s %q, choose 1.0, 1.1, 1.2 or 1.3", v.name, v.value)
 		}
+		*v.version = version
 	}
-	if !validLb {
-		errMsg := fmt.Sprintf("Invalid load balancing algorithm %s. Allowed values are %s", c.LoadBalance, LoadBalancingStrategies)
-		panic(errMsg)
+	if r.MinVersion > r.MaxVersion {
+		return r, errors.New("min_version is above max_version")
 	}
 
-	validShardMode := false
-	for _, sm := range AllowedShardingModes {
-		if c.RoutingTableShardingMode == sm {
+	if len(p.CipherSuites) > 0 {
+		r.CipherSuites = nil
+		for _, name := range p.CipherSuites {
+			suite := cipherSuiteByName(name)
+			if suite == nil {
+				return r, fmt.Errorf("cipher suite %s is not supported by Go", name)
+			}
+			r.CipherSuites = append(r.CipherSuites, suite.ID)
+		}
+	}
+
+	// cipher suites only apply below TLS 1.3, so one must work there
+	if r.MinVersion < tls.VersionTLS13 && !r.hasCipherSuiteBelowTLS13() {
+		return r, errors.New("no cipher suite supports the allowed versions below 1.3")
+	}
+	return r, nil
+}
+
+func (r ResolvedTLSPolicy) hasCipherSuiteBelowTLS13() bool {
+	for _, suite := range append(tls.CipherSuites(), tls.InsecureCipherSuites()...) {
+		for _, id := range r.CipherSuites {
+			if suite.ID != id {
+				continue
+			}
+			for _, version := range suite.SupportedVersions {
+				if version >= r.MinVersion && version <= r.MaxVersion && version < tls.VersionTLS13 {
+					return true
+				}
+			}
+		}
+	}
+	return false
+}
+
+func cipherSuiteByName(name string) *tls.CipherSuite {
+	for _, suite := range append(tls.CipherSuites(), tls.InsecureCipherSuites()...) {
+		if suite.Name == name {
+			return suite
+		}
+	}
+	return nil
+}
+
+// OCSPStaplingConfig staples an OCSP response for the certificate served on
+// the SSL port to the handshake, sparing clients a request to the CA. The
+// response is fetched from ResponderURL, by default the OCSP server named in
+// the certificate, whose chain must include the issuer. It is refreshed half
+// way through its validity, retrying every RetryInterval after a failure,
+// and kept in CacheFile, when set, to be stapled again after a restart.
+type OCSPStaplingConfig struct {
+	Enabled       bool          `yaml:"enabled"`
+	ResponderURL  string        `yaml:"responder_url"`
+	CacheFile     string        `yaml:"cache_file"`
+	RetryInterval time.Duration `yaml:"retry_interval"`
+	Timeout       time.Duration `yaml:"timeout"`
+}
+
+var defaultOCSPStaplingConfig = OCSPStaplingConfig{
+	RetryInterval: 1 * time.Minute,
+	Timeout:       10 * time.Second,
+}
+
+// AcceptConfig sizes the pool of goroutines accepting the conns of each
+// frontend listener. A worker hitting a temporary accept error, such as
+// running out of file descriptors, backs off from 5ms doubling up to
+// MaxBackoff rather than retrying at once.
+type AcceptConfig struct {
+	Workers    int           `yaml:"workers"`
+	MaxBackoff time.Duration `yaml:"max_backoff"`
+}
+
+var defaultAcceptConfig = AcceptConfig{
+	Workers:    4,
+	MaxBackoff: time.Second,
+}
+
+// ConnScavengerConfig closes client connections left idle, having sent no
+// request or none since their last response, for longer than MaxIdle, and
+// keep-alive connections older than MaxLifetime once they are idle. Zero
+// disables either. The connections are checked every Interval.
+type ConnScavengerConfig struct {
+	MaxIdle     time.Duration `yaml:"max_idle"`
+	MaxLifetime time.Duration `yaml:"max_lifetime"`
+	Interval    time.Duration `yaml:"interval"`
+}
+
+var defaultConnScavengerConfig = ConnScavengerConfig{
+	Interval: 5 * time.Second,
+}
+
+// ConnectionLimitsConfig caps the concurrent frontend connections of each
+// client IP at MaxPerIP, zero being unlimited. The client of a connection is
+// its peer, as given by the PROXY protocol when enabled. Connections from
+// TrustedSources (CIDRs), such as load balancers, are counted against the
+// rightmost untrusted address in the X-Forwarded-For of their first request.
+// A connection beyond the limit is answered with a 429 and closed, or reset,
+// by Policy.
+type ConnectionLimitsConfig struct {
+	MaxPerIP       int      `yaml:"max_per_ip"`
+	Policy         string   `yaml:"policy"`
+	TrustedSources []string `yaml:"trusted_sources"`
+
+	// Populated by process from TrustedSources
+	TrustedNetworks []*net.IPNet `yaml:"-"`
+}
+
+var defaultConnectionLimitsConfig = ConnectionLimitsConfig{
+	Policy: CONNECTION_LIMIT_POLICY_TOO_MANY_REQUESTS,
+}
+
+// TrustedIngressConfig rejects the connections of the http and https
+// listeners that do not arrive through the expected load balancers, so that
+// the router cannot be reached from the internet directly. When SourceCIDRs
+// are set, the TCP peer of a connection must be in one of them; the address
+// given by the PROXY protocol is not considered. With ClientCAFile, clients of
+// the https listener must present a certificate signed by one of its CAs.
+type TrustedIngressConfig struct {
+	SourceCIDRs  []string `yaml:"source_cidrs"`
+	ClientCAFile string   `yaml:"client_ca_file"`
+
+	// Populated by process from SourceCIDRs and ClientCAFile
+	SourceNetworks []*net.IPNet   `yaml:"-"`
+	ClientCAs      *x509.CertPool `yaml:"-"`
+}
+
+// RequestValidationConfig checks the framing of the HTTP/1.1 requests read
+// on both ports for what could smuggle a request past the router: conflicting
+// or duplicate Content-Length and Transfer-Encoding headers, header values
+// folded over several lines, malformed header names and malformed chunks.
+// The monitor level only counts them; normalize rewrites those that can only
+// mean one thing and rejects the others; reject rejects them all.
+type RequestValidationConfig struct {
+	Level string `yaml:"level"`
+}
+
+var defaultRequestValidationConfig = RequestValidationConfig{
+	Level: REQUEST_VALIDATION_OFF,
+}
+
+// URLNormalizationConfig rewrites request paths into one canonical form
+// before routes are looked up, so the path a route matched is the path the
+// backend or route service receives. With the unreserved PercentDecoding
+// policy escaped unreserved characters are decoded, "%7E" becoming "~", and
+// the hex digits of other escapes capitalized. RemoveDotSegments resolves
+// "." and ".." segments, which then cannot climb above the root, and
+// MergeSlashes collapses runs of slashes. With RejectEncodedSlashes paths
+// holding an escaped slash or backslash, which backends disagree on, are
+// rejected with a 400.
+type URLNormalizationConfig struct {
+	Enabled              bool   `yaml:"enabled"`
+	PercentDecoding      string `yaml:"percent_decoding"`
+	RemoveDotSegments    bool   `yaml:"remove_dot_segments"`
+	MergeSlashes         bool   `yaml:"merge_slashes"`
+	RejectEncodedSlashes bool   `yaml:"reject_encoded_slashes"`
+}
+
+var defaultURLNormalizationConfig = URLNormalizationConfig{
+	PercentDecoding:   URL_PERCENT_DECODING_UNRESERVED,
+	RemoveDotSegments: true,
+	MergeSlashes:      true,
+}
+
+// CORSPolicy answers the CORS preflight requests of browsers on behalf of a
+// route and adds CORS headers to its responses, replacing any the backend
+// sent. Origins are matched exactly, or by "*". Requests may use the
+// AllowedMethods, GET, HEAD and POST when empty, and send the AllowedHeaders
+// beyond the safelisted ones, or any with "*". Preflight responses are
+// cached by browsers for MaxAgeSeconds. Credentials are never allowed for
+// origins matched by "*". A policy is set per route by registrations, or by
+// CORSConfig.
+type CORSPolicy struct {
+	AllowedOrigins   []string `yaml:"allowed_origins" json:"allowed_origins"`
+	AllowedMethods   []string `yaml:"allowed_methods" json:"allowed_methods,omitempty"`
+	AllowedHeaders   []string `yaml:"allowed_headers" json:"allowed_headers,omitempty"`
+	ExposedHeaders   []string `yaml:"exposed_headers" json:"exposed_headers,omitempty"`
+	AllowCredentials bool     `yaml:"allow_credentials" json:"allow_credentials,omitempty"`
+	MaxAgeSeconds    int      `yaml:"max_age_seconds" json:"max_age_seconds,omitempty"`
+}
+
+// CORSRule applies a CORS policy to the routes of Host, either a host name or
+// a wildcard such as "*.apps.example.com" matching its subdomains. Policies
+// registered with a route take precedence.
+type CORSRule struct {
+	Host       string `yaml:"host"`
+	CORSPolicy `yaml:",inline"`
+}
+
+// CORSConfig holds the CORS rules applied by host. The first rule matching
+// a request applies.
+type CORSConfig struct {
+	Rules []CORSRule `yaml:"rules"`
+}
+
+// SecurityHeaders are the security headers added to responses whose backend
+// did not set them. Empty headers are not added. Routes override them with
+// their own, where "off" drops a header for the route.
+// StrictTransportSecurity is only added to requests received over TLS, or
+// forwarded with X-Forwarded-Proto: https.
+type SecurityHeaders struct {
+	StrictTransportSecurity string `yaml:"strict_transport_security" json:"strict_transport_security,omitempty"`
+	ContentTypeOptions      string `yaml:"x_content_type_options" json:"x_content_type_options,omitempty"`
+	FrameOptions            string `yaml:"x_frame_options" json:"x_frame_options,omitempty"`
+	ContentSecurityPolicy   string `yaml:"content_security_policy" json:"content_security_policy,omitempty"`
+}
+
+// SecurityHeadersConfig enables adding the default SecurityHeaders, and the
+// overrides routes register, to responses
+type SecurityHeadersConfig struct {
+	Enabled         bool `yaml:"enabled"`
+	SecurityHeaders `yaml:",inline"`
+}
+
+var defaultSecurityHeadersConfig = SecurityHeadersConfig{
+	SecurityHeaders: SecurityHeaders{
+		StrictTransportSecurity: "max-age=31536000",
+		ContentTypeOptions:      "nosniff",
+		FrameOptions:            "SAMEORIGIN",
+	},
+}
+
+// RegistrationLimitsConfig protects the route registry from registration
+// storms. Registrations beyond MaxPerSecond from one endpoint host are
+// dropped, and so are registrations identical to one processed within
+// CoalesceWindow. Zero disables either.
+type RegistrationLimitsConfig struct {
+	MaxPerSecond   int           `yaml:"max_per_second"`
+	CoalesceWindow time.Duration `yaml:"coalesce_window"`
+}
+
+// RegisterIntervalConfig adapts the registration interval advertised in
+// router.start and router.greet messages to the load on the registry. While
+// more than TargetRegistrationsPerSecond registrations arrive, the interval
+// grows in proportion, up to MaxInterval, which defaults to a third of the
+// droplet_stale_threshold. A zero target always advertises
+// start_response_delay_interval.
+// With EnforceMinimum, endpoints refreshing their registration within half of
+// start_response_delay_interval are logged and counted, and with
+// DropEarlyRefreshes such refreshes are dropped unless they change the
+// registration.
+type RegisterIntervalConfig struct {
+	TargetRegistrationsPerSecond int           `yaml:"target_registrations_per_second"`
+	MaxInterval                  time.Duration `yaml:"max_interval"`
+	EnforceMinimum               bool          `yaml:"enforce_minimum"`
+	DropEarlyRefreshes           bool          `yaml:"drop_early_refreshes"`
+}
+
+// RouteSourcesConfig resolves routes registered by several sources, such as
+// NATS and the routing API. Without Precedence, the endpoints of every source
+// are merged. With it, a route only keeps the endpoints of its highest ranked
+// source, and sources not listed rank below the listed ones.
+type RouteSourcesConfig struct {
+	Precedence []string `yaml:"precedence"`
+}
+
+// RouteTableLimitsConfig caps the URIs and backend addresses of the routing
+// table, rejecting registrations beyond them. Zero is unlimited.
+type RouteTableLimitsConfig struct {
+	MaxURIs      int `yaml:"max_uris"`
+	MaxEndpoints int `yaml:"max_endpoints"`
+}
+
+// BandwidthLimit caps the rate, in bytes per second, at which the response
+// bodies of a route are sent: PerRoute across all of its clients and
+// PerClient for each client IP. Up to Burst bytes are sent at once, a
+// second's worth of the lower limit by default. A zero limit is not enforced.
+type BandwidthLimit struct {
+	PerRoute  int64 `yaml:"per_route" json:"per_route,omitempty"`
+	PerClient int64 `yaml:"per_client" json:"per_client,omitempty"`
+	Burst     int64 `yaml:"burst" json:"burst,omitempty"`
+}
+
+// PathRewrite changes the path prefix a route was registered with before
+// requests are forwarded to its backends: StripPrefix removes it, so that a
+// route at /api/v1 is served from / by the app, and ReplacePrefix, when set,
+// substitutes it.
+type PathRewrite struct {
+	StripPrefix   bool   `yaml:"strip_prefix" json:"strip_prefix,omitempty"`
+	ReplacePrefix string `yaml:"replace_prefix" json:"replace_prefix,omitempty"`
+}
+
+// Rewrite returns uri, a request URI of a route registered at prefix, with
+// the prefix rewritten. The query is kept as is, and a uri outside of the
+// prefix is returned unchanged.
+func (r *PathRewrite) Rewrite(prefix, uri string) string {
+	path, query := uri, ""
+	if i := strings.IndexByte(uri, '?'); i >= 0 {
+		path, query = uri[:i], uri[i:]
+	}
+
+	prefix = strings.TrimSuffix(prefix, "/")
+	if len(path) < len(prefix) || !strings.EqualFold(path[:len(prefix)], prefix) {
+		return uri
+	}
+	rest := path[len(prefix):]
+	if rest != "" && rest[0] != '/' {
+		return uri
+	}
+
+	replacement := ""
+	if r.ReplacePrefix != "" {
+		replacement = strings.TrimSuffix(r.ReplacePrefix, "/")
+	} else if !r.StripPrefix {
+		return uri
+	}
+
+	path = replacement + rest
+	if !strings.HasPrefix(path, "/") {
+		path = "/" + path
+	}
+	return path + query
+}
+
+// BandwidthLimitConfig enables limiting the bandwidth of responses. Routes
+// registering a bandwidth limit use theirs, the others Default. At most
+// MaxClients clients are tracked at a time; the clients seen beyond share a
+// limit.
+type BandwidthLimitConfig struct {
+	Enabled    bool           `yaml:"enabled"`
+	Default    BandwidthLimit `yaml:"default"`
+	MaxClients int            `yaml:"max_clients"`
+}
+
+var defaultBandwidthLimitConfig = BandwidthLimitConfig{
+	MaxClients: 100000,
+}
+
+// ResponseStreamingConfig tunes how response bodies are copied to clients.
+// BufferSize is the size of the buffers reads from backends and writes to
+// clients go through. A write blocked for longer than WriteStallTimeout, as
+// the client stopped reading, abandons the response and closes the backend
+// connection; zero waits for the client indefinitely. Bodies with a
+// Content-Length of at least ZeroCopyMinBytes skip the buffers, letting the
+// kernel move them with splice or sendfile where it can; zero always buffers.
+// As stalls are detected on every buffered write, a WriteStallTimeout turns
+// this off.
+type ResponseStreamingConfig struct {
+	BufferSize        int           `yaml:"buffer_size"`
+	WriteStallTimeout time.Duration `yaml:"write_stall_timeout"`
+	ZeroCopyMinBytes  int64         `yaml:"zero_copy_min_bytes"`
+}
+
+var defaultResponseStreamingConfig = ResponseStreamingConfig{
+	BufferSize:       8192,
+	ZeroCopyMinBytes: 1024 * 1024,
+}
+
+// RequestCoalescingConfig has identical concurrent GET requests to the routes
+// of Hosts, host names or wildcards such as "*.apps.example.com", share one
+// backend request, all routes being coalesced without Hosts. Requests are
+// identical when their host, path and query match, along with the values of
+// Headers. Responses larger than MaxBodySize or streamed are not shared, and
+// requests waiting for longer than MaxWait are sent to the backends.
+type RequestCoalescingConfig struct {
+	Enabled     bool          `yaml:"enabled"`
+	Hosts       []string      `yaml:"hosts"`
+	Headers     []string      `yaml:"headers"`
+	MaxBodySize int           `yaml:"max_body_size"`
+	MaxWait     time.Duration `yaml:"max_wait"`
+}
+
+var defaultRequestCoalescingConfig = RequestCoalescingConfig{
+	MaxBodySize: 1024 * 1024,
+	MaxWait:     5 * time.Second,
+}
+
+// DeadlinePropagationConfig tells backends when the router stops waiting for
+// their responses, endpoint_timeout after a request arrived or earlier under a
+// domain profile, so that they can give up on work that would never be
+// delivered. Header carries the deadline in Unix milliseconds. With
+// GRPCTimeout, gRPC requests also get the time left in grpc-timeout, unless
+// their client asked for less.
+type DeadlinePropagationConfig struct {
+	Enabled     bool   `yaml:"enabled"`
+	Header      string `yaml:"header"`
+	GRPCTimeout bool   `yaml:"grpc_timeout"`
+}
+
+var defaultDeadlinePropagationConfig = DeadlinePropagationConfig{
+	Header: "X-Request-Deadline",
+}
+
+// RequestStartConfig stamps the requests sent to backends with the time the
+// router received them, in Header as t=<Unix microseconds>, so that APM
+// agents can measure the time requests spend before reaching the app. With
+// PreserveUpstream, a value a load balancer in front of the router set is
+// forwarded instead, which accounts for the time spent in the load balancer.
+type RequestStartConfig struct {
+	Enabled          bool   `yaml:"enabled"`
+	Header           string `yaml:"header"`
+	PreserveUpstream bool   `yaml:"preserve_upstream"`
+}
+
+var defaultRequestStartConfig = RequestStartConfig{
+	Header: "X-Request-Start",
+}
+
+// AltSvcConfig controls the Alt-Svc header of backend responses. Advertise,
+// e.g. h3=":443"; ma=86400, is sent with every response. The Alt-Svc
+// backends send would point clients at endpoints bypassing the router, so it
+// is removed unless AllowBackend is set, in which case it is kept over
+// Advertise.
+type AltSvcConfig struct {
+	Advertise    string `yaml:"advertise"`
+	AllowBackend bool   `yaml:"allow_backend"`
+}
+
+// RouteVisibilityConfig opens an internal listener on InternalPort. Routes
+// registered as internal, and the routes of InternalDomains and their
+// subdomains, are only served on it; the other listeners answer them as
+// unknown routes. The internal listener serves external routes too.
+type RouteVisibilityConfig struct {
+	InternalPort    uint16   `yaml:"internal_port"`
+	InternalDomains []string `yaml:"internal_domains"`
+}
+
+// HealthCheckConfig lists the requests answered as platform healthchecks
+// without looking up a route: those sent with one of UserAgents, or for one of
+// Paths on any host. Listeners restricts the answers to the requests arriving
+// on some of HealthCheckListeners, all of them by default.
+type HealthCheckConfig struct {
+	UserAgents []string `yaml:"user_agents"`
+	Paths      []string `yaml:"paths"`
+	Listeners  []string `yaml:"listeners"`
+}
+
+// TenantMetricsConfig enables the per-org and per-space response counters
+// served on the status server's /metrics/tenants endpoint. Tenants are
+// identified by the values of the endpoint tags OrgTag and SpaceTag.
+type TenantMetricsConfig struct {
+	Enabled    bool   `yaml:"enabled"`
+	OrgTag     string `yaml:"org_tag"`
+	SpaceTag   string `yaml:"space_tag"`
+	MaxTenants int    `yaml:"max_tenants"`
+}
+
+var defaultTenantMetricsConfig = TenantMetricsConfig{
+	OrgTag:     "organization_id",
+	SpaceTag:   "space_id",
+	MaxTenants: 10000,
+}
+
+// MiddlewarePluginConfig enables a registered middleware plugin, for all
+// routes or only for requests to the listed hosts. A host may start with
+// "*." to match its subdomains.
+type MiddlewarePluginConfig struct {
+	Name   string   `yaml:"name"`
+	Routes []string `yaml:"routes"`
+}
+
+// ExternalPluginConfig starts an out-of-process filter plugin. Once started it
+// is registered as a middleware plugin called Name and, like plugins compiled
+// into gorouter, must be enabled in middleware_plugins.
+type ExternalPluginConfig struct {
+	Name                string        `yaml:"name"`
+	Path                string        `yaml:"path"`
+	Args                []string      `yaml:"args"`
+	Stage               string        `yaml:"stage"`
+	Order               int           `yaml:"order"`
+	Timeout             time.Duration `yaml:"timeout"`
+	FailurePolicy       string        `yaml:"failure_policy"`
+	HealthCheckInterval time.Duration `yaml:"health_check_interval"`
+}
+
+var defaultExternalPluginConfig = ExternalPluginConfig{
+	Stage:               EXTERNAL_PLUGIN_STAGE_PRE_LOOKUP,
+	Timeout:             100 * time.Millisecond,
+	FailurePolicy:       EXTERNAL_PLUGIN_FAIL_CLOSED,
+	HealthCheckInterval: 10 * time.Second,
+}
+
+type Tracing struct {
+	EnableZipkin bool `yaml:"enable_zipkin"`
+}
+
+var defaultLoggingConfig = LoggingConfig{
+	Level:           "debug",
+	MetronAddress:   "localhost:3457",
+	TimestampFormat: TIMESTAMP_FORMAT_EPOCH,
+	Sampling: LogSamplingConfig{
+		First:      100,
+		Thereafter: 100,
+	},
+	EnvelopeBatching: defaultEnvelopeBatchingConfig,
+}
+
+type Config struct {
+	Status                   StatusConfig  `yaml:"status"`
+	Nats                     []NatsConfig  `yaml:"nats"`
+	Logging                  LoggingConfig `yaml:"logging"`
+	Port                     uint16        `yaml:"port"`
+	Index                    uint          `yaml:"index"`
+	Zone                     string        `yaml:"zone"`
+	GoMaxProcs               int           `yaml:"go_max_procs,omitempty"`
+	Tracing                  Tracing       `yaml:"tracing"`
+	TraceKey                 string        `yaml:"trace_key"`
+	AccessLog                AccessLog     `yaml:"access_log"`
+	EnableAccessLogStreaming bool          `yaml:"enable_access_log_streaming"`
+	AuditLog                 AuditLog      `yaml:"audit_log"`
+	DebugAddr                string        `yaml:"debug_addr"`
+	EnablePROXY              bool          `yaml:"enable_proxy"`
+	EnableSSL                bool          `yaml:"enable_ssl"`
+	SSLPort                  uint16        `yaml:"ssl_port"`
+	SSLCertPath              string        `yaml:"ssl_cert_path"`
+	SSLKeyPath               string        `yaml:"ssl_key_path"`
+	SSLCertificate           tls.Certificate
+	SkipSSLValidation        bool     `yaml:"skip_ssl_validation"`
+	ForceForwardedProtoHttps bool     `yaml:"force_forwarded_proto_https"`
+	IsolationSegments        []string `yaml:"isolation_segments"`
+	RoutingTableShardingMode string   `yaml:"routing_table_sharding_mode"`
+	StaleUpdatePolicy        string   `yaml:"stale_update_policy"`
+
+	CipherString string `yaml:"cipher_suites"`
+	CipherSuites []uint16
+
+	TLSPolicy   TLSPolicyConfig              `yaml:"tls_policy"`
+	TLSPolicies map[string]ResolvedTLSPolicy `yaml:"-"`
+
+	LoadBalancerHealthyThreshold    time.Duration `yaml:"load_balancer_healthy_threshold"`
+	PublishStartMessageInterval     time.Duration `yaml:"publish_start_message_interval"`
+	SuspendPruningIfNatsUnavailable bool          `yaml:"suspend_pruning_if_nats_unavailable"`
+	PruneStaleDropletsInterval      time.Duration `yaml:"prune_stale_droplets_interval"`
+	DropletStaleThreshold           time.Duration `yaml:"droplet_stale_threshold"`
+	PruneCycleWarningDuration       time.Duration `yaml:"prune_cycle_warning_duration"`
+	PruneChunkSize                  int           `yaml:"prune_chunk_size"`
+	EndpointQuarantinePeriod        time.Duration `yaml:"endpoint_quarantine_period"`
+	PublishActiveAppsInterval       time.Duration `yaml:"publish_active_apps_interval"`
+	StartResponseDelayInterval      time.Duration `yaml:"start_response_delay_interval"`
+	EndpointTimeout                 time.Duration `yaml:"endpoint_timeout"`
+	ExpectContinueTimeout           time.Duration `yaml:"expect_continue_timeout"`
+	RouteServiceTimeout             time.Duration `yaml:"route_services_timeout"`
+
+	DrainWait            time.Duration `yaml:"drain_wait,omitempty"`
+	DrainTimeout         time.Duration `yaml:"drain_timeout,omitempty"`
+	SecureCookies        bool          `yaml:"secure_cookies"`
+	HealthCheckUserAgent string        `yaml:"healthcheck_user_agent,omitempty"`
+
+	// HealthCheck.UserAgents replaces HealthCheckUserAgent when set
+	HealthCheck HealthCheckConfig `yaml:"healthcheck"`
+
+	OAuth                      OAuthConfig      `yaml:"oauth"`
+	RoutingApi                 RoutingApiConfig `yaml:"routing_api"`
+	RouteServiceSecret         string           `yaml:"route_services_secret"`
+	RouteServiceSecretPrev     string           `yaml:"route_services_secret_decrypt_only"`
+	RouteServiceRecommendHttps bool             `yaml:"route_services_recommend_https"`
+	// These fields are populated by the `Process` function.
+	Ip                     string        `yaml:"-"`
+	RouteServiceEnabled    bool          `yaml:"-"`
+	NatsClientPingInterval time.Duration `yaml:"-"`
+
+	ExtraHeadersToLog []string `yaml:"extra_headers_to_log"`
+
+	TokenFetcherMaxRetries                    uint32        `yaml:"token_fetcher_max_retries"`
+	TokenFetcherRetryInterval                 time.Duration `yaml:"token_fetcher_retry_interval"`
+	TokenFetcherExpirationBufferTimeInSeconds int64         `yaml:"token_fetcher_expiration_buffer_time"`
+
+	PidFile        string               `yaml:"pid_file"`
+	LoadBalance    string               `yaml:"balancing_algorithm"`
+	ConsistentHash ConsistentHashConfig `yaml:"consistent_hash"`
+
+	UpgradeProtocols UpgradeProtocolsConfig `yaml:"upgrade_protocols"`
+	CanaryAnalysis   CanaryAnalysisConfig   `yaml:"canary_analysis"`
+
+	RouteRegistrationAuth RouteRegistrationAuthConfig `yaml:"route_registration_auth"`
+	RouteTableSharing     RouteTableSharingConfig     `yaml:"route_table_sharing"`
+	RequestCapture        RequestCaptureConfig        `yaml:"request_capture"`
+	TenantMetrics         TenantMetricsConfig         `yaml:"tenant_metrics"`
+	SlowRequestLog        SlowRequestLogConfig        `yaml:"slow_request_log"`
+	AnomalyDetection      AnomalyDetectionConfig      `yaml:"anomaly_detection"`
+	AuthFailureMetrics    AuthFailureMetricsConfig    `yaml:"auth_failure_metrics"`
+	LoadShedding          LoadSheddingConfig          `yaml:"load_shedding"`
+	AdaptiveConcurrency   AdaptiveConcurrencyConfig   `yaml:"adaptive_concurrency"`
+	RequestPriority       RequestPriorityConfig       `yaml:"request_priority"`
+	RouteServiceBypass    RouteServiceBypassConfig    `yaml:"route_service_bypass"`
+	RouteServiceResponses RouteServiceResponsesConfig `yaml:"route_service_responses"`
+	DNSCache              DNSCacheConfig              `yaml:"dns_cache"`
+	StaticRoutes          StaticRoutesConfig          `yaml:"static_routes"`
+	Kubernetes            KubernetesConfig            `yaml:"kubernetes"`
+	Consul                ConsulConfig                `yaml:"consul"`
+	Xds                   XdsConfig                   `yaml:"xds"`
+	ErrorPages            ErrorPagesConfig            `yaml:"error_pages"`
+	GeoIP                 GeoIPConfig                 `yaml:"geoip"`
+	TLSSessions           TLSSessionConfig            `yaml:"tls_sessions"`
+	OCSPStapling          OCSPStaplingConfig          `yaml:"ocsp_stapling"`
+	RequestValidation     RequestValidationConfig     `yaml:"request_validation"`
+	URLNormalization      URLNormalizationConfig      `yaml:"url_normalization"`
+	CORS                  CORSConfig                  `yaml:"cors"`
+	SecurityHeaders       SecurityHeadersConfig       `yaml:"security_headers"`
+	RouteVisibility       RouteVisibilityConfig       `yaml:"route_visibility"`
+	AltSvc                AltSvcConfig                `yaml:"alt_svc"`
+	ResponseStreaming     ResponseStreamingConfig     `yaml:"response_streaming"`
+	BandwidthLimit        BandwidthLimitConfig        `yaml:"bandwidth_limit"`
+	RegistrationLimits    RegistrationLimitsConfig    `yaml:"registration_limits"`
+	RegisterInterval      RegisterIntervalConfig      `yaml:"register_interval"`
+	RouteSources          RouteSourcesConfig          `yaml:"route_sources"`
+	RouteTableLimits      RouteTableLimitsConfig      `yaml:"route_table_limits"`
+	ConnectionLimits      ConnectionLimitsConfig      `yaml:"connection_limits"`
+	Accept                AcceptConfig                `yaml:"accept"`
+	ConnScavenger         ConnScavengerConfig         `yaml:"conn_scavenger"`
+	TrustedIngress        TrustedIngressConfig        `yaml:"trusted_ingress"`
+	Retries               RetriesConfig               `yaml:"retries"`
+	UpstreamProxies       UpstreamProxiesConfig       `yaml:"upstream_proxies"`
+	RequestCoalescing     RequestCoalescingConfig     `yaml:"request_coalescing"`
+	DeadlinePropagation   DeadlinePropagationConfig   `yaml:"deadline_propagation"`
+	RequestStart          RequestStartConfig          `yaml:"request_start"`
+
+	RouteServiceForwardedURL RouteServiceForwardedURLConfig `yaml:"route_service_forwarded_url"`
+	DomainProfiles           []DomainProfile                `yaml:"domain_profiles"`
+	StaleThresholdClasses    []StaleThresholdClass          `yaml:"stale_threshold_classes"`
+	Experiments              []ExperimentConfig             `yaml:"experiments"`
+
+	MiddlewarePlugins []MiddlewarePluginConfig `yaml:"middleware_plugins"`
+	ExternalPlugins   []ExternalPluginConfig   `yaml:"external_plugins"`
+	// AttemptObservers enables registered observers of the attempts of the
+	// round tripper, called in order
+	AttemptObservers []string `yaml:"attempt_observers"`
+
+	DisableKeepAlives   bool `yaml:"disable_keep_alives"`
+	MaxIdleConns        int  `yaml:"max_idle_conns"`
+	MaxIdleConnsPerHost int  `yaml:"max_idle_conns_per_host"`
+
+	DisableInformationalResponses bool `yaml:"disable_informational_responses"`
+}
+
+var defaultConfig = Config{
+	Status:  defaultStatusConfig,
+	Nats:    []NatsConfig{defaultNatsConfig},
+	Logging: defaultLoggingConfig,
+
+	Port:        8081,
+	Index:       0,
+	GoMaxProcs:  -1,
+	EnablePROXY: false,
+	EnableSSL:   false,
+	SSLPort:     443,
+
+	EndpointTimeout:       60 * time.Second,
+	ExpectContinueTimeout: 1 * time.Second,
+	RouteServiceTimeout:   60 * time.Second,
+
+	PublishStartMessageInterval:               30 * time.Second,
+	PruneStaleDropletsInterval:                30 * time.Second,
+	DropletStaleThreshold:                     120 * time.Second,
+	PruneCycleWarningDuration:                 time.Second,
+	PruneChunkSize:                            1000,
+	PublishActiveAppsInterval:                 0 * time.Second,
+	StartResponseDelayInterval:                5 * time.Second,
+	TokenFetcherMaxRetries:                    3,
+	TokenFetcherRetryInterval:                 5 * time.Second,
+	TokenFetcherExpirationBufferTimeInSeconds: 30,
+
+	HealthCheckUserAgent: "HTTP-Monitor/1.1",
+	LoadBalance:          LOAD_BALANCE_RR,
+	ConsistentHash:       defaultConsistentHashConfig,
+
+	RoutingTableShardingMode: "all",
+	StaleUpdatePolicy:        STALE_UPDATE_DROP,
+	RouteTableSharing:        defaultRouteTableSharingConfig,
+	RequestCapture:           defaultRequestCaptureConfig,
+	TenantMetrics:            defaultTenantMetricsConfig,
+	AnomalyDetection:         defaultAnomalyDetectionConfig,
+	AuthFailureMetrics:       defaultAuthFailureMetricsConfig,
+	LoadShedding:             defaultLoadSheddingConfig,
+	AdaptiveConcurrency:      defaultAdaptiveConcurrencyConfig,
+	RequestPriority:          defaultRequestPriorityConfig,
+	RouteServiceBypass:       defaultRouteServiceBypassConfig,
+	RouteServiceResponses:    defaultRouteServiceResponsesConfig,
+	DNSCache:                 defaultDNSCacheConfig,
+	StaticRoutes:             defaultStaticRoutesConfig,
+	Kubernetes:               defaultKubernetesConfig,
+	Consul:                   defaultConsulConfig,
+	Xds:                      defaultXdsConfig,
+	ErrorPages:               defaultErrorPagesConfig,
+	GeoIP:                    defaultGeoIPConfig,
+	TLSSessions:              defaultTLSSessionConfig,
+	OCSPStapling:             defaultOCSPStaplingConfig,
+	RequestValidation:        defaultRequestValidationConfig,
+	URLNormalization:         defaultURLNormalizationConfig,
+	SecurityHeaders:          defaultSecurityHeadersConfig,
+	ResponseStreaming:        defaultResponseStreamingConfig,
+	BandwidthLimit:           defaultBandwidthLimitConfig,
+	ConnectionLimits:         defaultConnectionLimitsConfig,
+	Accept:                   defaultAcceptConfig,
+	ConnScavenger:            defaultConnScavengerConfig,
+	Retries:                  defaultRetriesConfig,
+	RequestCoalescing:        defaultRequestCoalescingConfig,
+	DeadlinePropagation:      defaultDeadlinePropagationConfig,
+	RequestStart:             defaultRequestStartConfig,
+	RouteServiceForwardedURL: defaultRouteServiceForwardedURLConfig,
+
+	DisableKeepAlives:   true,
+	MaxIdleConns:        100,
+	MaxIdleConnsPerHost: 2,
+}
+
+func DefaultConfig() *Config {
+	c := defaultConfig
+	c.Process()
+
+	return &c
+}
+
+func (c *Config) Process() {
+	var err error
+
+	if c.GoMaxProcs == -1 {
+		c.GoMaxProcs = runtime.NumCPU()
+	}
+
+	c.Logging.JobName = "gorouter"
+	if c.StartResponseDelayInterval > c.DropletStaleThreshold {
+		c.DropletStaleThreshold = c.StartResponseDelayInterval
+	}
+
+	// To avoid routes getting purged because of unresponsive NATS server
+	// we need to set the ping interval of nats client such that it fails over
+	// to next NATS server before dropletstalethreshold is hit. We are hardcoding the ping interval
+	// to 20 sec because the operators cannot set the value of DropletStaleThreshold and StartResponseDelayInterval
+	// ping_interval = ((DropletStaleThreshold- StartResponseDelayInterval)-minimumRegistrationInterval+(2 * number_of_nats_servers))/3
+	c.NatsClientPingInterval = 20 * time.Second
+
+	if c.DrainTimeout == 0 || c.DrainTimeout == defaultConfig.EndpointTimeout {
+		c.DrainTimeout = c.EndpointTimeout
+	}
+
+	c.Ip, err = localip.LocalIP()
+	if err != nil {
+		panic(err)
+	}
+
+	c.processTLSPolicies()
+	if c.EnableSSL {
+		if c.CipherString != "" {
+			c.CipherSuites = c.processCipherSuites()
+		}
+		cert, err := tls.LoadX509KeyPair(c.SSLCertPath, c.SSLKeyPath)
+		if err != nil {
+			panic(err)
+		}
+		c.SSLCertificate = cert
+	}
+
+	if c.RouteServiceSecret != "" {
+		c.RouteServiceEnabled = true
+	}
+
+	// check if valid load balancing strategy
+	validLb := false
+	for _, lb := range LoadBalancingStrategies {
+		if c.LoadBalance == lb {
+			validLb = true
+			break
+		}
+	}
+	if !validLb {
+		errMsg := fmt.Sprintf("Invalid load balancing algorithm %s. Allowed values are %s", c.LoadBalance, LoadBalancingStrategies)
+		panic(errMsg)
+	}
+
+	if c.ConsistentHash.Replicas <= 0 {
+		c.ConsistentHash.Replicas = defaultConsistentHashConfig.Replicas
+	}
+
+	if c.CanaryAnalysis.Tag != "" && c.CanaryAnalysis.DefaultGroup == "" {
+		c.CanaryAnalysis.DefaultGroup = "stable"
+	}
+
+	if c.AuditLog.SyslogTag == "" {
+		c.AuditLog.SyslogTag = "gorouter.audit"
+		if c.Logging.Syslog != "" {
+			c.AuditLog.SyslogTag = c.Logging.Syslog + ".audit"
+		}
+	}
+
+	if c.RouteRegistrationAuth.Enabled && c.RouteRegistrationAuth.SharedKey == "" && len(c.RouteRegistrationAuth.PublisherKeys) == 0 {
+		panic("route_registration_auth is enabled but no shared_key or publisher_keys are configured")
+	}
+
+	validShardMode := false
+	for _, sm := range AllowedShardingModes {
+		if c.RoutingTableShardingMode == sm {
 			validShardMode = true
 			break
 		}
 	}
-	if !validShardMode {
-		errMsg := fmt.Sprintf("Invalid sharding mode: %s. Allowed values are %s", c.RoutingTableShardingMode, AllowedShardingModes)
+	if !validShardMode {
+		errMsg := fmt.Sprintf("Invalid sharding mode: %s. Allowed values are %s", c.RoutingTableShardingMode, AllowedShardingModes)
+		panic(errMsg)
+	}
+
+	switch c.RouteTableSharing.Mode {
+	case "":
+	case ROUTE_TABLE_SHARING_OWNER, ROUTE_TABLE_SHARING_FOLLOWER:
+		if c.RouteTableSharing.Socket == "" {
+			panic("route_table_sharing.socket is required when route_table_sharing.mode is set")
+		}
+	default:
+		errMsg := fmt.Sprintf("Invalid route table sharing mode: %s. Allowed values are %s and %s",
+			c.RouteTableSharing.Mode, ROUTE_TABLE_SHARING_OWNER, ROUTE_TABLE_SHARING_FOLLOWER)
+		panic(errMsg)
+	}
+	if c.RouteTableSharing.RetryInterval <= 0 {
+		c.RouteTableSharing.RetryInterval = defaultRouteTableSharingConfig.RetryInterval
+	}
+
+	if c.RequestCapture.MaxEntries <= 0 {
+		c.RequestCapture.MaxEntries = defaultRequestCaptureConfig.MaxEntries
+	}
+	if c.RequestCapture.MaxDuration <= 0 {
+		c.RequestCapture.MaxDuration = defaultRequestCaptureConfig.MaxDuration
+	}
+
+	if c.TenantMetrics.MaxTenants <= 0 {
+		c.TenantMetrics.MaxTenants = defaultTenantMetricsConfig.MaxTenants
+	}
+
+	if c.AnomalyDetection.Window <= 0 {
+		c.AnomalyDetection.Window = defaultAnomalyDetectionConfig.Window
+	}
+	if c.AnomalyDetection.BaselineWindows <= 0 {
+		c.AnomalyDetection.BaselineWindows = defaultAnomalyDetectionConfig.BaselineWindows
+	}
+	if c.AnomalyDetection.Sigma <= 0 {
+		c.AnomalyDetection.Sigma = defaultAnomalyDetectionConfig.Sigma
+	}
+	if c.AnomalyDetection.MaxRoutes <= 0 {
+		c.AnomalyDetection.MaxRoutes = defaultAnomalyDetectionConfig.MaxRoutes
+	}
+
+	if c.RequestCoalescing.MaxBodySize <= 0 {
+		c.RequestCoalescing.MaxBodySize = defaultRequestCoalescingConfig.MaxBodySize
+	}
+	if c.RequestCoalescing.MaxWait <= 0 {
+		c.RequestCoalescing.MaxWait = defaultRequestCoalescingConfig.MaxWait
+	}
+	for i, host := range c.RequestCoalescing.Hosts {
+		c.RequestCoalescing.Hosts[i] = strings.ToLower(host)
+	}
+
+	c.DeadlinePropagation.Header = strings.TrimSpace(c.DeadlinePropagation.Header)
+	if c.DeadlinePropagation.Header == "" {
+		c.DeadlinePropagation.Header = defaultDeadlinePropagationConfig.Header
+	}
+
+	c.RequestStart.Header = strings.TrimSpace(c.RequestStart.Header)
+	if c.RequestStart.Header == "" {
+		c.RequestStart.Header = defaultRequestStartConfig.Header
+	}
+
+	if c.ResponseStreaming.BufferSize <= 0 {
+		c.ResponseStreaming.BufferSize = defaultResponseStreamingConfig.BufferSize
+	}
+	if c.ResponseStreaming.WriteStallTimeout < 0 {
+		c.ResponseStreaming.WriteStallTimeout = 0
+	}
+	if c.ResponseStreaming.ZeroCopyMinBytes < 0 {
+		c.ResponseStreaming.ZeroCopyMinBytes = 0
+	}
+
+	limit := c.BandwidthLimit.Default
+	if limit.PerRoute < 0 || limit.PerClient < 0 || limit.Burst < 0 {
+		panic("bandwidth_limit.default: limits must not be negative")
+	}
+	if c.BandwidthLimit.MaxClients <= 0 {
+		c.BandwidthLimit.MaxClients = defaultBandwidthLimitConfig.MaxClients
+	}
+
+	if c.AuthFailureMetrics.Window <= 0 {
+		c.AuthFailureMetrics.Window = defaultAuthFailureMetricsConfig.Window
+	}
+	if c.AuthFailureMetrics.Threshold < 0 {
+		c.AuthFailureMetrics.Threshold = 0
+	}
+	if c.AuthFailureMetrics.MaxRoutes <= 0 {
+		c.AuthFailureMetrics.MaxRoutes = defaultAuthFailureMetricsConfig.MaxRoutes
+	}
+
+	if c.LoadShedding.CheckInterval <= 0 {
+		c.LoadShedding.CheckInterval = defaultLoadSheddingConfig.CheckInterval
+	}
+	if c.LoadShedding.RetryAfter < time.Second {
+		c.LoadShedding.RetryAfter = defaultLoadSheddingConfig.RetryAfter
+	}
+
+	c.AdaptiveConcurrency.Limits.process(defaultAdaptiveConcurrencyConfig.Limits)
+	for host, limits := range c.AdaptiveConcurrency.Routes {
+		limits.process(c.AdaptiveConcurrency.Limits)
+		c.AdaptiveConcurrency.Routes[host] = limits
+	}
+	if c.AdaptiveConcurrency.MaxRoutes <= 0 {
+		c.AdaptiveConcurrency.MaxRoutes = defaultAdaptiveConcurrencyConfig.MaxRoutes
+	}
+	if c.AdaptiveConcurrency.RetryAfter < time.Second {
+		c.AdaptiveConcurrency.RetryAfter = defaultAdaptiveConcurrencyConfig.RetryAfter
+	}
+	if c.AdaptiveConcurrency.QueueTimeout <= 0 {
+		c.AdaptiveConcurrency.QueueTimeout = defaultAdaptiveConcurrencyConfig.QueueTimeout
+	}
+
+	c.RequestPriority.process()
+
+	if c.RouteServiceBypass.MaxAge <= 0 {
+		c.RouteServiceBypass.MaxAge = defaultRouteServiceBypassConfig.MaxAge
+	}
+	if c.RouteServiceResponses.LogLevel == "" {
+		c.RouteServiceResponses.LogLevel = defaultRouteServiceResponsesConfig.LogLevel
+	}
+	if !contains(RouteServiceResponseLogLevels, c.RouteServiceResponses.LogLevel) {
+		errMsg := fmt.Sprintf("Invalid route service response log level: %s. Allowed values are %s",
+			c.RouteServiceResponses.LogLevel, RouteServiceResponseLogLevels)
+		panic(errMsg)
+	}
+	if c.RouteServiceForwardedURL.Query == "" {
+		c.RouteServiceForwardedURL.Query = defaultRouteServiceForwardedURLConfig.Query
+	}
+	if !contains(ForwardedURLQueryPolicies, c.RouteServiceForwardedURL.Query) {
+		errMsg := fmt.Sprintf("Invalid route service forwarded url query: %s. Allowed values are %s",
+			c.RouteServiceForwardedURL.Query, ForwardedURLQueryPolicies)
+		panic(errMsg)
+	}
+	if c.RouteServiceForwardedURL.Header == "" {
+		c.RouteServiceForwardedURL.Header = defaultRouteServiceForwardedURLConfig.Header
+	}
+	if strings.ContainsAny(c.RouteServiceForwardedURL.Header, " \t\r\n:") {
+		panic(fmt.Sprintf("Invalid route service forwarded url header: %q", c.RouteServiceForwardedURL.Header))
+	}
+
+	for i := range c.DomainProfiles {
+		profile := &c.DomainProfiles[i]
+		profile.Domain = strings.ToLower(strings.Trim(profile.Domain, "."))
+		if profile.Domain == "" {
+			panic("domain_profiles: domain is required")
+		}
+		if profile.EndpointTimeout < 0 || profile.MaxAttempts < 0 {
+			panic(fmt.Sprintf("domain_profiles: %s: endpoint_timeout and max_attempts cannot be negative", profile.Domain))
+		}
+		if profile.AccessLog == "" {
+			profile.AccessLog = DOMAIN_ACCESS_LOG_ALL
+		}
+		if !contains(DomainAccessLogPolicies, profile.AccessLog) {
+			errMsg := fmt.Sprintf("Invalid domain profile access log: %s. Allowed values are %s",
+				profile.AccessLog, DomainAccessLogPolicies)
+			panic(errMsg)
+		}
+	}
+
+	if c.DNSCache.TTL <= 0 {
+		c.DNSCache.TTL = defaultDNSCacheConfig.TTL
+	}
+	if c.DNSCache.NegativeTTL <= 0 {
+		c.DNSCache.NegativeTTL = defaultDNSCacheConfig.NegativeTTL
+	}
+	if c.DNSCache.MaxEntries <= 0 {
+		c.DNSCache.MaxEntries = defaultDNSCacheConfig.MaxEntries
+	}
+
+	if c.RegisterInterval.MaxInterval <= 0 {
+		c.RegisterInterval.MaxInterval = c.DropletStaleThreshold / 3
+	}
+	if c.RegisterInterval.MaxInterval < c.StartResponseDelayInterval {
+		c.RegisterInterval.MaxInterval = c.StartResponseDelayInterval
+	}
+	if c.RegisterInterval.MaxInterval >= c.DropletStaleThreshold && c.RegisterInterval.TargetRegistrationsPerSecond > 0 {
+		panic("register_interval.max_interval must be shorter than droplet_stale_threshold")
+	}
+
+	if c.RouteTableLimits.MaxURIs < 0 || c.RouteTableLimits.MaxEndpoints < 0 {
+		panic("route_table_limits must not be negative")
+	}
+	if c.PruneChunkSize <= 0 {
+		c.PruneChunkSize = defaultConfig.PruneChunkSize
+	}
+	if c.EndpointQuarantinePeriod < 0 {
+		panic("endpoint_quarantine_period must not be negative")
+	}
+	for i := range c.StaleThresholdClasses {
+		class := &c.StaleThresholdClasses[i]
+		class.Domain = strings.ToLower(strings.Trim(class.Domain, "."))
+		if class.Domain == "" && len(class.Tags) == 0 {
+			panic(fmt.Sprintf("stale_threshold_classes: %s: a domain or tags are required", class.Name))
+		}
+		if class.Threshold <= 0 {
+			panic(fmt.Sprintf("stale_threshold_classes: %s: threshold must be positive", class.Name))
+		}
+	}
+
+	if c.Retries.MaxAttempts <= 0 {
+		c.Retries.MaxAttempts = defaultRetriesConfig.MaxAttempts
+	}
+
+	if c.Accept.Workers <= 0 {
+		c.Accept.Workers = defaultAcceptConfig.Workers
+	}
+	if c.Accept.MaxBackoff <= 0 {
+		c.Accept.MaxBackoff = defaultAcceptConfig.MaxBackoff
+	}
+
+	if c.ConnScavenger.MaxIdle < 0 || c.ConnScavenger.MaxLifetime < 0 {
+		panic("conn_scavenger durations must not be negative")
+	}
+	if c.ConnScavenger.Interval <= 0 {
+		c.ConnScavenger.Interval = defaultConnScavengerConfig.Interval
+	}
+
+	if c.ConnectionLimits.MaxPerIP < 0 {
+		panic("connection_limits.max_per_ip must not be negative")
+	}
+	if !contains(ConnectionLimitPolicies, c.ConnectionLimits.Policy) {
+		errMsg := fmt.Sprintf("Invalid connection limit policy: %s. Allowed values are %s", c.ConnectionLimits.Policy, ConnectionLimitPolicies)
+		panic(errMsg)
+	}
+	c.ConnectionLimits.TrustedNetworks = parseTrustedSources("connection_limits", c.ConnectionLimits.TrustedSources)
+
+	c.TrustedIngress.SourceNetworks = parseTrustedSources("trusted_ingress", c.TrustedIngress.SourceCIDRs)
+	if c.TrustedIngress.ClientCAFile != "" {
+		if !c.EnableSSL {
+			panic("trusted_ingress.client_ca_file requires enable_ssl")
+		}
+		cas, err := ioutil.ReadFile(c.TrustedIngress.ClientCAFile)
+		if err != nil {
+			panic(fmt.Sprintf("trusted_ingress: cannot read client_ca_file: %s", err))
+		}
+		c.TrustedIngress.ClientCAs = x509.NewCertPool()
+		if !c.TrustedIngress.ClientCAs.AppendCertsFromPEM(cas) {
+			panic("trusted_ingress: client_ca_file holds no certificates")
+		}
+	}
+
+	for i, source := range c.RouteSources.Precedence {
+		c.RouteSources.Precedence[i] = strings.ToLower(source)
+	}
+
+	if c.StaticRoutes.PollInterval <= 0 {
+		c.StaticRoutes.PollInterval = defaultStaticRoutesConfig.PollInterval
+	}
+	if c.StaticRoutes.PollInterval >= c.DropletStaleThreshold {
+		panic("static_routes.poll_interval must be shorter than droplet_stale_threshold")
+	}
+
+	if c.Kubernetes.ResyncInterval <= 0 {
+		c.Kubernetes.ResyncInterval = defaultKubernetesConfig.ResyncInterval
+	}
+	if c.Kubernetes.RetryInterval <= 0 {
+		c.Kubernetes.RetryInterval = defaultKubernetesConfig.RetryInterval
+	}
+	if c.Kubernetes.Enabled {
+		if c.Kubernetes.APIServer == "" {
+			panic("kubernetes.api_server is required when kubernetes.enabled is set")
+		}
+		if c.Kubernetes.ResyncInterval >= c.DropletStaleThreshold {
+			panic("kubernetes.resync_interval must be shorter than droplet_stale_threshold")
+		}
+	}
+
+	if c.Consul.RouteTemplate == "" {
+		c.Consul.RouteTemplate = defaultConsulConfig.RouteTemplate
+	}
+	if c.Consul.ResyncInterval <= 0 {
+		c.Consul.ResyncInterval = defaultConsulConfig.ResyncInterval
+	}
+	if c.Consul.RetryInterval <= 0 {
+		c.Consul.RetryInterval = defaultConsulConfig.RetryInterval
+	}
+	if c.Consul.Enabled {
+		if c.Consul.Address == "" {
+			panic("consul.address is required when consul.enabled is set")
+		}
+		if !strings.Contains(c.Consul.RouteTemplate, "{service}") {
+			panic("consul.route_template must contain {service}")
+		}
+		if c.Consul.ResyncInterval >= c.DropletStaleThreshold {
+			panic("consul.resync_interval must be shorter than droplet_stale_threshold")
+		}
+	}
+
+	if c.Xds.Address == "" {
+		c.Xds.Address = defaultXdsConfig.Address
+	}
+	if c.Xds.RefreshInterval <= 0 {
+		c.Xds.RefreshInterval = defaultXdsConfig.RefreshInterval
+	}
+	if c.Xds.RouteConfigName == "" {
+		c.Xds.RouteConfigName = defaultXdsConfig.RouteConfigName
+	}
+	if c.Xds.ConnectTimeout <= 0 {
+		c.Xds.ConnectTimeout = defaultXdsConfig.ConnectTimeout
+	}
+
+	if c.ErrorPages.MaxInlineBytes <= 0 {
+		c.ErrorPages.MaxInlineBytes = defaultErrorPagesConfig.MaxInlineBytes
+	}
+	c.ErrorPages.Pages = map[string][]byte{}
+	for name, path := range c.ErrorPages.Files {
+		page, err := ioutil.ReadFile(path)
+		if err != nil {
+			panic(fmt.Sprintf("error_pages: cannot read %s: %s", name, err))
+		}
+		c.ErrorPages.Pages[name] = page
+	}
+
+	if c.GeoIP.ReloadInterval <= 0 {
+		c.GeoIP.ReloadInterval = defaultGeoIPConfig.ReloadInterval
+	}
+
+	if c.TLSSessions.DisableTickets && c.TLSSessions.TicketKeysFile != "" {
+		panic("tls_sessions: ticket_keys_file cannot be used with disable_tickets")
+	}
+	if c.TLSSessions.TicketKeysReloadInterval <= 0 {
+		c.TLSSessions.TicketKeysReloadInterval = defaultTLSSessionConfig.TicketKeysReloadInterval
+	}
+
+	if c.OCSPStapling.RetryInterval <= 0 {
+		c.OCSPStapling.RetryInterval = defaultOCSPStaplingConfig.RetryInterval
+	}
+	if c.OCSPStapling.Timeout <= 0 {
+		c.OCSPStapling.Timeout = defaultOCSPStaplingConfig.Timeout
+	}
+
+	if c.RequestValidation.Level == "" {
+		c.RequestValidation.Level = REQUEST_VALIDATION_OFF
+	}
+	validRequestValidationLevel := false
+	for _, level := range RequestValidationLevels {
+		if c.RequestValidation.Level == level {
+			validRequestValidationLevel = true
+			break
+		}
+	}
+	if !validRequestValidationLevel {
+		errMsg := fmt.Sprintf("Invalid request validation level: %s. Allowed values are %s", c.RequestValidation.Level, RequestValidationLevels)
+		panic(errMsg)
+	}
+
+	if c.URLNormalization.PercentDecoding == "" {
+		c.URLNormalization.PercentDecoding = URL_PERCENT_DECODING_NONE
+	}
+	validPercentDecoding := false
+	for _, policy := range URLPercentDecodingPolicies {
+		if c.URLNormalization.PercentDecoding == policy {
+			validPercentDecoding = true
+			break
+		}
+	}
+	if !validPercentDecoding {
+		errMsg := fmt.Sprintf("Invalid url_normalization.percent_decoding: %s. Allowed values are %s", c.URLNormalization.PercentDecoding, URLPercentDecodingPolicies)
+		panic(errMsg)
+	}
+
+	for i, rule := range c.CORS.Rules {
+		if rule.Host == "" {
+			panic("cors.rules: host is required")
+		}
+		if len(rule.AllowedOrigins) == 0 {
+			panic(fmt.Sprintf("cors.rules: allowed_origins is required for %s", rule.Host))
+		}
+		c.CORS.Rules[i].Host = strings.ToLower(rule.Host)
+	}
+
+	for i := range c.Experiments {
+		experiment := &c.Experiments[i]
+		if experiment.Name == "" || experiment.Host == "" {
+			panic("experiments: name and host are required")
+		}
+		if len(experiment.Buckets) == 0 {
+			panic(fmt.Sprintf("experiments: %s: buckets are required", experiment.Name))
+		}
+		experiment.Host = strings.ToLower(experiment.Host)
+		for j := range experiment.Buckets {
+			bucket := &experiment.Buckets[j]
+			if bucket.Name == "" || bucket.Weight < 0 {
+				panic(fmt.Sprintf("experiments: %s: buckets need a name and a weight that is not negative", experiment.Name))
+			}
+			if bucket.Weight == 0 {
+				bucket.Weight = 1
+			}
+		}
+	}
+
+	upstreamProxies := map[string]bool{}
+	for _, proxy := range c.UpstreamProxies.Proxies {
+		if proxy.Name == "" || upstreamProxies[proxy.Name] {
+			panic("upstream_proxies: every proxy needs a unique name")
+		}
+		u, err := url.Parse(proxy.URL)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "socks5") || u.Host == "" {
+			panic(fmt.Sprintf("upstream_proxies: %s: url must be http://host:port or socks5://host:port", proxy.Name))
+		}
+		upstreamProxies[proxy.Name] = true
+	}
+	if c.UpstreamProxies.Default != "" && !upstreamProxies[c.UpstreamProxies.Default] {
+		panic(fmt.Sprintf("upstream_proxies: default proxy %s is not configured", c.UpstreamProxies.Default))
+	}
+
+	for _, listener := range c.HealthCheck.Listeners {
+		if !contains(HealthCheckListeners, listener) {
+			panic(fmt.Sprintf("healthcheck: unknown listener %q, choose from %s", listener, HealthCheckListeners))
+		}
+	}
+	for _, path := range c.HealthCheck.Paths {
+		if !strings.HasPrefix(path, "/") {
+			panic(fmt.Sprintf("healthcheck: path %q must start with /", path))
+		}
+	}
+
+	for i, domain := range c.RouteVisibility.InternalDomains {
+		c.RouteVisibility.InternalDomains[i] = strings.ToLower(strings.TrimPrefix(domain, "."))
+	}
+	if c.RouteVisibility.InternalPort != 0 &&
+		(c.RouteVisibility.InternalPort == c.Port || (c.EnableSSL && c.RouteVisibility.InternalPort == c.SSLPort)) {
+		panic(fmt.Sprintf("route_visibility.internal_port %d is already used by another listener", c.RouteVisibility.InternalPort))
+	}
+
+	c.RouteServiceBypass.TrustedNetworks = parseTrustedSources("route_service_bypass", c.RouteServiceBypass.TrustedSources)
+
+	for _, plugin := range c.MiddlewarePlugins {
+		if plugin.Name == "" {
+			panic("middleware_plugins: name is required")
+		}
+	}
+	for _, name := range c.AttemptObservers {
+		if name == "" {
+			panic("attempt_observers: name is required")
+		}
+	}
+
+	if !contains(TimestampFormats, c.Logging.TimestampFormat) {
+		errMsg := fmt.Sprintf("Invalid log timestamp format: %s. Allowed values are %s", c.Logging.TimestampFormat, TimestampFormats)
+		panic(errMsg)
+	}
+
+	for i := range c.AccessLog.Sinks {
+		if c.AccessLog.Sinks[i].TimestampFormat == "" {
+			c.AccessLog.Sinks[i].TimestampFormat = c.AccessLog.TimestampFormat
+		}
+		c.AccessLog.Sinks[i].process()
+	}
+	if c.AccessLog.TimestampFormat != "" && !contains(TimestampFormats, c.AccessLog.TimestampFormat) {
+		errMsg := fmt.Sprintf("Invalid access log timestamp format: %s. Allowed values are %s", c.AccessLog.TimestampFormat, TimestampFormats)
+		panic(errMsg)
+	}
+
+	for i := range c.Logging.Outputs {
+		c.Logging.Outputs[i].process()
+	}
+	if c.Logging.EnvelopeBatching.FlushInterval <= 0 {
+		c.Logging.EnvelopeBatching.FlushInterval = defaultEnvelopeBatchingConfig.FlushInterval
+	}
+	if c.Logging.EnvelopeBatching.MaxBatchSize <= 0 {
+		c.Logging.EnvelopeBatching.MaxBatchSize = defaultEnvelopeBatchingConfig.MaxBatchSize
+	}
+
+	for i := range c.ExternalPlugins {
+		c.ExternalPlugins[i].process()
+	}
+
+	validStaleUpdatePolicy := false
+	for _, sp := range StaleUpdatePolicies {
+		if c.StaleUpdatePolicy == sp {
+			validStaleUpdatePolicy = true
+			break
+		}
+	}
+	if !validStaleUpdatePolicy {
+		errMsg := fmt.Sprintf("Invalid stale update policy: %s. Allowed values are %s", c.StaleUpdatePolicy, StaleUpdatePolicies)
 		panic(errMsg)
 	}
 
@@ -268,6 +2129,203 @@ func (c *Config) Process() {
 	}
 }
 
+func (s *AccessLogSink) process() {
+	if s.Format == "" {
+		s.Format = ACCESS_LOG_FORMAT_CLASSIC
+	}
+
+	if !contains(AccessLogSinkTypes, s.Type) {
+		errMsg := fmt.Sprintf("Invalid access log sink type: %s. Allowed values are %s", s.Type, AccessLogSinkTypes)
+		panic(errMsg)
+	}
+	if !contains(AccessLogFormats, s.Format) {
+		errMsg := fmt.Sprintf("Invalid access log format: %s. Allowed values are %s", s.Format, AccessLogFormats)
+		panic(errMsg)
+	}
+	if s.Type == ACCESS_LOG_SINK_FILE && s.Path == "" {
+		panic("access_log.sinks: path is required for file sinks")
+	}
+	if s.Format == ACCESS_LOG_FORMAT_TEMPLATE && s.Template == "" {
+		panic("access_log.sinks: template is required for the template format")
+	}
+	if s.SampleRate < 0 || s.SampleRate > 1 {
+		errMsg := fmt.Sprintf("Invalid access log sample rate: %v. Must be between 0 and 1", s.SampleRate)
+		panic(errMsg)
+	}
+	if s.TimestampFormat != "" && !contains(TimestampFormats, s.TimestampFormat) {
+		errMsg := fmt.Sprintf("Invalid access log timestamp format: %s. Allowed values are %s", s.TimestampFormat, TimestampFormats)
+		panic(errMsg)
+	}
+}
+
+func (o *LogOutput) process() {
+	if !contains(LogOutputTypes, o.Type) {
+		errMsg := fmt.Sprintf("Invalid log output type: %s. Allowed values are %s", o.Type, LogOutputTypes)
+		panic(errMsg)
+	}
+	if o.Type == LOG_OUTPUT_FILE && o.Path == "" {
+		panic("logging.outputs: path is required for file outputs")
+	}
+	if o.Rotation.MaxSize < 0 || o.Rotation.Interval < 0 || o.Rotation.MaxBackups < 0 {
+		panic("logging.outputs: rotation settings must not be negative")
+	}
+}
+
+func (p *ExternalPluginConfig) process() {
+	if p.Stage == "" {
+		p.Stage = defaultExternalPluginConfig.Stage
+	}
+	if p.Timeout <= 0 {
+		p.Timeout = defaultExternalPluginConfig.Timeout
+	}
+	if p.FailurePolicy == "" {
+		p.FailurePolicy = defaultExternalPluginConfig.FailurePolicy
+	}
+	if p.HealthCheckInterval <= 0 {
+		p.HealthCheckInterval = defaultExternalPluginConfig.HealthCheckInterval
+	}
+
+	if p.Name == "" {
+		panic("external_plugins: name is required")
+	}
+	if p.Path == "" {
+		panic("external_plugins: path is required")
+	}
+	if !contains(ExternalPluginStages, p.Stage) {
+		errMsg := fmt.Sprintf("Invalid external plugin stage: %s. Allowed values are %s", p.Stage, ExternalPluginStages)
+		panic(errMsg)
+	}
+	if !contains(ExternalPluginFailurePolicies, p.FailurePolicy) {
+		errMsg := fmt.Sprintf("Invalid external plugin failure policy: %s. Allowed values are %s", p.FailurePolicy, ExternalPluginFailurePolicies)
+		panic(errMsg)
+	}
+}
+
+func (p *RequestPriorityConfig) process() {
+	if p.Default == "" {
+		p.Default = defaultRequestPriorityConfig.Default
+	}
+	if !contains(Priorities, p.Default) {
+		errMsg := fmt.Sprintf("Invalid request priority: %s. Allowed values are %s", p.Default, Priorities)
+		panic(errMsg)
+	}
+	for host, priority := range p.Routes {
+		if !contains(Priorities, priority) {
+			errMsg := fmt.Sprintf("Invalid request priority for %s: %s. Allowed values are %s", host, priority, Priorities)
+			panic(errMsg)
+		}
+	}
+
+	p.TrustedNetworks = parseTrustedSources("request_priority", p.TrustedSources)
+}
+
+func parseTrustedSources(section string, sources []string) []*net.IPNet {
+	var networks []*net.IPNet
+	for _, source := range sources {
+		_, network, err := net.ParseCIDR(source)
+		if err != nil {
+			panic(fmt.Sprintf("%s: invalid trusted source %s: %s", section, source, err))
+		}
+		networks = append(networks, network)
+	}
+	return networks
+}
+
+func (l *ConcurrencyLimits) process(defaults ConcurrencyLimits) {
+	if l.InitialLimit <= 0 {
+		l.InitialLimit = defaults.InitialLimit
+	}
+	if l.MinLimit <= 0 {
+		l.MinLimit = defaults.MinLimit
+	}
+	if l.MaxLimit <= 0 {
+		l.MaxLimit = defaults.MaxLimit
+	}
+	if l.Tolerance <= 0 {
+		l.Tolerance = defaults.Tolerance
+	}
+
+	if l.MinLimit > l.MaxLimit {
+		panic("adaptive_concurrency: min_limit must not exceed max_limit")
+	}
+	if l.Tolerance < 1 {
+		panic("adaptive_concurrency: tolerance must be at least 1")
+	}
+	if l.InitialLimit < l.MinLimit {
+		l.InitialLimit = l.MinLimit
+	}
+	if l.InitialLimit > l.MaxLimit {
+		l.InitialLimit = l.MaxLimit
+	}
+}
+
+func contains(values []string, v string) bool {
+	for _, value := range values {
+		if value == v {
+			return true
+		}
+	}
+	return false
+}
+
+// processTLSPolicies resolves the policy of each TLS connection. Connections
+// without a policy keep Go's defaults, except for the SSL listener which
+// requires one.
+func (c *Config) processTLSPolicies() {
+	base := c.TLSPolicy.TLSPolicy
+	if c.EnableSSL && base.Preset == "" && len(base.CipherSuites) == 0 && c.CipherString != "" {
+		// cipher_suites predates tls_policy
+		base.Preset = TLSPresetCustom
+		base.CipherSuites = strings.Split(c.CipherString, ":")
+	}
+
+	for listener := range c.TLSPolicy.Listeners {
+		if listener != TLSListenerFrontend && listener != TLSListenerBackend {
+			panic(fmt.Sprintf("tls_policy: unknown listener %q, choose frontend or backend", listener))
+		}
+	}
+
+	c.TLSPolicies = map[string]ResolvedTLSPolicy{}
+	for _, listener := range []string{TLSListenerFrontend, TLSListenerBackend} {
+		policy := base
+		if override, ok := c.TLSPolicy.Listeners[listener]; ok {
+			policy = policy.override(override)
+		}
+		if policy.Preset == "" && len(policy.CipherSuites) == 0 && policy.MinVersion == "" && policy.MaxVersion == "" {
+			continue
+		}
+
+		resolved, err := policy.resolve()
+		if err != nil {
+			panic(fmt.Sprintf("tls_policy: %s: %s", listener, err))
+		}
+		c.TLSPolicies[listener] = resolved
+	}
+
+	if _, ok := c.TLSPolicies[TLSListenerFrontend]; c.EnableSSL && !ok {
+		panic("must specify list of cipher suite or a tls_policy preset when ssl is enabled")
+	}
+}
+
+// HealthChecks returns the healthcheck requests, those sent with
+// HealthCheckUserAgent unless HealthCheck lists user agents
+func (c *Config) HealthChecks() HealthCheckConfig {
+	healthCheck := c.HealthCheck
+	if len(healthCheck.UserAgents) == 0 {
+		healthCheck.UserAgents = []string{c.HealthCheckUserAgent}
+	}
+	return healthCheck
+}
+
+// TLSPolicyFor returns the policy of listener. A config that was not
+// processed only has its CipherSuites.
+func (c *Config) TLSPolicyFor(listener string) ResolvedTLSPolicy {
+	if policy, ok := c.TLSPolicies[listener]; ok {
+		return policy
+	}
+	return ResolvedTLSPolicy{CipherSuites: c.CipherSuites}
+}
+
 func (c *Config) processCipherSuites() []uint16 {
 	cipherMap := map[string]uint16{
 		"TLS_RSA_WITH_RC4_128_SHA":                0x0005,
@@ -0,0 +1,134 @@
+package connlimit
+
+import (
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"sync"
+
+	router_http "code.cloudfoundry.org/gorouter/common/http"
+	"code.cloudfoundry.org/gorouter/config"
+)
+
+var errLimited = errors.New("connlimit: too many connections from client")
+
+// Conn is a conn counted against its client IP. The client is resolved on the
+// first read, off the accept loop, as reading the PROXY protocol header may
+// block.
+type Conn struct {
+	net.Conn
+	limiter *Limiter
+
+	resolveOnce sync.Once
+	// reset is set by resolve when the conn must be reset rather than read
+	reset bool
+
+	lock       sync.Mutex
+	ip         string
+	trusted    bool
+	attributed bool
+	limited    bool
+	closed     bool
+}
+
+func (c *Conn) resolve() {
+	host, _, err := net.SplitHostPort(c.Conn.RemoteAddr().String())
+	if err != nil {
+		return
+	}
+
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	if c.limiter.isTrusted(host) {
+		c.trusted = true
+		return
+	}
+	c.admit(host)
+	c.reset = c.limited && c.limiter.policy == config.CONNECTION_LIMIT_POLICY_RESET
+}
+
+// admit counts the conn against ip, or marks it limited. It must be called
+// with the lock held.
+func (c *Conn) admit(ip string) {
+	if c.closed {
+		return
+	}
+	if !c.limiter.acquire(ip) {
+		c.limited = true
+		c.limiter.limited(ip)
+		return
+	}
+	c.ip = ip
+}
+
+func (c *Conn) Read(p []byte) (int, error) {
+	c.resolveOnce.Do(c.resolve)
+	if c.reset {
+		c.Reset()
+		return 0, errLimited
+	}
+	return c.Conn.Read(p)
+}
+
+func (c *Conn) ReadFrom(r io.Reader) (int64, error) {
+	return router_http.ReadFrom(c.Conn, r)
+}
+
+// Admit reports whether req may be served on the conn. A conn from a trusted
+// peer is counted against the client of its first request.
+func (c *Conn) Admit(req *http.Request) bool {
+	c.resolveOnce.Do(c.resolve)
+
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	if c.trusted && !c.attributed {
+		c.attributed = true
+		if ip := c.limiter.forwardedFor(req); ip != "" {
+			c.admit(ip)
+		}
+	}
+	return !c.limited
+}
+
+// Reject turns away a request Admit refused, by the policy of the limiter
+func (c *Conn) Reject(w http.ResponseWriter) {
+	if c.limiter.policy == config.CONNECTION_LIMIT_POLICY_RESET {
+		if hijacker, ok := w.(http.Hijacker); ok {
+			if conn, _, err := hijacker.Hijack(); err == nil {
+				c.Reset()
+				conn.Close()
+				return
+			}
+		}
+	}
+
+	w.Header().Set("Connection", "close")
+	http.Error(w, http.StatusText(http.StatusTooManyRequests), http.StatusTooManyRequests)
+}
+
+// Reset closes the conn, with a TCP reset when the conn wrapped allows it
+func (c *Conn) Reset() error {
+	if tcpConn, ok := c.Conn.(interface {
+		SetLinger(sec int) error
+	}); ok {
+		tcpConn.SetLinger(0)
+	}
+	return c.Close()
+}
+
+// Close closes the conn and stops counting it against its client
+func (c *Conn) Close() error {
+	c.lock.Lock()
+	if !c.closed {
+		c.closed = true
+		if c.ip != "" {
+			c.limiter.release(c.ip)
+		}
+	}
+	c.lock.Unlock()
+
+	return c.Conn.Close()
+}
@@ -0,0 +1,166 @@
+package connlimit_test
+
+import (
+	"bufio"
+	"context"
+	"net"
+	"net/http"
+
+	"code.cloudfoundry.org/gorouter/config"
+	"code.cloudfoundry.org/gorouter/connlimit"
+	"code.cloudfoundry.org/gorouter/metrics/fakes"
+	"code.cloudfoundry.org/gorouter/test_util"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+type connKey struct{}
+
+var _ = Describe("Limiter", func() {
+	var (
+		cfg      config.ConnectionLimitsConfig
+		reporter *fakes.FakeConnectionLimitReporter
+		limiter  *connlimit.Limiter
+		listener net.Listener
+		server   *http.Server
+	)
+
+	BeforeEach(func() {
+		cfg = config.ConnectionLimitsConfig{
+			MaxPerIP: 2,
+			Policy:   config.CONNECTION_LIMIT_POLICY_TOO_MANY_REQUESTS,
+		}
+		reporter = new(fakes.FakeConnectionLimitReporter)
+	})
+
+	JustBeforeEach(func() {
+		limiter = connlimit.NewLimiter(cfg, reporter, test_util.NewTestZapLogger("connlimit"))
+
+		ln, err := net.Listen("tcp", "127.0.0.1:0")
+		Expect(err).NotTo(HaveOccurred())
+		listener = limiter.Listener(ln)
+
+		server = &http.Server{
+			Handler: http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+				conn := req.Context().Value(connKey{}).(*connlimit.Conn)
+				if !conn.Admit(req) {
+					conn.Reject(w)
+					return
+				}
+				w.WriteHeader(http.StatusOK)
+			}),
+			ConnContext: func(ctx context.Context, conn net.Conn) context.Context {
+				return context.WithValue(ctx, connKey{}, conn)
+			},
+		}
+		go server.Serve(listener)
+	})
+
+	AfterEach(func() {
+		server.Close()
+	})
+
+	// request sends a request on conn and returns the status of the response,
+	// or an error when the conn was closed without one
+	request := func(conn net.Conn, forwardedFor string) (int, error) {
+		req, err := http.NewRequest("GET", "http://example.com/", nil)
+		Expect(err).NotTo(HaveOccurred())
+		if forwardedFor != "" {
+			req.Header.Set("X-Forwarded-For", forwardedFor)
+		}
+		if err := req.Write(conn); err != nil {
+			return 0, err
+		}
+		res, err := http.ReadResponse(bufio.NewReader(conn), req)
+		if err != nil {
+			return 0, err
+		}
+		res.Body.Close()
+		return res.StatusCode, nil
+	}
+
+	dial := func() net.Conn {
+		conn, err := net.Dial("tcp", listener.Addr().String())
+		Expect(err).NotTo(HaveOccurred())
+		return conn
+	}
+
+	It("answers the conns beyond the limit with a 429", func() {
+		first, second, third := dial(), dial(), dial()
+		defer first.Close()
+		defer second.Close()
+		defer third.Close()
+
+		Expect(request(first, "")).To(Equal(http.StatusOK))
+		Expect(request(second, "")).To(Equal(http.StatusOK))
+		Expect(request(third, "")).To(Equal(http.StatusTooManyRequests))
+		Expect(limiter.Conns("127.0.0.1")).To(Equal(2))
+		Expect(reporter.CaptureConnectionLimitedCallCount()).To(Equal(1))
+		Expect(reporter.CaptureConnectionLimitedArgsForCall(0)).To(Equal("too_many_requests"))
+	})
+
+	It("accepts new conns once others are closed", func() {
+		first, second := dial(), dial()
+		defer second.Close()
+		Expect(request(first, "")).To(Equal(http.StatusOK))
+		Expect(request(second, "")).To(Equal(http.StatusOK))
+
+		first.Close()
+		Eventually(func() int { return limiter.Conns("127.0.0.1") }).Should(Equal(1))
+
+		third := dial()
+		defer third.Close()
+		Expect(request(third, "")).To(Equal(http.StatusOK))
+	})
+
+	Context("when the policy is to reset", func() {
+		BeforeEach(func() {
+			cfg.Policy = config.CONNECTION_LIMIT_POLICY_RESET
+		})
+
+		It("resets the conns beyond the limit", func() {
+			first, second, third := dial(), dial(), dial()
+			defer first.Close()
+			defer second.Close()
+			defer third.Close()
+
+			Expect(request(first, "")).To(Equal(http.StatusOK))
+			Expect(request(second, "")).To(Equal(http.StatusOK))
+			_, err := request(third, "")
+			Expect(err).To(HaveOccurred())
+			Expect(reporter.CaptureConnectionLimitedArgsForCall(0)).To(Equal("reset"))
+		})
+	})
+
+	Context("when the peer is trusted", func() {
+		BeforeEach(func() {
+			_, network, _ := net.ParseCIDR("127.0.0.0/8")
+			cfg.TrustedNetworks = []*net.IPNet{network}
+		})
+
+		It("counts conns against the forwarded client", func() {
+			first, second, third, other := dial(), dial(), dial(), dial()
+			defer first.Close()
+			defer second.Close()
+			defer third.Close()
+			defer other.Close()
+
+			Expect(request(first, "10.0.0.1")).To(Equal(http.StatusOK))
+			Expect(request(second, "1.2.3.4, 10.0.0.1, 127.0.0.2")).To(Equal(http.StatusOK))
+			Expect(request(third, "10.0.0.1")).To(Equal(http.StatusTooManyRequests))
+			Expect(request(other, "10.0.0.2")).To(Equal(http.StatusOK))
+
+			Expect(limiter.Conns("10.0.0.1")).To(Equal(2))
+			Expect(limiter.Conns("127.0.0.1")).To(Equal(0))
+		})
+
+		It("does not limit requests without a forwarded client", func() {
+			conns := []net.Conn{dial(), dial(), dial()}
+			for _, conn := range conns {
+				defer conn.Close()
+				Expect(request(conn, "")).To(Equal(http.StatusOK))
+			}
+		})
+	})
+})
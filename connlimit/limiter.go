@@ -0,0 +1,122 @@
+// Package connlimit caps the concurrent connections of each client IP on the
+// frontend listeners, so that a single client cannot exhaust the router's
+// connections. Conns are counted against their client from their first read
+// until they are closed; those beyond the limit are turned away with a 429 on
+// their first request, or reset.
+package connlimit
+
+import (
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+
+	"github.com/uber-go/zap"
+
+	"code.cloudfoundry.org/gorouter/config"
+	"code.cloudfoundry.org/gorouter/logger"
+	"code.cloudfoundry.org/gorouter/metrics"
+)
+
+// Limiter counts the conns of each client IP
+type Limiter struct {
+	maxPerIP int
+	policy   string
+	trusted  []*net.IPNet
+	reporter metrics.ConnectionLimitReporter
+	logger   logger.Logger
+
+	lock  sync.Mutex
+	conns map[string]int
+}
+
+// NewLimiter returns the limiter configured by c
+func NewLimiter(c config.ConnectionLimitsConfig, reporter metrics.ConnectionLimitReporter, logger logger.Logger) *Limiter {
+	return &Limiter{
+		maxPerIP: c.MaxPerIP,
+		policy:   c.Policy,
+		trusted:  c.TrustedNetworks,
+		reporter: reporter,
+		logger:   logger,
+		conns:    make(map[string]int),
+	}
+}
+
+// Listener counts the conns l accepts
+func (l *Limiter) Listener(ln net.Listener) net.Listener {
+	return &listener{Listener: ln, limiter: l}
+}
+
+// Conns returns the number of conns counted against ip
+func (l *Limiter) Conns(ip string) int {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	return l.conns[ip]
+}
+
+func (l *Limiter) acquire(ip string) bool {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	if l.conns[ip] >= l.maxPerIP {
+		return false
+	}
+	l.conns[ip]++
+	return true
+}
+
+func (l *Limiter) release(ip string) {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	if l.conns[ip] <= 1 {
+		delete(l.conns, ip)
+		return
+	}
+	l.conns[ip]--
+}
+
+func (l *Limiter) isTrusted(ip string) bool {
+	parsed := net.ParseIP(ip)
+	if parsed == nil {
+		return false
+	}
+	for _, network := range l.trusted {
+		if network.Contains(parsed) {
+			return true
+		}
+	}
+	return false
+}
+
+// forwardedFor returns the rightmost address of the X-Forwarded-For of req
+// that is not trusted, as the addresses left of it could be forged
+func (l *Limiter) forwardedFor(req *http.Request) string {
+	hops := strings.Split(strings.Join(req.Header["X-Forwarded-For"], ","), ",")
+	for i := len(hops) - 1; i >= 0; i-- {
+		hop := strings.TrimSpace(hops[i])
+		if hop != "" && !l.isTrusted(hop) {
+			return hop
+		}
+	}
+	return ""
+}
+
+func (l *Limiter) limited(ip string) {
+	l.reporter.CaptureConnectionLimited(l.policy)
+	l.logger.Debug("connection-limit-reached", zap.String("client_ip", ip), zap.String("policy", l.policy))
+}
+
+type listener struct {
+	net.Listener
+	limiter *Limiter
+}
+
+func (l *listener) Accept() (net.Conn, error) {
+	c, err := l.Listener.Accept()
+	if err != nil {
+		return nil, err
+	}
+	return &Conn{Conn: c, limiter: l.limiter}, nil
+}
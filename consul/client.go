@@ -0,0 +1,125 @@
+package consul
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// blockingWait is how long Consul holds a blocking query open when nothing
+// changes
+const blockingWait = 5 * time.Minute
+
+// ServiceEntry is an instance of a service as returned by the health endpoint
+type ServiceEntry struct {
+	Node    Node         `json:"Node"`
+	Service AgentService `json:"Service"`
+}
+
+// Node is the node a service instance runs on
+type Node struct {
+	Node       string `json:"Node"`
+	Address    string `json:"Address"`
+	Datacenter string `json:"Datacenter"`
+}
+
+// AgentService is a service instance registered with an agent
+type AgentService struct {
+	ID      string   `json:"ID"`
+	Service string   `json:"Service"`
+	Tags    []string `json:"Tags"`
+	Address string   `json:"Address"`
+	Port    int      `json:"Port"`
+}
+
+// Client reads the service catalog from a Consul agent with blocking queries
+type Client struct {
+	address    string
+	token      string
+	datacenter string
+	httpClient *http.Client
+}
+
+// NewClient returns a Client for the agent at address, such as
+// http://127.0.0.1:8500. An empty datacenter queries the agent's own.
+func NewClient(address, token, datacenter string) *Client {
+	return &Client{
+		address:    strings.TrimSuffix(address, "/"),
+		token:      token,
+		datacenter: datacenter,
+		httpClient: &http.Client{
+			// leave room for the jitter Consul adds to the wait
+			Timeout: blockingWait + blockingWait/16 + 10*time.Second,
+		},
+	}
+}
+
+// Services returns the names and tags of the services in the catalog once its
+// index moves past index
+func (c *Client) Services(ctx context.Context, index uint64) (map[string][]string, uint64, error) {
+	var services map[string][]string
+	index, err := c.get(ctx, "/v1/catalog/services", nil, index, &services)
+	return services, index, err
+}
+
+// Instances returns the instances of service, only those whose checks all
+// pass when passingOnly is set, once the index moves past index
+func (c *Client) Instances(ctx context.Context, service string, passingOnly bool, index uint64) ([]ServiceEntry, uint64, error) {
+	query := url.Values{}
+	if passingOnly {
+		query.Set("passing", "true")
+	}
+
+	var entries []ServiceEntry
+	index, err := c.get(ctx, "/v1/health/service/"+url.PathEscape(service), query, index, &entries)
+	return entries, index, err
+}
+
+func (c *Client) get(ctx context.Context, path string, query url.Values, index uint64, into interface{}) (uint64, error) {
+	if query == nil {
+		query = url.Values{}
+	}
+	if c.datacenter != "" {
+		query.Set("dc", c.datacenter)
+	}
+	if index > 0 {
+		query.Set("index", strconv.FormatUint(index, 10))
+		query.Set("wait", fmt.Sprintf("%ds", int(blockingWait.Seconds())))
+	}
+
+	req, err := http.NewRequest("GET", c.address+path+"?"+query.Encode(), nil)
+	if err != nil {
+		return 0, err
+	}
+	req = req.WithContext(ctx)
+	if c.token != "" {
+		req.Header.Set("X-Consul-Token", c.token)
+	}
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := ioutil.ReadAll(resp.Body)
+		return 0, fmt.Errorf("GET %s: %s: %s", path, resp.Status, strings.TrimSpace(string(body)))
+	}
+
+	if err := json.NewDecoder(resp.Body).Decode(into); err != nil {
+		return 0, err
+	}
+
+	newIndex, err := strconv.ParseUint(resp.Header.Get("X-Consul-Index"), 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("GET %s: invalid X-Consul-Index: %s", path, err)
+	}
+	return newIndex, nil
+}
@@ -0,0 +1,291 @@
+package consul
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/uber-go/zap"
+
+	"code.cloudfoundry.org/gorouter/logger"
+	"code.cloudfoundry.org/gorouter/registry"
+	"code.cloudfoundry.org/gorouter/route"
+	"code.cloudfoundry.org/routing-api/models"
+)
+
+// Placeholders expanded in a route template
+const (
+	PlaceholderService    = "{service}"
+	PlaceholderDatacenter = "{datacenter}"
+	PlaceholderDomain     = "{domain}"
+)
+
+// RegistrarOpts configures a Registrar
+type RegistrarOpts struct {
+	// RouteTemplate builds the route of a service instance from its
+	// placeholders
+	RouteTemplate string
+	Domain        string
+	// Tag, when set, limits the synced services to those carrying it
+	Tag            string
+	PassingOnly    bool
+	ResyncInterval time.Duration
+	RetryInterval  time.Duration
+}
+
+// Registrar registers the instances of the services in a Consul catalog in a
+// registry. The catalog and every synced service are followed with blocking
+// queries; each change is reconciled against what was registered before, and
+// all instances are registered again every resync interval so that they are
+// never pruned.
+type Registrar struct {
+	client   *Client
+	registry registry.Registry
+	opts     RegistrarOpts
+	logger   logger.Logger
+
+	lock      sync.Mutex
+	instances map[string][]ServiceEntry
+	watchers  map[string]context.CancelFunc
+	changed   chan struct{}
+
+	registered map[registration]struct{}
+}
+
+type registration struct {
+	uri        route.Uri
+	host       string
+	port       uint16
+	service    string
+	datacenter string
+	node       string
+	instance   string
+}
+
+func (r registration) makeEndpoint() *route.Endpoint {
+	return route.NewEndpoint(
+		"",
+		r.host,
+		r.port,
+		r.instance,
+		"",
+		map[string]string{"service": r.service, "datacenter": r.datacenter, "node": r.node},
+		0,
+		"",
+		models.ModificationTag{},
+		"",
+	)
+}
+
+// NewRegistrar returns a Registrar syncing the catalog read by client
+func NewRegistrar(client *Client, registry registry.Registry, opts RegistrarOpts, logger logger.Logger) *Registrar {
+	return &Registrar{
+		client:     client,
+		registry:   registry,
+		opts:       opts,
+		logger:     logger,
+		instances:  map[string][]ServiceEntry{},
+		watchers:   map[string]context.CancelFunc{},
+		changed:    make(chan struct{}, 1),
+		registered: map[registration]struct{}{},
+	}
+}
+
+// Run syncs the catalog until signaled
+func (r *Registrar) Run(signals <-chan os.Signal, ready chan<- struct{}) error {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	go r.watchCatalog(ctx)
+
+	close(ready)
+	r.logger.Info("consul-registrar-started")
+
+	ticker := time.NewTicker(r.opts.ResyncInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-r.changed:
+			r.reconcile(false)
+		case <-ticker.C:
+			r.reconcile(true)
+		case <-signals:
+			r.logger.Info("consul-registrar-exited")
+			return nil
+		}
+	}
+}
+
+// watchCatalog starts following each service as it appears in the catalog
+// and stops when it disappears
+func (r *Registrar) watchCatalog(ctx context.Context) {
+	var index uint64
+	for ctx.Err() == nil {
+		services, newIndex, err := r.client.Services(ctx, index)
+		if err != nil {
+			if ctx.Err() == nil {
+				r.logger.Error("consul-catalog-query-failed", zap.Error(err))
+				r.wait(ctx)
+			}
+			continue
+		}
+		index = nextIndex(index, newIndex)
+
+		r.lock.Lock()
+		for name, tags := range services {
+			if _, ok := r.watchers[name]; ok || !r.selected(tags) {
+				continue
+			}
+			serviceCtx, stop := context.WithCancel(ctx)
+			r.watchers[name] = stop
+			go r.watchService(serviceCtx, name)
+		}
+		for name, stop := range r.watchers {
+			if tags, ok := services[name]; !ok || !r.selected(tags) {
+				stop()
+				delete(r.watchers, name)
+				delete(r.instances, name)
+			}
+		}
+		r.lock.Unlock()
+		r.notify()
+	}
+}
+
+func (r *Registrar) selected(tags []string) bool {
+	if r.opts.Tag == "" {
+		return true
+	}
+	for _, tag := range tags {
+		if tag == r.opts.Tag {
+			return true
+		}
+	}
+	return false
+}
+
+func (r *Registrar) watchService(ctx context.Context, name string) {
+	var index uint64
+	for ctx.Err() == nil {
+		entries, newIndex, err := r.client.Instances(ctx, name, r.opts.PassingOnly, index)
+		if err != nil {
+			if ctx.Err() == nil {
+				r.logger.Error("consul-service-query-failed", zap.String("service", name), zap.Error(err))
+				r.wait(ctx)
+			}
+			continue
+		}
+		index = nextIndex(index, newIndex)
+
+		r.lock.Lock()
+		// the catalog watch may have dropped the service meanwhile
+		if ctx.Err() == nil {
+			r.instances[name] = entries
+		}
+		r.lock.Unlock()
+		r.notify()
+	}
+}
+
+// nextIndex returns the index for the next blocking query, resetting it when
+// Consul reports an index that went backwards
+func nextIndex(previous, current uint64) uint64 {
+	if current < previous {
+		return 0
+	}
+	if current == 0 {
+		return 1
+	}
+	return current
+}
+
+func (r *Registrar) wait(ctx context.Context) {
+	select {
+	case <-time.After(r.opts.RetryInterval):
+	case <-ctx.Done():
+	}
+}
+
+func (r *Registrar) notify() {
+	select {
+	case r.changed <- struct{}{}:
+	default:
+	}
+}
+
+// reconcile registers the instances that appeared and unregisters those that
+// went away since the last call. With refresh set every instance is
+// registered again.
+func (r *Registrar) reconcile(refresh bool) {
+	desired := r.desired()
+
+	removed := 0
+	for reg := range r.registered {
+		if _, ok := desired[reg]; !ok {
+			r.registry.Unregister(reg.uri, reg.makeEndpoint())
+			removed++
+		}
+	}
+
+	added := 0
+	for reg := range desired {
+		if _, ok := r.registered[reg]; !ok {
+			added++
+		} else if !refresh {
+			continue
+		}
+		r.registry.Register(reg.uri, reg.makeEndpoint())
+	}
+
+	if added > 0 || removed > 0 {
+		r.logger.Info("consul-instances-reconciled",
+			zap.Int("instances", len(desired)),
+			zap.Int("added", added),
+			zap.Int("removed", removed),
+		)
+	}
+
+	r.registered = desired
+}
+
+func (r *Registrar) desired() map[registration]struct{} {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	desired := map[registration]struct{}{}
+	for name, entries := range r.instances {
+		for _, entry := range entries {
+			address := entry.Service.Address
+			if address == "" {
+				address = entry.Node.Address
+			}
+			if address == "" || entry.Service.Port <= 0 || entry.Service.Port > 65535 {
+				continue
+			}
+
+			desired[registration{
+				uri:        r.route(name, entry.Node.Datacenter),
+				host:       address,
+				port:       uint16(entry.Service.Port),
+				service:    name,
+				datacenter: entry.Node.Datacenter,
+				node:       entry.Node.Node,
+				instance:   fmt.Sprintf("%s/%s", entry.Node.Node, entry.Service.ID),
+			}] = struct{}{}
+		}
+	}
+
+	return desired
+}
+
+func (r *Registrar) route(service, datacenter string) route.Uri {
+	return route.Uri(strings.NewReplacer(
+		PlaceholderService, service,
+		PlaceholderDatacenter, datacenter,
+		PlaceholderDomain, r.opts.Domain,
+	).Replace(r.opts.RouteTemplate))
+}
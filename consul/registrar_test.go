@@ -0,0 +1,129 @@
+package consul_test
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"time"
+
+	"code.cloudfoundry.org/gorouter/config"
+	"code.cloudfoundry.org/gorouter/consul"
+	"code.cloudfoundry.org/gorouter/metrics/fakes"
+	"code.cloudfoundry.org/gorouter/registry"
+	"code.cloudfoundry.org/gorouter/route"
+	"code.cloudfoundry.org/gorouter/test_util"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/tedsuo/ifrit"
+)
+
+const webInstances = `[
+  {"Node": {"Node": "node-1", "Address": "10.2.0.1", "Datacenter": "dc1"},
+   "Service": {"ID": "web-1", "Service": "web", "Port": 8080}},
+  {"Node": {"Node": "node-2", "Address": "10.2.0.2", "Datacenter": "dc1"},
+   "Service": {"ID": "web-2", "Service": "web", "Address": "10.3.0.2", "Port": 8081}}
+]`
+
+var _ = Describe("Registrar", func() {
+	var (
+		server     *httptest.Server
+		table      *registry.RouteRegistry
+		process    ifrit.Process
+		webUpdates chan string
+		queries    chan string
+	)
+
+	addresses := func(uri route.Uri) func() []string {
+		return func() []string {
+			var addrs []string
+			if pool := table.Lookup(uri); pool != nil {
+				pool.Each(func(e *route.Endpoint) {
+					addrs = append(addrs, e.CanonicalAddr())
+				})
+			}
+			return addrs
+		}
+	}
+
+	BeforeEach(func() {
+		webUpdates = make(chan string, 10)
+		queries = make(chan string, 100)
+		server = httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+			select {
+			case queries <- req.URL.String():
+			default:
+			}
+
+			blocking := req.URL.Query().Get("index") != ""
+			switch req.URL.Path {
+			case "/v1/catalog/services":
+				if blocking {
+					<-req.Context().Done()
+					return
+				}
+				rw.Header().Set("X-Consul-Index", "5")
+				fmt.Fprint(rw, `{"web": ["gorouter"], "db": []}`)
+			case "/v1/health/service/web":
+				body := webInstances
+				if blocking {
+					select {
+					case body = <-webUpdates:
+					case <-req.Context().Done():
+						return
+					}
+				}
+				rw.Header().Set("X-Consul-Index", "7")
+				fmt.Fprint(rw, body)
+			default:
+				rw.WriteHeader(http.StatusNotFound)
+			}
+		}))
+
+		logger := test_util.NewTestZapLogger("consul")
+		table = registry.NewRouteRegistry(logger, config.DefaultConfig(), new(fakes.FakeRouteRegistryReporter))
+		registrar := consul.NewRegistrar(consul.NewClient(server.URL, "", ""), table, consul.RegistrarOpts{
+			RouteTemplate:  "{service}.{datacenter}.{domain}",
+			Domain:         "example.com",
+			Tag:            "gorouter",
+			PassingOnly:    true,
+			ResyncInterval: time.Minute,
+			RetryInterval:  10 * time.Millisecond,
+		}, logger)
+		process = ifrit.Invoke(registrar)
+	})
+
+	AfterEach(func() {
+		process.Signal(os.Interrupt)
+		Eventually(process.Wait()).Should(Receive())
+		server.CloseClientConnections()
+		server.Close()
+	})
+
+	It("registers the instances of tagged services under the templated route", func() {
+		Eventually(addresses("web.dc1.example.com")).Should(ConsistOf("10.2.0.1:8080", "10.3.0.2:8081"))
+		Consistently(table.NumUris).Should(Equal(1))
+
+		var tags []map[string]string
+		table.Lookup("web.dc1.example.com").Each(func(e *route.Endpoint) {
+			tags = append(tags, e.Tags)
+		})
+		Expect(tags).To(ContainElement(map[string]string{"service": "web", "datacenter": "dc1", "node": "node-1"}))
+	})
+
+	It("only asks for instances passing their health checks", func() {
+		Eventually(queries).Should(Receive(HavePrefix("/v1/health/service/web?passing=true")))
+	})
+
+	It("follows changes to the service instances", func() {
+		Eventually(addresses("web.dc1.example.com")).Should(HaveLen(2))
+
+		webUpdates <- `[{"Node": {"Node": "node-3", "Address": "10.2.0.3", "Datacenter": "dc1"},
+  "Service": {"ID": "web-3", "Service": "web", "Port": 8080}}]`
+		Eventually(addresses("web.dc1.example.com")).Should(ConsistOf("10.2.0.3:8080"))
+
+		webUpdates <- `[]`
+		Eventually(addresses("web.dc1.example.com")).Should(BeEmpty())
+	})
+})
@@ -0,0 +1,174 @@
+package geoip_test
+
+import (
+	"bytes"
+	"encoding/binary"
+	"net"
+	"sort"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"testing"
+)
+
+func TestGeoIP(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "GeoIP Suite")
+}
+
+// network maps a CIDR to the record stored for it
+type network struct {
+	cidr   string
+	record interface{}
+}
+
+// pointer encodes a data section pointer to offset
+type pointer uint
+
+// buildDatabase writes a MaxMind DB file holding networks. The data section
+// starts with shared, which records can refer to with pointer(0).
+func buildDatabase(ipVersion, recordSize int, shared interface{}, networks ...network) []byte {
+	var data bytes.Buffer
+	if shared != nil {
+		encodeValue(&data, shared)
+	}
+
+	// a child of -1 is empty, and -2-i points at the data of network i
+	nodes := [][2]int{{-1, -1}}
+	offsets := make([]int, len(networks))
+	for i, n := range networks {
+		offsets[i] = data.Len()
+		encodeValue(&data, n.record)
+
+		_, ipNet, err := net.ParseCIDR(n.cidr)
+		Expect(err).ToNot(HaveOccurred())
+		ip := ipNet.IP
+		ones, bits := ipNet.Mask.Size()
+		if ipVersion == 6 && bits == 32 {
+			ip = append(make(net.IP, 12), ip.To4()...)
+			ones += 96
+		}
+
+		node := 0
+		for bit := 0; bit < ones; bit++ {
+			b := int(ip[bit/8]>>(7-uint(bit%8))) & 1
+			if bit == ones-1 {
+				nodes[node][b] = -2 - i
+				break
+			}
+			if nodes[node][b] <= 0 {
+				nodes = append(nodes, [2]int{-1, -1})
+				nodes[node][b] = len(nodes) - 1
+			}
+			node = nodes[node][b]
+		}
+	}
+
+	nodeCount := len(nodes)
+	value := func(child int) uint32 {
+		switch {
+		case child == -1:
+			return uint32(nodeCount)
+		case child < -1:
+			return uint32(nodeCount + 16 + offsets[-2-child])
+		default:
+			return uint32(child)
+		}
+	}
+
+	var file bytes.Buffer
+	for _, n := range nodes {
+		left, right := value(n[0]), value(n[1])
+		switch recordSize {
+		case 24:
+			file.Write([]byte{byte(left >> 16), byte(left >> 8), byte(left), byte(right >> 16), byte(right >> 8), byte(right)})
+		case 28:
+			file.Write([]byte{byte(left >> 16), byte(left >> 8), byte(left),
+				byte(left>>24)<<4 | byte(right>>24)&0x0F,
+				byte(right >> 16), byte(right >> 8), byte(right)})
+		case 32:
+			binary.Write(&file, binary.BigEndian, []uint32{left, right})
+		}
+	}
+	file.Write(make([]byte, 16))
+	file.Write(data.Bytes())
+
+	file.WriteString("\xAB\xCD\xEFMaxMind.com")
+	encodeValue(&file, map[string]interface{}{
+		"node_count":    uint32(nodeCount),
+		"record_size":   uint16(recordSize),
+		"ip_version":    uint16(ipVersion),
+		"database_type": "Test-City",
+	})
+	return file.Bytes()
+}
+
+func encodeValue(buf *bytes.Buffer, v interface{}) {
+	switch v := v.(type) {
+	case string:
+		encodeControl(buf, 2, len(v))
+		buf.WriteString(v)
+	case uint16:
+		encodeControl(buf, 5, 2)
+		binary.Write(buf, binary.BigEndian, v)
+	case uint32:
+		encodeControl(buf, 6, 4)
+		binary.Write(buf, binary.BigEndian, v)
+	case float64:
+		encodeControl(buf, 3, 8)
+		binary.Write(buf, binary.BigEndian, v)
+	case bool:
+		size := 0
+		if v {
+			size = 1
+		}
+		encodeControl(buf, 14, size)
+	case map[string]interface{}:
+		encodeControl(buf, 7, len(v))
+		keys := make([]string, 0, len(v))
+		for k := range v {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			encodeValue(buf, k)
+			encodeValue(buf, v[k])
+		}
+	case []interface{}:
+		encodeControl(buf, 11, len(v))
+		for _, e := range v {
+			encodeValue(buf, e)
+		}
+	case pointer:
+		Expect(v).To(BeNumerically("<", 2048))
+		buf.Write([]byte{1<<5 | byte(v>>8)&0x7, byte(v)})
+	default:
+		Fail("cannot encode value")
+	}
+}
+
+func encodeControl(buf *bytes.Buffer, typ, size int) {
+	first := byte(0)
+	if typ <= 7 {
+		first = byte(typ << 5)
+	}
+
+	var extra []byte
+	switch {
+	case size < 29:
+		first |= byte(size)
+	case size < 285:
+		first |= 29
+		extra = []byte{byte(size - 29)}
+	default:
+		first |= 30
+		extra = []byte{byte((size - 285) >> 8), byte(size - 285)}
+	}
+
+	buf.WriteByte(first)
+	if typ > 7 {
+		buf.WriteByte(byte(typ - 7))
+	}
+	buf.Write(extra)
+}
@@ -0,0 +1,104 @@
+package geoip
+
+import (
+	"io/ioutil"
+	"net"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/uber-go/zap"
+
+	"code.cloudfoundry.org/gorouter/logger"
+)
+
+// Locator looks up addresses in a database file and reloads it when the file
+// changes. A file that cannot be loaded leaves the previous database in use.
+type Locator struct {
+	path     string
+	interval time.Duration
+	logger   logger.Logger
+
+	lock    sync.RWMutex
+	reader  *Reader
+	modTime time.Time
+	size    int64
+}
+
+// NewLocator loads the database at path, failing when it cannot be read
+func NewLocator(path string, interval time.Duration, logger logger.Logger) (*Locator, error) {
+	l := &Locator{path: path, interval: interval, logger: logger}
+	if _, err := l.Reload(); err != nil {
+		return nil, err
+	}
+	return l, nil
+}
+
+// Lookup returns the location of ip, or an empty location when it is unknown
+func (l *Locator) Lookup(ip net.IP) Location {
+	l.lock.RLock()
+	reader := l.reader
+	l.lock.RUnlock()
+
+	loc, err := reader.Lookup(ip)
+	if err != nil {
+		l.logger.Debug("geoip-lookup-failed", zap.String("ip", ip.String()), zap.Error(err))
+	}
+	return loc
+}
+
+// Reload loads the database again when the file changed since it was last
+// loaded, and reports whether it did
+func (l *Locator) Reload() (bool, error) {
+	info, err := os.Stat(l.path)
+	if err != nil {
+		return false, err
+	}
+
+	l.lock.RLock()
+	unchanged := l.reader != nil && info.ModTime().Equal(l.modTime) && info.Size() == l.size
+	l.lock.RUnlock()
+	if unchanged {
+		return false, nil
+	}
+
+	buf, err := ioutil.ReadFile(l.path)
+	if err != nil {
+		return false, err
+	}
+	reader, err := NewReader(buf)
+	if err != nil {
+		return false, err
+	}
+
+	l.lock.Lock()
+	l.reader = reader
+	l.modTime = info.ModTime()
+	l.size = info.Size()
+	l.lock.Unlock()
+
+	l.logger.Info("geoip-database-loaded",
+		zap.String("path", l.path),
+		zap.String("type", reader.DatabaseType()),
+	)
+	return true, nil
+}
+
+// Run reloads the database every interval until signaled
+func (l *Locator) Run(signals <-chan os.Signal, ready chan<- struct{}) error {
+	close(ready)
+
+	ticker := time.NewTicker(l.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			if _, err := l.Reload(); err != nil {
+				l.logger.Error("geoip-database-reload-failed", zap.String("path", l.path), zap.Error(err))
+			}
+		case <-signals:
+			return nil
+		}
+	}
+}
@@ -0,0 +1,79 @@
+package geoip_test
+
+import (
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"time"
+
+	"code.cloudfoundry.org/gorouter/geoip"
+	"code.cloudfoundry.org/gorouter/test_util"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Locator", func() {
+	var (
+		dir  string
+		path string
+	)
+
+	country := func(code string) map[string]interface{} {
+		return map[string]interface{}{"country": map[string]interface{}{"iso_code": code}}
+	}
+
+	writeDatabase := func(code string, modTime time.Time) {
+		Expect(ioutil.WriteFile(path, buildDatabase(4, 24, nil, network{"192.0.2.0/24", country(code)}), 0644)).To(Succeed())
+		Expect(os.Chtimes(path, modTime, modTime)).To(Succeed())
+	}
+
+	BeforeEach(func() {
+		var err error
+		dir, err = ioutil.TempDir("", "geoip")
+		Expect(err).ToNot(HaveOccurred())
+		path = filepath.Join(dir, "GeoLite2-Country.mmdb")
+	})
+
+	AfterEach(func() {
+		os.RemoveAll(dir)
+	})
+
+	It("fails when the database cannot be loaded", func() {
+		_, err := geoip.NewLocator(path, time.Minute, test_util.NewTestZapLogger("geoip"))
+		Expect(err).To(HaveOccurred())
+
+		Expect(ioutil.WriteFile(path, []byte("garbage"), 0644)).To(Succeed())
+		_, err = geoip.NewLocator(path, time.Minute, test_util.NewTestZapLogger("geoip"))
+		Expect(err).To(HaveOccurred())
+	})
+
+	It("reloads the database when the file changes", func() {
+		writeDatabase("US", time.Now().Add(-time.Hour))
+		locator, err := geoip.NewLocator(path, time.Minute, test_util.NewTestZapLogger("geoip"))
+		Expect(err).ToNot(HaveOccurred())
+		Expect(locator.Lookup(net.ParseIP("192.0.2.1")).Country).To(Equal("US"))
+
+		reloaded, err := locator.Reload()
+		Expect(err).ToNot(HaveOccurred())
+		Expect(reloaded).To(BeFalse())
+
+		writeDatabase("CA", time.Now())
+		reloaded, err = locator.Reload()
+		Expect(err).ToNot(HaveOccurred())
+		Expect(reloaded).To(BeTrue())
+		Expect(locator.Lookup(net.ParseIP("192.0.2.1")).Country).To(Equal("CA"))
+	})
+
+	It("keeps the previous database when the new one is invalid", func() {
+		writeDatabase("US", time.Now().Add(-time.Hour))
+		locator, err := geoip.NewLocator(path, time.Minute, test_util.NewTestZapLogger("geoip"))
+		Expect(err).ToNot(HaveOccurred())
+
+		Expect(ioutil.WriteFile(path, []byte("garbage"), 0644)).To(Succeed())
+		_, err = locator.Reload()
+		Expect(err).To(HaveOccurred())
+		Expect(locator.Lookup(net.ParseIP("192.0.2.1")).Country).To(Equal("US"))
+	})
+})
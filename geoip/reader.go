@@ -0,0 +1,367 @@
+// Package geoip looks up the location of client addresses in a MaxMind DB
+// (.mmdb) file, such as the GeoLite2 and GeoIP2 Country and City databases.
+// Only the reading side of the format is implemented, so no MaxMind library
+// has to be vendored.
+package geoip
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+	"net"
+)
+
+var metadataMarker = []byte("\xAB\xCD\xEFMaxMind.com")
+
+// Data types of the MaxMind DB data section
+const (
+	typeExtended = iota
+	typePointer
+	typeString
+	typeDouble
+	typeBytes
+	typeUint16
+	typeUint32
+	typeMap
+	typeInt32
+	typeUint64
+	typeUint128
+	typeArray
+	typeContainer
+	typeEndMarker
+	typeBool
+	typeFloat
+)
+
+var errCorrupt = errors.New("geoip: corrupt database")
+
+// Location is the part of a database record gorouter uses. Region is the ISO
+// code of the first subdivision, and empty in country databases.
+type Location struct {
+	Country string
+	Region  string
+}
+
+// Reader looks up addresses in a database held in memory
+type Reader struct {
+	buf          []byte
+	nodeCount    uint
+	recordSize   uint
+	ipVersion    uint
+	databaseType string
+	data         []byte
+	ipv4Start    uint
+}
+
+// NewReader parses the database in buf
+func NewReader(buf []byte) (*Reader, error) {
+	i := bytes.LastIndex(buf, metadataMarker)
+	if i < 0 {
+		return nil, errors.New("geoip: metadata not found, not a MaxMind DB file")
+	}
+
+	metaStart := i + len(metadataMarker)
+	meta, _, err := decoder{buf: buf[metaStart:]}.decode(0, 0)
+	if err != nil {
+		return nil, err
+	}
+	m, ok := meta.(map[string]interface{})
+	if !ok {
+		return nil, errCorrupt
+	}
+
+	r := &Reader{buf: buf}
+	r.nodeCount, _ = asUint(m["node_count"])
+	r.recordSize, _ = asUint(m["record_size"])
+	r.ipVersion, _ = asUint(m["ip_version"])
+	r.databaseType, _ = m["database_type"].(string)
+
+	switch r.recordSize {
+	case 24, 28, 32:
+	default:
+		return nil, fmt.Errorf("geoip: unsupported record size %d", r.recordSize)
+	}
+	if r.ipVersion != 4 && r.ipVersion != 6 {
+		return nil, fmt.Errorf("geoip: unsupported ip version %d", r.ipVersion)
+	}
+
+	treeSize := r.nodeCount * r.recordSize / 4
+	if treeSize+16 > uint(i) {
+		return nil, errCorrupt
+	}
+	r.data = buf[treeSize+16 : i]
+
+	if r.ipVersion == 6 {
+		// IPv4 addresses live under ::/96
+		node := uint(0)
+		for bit := 0; bit < 96 && node < r.nodeCount; bit++ {
+			node = r.record(node, 0)
+		}
+		r.ipv4Start = node
+	}
+
+	return r, nil
+}
+
+// DatabaseType is the type recorded in the database metadata, such as
+// "GeoLite2-Country"
+func (r *Reader) DatabaseType() string {
+	return r.databaseType
+}
+
+// Lookup returns the location of ip. An address the database does not cover
+// has an empty location.
+func (r *Reader) Lookup(ip net.IP) (Location, error) {
+	record, err := r.lookup(ip)
+	if err != nil || record == nil {
+		return Location{}, err
+	}
+
+	m, ok := record.(map[string]interface{})
+	if !ok {
+		return Location{}, nil
+	}
+
+	var loc Location
+	for _, key := range []string{"country", "registered_country"} {
+		if country, ok := m[key].(map[string]interface{}); ok {
+			if loc.Country, _ = country["iso_code"].(string); loc.Country != "" {
+				break
+			}
+		}
+	}
+	if subdivisions, ok := m["subdivisions"].([]interface{}); ok && len(subdivisions) > 0 {
+		if subdivision, ok := subdivisions[0].(map[string]interface{}); ok {
+			loc.Region, _ = subdivision["iso_code"].(string)
+		}
+	}
+	return loc, nil
+}
+
+func (r *Reader) lookup(ip net.IP) (interface{}, error) {
+	node := uint(0)
+	bits := 128
+
+	if ip4 := ip.To4(); ip4 != nil {
+		ip = ip4
+		bits = 32
+		node = r.ipv4Start
+	} else if r.ipVersion == 4 {
+		return nil, nil
+	}
+	if len(ip) != bits/8 {
+		return nil, fmt.Errorf("geoip: invalid address %v", ip)
+	}
+
+	for bit := 0; bit < bits && node < r.nodeCount; bit++ {
+		node = r.record(node, uint(ip[bit/8]>>(7-uint(bit%8)))&1)
+	}
+
+	switch {
+	case node == r.nodeCount:
+		return nil, nil
+	case node < r.nodeCount:
+		return nil, errCorrupt
+	}
+
+	offset := node - r.nodeCount - 16
+	if offset >= uint(len(r.data)) {
+		return nil, errCorrupt
+	}
+	value, _, err := decoder{buf: r.data}.decode(offset, 0)
+	return value, err
+}
+
+// record returns the left (bit 0) or right (bit 1) record of node
+func (r *Reader) record(node, bit uint) uint {
+	b := r.buf
+	switch r.recordSize {
+	case 24:
+		o := node*6 + bit*3
+		return uint(b[o])<<16 | uint(b[o+1])<<8 | uint(b[o+2])
+	case 28:
+		o := node * 7
+		if bit == 0 {
+			return uint(b[o+3]&0xF0)<<20 | uint(b[o])<<16 | uint(b[o+1])<<8 | uint(b[o+2])
+		}
+		return uint(b[o+3]&0x0F)<<24 | uint(b[o+4])<<16 | uint(b[o+5])<<8 | uint(b[o+6])
+	default:
+		o := node*8 + bit*4
+		return uint(binary.BigEndian.Uint32(b[o : o+4]))
+	}
+}
+
+// decoder decodes values of the data section, or of the metadata which uses
+// the same encoding
+type decoder struct {
+	buf []byte
+}
+
+// maxDepth bounds nesting, so a corrupt database cannot recurse forever
+const maxDepth = 32
+
+// decode returns the value at offset and the offset following it
+func (d decoder) decode(offset uint, depth int) (interface{}, uint, error) {
+	if depth > maxDepth {
+		return nil, 0, errCorrupt
+	}
+
+	typ, size, offset, err := d.control(offset)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	if typ == typePointer {
+		target, next, err := d.pointer(size, offset)
+		if err != nil {
+			return nil, 0, err
+		}
+		value, _, err := d.decode(target, depth+1)
+		return value, next, err
+	}
+
+	switch typ {
+	case typeMap:
+		m := make(map[string]interface{}, size)
+		for i := uint(0); i < size; i++ {
+			var key, value interface{}
+			key, offset, err = d.decode(offset, depth+1)
+			if err != nil {
+				return nil, 0, err
+			}
+			value, offset, err = d.decode(offset, depth+1)
+			if err != nil {
+				return nil, 0, err
+			}
+			k, ok := key.(string)
+			if !ok {
+				return nil, 0, errCorrupt
+			}
+			m[k] = value
+		}
+		return m, offset, nil
+	case typeArray:
+		a := make([]interface{}, 0, size)
+		for i := uint(0); i < size; i++ {
+			var value interface{}
+			value, offset, err = d.decode(offset, depth+1)
+			if err != nil {
+				return nil, 0, err
+			}
+			a = append(a, value)
+		}
+		return a, offset, nil
+	case typeBool:
+		return size != 0, offset, nil
+	}
+
+	if offset+size > uint(len(d.buf)) {
+		return nil, 0, errCorrupt
+	}
+	b := d.buf[offset : offset+size]
+	next := offset + size
+
+	switch typ {
+	case typeString:
+		return string(b), next, nil
+	case typeBytes, typeUint128:
+		return append([]byte(nil), b...), next, nil
+	case typeDouble:
+		if size != 8 {
+			return nil, 0, errCorrupt
+		}
+		return math.Float64frombits(binary.BigEndian.Uint64(b)), next, nil
+	case typeFloat:
+		if size != 4 {
+			return nil, 0, errCorrupt
+		}
+		return math.Float32frombits(binary.BigEndian.Uint32(b)), next, nil
+	case typeUint16, typeUint32, typeUint64, typeInt32:
+		if size > 8 {
+			return nil, 0, errCorrupt
+		}
+		var v uint64
+		for _, c := range b {
+			v = v<<8 | uint64(c)
+		}
+		if typ == typeInt32 {
+			return int32(v), next, nil
+		}
+		return v, next, nil
+	default:
+		return nil, 0, fmt.Errorf("geoip: unknown data type %d", typ)
+	}
+}
+
+// control decodes the control byte at offset, returning the type, the size,
+// and the offset of the payload
+func (d decoder) control(offset uint) (int, uint, uint, error) {
+	if offset >= uint(len(d.buf)) {
+		return 0, 0, 0, errCorrupt
+	}
+	ctrl := d.buf[offset]
+	offset++
+
+	typ := int(ctrl >> 5)
+	if typ == typeExtended {
+		if offset >= uint(len(d.buf)) {
+			return 0, 0, 0, errCorrupt
+		}
+		typ = 7 + int(d.buf[offset])
+		offset++
+	}
+
+	size := uint(ctrl & 0x1F)
+	if typ == typePointer || size < 29 {
+		return typ, size, offset, nil
+	}
+
+	n := size - 28
+	if offset+n > uint(len(d.buf)) {
+		return 0, 0, 0, errCorrupt
+	}
+	var v uint
+	for _, c := range d.buf[offset : offset+n] {
+		v = v<<8 | uint(c)
+	}
+	switch n {
+	case 1:
+		size = 29 + v
+	case 2:
+		size = 285 + v
+	default:
+		size = 65821 + v
+	}
+	return typ, size, offset + n, nil
+}
+
+// pointer decodes the pointer whose control bits are ctrl, returning its
+// target and the offset following it
+func (d decoder) pointer(ctrl, offset uint) (uint, uint, error) {
+	n := (ctrl>>3)&0x3 + 1
+	if offset+n > uint(len(d.buf)) {
+		return 0, 0, errCorrupt
+	}
+
+	v := uint(0)
+	if n < 4 {
+		v = ctrl & 0x7
+	}
+	for _, c := range d.buf[offset : offset+n] {
+		v = v<<8 | uint(c)
+	}
+	switch n {
+	case 2:
+		v += 2048
+	case 3:
+		v += 526336
+	}
+	return v, offset + n, nil
+}
+
+func asUint(v interface{}) (uint, bool) {
+	n, ok := v.(uint64)
+	return uint(n), ok
+}
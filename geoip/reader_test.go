@@ -0,0 +1,114 @@
+package geoip_test
+
+import (
+	"fmt"
+	"net"
+	"strings"
+
+	"code.cloudfoundry.org/gorouter/geoip"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Reader", func() {
+	city := func(country, region string) map[string]interface{} {
+		return map[string]interface{}{
+			"country": map[string]interface{}{"iso_code": country, "geoname_id": uint32(6252001)},
+			"subdivisions": []interface{}{
+				map[string]interface{}{"iso_code": region, "names": map[string]interface{}{"en": "Somewhere"}},
+			},
+			"location":             map[string]interface{}{"latitude": 37.75, "longitude": -97.82},
+			"is_in_european_union": false,
+		}
+	}
+
+	for _, recordSize := range []int{24, 28, 32} {
+		recordSize := recordSize
+
+		Context(fmt.Sprintf("with an IPv6 database of record size %d", recordSize), func() {
+			var reader *geoip.Reader
+
+			BeforeEach(func() {
+				var err error
+				reader, err = geoip.NewReader(buildDatabase(6, recordSize, nil,
+					network{"81.2.69.0/24", city("GB", "ENG")},
+					network{"2001:db8::/32", city("US", "CA")},
+				))
+				Expect(err).ToNot(HaveOccurred())
+			})
+
+			It("locates IPv4 addresses", func() {
+				loc, err := reader.Lookup(net.ParseIP("81.2.69.160"))
+				Expect(err).ToNot(HaveOccurred())
+				Expect(loc).To(Equal(geoip.Location{Country: "GB", Region: "ENG"}))
+			})
+
+			It("locates IPv6 addresses", func() {
+				loc, err := reader.Lookup(net.ParseIP("2001:db8::1"))
+				Expect(err).ToNot(HaveOccurred())
+				Expect(loc).To(Equal(geoip.Location{Country: "US", Region: "CA"}))
+			})
+
+			It("returns an empty location for unknown addresses", func() {
+				loc, err := reader.Lookup(net.ParseIP("10.0.0.1"))
+				Expect(err).ToNot(HaveOccurred())
+				Expect(loc).To(Equal(geoip.Location{}))
+			})
+		})
+	}
+
+	Context("with an IPv4 country database", func() {
+		var reader *geoip.Reader
+
+		BeforeEach(func() {
+			var err error
+			reader, err = geoip.NewReader(buildDatabase(4, 24,
+				map[string]interface{}{"iso_code": "DE"},
+				network{"192.0.2.0/24", map[string]interface{}{"country": pointer(0)}},
+				network{"198.51.100.0/24", map[string]interface{}{"registered_country": map[string]interface{}{"iso_code": "FR"}}},
+			))
+			Expect(err).ToNot(HaveOccurred())
+			Expect(reader.DatabaseType()).To(Equal("Test-City"))
+		})
+
+		It("follows pointers in the data section", func() {
+			loc, err := reader.Lookup(net.ParseIP("192.0.2.1"))
+			Expect(err).ToNot(HaveOccurred())
+			Expect(loc).To(Equal(geoip.Location{Country: "DE"}))
+		})
+
+		It("falls back to the registered country", func() {
+			loc, err := reader.Lookup(net.ParseIP("198.51.100.7"))
+			Expect(err).ToNot(HaveOccurred())
+			Expect(loc.Country).To(Equal("FR"))
+		})
+
+		It("does not locate IPv6 addresses", func() {
+			loc, err := reader.Lookup(net.ParseIP("2001:db8::1"))
+			Expect(err).ToNot(HaveOccurred())
+			Expect(loc).To(Equal(geoip.Location{}))
+		})
+	})
+
+	It("decodes long strings", func() {
+		region := strings.Repeat("r", 300)
+		reader, err := geoip.NewReader(buildDatabase(4, 24, nil, network{"192.0.2.0/24", city("US", region)}))
+		Expect(err).ToNot(HaveOccurred())
+
+		loc, err := reader.Lookup(net.ParseIP("192.0.2.1"))
+		Expect(err).ToNot(HaveOccurred())
+		Expect(loc.Region).To(Equal(region))
+	})
+
+	It("rejects files without metadata", func() {
+		_, err := geoip.NewReader([]byte("not a database"))
+		Expect(err).To(HaveOccurred())
+	})
+
+	It("rejects truncated files", func() {
+		db := buildDatabase(4, 24, nil, network{"192.0.2.0/24", city("US", "CA")})
+		_, err := geoip.NewReader(db[len(db)-80:])
+		Expect(err).To(HaveOccurred())
+	})
+})
@@ -55,10 +55,16 @@ func (a *accessLog) ServeHTTP(rw http.ResponseWriter, r *http.Request, next http
 		return
 	}
 	alr.RouteEndpoint = reqInfo.RouteEndpoint
+	alr.GeoCountry = reqInfo.ClientLocation.Country
+	alr.GeoRegion = reqInfo.ClientLocation.Region
 	alr.RequestBytesReceived = requestBodyCounter.GetCount()
 	alr.BodyBytesSent = proxyWriter.Size()
 	alr.FinishedAt = time.Now()
 	alr.StatusCode = proxyWriter.Status()
+	alr.ContentRange = proxyWriter.Header().Get("Content-Range")
+	if !LogAccess(reqInfo.DomainProfile, alr.StatusCode) {
+		return
+	}
 	a.accessLogger.Log(*alr)
 }
 
@@ -7,6 +7,7 @@ import (
 	"net/http/httptest"
 
 	"code.cloudfoundry.org/gorouter/access_log/fakes"
+	"code.cloudfoundry.org/gorouter/config"
 	"code.cloudfoundry.org/gorouter/handlers"
 	logger_fakes "code.cloudfoundry.org/gorouter/logger/fakes"
 	"code.cloudfoundry.org/gorouter/proxy/utils"
@@ -103,6 +104,44 @@ var _ = Describe("AccessLog", func() {
 		Expect(alr.RouteEndpoint).To(Equal(testEndpoint))
 	})
 
+	Context("when the domain profile of the request limits the access log", func() {
+		var accessLog string
+
+		JustBeforeEach(func() {
+			fakeLogger := new(logger_fakes.FakeLogger)
+			profiles := []config.DomainProfile{{Domain: "example.com", AccessLog: accessLog}}
+
+			handler = negroni.New()
+			handler.Use(handlers.NewRequestInfo())
+			handler.Use(handlers.NewProxyWriter(fakeLogger))
+			handler.Use(handlers.NewAccessLog(accessLogger, extraHeadersToLog, fakeLogger))
+			handler.Use(handlers.NewDomainProfiles(profiles, fakeLogger))
+			handler.UseHandlerFunc(nextHandler)
+		})
+
+		Context("to errors", func() {
+			BeforeEach(func() {
+				accessLog = config.DOMAIN_ACCESS_LOG_ERRORS
+			})
+
+			It("logs failed requests", func() {
+				handler.ServeHTTP(resp, req)
+				Expect(accessLogger.LogCallCount()).To(Equal(1))
+			})
+		})
+
+		Context("to none", func() {
+			BeforeEach(func() {
+				accessLog = config.DOMAIN_ACCESS_LOG_NONE
+			})
+
+			It("does not log the request", func() {
+				handler.ServeHTTP(resp, req)
+				Expect(accessLogger.LogCallCount()).To(Equal(0))
+			})
+		})
+	})
+
 	Context("when request info is not set on the request context", func() {
 		var fakeLogger *logger_fakes.FakeLogger
 		BeforeEach(func() {
@@ -0,0 +1,332 @@
+package handlers
+
+import (
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"code.cloudfoundry.org/gorouter/config"
+	"code.cloudfoundry.org/gorouter/logger"
+	"code.cloudfoundry.org/gorouter/metrics"
+	"code.cloudfoundry.org/gorouter/route"
+
+	"github.com/uber-go/zap"
+	"github.com/urfave/negroni"
+)
+
+const (
+	// latency samples averaged before the limit is recalculated
+	limiterWindowSamples = 20
+	// windows the long-term latency is averaged over
+	limiterLongWindows = 100
+	// weight of a recalculated limit against the current one
+	limiterSmoothing = 0.2
+	// share of the limit low priority requests may take
+	lowPriorityShare = 0.8
+)
+
+// ConcurrencyLimiter is a gradient concurrency limiter. It compares the
+// average latency of recent requests with a long-term average and shrinks the
+// limit by their ratio once the latency inflates beyond the tolerance. While
+// latency stays within it, the limit grows by the square root of itself, the
+// queue allowed in front of the backends.
+//
+// Requests beyond the limit may wait for a slot in a bounded queue. Freed
+// slots go to waiting high priority requests first. Low priority requests
+// never wait and are limited to 80% of the limit.
+type ConcurrencyLimiter struct {
+	lock   sync.Mutex
+	limits config.ConcurrencyLimits
+
+	queueSize    int
+	queueTimeout time.Duration
+	highQueue    []chan struct{}
+	normalQueue  []chan struct{}
+
+	limit       float64
+	inFlight    int
+	maxInFlight int
+
+	windowSum   time.Duration
+	windowCount int
+	longRTT     float64
+}
+
+// NewConcurrencyLimiter returns a ConcurrencyLimiter starting at the initial
+// limit. Up to queueSize requests wait at most queueTimeout for a slot.
+func NewConcurrencyLimiter(limits config.ConcurrencyLimits, queueSize int, queueTimeout time.Duration) *ConcurrencyLimiter {
+	return &ConcurrencyLimiter{
+		limits:       limits,
+		limit:        float64(limits.InitialLimit),
+		queueSize:    queueSize,
+		queueTimeout: queueTimeout,
+	}
+}
+
+// Acquire takes a slot for a request of the given priority, waiting in the
+// queue if there is room. It returns false when no slot could be taken.
+func (l *ConcurrencyLimiter) Acquire(priority Priority) bool {
+	l.lock.Lock()
+
+	limit := l.limit
+	if priority == PriorityLow {
+		limit *= lowPriorityShare
+	}
+	if l.inFlight < int(limit) {
+		l.take()
+		l.lock.Unlock()
+		return true
+	}
+
+	if priority == PriorityLow || l.queued() >= l.queueSize {
+		l.lock.Unlock()
+		return false
+	}
+
+	queue := &l.normalQueue
+	if priority == PriorityHigh {
+		queue = &l.highQueue
+	}
+	granted := make(chan struct{}, 1)
+	*queue = append(*queue, granted)
+	l.lock.Unlock()
+
+	timer := time.NewTimer(l.queueTimeout)
+	defer timer.Stop()
+	select {
+	case <-granted:
+		return true
+	case <-timer.C:
+	}
+
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	for i, waiter := range *queue {
+		if waiter == granted {
+			*queue = append((*queue)[:i], (*queue)[i+1:]...)
+			return false
+		}
+	}
+	// the slot was granted as the timer fired
+	return true
+}
+
+// Queued returns the number of requests waiting for a slot
+func (l *ConcurrencyLimiter) Queued() int {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	return l.queued()
+}
+
+func (l *ConcurrencyLimiter) queued() int {
+	return len(l.highQueue) + len(l.normalQueue)
+}
+
+func (l *ConcurrencyLimiter) take() {
+	l.inFlight++
+	if l.inFlight > l.maxInFlight {
+		l.maxInFlight = l.inFlight
+	}
+}
+
+// grant hands free slots to waiting requests, high priority ones first
+func (l *ConcurrencyLimiter) grant() {
+	for l.inFlight < int(l.limit) {
+		var waiter chan struct{}
+		switch {
+		case len(l.highQueue) > 0:
+			waiter, l.highQueue = l.highQueue[0], l.highQueue[1:]
+		case len(l.normalQueue) > 0:
+			waiter, l.normalQueue = l.normalQueue[0], l.normalQueue[1:]
+		default:
+			return
+		}
+		l.take()
+		waiter <- struct{}{}
+	}
+}
+
+// Release frees the slot of a request that took latency to be answered by
+// its backend. A latency of 0, for requests that never reached a backend, is
+// not sampled.
+func (l *ConcurrencyLimiter) Release(latency time.Duration) {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	l.inFlight--
+	defer l.grant()
+	if latency <= 0 {
+		return
+	}
+
+	l.windowSum += latency
+	l.windowCount++
+	if l.windowCount < limiterWindowSamples {
+		return
+	}
+
+	l.update(float64(l.windowSum) / float64(l.windowCount))
+	l.windowSum = 0
+	l.windowCount = 0
+	l.maxInFlight = l.inFlight
+}
+
+// idle reports whether no request holds or waits for a slot
+func (l *ConcurrencyLimiter) idle() bool {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	return l.inFlight == 0 && l.queued() == 0
+}
+
+// Limit returns the current limit
+func (l *ConcurrencyLimiter) Limit() int {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	return int(l.limit)
+}
+
+func (l *ConcurrencyLimiter) update(shortRTT float64) {
+	if l.longRTT == 0 {
+		l.longRTT = shortRTT
+	} else {
+		l.longRTT += (shortRTT - l.longRTT) * 2 / (limiterLongWindows + 1)
+	}
+
+	// after a sustained drop in latency, let the long-term average catch up
+	// instead of growing the limit for many windows
+	if l.longRTT/shortRTT > 2 {
+		l.longRTT *= 0.95
+	}
+
+	// do not grow a limit the traffic never got near
+	if float64(l.maxInFlight) < l.limit/2 {
+		return
+	}
+
+	gradient := math.Max(0.5, math.Min(1, l.limits.Tolerance*l.longRTT/shortRTT))
+	newLimit := l.limit*gradient + math.Sqrt(l.limit)
+	newLimit = l.limit*(1-limiterSmoothing) + newLimit*limiterSmoothing
+
+	l.limit = math.Max(float64(l.limits.MinLimit), math.Min(float64(l.limits.MaxLimit), newLimit))
+}
+
+type adaptiveConcurrency struct {
+	lock         sync.Mutex
+	limiters     map[string]*ConcurrencyLimiter
+	dropped      time.Time
+	defaults     config.ConcurrencyLimits
+	routes       map[string]config.ConcurrencyLimits
+	maxRoutes    int
+	queueSize    int
+	queueTimeout time.Duration
+
+	retryAfter string
+	reporter   metrics.ConcurrencyLimitReporter
+	logger     logger.Logger
+}
+
+// NewAdaptiveConcurrency creates a handler that keeps a ConcurrencyLimiter for
+// every route and rejects requests beyond its limit, and the queue, with a 503.
+// Once the configured maximum of routes is limited, the limiters of idle routes
+// are dropped, at most once a second, and routes beyond are not limited. It
+// must follow the lookup handler.
+func NewAdaptiveConcurrency(cfg config.AdaptiveConcurrencyConfig, reporter metrics.ConcurrencyLimitReporter, logger logger.Logger) negroni.Handler {
+	routes := make(map[string]config.ConcurrencyLimits, len(cfg.Routes))
+	for host, limits := range cfg.Routes {
+		routes[strings.ToLower(host)] = limits
+	}
+
+	return &adaptiveConcurrency{
+		limiters:     make(map[string]*ConcurrencyLimiter),
+		defaults:     cfg.Limits,
+		routes:       routes,
+		maxRoutes:    cfg.MaxRoutes,
+		queueSize:    cfg.QueueSize,
+		queueTimeout: cfg.QueueTimeout,
+		retryAfter:   strconv.Itoa(int(cfg.RetryAfter.Seconds())),
+		reporter:     reporter,
+		logger:       logger,
+	}
+}
+
+func (a *adaptiveConcurrency) ServeHTTP(rw http.ResponseWriter, r *http.Request, next http.HandlerFunc) {
+	requestInfo, err := ContextRequestInfo(r)
+	if err != nil {
+		a.logger.Fatal("request-info-err", zap.Error(err))
+		return
+	}
+	if requestInfo.RoutePool == nil {
+		next(rw, r)
+		return
+	}
+
+	limiter := a.limiter(r, requestInfo.RoutePool)
+	if limiter == nil {
+		next(rw, r)
+		return
+	}
+
+	if !limiter.Acquire(requestInfo.Priority) {
+		a.reporter.CaptureConcurrencyLimited()
+		writeOverloaded(rw, a.retryAfter, "concurrency_limit", "Route is overloaded.")
+		return
+	}
+
+	startedAt := time.Now()
+	defer func() {
+		var latency time.Duration
+		if requestInfo.StoppedAt.After(startedAt) {
+			latency = requestInfo.StoppedAt.Sub(startedAt)
+		}
+		limiter.Release(latency)
+	}()
+
+	next(rw, r)
+}
+
+func (a *adaptiveConcurrency) limiter(r *http.Request, pool *route.Pool) *ConcurrencyLimiter {
+	name := routeName(r, pool)
+
+	a.lock.Lock()
+	defer a.lock.Unlock()
+
+	limiter, ok := a.limiters[name]
+	if ok {
+		return limiter
+	}
+	if len(a.limiters) >= a.maxRoutes {
+		a.dropIdle()
+	}
+	if len(a.limiters) >= a.maxRoutes {
+		return nil
+	}
+
+	limits, ok := a.routes[strings.ToLower(hostWithoutPort(r.Host))]
+	if !ok {
+		limits = a.defaults
+	}
+	limiter = NewConcurrencyLimiter(limits, a.queueSize, a.queueTimeout)
+	a.limiters[name] = limiter
+	return limiter
+}
+
+// dropIdle must be called with the lock held
+func (a *adaptiveConcurrency) dropIdle() {
+	now := time.Now()
+	if now.Sub(a.dropped) < time.Second {
+		return
+	}
+	a.dropped = now
+
+	for name, limiter := range a.limiters {
+		if limiter.idle() {
+			delete(a.limiters, name)
+		}
+	}
+}
@@ -0,0 +1,319 @@
+package handlers_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"time"
+
+	"code.cloudfoundry.org/gorouter/config"
+	"code.cloudfoundry.org/gorouter/handlers"
+	logger_fakes "code.cloudfoundry.org/gorouter/logger/fakes"
+	metrics_fakes "code.cloudfoundry.org/gorouter/metrics/fakes"
+	"code.cloudfoundry.org/gorouter/route"
+	"code.cloudfoundry.org/gorouter/test_util"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/urfave/negroni"
+)
+
+var _ = Describe("ConcurrencyLimiter", func() {
+	var (
+		limiter   *handlers.ConcurrencyLimiter
+		limits    config.ConcurrencyLimits
+		queueSize int
+	)
+
+	// burst fills the limiter and then answers every request after latency
+	burst := func(latency time.Duration) {
+		acquired := 0
+		for limiter.Acquire(handlers.PriorityNormal) {
+			acquired++
+		}
+		for i := 0; i < acquired; i++ {
+			limiter.Release(latency)
+		}
+	}
+
+	BeforeEach(func() {
+		limits = config.ConcurrencyLimits{InitialLimit: 100, MinLimit: 10, MaxLimit: 200, Tolerance: 1.5}
+		queueSize = 0
+	})
+
+	JustBeforeEach(func() {
+		limiter = handlers.NewConcurrencyLimiter(limits, queueSize, 100*time.Millisecond)
+	})
+
+	It("rejects requests beyond the limit", func() {
+		for i := 0; i < 100; i++ {
+			Expect(limiter.Acquire(handlers.PriorityNormal)).To(BeTrue())
+		}
+		Expect(limiter.Acquire(handlers.PriorityNormal)).To(BeFalse())
+
+		limiter.Release(0)
+		Expect(limiter.Acquire(handlers.PriorityNormal)).To(BeTrue())
+	})
+
+	It("limits low priority requests to 80% of the limit", func() {
+		for i := 0; i < 80; i++ {
+			Expect(limiter.Acquire(handlers.PriorityLow)).To(BeTrue())
+		}
+		Expect(limiter.Acquire(handlers.PriorityLow)).To(BeFalse())
+		Expect(limiter.Acquire(handlers.PriorityNormal)).To(BeTrue())
+	})
+
+	It("grows the limit while latency is steady", func() {
+		for i := 0; i < 5; i++ {
+			burst(10 * time.Millisecond)
+		}
+		Expect(limiter.Limit()).To(BeNumerically(">", 100))
+	})
+
+	It("does not grow the limit past the maximum", func() {
+		for i := 0; i < 50; i++ {
+			burst(10 * time.Millisecond)
+		}
+		Expect(limiter.Limit()).To(Equal(200))
+	})
+
+	It("shrinks the limit when latency inflates", func() {
+		burst(10 * time.Millisecond)
+		steady := limiter.Limit()
+
+		for i := 0; i < 3; i++ {
+			burst(100 * time.Millisecond)
+		}
+		Expect(limiter.Limit()).To(BeNumerically("<", steady))
+	})
+
+	It("does not grow the limit when traffic stays well below it", func() {
+		for i := 0; i < 100; i++ {
+			Expect(limiter.Acquire(handlers.PriorityNormal)).To(BeTrue())
+			limiter.Release(10 * time.Millisecond)
+		}
+		Expect(limiter.Limit()).To(Equal(100))
+	})
+
+	Context("when requests may queue", func() {
+		BeforeEach(func() {
+			limits.InitialLimit = 1
+			limits.MinLimit = 1
+			queueSize = 2
+		})
+
+		JustBeforeEach(func() {
+			Expect(limiter.Acquire(handlers.PriorityNormal)).To(BeTrue())
+		})
+
+		// acquire queues a request and reports the order slots were granted in
+		acquire := func(priority handlers.Priority, granted chan<- handlers.Priority) {
+			go func() {
+				defer GinkgoRecover()
+				if limiter.Acquire(priority) {
+					granted <- priority
+				}
+			}()
+		}
+
+		It("hands freed slots to waiting requests", func() {
+			granted := make(chan handlers.Priority, 1)
+			acquire(handlers.PriorityNormal, granted)
+			Eventually(limiter.Queued).Should(Equal(1))
+
+			limiter.Release(0)
+			Eventually(granted).Should(Receive(Equal(handlers.PriorityNormal)))
+			Expect(limiter.Queued()).To(Equal(0))
+		})
+
+		It("serves high priority requests first", func() {
+			granted := make(chan handlers.Priority, 2)
+			acquire(handlers.PriorityNormal, granted)
+			Eventually(limiter.Queued).Should(Equal(1))
+			acquire(handlers.PriorityHigh, granted)
+			Eventually(limiter.Queued).Should(Equal(2))
+
+			limiter.Release(0)
+			Eventually(granted).Should(Receive(Equal(handlers.PriorityHigh)))
+			Consistently(granted, 50*time.Millisecond).ShouldNot(Receive())
+		})
+
+		It("rejects requests once the queue is full", func() {
+			acquire(handlers.PriorityNormal, nil)
+			acquire(handlers.PriorityNormal, nil)
+			Eventually(limiter.Queued).Should(Equal(2))
+
+			Expect(limiter.Acquire(handlers.PriorityHigh)).To(BeFalse())
+		})
+
+		It("does not queue low priority requests", func() {
+			Expect(limiter.Acquire(handlers.PriorityLow)).To(BeFalse())
+			Expect(limiter.Queued()).To(Equal(0))
+		})
+
+		It("gives up after the queue timeout", func() {
+			Expect(limiter.Acquire(handlers.PriorityNormal)).To(BeFalse())
+			Expect(limiter.Queued()).To(Equal(0))
+		})
+	})
+
+	Context("when latency stays inflated", func() {
+		BeforeEach(func() {
+			limits.MinLimit = 60
+		})
+
+		It("does not shrink the limit below the minimum", func() {
+			burst(10 * time.Millisecond)
+			for i := 0; i < 20; i++ {
+				burst(time.Second)
+			}
+			Expect(limiter.Limit()).To(BeNumerically(">=", 60))
+		})
+	})
+})
+
+var _ = Describe("AdaptiveConcurrency", func() {
+	var (
+		handler      *negroni.Negroni
+		cfg          config.AdaptiveConcurrencyConfig
+		fakeReporter *metrics_fakes.FakeConcurrencyLimitReporter
+		entered      chan struct{}
+		release      chan struct{}
+	)
+
+	serve := func(host string) *httptest.ResponseRecorder {
+		resp := httptest.NewRecorder()
+		handler.ServeHTTP(resp, test_util.NewRequest("GET", host, "/", nil))
+		return resp
+	}
+
+	BeforeEach(func() {
+		cfg = config.AdaptiveConcurrencyConfig{
+			Limits:     config.ConcurrencyLimits{InitialLimit: 1, MinLimit: 1, MaxLimit: 10, Tolerance: 1.5},
+			MaxRoutes:  10,
+			RetryAfter: 2 * time.Second,
+		}
+		fakeReporter = new(metrics_fakes.FakeConcurrencyLimitReporter)
+		entered = make(chan struct{}, 1)
+		release = make(chan struct{})
+	})
+
+	JustBeforeEach(func() {
+		handler = negroni.New()
+		handler.Use(handlers.NewRequestInfo())
+		handler.UseFunc(func(rw http.ResponseWriter, req *http.Request, next http.HandlerFunc) {
+			if req.Host != "unrouted.com" {
+				reqInfo, err := handlers.ContextRequestInfo(req)
+				Expect(err).NotTo(HaveOccurred())
+				reqInfo.RoutePool = route.NewPool(0, "")
+			}
+			next(rw, req)
+		})
+		handler.Use(handlers.NewAdaptiveConcurrency(cfg, fakeReporter, new(logger_fakes.FakeLogger)))
+		handler.UseHandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+			if req.Host == "slow.com" || req.Host == "unrouted.com" {
+				entered <- struct{}{}
+				<-release
+			}
+		})
+	})
+
+	Context("when a route is at its limit", func() {
+		var done chan struct{}
+
+		JustBeforeEach(func() {
+			done = make(chan struct{})
+			go func() {
+				defer GinkgoRecover()
+				defer close(done)
+				Expect(serve("slow.com").Code).To(Equal(http.StatusOK))
+			}()
+			Eventually(entered).Should(Receive())
+		})
+
+		AfterEach(func() {
+			close(release)
+			Eventually(done).Should(BeClosed())
+		})
+
+		It("rejects further requests to the route", func() {
+			resp := serve("slow.com")
+			Expect(resp.Code).To(Equal(http.StatusServiceUnavailable))
+			Expect(resp.Header().Get("Retry-After")).To(Equal("2"))
+			Expect(resp.Header().Get("X-Cf-RouterError")).To(Equal("concurrency_limit"))
+			Expect(fakeReporter.CaptureConcurrencyLimitedCallCount()).To(Equal(1))
+		})
+
+		It("serves other routes", func() {
+			Expect(serve("fast.com").Code).To(Equal(http.StatusOK))
+		})
+
+		Context("when the route has its own limits", func() {
+			BeforeEach(func() {
+				cfg.Routes = map[string]config.ConcurrencyLimits{
+					"Slow.com": {InitialLimit: 2, MinLimit: 1, MaxLimit: 10, Tolerance: 1.5},
+				}
+			})
+
+			It("limits the route by them", func() {
+				go func() {
+					defer GinkgoRecover()
+					Expect(serve("slow.com").Code).To(Equal(http.StatusOK))
+				}()
+				Eventually(entered).Should(Receive())
+				Expect(fakeReporter.CaptureConcurrencyLimitedCallCount()).To(Equal(0))
+			})
+		})
+
+		Context("when the maximum of routes is limited", func() {
+			BeforeEach(func() {
+				cfg.MaxRoutes = 1
+			})
+
+			It("does not limit further routes while the limited one is busy", func() {
+				Expect(serve("fast.com").Code).To(Equal(http.StatusOK))
+				Expect(fakeReporter.CaptureConcurrencyLimitedCallCount()).To(Equal(0))
+			})
+		})
+	})
+
+	Context("when the maximum of routes is limited by idle routes", func() {
+		BeforeEach(func() {
+			cfg.MaxRoutes = 1
+		})
+
+		It("drops their limiters to limit new routes", func() {
+			Expect(serve("fast.com").Code).To(Equal(http.StatusOK))
+
+			done := make(chan struct{})
+			go func() {
+				defer GinkgoRecover()
+				defer close(done)
+				Expect(serve("slow.com").Code).To(Equal(http.StatusOK))
+			}()
+			Eventually(entered).Should(Receive())
+
+			Expect(serve("slow.com").Code).To(Equal(http.StatusServiceUnavailable))
+
+			close(release)
+			Eventually(done).Should(BeClosed())
+		})
+	})
+
+	Context("when a request has no route", func() {
+		It("does not limit it", func() {
+			done := make(chan struct{}, 2)
+			for i := 0; i < 2; i++ {
+				go func() {
+					defer GinkgoRecover()
+					Expect(serve("unrouted.com").Code).To(Equal(http.StatusOK))
+					done <- struct{}{}
+				}()
+				Eventually(entered).Should(Receive())
+			}
+
+			close(release)
+			Eventually(done).Should(HaveLen(2))
+			Expect(fakeReporter.CaptureConcurrencyLimitedCallCount()).To(Equal(0))
+		})
+	})
+})
@@ -0,0 +1,42 @@
+package handlers
+
+import (
+	"net/http"
+
+	"code.cloudfoundry.org/gorouter/logger"
+	"code.cloudfoundry.org/gorouter/metrics"
+	"code.cloudfoundry.org/gorouter/proxy/utils"
+
+	"github.com/uber-go/zap"
+	"github.com/urfave/negroni"
+)
+
+type anomalyDetection struct {
+	detector *metrics.AnomalyDetector
+	logger   logger.Logger
+}
+
+// NewAnomalyDetection creates a handler that feeds the status of every routed
+// response to detector, keyed by the route it was routed by
+func NewAnomalyDetection(detector *metrics.AnomalyDetector, logger logger.Logger) negroni.Handler {
+	return &anomalyDetection{
+		detector: detector,
+		logger:   logger,
+	}
+}
+
+func (a *anomalyDetection) ServeHTTP(rw http.ResponseWriter, r *http.Request, next http.HandlerFunc) {
+	next(rw, r)
+
+	requestInfo, err := ContextRequestInfo(r)
+	if err != nil {
+		a.logger.Error("request-info-err", zap.Error(err))
+		return
+	}
+	if requestInfo.RoutePool == nil {
+		return
+	}
+
+	proxyWriter := rw.(utils.ProxyResponseWriter)
+	a.detector.CaptureResponse(routeName(r, requestInfo.RoutePool), proxyWriter.Status())
+}
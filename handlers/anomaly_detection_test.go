@@ -0,0 +1,77 @@
+package handlers_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"time"
+
+	"code.cloudfoundry.org/gorouter/handlers"
+	logger_fakes "code.cloudfoundry.org/gorouter/logger/fakes"
+	"code.cloudfoundry.org/gorouter/metrics"
+	"code.cloudfoundry.org/gorouter/route"
+	"code.cloudfoundry.org/gorouter/test_util"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/urfave/negroni"
+)
+
+var _ = Describe("AnomalyDetection", func() {
+	var (
+		handler   *negroni.Negroni
+		detector  *metrics.AnomalyDetector
+		pool      *route.Pool
+		anomalies []metrics.Anomaly
+	)
+
+	BeforeEach(func() {
+		anomalies = nil
+		detector = metrics.NewAnomalyDetector(time.Minute, 1, 3, 1, 10, func(a metrics.Anomaly) {
+			anomalies = append(anomalies, a)
+		})
+		pool = route.NewPool(0, "/api")
+
+		fakeLogger := new(logger_fakes.FakeLogger)
+		handler = negroni.New()
+		handler.Use(handlers.NewRequestInfo())
+		handler.Use(handlers.NewProxyWriter(fakeLogger))
+		handler.Use(handlers.NewAnomalyDetection(detector, fakeLogger))
+		handler.UseHandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+			reqInfo, err := handlers.ContextRequestInfo(req)
+			Expect(err).NotTo(HaveOccurred())
+			reqInfo.RoutePool = pool
+			if req.Header.Get("X-Fail") != "" {
+				rw.WriteHeader(http.StatusBadGateway)
+			}
+		})
+	})
+
+	serve := func(fail bool) {
+		req := test_util.NewRequest("GET", "App.Example.com:8080", "/api/foo", nil)
+		if fail {
+			req.Header.Set("X-Fail", "true")
+		}
+		handler.ServeHTTP(httptest.NewRecorder(), req)
+	}
+
+	It("captures responses by host and context path", func() {
+		serve(false)
+		detector.Evaluate()
+
+		serve(true)
+		detector.Evaluate()
+
+		Expect(anomalies).To(HaveLen(1))
+		Expect(anomalies[0].Route).To(Equal("app.example.com/api"))
+	})
+
+	It("ignores requests that were not routed", func() {
+		pool = nil
+		serve(true)
+		detector.Evaluate()
+		serve(true)
+		detector.Evaluate()
+
+		Expect(anomalies).To(BeEmpty())
+	})
+})
@@ -0,0 +1,43 @@
+package handlers
+
+import (
+	"net/http"
+
+	"code.cloudfoundry.org/gorouter/logger"
+	"code.cloudfoundry.org/gorouter/metrics"
+	"code.cloudfoundry.org/gorouter/proxy/utils"
+
+	"github.com/uber-go/zap"
+	"github.com/urfave/negroni"
+)
+
+type authFailures struct {
+	aggregator *metrics.AuthFailureAggregator
+	logger     logger.Logger
+}
+
+// NewAuthFailures creates a handler that counts the 401 and 403 responses of
+// backends in aggregator, keyed by the route they were routed by. Rejections
+// by the router itself are not counted.
+func NewAuthFailures(aggregator *metrics.AuthFailureAggregator, logger logger.Logger) negroni.Handler {
+	return &authFailures{
+		aggregator: aggregator,
+		logger:     logger,
+	}
+}
+
+func (a *authFailures) ServeHTTP(rw http.ResponseWriter, r *http.Request, next http.HandlerFunc) {
+	next(rw, r)
+
+	requestInfo, err := ContextRequestInfo(r)
+	if err != nil {
+		a.logger.Error("request-info-err", zap.Error(err))
+		return
+	}
+	if requestInfo.RoutePool == nil || requestInfo.RouteEndpoint == nil {
+		return
+	}
+
+	proxyWriter := rw.(utils.ProxyResponseWriter)
+	a.aggregator.CaptureResponse(routeName(r, requestInfo.RoutePool), proxyWriter.Status())
+}
@@ -0,0 +1,66 @@
+package handlers_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"time"
+
+	"code.cloudfoundry.org/gorouter/handlers"
+	logger_fakes "code.cloudfoundry.org/gorouter/logger/fakes"
+	"code.cloudfoundry.org/gorouter/metrics"
+	"code.cloudfoundry.org/gorouter/route"
+	"code.cloudfoundry.org/gorouter/test_util"
+	"code.cloudfoundry.org/routing-api/models"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/urfave/negroni"
+)
+
+var _ = Describe("AuthFailures", func() {
+	var (
+		handler    *negroni.Negroni
+		aggregator *metrics.AuthFailureAggregator
+		pool       *route.Pool
+		endpoint   *route.Endpoint
+	)
+
+	BeforeEach(func() {
+		aggregator = metrics.NewAuthFailureAggregator(time.Minute, 0, 10, nil)
+		pool = route.NewPool(0, "/api")
+		endpoint = route.NewEndpoint("app", "1.2.3.4", 5678, "", "", nil, -1, "", models.ModificationTag{}, "")
+
+		fakeLogger := new(logger_fakes.FakeLogger)
+		handler = negroni.New()
+		handler.Use(handlers.NewRequestInfo())
+		handler.Use(handlers.NewProxyWriter(fakeLogger))
+		handler.Use(handlers.NewAuthFailures(aggregator, fakeLogger))
+		handler.UseHandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+			reqInfo, err := handlers.ContextRequestInfo(req)
+			Expect(err).NotTo(HaveOccurred())
+			reqInfo.RoutePool = pool
+			reqInfo.RouteEndpoint = endpoint
+			rw.WriteHeader(http.StatusUnauthorized)
+		})
+	})
+
+	serve := func() {
+		req := test_util.NewRequest("GET", "App.Example.com:8080", "/api/login", nil)
+		handler.ServeHTTP(httptest.NewRecorder(), req)
+	}
+
+	It("counts backend auth failures by host and context path", func() {
+		serve()
+
+		Expect(aggregator.Snapshot()).To(Equal(map[string]metrics.AuthFailureStats{
+			"app.example.com/api": {Unauthorized: 1},
+		}))
+	})
+
+	It("ignores responses that did not come from a backend", func() {
+		endpoint = nil
+		serve()
+
+		Expect(aggregator.Snapshot()).To(BeEmpty())
+	})
+})
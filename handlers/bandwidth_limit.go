@@ -0,0 +1,240 @@
+package handlers
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/uber-go/zap"
+	"github.com/urfave/negroni"
+
+	"code.cloudfoundry.org/gorouter/config"
+	"code.cloudfoundry.org/gorouter/logger"
+	"code.cloudfoundry.org/gorouter/proxy/utils"
+)
+
+// otherClients is the bucket shared by the clients seen once the limit of
+// tracked clients is reached
+const otherClients = "_other"
+
+type bandwidthLimit struct {
+	defaults config.BandwidthLimit
+	routes   *tokenBuckets
+	clients  *tokenBuckets
+	logger   logger.Logger
+}
+
+// NewBandwidthLimit creates a handler pacing the response bodies of every
+// route to its bandwidth limit, registered with the route or defaults. It
+// must follow the lookup handler.
+func NewBandwidthLimit(c config.BandwidthLimitConfig, logger logger.Logger) negroni.Handler {
+	return &bandwidthLimit{
+		defaults: c.Default,
+		routes:   newTokenBuckets(0),
+		clients:  newTokenBuckets(c.MaxClients),
+		logger:   logger,
+	}
+}
+
+func (b *bandwidthLimit) ServeHTTP(rw http.ResponseWriter, r *http.Request, next http.HandlerFunc) {
+	requestInfo, err := ContextRequestInfo(r)
+	if err != nil {
+		b.logger.Fatal("request-info-err", zap.Error(err))
+		return
+	}
+
+	limit := b.defaults
+	if registered := requestInfo.RoutePool.BandwidthLimit(); registered != nil {
+		limit = *registered
+	}
+	if limit.PerRoute == 0 && limit.PerClient == 0 {
+		next(rw, r)
+		return
+	}
+
+	burst := limit.Burst
+	if burst == 0 {
+		burst = limit.PerRoute
+		if limit.PerClient != 0 && (burst == 0 || limit.PerClient < burst) {
+			burst = limit.PerClient
+		}
+	}
+
+	route := routeName(r, requestInfo.RoutePool)
+	proxyWriter := &bandwidthResponseWriter{
+		ProxyResponseWriter: rw.(utils.ProxyResponseWriter),
+		chunk:               int(burst),
+		ctx:                 r.Context(),
+	}
+	if limit.PerRoute != 0 {
+		proxyWriter.buckets = append(proxyWriter.buckets, b.routes.get(route, limit.PerRoute, burst))
+	}
+	if limit.PerClient != 0 {
+		client, _, err := net.SplitHostPort(r.RemoteAddr)
+		if err != nil {
+			client = r.RemoteAddr
+		}
+		proxyWriter.buckets = append(proxyWriter.buckets, b.clients.get(route+" "+client, limit.PerClient, burst))
+	}
+
+	requestInfo.ProxyResponseWriter = proxyWriter
+	next(proxyWriter, r)
+}
+
+// bandwidthResponseWriter writes bodies in chunks no larger than a burst,
+// each once every bucket can afford it
+type bandwidthResponseWriter struct {
+	utils.ProxyResponseWriter
+	buckets []*tokenBucket
+	chunk   int
+	ctx     context.Context
+}
+
+func (w *bandwidthResponseWriter) Write(b []byte) (int, error) {
+	written := 0
+	for written < len(b) {
+		n := len(b) - written
+		if n > w.chunk {
+			n = w.chunk
+		}
+
+		var wait time.Duration
+		now := time.Now()
+		for _, bucket := range w.buckets {
+			if d := bucket.reserve(n, now); d > wait {
+				wait = d
+			}
+		}
+		if wait > 0 {
+			t := time.NewTimer(wait)
+			select {
+			case <-t.C:
+			case <-w.ctx.Done():
+				t.Stop()
+				return written, w.ctx.Err()
+			}
+		}
+
+		nw, err := w.ProxyResponseWriter.Write(b[written : written+n])
+		written += nw
+		if err != nil {
+			return written, err
+		}
+	}
+	return written, nil
+}
+
+// tokenBucket holds up to burst tokens, a byte each, refilled at rate per
+// second. Reservations may take it into debt, which later reservations wait
+// out, so concurrent writers share the rate in turn.
+type tokenBucket struct {
+	lock   sync.Mutex
+	rate   float64
+	burst  float64
+	tokens float64
+	last   time.Time
+}
+
+// reserve takes n tokens and returns how long to wait before they are
+// available
+func (b *tokenBucket) reserve(n int, now time.Time) time.Duration {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	b.refill(now)
+	b.tokens -= float64(n)
+	if b.tokens >= 0 {
+		return 0
+	}
+	return time.Duration(-b.tokens / b.rate * float64(time.Second))
+}
+
+// full reports whether the bucket refilled completely, when dropping it
+// loses nothing
+func (b *tokenBucket) full(now time.Time) bool {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	b.refill(now)
+	return b.tokens >= b.burst
+}
+
+func (b *tokenBucket) setLimit(rate, burst int64) {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	b.rate = float64(rate)
+	b.burst = float64(burst)
+	if b.tokens > b.burst {
+		b.tokens = b.burst
+	}
+}
+
+// refill must be called with the lock held
+func (b *tokenBucket) refill(now time.Time) {
+	if now.After(b.last) {
+		b.tokens += now.Sub(b.last).Seconds() * b.rate
+		if b.tokens > b.burst {
+			b.tokens = b.burst
+		}
+		b.last = now
+	}
+}
+
+// tokenBuckets keeps a bucket per key. Once max buckets are kept, the full
+// ones are dropped, at most once a second, and keys beyond share a bucket. A
+// zero max is unlimited.
+type tokenBuckets struct {
+	max int
+
+	lock    sync.Mutex
+	buckets map[string]*tokenBucket
+	dropped time.Time
+}
+
+func newTokenBuckets(max int) *tokenBuckets {
+	return &tokenBuckets{
+		max:     max,
+		buckets: make(map[string]*tokenBucket),
+	}
+}
+
+func (t *tokenBuckets) get(key string, rate, burst int64) *tokenBucket {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	b, ok := t.buckets[key]
+	if !ok {
+		if t.max > 0 && len(t.buckets) >= t.max {
+			t.dropFull()
+		}
+		if t.max > 0 && len(t.buckets) >= t.max {
+			key = otherClients
+			b, ok = t.buckets[key]
+		}
+	}
+	if !ok {
+		b = &tokenBucket{tokens: float64(burst), last: time.Now()}
+		t.buckets[key] = b
+	}
+
+	b.setLimit(rate, burst)
+	return b
+}
+
+// dropFull must be called with the lock held
+func (t *tokenBuckets) dropFull() {
+	now := time.Now()
+	if now.Sub(t.dropped) < time.Second {
+		return
+	}
+	t.dropped = now
+
+	for key, b := range t.buckets {
+		if b.full(now) {
+			delete(t.buckets, key)
+		}
+	}
+}
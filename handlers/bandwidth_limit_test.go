@@ -0,0 +1,101 @@
+package handlers_test
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"time"
+
+	"code.cloudfoundry.org/gorouter/config"
+	"code.cloudfoundry.org/gorouter/handlers"
+	logger_fakes "code.cloudfoundry.org/gorouter/logger/fakes"
+	"code.cloudfoundry.org/gorouter/route"
+	"code.cloudfoundry.org/routing-api/models"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/urfave/negroni"
+)
+
+var _ = Describe("BandwidthLimit", func() {
+	var (
+		handler *negroni.Negroni
+		pool    *route.Pool
+		c       config.BandwidthLimitConfig
+	)
+
+	body := bytes.Repeat([]byte("x"), 300)
+
+	JustBeforeEach(func() {
+		fakeLogger := new(logger_fakes.FakeLogger)
+		handler = negroni.New()
+		handler.Use(handlers.NewRequestInfo())
+		handler.Use(handlers.NewProxyWriter(fakeLogger))
+		handler.UseFunc(func(rw http.ResponseWriter, req *http.Request, next http.HandlerFunc) {
+			reqInfo, err := handlers.ContextRequestInfo(req)
+			Expect(err).NotTo(HaveOccurred())
+			reqInfo.RoutePool = pool
+			next(rw, req)
+		})
+		handler.Use(handlers.NewBandwidthLimit(c, fakeLogger))
+		handler.UseHandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+			rw.Write(body)
+		})
+	})
+
+	BeforeEach(func() {
+		pool = route.NewPool(0, "")
+		c = config.BandwidthLimitConfig{Enabled: true, MaxClients: 10}
+	})
+
+	register := func(limit *config.BandwidthLimit) {
+		endpoint := route.NewEndpoint("app", "1.2.3.4", 5678, "", "", nil, -1, "", models.ModificationTag{}, "")
+		endpoint.BandwidthLimit = limit
+		pool.Put(endpoint)
+	}
+
+	download := func(client string) time.Duration {
+		req := httptest.NewRequest("GET", "http://download.example.com/file", nil)
+		req.RemoteAddr = client + ":5555"
+		resp := httptest.NewRecorder()
+
+		start := time.Now()
+		handler.ServeHTTP(resp, req)
+		Expect(resp.Body.Bytes()).To(Equal(body))
+		return time.Since(start)
+	}
+
+	It("does not limit routes without a limit", func() {
+		register(nil)
+		Expect(download("10.0.0.1")).To(BeNumerically("<", 50*time.Millisecond))
+	})
+
+	Context("when the route registers a limit", func() {
+		BeforeEach(func() {
+			c.Default = config.BandwidthLimit{PerRoute: 1000000}
+			register(&config.BandwidthLimit{PerRoute: 1000, Burst: 100})
+		})
+
+		It("paces the response beyond the burst", func() {
+			Expect(download("10.0.0.1")).To(BeNumerically(">=", 150*time.Millisecond))
+		})
+
+		It("shares the limit between clients", func() {
+			download("10.0.0.1")
+			Expect(download("10.0.0.2")).To(BeNumerically(">=", 250*time.Millisecond))
+		})
+	})
+
+	Context("when the default limits clients", func() {
+		BeforeEach(func() {
+			c.Default = config.BandwidthLimit{PerClient: 1000, Burst: 300}
+			register(nil)
+		})
+
+		It("limits every client separately", func() {
+			Expect(download("10.0.0.1")).To(BeNumerically("<", 50*time.Millisecond))
+			Expect(download("10.0.0.2")).To(BeNumerically("<", 50*time.Millisecond))
+			Expect(download("10.0.0.1")).To(BeNumerically(">=", 250*time.Millisecond))
+		})
+	})
+})
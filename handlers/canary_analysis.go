@@ -0,0 +1,62 @@
+package handlers
+
+import (
+	"net/http"
+	"time"
+
+	"code.cloudfoundry.org/gorouter/config"
+	"code.cloudfoundry.org/gorouter/logger"
+	"code.cloudfoundry.org/gorouter/metrics"
+	"code.cloudfoundry.org/gorouter/proxy/utils"
+
+	"github.com/uber-go/zap"
+	"github.com/urfave/negroni"
+)
+
+type canaryAnalysis struct {
+	tag          string
+	defaultGroup string
+	reporter     metrics.CombinedReporter
+	logger       logger.Logger
+}
+
+// NewCanaryAnalysis creates a handler that reports backend responses split by
+// the endpoint group named in the configured endpoint tag
+func NewCanaryAnalysis(cfg config.CanaryAnalysisConfig, reporter metrics.CombinedReporter, logger logger.Logger) negroni.Handler {
+	return &canaryAnalysis{
+		tag:          cfg.Tag,
+		defaultGroup: cfg.DefaultGroup,
+		reporter:     reporter,
+		logger:       logger,
+	}
+}
+
+func (c *canaryAnalysis) ServeHTTP(rw http.ResponseWriter, r *http.Request, next http.HandlerFunc) {
+	next(rw, r)
+
+	if c.tag == "" {
+		return
+	}
+
+	requestInfo, err := ContextRequestInfo(r)
+	if err != nil {
+		c.logger.Fatal("request-info-err", zap.Error(err))
+		return
+	}
+
+	endpoint := requestInfo.RouteEndpoint
+	if endpoint == nil || requestInfo.StoppedAt.Equal(time.Time{}) {
+		return
+	}
+
+	group := endpoint.Tags[c.tag]
+	if group == "" {
+		group = c.defaultGroup
+	}
+
+	proxyWriter := rw.(utils.ProxyResponseWriter)
+	c.reporter.CaptureEndpointGroupResponse(
+		endpoint, group, proxyWriter.Status(),
+		requestInfo.StoppedAt.Sub(requestInfo.StartedAt),
+	)
+}
@@ -0,0 +1,88 @@
+package handlers_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"time"
+
+	"code.cloudfoundry.org/gorouter/config"
+	"code.cloudfoundry.org/gorouter/handlers"
+	logger_fakes "code.cloudfoundry.org/gorouter/logger/fakes"
+	metrics_fakes "code.cloudfoundry.org/gorouter/metrics/fakes"
+	"code.cloudfoundry.org/gorouter/route"
+	"code.cloudfoundry.org/gorouter/test_util"
+	"code.cloudfoundry.org/routing-api/models"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/urfave/negroni"
+)
+
+var _ = Describe("CanaryAnalysis", func() {
+	var (
+		handler      *negroni.Negroni
+		cfg          config.CanaryAnalysisConfig
+		tags         map[string]string
+		fakeReporter *metrics_fakes.FakeCombinedReporter
+		fakeLogger   *logger_fakes.FakeLogger
+	)
+
+	BeforeEach(func() {
+		cfg = config.CanaryAnalysisConfig{Tag: "deployment", DefaultGroup: "stable"}
+		tags = map[string]string{}
+		fakeReporter = new(metrics_fakes.FakeCombinedReporter)
+		fakeLogger = new(logger_fakes.FakeLogger)
+	})
+
+	JustBeforeEach(func() {
+		handler = negroni.New()
+		handler.Use(handlers.NewRequestInfo())
+		handler.Use(handlers.NewProxyWriter(fakeLogger))
+		handler.Use(handlers.NewCanaryAnalysis(cfg, fakeReporter, fakeLogger))
+		handler.UseHandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+			rw.WriteHeader(http.StatusServiceUnavailable)
+
+			reqInfo, err := handlers.ContextRequestInfo(req)
+			Expect(err).NotTo(HaveOccurred())
+			reqInfo.RouteEndpoint = route.NewEndpoint(
+				"appID", "blah", uint16(1234), "id", "1", tags, 0, "",
+				models.ModificationTag{}, "")
+			reqInfo.StoppedAt = time.Now()
+		})
+
+		handler.ServeHTTP(httptest.NewRecorder(), test_util.NewRequest("GET", "example.com", "/", nil))
+	})
+
+	Context("when the endpoint is tagged", func() {
+		BeforeEach(func() {
+			tags["deployment"] = "canary"
+		})
+
+		It("reports the response for the tagged group", func() {
+			Expect(fakeReporter.CaptureEndpointGroupResponseCallCount()).To(Equal(1))
+			endpoint, group, statusCode, latency := fakeReporter.CaptureEndpointGroupResponseArgsForCall(0)
+			Expect(endpoint.ApplicationId).To(Equal("appID"))
+			Expect(group).To(Equal("canary"))
+			Expect(statusCode).To(Equal(http.StatusServiceUnavailable))
+			Expect(latency).To(BeNumerically(">", 0))
+		})
+	})
+
+	Context("when the endpoint is not tagged", func() {
+		It("reports the response for the default group", func() {
+			Expect(fakeReporter.CaptureEndpointGroupResponseCallCount()).To(Equal(1))
+			_, group, _, _ := fakeReporter.CaptureEndpointGroupResponseArgsForCall(0)
+			Expect(group).To(Equal("stable"))
+		})
+	})
+
+	Context("when no tag is configured", func() {
+		BeforeEach(func() {
+			cfg = config.CanaryAnalysisConfig{}
+		})
+
+		It("does not report anything", func() {
+			Expect(fakeReporter.CaptureEndpointGroupResponseCallCount()).To(Equal(0))
+		})
+	})
+})
@@ -0,0 +1,251 @@
+package handlers
+
+import (
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/uber-go/zap"
+	"github.com/urfave/negroni"
+
+	"code.cloudfoundry.org/gorouter/config"
+	"code.cloudfoundry.org/gorouter/logger"
+	"code.cloudfoundry.org/gorouter/metrics"
+	"code.cloudfoundry.org/gorouter/proxy/utils"
+)
+
+// coalescedCall is the backend request of the first of identical requests,
+// whose response the others wait for. Its fields are set before done is
+// closed.
+type coalescedCall struct {
+	done     chan struct{}
+	released bool
+
+	shared bool
+	status int
+	header http.Header
+	body   []byte
+}
+
+type coalescing struct {
+	lock  sync.Mutex
+	calls map[string]*coalescedCall
+
+	hosts       []string
+	headers     []string
+	maxBodySize int
+	maxWait     time.Duration
+	reporter    metrics.CombinedReporter
+	logger      logger.Logger
+}
+
+// NewRequestCoalescing creates a handler sending only the first of identical
+// concurrent GET requests to the backends, the others being served a copy of
+// its response once complete. Requests carrying credentials are not
+// coalesced unless their Authorization or Cookie header is one of those the
+// requests must match on, and neither are those sent to route services.
+// Responses setting cookies, larger than the maximum body size or flushed
+// while streamed are not shared, the waiting requests then being sent to the
+// backends themselves, as are those waiting for longer than the maximum wait.
+func NewRequestCoalescing(cfg config.RequestCoalescingConfig, reporter metrics.CombinedReporter, logger logger.Logger) negroni.Handler {
+	headers := make([]string, len(cfg.Headers))
+	for i, header := range cfg.Headers {
+		headers[i] = http.CanonicalHeaderKey(header)
+	}
+
+	return &coalescing{
+		calls:       make(map[string]*coalescedCall),
+		hosts:       cfg.Hosts,
+		headers:     headers,
+		maxBodySize: cfg.MaxBodySize,
+		maxWait:     cfg.MaxWait,
+		reporter:    reporter,
+		logger:      logger,
+	}
+}
+
+func (c *coalescing) ServeHTTP(rw http.ResponseWriter, r *http.Request, next http.HandlerFunc) {
+	host := strings.ToLower(hostWithoutPort(r.Host))
+	if r.Method != http.MethodGet || !c.coalesces(host) {
+		next(rw, r)
+		return
+	}
+
+	requestInfo, err := ContextRequestInfo(r)
+	if err != nil {
+		c.logger.Fatal("request-info-err", zap.Error(err))
+		return
+	}
+	if requestInfo.RouteServiceURL != nil {
+		next(rw, r)
+		return
+	}
+
+	key, ok := c.key(host, r)
+	if !ok {
+		next(rw, r)
+		return
+	}
+
+	c.lock.Lock()
+	call, inFlight := c.calls[key]
+	if !inFlight {
+		call = &coalescedCall{done: make(chan struct{})}
+		c.calls[key] = call
+	}
+	c.lock.Unlock()
+
+	if inFlight {
+		var expired <-chan time.Time
+		if c.maxWait > 0 {
+			timer := time.NewTimer(c.maxWait)
+			defer timer.Stop()
+			expired = timer.C
+		}
+		select {
+		case <-call.done:
+		case <-expired:
+			next(rw, r)
+			return
+		case <-r.Context().Done():
+			return
+		}
+		if !call.shared {
+			next(rw, r)
+			return
+		}
+
+		c.reporter.CaptureCoalescedRequest()
+		for name, values := range call.header {
+			rw.Header()[name] = append([]string(nil), values...)
+		}
+		rw.WriteHeader(call.status)
+		rw.Write(call.body)
+		return
+	}
+
+	writer := &coalescingResponseWriter{
+		ProxyResponseWriter: rw.(utils.ProxyResponseWriter),
+		coalescing:          c,
+		key:                 key,
+		call:                call,
+	}
+	requestInfo.ProxyResponseWriter = writer
+	defer func() {
+		c.release(key, call, writer.status != 0 && !writer.tooLarge &&
+			writer.header.Get("Set-Cookie") == "" && r.Context().Err() == nil)
+	}()
+	next(writer, r)
+}
+
+func (c *coalescing) coalesces(host string) bool {
+	if len(c.hosts) == 0 {
+		return true
+	}
+	for _, pattern := range c.hosts {
+		if pattern == host || (strings.HasPrefix(pattern, "*.") && strings.HasSuffix(host, pattern[1:])) {
+			return true
+		}
+	}
+	return false
+}
+
+// key identifies the requests identical to r, which has none when it is not
+// to be coalesced
+func (c *coalescing) key(host string, r *http.Request) (string, bool) {
+	if r.Header.Get("Range") != "" || r.Header.Get("Upgrade") != "" {
+		return "", false
+	}
+	for _, credentials := range []string{"Authorization", "Cookie"} {
+		if r.Header.Get(credentials) != "" && !c.matchesOn(credentials) {
+			return "", false
+		}
+	}
+
+	var key strings.Builder
+	key.WriteString(host)
+	key.WriteString(r.URL.RequestURI())
+	for _, header := range c.headers {
+		key.WriteByte('\n')
+		key.WriteString(strings.Join(r.Header[header], ","))
+	}
+	return key.String(), true
+}
+
+func (c *coalescing) matchesOn(header string) bool {
+	for _, h := range c.headers {
+		if h == header {
+			return true
+		}
+	}
+	return false
+}
+
+// release hands the response of call to the requests waiting for it, sharing
+// it or not. Requests arriving from then on make a new call. Only the first
+// release of a call counts.
+func (c *coalescing) release(key string, call *coalescedCall, shared bool) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	if call.released {
+		return
+	}
+	call.released = true
+	if c.calls[key] == call {
+		delete(c.calls, key)
+	}
+	call.shared = shared
+	close(call.done)
+}
+
+// coalescingResponseWriter keeps a copy of the response written to the client
+// of the first request for the others, giving up on it once larger than the
+// maximum body size
+type coalescingResponseWriter struct {
+	utils.ProxyResponseWriter
+	coalescing *coalescing
+	key        string
+	call       *coalescedCall
+
+	status   int
+	header   http.Header
+	tooLarge bool
+}
+
+func (w *coalescingResponseWriter) WriteHeader(status int) {
+	if w.status == 0 && status >= http.StatusOK {
+		w.status = status
+		w.header = make(http.Header, len(w.Header()))
+		for name, values := range w.Header() {
+			w.header[name] = append([]string(nil), values...)
+		}
+		w.call.status = status
+		w.call.header = w.header
+	}
+	w.ProxyResponseWriter.WriteHeader(status)
+}
+
+func (w *coalescingResponseWriter) Write(b []byte) (int, error) {
+	if w.status == 0 {
+		w.WriteHeader(http.StatusOK)
+	}
+	if !w.tooLarge {
+		if len(w.call.body)+len(b) > w.coalescing.maxBodySize {
+			w.tooLarge = true
+			w.call.body = nil
+			w.coalescing.release(w.key, w.call, false)
+		} else {
+			w.call.body = append(w.call.body, b...)
+		}
+	}
+	return w.ProxyResponseWriter.Write(b)
+}
+
+// Flush hands the response over to the client as it streams, which leaves
+// it unshared: the waiting requests are released to the backends right away.
+func (w *coalescingResponseWriter) Flush() {
+	w.coalescing.release(w.key, w.call, false)
+	w.ProxyResponseWriter.Flush()
+}
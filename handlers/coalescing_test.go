@@ -0,0 +1,248 @@
+package handlers_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"code.cloudfoundry.org/gorouter/config"
+	"code.cloudfoundry.org/gorouter/handlers"
+	logger_fakes "code.cloudfoundry.org/gorouter/logger/fakes"
+	metrics_fakes "code.cloudfoundry.org/gorouter/metrics/fakes"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/urfave/negroni"
+)
+
+var _ = Describe("RequestCoalescing", func() {
+	var (
+		handler      *negroni.Negroni
+		cfg          config.RequestCoalescingConfig
+		fakeReporter *metrics_fakes.FakeCombinedReporter
+		backendCalls int32
+		release      chan struct{}
+		body         string
+		setCookie    bool
+		flush        bool
+		routeService *url.URL
+	)
+
+	BeforeEach(func() {
+		cfg = config.RequestCoalescingConfig{Enabled: true, MaxBodySize: 1024}
+		fakeReporter = new(metrics_fakes.FakeCombinedReporter)
+		atomic.StoreInt32(&backendCalls, 0)
+		release = make(chan struct{})
+		body = "the response"
+		setCookie = false
+		flush = false
+		routeService = nil
+	})
+
+	JustBeforeEach(func() {
+		fakeLogger := new(logger_fakes.FakeLogger)
+		handler = negroni.New()
+		handler.Use(handlers.NewRequestInfo())
+		handler.Use(handlers.NewProxyWriter(fakeLogger))
+		handler.UseFunc(func(rw http.ResponseWriter, r *http.Request, next http.HandlerFunc) {
+			reqInfo, err := handlers.ContextRequestInfo(r)
+			Expect(err).NotTo(HaveOccurred())
+			reqInfo.RouteServiceURL = routeService
+			next(rw, r)
+		})
+		handler.Use(handlers.NewRequestCoalescing(cfg, fakeReporter, fakeLogger))
+		handler.UseHandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+			atomic.AddInt32(&backendCalls, 1)
+			<-release
+			rw.Header().Set("X-Backend", "yes")
+			if setCookie {
+				rw.Header().Set("Set-Cookie", "session=abc")
+			}
+			rw.WriteHeader(http.StatusOK)
+			if flush {
+				rw.(http.Flusher).Flush()
+			}
+			rw.Write([]byte(body))
+		})
+	})
+
+	calls := func() int32 { return atomic.LoadInt32(&backendCalls) }
+
+	// serveConcurrently sends the requests, waiting for the first to reach the
+	// backend before sending the others
+	serveConcurrently := func(reqs ...*http.Request) []*httptest.ResponseRecorder {
+		resps := make([]*httptest.ResponseRecorder, len(reqs))
+		var wg sync.WaitGroup
+		for i, req := range reqs {
+			resps[i] = httptest.NewRecorder()
+			wg.Add(1)
+			go func(resp *httptest.ResponseRecorder, req *http.Request) {
+				defer GinkgoRecover()
+				defer wg.Done()
+				handler.ServeHTTP(resp, req)
+			}(resps[i], req)
+			if i == 0 {
+				Eventually(calls).Should(Equal(int32(1)))
+			}
+		}
+		time.Sleep(50 * time.Millisecond)
+		close(release)
+		wg.Wait()
+		return resps
+	}
+
+	get := func(target string) *http.Request {
+		return httptest.NewRequest("GET", target, nil)
+	}
+
+	It("sends identical concurrent GETs to the backend once", func() {
+		resps := serveConcurrently(get("http://app.example.com/items?page=1"), get("http://app.example.com/items?page=1"), get("http://app.example.com/items?page=1"))
+
+		Expect(calls()).To(Equal(int32(1)))
+		for _, resp := range resps {
+			Expect(resp.Code).To(Equal(http.StatusOK))
+			Expect(resp.Body.String()).To(Equal("the response"))
+			Expect(resp.Header().Get("X-Backend")).To(Equal("yes"))
+		}
+		Expect(fakeReporter.CaptureCoalescedRequestCallCount()).To(Equal(2))
+	})
+
+	It("does not coalesce requests for other paths or queries", func() {
+		serveConcurrently(get("http://app.example.com/items?page=1"), get("http://app.example.com/items?page=2"), get("http://app.example.com/other"))
+
+		Expect(calls()).To(Equal(int32(3)))
+	})
+
+	It("does not coalesce other methods", func() {
+		serveConcurrently(httptest.NewRequest("POST", "http://app.example.com/items", nil), httptest.NewRequest("POST", "http://app.example.com/items", nil))
+
+		Expect(calls()).To(Equal(int32(2)))
+	})
+
+	It("does not coalesce requests carrying credentials", func() {
+		first := get("http://app.example.com/items")
+		first.Header.Set("Authorization", "Bearer one")
+		second := get("http://app.example.com/items")
+		second.Header.Set("Authorization", "Bearer one")
+
+		serveConcurrently(first, second)
+		Expect(calls()).To(Equal(int32(2)))
+	})
+
+	It("does not coalesce requests sent to route services", func() {
+		routeService = &url.URL{Scheme: "https", Host: "rs.example.com"}
+
+		serveConcurrently(get("http://app.example.com/items"), get("http://app.example.com/items"))
+		Expect(calls()).To(Equal(int32(2)))
+	})
+
+	It("does not share responses setting cookies", func() {
+		setCookie = true
+
+		resps := serveConcurrently(get("http://app.example.com/items"), get("http://app.example.com/items"))
+		Expect(calls()).To(Equal(int32(2)))
+		Expect(resps[1].Body.String()).To(Equal("the response"))
+	})
+
+	Context("with headers to match on", func() {
+		BeforeEach(func() {
+			cfg.Headers = []string{"accept", "authorization"}
+		})
+
+		It("coalesces requests with the same values", func() {
+			first := get("http://app.example.com/items")
+			first.Header.Set("Accept", "application/json")
+			first.Header.Set("Authorization", "Bearer one")
+			second := get("http://app.example.com/items")
+			second.Header.Set("Accept", "application/json")
+			second.Header.Set("Authorization", "Bearer one")
+
+			serveConcurrently(first, second)
+			Expect(calls()).To(Equal(int32(1)))
+		})
+
+		It("does not coalesce requests with other values", func() {
+			first := get("http://app.example.com/items")
+			first.Header.Set("Accept", "application/json")
+			second := get("http://app.example.com/items")
+			second.Header.Set("Accept", "text/html")
+
+			serveConcurrently(first, second)
+			Expect(calls()).To(Equal(int32(2)))
+		})
+	})
+
+	Context("with hosts", func() {
+		BeforeEach(func() {
+			cfg.Hosts = []string{"*.example.com"}
+		})
+
+		It("coalesces the requests of matching hosts", func() {
+			serveConcurrently(get("http://app.example.com/items"), get("http://app.example.com/items"))
+			Expect(calls()).To(Equal(int32(1)))
+		})
+
+		It("leaves other hosts alone", func() {
+			serveConcurrently(get("http://app.other.com/items"), get("http://app.other.com/items"))
+			Expect(calls()).To(Equal(int32(2)))
+		})
+	})
+
+	Context("when the response is larger than the maximum body size", func() {
+		BeforeEach(func() {
+			body = strings.Repeat("x", 2048)
+		})
+
+		It("sends the waiting requests to the backend themselves", func() {
+			resps := serveConcurrently(get("http://app.example.com/items"), get("http://app.example.com/items"))
+
+			Expect(calls()).To(Equal(int32(2)))
+			for _, resp := range resps {
+				Expect(resp.Body.Len()).To(Equal(2048))
+			}
+			Expect(fakeReporter.CaptureCoalescedRequestCallCount()).To(Equal(0))
+		})
+	})
+
+	Context("when the response is streamed", func() {
+		BeforeEach(func() {
+			flush = true
+		})
+
+		It("sends the waiting requests to the backend themselves", func() {
+			resps := serveConcurrently(get("http://app.example.com/items"), get("http://app.example.com/items"))
+
+			Expect(calls()).To(Equal(int32(2)))
+			for _, resp := range resps {
+				Expect(resp.Body.String()).To(Equal("the response"))
+			}
+			Expect(fakeReporter.CaptureCoalescedRequestCallCount()).To(Equal(0))
+		})
+	})
+
+	Context("with a maximum wait", func() {
+		BeforeEach(func() {
+			cfg.MaxWait = 10 * time.Millisecond
+		})
+
+		It("sends requests waiting for longer to the backend themselves", func() {
+			resps := serveConcurrently(get("http://app.example.com/items"), get("http://app.example.com/items"))
+
+			Expect(calls()).To(Equal(int32(2)))
+			Expect(resps[1].Body.String()).To(Equal("the response"))
+			Expect(fakeReporter.CaptureCoalescedRequestCallCount()).To(Equal(0))
+		})
+	})
+
+	It("sends requests arriving after the response to the backend", func() {
+		close(release)
+		handler.ServeHTTP(httptest.NewRecorder(), get("http://app.example.com/items"))
+		handler.ServeHTTP(httptest.NewRecorder(), get("http://app.example.com/items"))
+
+		Expect(calls()).To(Equal(int32(2)))
+	})
+})
@@ -0,0 +1,239 @@
+package handlers
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/uber-go/zap"
+	"github.com/urfave/negroni"
+
+	router_http "code.cloudfoundry.org/gorouter/common/http"
+	"code.cloudfoundry.org/gorouter/config"
+	"code.cloudfoundry.org/gorouter/logger"
+	"code.cloudfoundry.org/gorouter/proxy/utils"
+)
+
+var corsResponseHeaders = []string{
+	"Access-Control-Allow-Origin",
+	"Access-Control-Allow-Credentials",
+	"Access-Control-Allow-Methods",
+	"Access-Control-Allow-Headers",
+	"Access-Control-Expose-Headers",
+	"Access-Control-Max-Age",
+}
+
+type cors struct {
+	rules  []config.CORSRule
+	logger logger.Logger
+}
+
+// NewCORS creates a handler applying the CORS policy of a request's route,
+// either registered with it or configured for its host by rules. Preflight
+// requests are answered without reaching the backend. It must follow the
+// lookup handler.
+func NewCORS(rules []config.CORSRule, logger logger.Logger) negroni.Handler {
+	return &cors{
+		rules:  rules,
+		logger: logger,
+	}
+}
+
+func (c *cors) ServeHTTP(rw http.ResponseWriter, r *http.Request, next http.HandlerFunc) {
+	origin := r.Header.Get("Origin")
+	if origin == "" {
+		next(rw, r)
+		return
+	}
+
+	requestInfo, err := ContextRequestInfo(r)
+	if err != nil {
+		c.logger.Fatal("request-info-err", zap.Error(err))
+		return
+	}
+
+	policy := requestInfo.RoutePool.CORSPolicy()
+	if policy == nil {
+		policy = c.ruleFor(hostWithoutPort(r.Host))
+	}
+	if policy == nil {
+		next(rw, r)
+		return
+	}
+
+	allowOrigin, allowCredentials := matchOrigin(policy, origin)
+
+	if r.Method == http.MethodOptions && r.Header.Get("Access-Control-Request-Method") != "" {
+		c.preflight(rw, r, policy, allowOrigin, allowCredentials)
+		return
+	}
+
+	headers := http.Header{}
+	if allowOrigin != "" {
+		headers.Set("Access-Control-Allow-Origin", allowOrigin)
+		if allowCredentials {
+			headers.Set("Access-Control-Allow-Credentials", "true")
+		}
+		if len(policy.ExposedHeaders) > 0 {
+			headers.Set("Access-Control-Expose-Headers", strings.Join(policy.ExposedHeaders, ", "))
+		}
+	}
+
+	proxyWriter := &corsResponseWriter{
+		ProxyResponseWriter: rw.(utils.ProxyResponseWriter),
+		headers:             headers,
+	}
+	requestInfo.ProxyResponseWriter = proxyWriter
+	next(proxyWriter, r)
+}
+
+func (c *cors) preflight(rw http.ResponseWriter, r *http.Request, policy *config.CORSPolicy, allowOrigin string, allowCredentials bool) {
+	method := r.Header.Get("Access-Control-Request-Method")
+	requested := splitHeaderList(r.Header.Get("Access-Control-Request-Headers"))
+
+	if allowOrigin == "" || !corsMethodAllowed(policy, method) || !corsHeadersAllowed(policy, requested) {
+		c.logger.Info("cors-preflight-rejected",
+			zap.String("origin", r.Header.Get("Origin")),
+			zap.String("method", method),
+		)
+		rw.Header().Set(router_http.CfRouterError, "cors_rejected")
+		writeStatus(
+			rw,
+			http.StatusForbidden,
+			fmt.Sprintf("CORS request not allowed on route ('%s').", r.Host),
+			c.logger,
+		)
+		return
+	}
+
+	header := rw.Header()
+	header.Set("Access-Control-Allow-Origin", allowOrigin)
+	if allowCredentials {
+		header.Set("Access-Control-Allow-Credentials", "true")
+	}
+	header.Set("Access-Control-Allow-Methods", method)
+	if len(requested) > 0 {
+		header.Set("Access-Control-Allow-Headers", strings.Join(requested, ", "))
+	}
+	if policy.MaxAgeSeconds > 0 {
+		header.Set("Access-Control-Max-Age", strconv.Itoa(policy.MaxAgeSeconds))
+	}
+	header.Add("Vary", "Origin")
+	rw.WriteHeader(http.StatusNoContent)
+}
+
+// ruleFor returns the policy of the first rule matching host
+func (c *cors) ruleFor(host string) *config.CORSPolicy {
+	host = strings.ToLower(host)
+	for i, rule := range c.rules {
+		if rule.Host == host || (strings.HasPrefix(rule.Host, "*.") && strings.HasSuffix(host, rule.Host[1:])) {
+			return &c.rules[i].CORSPolicy
+		}
+	}
+	return nil
+}
+
+// matchOrigin returns the Access-Control-Allow-Origin value for origin, empty
+// when it is not allowed, and whether credentials may be sent
+func matchOrigin(policy *config.CORSPolicy, origin string) (string, bool) {
+	wildcard := false
+	for _, allowed := range policy.AllowedOrigins {
+		if allowed == origin {
+			return origin, policy.AllowCredentials
+		}
+		wildcard = wildcard || allowed == "*"
+	}
+	if wildcard {
+		return "*", false
+	}
+	return "", false
+}
+
+func corsMethodAllowed(policy *config.CORSPolicy, method string) bool {
+	if len(policy.AllowedMethods) == 0 {
+		return method == http.MethodGet || method == http.MethodHead || method == http.MethodPost
+	}
+	for _, allowed := range policy.AllowedMethods {
+		if strings.EqualFold(allowed, method) {
+			return true
+		}
+	}
+	return false
+}
+
+func corsHeadersAllowed(policy *config.CORSPolicy, requested []string) bool {
+	for _, header := range requested {
+		if isSafelistedHeader(header) {
+			continue
+		}
+		allowed := false
+		for _, a := range policy.AllowedHeaders {
+			if a == "*" || strings.EqualFold(a, header) {
+				allowed = true
+				break
+			}
+		}
+		if !allowed {
+			return false
+		}
+	}
+	return true
+}
+
+func isSafelistedHeader(header string) bool {
+	switch strings.ToLower(header) {
+	case "accept", "accept-language", "content-language", "content-type":
+		return true
+	}
+	return false
+}
+
+func splitHeaderList(value string) []string {
+	var list []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			list = append(list, item)
+		}
+	}
+	return list
+}
+
+// corsResponseWriter replaces the CORS headers of the backend's response with
+// the router's
+type corsResponseWriter struct {
+	utils.ProxyResponseWriter
+	headers     http.Header
+	wroteHeader bool
+}
+
+func (w *corsResponseWriter) WriteHeader(status int) {
+	if !w.wroteHeader && status >= http.StatusOK {
+		w.wroteHeader = true
+
+		header := w.Header()
+		for _, name := range corsResponseHeaders {
+			header.Del(name)
+		}
+		for name, values := range w.headers {
+			header[name] = values
+		}
+		header.Add("Vary", "Origin")
+	}
+	w.ProxyResponseWriter.WriteHeader(status)
+}
+
+func (w *corsResponseWriter) Write(b []byte) (int, error) {
+	if !w.wroteHeader {
+		w.WriteHeader(http.StatusOK)
+	}
+	return w.ProxyResponseWriter.Write(b)
+}
+
+func (w *corsResponseWriter) ReadFrom(r io.Reader) (int64, error) {
+	if !w.wroteHeader {
+		w.WriteHeader(http.StatusOK)
+	}
+	return utils.ReadFrom(w.ProxyResponseWriter, r)
+}
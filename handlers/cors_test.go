@@ -0,0 +1,175 @@
+package handlers_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+
+	"code.cloudfoundry.org/gorouter/config"
+	"code.cloudfoundry.org/gorouter/handlers"
+	logger_fakes "code.cloudfoundry.org/gorouter/logger/fakes"
+	"code.cloudfoundry.org/gorouter/route"
+	"code.cloudfoundry.org/routing-api/models"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/urfave/negroni"
+)
+
+var _ = Describe("CORS", func() {
+	var (
+		handler    *negroni.Negroni
+		pool       *route.Pool
+		rules      []config.CORSRule
+		nextCalled bool
+	)
+
+	JustBeforeEach(func() {
+		fakeLogger := new(logger_fakes.FakeLogger)
+		handler = negroni.New()
+		handler.Use(handlers.NewRequestInfo())
+		handler.Use(handlers.NewProxyWriter(fakeLogger))
+		handler.UseFunc(func(rw http.ResponseWriter, req *http.Request, next http.HandlerFunc) {
+			reqInfo, err := handlers.ContextRequestInfo(req)
+			Expect(err).NotTo(HaveOccurred())
+			reqInfo.RoutePool = pool
+			next(rw, req)
+		})
+		handler.Use(handlers.NewCORS(rules, fakeLogger))
+		handler.UseHandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+			nextCalled = true
+			rw.Header().Set("Access-Control-Allow-Origin", "*")
+			rw.Write([]byte("backend"))
+		})
+	})
+
+	BeforeEach(func() {
+		pool = route.NewPool(0, "")
+		rules = nil
+		nextCalled = false
+	})
+
+	register := func(policy *config.CORSPolicy) {
+		endpoint := route.NewEndpoint("app", "1.2.3.4", 5678, "", "", nil, -1, "", models.ModificationTag{}, "")
+		endpoint.CORS = policy
+		pool.Put(endpoint)
+	}
+
+	serve := func(method, origin string, headers map[string]string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, "http://api.example.com/things", nil)
+		if origin != "" {
+			req.Header.Set("Origin", origin)
+		}
+		for name, value := range headers {
+			req.Header.Set(name, value)
+		}
+		resp := httptest.NewRecorder()
+		handler.ServeHTTP(resp, req)
+		return resp
+	}
+
+	preflight := func(origin, method, headers string) *httptest.ResponseRecorder {
+		return serve("OPTIONS", origin, map[string]string{
+			"Access-Control-Request-Method":  method,
+			"Access-Control-Request-Headers": headers,
+		})
+	}
+
+	It("leaves routes without a policy to the backend", func() {
+		resp := serve("GET", "https://app.example.org", nil)
+		Expect(nextCalled).To(BeTrue())
+		Expect(resp.Header().Get("Access-Control-Allow-Origin")).To(Equal("*"))
+	})
+
+	Context("with a registered policy", func() {
+		BeforeEach(func() {
+			register(&config.CORSPolicy{
+				AllowedOrigins:   []string{"https://app.example.org"},
+				AllowedMethods:   []string{"GET", "PUT"},
+				AllowedHeaders:   []string{"X-Token"},
+				ExposedHeaders:   []string{"X-Total"},
+				AllowCredentials: true,
+				MaxAgeSeconds:    600,
+			})
+		})
+
+		It("answers preflight requests itself", func() {
+			resp := preflight("https://app.example.org", "PUT", "X-Token, Content-Type")
+
+			Expect(nextCalled).To(BeFalse())
+			Expect(resp.Code).To(Equal(http.StatusNoContent))
+			Expect(resp.Header().Get("Access-Control-Allow-Origin")).To(Equal("https://app.example.org"))
+			Expect(resp.Header().Get("Access-Control-Allow-Credentials")).To(Equal("true"))
+			Expect(resp.Header().Get("Access-Control-Allow-Methods")).To(Equal("PUT"))
+			Expect(resp.Header().Get("Access-Control-Allow-Headers")).To(Equal("X-Token, Content-Type"))
+			Expect(resp.Header().Get("Access-Control-Max-Age")).To(Equal("600"))
+		})
+
+		It("rejects preflight requests the policy does not allow", func() {
+			for _, resp := range []*httptest.ResponseRecorder{
+				preflight("https://evil.example.org", "PUT", ""),
+				preflight("https://app.example.org", "DELETE", ""),
+				preflight("https://app.example.org", "PUT", "X-Other"),
+			} {
+				Expect(resp.Code).To(Equal(http.StatusForbidden))
+				Expect(resp.Header().Get("X-Cf-RouterError")).To(Equal("cors_rejected"))
+				Expect(resp.Header().Get("Access-Control-Allow-Origin")).To(BeEmpty())
+			}
+			Expect(nextCalled).To(BeFalse())
+		})
+
+		It("replaces the CORS headers of the backend's responses", func() {
+			resp := serve("GET", "https://app.example.org", nil)
+
+			Expect(nextCalled).To(BeTrue())
+			Expect(resp.Body.String()).To(Equal("backend"))
+			Expect(resp.Header()["Access-Control-Allow-Origin"]).To(Equal([]string{"https://app.example.org"}))
+			Expect(resp.Header().Get("Access-Control-Expose-Headers")).To(Equal("X-Total"))
+			Expect(resp.Header().Get("Vary")).To(Equal("Origin"))
+		})
+
+		It("strips the backend's CORS headers for origins not allowed", func() {
+			resp := serve("GET", "https://evil.example.org", nil)
+
+			Expect(nextCalled).To(BeTrue())
+			Expect(resp.Header().Get("Access-Control-Allow-Origin")).To(BeEmpty())
+		})
+
+		It("passes OPTIONS requests that are not preflights through", func() {
+			serve("OPTIONS", "https://app.example.org", nil)
+			Expect(nextCalled).To(BeTrue())
+		})
+	})
+
+	Context("with a configured rule", func() {
+		BeforeEach(func() {
+			rules = []config.CORSRule{{
+				Host: "*.example.com",
+				CORSPolicy: config.CORSPolicy{
+					AllowedOrigins:   []string{"*"},
+					AllowCredentials: true,
+				},
+			}}
+		})
+
+		It("applies the rule to the hosts it matches", func() {
+			resp := preflight("https://anywhere.org", "POST", "")
+			Expect(resp.Code).To(Equal(http.StatusNoContent))
+			Expect(resp.Header().Get("Access-Control-Allow-Origin")).To(Equal("*"))
+		})
+
+		It("never allows credentials for any origin", func() {
+			resp := serve("GET", "https://anywhere.org", nil)
+			Expect(resp.Header().Get("Access-Control-Allow-Credentials")).To(BeEmpty())
+		})
+
+		It("only allows simple methods when the rule names none", func() {
+			Expect(preflight("https://anywhere.org", "DELETE", "").Code).To(Equal(http.StatusForbidden))
+		})
+
+		It("gives way to a policy registered with the route", func() {
+			register(&config.CORSPolicy{AllowedOrigins: []string{"https://app.example.org"}})
+
+			Expect(preflight("https://anywhere.org", "GET", "").Code).To(Equal(http.StatusForbidden))
+		})
+	})
+})
@@ -0,0 +1,115 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/uber-go/zap"
+	"github.com/urfave/negroni"
+
+	"code.cloudfoundry.org/gorouter/config"
+	"code.cloudfoundry.org/gorouter/logger"
+)
+
+// GRPCTimeoutHeader is the header gRPC clients send the time they wait for a
+// call in
+const GRPCTimeoutHeader = "Grpc-Timeout"
+
+// grpcTimeoutUnits are the units of grpc-timeout values
+var grpcTimeoutUnits = map[byte]time.Duration{
+	'H': time.Hour,
+	'M': time.Minute,
+	'S': time.Second,
+	'm': time.Millisecond,
+	'u': time.Microsecond,
+	'n': time.Nanosecond,
+}
+
+type deadlinePropagation struct {
+	header          string
+	grpcTimeout     bool
+	endpointTimeout time.Duration
+	logger          logger.Logger
+}
+
+// NewDeadlinePropagation creates a handler setting the header of cfg to the
+// time the router stops waiting for the backend, in Unix milliseconds. That
+// is endpointTimeout after the request arrived, or the deadline of the request
+// context when earlier. The header of clients is never forwarded, and is not
+// set when the request has no deadline. gRPC requests also get the time left
+// in grpc-timeout when enabled, unless the client asked for less.
+func NewDeadlinePropagation(cfg config.DeadlinePropagationConfig, endpointTimeout time.Duration, logger logger.Logger) negroni.Handler {
+	return &deadlinePropagation{
+		header:          http.CanonicalHeaderKey(cfg.Header),
+		grpcTimeout:     cfg.GRPCTimeout,
+		endpointTimeout: endpointTimeout,
+		logger:          logger,
+	}
+}
+
+func (d *deadlinePropagation) ServeHTTP(rw http.ResponseWriter, r *http.Request, next http.HandlerFunc) {
+	r.Header.Del(d.header)
+
+	requestInfo, err := ContextRequestInfo(r)
+	if err != nil {
+		d.logger.Fatal("request-info-err", zap.Error(err))
+		return
+	}
+
+	var deadline time.Time
+	if d.endpointTimeout > 0 {
+		deadline = requestInfo.StartedAt.Add(d.endpointTimeout)
+	}
+	if ctxDeadline, ok := r.Context().Deadline(); ok && (deadline.IsZero() || ctxDeadline.Before(deadline)) {
+		deadline = ctxDeadline
+	}
+
+	if !deadline.IsZero() {
+		r.Header.Set(d.header, strconv.FormatInt(deadline.UnixNano()/int64(time.Millisecond), 10))
+		if d.grpcTimeout && isGRPC(r) {
+			remaining := time.Until(deadline)
+			if requested, ok := parseGRPCTimeout(r.Header.Get(GRPCTimeoutHeader)); !ok || requested > remaining {
+				r.Header.Set(GRPCTimeoutHeader, formatGRPCTimeout(remaining))
+			}
+		}
+	}
+
+	next(rw, r)
+}
+
+func isGRPC(r *http.Request) bool {
+	return strings.HasPrefix(r.Header.Get("Content-Type"), "application/grpc")
+}
+
+// parseGRPCTimeout reads a grpc-timeout value, at most 8 digits followed by
+// a unit
+func parseGRPCTimeout(value string) (time.Duration, bool) {
+	if len(value) < 2 || len(value) > 9 {
+		return 0, false
+	}
+	unit, ok := grpcTimeoutUnits[value[len(value)-1]]
+	if !ok {
+		return 0, false
+	}
+	n, err := strconv.ParseUint(value[:len(value)-1], 10, 64)
+	if err != nil {
+		return 0, false
+	}
+	return time.Duration(n) * unit, true
+}
+
+// formatGRPCTimeout writes d as a grpc-timeout value, rounded up to the
+// millisecond so that backends are never told the time is up before it is
+func formatGRPCTimeout(d time.Duration) string {
+	ms := (d + time.Millisecond - 1) / time.Millisecond
+	if ms < 1 {
+		ms = 1
+	}
+	if ms < 1e8 {
+		return fmt.Sprintf("%dm", ms)
+	}
+	return fmt.Sprintf("%dS", (d+time.Second-1)/time.Second)
+}
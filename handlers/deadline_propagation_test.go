@@ -0,0 +1,144 @@
+package handlers_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"time"
+
+	"code.cloudfoundry.org/gorouter/config"
+	"code.cloudfoundry.org/gorouter/handlers"
+	logger_fakes "code.cloudfoundry.org/gorouter/logger/fakes"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/urfave/negroni"
+)
+
+var _ = Describe("DeadlinePropagation", func() {
+	var (
+		handler         *negroni.Negroni
+		cfg             config.DeadlinePropagationConfig
+		endpointTimeout time.Duration
+		contextTimeout  time.Duration
+		req             *http.Request
+		forwarded       http.Header
+	)
+
+	BeforeEach(func() {
+		cfg = config.DeadlinePropagationConfig{Enabled: true, Header: "X-Request-Deadline"}
+		endpointTimeout = 10 * time.Second
+		contextTimeout = 0
+		req = httptest.NewRequest("POST", "http://app.example.com/items", nil)
+		forwarded = nil
+	})
+
+	JustBeforeEach(func() {
+		handler = negroni.New()
+		handler.Use(handlers.NewRequestInfo())
+		handler.UseFunc(func(rw http.ResponseWriter, r *http.Request, next http.HandlerFunc) {
+			if contextTimeout > 0 {
+				ctx, cancel := context.WithTimeout(r.Context(), contextTimeout)
+				defer cancel()
+				r = r.WithContext(ctx)
+			}
+			next(rw, r)
+		})
+		handler.Use(handlers.NewDeadlinePropagation(cfg, endpointTimeout, new(logger_fakes.FakeLogger)))
+		handler.UseHandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+			forwarded = r.Header
+		})
+	})
+
+	deadline := func() time.Time {
+		ms, err := strconv.ParseInt(forwarded.Get("X-Request-Deadline"), 10, 64)
+		Expect(err).NotTo(HaveOccurred())
+		return time.Unix(0, ms*int64(time.Millisecond))
+	}
+
+	It("sets the deadline to the endpoint timeout after the request arrived", func() {
+		sent := time.Now()
+		handler.ServeHTTP(httptest.NewRecorder(), req)
+
+		Expect(deadline()).To(BeTemporally("~", sent.Add(10*time.Second), 100*time.Millisecond))
+	})
+
+	It("replaces the deadline sent by the client", func() {
+		req.Header.Set("X-Request-Deadline", "1")
+		handler.ServeHTTP(httptest.NewRecorder(), req)
+
+		Expect(deadline()).To(BeTemporally(">", time.Now()))
+	})
+
+	Context("when the request context has an earlier deadline", func() {
+		BeforeEach(func() {
+			contextTimeout = 2 * time.Second
+		})
+
+		It("sets that deadline", func() {
+			sent := time.Now()
+			handler.ServeHTTP(httptest.NewRecorder(), req)
+
+			Expect(deadline()).To(BeTemporally("~", sent.Add(2*time.Second), 100*time.Millisecond))
+		})
+	})
+
+	Context("without a timeout", func() {
+		BeforeEach(func() {
+			endpointTimeout = 0
+		})
+
+		It("removes the deadline sent by the client", func() {
+			req.Header.Set("X-Request-Deadline", "1")
+			handler.ServeHTTP(httptest.NewRecorder(), req)
+
+			Expect(forwarded).NotTo(HaveKey("X-Request-Deadline"))
+		})
+	})
+
+	Context("with grpc-timeout", func() {
+		BeforeEach(func() {
+			cfg.GRPCTimeout = true
+			req.Header.Set("Content-Type", "application/grpc+proto")
+		})
+
+		It("sets the time left on gRPC requests", func() {
+			handler.ServeHTTP(httptest.NewRecorder(), req)
+
+			timeout := forwarded.Get("Grpc-Timeout")
+			Expect(timeout).To(HaveSuffix("m"))
+			ms, err := strconv.Atoi(timeout[:len(timeout)-1])
+			Expect(err).NotTo(HaveOccurred())
+			Expect(ms).To(BeNumerically("~", 10000, 100))
+		})
+
+		It("keeps a shorter timeout of the client", func() {
+			req.Header.Set("Grpc-Timeout", "3S")
+			handler.ServeHTTP(httptest.NewRecorder(), req)
+
+			Expect(forwarded.Get("Grpc-Timeout")).To(Equal("3S"))
+		})
+
+		It("shortens a longer timeout of the client", func() {
+			req.Header.Set("Grpc-Timeout", "1M")
+			handler.ServeHTTP(httptest.NewRecorder(), req)
+
+			Expect(forwarded.Get("Grpc-Timeout")).To(MatchRegexp(`^\d+m$`))
+		})
+
+		It("leaves other requests alone", func() {
+			req.Header.Set("Content-Type", "application/json")
+			handler.ServeHTTP(httptest.NewRecorder(), req)
+
+			Expect(forwarded).NotTo(HaveKey("Grpc-Timeout"))
+		})
+	})
+
+	It("does not set grpc-timeout unless enabled", func() {
+		req.Header.Set("Content-Type", "application/grpc")
+		handler.ServeHTTP(httptest.NewRecorder(), req)
+
+		Expect(forwarded).NotTo(HaveKey("Grpc-Timeout"))
+	})
+})
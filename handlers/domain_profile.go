@@ -0,0 +1,136 @@
+package handlers
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"sort"
+
+	"github.com/uber-go/zap"
+	"github.com/urfave/negroni"
+
+	"code.cloudfoundry.org/gorouter/config"
+	"code.cloudfoundry.org/gorouter/logger"
+	"code.cloudfoundry.org/gorouter/proxy/utils"
+)
+
+type domainProfiles struct {
+	profiles []config.DomainProfile
+	logger   logger.Logger
+}
+
+// NewDomainProfiles creates a handler applying the profile of the request's
+// domain: it records the profile on the request info for the access log and
+// the round tripper, rewrites request and response headers and bounds the
+// time to proxy the request. Of the profiles matching a host, the one of the
+// longest domain applies.
+func NewDomainProfiles(profiles []config.DomainProfile, logger logger.Logger) negroni.Handler {
+	sorted := make([]config.DomainProfile, len(profiles))
+	copy(sorted, profiles)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return len(sorted[i].Domain) > len(sorted[j].Domain)
+	})
+
+	return &domainProfiles{
+		profiles: sorted,
+		logger:   logger,
+	}
+}
+
+func (d *domainProfiles) ServeHTTP(rw http.ResponseWriter, r *http.Request, next http.HandlerFunc) {
+	profile := d.match(hostWithoutPort(r.Host))
+	if profile == nil {
+		next(rw, r)
+		return
+	}
+
+	requestInfo, err := ContextRequestInfo(r)
+	if err != nil {
+		d.logger.Fatal("request-info-err", zap.Error(err))
+		return
+	}
+	requestInfo.DomainProfile = profile
+
+	applyHeaderPolicy(r.Header, profile.RequestHeaders)
+
+	if len(profile.ResponseHeaders.Remove) > 0 || len(profile.ResponseHeaders.Set) > 0 {
+		proxyWriter := &headerPolicyResponseWriter{
+			ProxyResponseWriter: rw.(utils.ProxyResponseWriter),
+			policy:              profile.ResponseHeaders,
+		}
+		requestInfo.ProxyResponseWriter = proxyWriter
+		rw = proxyWriter
+	}
+
+	// Upgraded connections outlive the request, so are not bounded
+	if profile.EndpointTimeout > 0 && r.Header.Get("Upgrade") == "" {
+		ctx, cancel := context.WithTimeout(r.Context(), profile.EndpointTimeout)
+		defer cancel()
+		r = r.WithContext(ctx)
+	}
+
+	next(rw, r)
+}
+
+func (d *domainProfiles) match(host string) *config.DomainProfile {
+	for i := range d.profiles {
+		if d.profiles[i].Matches(host) {
+			return &d.profiles[i]
+		}
+	}
+	return nil
+}
+
+// LogAccess reports whether a response with the given status is access
+// logged under profile
+func LogAccess(profile *config.DomainProfile, status int) bool {
+	if profile == nil {
+		return true
+	}
+	switch profile.AccessLog {
+	case config.DOMAIN_ACCESS_LOG_NONE:
+		return false
+	case config.DOMAIN_ACCESS_LOG_ERRORS:
+		return status >= http.StatusBadRequest
+	}
+	return true
+}
+
+func applyHeaderPolicy(header http.Header, policy config.HeaderPolicy) {
+	for _, name := range policy.Remove {
+		header.Del(name)
+	}
+	for name, value := range policy.Set {
+		header.Set(name, value)
+	}
+}
+
+// headerPolicyResponseWriter applies a header policy to the response when its
+// header is written
+type headerPolicyResponseWriter struct {
+	utils.ProxyResponseWriter
+	policy      config.HeaderPolicy
+	wroteHeader bool
+}
+
+func (w *headerPolicyResponseWriter) WriteHeader(status int) {
+	if !w.wroteHeader && status >= http.StatusOK {
+		w.wroteHeader = true
+		applyHeaderPolicy(w.Header(), w.policy)
+	}
+	w.ProxyResponseWriter.WriteHeader(status)
+}
+
+func (w *headerPolicyResponseWriter) Write(b []byte) (int, error) {
+	if !w.wroteHeader {
+		w.WriteHeader(http.StatusOK)
+	}
+	return w.ProxyResponseWriter.Write(b)
+}
+
+func (w *headerPolicyResponseWriter) ReadFrom(r io.Reader) (int64, error) {
+	if !w.wroteHeader {
+		w.WriteHeader(http.StatusOK)
+	}
+	return utils.ReadFrom(w.ProxyResponseWriter, r)
+}
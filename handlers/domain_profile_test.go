@@ -0,0 +1,150 @@
+package handlers_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"time"
+
+	"code.cloudfoundry.org/gorouter/config"
+	"code.cloudfoundry.org/gorouter/handlers"
+	logger_fakes "code.cloudfoundry.org/gorouter/logger/fakes"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/urfave/negroni"
+)
+
+var _ = Describe("DomainProfiles", func() {
+	var (
+		handler     *negroni.Negroni
+		profiles    []config.DomainProfile
+		req         *http.Request
+		nextRequest *http.Request
+		reqInfo     *handlers.RequestInfo
+	)
+
+	BeforeEach(func() {
+		profiles = []config.DomainProfile{
+			{Domain: "example.com", MaxAttempts: 1},
+			{Domain: "sys.example.com", MaxAttempts: 2},
+		}
+		req = httptest.NewRequest("GET", "http://app.example.com/", nil)
+		nextRequest = nil
+		reqInfo = nil
+	})
+
+	JustBeforeEach(func() {
+		fakeLogger := new(logger_fakes.FakeLogger)
+		handler = negroni.New()
+		handler.Use(handlers.NewRequestInfo())
+		handler.Use(handlers.NewProxyWriter(fakeLogger))
+		handler.Use(handlers.NewDomainProfiles(profiles, fakeLogger))
+		handler.UseHandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+			nextRequest = r
+			var err error
+			reqInfo, err = handlers.ContextRequestInfo(r)
+			Expect(err).NotTo(HaveOccurred())
+			rw.Header().Set("Server", "backend")
+			rw.Header().Set("X-Powered-By", "backend")
+			rw.Write([]byte("backend"))
+		})
+	})
+
+	serve := func() *httptest.ResponseRecorder {
+		resp := httptest.NewRecorder()
+		handler.ServeHTTP(resp, req)
+		return resp
+	}
+
+	It("records the profile of the request's domain", func() {
+		serve()
+		Expect(reqInfo.DomainProfile).NotTo(BeNil())
+		Expect(reqInfo.DomainProfile.Domain).To(Equal("example.com"))
+	})
+
+	It("prefers the profile of the longest domain", func() {
+		req.Host = "api.SYS.example.com:443"
+		serve()
+		Expect(reqInfo.DomainProfile.Domain).To(Equal("sys.example.com"))
+	})
+
+	It("matches the domain itself", func() {
+		req.Host = "example.com"
+		serve()
+		Expect(reqInfo.DomainProfile.Domain).To(Equal("example.com"))
+	})
+
+	It("does not match other domains sharing the suffix", func() {
+		req.Host = "app.otherexample.com"
+		serve()
+		Expect(reqInfo.DomainProfile).To(BeNil())
+	})
+
+	Context("with header policies", func() {
+		BeforeEach(func() {
+			profiles[0].RequestHeaders = config.HeaderPolicy{
+				Remove: []string{"X-Debug"},
+				Set:    map[string]string{"X-Domain": "apps"},
+			}
+			profiles[0].ResponseHeaders = config.HeaderPolicy{
+				Remove: []string{"X-Powered-By"},
+				Set:    map[string]string{"Server": "gorouter"},
+			}
+			req.Header.Set("X-Debug", "true")
+		})
+
+		It("applies them to the request and response", func() {
+			resp := serve()
+			Expect(nextRequest.Header).NotTo(HaveKey("X-Debug"))
+			Expect(nextRequest.Header.Get("X-Domain")).To(Equal("apps"))
+
+			Expect(resp.Body.String()).To(Equal("backend"))
+			Expect(resp.Header()).NotTo(HaveKey("X-Powered-By"))
+			Expect(resp.Header().Get("Server")).To(Equal("gorouter"))
+		})
+	})
+
+	Context("with an endpoint timeout", func() {
+		BeforeEach(func() {
+			profiles[0].EndpointTimeout = time.Minute
+		})
+
+		It("bounds the request with a deadline", func() {
+			serve()
+			deadline, ok := nextRequest.Context().Deadline()
+			Expect(ok).To(BeTrue())
+			Expect(deadline).To(BeTemporally("~", time.Now().Add(time.Minute), time.Second))
+		})
+
+		It("does not bound upgraded connections", func() {
+			req.Header.Set("Upgrade", "websocket")
+			serve()
+			_, ok := nextRequest.Context().Deadline()
+			Expect(ok).To(BeFalse())
+		})
+
+		It("cancels the deadline once the request is served", func() {
+			serve()
+			Expect(nextRequest.Context().Err()).To(Equal(context.Canceled))
+		})
+	})
+
+	Describe("LogAccess", func() {
+		It("logs all requests without a profile", func() {
+			Expect(handlers.LogAccess(nil, http.StatusOK)).To(BeTrue())
+		})
+
+		It("logs only failed requests with errors", func() {
+			profile := &config.DomainProfile{AccessLog: config.DOMAIN_ACCESS_LOG_ERRORS}
+			Expect(handlers.LogAccess(profile, http.StatusOK)).To(BeFalse())
+			Expect(handlers.LogAccess(profile, http.StatusNotFound)).To(BeTrue())
+			Expect(handlers.LogAccess(profile, http.StatusBadGateway)).To(BeTrue())
+		})
+
+		It("logs no requests with none", func() {
+			profile := &config.DomainProfile{AccessLog: config.DOMAIN_ACCESS_LOG_NONE}
+			Expect(handlers.LogAccess(profile, http.StatusBadGateway)).To(BeFalse())
+		})
+	})
+})
@@ -0,0 +1,51 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/uber-go/zap"
+	"github.com/urfave/negroni"
+
+	router_http "code.cloudfoundry.org/gorouter/common/http"
+	"code.cloudfoundry.org/gorouter/logger"
+)
+
+// statusTooEarly asks a client to retry a request it sent as TLS early data
+// once its handshake has completed (RFC 8470)
+const statusTooEarly = 425
+
+type earlyData struct {
+	allowIdempotent bool
+	logger          logger.Logger
+}
+
+// NewEarlyData creates a handler guarding against replays of requests sent as
+// TLS 0-RTT early data, which an attacker can capture and send again. Such
+// requests are rejected with a 425, unless allowIdempotent is set and
+// replaying the request cannot change anything.
+func NewEarlyData(allowIdempotent bool, logger logger.Logger) negroni.Handler {
+	return &earlyData{
+		allowIdempotent: allowIdempotent,
+		logger:          logger,
+	}
+}
+
+func (e *earlyData) ServeHTTP(rw http.ResponseWriter, r *http.Request, next http.HandlerFunc) {
+	if r.Header.Get(router_http.EarlyDataHeader) != "1" || (e.allowIdempotent && isIdempotent(r.Method)) {
+		next(rw, r)
+		return
+	}
+
+	e.logger.Info("early-data-rejected", zap.String("method", r.Method))
+	rw.Header().Set(router_http.CfRouterError, "too_early")
+	http.Error(rw, "425 Too Early: Request must not be sent as TLS early data.", statusTooEarly)
+	rw.Header().Del("Connection")
+}
+
+func isIdempotent(method string) bool {
+	switch method {
+	case "GET", "HEAD", "OPTIONS", "TRACE", "PUT", "DELETE":
+		return true
+	}
+	return false
+}
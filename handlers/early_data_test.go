@@ -0,0 +1,74 @@
+package handlers_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+
+	"code.cloudfoundry.org/gorouter/handlers"
+	"code.cloudfoundry.org/gorouter/test_util"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/urfave/negroni"
+)
+
+var _ = Describe("EarlyData", func() {
+	var (
+		allowIdempotent bool
+		nextCalled      bool
+	)
+
+	serve := func(method string, early bool) *httptest.ResponseRecorder {
+		handler := negroni.New()
+		handler.Use(handlers.NewEarlyData(allowIdempotent, test_util.NewTestZapLogger("early-data")))
+		handler.UseHandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+			nextCalled = true
+		})
+
+		req := test_util.NewRequest(method, "example.com", "/", nil)
+		if early {
+			req.Header.Set("Early-Data", "1")
+		}
+		resp := httptest.NewRecorder()
+		handler.ServeHTTP(resp, req)
+		return resp
+	}
+
+	BeforeEach(func() {
+		allowIdempotent = false
+		nextCalled = false
+	})
+
+	It("serves requests that were not sent as early data", func() {
+		serve("POST", false)
+		Expect(nextCalled).To(BeTrue())
+	})
+
+	It("rejects early data when it is not enabled", func() {
+		resp := serve("GET", true)
+		Expect(nextCalled).To(BeFalse())
+		Expect(resp.Code).To(Equal(425))
+		Expect(resp.Header().Get("X-Cf-RouterError")).To(Equal("too_early"))
+	})
+
+	Context("when early data is enabled", func() {
+		BeforeEach(func() {
+			allowIdempotent = true
+		})
+
+		It("serves idempotent requests", func() {
+			for _, method := range []string{"GET", "HEAD", "OPTIONS", "PUT", "DELETE"} {
+				nextCalled = false
+				Expect(serve(method, true).Code).To(Equal(http.StatusOK))
+				Expect(nextCalled).To(BeTrue(), method)
+			}
+		})
+
+		It("rejects requests a replay could repeat the effects of", func() {
+			for _, method := range []string{"POST", "PATCH"} {
+				Expect(serve(method, true).Code).To(Equal(425), method)
+			}
+			Expect(nextCalled).To(BeFalse())
+		})
+	})
+})
@@ -0,0 +1,113 @@
+package handlers
+
+import (
+	"hash/fnv"
+	"net"
+	"net/http"
+	"strings"
+
+	"github.com/uber-go/zap"
+	"github.com/urfave/negroni"
+
+	router_http "code.cloudfoundry.org/gorouter/common/http"
+	"code.cloudfoundry.org/gorouter/config"
+	"code.cloudfoundry.org/gorouter/logger"
+	"code.cloudfoundry.org/gorouter/route"
+)
+
+type experimentHandler struct {
+	experiments []config.ExperimentConfig
+	logger      logger.Logger
+}
+
+// NewExperiments creates a handler assigning the clients of the routes of an
+// experiment to one of its buckets. The bucket is sent to the backend in the
+// X-Experiment-Bucket header, replacing any the client sent, and steers the
+// request to the endpoints tagged with it when the experiment names a tag. It
+// must run after the route is looked up. The first experiment matching the
+// host of a request applies.
+func NewExperiments(experiments []config.ExperimentConfig, logger logger.Logger) negroni.Handler {
+	return &experimentHandler{
+		experiments: experiments,
+		logger:      logger,
+	}
+}
+
+func (e *experimentHandler) ServeHTTP(rw http.ResponseWriter, r *http.Request, next http.HandlerFunc) {
+	experiment := e.experimentFor(strings.ToLower(hostWithoutPort(r.Host)))
+	if experiment == nil {
+		next(rw, r)
+		return
+	}
+
+	bucket := assignBucket(experiment, experimentKey(experiment, r))
+	r.Header.Set(router_http.ExperimentBucketHeader, bucket)
+
+	if experiment.Tag != "" {
+		requestInfo, err := ContextRequestInfo(r)
+		if err != nil {
+			e.logger.Fatal("request-info-err", zap.Error(err))
+			return
+		}
+		if pool := requestInfo.RoutePool; pool != nil {
+			steered := pool.Filter(func(endpoint *route.Endpoint) bool {
+				return endpoint.Tags[experiment.Tag] == bucket
+			})
+			if steered != nil {
+				requestInfo.RoutePool = steered
+			}
+		}
+	}
+
+	next(rw, r)
+}
+
+func (e *experimentHandler) experimentFor(host string) *config.ExperimentConfig {
+	for i := range e.experiments {
+		pattern := e.experiments[i].Host
+		if pattern == host || (strings.HasPrefix(pattern, "*.") && strings.HasSuffix(host, pattern[1:])) {
+			return &e.experiments[i]
+		}
+	}
+	return nil
+}
+
+// experimentKey identifies the client of r: by the value of the cookie of the
+// experiment when it is sent, else by IP
+func experimentKey(experiment *config.ExperimentConfig, r *http.Request) string {
+	if experiment.Cookie != "" {
+		if cookie, err := r.Cookie(experiment.Cookie); err == nil && cookie.Value != "" {
+			return "cookie:" + cookie.Value
+		}
+	}
+
+	ip, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		ip = r.RemoteAddr
+	}
+	return "ip:" + ip
+}
+
+// assignBucket picks the bucket of key in proportion to the bucket weights.
+// The experiment name is hashed along with the key, so that clients are
+// assigned independently in each experiment.
+func assignBucket(experiment *config.ExperimentConfig, key string) string {
+	total := 0
+	for _, bucket := range experiment.Buckets {
+		total += bucket.Weight
+	}
+
+	h := fnv.New32a()
+	h.Write([]byte(experiment.Name))
+	h.Write([]byte{0})
+	h.Write([]byte(key))
+	point := int(h.Sum32() % uint32(total))
+
+	for _, bucket := range experiment.Buckets {
+		if point < bucket.Weight {
+			return bucket.Name
+		}
+		point -= bucket.Weight
+	}
+	return experiment.Buckets[len(experiment.Buckets)-1].Name
+}
@@ -0,0 +1,139 @@
+package handlers_test
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"time"
+
+	router_http "code.cloudfoundry.org/gorouter/common/http"
+	"code.cloudfoundry.org/gorouter/config"
+	"code.cloudfoundry.org/gorouter/handlers"
+	logger_fakes "code.cloudfoundry.org/gorouter/logger/fakes"
+	"code.cloudfoundry.org/gorouter/route"
+	"code.cloudfoundry.org/routing-api/models"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/urfave/negroni"
+)
+
+var _ = Describe("Experiments", func() {
+	var (
+		handler     *negroni.Negroni
+		experiments []config.ExperimentConfig
+		pool        *route.Pool
+		req         *http.Request
+		nextRequest *http.Request
+		reqInfo     *handlers.RequestInfo
+	)
+
+	BeforeEach(func() {
+		experiments = []config.ExperimentConfig{{
+			Name:    "checkout",
+			Host:    "*.example.com",
+			Cookie:  "session",
+			Buckets: []config.ExperimentBucket{{Name: "control", Weight: 1}, {Name: "treatment", Weight: 1}},
+		}}
+		pool = route.NewPool(2*time.Minute, "")
+		pool.Put(route.NewEndpoint("app", "1.1.1.1", 8080, "", "", map[string]string{"bucket": "control"}, -1, "", models.ModificationTag{}, ""))
+		pool.Put(route.NewEndpoint("app", "2.2.2.2", 8080, "", "", map[string]string{"bucket": "treatment"}, -1, "", models.ModificationTag{}, ""))
+		req = httptest.NewRequest("GET", "http://app.example.com/", nil)
+		nextRequest = nil
+	})
+
+	JustBeforeEach(func() {
+		fakeLogger := new(logger_fakes.FakeLogger)
+		handler = negroni.New()
+		handler.Use(handlers.NewRequestInfo())
+		handler.UseFunc(func(rw http.ResponseWriter, r *http.Request, next http.HandlerFunc) {
+			info, err := handlers.ContextRequestInfo(r)
+			Expect(err).NotTo(HaveOccurred())
+			info.RoutePool = pool
+			next(rw, r)
+		})
+		handler.Use(handlers.NewExperiments(experiments, fakeLogger))
+		handler.UseHandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+			nextRequest = r
+			var err error
+			reqInfo, err = handlers.ContextRequestInfo(r)
+			Expect(err).NotTo(HaveOccurred())
+		})
+	})
+
+	bucketOf := func(r *http.Request) string {
+		handler.ServeHTTP(httptest.NewRecorder(), r)
+		return nextRequest.Header.Get(router_http.ExperimentBucketHeader)
+	}
+
+	It("assigns the client to a bucket", func() {
+		Expect(bucketOf(req)).To(Or(Equal("control"), Equal("treatment")))
+		Expect(reqInfo.RoutePool).To(BeIdenticalTo(pool))
+	})
+
+	It("keeps the client in its bucket", func() {
+		req.AddCookie(&http.Cookie{Name: "session", Value: "abc"})
+		bucket := bucketOf(req)
+
+		for i := 0; i < 10; i++ {
+			again := httptest.NewRequest("GET", "http://app.example.com/", nil)
+			again.RemoteAddr = fmt.Sprintf("10.0.0.%d:1234", i)
+			again.AddCookie(&http.Cookie{Name: "session", Value: "abc"})
+			Expect(bucketOf(again)).To(Equal(bucket))
+		}
+	})
+
+	It("assigns clients without the cookie by IP", func() {
+		req.RemoteAddr = "10.0.0.1:1234"
+		bucket := bucketOf(req)
+
+		again := httptest.NewRequest("GET", "http://app.example.com/", nil)
+		again.RemoteAddr = "10.0.0.1:5678"
+		Expect(bucketOf(again)).To(Equal(bucket))
+	})
+
+	It("replaces the bucket the client sent", func() {
+		req.Header.Set(router_http.ExperimentBucketHeader, "anything")
+		Expect(bucketOf(req)).NotTo(Equal("anything"))
+	})
+
+	It("leaves requests for other hosts alone", func() {
+		req = httptest.NewRequest("GET", "http://app.other.com/", nil)
+		Expect(bucketOf(req)).To(BeEmpty())
+	})
+
+	Context("with weighted buckets", func() {
+		BeforeEach(func() {
+			experiments[0].Buckets = []config.ExperimentBucket{{Name: "control", Weight: 3}, {Name: "treatment", Weight: 1}}
+		})
+
+		It("assigns clients in proportion to the weights", func() {
+			counts := map[string]int{}
+			for i := 0; i < 2000; i++ {
+				r := httptest.NewRequest("GET", "http://app.example.com/", nil)
+				r.RemoteAddr = fmt.Sprintf("10.%d.%d.1:1234", i/250, i%250)
+				counts[bucketOf(r)]++
+			}
+			Expect(counts["control"]).To(BeNumerically("~", 1500, 150))
+			Expect(counts["treatment"]).To(BeNumerically("~", 500, 150))
+		})
+	})
+
+	Context("with a tag", func() {
+		BeforeEach(func() {
+			experiments[0].Tag = "bucket"
+		})
+
+		It("steers the request to the endpoints of its bucket", func() {
+			bucket := bucketOf(req)
+			Expect(reqInfo.RoutePool.NumEndpoints()).To(Equal(1))
+			Expect(reqInfo.RoutePool.Endpoints("", "").Next().Tags["bucket"]).To(Equal(bucket))
+		})
+
+		It("keeps the pool when no endpoint is tagged with the bucket", func() {
+			experiments[0].Buckets = []config.ExperimentBucket{{Name: "other", Weight: 1}}
+			Expect(bucketOf(req)).To(Equal("other"))
+			Expect(reqInfo.RoutePool).To(BeIdenticalTo(pool))
+		})
+	})
+})
@@ -0,0 +1,71 @@
+package handlers
+
+import (
+	"net"
+	"net/http"
+	"strings"
+
+	router_http "code.cloudfoundry.org/gorouter/common/http"
+	"code.cloudfoundry.org/gorouter/geoip"
+	"code.cloudfoundry.org/gorouter/logger"
+
+	"github.com/uber-go/zap"
+	"github.com/urfave/negroni"
+)
+
+// GeoLocator looks up the location of a client address
+type GeoLocator interface {
+	Lookup(ip net.IP) geoip.Location
+}
+
+type geoIP struct {
+	locator          GeoLocator
+	addHeader        bool
+	useXForwardedFor bool
+	logger           logger.Logger
+}
+
+// NewGeoIP creates a handler that records the location of the client for the
+// access log. With addHeader the country is passed on to backends in the
+// X-Geo-Country header; a value sent by the client is never forwarded.
+func NewGeoIP(locator GeoLocator, addHeader, useXForwardedFor bool, logger logger.Logger) negroni.Handler {
+	return &geoIP{
+		locator:          locator,
+		addHeader:        addHeader,
+		useXForwardedFor: useXForwardedFor,
+		logger:           logger,
+	}
+}
+
+func (g *geoIP) ServeHTTP(rw http.ResponseWriter, r *http.Request, next http.HandlerFunc) {
+	requestInfo, err := ContextRequestInfo(r)
+	if err != nil {
+		g.logger.Fatal("request-info-err", zap.Error(err))
+		return
+	}
+
+	r.Header.Del(router_http.GeoCountryHeader)
+
+	if ip := g.clientIP(r); ip != nil {
+		requestInfo.ClientLocation = g.locator.Lookup(ip)
+		if g.addHeader && requestInfo.ClientLocation.Country != "" {
+			r.Header.Set(router_http.GeoCountryHeader, requestInfo.ClientLocation.Country)
+		}
+	}
+
+	next(rw, r)
+}
+
+func (g *geoIP) clientIP(r *http.Request) net.IP {
+	if g.useXForwardedFor {
+		if xff := r.Header.Get("X-Forwarded-For"); xff != "" {
+			return net.ParseIP(strings.TrimSpace(strings.Split(xff, ",")[0]))
+		}
+	}
+
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+	return net.ParseIP(host)
+}
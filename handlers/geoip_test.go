@@ -0,0 +1,108 @@
+package handlers_test
+
+import (
+	"net"
+	"net/http"
+	"net/http/httptest"
+
+	router_http "code.cloudfoundry.org/gorouter/common/http"
+	"code.cloudfoundry.org/gorouter/geoip"
+	"code.cloudfoundry.org/gorouter/handlers"
+	logger_fakes "code.cloudfoundry.org/gorouter/logger/fakes"
+	"code.cloudfoundry.org/gorouter/test_util"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/urfave/negroni"
+)
+
+type fakeGeoLocator map[string]geoip.Location
+
+func (f fakeGeoLocator) Lookup(ip net.IP) geoip.Location {
+	return f[ip.String()]
+}
+
+var _ = Describe("GeoIP", func() {
+	var (
+		addHeader bool
+		useXFF    bool
+		req       *http.Request
+		location  geoip.Location
+		header    string
+	)
+
+	BeforeEach(func() {
+		addHeader = false
+		useXFF = false
+		req = test_util.NewRequest("GET", "app.example.com", "/", nil)
+		req.RemoteAddr = "81.2.69.160:4567"
+		req.Header.Set(router_http.GeoCountryHeader, "forged")
+	})
+
+	JustBeforeEach(func() {
+		locator := fakeGeoLocator{
+			"81.2.69.160": {Country: "GB", Region: "ENG"},
+			"2001:db8::1": {Country: "US", Region: "CA"},
+		}
+
+		handler := negroni.New()
+		handler.Use(handlers.NewRequestInfo())
+		handler.Use(handlers.NewGeoIP(locator, addHeader, useXFF, new(logger_fakes.FakeLogger)))
+		handler.UseHandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+			requestInfo, err := handlers.ContextRequestInfo(req)
+			Expect(err).NotTo(HaveOccurred())
+			location = requestInfo.ClientLocation
+			header = req.Header.Get(router_http.GeoCountryHeader)
+		})
+		handler.ServeHTTP(httptest.NewRecorder(), req)
+	})
+
+	It("records the location of the client", func() {
+		Expect(location).To(Equal(geoip.Location{Country: "GB", Region: "ENG"}))
+	})
+
+	It("does not forward the country header sent by the client", func() {
+		Expect(header).To(BeEmpty())
+	})
+
+	Context("when the header is enabled", func() {
+		BeforeEach(func() {
+			addHeader = true
+		})
+
+		It("sends the country to the backend", func() {
+			Expect(header).To(Equal("GB"))
+		})
+
+		Context("and the client cannot be located", func() {
+			BeforeEach(func() {
+				req.RemoteAddr = "10.0.0.1:4567"
+			})
+
+			It("sends no country", func() {
+				Expect(location).To(Equal(geoip.Location{}))
+				Expect(header).To(BeEmpty())
+			})
+		})
+	})
+
+	Context("when the request is forwarded", func() {
+		BeforeEach(func() {
+			req.Header.Set("X-Forwarded-For", "2001:db8::1, 10.0.0.2")
+		})
+
+		It("locates the connection's address by default", func() {
+			Expect(location.Country).To(Equal("GB"))
+		})
+
+		Context("and X-Forwarded-For is used", func() {
+			BeforeEach(func() {
+				useXFF = true
+			})
+
+			It("locates the first forwarded address", func() {
+				Expect(location).To(Equal(geoip.Location{Country: "US", Region: "CA"}))
+			})
+		})
+	})
+})
@@ -2,10 +2,12 @@ package handlers
 
 import (
 	"fmt"
+	"net"
 	"net/http"
 	"strings"
 
 	"code.cloudfoundry.org/gorouter/logger"
+	"code.cloudfoundry.org/gorouter/route"
 	"github.com/uber-go/zap"
 )
 
@@ -20,6 +22,15 @@ func writeStatus(rw http.ResponseWriter, code int, message string, logger logger
 	}
 }
 
+// routeName identifies the route pool a request was routed by, for metrics
+func routeName(r *http.Request, pool *route.Pool) string {
+	name := strings.ToLower(hostWithoutPort(r.Host))
+	if contextPath := pool.ContextPath(); contextPath != "/" {
+		name += contextPath
+	}
+	return name
+}
+
 func hostWithoutPort(reqHost string) string {
 	host := reqHost
 
@@ -31,3 +42,23 @@ func hostWithoutPort(reqHost string) string {
 
 	return host
 }
+
+// remoteIPIn reports whether the client address of a request is in one of the
+// networks
+func remoteIPIn(remoteAddr string, networks []*net.IPNet) bool {
+	host, _, err := net.SplitHostPort(remoteAddr)
+	if err != nil {
+		host = remoteAddr
+	}
+	ip := net.ParseIP(host)
+	if ip == nil {
+		return false
+	}
+
+	for _, network := range networks {
+		if network.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
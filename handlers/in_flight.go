@@ -0,0 +1,57 @@
+package handlers
+
+import (
+	"net/http"
+
+	"code.cloudfoundry.org/gorouter/logger"
+	"code.cloudfoundry.org/gorouter/metrics"
+
+	"github.com/uber-go/zap"
+	"github.com/urfave/negroni"
+)
+
+type inFlight struct {
+	tracker *metrics.InFlightTracker
+}
+
+// NewInFlight creates a handler that counts every request it sees as in
+// flight until the request is done
+func NewInFlight(tracker *metrics.InFlightTracker) negroni.Handler {
+	return &inFlight{tracker: tracker}
+}
+
+func (i *inFlight) ServeHTTP(rw http.ResponseWriter, r *http.Request, next http.HandlerFunc) {
+	done := i.tracker.StartRequest()
+	defer done()
+
+	next(rw, r)
+}
+
+type routeInFlight struct {
+	tracker *metrics.InFlightTracker
+	logger  logger.Logger
+}
+
+// NewRouteInFlight creates a handler that counts requests as in flight to the
+// route they were looked up by. It must follow the lookup handler.
+func NewRouteInFlight(tracker *metrics.InFlightTracker, logger logger.Logger) negroni.Handler {
+	return &routeInFlight{tracker: tracker, logger: logger}
+}
+
+func (i *routeInFlight) ServeHTTP(rw http.ResponseWriter, r *http.Request, next http.HandlerFunc) {
+	requestInfo, err := ContextRequestInfo(r)
+	if err != nil {
+		i.logger.Error("request-info-err", zap.Error(err))
+		next(rw, r)
+		return
+	}
+	if requestInfo.RoutePool == nil {
+		next(rw, r)
+		return
+	}
+
+	done := i.tracker.StartRoute(routeName(r, requestInfo.RoutePool))
+	defer done()
+
+	next(rw, r)
+}
@@ -0,0 +1,64 @@
+package handlers_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"time"
+
+	"code.cloudfoundry.org/gorouter/handlers"
+	logger_fakes "code.cloudfoundry.org/gorouter/logger/fakes"
+	"code.cloudfoundry.org/gorouter/metrics"
+	"code.cloudfoundry.org/gorouter/route"
+	"code.cloudfoundry.org/gorouter/test_util"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/urfave/negroni"
+)
+
+var _ = Describe("InFlight", func() {
+	var (
+		handler  *negroni.Negroni
+		tracker  *metrics.InFlightTracker
+		pool     *route.Pool
+		snapshot metrics.InFlightSnapshot
+	)
+
+	BeforeEach(func() {
+		tracker = metrics.NewInFlightTracker(time.Second)
+		pool = route.NewPool(0, "")
+
+		fakeLogger := new(logger_fakes.FakeLogger)
+		handler = negroni.New()
+		handler.Use(handlers.NewRequestInfo())
+		handler.Use(handlers.NewInFlight(tracker))
+		handler.UseHandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+			reqInfo, err := handlers.ContextRequestInfo(req)
+			Expect(err).NotTo(HaveOccurred())
+			reqInfo.RoutePool = pool
+		})
+		handler.Use(handlers.NewRouteInFlight(tracker, fakeLogger))
+		handler.UseHandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+			snapshot = tracker.Snapshot()
+		})
+	})
+
+	It("counts the request in total and for its route while it is served", func() {
+		handler.ServeHTTP(httptest.NewRecorder(), test_util.NewRequest("GET", "App.Example.com", "/", nil))
+
+		Expect(snapshot.Total).To(BeEquivalentTo(1))
+		Expect(snapshot.Routes).To(Equal(map[string]int64{"app.example.com": 1}))
+		Expect(tracker.Snapshot()).To(Equal(metrics.InFlightSnapshot{
+			Routes:    map[string]int64{},
+			Endpoints: map[string]int64{},
+		}))
+	})
+
+	It("only counts the total for requests that were not routed", func() {
+		pool = nil
+		handler.ServeHTTP(httptest.NewRecorder(), test_util.NewRequest("GET", "app.example.com", "/", nil))
+
+		Expect(snapshot.Total).To(BeEquivalentTo(1))
+		Expect(snapshot.Routes).To(BeEmpty())
+	})
+})
@@ -0,0 +1,58 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/urfave/negroni"
+)
+
+// Shedder decides whether the router is too loaded to take another request.
+// ShedLowPriority is expected to shed earlier than Shed.
+type Shedder interface {
+	Shed() (reason string, shed bool)
+	ShedLowPriority() (reason string, shed bool)
+}
+
+type loadShedding struct {
+	shedder    Shedder
+	retryAfter string
+}
+
+// NewLoadShedding creates a handler that rejects requests with a 503 and a
+// Retry-After header while shedder says so. Shed requests are not logged
+// individually, as shedding happens when the router can least afford it.
+func NewLoadShedding(shedder Shedder, retryAfter time.Duration) negroni.Handler {
+	return &loadShedding{
+		shedder:    shedder,
+		retryAfter: strconv.Itoa(int(retryAfter.Seconds())),
+	}
+}
+
+func (l *loadShedding) ServeHTTP(rw http.ResponseWriter, r *http.Request, next http.HandlerFunc) {
+	shedFunc := l.shedder.Shed
+	if requestInfo, err := ContextRequestInfo(r); err == nil && requestInfo.Priority == PriorityLow {
+		shedFunc = l.shedder.ShedLowPriority
+	}
+
+	reason, shed := shedFunc()
+	if !shed {
+		next(rw, r)
+		return
+	}
+
+	writeOverloaded(rw, l.retryAfter, "load_shedding", fmt.Sprintf("Router is overloaded (%s).", reason))
+}
+
+// writeOverloaded rejects a request with a 503 asking the client to retry
+// later. Like the shedding itself, it does not log.
+func writeOverloaded(rw http.ResponseWriter, retryAfter, routerError, message string) {
+	rw.Header().Set("Retry-After", retryAfter)
+	rw.Header().Set("X-Cf-RouterError", routerError)
+	body := fmt.Sprintf("%d %s: %s", http.StatusServiceUnavailable,
+		http.StatusText(http.StatusServiceUnavailable), message)
+	http.Error(rw, body, http.StatusServiceUnavailable)
+	rw.Header().Del("Connection")
+}
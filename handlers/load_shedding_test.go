@@ -0,0 +1,101 @@
+package handlers_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"time"
+
+	"code.cloudfoundry.org/gorouter/handlers"
+	"code.cloudfoundry.org/gorouter/test_util"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/urfave/negroni"
+)
+
+type fakeShedder struct {
+	reason            string
+	lowPriorityReason string
+}
+
+func (f *fakeShedder) Shed() (string, bool) {
+	return f.reason, f.reason != ""
+}
+
+func (f *fakeShedder) ShedLowPriority() (string, bool) {
+	return f.lowPriorityReason, f.lowPriorityReason != ""
+}
+
+var _ = Describe("LoadShedding", func() {
+	var (
+		handler    *negroni.Negroni
+		shedder    *fakeShedder
+		resp       *httptest.ResponseRecorder
+		nextCalled bool
+		priority   handlers.Priority
+	)
+
+	BeforeEach(func() {
+		shedder = &fakeShedder{}
+		nextCalled = false
+		priority = handlers.PriorityNormal
+
+		handler = negroni.New()
+		handler.Use(handlers.NewRequestInfo())
+		handler.UseFunc(func(rw http.ResponseWriter, req *http.Request, next http.HandlerFunc) {
+			requestInfo, err := handlers.ContextRequestInfo(req)
+			Expect(err).NotTo(HaveOccurred())
+			requestInfo.Priority = priority
+			next(rw, req)
+		})
+		handler.Use(handlers.NewLoadShedding(shedder, 5*time.Second))
+		handler.UseHandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+			nextCalled = true
+		})
+	})
+
+	JustBeforeEach(func() {
+		resp = httptest.NewRecorder()
+		handler.ServeHTTP(resp, test_util.NewRequest("GET", "example.com", "/", nil))
+	})
+
+	It("passes requests on while the router is not overloaded", func() {
+		Expect(nextCalled).To(BeTrue())
+		Expect(resp.Code).To(Equal(http.StatusOK))
+	})
+
+	Context("when the shedder sheds", func() {
+		BeforeEach(func() {
+			shedder.reason = "goroutines"
+		})
+
+		It("rejects the request with a 503 and Retry-After", func() {
+			Expect(nextCalled).To(BeFalse())
+			Expect(resp.Code).To(Equal(http.StatusServiceUnavailable))
+			Expect(resp.Header().Get("Retry-After")).To(Equal("5"))
+			Expect(resp.Header().Get("X-Cf-RouterError")).To(Equal("load_shedding"))
+			Expect(resp.Body.String()).To(ContainSubstring("goroutines"))
+		})
+	})
+
+	Context("when only low priority requests are shed", func() {
+		BeforeEach(func() {
+			shedder.lowPriorityReason = "goroutines"
+		})
+
+		It("passes normal priority requests on", func() {
+			Expect(nextCalled).To(BeTrue())
+		})
+
+		Context("when the request has low priority", func() {
+			BeforeEach(func() {
+				priority = handlers.PriorityLow
+			})
+
+			It("rejects the request", func() {
+				Expect(nextCalled).To(BeFalse())
+				Expect(resp.Code).To(Equal(http.StatusServiceUnavailable))
+			})
+		})
+	})
+})
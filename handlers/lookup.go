@@ -3,6 +3,7 @@ package handlers
 import (
 	"net/http"
 	"strings"
+	"time"
 
 	"fmt"
 
@@ -18,6 +19,9 @@ import (
 const (
 	CfInstanceIdHeader = "X-CF-InstanceID"
 	CfAppInstance      = "X-CF-APP-INSTANCE"
+
+	stickyCookieKey = "JSESSIONID"
+	vcapCookieId    = "__VCAP_ID__"
 )
 
 type lookupHandler struct {
@@ -35,17 +39,23 @@ func NewLookup(registry registry.Registry, rep metrics.CombinedReporter, logger
 	}
 }
 
+// stepsLookup is implemented by registries able to report the route keys a
+// lookup tried
+type stepsLookup interface {
+	LookupSteps(uri route.Uri) (*route.Pool, []route.Uri)
+}
+
 func (l *lookupHandler) ServeHTTP(rw http.ResponseWriter, r *http.Request, next http.HandlerFunc) {
-	pool := l.lookup(r)
-	if pool == nil {
-		l.handleMissingRoute(rw, r)
-		return
-	}
 	requestInfo, err := ContextRequestInfo(r)
 	if err != nil {
 		l.logger.Fatal("request-info-err", zap.Error(err))
 		return
 	}
+	pool := l.lookup(r, requestInfo.RouteDebug)
+	if pool == nil {
+		l.handleMissingRoute(rw, r)
+		return
+	}
 	requestInfo.RoutePool = pool
 	next(rw, r)
 }
@@ -64,7 +74,7 @@ func (l *lookupHandler) handleMissingRoute(rw http.ResponseWriter, r *http.Reque
 	)
 }
 
-func (l *lookupHandler) lookup(r *http.Request) *route.Pool {
+func (l *lookupHandler) lookup(r *http.Request, debug *RouteDebug) *route.Pool {
 	requestPath := r.URL.EscapedPath()
 
 	uri := route.Uri(hostWithoutPort(r.Host) + requestPath)
@@ -81,7 +91,38 @@ func (l *lookupHandler) lookup(r *http.Request) *route.Pool {
 		return l.registry.LookupWithInstance(uri, appID, appIndex)
 	}
 
-	return l.registry.Lookup(uri)
+	var pool *route.Pool
+	if steps, ok := l.registry.(stepsLookup); ok && debug != nil {
+		started := time.Now()
+		pool, debug.LookupSteps = steps.LookupSteps(uri)
+		debug.LookupTime = time.Since(started)
+	} else {
+		pool = l.registry.Lookup(uri)
+	}
+	if pool == nil {
+		return nil
+	}
+	// a route left with quarantined endpoints only serves the sessions pinned
+	// to them
+	if pool.Quarantined() && pool.FindByPrivateInstanceId(stickySession(r)) == nil {
+		return nil
+	}
+	pool = pool.MatchQuery(r.URL.RawQuery)
+	if debug != nil && pool != nil {
+		pool.Each(func(*route.Endpoint) { debug.PoolSize++ })
+	}
+	return pool
+}
+
+// stickySession returns the instance the sticky session of r is pinned to, if
+// any
+func stickySession(r *http.Request) string {
+	if _, err := r.Cookie(stickyCookieKey); err == nil {
+		if sticky, err := r.Cookie(vcapCookieId); err == nil {
+			return sticky.Value
+		}
+	}
+	return ""
 }
 
 func validateCfAppInstance(appInstanceHeader string) (string, string, error) {
@@ -11,6 +11,7 @@ import (
 	fakeRegistry "code.cloudfoundry.org/gorouter/registry/fakes"
 	"code.cloudfoundry.org/gorouter/route"
 	"code.cloudfoundry.org/gorouter/test_util"
+	"code.cloudfoundry.org/routing-api/models"
 
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/gomega"
@@ -92,6 +93,77 @@ var _ = Describe("Lookup", func() {
 			Expect(requestInfo.RoutePool).To(Equal(pool))
 		})
 
+		Context("when endpoints match on query parameters", func() {
+			var v2 *route.Endpoint
+
+			BeforeEach(func() {
+				pool.Put(route.NewEndpoint("app", "1.1.1.1", 8080, "", "", nil, -1, "", models.ModificationTag{}, ""))
+				v2 = route.NewEndpoint("app", "2.2.2.2", 8080, "", "", nil, -1, "", models.ModificationTag{}, "")
+				v2.QueryMatch = map[string]string{"version": "2"}
+				pool.Put(v2)
+			})
+
+			Context("when the request carries the parameters", func() {
+				BeforeEach(func() {
+					req = test_util.NewRequest("GET", "example.com", "/?version=2", nil)
+				})
+
+				It("calls next with the matching endpoints", func() {
+					Expect(nextCalled).To(BeTrue())
+					requestInfo, err := handlers.ContextRequestInfo(nextRequest)
+					Expect(err).ToNot(HaveOccurred())
+
+					var addrs []string
+					requestInfo.RoutePool.Each(func(e *route.Endpoint) {
+						addrs = append(addrs, e.CanonicalAddr())
+					})
+					Expect(addrs).To(ConsistOf("2.2.2.2:8080"))
+				})
+			})
+
+			Context("when the request does not carry them", func() {
+				It("calls next with the other endpoints", func() {
+					Expect(nextCalled).To(BeTrue())
+					requestInfo, err := handlers.ContextRequestInfo(nextRequest)
+					Expect(err).ToNot(HaveOccurred())
+
+					var addrs []string
+					requestInfo.RoutePool.Each(func(e *route.Endpoint) {
+						addrs = append(addrs, e.CanonicalAddr())
+					})
+					Expect(addrs).To(ConsistOf("1.1.1.1:8080"))
+				})
+			})
+		})
+
+		Context("when all the endpoints are quarantined", func() {
+			BeforeEach(func() {
+				pool.Put(route.NewEndpoint("app", "1.1.1.1", 8080, "stale-id", "", nil, -1, "", models.ModificationTag{}, ""))
+				pool.MarkUpdated(time.Now().Add(-2 * time.Minute))
+				pool.QuarantineEndpoints(route.FixedStaleThreshold(time.Minute), time.Hour)
+			})
+
+			It("responds with 404 unknown_route", func() {
+				Expect(nextCalled).To(BeFalse())
+				Expect(resp.Code).To(Equal(http.StatusNotFound))
+				Expect(resp.Header().Get("X-Cf-RouterError")).To(Equal("unknown_route"))
+			})
+
+			Context("when the request is pinned to a quarantined instance", func() {
+				BeforeEach(func() {
+					req.AddCookie(&http.Cookie{Name: "JSESSIONID", Value: "session"})
+					req.AddCookie(&http.Cookie{Name: "__VCAP_ID__", Value: "stale-id"})
+				})
+
+				It("calls next with the pool", func() {
+					Expect(nextCalled).To(BeTrue())
+					requestInfo, err := handlers.ContextRequestInfo(nextRequest)
+					Expect(err).ToNot(HaveOccurred())
+					Expect(requestInfo.RoutePool).To(Equal(pool))
+				})
+			})
+		})
+
 		Context("when a specific instance is requested", func() {
 			BeforeEach(func() {
 				req.Header.Add("X-CF-App-Instance", "app-guid:instance-id")
@@ -0,0 +1,59 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/uber-go/zap"
+	"github.com/urfave/negroni"
+
+	router_http "code.cloudfoundry.org/gorouter/common/http"
+	"code.cloudfoundry.org/gorouter/logger"
+	"code.cloudfoundry.org/gorouter/metrics"
+)
+
+type methodAllowlist struct {
+	reporter metrics.CombinedReporter
+	logger   logger.Logger
+}
+
+// NewMethodAllowlist creates a handler rejecting requests whose method their
+// route was registered without, with a 405 listing the methods allowed. It
+// must follow the lookup handler.
+func NewMethodAllowlist(reporter metrics.CombinedReporter, logger logger.Logger) negroni.Handler {
+	return &methodAllowlist{
+		reporter: reporter,
+		logger:   logger,
+	}
+}
+
+func (m *methodAllowlist) ServeHTTP(rw http.ResponseWriter, r *http.Request, next http.HandlerFunc) {
+	requestInfo, err := ContextRequestInfo(r)
+	if err != nil {
+		m.logger.Fatal("request-info-err", zap.Error(err))
+		return
+	}
+
+	allowed := requestInfo.RoutePool.AllowedMethods()
+	if len(allowed) == 0 {
+		next(rw, r)
+		return
+	}
+	for _, method := range allowed {
+		if r.Method == method {
+			next(rw, r)
+			return
+		}
+	}
+
+	m.reporter.CaptureMethodNotAllowed()
+	rw.Header().Set("Allow", strings.Join(allowed, ", "))
+	rw.Header().Set(router_http.CfRouterError, "method_not_allowed")
+	writeStatus(
+		rw,
+		http.StatusMethodNotAllowed,
+		fmt.Sprintf("Method %s is not allowed on route ('%s').", r.Method, r.Host),
+		m.logger,
+	)
+}
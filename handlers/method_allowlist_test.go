@@ -0,0 +1,81 @@
+package handlers_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+
+	"code.cloudfoundry.org/gorouter/handlers"
+	logger_fakes "code.cloudfoundry.org/gorouter/logger/fakes"
+	metrics_fakes "code.cloudfoundry.org/gorouter/metrics/fakes"
+	"code.cloudfoundry.org/gorouter/route"
+	"code.cloudfoundry.org/gorouter/test_util"
+	"code.cloudfoundry.org/routing-api/models"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/urfave/negroni"
+)
+
+var _ = Describe("MethodAllowlist", func() {
+	var (
+		handler    *negroni.Negroni
+		pool       *route.Pool
+		reporter   *metrics_fakes.FakeCombinedReporter
+		nextCalled bool
+	)
+
+	BeforeEach(func() {
+		pool = route.NewPool(0, "")
+		reporter = new(metrics_fakes.FakeCombinedReporter)
+		nextCalled = false
+
+		fakeLogger := new(logger_fakes.FakeLogger)
+		handler = negroni.New()
+		handler.Use(handlers.NewRequestInfo())
+		handler.UseFunc(func(rw http.ResponseWriter, req *http.Request, next http.HandlerFunc) {
+			reqInfo, err := handlers.ContextRequestInfo(req)
+			Expect(err).NotTo(HaveOccurred())
+			reqInfo.RoutePool = pool
+			next(rw, req)
+		})
+		handler.Use(handlers.NewMethodAllowlist(reporter, fakeLogger))
+		handler.UseHandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+			nextCalled = true
+		})
+	})
+
+	register := func(methods ...string) {
+		endpoint := route.NewEndpoint("app", "1.2.3.4", 5678, "", "", nil, -1, "", models.ModificationTag{}, "")
+		endpoint.AllowedMethods = methods
+		pool.Put(endpoint)
+	}
+
+	serve := func(method string) *httptest.ResponseRecorder {
+		resp := httptest.NewRecorder()
+		handler.ServeHTTP(resp, test_util.NewRequest(method, "static.example.com", "/", nil))
+		return resp
+	}
+
+	It("allows every method on routes that do not restrict them", func() {
+		register()
+		serve("DELETE")
+		Expect(nextCalled).To(BeTrue())
+	})
+
+	It("allows the methods registered", func() {
+		register("GET", "HEAD")
+		serve("HEAD")
+		Expect(nextCalled).To(BeTrue())
+	})
+
+	It("rejects other methods with a 405 listing the methods allowed", func() {
+		register("GET", "HEAD")
+		resp := serve("POST")
+
+		Expect(nextCalled).To(BeFalse())
+		Expect(resp.Code).To(Equal(http.StatusMethodNotAllowed))
+		Expect(resp.Header().Get("Allow")).To(Equal("GET, HEAD"))
+		Expect(resp.Header().Get("X-Cf-RouterError")).To(Equal("method_not_allowed"))
+		Expect(reporter.CaptureMethodNotAllowedCallCount()).To(Equal(1))
+	})
+})
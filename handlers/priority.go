@@ -0,0 +1,97 @@
+package handlers
+
+import (
+	"net"
+	"net/http"
+	"strings"
+
+	"code.cloudfoundry.org/gorouter/config"
+	"code.cloudfoundry.org/gorouter/logger"
+
+	"github.com/uber-go/zap"
+	"github.com/urfave/negroni"
+)
+
+// Priority ranks requests under overload. Lower priorities are shed first.
+type Priority int
+
+const (
+	PriorityLow    Priority = -1
+	PriorityNormal Priority = 0
+	PriorityHigh   Priority = 1
+)
+
+// ParsePriority converts a priority class name to a Priority
+func ParsePriority(class string) (Priority, bool) {
+	switch strings.ToLower(class) {
+	case config.PRIORITY_LOW:
+		return PriorityLow, true
+	case config.PRIORITY_NORMAL:
+		return PriorityNormal, true
+	case config.PRIORITY_HIGH:
+		return PriorityHigh, true
+	}
+	return PriorityNormal, false
+}
+
+func (p Priority) String() string {
+	switch p {
+	case PriorityLow:
+		return config.PRIORITY_LOW
+	case PriorityHigh:
+		return config.PRIORITY_HIGH
+	}
+	return config.PRIORITY_NORMAL
+}
+
+type requestPriority struct {
+	defaultPriority Priority
+	routes          map[string]Priority
+	header          string
+	trusted         []*net.IPNet
+	logger          logger.Logger
+}
+
+// NewRequestPriority creates a handler that sets the Priority of the
+// RequestInfo from the priority of the requested host or, for requests from
+// trusted sources, from the priority header. The header is not forwarded.
+func NewRequestPriority(cfg config.RequestPriorityConfig, logger logger.Logger) negroni.Handler {
+	routes := make(map[string]Priority, len(cfg.Routes))
+	for host, class := range cfg.Routes {
+		routes[strings.ToLower(host)], _ = ParsePriority(class)
+	}
+	defaultPriority, _ := ParsePriority(cfg.Default)
+
+	return &requestPriority{
+		defaultPriority: defaultPriority,
+		routes:          routes,
+		header:          cfg.Header,
+		trusted:         cfg.TrustedNetworks,
+		logger:          logger,
+	}
+}
+
+func (p *requestPriority) ServeHTTP(rw http.ResponseWriter, r *http.Request, next http.HandlerFunc) {
+	requestInfo, err := ContextRequestInfo(r)
+	if err != nil {
+		p.logger.Fatal("request-info-err", zap.Error(err))
+		return
+	}
+
+	priority, ok := p.routes[strings.ToLower(hostWithoutPort(r.Host))]
+	if !ok {
+		priority = p.defaultPriority
+	}
+
+	if p.header != "" {
+		if class := r.Header.Get(p.header); class != "" {
+			if requested, ok := ParsePriority(class); ok && remoteIPIn(r.RemoteAddr, p.trusted) {
+				priority = requested
+			}
+			r.Header.Del(p.header)
+		}
+	}
+
+	requestInfo.Priority = priority
+	next(rw, r)
+}
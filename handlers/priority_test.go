@@ -0,0 +1,101 @@
+package handlers_test
+
+import (
+	"net"
+	"net/http"
+	"net/http/httptest"
+
+	"code.cloudfoundry.org/gorouter/config"
+	"code.cloudfoundry.org/gorouter/handlers"
+	logger_fakes "code.cloudfoundry.org/gorouter/logger/fakes"
+	"code.cloudfoundry.org/gorouter/test_util"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/urfave/negroni"
+)
+
+var _ = Describe("RequestPriority", func() {
+	var (
+		cfg      config.RequestPriorityConfig
+		req      *http.Request
+		priority handlers.Priority
+		header   string
+	)
+
+	BeforeEach(func() {
+		_, trusted, err := net.ParseCIDR("10.0.0.0/8")
+		Expect(err).NotTo(HaveOccurred())
+
+		cfg = config.RequestPriorityConfig{
+			Default:         "normal",
+			Routes:          map[string]string{"Batch.example.com": "low", "api.example.com": "high"},
+			Header:          "X-Cf-Priority",
+			TrustedNetworks: []*net.IPNet{trusted},
+		}
+		req = test_util.NewRequest("GET", "app.example.com", "/", nil)
+		req.RemoteAddr = "10.0.0.1:4567"
+	})
+
+	JustBeforeEach(func() {
+		handler := negroni.New()
+		handler.Use(handlers.NewRequestInfo())
+		handler.Use(handlers.NewRequestPriority(cfg, new(logger_fakes.FakeLogger)))
+		handler.UseHandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+			requestInfo, err := handlers.ContextRequestInfo(req)
+			Expect(err).NotTo(HaveOccurred())
+			priority = requestInfo.Priority
+			header = req.Header.Get("X-Cf-Priority")
+		})
+		handler.ServeHTTP(httptest.NewRecorder(), req)
+	})
+
+	It("gives requests the default priority", func() {
+		Expect(priority).To(Equal(handlers.PriorityNormal))
+	})
+
+	Context("when the host has a priority", func() {
+		BeforeEach(func() {
+			req.Host = "batch.example.com:443"
+		})
+
+		It("gives requests the priority of the host", func() {
+			Expect(priority).To(Equal(handlers.PriorityLow))
+		})
+	})
+
+	Context("when the request names its priority", func() {
+		BeforeEach(func() {
+			req.Header.Set("X-Cf-Priority", "high")
+		})
+
+		It("honours it from trusted sources", func() {
+			Expect(priority).To(Equal(handlers.PriorityHigh))
+		})
+
+		It("does not forward the header", func() {
+			Expect(header).To(BeEmpty())
+		})
+
+		Context("when the source is not trusted", func() {
+			BeforeEach(func() {
+				req.RemoteAddr = "192.168.0.1:4567"
+			})
+
+			It("ignores it", func() {
+				Expect(priority).To(Equal(handlers.PriorityNormal))
+				Expect(header).To(BeEmpty())
+			})
+		})
+
+		Context("when the priority is unknown", func() {
+			BeforeEach(func() {
+				req.Header.Set("X-Cf-Priority", "urgent")
+			})
+
+			It("ignores it", func() {
+				Expect(priority).To(Equal(handlers.PriorityNormal))
+			})
+		})
+	})
+})
@@ -4,23 +4,27 @@ import (
 	"net/http"
 	"sync/atomic"
 
+	"code.cloudfoundry.org/gorouter/config"
 	"code.cloudfoundry.org/gorouter/logger"
 	"github.com/urfave/negroni"
 )
 
 type proxyHealthcheck struct {
-	userAgent   string
+	userAgents  []string
+	paths       []string
+	listeners   []string
 	heartbeatOK *int32
 	logger      logger.Logger
 }
 
-// NewHealthcheck creates a handler that responds to healthcheck requests.
-// If userAgent is set to a non-empty string, it will use that user agent to
-// differentiate between healthcheck requests and non-healthcheck requests.
-// Otherwise, it will treat all requests as healthcheck requests.
-func NewProxyHealthcheck(userAgent string, heartbeatOK *int32, logger logger.Logger) negroni.Handler {
+// NewHealthcheck creates a handler that responds to healthcheck requests,
+// those sent with one of the user agents of cfg or for one of its paths, on
+// its listeners. An empty user agent matches the requests sent without one.
+func NewProxyHealthcheck(cfg config.HealthCheckConfig, heartbeatOK *int32, logger logger.Logger) negroni.Handler {
 	return &proxyHealthcheck{
-		userAgent:   userAgent,
+		userAgents:  cfg.UserAgents,
+		paths:       cfg.Paths,
+		listeners:   cfg.Listeners,
 		heartbeatOK: heartbeatOK,
 		logger:      logger,
 	}
@@ -28,7 +32,7 @@ func NewProxyHealthcheck(userAgent string, heartbeatOK *int32, logger logger.Log
 
 func (h *proxyHealthcheck) ServeHTTP(rw http.ResponseWriter, r *http.Request, next http.HandlerFunc) {
 	// If reqeust is not intended for healthcheck
-	if r.Header.Get("User-Agent") != h.userAgent {
+	if !h.isHealthcheck(r) {
 		next(rw, r)
 		return
 	}
@@ -47,3 +51,22 @@ func (h *proxyHealthcheck) ServeHTTP(rw http.ResponseWriter, r *http.Request, ne
 	rw.Write([]byte("ok\n"))
 	r.Close = true
 }
+
+func (h *proxyHealthcheck) isHealthcheck(r *http.Request) bool {
+	if !contains(h.userAgents, r.Header.Get("User-Agent")) && !contains(h.paths, r.URL.Path) {
+		return false
+	}
+	return len(h.listeners) == 0 || contains(h.listeners, healthcheckListener(r))
+}
+
+// healthcheckListener names the listener r arrived on, one of
+// config.HealthCheckListeners
+func healthcheckListener(r *http.Request) string {
+	if ContextListener(r) == config.ROUTE_VISIBILITY_INTERNAL {
+		return config.HEALTHCHECK_LISTENER_INTERNAL
+	}
+	if r.TLS != nil {
+		return config.HEALTHCHECK_LISTENER_HTTPS
+	}
+	return config.HEALTHCHECK_LISTENER_HTTP
+}
@@ -1,10 +1,12 @@
 package handlers_test
 
 import (
+	"crypto/tls"
 	"io/ioutil"
 	"net/http"
 	"net/http/httptest"
 
+	"code.cloudfoundry.org/gorouter/config"
 	"code.cloudfoundry.org/gorouter/handlers"
 	"code.cloudfoundry.org/gorouter/logger"
 	"code.cloudfoundry.org/gorouter/test_util"
@@ -22,20 +24,25 @@ var _ = Describe("Proxy Healthcheck", func() {
 		heartbeatOK int32
 		nextHandler http.HandlerFunc
 		nextCalled  bool
+		healthCheck config.HealthCheckConfig
 	)
 	BeforeEach(func() {
 		logger = test_util.NewTestZapLogger("healthcheck")
 		req = test_util.NewRequest("GET", "example.com", "/", nil)
 		resp = httptest.NewRecorder()
 		heartbeatOK = 1
+		healthCheck = config.HealthCheckConfig{UserAgents: []string{"HTTP-Monitor/1.1"}}
 
-		handler = handlers.NewProxyHealthcheck("HTTP-Monitor/1.1", &heartbeatOK, logger)
 		nextHandler = http.HandlerFunc(func(http.ResponseWriter, *http.Request) {
 			nextCalled = true
 		})
 
 	})
 
+	JustBeforeEach(func() {
+		handler = handlers.NewProxyHealthcheck(healthCheck, &heartbeatOK, logger)
+	})
+
 	AfterEach(func() {
 		nextCalled = false
 	})
@@ -109,4 +116,61 @@ var _ = Describe("Proxy Healthcheck", func() {
 			Expect(nextCalled).To(BeTrue())
 		})
 	})
+
+	Context("with several user agents", func() {
+		BeforeEach(func() {
+			healthCheck.UserAgents = []string{"HTTP-Monitor/1.1", "ELB-HealthChecker/2.0"}
+			req.Header.Set("User-Agent", "ELB-HealthChecker/2.0")
+		})
+
+		It("answers the requests sent with any of them", func() {
+			handler.ServeHTTP(resp, req, nextHandler)
+			Expect(resp.Code).To(Equal(200))
+			Expect(nextCalled).To(BeFalse())
+		})
+	})
+
+	Context("with paths", func() {
+		BeforeEach(func() {
+			healthCheck.Paths = []string{"/lb-health"}
+			req.Header.Set("User-Agent", "probe")
+		})
+
+		It("answers the requests for them whatever their user agent", func() {
+			req = test_util.NewRequest("GET", "example.com", "/lb-health", nil)
+			handler.ServeHTTP(resp, req, nextHandler)
+			Expect(resp.Code).To(Equal(200))
+			Expect(nextCalled).To(BeFalse())
+		})
+
+		It("forwards the requests for other paths", func() {
+			handler.ServeHTTP(resp, req, nextHandler)
+			Expect(nextCalled).To(BeTrue())
+		})
+	})
+
+	Context("with listeners", func() {
+		BeforeEach(func() {
+			healthCheck.Listeners = []string{config.HEALTHCHECK_LISTENER_HTTPS, config.HEALTHCHECK_LISTENER_INTERNAL}
+			req.Header.Set("User-Agent", "HTTP-Monitor/1.1")
+		})
+
+		It("answers the healthchecks arriving on them", func() {
+			req.TLS = &tls.ConnectionState{}
+			handler.ServeHTTP(resp, req, nextHandler)
+			Expect(resp.Code).To(Equal(200))
+
+			internalReq := test_util.NewRequest("GET", "example.com", "/", nil)
+			internalReq.Header.Set("User-Agent", "HTTP-Monitor/1.1")
+			resp = httptest.NewRecorder()
+			handler.ServeHTTP(resp, handlers.WithListener(internalReq, config.ROUTE_VISIBILITY_INTERNAL), nextHandler)
+			Expect(resp.Code).To(Equal(200))
+			Expect(nextCalled).To(BeFalse())
+		})
+
+		It("forwards the healthchecks arriving on other listeners", func() {
+			handler.ServeHTTP(resp, req, nextHandler)
+			Expect(nextCalled).To(BeTrue())
+		})
+	})
 })
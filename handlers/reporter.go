@@ -45,6 +45,10 @@ func (rh *reporterHandler) ServeHTTP(rw http.ResponseWriter, r *http.Request, ne
 	proxyWriter := rw.(utils.ProxyResponseWriter)
 	rh.reporter.CaptureRoutingResponse(proxyWriter.Status())
 
+	if r.Header.Get("Range") != "" {
+		rh.reporter.CaptureRangeRequest(requestInfo.RouteEndpoint, proxyWriter.Status())
+	}
+
 	if requestInfo.StoppedAt.Equal(time.Time{}) {
 		return
 	}
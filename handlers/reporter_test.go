@@ -90,6 +90,27 @@ var _ = Describe("Reporter Handler", func() {
 		Expect(nextCalled).To(BeTrue(), "Expected the next handler to be called.")
 	})
 
+	It("does not emit a range request metric without a Range header", func() {
+		handler.ServeHTTP(resp, req)
+
+		Expect(fakeReporter.CaptureRangeRequestCallCount()).To(Equal(0))
+	})
+
+	Context("when the request has a Range header", func() {
+		BeforeEach(func() {
+			req.Header.Set("Range", "bytes=0-10")
+		})
+
+		It("emits a range request metric with the response status", func() {
+			handler.ServeHTTP(resp, req)
+
+			Expect(fakeReporter.CaptureRangeRequestCallCount()).To(Equal(1))
+			capturedEndpoint, capturedRespCode := fakeReporter.CaptureRangeRequestArgsForCall(0)
+			Expect(capturedEndpoint.ApplicationId).To(Equal("appID"))
+			Expect(capturedRespCode).To(Equal(http.StatusTeapot))
+		})
+	})
+
 	Context("when reqInfo.StoppedAt is 0", func() {
 		BeforeEach(func() {
 			nextHandler = http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
@@ -0,0 +1,87 @@
+package handlers
+
+import (
+	"io"
+	"net/http"
+	"time"
+
+	"code.cloudfoundry.org/gorouter/capture"
+	"code.cloudfoundry.org/gorouter/logger"
+	"code.cloudfoundry.org/gorouter/proxy/utils"
+
+	"github.com/uber-go/zap"
+	"github.com/urfave/negroni"
+)
+
+type requestCapture struct {
+	recorder *capture.Recorder
+	logger   logger.Logger
+}
+
+// NewRequestCapture creates a handler recording the requests matching the
+// recorder's active capture
+func NewRequestCapture(recorder *capture.Recorder, logger logger.Logger) negroni.Handler {
+	return &requestCapture{
+		recorder: recorder,
+		logger:   logger,
+	}
+}
+
+func (c *requestCapture) ServeHTTP(rw http.ResponseWriter, r *http.Request, next http.HandlerFunc) {
+	bodyLimit, ok := c.recorder.Match(r)
+	if !ok {
+		next(rw, r)
+		return
+	}
+
+	reqInfo, err := ContextRequestInfo(r)
+	if err != nil {
+		c.logger.Fatal("request-info-err", zap.Error(err))
+		return
+	}
+
+	exchange := capture.NewExchange(r, time.Now())
+	proxyWriter := rw.(utils.ProxyResponseWriter)
+
+	var requestBody, responseBody *capture.BodyBuffer
+	if bodyLimit > 0 {
+		requestBody = &capture.BodyBuffer{Limit: bodyLimit}
+		if r.Body != nil {
+			r.Body = &teeReadCloser{Reader: io.TeeReader(r.Body, requestBody), Closer: r.Body}
+		}
+
+		responseBody = &capture.BodyBuffer{Limit: bodyLimit}
+		proxyWriter = &capturingResponseWriter{ProxyResponseWriter: proxyWriter, body: responseBody}
+		reqInfo.ProxyResponseWriter = proxyWriter
+	}
+
+	next(proxyWriter, r)
+
+	exchange.SetResponse(proxyWriter.Status(), proxyWriter.Header(), proxyWriter.Size(), time.Now())
+	if endpoint := reqInfo.RouteEndpoint; endpoint != nil {
+		exchange.AppID = endpoint.ApplicationId
+		exchange.Backend = endpoint.CanonicalAddr()
+	}
+	if bodyLimit > 0 {
+		exchange.RequestBody, exchange.RequestBodyTruncated = requestBody.String(), requestBody.Truncated()
+		exchange.ResponseBody, exchange.ResponseBodyTruncated = responseBody.String(), responseBody.Truncated()
+	}
+
+	c.recorder.Record(exchange)
+}
+
+type teeReadCloser struct {
+	io.Reader
+	io.Closer
+}
+
+type capturingResponseWriter struct {
+	utils.ProxyResponseWriter
+	body io.Writer
+}
+
+func (w *capturingResponseWriter) Write(b []byte) (int, error) {
+	n, err := w.ProxyResponseWriter.Write(b)
+	_, _ = w.body.Write(b[:n])
+	return n, err
+}
@@ -0,0 +1,99 @@
+package handlers_test
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"time"
+
+	"code.cloudfoundry.org/gorouter/capture"
+	"code.cloudfoundry.org/gorouter/handlers"
+	logger_fakes "code.cloudfoundry.org/gorouter/logger/fakes"
+	"code.cloudfoundry.org/gorouter/route"
+	"code.cloudfoundry.org/gorouter/test_util"
+	"code.cloudfoundry.org/routing-api/models"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/urfave/negroni"
+)
+
+var _ = Describe("RequestCapture", func() {
+	var (
+		handler  *negroni.Negroni
+		recorder *capture.Recorder
+		req      *http.Request
+	)
+
+	BeforeEach(func() {
+		fakeLogger := new(logger_fakes.FakeLogger)
+		recorder = capture.NewRecorder(true, 10, 5, time.Hour)
+
+		handler = negroni.New()
+		handler.Use(handlers.NewRequestInfo())
+		handler.Use(handlers.NewProxyWriter(fakeLogger))
+		handler.Use(handlers.NewRequestCapture(recorder, fakeLogger))
+		handler.UseHandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+			_, err := ioutil.ReadAll(req.Body)
+			Expect(err).NotTo(HaveOccurred())
+
+			reqInfo, err := handlers.ContextRequestInfo(req)
+			Expect(err).NotTo(HaveOccurred())
+			reqInfo.RouteEndpoint = route.NewEndpoint(
+				"appID", "1.2.3.4", uint16(1234), "id", "1", nil, 0, "",
+				models.ModificationTag{}, "")
+
+			rw.Header().Set("X-App", "yes")
+			rw.WriteHeader(http.StatusTeapot)
+			_, err = rw.Write([]byte("response body"))
+			Expect(err).NotTo(HaveOccurred())
+		})
+
+		req = test_util.NewRequest("POST", "example.com", "/foo", strings.NewReader("request body"))
+	})
+
+	It("does not record anything without an active capture", func() {
+		handler.ServeHTTP(httptest.NewRecorder(), req)
+		Expect(recorder.Status().Exchanges).To(BeEmpty())
+	})
+
+	It("records the request and response metadata", func() {
+		Expect(recorder.Start(capture.Filter{Host: "example.com"}, time.Minute)).To(Succeed())
+
+		resp := httptest.NewRecorder()
+		handler.ServeHTTP(resp, req)
+		Expect(resp.Body.String()).To(Equal("response body"))
+
+		exchanges := recorder.Status().Exchanges
+		Expect(exchanges).To(HaveLen(1))
+		Expect(exchanges[0].Method).To(Equal("POST"))
+		Expect(exchanges[0].URI).To(Equal("/foo"))
+		Expect(exchanges[0].StatusCode).To(Equal(http.StatusTeapot))
+		Expect(exchanges[0].ResponseHeaders.Get("X-App")).To(Equal("yes"))
+		Expect(exchanges[0].BytesSent).To(Equal(len("response body")))
+		Expect(exchanges[0].AppID).To(Equal("appID"))
+		Expect(exchanges[0].Backend).To(Equal("1.2.3.4:1234"))
+		Expect(exchanges[0].RequestBody).To(BeEmpty())
+	})
+
+	It("records truncated bodies when requested", func() {
+		Expect(recorder.Start(capture.Filter{IncludeBodies: true}, time.Minute)).To(Succeed())
+
+		resp := httptest.NewRecorder()
+		handler.ServeHTTP(resp, req)
+		Expect(resp.Body.String()).To(Equal("response body"))
+
+		exchange := recorder.Status().Exchanges[0]
+		Expect(exchange.RequestBody).To(Equal("reque"))
+		Expect(exchange.RequestBodyTruncated).To(BeTrue())
+		Expect(exchange.ResponseBody).To(Equal("respo"))
+		Expect(exchange.ResponseBodyTruncated).To(BeTrue())
+	})
+
+	It("skips requests not matching the filter", func() {
+		Expect(recorder.Start(capture.Filter{Host: "other.com"}, time.Minute)).To(Succeed())
+		handler.ServeHTTP(httptest.NewRecorder(), req)
+		Expect(recorder.Status().Exchanges).To(BeEmpty())
+	})
+})
@@ -0,0 +1,49 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/uber-go/zap"
+	"github.com/urfave/negroni"
+
+	"code.cloudfoundry.org/gorouter/config"
+	"code.cloudfoundry.org/gorouter/logger"
+)
+
+type requestStart struct {
+	header           string
+	preserveUpstream bool
+	logger           logger.Logger
+}
+
+// NewRequestStart creates a handler setting the header of cfg to the time the
+// request arrived, as t=<Unix microseconds>, replacing any value sent along.
+// When upstream values are preserved, a value already set is forwarded as it
+// is.
+func NewRequestStart(cfg config.RequestStartConfig, logger logger.Logger) negroni.Handler {
+	return &requestStart{
+		header:           http.CanonicalHeaderKey(cfg.Header),
+		preserveUpstream: cfg.PreserveUpstream,
+		logger:           logger,
+	}
+}
+
+func (s *requestStart) ServeHTTP(rw http.ResponseWriter, r *http.Request, next http.HandlerFunc) {
+	if s.preserveUpstream && r.Header.Get(s.header) != "" {
+		next(rw, r)
+		return
+	}
+
+	requestInfo, err := ContextRequestInfo(r)
+	if err != nil {
+		s.logger.Fatal("request-info-err", zap.Error(err))
+		return
+	}
+
+	micros := requestInfo.StartedAt.UnixNano() / int64(time.Microsecond)
+	r.Header.Set(s.header, "t="+strconv.FormatInt(micros, 10))
+
+	next(rw, r)
+}
@@ -0,0 +1,94 @@
+package handlers_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"time"
+
+	"code.cloudfoundry.org/gorouter/config"
+	"code.cloudfoundry.org/gorouter/handlers"
+	logger_fakes "code.cloudfoundry.org/gorouter/logger/fakes"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/urfave/negroni"
+)
+
+var _ = Describe("RequestStart", func() {
+	var (
+		handler   *negroni.Negroni
+		cfg       config.RequestStartConfig
+		req       *http.Request
+		startedAt time.Time
+		forwarded http.Header
+	)
+
+	BeforeEach(func() {
+		cfg = config.RequestStartConfig{Enabled: true, Header: "X-Request-Start"}
+		req = httptest.NewRequest("GET", "http://app.example.com/", nil)
+		forwarded = nil
+	})
+
+	JustBeforeEach(func() {
+		handler = negroni.New()
+		handler.Use(handlers.NewRequestInfo())
+		handler.Use(handlers.NewRequestStart(cfg, new(logger_fakes.FakeLogger)))
+		handler.UseHandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+			reqInfo, err := handlers.ContextRequestInfo(r)
+			Expect(err).NotTo(HaveOccurred())
+			startedAt = reqInfo.StartedAt
+			forwarded = r.Header
+		})
+	})
+
+	It("sets the time the request arrived in microseconds", func() {
+		handler.ServeHTTP(httptest.NewRecorder(), req)
+
+		value := forwarded.Get("X-Request-Start")
+		Expect(value).To(HavePrefix("t="))
+		micros, err := strconv.ParseInt(strings.TrimPrefix(value, "t="), 10, 64)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(micros).To(Equal(startedAt.UnixNano() / int64(time.Microsecond)))
+	})
+
+	It("replaces the value sent by the client", func() {
+		req.Header.Set("X-Request-Start", "t=1")
+		handler.ServeHTTP(httptest.NewRecorder(), req)
+
+		Expect(forwarded.Get("X-Request-Start")).NotTo(Equal("t=1"))
+	})
+
+	Context("with another header", func() {
+		BeforeEach(func() {
+			cfg.Header = "x-queue-start"
+		})
+
+		It("sets that header", func() {
+			handler.ServeHTTP(httptest.NewRecorder(), req)
+
+			Expect(forwarded.Get("X-Queue-Start")).To(HavePrefix("t="))
+			Expect(forwarded).NotTo(HaveKey("X-Request-Start"))
+		})
+	})
+
+	Context("when preserving upstream values", func() {
+		BeforeEach(func() {
+			cfg.PreserveUpstream = true
+		})
+
+		It("forwards the value set by the load balancer", func() {
+			req.Header.Set("X-Request-Start", "t=1234567890123456")
+			handler.ServeHTTP(httptest.NewRecorder(), req)
+
+			Expect(forwarded.Get("X-Request-Start")).To(Equal("t=1234567890123456"))
+		})
+
+		It("sets the time the request arrived when there is none", func() {
+			handler.ServeHTTP(httptest.NewRecorder(), req)
+
+			Expect(forwarded.Get("X-Request-Start")).To(HavePrefix("t="))
+		})
+	})
+})
@@ -1,21 +1,21 @@
 package handlers
 
 import (
-	"context"
 	"errors"
 	"net/http"
 	"net/url"
 	"time"
 
+	"code.cloudfoundry.org/gorouter/config"
+	"code.cloudfoundry.org/gorouter/geoip"
 	"code.cloudfoundry.org/gorouter/proxy/utils"
+	"code.cloudfoundry.org/gorouter/reqctx"
 	"code.cloudfoundry.org/gorouter/route"
 
 	"github.com/urfave/negroni"
 )
 
-type key string
-
-const requestInfoCtxKey key = "RequestInfo"
+var requestInfoKey = reqctx.NewKey("RequestInfo")
 
 // RequestInfo stores all metadata about the request and is used to pass
 // informaton between handlers
@@ -26,11 +26,34 @@ type RequestInfo struct {
 	ProxyResponseWriter    utils.ProxyResponseWriter
 	RouteServiceURL        *url.URL
 	IsInternalRouteService bool
+	Attempts               []BackendAttempt
+	Priority               Priority
+	RouteServiceBypassed   bool
+	// FromRouteService is set for requests a route service forwarded
+	FromRouteService bool
+	// RouteServiceDirectResponse is set when the route service answered the
+	// request itself
+	RouteServiceDirectResponse bool
+	// ClientLocation is looked up when GeoIP is enabled
+	ClientLocation geoip.Location
+	// DomainProfile is the profile of the request's domain, if any
+	DomainProfile *config.DomainProfile
+	// RouteDebug is set for requests asking for the X-Cf-Route-Debug header
+	RouteDebug *RouteDebug
+}
+
+// BackendAttempt records one attempt to reach an endpoint or route service.
+// Duration lasts until the response headers arrived or the attempt failed.
+type BackendAttempt struct {
+	Endpoint  string
+	StartedAt time.Time
+	Duration  time.Duration
+	Err       error
 }
 
 // ContextRequestInfo gets the RequestInfo from the request Context
 func ContextRequestInfo(req *http.Request) (*RequestInfo, error) {
-	ri := req.Context().Value(requestInfoCtxKey)
+	ri := requestInfoKey.Value(req.Context())
 	if ri == nil {
 		return nil, errors.New("RequestInfo not set on context")
 	}
@@ -41,6 +64,11 @@ func ContextRequestInfo(req *http.Request) (*RequestInfo, error) {
 	return reqInfo, nil
 }
 
+// WithRequestInfo returns a copy of req carrying reqInfo
+func WithRequestInfo(req *http.Request, reqInfo *RequestInfo) *http.Request {
+	return req.WithContext(requestInfoKey.With(req.Context(), reqInfo))
+}
+
 // RequestInfoHandler adds a RequestInfo to the context of all requests that go
 // through this handler
 type RequestInfoHandler struct{}
@@ -52,7 +80,7 @@ func NewRequestInfo() negroni.Handler {
 
 func (r *RequestInfoHandler) ServeHTTP(w http.ResponseWriter, req *http.Request, next http.HandlerFunc) {
 	reqInfo := new(RequestInfo)
-	req = req.WithContext(context.WithValue(req.Context(), requestInfoCtxKey, reqInfo))
+	req = WithRequestInfo(req, reqInfo)
 	reqInfo.StartedAt = time.Now()
 	next(w, req)
 }
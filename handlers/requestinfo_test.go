@@ -2,6 +2,7 @@ package handlers_test
 
 import (
 	"bytes"
+	"context"
 	"io/ioutil"
 	"net/http"
 	"net/http/httptest"
@@ -70,3 +71,32 @@ var _ = Describe("RequestInfo", func() {
 	})
 
 })
+
+var _ = Describe("ContextRequestInfo", func() {
+	var req *http.Request
+
+	BeforeEach(func() {
+		req = test_util.NewRequest("GET", "example.com", "/", nil)
+	})
+
+	It("returns the RequestInfo set on the request", func() {
+		reqInfo := &handlers.RequestInfo{}
+		req = handlers.WithRequestInfo(req, reqInfo)
+
+		ri, err := handlers.ContextRequestInfo(req)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(ri).To(BeIdenticalTo(reqInfo))
+	})
+
+	It("fails when no RequestInfo is set", func() {
+		_, err := handlers.ContextRequestInfo(req)
+		Expect(err).To(HaveOccurred())
+	})
+
+	It("ignores a value stored under the name of the key", func() {
+		req = req.WithContext(context.WithValue(req.Context(), "RequestInfo", &handlers.RequestInfo{}))
+
+		_, err := handlers.ContextRequestInfo(req)
+		Expect(err).To(HaveOccurred())
+	})
+})
@@ -0,0 +1,144 @@
+package handlers
+
+import (
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/uber-go/zap"
+	"github.com/urfave/negroni"
+
+	router_http "code.cloudfoundry.org/gorouter/common/http"
+	"code.cloudfoundry.org/gorouter/logger"
+	"code.cloudfoundry.org/gorouter/proxy/utils"
+	"code.cloudfoundry.org/gorouter/route"
+	"code.cloudfoundry.org/gorouter/routererr"
+)
+
+// RouteDebug records how a request asking for the X-Cf-Route-Debug header
+// was looked up
+type RouteDebug struct {
+	// LookupSteps are the route keys tried, the wildcards falling back from
+	// the request's included
+	LookupSteps []route.Uri
+	LookupTime  time.Duration
+	// PoolSize is the number of endpoints the request could be routed to
+	PoolSize int
+}
+
+type routeDebug struct {
+	traceKey string
+	logger   logger.Logger
+}
+
+// NewRouteDebug creates a handler answering requests whose X-Cf-Route-Debug
+// header holds the trace key with an X-Cf-Route-Debug header summarizing how
+// they were routed: the lookup steps and time, the size of the pool, the
+// endpoint chosen, each attempt and the time spent in the router. The request
+// header is not forwarded.
+func NewRouteDebug(traceKey string, logger logger.Logger) negroni.Handler {
+	return &routeDebug{
+		traceKey: traceKey,
+		logger:   logger,
+	}
+}
+
+func (d *routeDebug) ServeHTTP(rw http.ResponseWriter, r *http.Request, next http.HandlerFunc) {
+	key := r.Header.Get(router_http.CfRouteDebug)
+	if key == "" {
+		next(rw, r)
+		return
+	}
+	r.Header.Del(router_http.CfRouteDebug)
+	if d.traceKey == "" || key != d.traceKey {
+		next(rw, r)
+		return
+	}
+
+	requestInfo, err := ContextRequestInfo(r)
+	if err != nil {
+		d.logger.Fatal("request-info-err", zap.Error(err))
+		return
+	}
+	requestInfo.RouteDebug = &RouteDebug{}
+
+	proxyWriter := &routeDebugResponseWriter{
+		ProxyResponseWriter: rw.(utils.ProxyResponseWriter),
+		requestInfo:         requestInfo,
+	}
+	requestInfo.ProxyResponseWriter = proxyWriter
+	next(proxyWriter, r)
+}
+
+// routeDebugResponseWriter adds the summary of the routing of the request when
+// the header of its response is written
+type routeDebugResponseWriter struct {
+	utils.ProxyResponseWriter
+	requestInfo *RequestInfo
+	wroteHeader bool
+}
+
+func (w *routeDebugResponseWriter) WriteHeader(status int) {
+	if !w.wroteHeader && status >= http.StatusOK {
+		w.wroteHeader = true
+		w.Header().Set(router_http.CfRouteDebug, RouteDebugSummary(w.requestInfo, time.Now()))
+	}
+	w.ProxyResponseWriter.WriteHeader(status)
+}
+
+func (w *routeDebugResponseWriter) Write(b []byte) (int, error) {
+	if !w.wroteHeader {
+		w.WriteHeader(http.StatusOK)
+	}
+	return w.ProxyResponseWriter.Write(b)
+}
+
+func (w *routeDebugResponseWriter) ReadFrom(r io.Reader) (int64, error) {
+	if !w.wroteHeader {
+		w.WriteHeader(http.StatusOK)
+	}
+	return utils.ReadFrom(w.ProxyResponseWriter, r)
+}
+
+// RouteDebugSummary formats how the request of reqInfo was routed until now as
+// "; " separated fields, e.g. "lookup=app.example.com,*.example.com;
+// lookup_time=0.012ms; pool=2; endpoint=10.0.0.1:8080; attempts=10.0.0.2:8080
+// dial_failed 1.204ms, 10.0.0.1:8080 ok 3.010ms; total=4.870ms"
+func RouteDebugSummary(reqInfo *RequestInfo, now time.Time) string {
+	var fields []string
+	if debug := reqInfo.RouteDebug; debug != nil && len(debug.LookupSteps) > 0 {
+		steps := make([]string, len(debug.LookupSteps))
+		for i, step := range debug.LookupSteps {
+			steps[i] = step.String()
+		}
+		fields = append(fields,
+			"lookup="+strings.Join(steps, ","),
+			"lookup_time="+debugDuration(debug.LookupTime),
+			"pool="+strconv.Itoa(debug.PoolSize),
+		)
+	}
+	if reqInfo.RouteEndpoint != nil {
+		fields = append(fields, "endpoint="+reqInfo.RouteEndpoint.CanonicalAddr())
+	}
+	if len(reqInfo.Attempts) > 0 {
+		attempts := make([]string, len(reqInfo.Attempts))
+		for i, attempt := range reqInfo.Attempts {
+			outcome := "ok"
+			if attempt.Err != nil {
+				outcome = routererr.Name(attempt.Err)
+			}
+			attempts[i] = attempt.Endpoint + " " + outcome + " " + debugDuration(attempt.Duration)
+		}
+		fields = append(fields, "attempts="+strings.Join(attempts, ", "))
+	}
+	fields = append(fields, "total="+debugDuration(now.Sub(reqInfo.StartedAt)))
+	return strings.Join(fields, "; ")
+}
+
+// debugDuration formats d in milliseconds, as header values are best kept to
+// ASCII
+func debugDuration(d time.Duration) string {
+	return strconv.FormatFloat(float64(d)/float64(time.Millisecond), 'f', 3, 64) + "ms"
+}
@@ -0,0 +1,140 @@
+package handlers_test
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"time"
+
+	router_http "code.cloudfoundry.org/gorouter/common/http"
+	"code.cloudfoundry.org/gorouter/handlers"
+	logger_fakes "code.cloudfoundry.org/gorouter/logger/fakes"
+	"code.cloudfoundry.org/gorouter/route"
+	"code.cloudfoundry.org/gorouter/routererr"
+	"code.cloudfoundry.org/routing-api/models"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/urfave/negroni"
+)
+
+var _ = Describe("RouteDebug", func() {
+	var (
+		handler     *negroni.Negroni
+		traceKey    string
+		req         *http.Request
+		nextRequest *http.Request
+		reqInfo     *handlers.RequestInfo
+	)
+
+	BeforeEach(func() {
+		traceKey = "secret"
+		req = httptest.NewRequest("GET", "http://app.example.com/", nil)
+		nextRequest = nil
+		reqInfo = nil
+	})
+
+	JustBeforeEach(func() {
+		fakeLogger := new(logger_fakes.FakeLogger)
+		handler = negroni.New()
+		handler.Use(handlers.NewRequestInfo())
+		handler.Use(handlers.NewProxyWriter(fakeLogger))
+		handler.Use(handlers.NewRouteDebug(traceKey, fakeLogger))
+		handler.UseHandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+			nextRequest = r
+			var err error
+			reqInfo, err = handlers.ContextRequestInfo(r)
+			Expect(err).NotTo(HaveOccurred())
+			if reqInfo.RouteDebug != nil {
+				reqInfo.RouteDebug.LookupSteps = []route.Uri{"app.example.com", "*.example.com"}
+				reqInfo.RouteDebug.PoolSize = 2
+			}
+			reqInfo.RouteEndpoint = route.NewEndpoint("app", "10.0.0.1", 8080, "", "", nil, -1, "", models.ModificationTag{}, "")
+			rw.Write([]byte("backend"))
+		})
+	})
+
+	serve := func() *httptest.ResponseRecorder {
+		resp := httptest.NewRecorder()
+		handler.ServeHTTP(resp, req)
+		return resp
+	}
+
+	Context("when the request carries the trace key", func() {
+		BeforeEach(func() {
+			req.Header.Set(router_http.CfRouteDebug, "secret")
+		})
+
+		It("summarizes the routing of the request in the response", func() {
+			resp := serve()
+			Expect(resp.Body.String()).To(Equal("backend"))
+			Expect(reqInfo.RouteDebug).NotTo(BeNil())
+
+			summary := resp.Header().Get(router_http.CfRouteDebug)
+			Expect(summary).To(HavePrefix("lookup=app.example.com,*.example.com; lookup_time="))
+			Expect(summary).To(ContainSubstring("; pool=2; endpoint=10.0.0.1:8080; total="))
+		})
+
+		It("does not forward the header", func() {
+			serve()
+			Expect(nextRequest.Header).NotTo(HaveKey(router_http.CfRouteDebug))
+		})
+	})
+
+	Context("when the request carries another key", func() {
+		BeforeEach(func() {
+			req.Header.Set(router_http.CfRouteDebug, "guess")
+		})
+
+		It("does not summarize the routing", func() {
+			resp := serve()
+			Expect(reqInfo.RouteDebug).To(BeNil())
+			Expect(resp.Header()).NotTo(HaveKey(router_http.CfRouteDebug))
+		})
+
+		It("does not forward the header", func() {
+			serve()
+			Expect(nextRequest.Header).NotTo(HaveKey(router_http.CfRouteDebug))
+		})
+	})
+
+	Context("without a trace key", func() {
+		BeforeEach(func() {
+			traceKey = ""
+			req.Header.Set(router_http.CfRouteDebug, "anything")
+		})
+
+		It("does not summarize the routing", func() {
+			resp := serve()
+			Expect(reqInfo.RouteDebug).To(BeNil())
+			Expect(resp.Header()).NotTo(HaveKey(router_http.CfRouteDebug))
+		})
+	})
+
+	Describe("RouteDebugSummary", func() {
+		It("lists the attempts with their outcome", func() {
+			started := time.Now()
+			info := &handlers.RequestInfo{
+				StartedAt: started,
+				Attempts: []handlers.BackendAttempt{
+					{Endpoint: "10.0.0.2:8080", Duration: 1500 * time.Microsecond, Err: routererr.ErrDialFailed},
+					{Endpoint: "10.0.0.1:8080", Duration: 3 * time.Millisecond},
+				},
+			}
+
+			Expect(handlers.RouteDebugSummary(info, started.Add(5*time.Millisecond))).To(Equal(
+				"attempts=10.0.0.2:8080 dial_failed 1.500ms, 10.0.0.1:8080 ok 3.000ms; total=5.000ms",
+			))
+		})
+
+		It("names unclassified errors too", func() {
+			started := time.Now()
+			info := &handlers.RequestInfo{
+				StartedAt: started,
+				Attempts:  []handlers.BackendAttempt{{Endpoint: "10.0.0.2:8080", Err: errors.New("boom")}},
+			}
+
+			Expect(handlers.RouteDebugSummary(info, started)).To(MatchRegexp(`^attempts=10\.0\.0\.2:8080 \S+ 0\.000ms; total=0\.000ms$`))
+		})
+	})
+})
@@ -0,0 +1,91 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/uber-go/zap"
+	"github.com/urfave/negroni"
+
+	router_http "code.cloudfoundry.org/gorouter/common/http"
+	"code.cloudfoundry.org/gorouter/config"
+	"code.cloudfoundry.org/gorouter/logger"
+	"code.cloudfoundry.org/gorouter/metrics"
+	"code.cloudfoundry.org/gorouter/reqctx"
+)
+
+var listenerKey = reqctx.NewKey("Listener")
+
+// WithListener records the visibility scope of the listener req arrived on,
+// config.ROUTE_VISIBILITY_INTERNAL or config.ROUTE_VISIBILITY_EXTERNAL
+func WithListener(req *http.Request, scope string) *http.Request {
+	return req.WithContext(listenerKey.With(req.Context(), scope))
+}
+
+// ContextListener returns the visibility scope of the listener req arrived
+// on, external unless recorded otherwise
+func ContextListener(req *http.Request) string {
+	if scope, ok := listenerKey.Value(req.Context()).(string); ok {
+		return scope
+	}
+	return config.ROUTE_VISIBILITY_EXTERNAL
+}
+
+type routeVisibility struct {
+	internalDomains []string
+	reporter        metrics.CombinedReporter
+	logger          logger.Logger
+}
+
+// NewRouteVisibility creates a handler answering requests for internal routes
+// that arrived on an external listener as if the route did not exist. It must
+// follow the lookup handler.
+func NewRouteVisibility(internalDomains []string, reporter metrics.CombinedReporter, logger logger.Logger) negroni.Handler {
+	return &routeVisibility{
+		internalDomains: internalDomains,
+		reporter:        reporter,
+		logger:          logger,
+	}
+}
+
+func (v *routeVisibility) ServeHTTP(rw http.ResponseWriter, r *http.Request, next http.HandlerFunc) {
+	if ContextListener(r) == config.ROUTE_VISIBILITY_INTERNAL {
+		next(rw, r)
+		return
+	}
+
+	requestInfo, err := ContextRequestInfo(r)
+	if err != nil {
+		v.logger.Fatal("request-info-err", zap.Error(err))
+		return
+	}
+
+	host := hostWithoutPort(r.Host)
+	if !requestInfo.RoutePool.Internal() && !v.internalDomain(host) {
+		next(rw, r)
+		return
+	}
+
+	v.reporter.CaptureBadRequest()
+	v.logger.Info("internal-route", zap.String("host", host))
+
+	requestInfo.RoutePool = nil
+	rw.Header().Set(router_http.CfRouterError, "unknown_route")
+	writeStatus(
+		rw,
+		http.StatusNotFound,
+		fmt.Sprintf("Requested route ('%s') does not exist.", r.Host),
+		v.logger,
+	)
+}
+
+func (v *routeVisibility) internalDomain(host string) bool {
+	host = strings.ToLower(host)
+	for _, domain := range v.internalDomains {
+		if host == domain || strings.HasSuffix(host, "."+domain) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,107 @@
+package handlers_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+
+	"code.cloudfoundry.org/gorouter/config"
+	"code.cloudfoundry.org/gorouter/handlers"
+	logger_fakes "code.cloudfoundry.org/gorouter/logger/fakes"
+	"code.cloudfoundry.org/gorouter/metrics/fakes"
+	"code.cloudfoundry.org/gorouter/route"
+	"code.cloudfoundry.org/routing-api/models"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/urfave/negroni"
+)
+
+var _ = Describe("RouteVisibility", func() {
+	var (
+		handler    *negroni.Negroni
+		pool       *route.Pool
+		rep        *fakes.FakeCombinedReporter
+		listener   string
+		nextCalled bool
+	)
+
+	BeforeEach(func() {
+		pool = route.NewPool(0, "")
+		rep = new(fakes.FakeCombinedReporter)
+		listener = ""
+		nextCalled = false
+
+		fakeLogger := new(logger_fakes.FakeLogger)
+		handler = negroni.New()
+		handler.Use(handlers.NewRequestInfo())
+		handler.Use(handlers.NewProxyWriter(fakeLogger))
+		handler.UseFunc(func(rw http.ResponseWriter, req *http.Request, next http.HandlerFunc) {
+			reqInfo, err := handlers.ContextRequestInfo(req)
+			Expect(err).NotTo(HaveOccurred())
+			reqInfo.RoutePool = pool
+			next(rw, req)
+		})
+		handler.Use(handlers.NewRouteVisibility([]string{"apps.internal"}, rep, fakeLogger))
+		handler.UseHandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+			nextCalled = true
+		})
+	})
+
+	register := func(visibility string) {
+		endpoint := route.NewEndpoint("app", "1.2.3.4", 5678, "", "", nil, -1, "", models.ModificationTag{}, "")
+		endpoint.Visibility = visibility
+		pool.Put(endpoint)
+	}
+
+	serve := func(host string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("GET", "http://"+host+"/", nil)
+		if listener != "" {
+			req = handlers.WithListener(req, listener)
+		}
+		resp := httptest.NewRecorder()
+		handler.ServeHTTP(resp, req)
+		return resp
+	}
+
+	Context("on an external listener", func() {
+		It("serves external routes", func() {
+			register("")
+			serve("app.example.com")
+			Expect(nextCalled).To(BeTrue())
+		})
+
+		It("answers internal routes as unknown", func() {
+			register(config.ROUTE_VISIBILITY_INTERNAL)
+			resp := serve("app.example.com")
+			Expect(nextCalled).To(BeFalse())
+			Expect(resp.Code).To(Equal(http.StatusNotFound))
+			Expect(resp.Header().Get("X-Cf-RouterError")).To(Equal("unknown_route"))
+			Expect(rep.CaptureBadRequestCallCount()).To(Equal(1))
+		})
+
+		It("answers the routes of internal domains as unknown", func() {
+			register("")
+			Expect(serve("app.apps.internal").Code).To(Equal(http.StatusNotFound))
+			Expect(serve("apps.internal:8080").Code).To(Equal(http.StatusNotFound))
+			Expect(nextCalled).To(BeFalse())
+		})
+	})
+
+	Context("on an internal listener", func() {
+		BeforeEach(func() {
+			listener = config.ROUTE_VISIBILITY_INTERNAL
+		})
+
+		It("serves internal and external routes", func() {
+			register(config.ROUTE_VISIBILITY_INTERNAL)
+			serve("app.apps.internal")
+			Expect(nextCalled).To(BeTrue())
+
+			nextCalled = false
+			pool = route.NewPool(0, "")
+			register("")
+			serve("app.example.com")
+			Expect(nextCalled).To(BeTrue())
+		})
+	})
+})
@@ -3,7 +3,9 @@ package handlers
 import (
 	"errors"
 	"net/http"
+	"strings"
 
+	"code.cloudfoundry.org/gorouter/config"
 	"code.cloudfoundry.org/gorouter/logger"
 	"code.cloudfoundry.org/gorouter/registry"
 	"code.cloudfoundry.org/gorouter/routeservice"
@@ -14,17 +16,25 @@ import (
 )
 
 type routeService struct {
-	config   *routeservice.RouteServiceConfig
-	logger   logger.Logger
-	registry registry.Registry
+	config       *routeservice.RouteServiceConfig
+	forwardedURL config.RouteServiceForwardedURLConfig
+	logger       logger.Logger
+	registry     registry.Registry
 }
 
-// NewRouteService creates a handler responsible for handling route services
-func NewRouteService(config *routeservice.RouteServiceConfig, logger logger.Logger, routeRegistry registry.Registry) negroni.Handler {
+// NewRouteService creates a handler responsible for handling route services.
+// forwardedURL shapes the URL route services are sent.
+func NewRouteService(
+	config *routeservice.RouteServiceConfig,
+	forwardedURL config.RouteServiceForwardedURLConfig,
+	logger logger.Logger,
+	routeRegistry registry.Registry,
+) negroni.Handler {
 	return &routeService{
-		config:   config,
-		logger:   logger,
-		registry: routeRegistry,
+		config:       config,
+		forwardedURL: forwardedURL,
+		logger:       logger,
+		registry:     routeRegistry,
 	}
 }
 
@@ -40,6 +50,25 @@ func (r *routeService) ServeHTTP(rw http.ResponseWriter, req *http.Request, next
 	}
 
 	routeServiceURL := reqInfo.RoutePool.RouteServiceUrl()
+	routeServiceOnly := reqInfo.RoutePool.RouteServiceOnly()
+	if routeServiceOnly && reqInfo.RouteServiceBypassed {
+		// there is no backend to bypass the route service for
+		r.logger.Debug("route-service-bypass-ignored", zap.String("route-service-url", routeServiceURL))
+		reqInfo.RouteServiceBypassed = false
+	}
+	if routeServiceURL != "" && reqInfo.RouteServiceBypassed {
+		r.logger.Debug("route-service-bypassed", zap.String("route-service-url", routeServiceURL))
+		// the backend must not take the request for one coming from the route service
+		req.Header.Del(routeservice.RouteServiceSignature)
+		req.Header.Del(routeservice.RouteServiceMetadata)
+		r.delForwardedURL(req)
+		if !r.toBackends(rw, reqInfo) {
+			return
+		}
+		next(rw, req)
+		return
+	}
+
 	// Attempted to use a route service when it is not supported
 	if routeServiceURL != "" && !r.config.RouteServiceEnabled() {
 		r.logger.Info("route-service-unsupported")
@@ -58,15 +87,7 @@ func (r *routeService) ServeHTTP(rw http.ResponseWriter, req *http.Request, next
 	if routeServiceURL != "" {
 		rsSignature := req.Header.Get(routeservice.RouteServiceSignature)
 
-		var recommendedScheme string
-
-		if r.config.RouteServiceRecommendHttps() {
-			recommendedScheme = "https"
-		} else {
-			recommendedScheme = "http"
-		}
-
-		forwardedURLRaw := recommendedScheme + "://" + hostWithoutPort(req.Host) + req.RequestURI
+		forwardedURLRaw := r.forwardedURLOf(req)
 		if hasBeenToRouteService(routeServiceURL, rsSignature) {
 			// A request from a route service destined for a backend instances
 			routeServiceArgs.URLString = routeServiceURL
@@ -82,10 +103,14 @@ func (r *routeService) ServeHTTP(rw http.ResponseWriter, req *http.Request, next
 				)
 				return
 			}
+			reqInfo.FromRouteService = true
 			// Remove the headers since the backend should not see it
 			req.Header.Del(routeservice.RouteServiceSignature)
 			req.Header.Del(routeservice.RouteServiceMetadata)
-			req.Header.Del(routeservice.RouteServiceForwardedURL)
+			r.delForwardedURL(req)
+			if !r.toBackends(rw, reqInfo) {
+				return
+			}
 		} else {
 			var err error
 			// should not hardcode http, will be addressed by #100982038
@@ -103,7 +128,7 @@ func (r *routeService) ServeHTTP(rw http.ResponseWriter, req *http.Request, next
 			}
 			req.Header.Set(routeservice.RouteServiceSignature, routeServiceArgs.Signature)
 			req.Header.Set(routeservice.RouteServiceMetadata, routeServiceArgs.Metadata)
-			req.Header.Set(routeservice.RouteServiceForwardedURL, routeServiceArgs.ForwardedURL)
+			req.Header.Set(r.forwardedURLHeader(), routeServiceArgs.ForwardedURL)
 
 			reqInfo.RouteServiceURL = routeServiceArgs.ParsedUrl
 
@@ -118,6 +143,80 @@ func (r *routeService) ServeHTTP(rw http.ResponseWriter, req *http.Request, next
 	next(rw, req)
 }
 
+// toBackends leaves the endpoints standing for the route service out of the
+// pool of a request forwarded to the backends of its route. A route without
+// backends gets a 502, as its route service was meant to answer the request
+// itself, and false is returned.
+func (r *routeService) toBackends(rw http.ResponseWriter, reqInfo *RequestInfo) bool {
+	backends := reqInfo.RoutePool.Backends()
+	if backends == nil {
+		r.logger.Info("route-service-only-forwarded")
+
+		rw.Header().Set("X-Cf-RouterError", "route_service_only")
+		writeStatus(
+			rw,
+			http.StatusBadGateway,
+			"The route has no backends; its route service must respond to requests itself.",
+			r.logger,
+		)
+		return false
+	}
+	reqInfo.RoutePool = backends
+	return true
+}
+
+// forwardedURLOf returns the URL of req sent to the route service, and signed
+// so that the request the route service sends back can be matched to it
+func (r *routeService) forwardedURLOf(req *http.Request) string {
+	scheme := "http"
+	if r.config.RouteServiceRecommendHttps() {
+		scheme = "https"
+	}
+	if r.forwardedURL.PreserveScheme {
+		scheme = requestScheme(req)
+	}
+
+	host := hostWithoutPort(req.Host)
+	if r.forwardedURL.PreservePort {
+		host = req.Host
+	}
+
+	uri := req.RequestURI
+	if r.forwardedURL.Query == config.FORWARDED_URL_QUERY_STRIP {
+		if i := strings.IndexByte(uri, '?'); i >= 0 {
+			uri = uri[:i]
+		}
+	}
+	return scheme + "://" + host + uri
+}
+
+func (r *routeService) forwardedURLHeader() string {
+	if r.forwardedURL.Header != "" {
+		return r.forwardedURL.Header
+	}
+	return routeservice.RouteServiceForwardedURL
+}
+
+// delForwardedURL keeps the backend from seeing the forwarded URL, under
+// either name
+func (r *routeService) delForwardedURL(req *http.Request) {
+	req.Header.Del(routeservice.RouteServiceForwardedURL)
+	req.Header.Del(r.forwardedURLHeader())
+}
+
+// requestScheme returns the scheme the client used, as forwarded by a load
+// balancer or as seen by gorouter
+func requestScheme(req *http.Request) string {
+	switch proto := strings.ToLower(req.Header.Get("X-Forwarded-Proto")); proto {
+	case "http", "https":
+		return proto
+	}
+	if req.TLS != nil {
+		return "https"
+	}
+	return "http"
+}
+
 func hasBeenToRouteService(rsUrl, sigHeader string) bool {
 	return sigHeader != "" && rsUrl != ""
 }
@@ -0,0 +1,58 @@
+package handlers
+
+import (
+	"net"
+	"net/http"
+	"time"
+
+	"code.cloudfoundry.org/gorouter/config"
+	"code.cloudfoundry.org/gorouter/logger"
+	"code.cloudfoundry.org/gorouter/routeservice"
+
+	"github.com/uber-go/zap"
+	"github.com/urfave/negroni"
+)
+
+type routeServiceBypass struct {
+	trusted []*net.IPNet
+	secret  []byte
+	maxAge  time.Duration
+	logger  logger.Logger
+}
+
+// NewRouteServiceBypass creates a handler that marks requests from trusted
+// sources, or with a valid bypass signature, to skip route services. The
+// bypass header is not forwarded.
+func NewRouteServiceBypass(cfg config.RouteServiceBypassConfig, logger logger.Logger) negroni.Handler {
+	return &routeServiceBypass{
+		trusted: cfg.TrustedNetworks,
+		secret:  []byte(cfg.Secret),
+		maxAge:  cfg.MaxAge,
+		logger:  logger,
+	}
+}
+
+func (b *routeServiceBypass) ServeHTTP(rw http.ResponseWriter, r *http.Request, next http.HandlerFunc) {
+	requestInfo, err := ContextRequestInfo(r)
+	if err != nil {
+		b.logger.Fatal("request-info-err", zap.Error(err))
+		return
+	}
+
+	signed := r.Header.Get(routeservice.RouteServiceBypass)
+	r.Header.Del(routeservice.RouteServiceBypass)
+
+	switch {
+	case remoteIPIn(r.RemoteAddr, b.trusted):
+		requestInfo.RouteServiceBypassed = true
+	case signed != "" && len(b.secret) > 0:
+		err := routeservice.VerifyBypass(b.secret, hostWithoutPort(r.Host), signed, b.maxAge, time.Now())
+		if err != nil {
+			b.logger.Info("route-service-bypass-rejected", zap.String("host", r.Host), zap.Error(err))
+		} else {
+			requestInfo.RouteServiceBypassed = true
+		}
+	}
+
+	next(rw, r)
+}
@@ -0,0 +1,94 @@
+package handlers_test
+
+import (
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"time"
+
+	"code.cloudfoundry.org/gorouter/config"
+	"code.cloudfoundry.org/gorouter/handlers"
+	logger_fakes "code.cloudfoundry.org/gorouter/logger/fakes"
+	"code.cloudfoundry.org/gorouter/routeservice"
+	"code.cloudfoundry.org/gorouter/test_util"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/urfave/negroni"
+)
+
+var _ = Describe("RouteServiceBypass", func() {
+	var (
+		cfg      config.RouteServiceBypassConfig
+		req      *http.Request
+		bypassed bool
+		header   string
+	)
+
+	BeforeEach(func() {
+		_, trusted, err := net.ParseCIDR("10.0.0.0/8")
+		Expect(err).NotTo(HaveOccurred())
+
+		cfg = config.RouteServiceBypassConfig{
+			TrustedNetworks: []*net.IPNet{trusted},
+			Secret:          "bypass-secret",
+			MaxAge:          5 * time.Minute,
+		}
+		req = test_util.NewRequest("GET", "app.example.com", "/", nil)
+		req.RemoteAddr = "192.168.0.1:4567"
+	})
+
+	JustBeforeEach(func() {
+		handler := negroni.New()
+		handler.Use(handlers.NewRequestInfo())
+		handler.Use(handlers.NewRouteServiceBypass(cfg, new(logger_fakes.FakeLogger)))
+		handler.UseHandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+			requestInfo, err := handlers.ContextRequestInfo(req)
+			Expect(err).NotTo(HaveOccurred())
+			bypassed = requestInfo.RouteServiceBypassed
+			header = req.Header.Get(routeservice.RouteServiceBypass)
+		})
+		handler.ServeHTTP(httptest.NewRecorder(), req)
+	})
+
+	It("does not bypass route services for other clients", func() {
+		Expect(bypassed).To(BeFalse())
+	})
+
+	Context("when the request comes from a trusted source", func() {
+		BeforeEach(func() {
+			req.RemoteAddr = "10.1.2.3:4567"
+		})
+
+		It("bypasses route services", func() {
+			Expect(bypassed).To(BeTrue())
+		})
+	})
+
+	Context("when the request carries a valid bypass signature", func() {
+		BeforeEach(func() {
+			req.Header.Set(routeservice.RouteServiceBypass,
+				routeservice.SignBypass([]byte("bypass-secret"), "app.example.com", time.Now()))
+		})
+
+		It("bypasses route services", func() {
+			Expect(bypassed).To(BeTrue())
+		})
+
+		It("does not forward the header", func() {
+			Expect(header).To(BeEmpty())
+		})
+	})
+
+	Context("when the request carries an invalid bypass signature", func() {
+		BeforeEach(func() {
+			req.Header.Set(routeservice.RouteServiceBypass,
+				routeservice.SignBypass([]byte("guessed"), "app.example.com", time.Now()))
+		})
+
+		It("does not bypass route services", func() {
+			Expect(bypassed).To(BeFalse())
+			Expect(header).To(BeEmpty())
+		})
+	})
+})
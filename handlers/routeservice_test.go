@@ -10,6 +10,7 @@ import (
 	"time"
 
 	"code.cloudfoundry.org/gorouter/common/secure"
+	gorouterconfig "code.cloudfoundry.org/gorouter/config"
 	"code.cloudfoundry.org/gorouter/handlers"
 	"code.cloudfoundry.org/gorouter/route"
 	"code.cloudfoundry.org/gorouter/routeservice"
@@ -33,16 +34,18 @@ var _ = Describe("Route Service Handler", func() {
 		resp *httptest.ResponseRecorder
 		req  *http.Request
 
-		config       *routeservice.RouteServiceConfig
-		crypto       *secure.AesGCM
-		routePool    *route.Pool
-		forwardedUrl string
+		config             *routeservice.RouteServiceConfig
+		forwardedURLConfig gorouterconfig.RouteServiceForwardedURLConfig
+		crypto             *secure.AesGCM
+		routePool          *route.Pool
+		forwardedUrl       string
 
 		fakeLogger *logger_fakes.FakeLogger
 
 		reqChan chan *http.Request
 
 		nextCalled bool
+		bypassed   bool
 	)
 
 	nextHandler := http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
@@ -60,6 +63,7 @@ var _ = Describe("Route Service Handler", func() {
 		reqInfo, err := handlers.ContextRequestInfo(req)
 		Expect(err).ToNot(HaveOccurred())
 		reqInfo.RoutePool = routePool
+		reqInfo.RouteServiceBypassed = bypassed
 		next(rw, req)
 	}
 
@@ -88,7 +92,10 @@ var _ = Describe("Route Service Handler", func() {
 			fakeLogger, true, 60*time.Second, crypto, nil, true,
 		)
 
+		forwardedURLConfig = gorouterconfig.RouteServiceForwardedURLConfig{}
+
 		nextCalled = false
+		bypassed = false
 	})
 
 	AfterEach(func() {
@@ -99,7 +106,7 @@ var _ = Describe("Route Service Handler", func() {
 		handler = negroni.New()
 		handler.Use(handlers.NewRequestInfo())
 		handler.UseFunc(testSetupHandler)
-		handler.Use(handlers.NewRouteService(config, fakeLogger, reg))
+		handler.Use(handlers.NewRouteService(config, forwardedURLConfig, fakeLogger, reg))
 		handler.UseHandlerFunc(nextHandler)
 	})
 
@@ -243,6 +250,28 @@ var _ = Describe("Route Service Handler", func() {
 				})
 			})
 
+			Context("when the request bypasses route services", func() {
+				BeforeEach(func() {
+					bypassed = true
+					req.Header.Set(routeservice.RouteServiceSignature, "forged")
+				})
+
+				It("sends the request to the backend", func() {
+					handler.ServeHTTP(resp, req)
+
+					var passedReq *http.Request
+					Eventually(reqChan).Should(Receive(&passedReq))
+
+					Expect(passedReq.Header.Get(routeservice.RouteServiceSignature)).To(BeEmpty())
+					Expect(passedReq.Header.Get(routeservice.RouteServiceForwardedURL)).To(BeEmpty())
+
+					reqInfo, err := handlers.ContextRequestInfo(passedReq)
+					Expect(err).ToNot(HaveOccurred())
+					Expect(reqInfo.RouteServiceURL).To(BeNil())
+					Expect(nextCalled).To(BeTrue(), "Expected the next handler to be called.")
+				})
+			})
+
 			Context("when recommendHttps is set to false", func() {
 				BeforeEach(func() {
 					config = routeservice.NewRouteServiceConfig(
@@ -272,6 +301,96 @@ var _ = Describe("Route Service Handler", func() {
 				})
 			})
 
+			Context("with forwarded url options", func() {
+				forwardedURLOf := func() string {
+					handler.ServeHTTP(resp, req)
+
+					var passedReq *http.Request
+					Eventually(reqChan).Should(Receive(&passedReq))
+					return passedReq.Header.Get(routeservice.RouteServiceForwardedURL)
+				}
+
+				BeforeEach(func() {
+					req.Host = "my_host.com:8443"
+				})
+
+				Context("when preserving the scheme", func() {
+					BeforeEach(func() {
+						forwardedURLConfig.PreserveScheme = true
+						req.Header.Set("X-Forwarded-Proto", "http")
+					})
+
+					It("keeps the scheme the client used", func() {
+						Expect(forwardedURLOf()).To(HavePrefix("http://my_host.com/"))
+					})
+				})
+
+				Context("when preserving the port", func() {
+					BeforeEach(func() {
+						forwardedURLConfig.PreservePort = true
+					})
+
+					It("keeps the port of the host", func() {
+						Expect(forwardedURLOf()).To(HavePrefix("https://my_host.com:8443/resource"))
+					})
+				})
+
+				Context("when stripping the query", func() {
+					BeforeEach(func() {
+						forwardedURLConfig.Query = gorouterconfig.FORWARDED_URL_QUERY_STRIP
+					})
+
+					It("sends the url without its query", func() {
+						Expect(forwardedURLOf()).To(Equal("https://my_host.com/resource+9-9_9"))
+					})
+				})
+
+				Context("with a custom header", func() {
+					BeforeEach(func() {
+						forwardedURLConfig.Header = "X-Original-Url"
+					})
+
+					It("sends the url in the configured header", func() {
+						handler.ServeHTTP(resp, req)
+
+						var passedReq *http.Request
+						Eventually(reqChan).Should(Receive(&passedReq))
+						Expect(passedReq.Header.Get("X-Original-Url")).To(HavePrefix("https://my_host.com/"))
+						Expect(passedReq.Header.Get(routeservice.RouteServiceForwardedURL)).To(BeEmpty())
+					})
+				})
+
+				Context("when the request comes back from the route service", func() {
+					BeforeEach(func() {
+						forwardedURLConfig = gorouterconfig.RouteServiceForwardedURLConfig{
+							PreservePort: true,
+							Query:        gorouterconfig.FORWARDED_URL_QUERY_STRIP,
+							Header:       "X-Original-Url",
+						}
+						req.RequestURI = "/resource+9-9_9"
+						reqArgs, err := config.Request("", "https://my_host.com:8443/resource+9-9_9")
+						Expect(err).ToNot(HaveOccurred())
+						req.Header.Set(routeservice.RouteServiceSignature, reqArgs.Signature)
+						req.Header.Set(routeservice.RouteServiceMetadata, reqArgs.Metadata)
+						req.Header.Set("X-Original-Url", reqArgs.ForwardedURL)
+					})
+
+					It("validates the signature against the same url and strips the header", func() {
+						handler.ServeHTTP(resp, req)
+
+						Expect(resp.Code).To(Equal(http.StatusTeapot))
+
+						var passedReq *http.Request
+						Eventually(reqChan).Should(Receive(&passedReq))
+						Expect(passedReq.Header.Get("X-Original-Url")).To(BeEmpty())
+
+						reqInfo, err := handlers.ContextRequestInfo(passedReq)
+						Expect(err).ToNot(HaveOccurred())
+						Expect(reqInfo.FromRouteService).To(BeTrue())
+					})
+				})
+			})
+
 			Context("when a request has a valid route service signature and metadata header", func() {
 				BeforeEach(func() {
 					reqArgs, err := config.Request("", forwardedUrl)
@@ -294,6 +413,7 @@ var _ = Describe("Route Service Handler", func() {
 					reqInfo, err := handlers.ContextRequestInfo(passedReq)
 					Expect(err).ToNot(HaveOccurred())
 					Expect(reqInfo.RouteServiceURL).To(BeNil())
+					Expect(reqInfo.FromRouteService).To(BeTrue())
 					Expect(nextCalled).To(BeTrue(), "Expected the next handler to be called.")
 				})
 			})
@@ -504,6 +624,89 @@ var _ = Describe("Route Service Handler", func() {
 			})
 		})
 
+		Context("for route-service-only routes", func() {
+			var rsOnly *route.Endpoint
+
+			BeforeEach(func() {
+				rsOnly = route.NewEndpoint(
+					"appId", "", 0, "", "", map[string]string{}, 0,
+					"https://route-service.com", models.ModificationTag{}, "",
+				)
+				rsOnly.RouteServiceOnly = true
+				Expect(routePool.Put(rsOnly)).To(BeTrue())
+			})
+
+			It("sends the request to the route service", func() {
+				handler.ServeHTTP(resp, req)
+
+				var passedReq *http.Request
+				Eventually(reqChan).Should(Receive(&passedReq))
+
+				reqInfo, err := handlers.ContextRequestInfo(passedReq)
+				Expect(err).ToNot(HaveOccurred())
+				Expect(reqInfo.RouteServiceURL.Host).To(Equal("route-service.com"))
+			})
+
+			Context("when the request bypasses route services", func() {
+				BeforeEach(func() {
+					bypassed = true
+				})
+
+				It("sends the request to the route service all the same", func() {
+					handler.ServeHTTP(resp, req)
+
+					var passedReq *http.Request
+					Eventually(reqChan).Should(Receive(&passedReq))
+
+					Expect(passedReq.Header.Get(routeservice.RouteServiceSignature)).ToNot(BeEmpty())
+					reqInfo, err := handlers.ContextRequestInfo(passedReq)
+					Expect(err).ToNot(HaveOccurred())
+					Expect(reqInfo.RouteServiceURL).ToNot(BeNil())
+					Expect(reqInfo.RouteServiceBypassed).To(BeFalse())
+				})
+			})
+
+			Context("when the route service forwards the request", func() {
+				BeforeEach(func() {
+					reqArgs, err := config.Request("", forwardedUrl)
+					Expect(err).ToNot(HaveOccurred())
+					req.Header.Set(routeservice.RouteServiceSignature, reqArgs.Signature)
+					req.Header.Set(routeservice.RouteServiceMetadata, reqArgs.Metadata)
+				})
+
+				It("returns 502 Bad Gateway", func() {
+					handler.ServeHTTP(resp, req)
+
+					Expect(resp.Code).To(Equal(http.StatusBadGateway))
+					Expect(resp.Header().Get("X-Cf-RouterError")).To(Equal("route_service_only"))
+					Expect(nextCalled).To(BeFalse())
+				})
+
+				Context("when the route has backends too", func() {
+					BeforeEach(func() {
+						endpoint := route.NewEndpoint(
+							"appId", "1.1.1.1", uint16(9090), "id", "1", map[string]string{}, 0,
+							"https://route-service.com", models.ModificationTag{}, "",
+						)
+						Expect(routePool.Put(endpoint)).To(BeTrue())
+					})
+
+					It("sends the request to the backends only", func() {
+						handler.ServeHTTP(resp, req)
+
+						var passedReq *http.Request
+						Eventually(reqChan).Should(Receive(&passedReq))
+
+						reqInfo, err := handlers.ContextRequestInfo(passedReq)
+						Expect(err).ToNot(HaveOccurred())
+						Expect(reqInfo.FromRouteService).To(BeTrue())
+						Expect(reqInfo.RoutePool.NumEndpoints()).To(Equal(1))
+						Expect(reqInfo.RoutePool.Endpoints("", "").Next().CanonicalAddr()).To(Equal("1.1.1.1:9090"))
+					})
+				})
+			})
+		})
+
 		Context("when a bad route service url is used", func() {
 			BeforeEach(func() {
 				endpoint := route.NewEndpoint(
@@ -530,7 +733,7 @@ var _ = Describe("Route Service Handler", func() {
 		var badHandler *negroni.Negroni
 		BeforeEach(func() {
 			badHandler = negroni.New()
-			badHandler.Use(handlers.NewRouteService(config, fakeLogger, reg))
+			badHandler.Use(handlers.NewRouteService(config, forwardedURLConfig, fakeLogger, reg))
 			badHandler.UseHandlerFunc(nextHandler)
 		})
 		It("calls Fatal on the logger", func() {
@@ -545,7 +748,7 @@ var _ = Describe("Route Service Handler", func() {
 		BeforeEach(func() {
 			badHandler = negroni.New()
 			badHandler.Use(handlers.NewRequestInfo())
-			badHandler.Use(handlers.NewRouteService(config, fakeLogger, reg))
+			badHandler.Use(handlers.NewRouteService(config, forwardedURLConfig, fakeLogger, reg))
 			badHandler.UseHandlerFunc(nextHandler)
 		})
 		It("calls Fatal on the logger", func() {
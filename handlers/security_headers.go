@@ -0,0 +1,122 @@
+package handlers
+
+import (
+	"io"
+	"net/http"
+
+	"github.com/uber-go/zap"
+	"github.com/urfave/negroni"
+
+	"code.cloudfoundry.org/gorouter/config"
+	"code.cloudfoundry.org/gorouter/logger"
+	"code.cloudfoundry.org/gorouter/proxy/utils"
+)
+
+// securityHeaderOff in a route's security headers drops the header for the
+// route
+const securityHeaderOff = "off"
+
+type securityHeaders struct {
+	defaults config.SecurityHeaders
+	logger   logger.Logger
+}
+
+// NewSecurityHeaders creates a handler adding the security headers a
+// response lacks, taking the overrides of the request's route over defaults.
+// Responses written before lookup, such as unknown route errors, only get
+// the defaults.
+func NewSecurityHeaders(defaults config.SecurityHeaders, logger logger.Logger) negroni.Handler {
+	return &securityHeaders{
+		defaults: defaults,
+		logger:   logger,
+	}
+}
+
+func (s *securityHeaders) ServeHTTP(rw http.ResponseWriter, r *http.Request, next http.HandlerFunc) {
+	requestInfo, err := ContextRequestInfo(r)
+	if err != nil {
+		s.logger.Fatal("request-info-err", zap.Error(err))
+		return
+	}
+
+	proxyWriter := &securityHeadersResponseWriter{
+		ProxyResponseWriter: rw.(utils.ProxyResponseWriter),
+		defaults:            s.defaults,
+		requestInfo:         requestInfo,
+		secure:              r.TLS != nil || r.Header.Get("X-Forwarded-Proto") == "https",
+	}
+	requestInfo.ProxyResponseWriter = proxyWriter
+	next(proxyWriter, r)
+}
+
+// securityHeadersResponseWriter adds the security headers missing from the
+// response when its header is written, once the route is known
+type securityHeadersResponseWriter struct {
+	utils.ProxyResponseWriter
+	defaults    config.SecurityHeaders
+	requestInfo *RequestInfo
+	secure      bool
+	wroteHeader bool
+}
+
+func (w *securityHeadersResponseWriter) WriteHeader(status int) {
+	if !w.wroteHeader && status >= http.StatusOK {
+		w.wroteHeader = true
+
+		headers := w.defaults
+		if w.requestInfo.RoutePool != nil {
+			headers = overrideSecurityHeaders(headers, w.requestInfo.RoutePool.SecurityHeaders())
+		}
+
+		if w.secure {
+			w.setMissing("Strict-Transport-Security", headers.StrictTransportSecurity)
+		}
+		w.setMissing("X-Content-Type-Options", headers.ContentTypeOptions)
+		w.setMissing("X-Frame-Options", headers.FrameOptions)
+		w.setMissing("Content-Security-Policy", headers.ContentSecurityPolicy)
+	}
+	w.ProxyResponseWriter.WriteHeader(status)
+}
+
+func (w *securityHeadersResponseWriter) Write(b []byte) (int, error) {
+	if !w.wroteHeader {
+		w.WriteHeader(http.StatusOK)
+	}
+	return w.ProxyResponseWriter.Write(b)
+}
+
+func (w *securityHeadersResponseWriter) ReadFrom(r io.Reader) (int64, error) {
+	if !w.wroteHeader {
+		w.WriteHeader(http.StatusOK)
+	}
+	return utils.ReadFrom(w.ProxyResponseWriter, r)
+}
+
+func (w *securityHeadersResponseWriter) setMissing(name, value string) {
+	if value == "" || value == securityHeaderOff {
+		return
+	}
+	header := w.Header()
+	if header.Get(name) == "" {
+		header.Set(name, value)
+	}
+}
+
+func overrideSecurityHeaders(headers config.SecurityHeaders, route *config.SecurityHeaders) config.SecurityHeaders {
+	if route == nil {
+		return headers
+	}
+	if route.StrictTransportSecurity != "" {
+		headers.StrictTransportSecurity = route.StrictTransportSecurity
+	}
+	if route.ContentTypeOptions != "" {
+		headers.ContentTypeOptions = route.ContentTypeOptions
+	}
+	if route.FrameOptions != "" {
+		headers.FrameOptions = route.FrameOptions
+	}
+	if route.ContentSecurityPolicy != "" {
+		headers.ContentSecurityPolicy = route.ContentSecurityPolicy
+	}
+	return headers
+}
@@ -0,0 +1,122 @@
+package handlers_test
+
+import (
+	"crypto/tls"
+	"net/http"
+	"net/http/httptest"
+
+	"code.cloudfoundry.org/gorouter/config"
+	"code.cloudfoundry.org/gorouter/handlers"
+	logger_fakes "code.cloudfoundry.org/gorouter/logger/fakes"
+	"code.cloudfoundry.org/gorouter/route"
+	"code.cloudfoundry.org/routing-api/models"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/urfave/negroni"
+)
+
+var _ = Describe("SecurityHeaders", func() {
+	var (
+		handler        *negroni.Negroni
+		pool           *route.Pool
+		backendHeaders http.Header
+		req            *http.Request
+	)
+
+	defaults := config.SecurityHeaders{
+		StrictTransportSecurity: "max-age=31536000",
+		ContentTypeOptions:      "nosniff",
+		FrameOptions:            "SAMEORIGIN",
+	}
+
+	JustBeforeEach(func() {
+		fakeLogger := new(logger_fakes.FakeLogger)
+		handler = negroni.New()
+		handler.Use(handlers.NewRequestInfo())
+		handler.Use(handlers.NewProxyWriter(fakeLogger))
+		handler.Use(handlers.NewSecurityHeaders(defaults, fakeLogger))
+		handler.UseFunc(func(rw http.ResponseWriter, req *http.Request, next http.HandlerFunc) {
+			reqInfo, err := handlers.ContextRequestInfo(req)
+			Expect(err).NotTo(HaveOccurred())
+			reqInfo.RoutePool = pool
+			next(rw, req)
+		})
+		handler.UseHandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+			for name, values := range backendHeaders {
+				rw.Header()[name] = values
+			}
+			rw.Write([]byte("backend"))
+		})
+	})
+
+	BeforeEach(func() {
+		pool = route.NewPool(0, "")
+		backendHeaders = http.Header{}
+		req = httptest.NewRequest("GET", "http://app.example.com/", nil)
+		req.TLS = &tls.ConnectionState{}
+	})
+
+	serve := func() http.Header {
+		resp := httptest.NewRecorder()
+		handler.ServeHTTP(resp, req)
+		Expect(resp.Body.String()).To(Equal("backend"))
+		return resp.Header()
+	}
+
+	It("adds the default headers", func() {
+		header := serve()
+		Expect(header.Get("Strict-Transport-Security")).To(Equal("max-age=31536000"))
+		Expect(header.Get("X-Content-Type-Options")).To(Equal("nosniff"))
+		Expect(header.Get("X-Frame-Options")).To(Equal("SAMEORIGIN"))
+		Expect(header).NotTo(HaveKey("Content-Security-Policy"))
+	})
+
+	It("keeps the headers the backend set", func() {
+		backendHeaders.Set("X-Frame-Options", "DENY")
+		Expect(serve().Get("X-Frame-Options")).To(Equal("DENY"))
+	})
+
+	Context("when the request is not secure", func() {
+		BeforeEach(func() {
+			req.TLS = nil
+		})
+
+		It("does not add Strict-Transport-Security", func() {
+			Expect(serve()).NotTo(HaveKey("Strict-Transport-Security"))
+		})
+
+		It("adds it when forwarded from https", func() {
+			req.Header.Set("X-Forwarded-Proto", "https")
+			Expect(serve().Get("Strict-Transport-Security")).To(Equal("max-age=31536000"))
+		})
+	})
+
+	Context("when the route overrides the headers", func() {
+		BeforeEach(func() {
+			endpoint := route.NewEndpoint("app", "1.2.3.4", 5678, "", "", nil, -1, "", models.ModificationTag{}, "")
+			endpoint.SecurityHeaders = &config.SecurityHeaders{
+				FrameOptions:          "off",
+				ContentSecurityPolicy: "default-src 'self'",
+			}
+			pool.Put(endpoint)
+		})
+
+		It("uses the route's headers over the defaults", func() {
+			header := serve()
+			Expect(header).NotTo(HaveKey("X-Frame-Options"))
+			Expect(header.Get("Content-Security-Policy")).To(Equal("default-src 'self'"))
+			Expect(header.Get("X-Content-Type-Options")).To(Equal("nosniff"))
+		})
+	})
+
+	Context("when the route is unknown", func() {
+		BeforeEach(func() {
+			pool = nil
+		})
+
+		It("adds the default headers", func() {
+			Expect(serve().Get("X-Content-Type-Options")).To(Equal("nosniff"))
+		})
+	})
+})
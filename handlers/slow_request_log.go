@@ -0,0 +1,108 @@
+package handlers
+
+import (
+	"net/http"
+	"time"
+
+	"code.cloudfoundry.org/gorouter/config"
+	"code.cloudfoundry.org/gorouter/logger"
+	"code.cloudfoundry.org/gorouter/proxy/utils"
+
+	"github.com/uber-go/zap"
+	"github.com/urfave/negroni"
+)
+
+type slowRequestLog struct {
+	totalThreshold   time.Duration
+	backendThreshold time.Duration
+	logger           logger.Logger
+}
+
+type attemptLogData struct {
+	Endpoint  string  `json:"endpoint"`
+	StartedAt string  `json:"started_at"`
+	Ms        float64 `json:"ms"`
+	Error     string  `json:"error,omitempty"`
+}
+
+// NewSlowRequestLog creates a handler that logs requests taking longer than
+// either threshold at warn level, with where the time was spent
+func NewSlowRequestLog(cfg config.SlowRequestLogConfig, logger logger.Logger) negroni.Handler {
+	return &slowRequestLog{
+		totalThreshold:   cfg.TotalThreshold,
+		backendThreshold: cfg.BackendThreshold,
+		logger:           logger,
+	}
+}
+
+func (s *slowRequestLog) ServeHTTP(rw http.ResponseWriter, r *http.Request, next http.HandlerFunc) {
+	next(rw, r)
+
+	if s.totalThreshold <= 0 && s.backendThreshold <= 0 {
+		return
+	}
+
+	requestInfo, err := ContextRequestInfo(r)
+	if err != nil {
+		s.logger.Error("request-info-err", zap.Error(err))
+		return
+	}
+
+	total := time.Since(requestInfo.StartedAt)
+	var backend time.Duration
+	for _, attempt := range requestInfo.Attempts {
+		backend += attempt.Duration
+	}
+
+	if !(s.totalThreshold > 0 && total >= s.totalThreshold) &&
+		!(s.backendThreshold > 0 && backend >= s.backendThreshold) {
+		return
+	}
+
+	// Routing is the time before the first attempt, response the time spent
+	// streaming the response to the client after the last attempt returned
+	routing, response := total, time.Duration(0)
+	attempts := make([]attemptLogData, len(requestInfo.Attempts))
+	for i, attempt := range requestInfo.Attempts {
+		attempts[i] = attemptLogData{
+			Endpoint:  attempt.Endpoint,
+			StartedAt: attempt.StartedAt.Format(time.RFC3339Nano),
+			Ms:        milliseconds(attempt.Duration),
+		}
+		if attempt.Err != nil {
+			attempts[i].Error = attempt.Err.Error()
+		}
+	}
+	if n := len(requestInfo.Attempts); n > 0 {
+		routing = requestInfo.Attempts[0].StartedAt.Sub(requestInfo.StartedAt)
+		last := requestInfo.Attempts[n-1]
+		response = total - last.StartedAt.Add(last.Duration).Sub(requestInfo.StartedAt)
+	}
+
+	var endpoint string
+	if requestInfo.RouteEndpoint != nil {
+		endpoint = requestInfo.RouteEndpoint.CanonicalAddr()
+	}
+	var status int
+	if proxyWriter, ok := rw.(utils.ProxyResponseWriter); ok {
+		status = proxyWriter.Status()
+	}
+
+	s.logger.Warn("slow-request",
+		zap.String("host", r.Host),
+		zap.String("method", r.Method),
+		zap.String("uri", r.URL.RequestURI()),
+		zap.String("vcap-request-id", r.Header.Get(VcapRequestIdHeader)),
+		zap.Int("status", status),
+		zap.String("endpoint", endpoint),
+		zap.Float64("total-ms", milliseconds(total)),
+		zap.Float64("routing-ms", milliseconds(routing)),
+		zap.Float64("backend-ms", milliseconds(backend)),
+		zap.Float64("response-ms", milliseconds(response)),
+		zap.Object("attempts", attempts),
+	)
+}
+
+func milliseconds(d time.Duration) float64 {
+	return float64(d) / float64(time.Millisecond)
+}
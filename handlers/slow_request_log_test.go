@@ -0,0 +1,99 @@
+package handlers_test
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"time"
+
+	"code.cloudfoundry.org/gorouter/config"
+	"code.cloudfoundry.org/gorouter/handlers"
+	logger_fakes "code.cloudfoundry.org/gorouter/logger/fakes"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/urfave/negroni"
+)
+
+var _ = Describe("SlowRequestLog", func() {
+	var (
+		handler    *negroni.Negroni
+		cfg        config.SlowRequestLogConfig
+		attempts   []handlers.BackendAttempt
+		delay      time.Duration
+		fakeLogger *logger_fakes.FakeLogger
+	)
+
+	BeforeEach(func() {
+		cfg = config.SlowRequestLogConfig{}
+		attempts = nil
+		delay = 0
+		fakeLogger = new(logger_fakes.FakeLogger)
+	})
+
+	JustBeforeEach(func() {
+		handler = negroni.New()
+		handler.Use(handlers.NewRequestInfo())
+		handler.Use(handlers.NewProxyWriter(fakeLogger))
+		handler.Use(handlers.NewSlowRequestLog(cfg, fakeLogger))
+		handler.UseHandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+			reqInfo, err := handlers.ContextRequestInfo(req)
+			Expect(err).NotTo(HaveOccurred())
+			reqInfo.Attempts = attempts
+			time.Sleep(delay)
+			rw.WriteHeader(http.StatusTeapot)
+		})
+
+		handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "http://example.com/foo", nil))
+	})
+
+	Context("when the request takes longer than the total threshold", func() {
+		BeforeEach(func() {
+			cfg.TotalThreshold = 10 * time.Millisecond
+			delay = 20 * time.Millisecond
+		})
+
+		It("logs the request at warn level", func() {
+			Expect(fakeLogger.WarnCallCount()).To(Equal(1))
+			message, fields := fakeLogger.WarnArgsForCall(0)
+			Expect(message).To(Equal("slow-request"))
+			Expect(fields).NotTo(BeEmpty())
+		})
+	})
+
+	Context("when the backend attempts take longer than the backend threshold", func() {
+		BeforeEach(func() {
+			cfg.BackendThreshold = time.Second
+			attempts = []handlers.BackendAttempt{
+				{Endpoint: "10.0.0.1:8080", StartedAt: time.Now(), Duration: 600 * time.Millisecond, Err: errors.New("dial tcp: i/o timeout")},
+				{Endpoint: "10.0.0.2:8080", StartedAt: time.Now(), Duration: 500 * time.Millisecond},
+			}
+		})
+
+		It("logs the request", func() {
+			Expect(fakeLogger.WarnCallCount()).To(Equal(1))
+		})
+	})
+
+	Context("when the request is faster than the thresholds", func() {
+		BeforeEach(func() {
+			cfg.TotalThreshold = time.Second
+			cfg.BackendThreshold = time.Second
+			attempts = []handlers.BackendAttempt{{Endpoint: "10.0.0.1:8080", StartedAt: time.Now(), Duration: time.Millisecond}}
+		})
+
+		It("does not log the request", func() {
+			Expect(fakeLogger.WarnCallCount()).To(Equal(0))
+		})
+	})
+
+	Context("when no threshold is configured", func() {
+		BeforeEach(func() {
+			delay = 5 * time.Millisecond
+		})
+
+		It("does not log the request", func() {
+			Expect(fakeLogger.WarnCallCount()).To(Equal(0))
+		})
+	})
+})
@@ -0,0 +1,78 @@
+package handlers
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/uber-go/zap"
+	"github.com/urfave/negroni"
+
+	"code.cloudfoundry.org/gorouter/config"
+	"code.cloudfoundry.org/gorouter/logger"
+)
+
+type upgradeProtocolCheck struct {
+	allowed map[string]bool
+	routes  map[string]map[string]bool
+	logger  logger.Logger
+}
+
+// NewUpgradeProtocolCheck creates a handler that rejects requests asking to
+// upgrade to a protocol which is not allowed for the requested host
+func NewUpgradeProtocolCheck(cfg config.UpgradeProtocolsConfig, logger logger.Logger) negroni.Handler {
+	routes := make(map[string]map[string]bool, len(cfg.Routes))
+	for host, protocols := range cfg.Routes {
+		routes[strings.ToLower(host)] = protocolSet(protocols)
+	}
+
+	return &upgradeProtocolCheck{
+		allowed: protocolSet(cfg.Allowed),
+		routes:  routes,
+		logger:  logger,
+	}
+}
+
+func (u *upgradeProtocolCheck) ServeHTTP(rw http.ResponseWriter, r *http.Request, next http.HandlerFunc) {
+	upgrade := upgradeHeader(r)
+	if upgrade == "" {
+		next(rw, r)
+		return
+	}
+
+	allowed, ok := u.routes[strings.ToLower(hostWithoutPort(r.Host))]
+	if !ok {
+		allowed = u.allowed
+	}
+
+	if len(allowed) > 0 {
+		for _, protocol := range strings.Split(upgrade, ",") {
+			protocol = strings.ToLower(strings.TrimSpace(protocol))
+			if !allowed[protocol] {
+				u.logger.Info("upgrade-protocol-not-allowed", zap.String("protocol", protocol), zap.String("host", r.Host))
+				rw.Header().Set("X-Cf-RouterError", "upgrade_protocol_not_allowed")
+				writeStatus(rw, http.StatusForbidden, "Upgrade protocol is not allowed.", u.logger)
+				return
+			}
+		}
+	}
+
+	next(rw, r)
+}
+
+func upgradeHeader(request *http.Request) string {
+	for _, v := range request.Header[http.CanonicalHeaderKey("Connection")] {
+		if strings.Contains(strings.ToLower(v), "upgrade") {
+			return request.Header.Get("Upgrade")
+		}
+	}
+
+	return ""
+}
+
+func protocolSet(protocols []string) map[string]bool {
+	set := make(map[string]bool, len(protocols))
+	for _, p := range protocols {
+		set[strings.ToLower(p)] = true
+	}
+	return set
+}
@@ -0,0 +1,130 @@
+package handlers_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+
+	"code.cloudfoundry.org/gorouter/config"
+	"code.cloudfoundry.org/gorouter/handlers"
+	logger_fakes "code.cloudfoundry.org/gorouter/logger/fakes"
+	"code.cloudfoundry.org/gorouter/test_util"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/urfave/negroni"
+)
+
+var _ = Describe("UpgradeProtocolCheck", func() {
+	var (
+		handler    *negroni.Negroni
+		logger     *logger_fakes.FakeLogger
+		cfg        config.UpgradeProtocolsConfig
+		resp       *httptest.ResponseRecorder
+		req        *http.Request
+		nextCalled bool
+	)
+
+	nextHandler := http.HandlerFunc(func(http.ResponseWriter, *http.Request) {
+		nextCalled = true
+	})
+
+	BeforeEach(func() {
+		nextCalled = false
+		logger = new(logger_fakes.FakeLogger)
+		cfg = config.UpgradeProtocolsConfig{}
+
+		req = test_util.NewRequest("GET", "example.com", "/", nil)
+		req.Header.Set("Connection", "Upgrade")
+		resp = httptest.NewRecorder()
+	})
+
+	JustBeforeEach(func() {
+		handler = negroni.New()
+		handler.Use(handlers.NewUpgradeProtocolCheck(cfg, logger))
+		handler.UseHandler(nextHandler)
+		handler.ServeHTTP(resp, req)
+	})
+
+	Context("when no protocols are configured", func() {
+		BeforeEach(func() {
+			req.Header.Set("Upgrade", "h2c")
+		})
+
+		It("forwards any upgrade", func() {
+			Expect(nextCalled).To(BeTrue())
+		})
+	})
+
+	Context("when a global allowlist is configured", func() {
+		BeforeEach(func() {
+			cfg.Allowed = []string{"websocket"}
+		})
+
+		Context("and the protocol is allowed", func() {
+			BeforeEach(func() {
+				req.Header.Set("Upgrade", "WebSocket")
+			})
+
+			It("forwards the request", func() {
+				Expect(nextCalled).To(BeTrue())
+			})
+		})
+
+		Context("and the protocol is not allowed", func() {
+			BeforeEach(func() {
+				req.Header.Set("Upgrade", "h2c")
+			})
+
+			It("rejects the request with a 403", func() {
+				Expect(nextCalled).To(BeFalse())
+				Expect(resp.Code).To(Equal(http.StatusForbidden))
+				Expect(resp.Header().Get("X-Cf-RouterError")).To(Equal("upgrade_protocol_not_allowed"))
+			})
+		})
+
+		Context("and one of several protocols is not allowed", func() {
+			BeforeEach(func() {
+				req.Header.Set("Upgrade", "websocket, tcp")
+			})
+
+			It("rejects the request with a 403", func() {
+				Expect(nextCalled).To(BeFalse())
+				Expect(resp.Code).To(Equal(http.StatusForbidden))
+			})
+		})
+
+		Context("and the request is not an upgrade", func() {
+			BeforeEach(func() {
+				req.Header.Del("Connection")
+				req.Header.Set("Upgrade", "h2c")
+			})
+
+			It("forwards the request", func() {
+				Expect(nextCalled).To(BeTrue())
+			})
+		})
+	})
+
+	Context("when a route overrides the global allowlist", func() {
+		BeforeEach(func() {
+			cfg.Allowed = []string{"websocket"}
+			cfg.Routes = map[string][]string{"Example.com": {"tcp"}}
+			req.Header.Set("Upgrade", "tcp")
+		})
+
+		It("uses the route allowlist", func() {
+			Expect(nextCalled).To(BeTrue())
+		})
+
+		Context("for a protocol only allowed globally", func() {
+			BeforeEach(func() {
+				req.Header.Set("Upgrade", "websocket")
+			})
+
+			It("rejects the request with a 403", func() {
+				Expect(nextCalled).To(BeFalse())
+				Expect(resp.Code).To(Equal(http.StatusForbidden))
+			})
+		})
+	})
+})
@@ -0,0 +1,171 @@
+package handlers
+
+import (
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/uber-go/zap"
+	"github.com/urfave/negroni"
+
+	router_http "code.cloudfoundry.org/gorouter/common/http"
+	"code.cloudfoundry.org/gorouter/config"
+	"code.cloudfoundry.org/gorouter/logger"
+)
+
+type urlNormalization struct {
+	config config.URLNormalizationConfig
+	logger logger.Logger
+}
+
+// NewURLNormalization creates a handler rewriting request paths into the
+// canonical form configured. Both the URL routes are looked up by and the
+// request URI forwarded to backends are rewritten, so they cannot disagree.
+func NewURLNormalization(c config.URLNormalizationConfig, logger logger.Logger) negroni.Handler {
+	return &urlNormalization{
+		config: c,
+		logger: logger,
+	}
+}
+
+func (u *urlNormalization) ServeHTTP(rw http.ResponseWriter, r *http.Request, next http.HandlerFunc) {
+	escaped := r.URL.EscapedPath()
+	if r.URL.Opaque != "" || !strings.HasPrefix(escaped, "/") {
+		// e.g. OPTIONS *
+		next(rw, r)
+		return
+	}
+
+	normalized := escaped
+	if u.config.PercentDecoding == config.URL_PERCENT_DECODING_UNRESERVED {
+		normalized = decodeUnreserved(normalized)
+	}
+	if u.config.RejectEncodedSlashes && hasEncodedSlash(normalized) {
+		rw.Header().Set(router_http.CfRouterError, "invalid_path")
+		writeStatus(rw, http.StatusBadRequest, "Encoded slashes are not allowed in the path.", u.logger)
+		return
+	}
+	if u.config.MergeSlashes {
+		normalized = mergeSlashes(normalized)
+	}
+	if u.config.RemoveDotSegments {
+		normalized = removeDotSegments(normalized)
+	}
+
+	if normalized != escaped {
+		path, err := url.PathUnescape(normalized)
+		if err != nil {
+			rw.Header().Set(router_http.CfRouterError, "invalid_path")
+			writeStatus(rw, http.StatusBadRequest, "Invalid path.", u.logger)
+			return
+		}
+
+		u.logger.Debug("url-normalized", zap.String("path", escaped), zap.String("normalized_path", normalized))
+		r.URL.Path = path
+		r.URL.RawPath = normalized
+		r.RequestURI = r.URL.RequestURI()
+	}
+
+	next(rw, r)
+}
+
+// decodeUnreserved decodes the escaped characters of p that never need
+// escaping and capitalizes the hex digits of the other escapes (RFC 3986
+// section 6.2.2)
+func decodeUnreserved(p string) string {
+	if !strings.Contains(p, "%") {
+		return p
+	}
+
+	b := make([]byte, 0, len(p))
+	for i := 0; i < len(p); i++ {
+		if p[i] != '%' || i+2 >= len(p) || !isHexDigit(p[i+1]) || !isHexDigit(p[i+2]) {
+			b = append(b, p[i])
+			continue
+		}
+
+		c := unhex(p[i+1])<<4 | unhex(p[i+2])
+		if isUnreserved(c) {
+			b = append(b, c)
+		} else {
+			b = append(b, '%', upperHex(p[i+1]), upperHex(p[i+2]))
+		}
+		i += 2
+	}
+	return string(b)
+}
+
+func hasEncodedSlash(p string) bool {
+	p = strings.ToUpper(p)
+	return strings.Contains(p, "%2F") || strings.Contains(p, "%5C")
+}
+
+func mergeSlashes(p string) string {
+	if !strings.Contains(p, "//") {
+		return p
+	}
+
+	b := make([]byte, 0, len(p))
+	for i := 0; i < len(p); i++ {
+		if p[i] == '/' && i > 0 && p[i-1] == '/' {
+			continue
+		}
+		b = append(b, p[i])
+	}
+	return string(b)
+}
+
+// removeDotSegments resolves the "." and ".." segments of the absolute path
+// p (RFC 3986 section 5.2.4), escaped dots included. ".." never climbs above
+// the root.
+func removeDotSegments(p string) string {
+	segments := strings.Split(p[1:], "/")
+	resolved := make([]string, 0, len(segments))
+	for i, segment := range segments {
+		last := i == len(segments)-1
+
+		switch strings.Replace(strings.ToLower(segment), "%2e", ".", -1) {
+		case ".":
+		case "..":
+			if len(resolved) > 0 {
+				resolved = resolved[:len(resolved)-1]
+			}
+		default:
+			resolved = append(resolved, segment)
+			continue
+		}
+
+		// a path ending in a dot segment still ends in a slash
+		if last {
+			resolved = append(resolved, "")
+		}
+	}
+	return "/" + strings.Join(resolved, "/")
+}
+
+func isUnreserved(c byte) bool {
+	return 'a' <= c && c <= 'z' || 'A' <= c && c <= 'Z' || '0' <= c && c <= '9' ||
+		c == '-' || c == '.' || c == '_' || c == '~'
+}
+
+func isHexDigit(c byte) bool {
+	return '0' <= c && c <= '9' || 'a' <= c && c <= 'f' || 'A' <= c && c <= 'F'
+}
+
+func upperHex(c byte) byte {
+	if 'a' <= c && c <= 'f' {
+		return c - 'a' + 'A'
+	}
+	return c
+}
+
+func unhex(c byte) byte {
+	switch {
+	case '0' <= c && c <= '9':
+		return c - '0'
+	case 'a' <= c && c <= 'f':
+		return c - 'a' + 10
+	default:
+		return c - 'A' + 10
+	}
+}
@@ -0,0 +1,100 @@
+package handlers_test
+
+import (
+	"bufio"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+
+	"code.cloudfoundry.org/gorouter/config"
+	"code.cloudfoundry.org/gorouter/handlers"
+	"code.cloudfoundry.org/gorouter/test_util"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/urfave/negroni"
+)
+
+var _ = Describe("URLNormalization", func() {
+	var (
+		cfg     config.URLNormalizationConfig
+		nextReq *http.Request
+	)
+
+	// serve sends a request for requestURI as the server would parse it
+	serve := func(requestURI string) *httptest.ResponseRecorder {
+		handler := negroni.New()
+		handler.Use(handlers.NewURLNormalization(cfg, test_util.NewTestZapLogger("url-normalization")))
+		handler.UseHandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+			nextReq = req
+		})
+
+		req, err := http.ReadRequest(bufio.NewReader(strings.NewReader("GET " + requestURI + " HTTP/1.1\r\nHost: example.com\r\n\r\n")))
+		Expect(err).ToNot(HaveOccurred())
+		resp := httptest.NewRecorder()
+		handler.ServeHTTP(resp, req)
+		return resp
+	}
+
+	BeforeEach(func() {
+		cfg = config.URLNormalizationConfig{
+			Enabled:           true,
+			PercentDecoding:   config.URL_PERCENT_DECODING_UNRESERVED,
+			RemoveDotSegments: true,
+			MergeSlashes:      true,
+		}
+		nextReq = nil
+	})
+
+	It("rewrites the path routes are looked up by and the request URI forwarded alike", func() {
+		serve("/app/static/../api?x=1")
+		Expect(nextReq.URL.EscapedPath()).To(Equal("/app/api"))
+		Expect(nextReq.RequestURI).To(Equal("/app/api?x=1"))
+	})
+
+	It("normalizes paths", func() {
+		for requestURI, normalized := range map[string]string{
+			"/a/./b/":             "/a/b/",
+			"/a/b/..":             "/a/",
+			"/../../etc/passwd":   "/etc/passwd",
+			"//a///b":             "/a/b",
+			"/%7euser/%2e%2E/x":   "/x",
+			"/a%3ab/%41":          "/a%3Ab/A",
+			"/with%20space/./doc": "/with%20space/doc",
+		} {
+			Expect(serve(requestURI).Code).To(Equal(http.StatusOK))
+			Expect(nextReq.RequestURI).To(Equal(normalized), requestURI)
+		}
+	})
+
+	It("leaves requests already normalized alone", func() {
+		serve("/a/b%2Fc?q")
+		Expect(nextReq.RequestURI).To(Equal("/a/b%2Fc?q"))
+	})
+
+	It("applies only the normalizations configured", func() {
+		cfg.PercentDecoding = config.URL_PERCENT_DECODING_NONE
+		cfg.MergeSlashes = false
+
+		serve("/%7Ea//b/../c")
+		Expect(nextReq.RequestURI).To(Equal("/%7Ea//c"))
+	})
+
+	It("still resolves escaped dot segments without decoding", func() {
+		cfg.PercentDecoding = config.URL_PERCENT_DECODING_NONE
+
+		serve("/a/%2e%2e/b")
+		Expect(nextReq.RequestURI).To(Equal("/b"))
+	})
+
+	It("rejects encoded slashes when configured to", func() {
+		cfg.RejectEncodedSlashes = true
+
+		for _, requestURI := range []string{"/a%2fb", "/a%5Cb"} {
+			resp := serve(requestURI)
+			Expect(resp.Code).To(Equal(http.StatusBadRequest))
+			Expect(resp.Header().Get("X-Cf-RouterError")).To(Equal("invalid_path"))
+		}
+		Expect(nextReq).To(BeNil())
+	})
+})
@@ -0,0 +1,143 @@
+// Package ingress rejects the frontend connections that do not arrive
+// through the load balancers in front of the router. A connection is trusted
+// when its TCP peer is in one of the configured networks, which is checked as
+// it is accepted, before any PROXY protocol header is read. On TLS listeners
+// clients may additionally have to present a certificate signed by one of the
+// configured CAs. Rejected connections are counted per listener and reason.
+package ingress
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"net"
+
+	"github.com/uber-go/zap"
+
+	"code.cloudfoundry.org/gorouter/config"
+	"code.cloudfoundry.org/gorouter/logger"
+	"code.cloudfoundry.org/gorouter/metrics"
+)
+
+// Reasons a connection is rejected for
+const (
+	ReasonSource     = "source"
+	ReasonClientCert = "client_cert"
+)
+
+var errNoClientCert = errors.New("ingress: no client certificate")
+
+// Verifier checks that connections arrive through a trusted load balancer
+type Verifier struct {
+	networks  []*net.IPNet
+	clientCAs *x509.CertPool
+	reporter  metrics.IngressReporter
+	logger    logger.Logger
+}
+
+// NewVerifier returns the verifier configured by c
+func NewVerifier(c config.TrustedIngressConfig, reporter metrics.IngressReporter, logger logger.Logger) *Verifier {
+	return &Verifier{
+		networks:  c.SourceNetworks,
+		clientCAs: c.ClientCAs,
+		reporter:  reporter,
+		logger:    logger,
+	}
+}
+
+// Listener closes the conns l accepts from peers outside of the trusted
+// networks, and returns l itself when there are none. name identifies the
+// listener in metrics and logs.
+func (v *Verifier) Listener(l net.Listener, name string) net.Listener {
+	if len(v.networks) == 0 {
+		return l
+	}
+	return &listener{Listener: l, name: name, verifier: v}
+}
+
+// ConfigureTLS has tlsConfig request a client certificate and fail the
+// handshakes of clients presenting none signed by the trusted CAs. It leaves
+// tlsConfig alone when no CAs are configured.
+func (v *Verifier) ConfigureTLS(tlsConfig *tls.Config, name string) {
+	if v.clientCAs == nil {
+		return
+	}
+
+	// Certificates are verified here rather than by crypto/tls, so that the
+	// handshakes failing on them are counted too
+	tlsConfig.ClientAuth = tls.RequestClientCert
+	verify := tlsConfig.VerifyConnection
+	tlsConfig.VerifyConnection = func(state tls.ConnectionState) error {
+		if err := v.verifyClient(state.PeerCertificates); err != nil {
+			v.reject(name, ReasonClientCert, err)
+			return err
+		}
+		if verify != nil {
+			return verify(state)
+		}
+		return nil
+	}
+}
+
+// Trusted reports whether addr is in one of the trusted networks
+func (v *Verifier) Trusted(addr net.Addr) bool {
+	host, _, err := net.SplitHostPort(addr.String())
+	if err != nil {
+		return false
+	}
+	ip := net.ParseIP(host)
+	if ip == nil {
+		return false
+	}
+	for _, network := range v.networks {
+		if network.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+func (v *Verifier) verifyClient(certs []*x509.Certificate) error {
+	if len(certs) == 0 {
+		return errNoClientCert
+	}
+	opts := x509.VerifyOptions{
+		Roots:         v.clientCAs,
+		Intermediates: x509.NewCertPool(),
+		KeyUsages:     []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	}
+	for _, cert := range certs[1:] {
+		opts.Intermediates.AddCert(cert)
+	}
+	_, err := certs[0].Verify(opts)
+	return err
+}
+
+func (v *Verifier) reject(name, reason string, err error) {
+	v.reporter.CaptureIngressRejected(name, reason)
+	v.logger.Debug("ingress-rejected",
+		zap.String("listener", name),
+		zap.String("reason", reason),
+		zap.Error(err),
+	)
+}
+
+type listener struct {
+	net.Listener
+	name     string
+	verifier *Verifier
+}
+
+func (l *listener) Accept() (net.Conn, error) {
+	for {
+		conn, err := l.Listener.Accept()
+		if err != nil {
+			return nil, err
+		}
+		if l.verifier.Trusted(conn.RemoteAddr()) {
+			return conn, nil
+		}
+		l.verifier.reject(l.name, ReasonSource, errors.New("untrusted peer "+conn.RemoteAddr().String()))
+		conn.Close()
+	}
+}
@@ -0,0 +1,181 @@
+package ingress_test
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"net"
+	"time"
+
+	"code.cloudfoundry.org/gorouter/config"
+	"code.cloudfoundry.org/gorouter/ingress"
+	"code.cloudfoundry.org/gorouter/metrics/fakes"
+	"code.cloudfoundry.org/gorouter/test_util"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Verifier", func() {
+	var (
+		cfg      config.TrustedIngressConfig
+		reporter *fakes.FakeIngressReporter
+		verifier *ingress.Verifier
+		inner    net.Listener
+	)
+
+	network := func(cidr string) *net.IPNet {
+		_, n, err := net.ParseCIDR(cidr)
+		Expect(err).NotTo(HaveOccurred())
+		return n
+	}
+
+	BeforeEach(func() {
+		cfg = config.TrustedIngressConfig{}
+		reporter = new(fakes.FakeIngressReporter)
+
+		var err error
+		inner, err = net.Listen("tcp", "127.0.0.1:0")
+		Expect(err).NotTo(HaveOccurred())
+	})
+
+	JustBeforeEach(func() {
+		verifier = ingress.NewVerifier(cfg, reporter, test_util.NewTestZapLogger("ingress"))
+	})
+
+	AfterEach(func() {
+		inner.Close()
+	})
+
+	Describe("Listener", func() {
+		It("returns the listener itself without trusted networks", func() {
+			Expect(verifier.Listener(inner, "http")).To(BeIdenticalTo(inner))
+		})
+
+		Context("when the peer is trusted", func() {
+			BeforeEach(func() {
+				cfg.SourceNetworks = []*net.IPNet{network("127.0.0.0/8")}
+			})
+
+			It("accepts the conn", func() {
+				listener := verifier.Listener(inner, "http")
+				client, err := net.Dial("tcp", listener.Addr().String())
+				Expect(err).NotTo(HaveOccurred())
+				defer client.Close()
+
+				conn, err := listener.Accept()
+				Expect(err).NotTo(HaveOccurred())
+				conn.Close()
+				Expect(reporter.CaptureIngressRejectedCallCount()).To(Equal(0))
+			})
+		})
+
+		Context("when the peer is not trusted", func() {
+			BeforeEach(func() {
+				cfg.SourceNetworks = []*net.IPNet{network("10.0.0.0/8")}
+			})
+
+			It("closes the conn and counts it", func() {
+				listener := verifier.Listener(inner, "http")
+				go listener.Accept()
+
+				client, err := net.Dial("tcp", listener.Addr().String())
+				Expect(err).NotTo(HaveOccurred())
+				defer client.Close()
+
+				client.SetReadDeadline(time.Now().Add(5 * time.Second))
+				_, err = client.Read(make([]byte, 1))
+				Expect(err).To(HaveOccurred())
+				Expect(err).NotTo(MatchError(ContainSubstring("timeout")))
+
+				Eventually(reporter.CaptureIngressRejectedCallCount).Should(Equal(1))
+				name, reason := reporter.CaptureIngressRejectedArgsForCall(0)
+				Expect(name).To(Equal("http"))
+				Expect(reason).To(Equal(ingress.ReasonSource))
+			})
+		})
+	})
+
+	Describe("ConfigureTLS", func() {
+		var (
+			authority *ca
+			serverTLS *tls.Config
+		)
+
+		BeforeEach(func() {
+			authority = newCA()
+			serverTLS = &tls.Config{
+				Certificates: []tls.Certificate{authority.issue("router.example.com", x509.ExtKeyUsageServerAuth)},
+			}
+		})
+
+		handshake := func(clientCerts ...tls.Certificate) error {
+			listener := tls.NewListener(inner, serverTLS)
+			go func() {
+				conn, err := listener.Accept()
+				if err == nil {
+					conn.(*tls.Conn).Handshake()
+					conn.Close()
+				}
+			}()
+
+			conn, err := tls.Dial("tcp", inner.Addr().String(), &tls.Config{
+				ServerName:   "router.example.com",
+				RootCAs:      authority.pool(),
+				Certificates: clientCerts,
+				MaxVersion:   tls.VersionTLS12,
+			})
+			if err != nil {
+				return err
+			}
+			defer conn.Close()
+			return conn.Handshake()
+		}
+
+		It("leaves the config alone without client CAs", func() {
+			verifier.ConfigureTLS(serverTLS, "https")
+			Expect(serverTLS.ClientAuth).To(Equal(tls.NoClientCert))
+			Expect(handshake()).To(Succeed())
+		})
+
+		Context("with client CAs", func() {
+			BeforeEach(func() {
+				cfg.ClientCAs = authority.pool()
+			})
+
+			JustBeforeEach(func() {
+				verifier.ConfigureTLS(serverTLS, "https")
+			})
+
+			It("admits clients presenting a certificate of the CAs", func() {
+				Expect(handshake(authority.issue("lb.example.com", x509.ExtKeyUsageClientAuth))).To(Succeed())
+				Expect(reporter.CaptureIngressRejectedCallCount()).To(Equal(0))
+			})
+
+			It("rejects clients presenting no certificate", func() {
+				Expect(handshake()).NotTo(Succeed())
+
+				Eventually(reporter.CaptureIngressRejectedCallCount).Should(Equal(1))
+				name, reason := reporter.CaptureIngressRejectedArgsForCall(0)
+				Expect(name).To(Equal("https"))
+				Expect(reason).To(Equal(ingress.ReasonClientCert))
+			})
+
+			It("rejects clients presenting a certificate of another CA", func() {
+				Expect(handshake(newCA().issue("lb.example.com", x509.ExtKeyUsageClientAuth))).NotTo(Succeed())
+				Eventually(reporter.CaptureIngressRejectedCallCount).Should(Equal(1))
+			})
+
+			It("calls the verification the config already had", func() {
+				called := make(chan struct{}, 1)
+				serverTLS.VerifyConnection = func(tls.ConnectionState) error {
+					called <- struct{}{}
+					return nil
+				}
+				verifier.ConfigureTLS(serverTLS, "https")
+
+				Expect(handshake(authority.issue("lb.example.com", x509.ExtKeyUsageClientAuth))).To(Succeed())
+				Eventually(called).Should(Receive())
+			})
+		})
+	})
+})
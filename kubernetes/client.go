@@ -0,0 +1,151 @@
+package kubernetes
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// errResourceExpired is returned by watch when the API server no longer has
+// the requested resource version and the resource has to be listed again
+var errResourceExpired = errors.New("resource version expired")
+
+const (
+	listTimeout         = 30 * time.Second
+	watchTimeoutSeconds = 300
+)
+
+// Client lists and watches resources on a Kubernetes API server
+type Client struct {
+	apiServer  string
+	tokenFile  string
+	httpClient *http.Client
+}
+
+// NewClient returns a Client for apiServer. The bearer token is read from
+// tokenFile on every request, as service account tokens are rotated. When
+// caFile is set it replaces the system roots for verifying the API server.
+func NewClient(apiServer, tokenFile, caFile string) (*Client, error) {
+	tlsConfig := &tls.Config{}
+	if caFile != "" {
+		pem, err := ioutil.ReadFile(caFile)
+		if err != nil {
+			return nil, err
+		}
+		tlsConfig.RootCAs = x509.NewCertPool()
+		if !tlsConfig.RootCAs.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no certificates found in %s", caFile)
+		}
+	}
+
+	return &Client{
+		apiServer: strings.TrimSuffix(apiServer, "/"),
+		tokenFile: tokenFile,
+		httpClient: &http.Client{
+			Transport: &http.Transport{
+				Proxy:           http.ProxyFromEnvironment,
+				TLSClientConfig: tlsConfig,
+			},
+		},
+	}, nil
+}
+
+func (c *Client) list(ctx context.Context, path string) (*list, error) {
+	ctx, cancel := context.WithTimeout(ctx, listTimeout)
+	defer cancel()
+
+	resp, err := c.get(ctx, path, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	var l list
+	if err := json.NewDecoder(resp.Body).Decode(&l); err != nil {
+		return nil, err
+	}
+	return &l, nil
+}
+
+// watch streams the changes to the resources at path made after
+// resourceVersion to handle. It returns when the stream ends, when handle
+// fails, or with errResourceExpired when the resource has to be listed again.
+func (c *Client) watch(ctx context.Context, path, resourceVersion string, handle func(watchEvent) error) error {
+	query := url.Values{
+		"watch":               {"true"},
+		"resourceVersion":     {resourceVersion},
+		"allowWatchBookmarks": {"true"},
+		"timeoutSeconds":      {fmt.Sprintf("%d", watchTimeoutSeconds)},
+	}
+
+	resp, err := c.get(ctx, path, query)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	decoder := json.NewDecoder(resp.Body)
+	for {
+		var event watchEvent
+		if err := decoder.Decode(&event); err != nil {
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			return err
+		}
+
+		if event.Type == EventError {
+			return errResourceExpired
+		}
+		if err := handle(event); err != nil {
+			return err
+		}
+	}
+}
+
+func (c *Client) get(ctx context.Context, path string, query url.Values) (*http.Response, error) {
+	u := c.apiServer + path
+	if len(query) > 0 {
+		u += "?" + query.Encode()
+	}
+
+	req, err := http.NewRequest("GET", u, nil)
+	if err != nil {
+		return nil, err
+	}
+	req = req.WithContext(ctx)
+	req.Header.Set("Accept", "application/json")
+
+	if c.tokenFile != "" {
+		token, err := ioutil.ReadFile(c.tokenFile)
+		if err != nil {
+			return nil, err
+		}
+		req.Header.Set("Authorization", "Bearer "+strings.TrimSpace(string(token)))
+	}
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+
+	switch resp.StatusCode {
+	case http.StatusOK:
+		return resp, nil
+	case http.StatusGone:
+		resp.Body.Close()
+		return nil, errResourceExpired
+	default:
+		body, _ := ioutil.ReadAll(resp.Body)
+		resp.Body.Close()
+		return nil, fmt.Errorf("GET %s: %s: %s", path, resp.Status, strings.TrimSpace(string(body)))
+	}
+}
@@ -0,0 +1,340 @@
+package kubernetes
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/uber-go/zap"
+
+	"code.cloudfoundry.org/gorouter/logger"
+	"code.cloudfoundry.org/gorouter/registry"
+	"code.cloudfoundry.org/gorouter/route"
+	"code.cloudfoundry.org/routing-api/models"
+)
+
+const (
+	// RoutesAnnotation lists, comma separated, the routes of a Service
+	RoutesAnnotation = "gorouter.cloudfoundry.org/routes"
+	// PortAnnotation names the port of a Service to route to when it exposes
+	// more than one
+	PortAnnotation = "gorouter.cloudfoundry.org/port"
+
+	serviceNameLabel = "kubernetes.io/service-name"
+)
+
+// Registrar registers the ready endpoints of annotated Services in a registry.
+// Services and EndpointSlices are listed and then watched; every change is
+// reconciled against what was registered before, and all endpoints are
+// registered again every resync interval so that they are never pruned.
+type Registrar struct {
+	client         *Client
+	registry       registry.Registry
+	namespace      string
+	resyncInterval time.Duration
+	retryInterval  time.Duration
+	logger         logger.Logger
+
+	lock     sync.Mutex
+	services map[string]interface{}
+	slices   map[string]interface{}
+	changed  chan struct{}
+
+	registered map[registration]struct{}
+}
+
+type registration struct {
+	uri       route.Uri
+	host      string
+	port      uint16
+	namespace string
+	service   string
+	instance  string
+}
+
+func (r registration) makeEndpoint() *route.Endpoint {
+	return route.NewEndpoint(
+		"",
+		r.host,
+		r.port,
+		r.instance,
+		"",
+		map[string]string{"namespace": r.namespace, "service": r.service},
+		0,
+		"",
+		models.ModificationTag{},
+		"",
+	)
+}
+
+// NewRegistrar returns a Registrar watching the Services in namespace, or in
+// every namespace when it is empty
+func NewRegistrar(
+	client *Client,
+	registry registry.Registry,
+	namespace string,
+	resyncInterval, retryInterval time.Duration,
+	logger logger.Logger,
+) *Registrar {
+	return &Registrar{
+		client:         client,
+		registry:       registry,
+		namespace:      namespace,
+		resyncInterval: resyncInterval,
+		retryInterval:  retryInterval,
+		logger:         logger,
+		services:       map[string]interface{}{},
+		slices:         map[string]interface{}{},
+		changed:        make(chan struct{}, 1),
+		registered:     map[registration]struct{}{},
+	}
+}
+
+// Run registers endpoints until signaled
+func (r *Registrar) Run(signals <-chan os.Signal, ready chan<- struct{}) error {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	go r.follow(ctx, r.path("/api/v1", "services"), decodeService, r.services)
+	go r.follow(ctx, r.path("/apis/discovery.k8s.io/v1", "endpointslices"), decodeEndpointSlice, r.slices)
+
+	close(ready)
+	r.logger.Info("kubernetes-registrar-started", zap.String("namespace", r.namespace))
+
+	ticker := time.NewTicker(r.resyncInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-r.changed:
+			r.reconcile(false)
+		case <-ticker.C:
+			r.reconcile(true)
+		case <-signals:
+			r.logger.Info("kubernetes-registrar-exited")
+			return nil
+		}
+	}
+}
+
+func (r *Registrar) path(group, resource string) string {
+	if r.namespace == "" {
+		return group + "/" + resource
+	}
+	return group + "/namespaces/" + r.namespace + "/" + resource
+}
+
+func decodeService(data json.RawMessage) (ObjectMeta, interface{}, error) {
+	var service Service
+	err := json.Unmarshal(data, &service)
+	return service.Metadata, &service, err
+}
+
+func decodeEndpointSlice(data json.RawMessage) (ObjectMeta, interface{}, error) {
+	var slice EndpointSlice
+	err := json.Unmarshal(data, &slice)
+	return slice.Metadata, &slice, err
+}
+
+// follow keeps cache, either r.services or r.slices, up to date with the
+// resources at path until ctx is done
+func (r *Registrar) follow(
+	ctx context.Context,
+	path string,
+	decode func(json.RawMessage) (ObjectMeta, interface{}, error),
+	cache map[string]interface{},
+) {
+	for ctx.Err() == nil {
+		l, err := r.client.list(ctx, path)
+		if err != nil {
+			r.logger.Error("kubernetes-list-failed", zap.String("path", path), zap.Error(err))
+			r.wait(ctx)
+			continue
+		}
+
+		objects := map[string]interface{}{}
+		for _, item := range l.Items {
+			meta, obj, err := decode(item)
+			if err != nil {
+				r.logger.Error("kubernetes-decode-failed", zap.String("path", path), zap.Error(err))
+				continue
+			}
+			objects[meta.Namespace+"/"+meta.Name] = obj
+		}
+		r.update(func() {
+			for key := range cache {
+				delete(cache, key)
+			}
+			for key, obj := range objects {
+				cache[key] = obj
+			}
+		})
+
+		resourceVersion := l.Metadata.ResourceVersion
+		for ctx.Err() == nil {
+			err = r.client.watch(ctx, path, resourceVersion, func(event watchEvent) error {
+				meta, obj, err := decode(event.Object)
+				if err != nil {
+					return err
+				}
+				resourceVersion = meta.ResourceVersion
+
+				key := meta.Namespace + "/" + meta.Name
+				switch event.Type {
+				case EventAdded, EventModified:
+					r.update(func() { cache[key] = obj })
+				case EventDeleted:
+					r.update(func() { delete(cache, key) })
+				}
+				return nil
+			})
+
+			if err == errResourceExpired {
+				break
+			}
+			if err != nil && ctx.Err() == nil {
+				r.logger.Error("kubernetes-watch-failed", zap.String("path", path), zap.Error(err))
+				r.wait(ctx)
+			}
+		}
+	}
+}
+
+func (r *Registrar) wait(ctx context.Context) {
+	select {
+	case <-time.After(r.retryInterval):
+	case <-ctx.Done():
+	}
+}
+
+// update applies f to the caches and schedules a reconcile
+func (r *Registrar) update(f func()) {
+	r.lock.Lock()
+	f()
+	r.lock.Unlock()
+
+	select {
+	case r.changed <- struct{}{}:
+	default:
+	}
+}
+
+// reconcile registers the endpoints that appeared and unregisters those that
+// went away since the last call. With refresh set every endpoint is
+// registered again.
+func (r *Registrar) reconcile(refresh bool) {
+	desired := r.desired()
+
+	removed := 0
+	for reg := range r.registered {
+		if _, ok := desired[reg]; !ok {
+			r.registry.Unregister(reg.uri, reg.makeEndpoint())
+			removed++
+		}
+	}
+
+	added := 0
+	for reg := range desired {
+		if _, ok := r.registered[reg]; !ok {
+			added++
+		} else if !refresh {
+			continue
+		}
+		r.registry.Register(reg.uri, reg.makeEndpoint())
+	}
+
+	if added > 0 || removed > 0 {
+		r.logger.Info("kubernetes-endpoints-reconciled",
+			zap.Int("endpoints", len(desired)),
+			zap.Int("added", added),
+			zap.Int("removed", removed),
+		)
+	}
+
+	r.registered = desired
+}
+
+func (r *Registrar) desired() map[registration]struct{} {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	desired := map[registration]struct{}{}
+	for _, obj := range r.slices {
+		slice := obj.(*EndpointSlice)
+		// route.Endpoint formats its address as host:port without brackets,
+		// so IPv6 slices cannot be routed
+		if slice.AddressType != "IPv4" {
+			continue
+		}
+
+		serviceName := slice.Metadata.Labels[serviceNameLabel]
+		obj, ok := r.services[slice.Metadata.Namespace+"/"+serviceName]
+		if !ok {
+			continue
+		}
+		service := obj.(*Service)
+
+		uris := routes(service)
+		if len(uris) == 0 {
+			continue
+		}
+
+		port, ok := selectPort(slice.Ports, service.Metadata.Annotations[PortAnnotation])
+		if !ok {
+			continue
+		}
+
+		for _, endpoint := range slice.Endpoints {
+			if endpoint.Conditions.Ready != nil && !*endpoint.Conditions.Ready {
+				continue
+			}
+
+			for _, address := range endpoint.Addresses {
+				instance := address
+				if endpoint.TargetRef != nil && endpoint.TargetRef.UID != "" {
+					instance = endpoint.TargetRef.UID
+				}
+
+				for _, uri := range uris {
+					desired[registration{
+						uri:       uri,
+						host:      address,
+						port:      port,
+						namespace: slice.Metadata.Namespace,
+						service:   serviceName,
+						instance:  instance,
+					}] = struct{}{}
+				}
+			}
+		}
+	}
+
+	return desired
+}
+
+func routes(service *Service) []route.Uri {
+	var uris []route.Uri
+	for _, uri := range strings.Split(service.Metadata.Annotations[RoutesAnnotation], ",") {
+		if uri = strings.TrimSpace(uri); uri != "" {
+			uris = append(uris, route.Uri(uri))
+		}
+	}
+	return uris
+}
+
+// selectPort returns the TCP port called name, or the first TCP port when name
+// is empty
+func selectPort(ports []EndpointPort, name string) (uint16, bool) {
+	for _, p := range ports {
+		if p.Port == nil || (p.Protocol != nil && *p.Protocol != "TCP") {
+			continue
+		}
+		if name == "" || (p.Name != nil && *p.Name == name) {
+			return uint16(*p.Port), true
+		}
+	}
+	return 0, false
+}
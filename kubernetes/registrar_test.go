@@ -0,0 +1,160 @@
+package kubernetes_test
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"time"
+
+	"code.cloudfoundry.org/gorouter/config"
+	"code.cloudfoundry.org/gorouter/kubernetes"
+	"code.cloudfoundry.org/gorouter/metrics/fakes"
+	"code.cloudfoundry.org/gorouter/registry"
+	"code.cloudfoundry.org/gorouter/route"
+	"code.cloudfoundry.org/gorouter/test_util"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/tedsuo/ifrit"
+)
+
+const services = `{"metadata": {"resourceVersion": "10"}, "items": [
+  {"metadata": {"name": "web", "namespace": "apps", "annotations": {
+    "gorouter.cloudfoundry.org/routes": "web.example.com, www.example.com",
+    "gorouter.cloudfoundry.org/port": "http"}}},
+  {"metadata": {"name": "internal", "namespace": "apps"}}
+]}`
+
+const endpointSlices = `{"metadata": {"resourceVersion": "11"}, "items": [
+  {"metadata": {"name": "web-abc", "namespace": "apps", "resourceVersion": "11",
+    "labels": {"kubernetes.io/service-name": "web"}},
+   "addressType": "IPv4",
+   "ports": [{"name": "metrics", "port": 9090, "protocol": "TCP"}, {"name": "http", "port": 8080, "protocol": "TCP"}],
+   "endpoints": [
+     {"addresses": ["10.1.0.1"], "conditions": {"ready": true}, "targetRef": {"kind": "Pod", "name": "web-1", "uid": "uid-1"}},
+     {"addresses": ["10.1.0.2"], "conditions": {"ready": false}}
+   ]},
+  {"metadata": {"name": "internal-abc", "namespace": "apps", "resourceVersion": "11",
+    "labels": {"kubernetes.io/service-name": "internal"}},
+   "addressType": "IPv4",
+   "ports": [{"port": 8080}],
+   "endpoints": [{"addresses": ["10.1.0.3"]}]}
+]}`
+
+var _ = Describe("Registrar", func() {
+	var (
+		tmpDir      string
+		server      *httptest.Server
+		table       *registry.RouteRegistry
+		process     ifrit.Process
+		sliceEvents chan string
+		tokens      chan string
+	)
+
+	addresses := func(uri route.Uri) func() []string {
+		return func() []string {
+			var addrs []string
+			if pool := table.Lookup(uri); pool != nil {
+				pool.Each(func(e *route.Endpoint) {
+					addrs = append(addrs, e.CanonicalAddr())
+				})
+			}
+			return addrs
+		}
+	}
+
+	BeforeEach(func() {
+		var err error
+		tmpDir, err = ioutil.TempDir("", "kubernetes")
+		Expect(err).NotTo(HaveOccurred())
+		tokenFile := filepath.Join(tmpDir, "token")
+		Expect(ioutil.WriteFile(tokenFile, []byte("secret-token\n"), 0600)).To(Succeed())
+
+		sliceEvents = make(chan string, 10)
+		tokens = make(chan string, 10)
+		server = httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+			select {
+			case tokens <- req.Header.Get("Authorization"):
+			default:
+			}
+
+			if req.URL.Query().Get("watch") != "true" {
+				switch req.URL.Path {
+				case "/api/v1/services":
+					fmt.Fprint(rw, services)
+				case "/apis/discovery.k8s.io/v1/endpointslices":
+					fmt.Fprint(rw, endpointSlices)
+				default:
+					rw.WriteHeader(http.StatusNotFound)
+				}
+				return
+			}
+
+			events := sliceEvents
+			if req.URL.Path != "/apis/discovery.k8s.io/v1/endpointslices" {
+				events = nil
+			}
+
+			rw.(http.Flusher).Flush()
+			for {
+				select {
+				case event := <-events:
+					fmt.Fprintln(rw, event)
+					rw.(http.Flusher).Flush()
+				case <-req.Context().Done():
+					return
+				}
+			}
+		}))
+
+		logger := test_util.NewTestZapLogger("kubernetes")
+		table = registry.NewRouteRegistry(logger, config.DefaultConfig(), new(fakes.FakeRouteRegistryReporter))
+		client, err := kubernetes.NewClient(server.URL, tokenFile, "")
+		Expect(err).NotTo(HaveOccurred())
+
+		registrar := kubernetes.NewRegistrar(client, table, "", time.Minute, 10*time.Millisecond, logger)
+		process = ifrit.Invoke(registrar)
+	})
+
+	AfterEach(func() {
+		process.Signal(os.Interrupt)
+		Eventually(process.Wait()).Should(Receive())
+		server.CloseClientConnections()
+		server.Close()
+		os.RemoveAll(tmpDir)
+	})
+
+	It("authenticates with the service account token", func() {
+		Eventually(tokens).Should(Receive(Equal("Bearer secret-token")))
+	})
+
+	It("registers the ready endpoints of annotated services on the named port", func() {
+		Eventually(addresses("web.example.com")).Should(ConsistOf("10.1.0.1:8080"))
+		Eventually(addresses("www.example.com")).Should(ConsistOf("10.1.0.1:8080"))
+
+		var tags map[string]string
+		table.Lookup("web.example.com").Each(func(e *route.Endpoint) {
+			tags = e.Tags
+			Expect(e.PrivateInstanceId).To(Equal("uid-1"))
+		})
+		Expect(tags).To(Equal(map[string]string{"namespace": "apps", "service": "web"}))
+
+		Consistently(table.NumEndpoints).Should(Equal(1))
+	})
+
+	It("applies watched changes to the endpoint slices", func() {
+		Eventually(addresses("web.example.com")).Should(ConsistOf("10.1.0.1:8080"))
+
+		sliceEvents <- `{"type": "MODIFIED", "object": {"metadata": {"name": "web-abc", "namespace": "apps", "resourceVersion": "12",
+  "labels": {"kubernetes.io/service-name": "web"}}, "addressType": "IPv4",
+  "ports": [{"name": "http", "port": 8080}],
+  "endpoints": [{"addresses": ["10.1.0.2"], "conditions": {"ready": true}}, {"addresses": ["10.1.0.4"]}]}}`
+		Eventually(addresses("web.example.com")).Should(ConsistOf("10.1.0.2:8080", "10.1.0.4:8080"))
+
+		sliceEvents <- `{"type": "DELETED", "object": {"metadata": {"name": "web-abc", "namespace": "apps", "resourceVersion": "13"}}}`
+		Eventually(addresses("web.example.com")).Should(BeEmpty())
+	})
+})
@@ -0,0 +1,76 @@
+package kubernetes
+
+import "encoding/json"
+
+// The subset of the Kubernetes API objects read by the Registrar
+
+// ObjectMeta is the metadata common to all API objects
+type ObjectMeta struct {
+	Name            string            `json:"name"`
+	Namespace       string            `json:"namespace"`
+	ResourceVersion string            `json:"resourceVersion"`
+	Labels          map[string]string `json:"labels"`
+	Annotations     map[string]string `json:"annotations"`
+}
+
+// Service is a core/v1 Service
+type Service struct {
+	Metadata ObjectMeta `json:"metadata"`
+}
+
+// EndpointSlice is a discovery.k8s.io/v1 EndpointSlice
+type EndpointSlice struct {
+	Metadata    ObjectMeta     `json:"metadata"`
+	AddressType string         `json:"addressType"`
+	Endpoints   []Endpoint     `json:"endpoints"`
+	Ports       []EndpointPort `json:"ports"`
+}
+
+// Endpoint is a single backend of an EndpointSlice
+type Endpoint struct {
+	Addresses  []string           `json:"addresses"`
+	Conditions EndpointConditions `json:"conditions"`
+	TargetRef  *ObjectReference   `json:"targetRef"`
+}
+
+// EndpointConditions reports the state of an Endpoint. A nil Ready is
+// interpreted as ready.
+type EndpointConditions struct {
+	Ready *bool `json:"ready"`
+}
+
+// ObjectReference points at the object, usually a pod, backing an Endpoint
+type ObjectReference struct {
+	Kind      string `json:"kind"`
+	Name      string `json:"name"`
+	Namespace string `json:"namespace"`
+	UID       string `json:"uid"`
+}
+
+// EndpointPort is a port exposed by every endpoint of an EndpointSlice
+type EndpointPort struct {
+	Name     *string `json:"name"`
+	Port     *int32  `json:"port"`
+	Protocol *string `json:"protocol"`
+}
+
+type list struct {
+	Metadata struct {
+		ResourceVersion string `json:"resourceVersion"`
+	} `json:"metadata"`
+	Items []json.RawMessage `json:"items"`
+}
+
+// Event types of a watch stream
+const (
+	EventAdded    = "ADDED"
+	EventModified = "MODIFIED"
+	EventDeleted  = "DELETED"
+	EventBookmark = "BOOKMARK"
+	EventError    = "ERROR"
+)
+
+type watchEvent struct {
+	Type   string          `json:"type"`
+	Object json.RawMessage `json:"object"`
+}
@@ -0,0 +1,210 @@
+package logger
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/uber-go/zap"
+)
+
+var levelNames = map[string]zap.Level{
+	"debug": zap.DebugLevel,
+	"info":  zap.InfoLevel,
+	"warn":  zap.WarnLevel,
+	"error": zap.ErrorLevel,
+	"fatal": zap.FatalLevel,
+}
+
+// ParseLevel returns the level named name
+func ParseLevel(name string) (zap.Level, error) {
+	level, ok := levelNames[strings.ToLower(name)]
+	if !ok {
+		return 0, fmt.Errorf("unknown log level: %s", name)
+	}
+	return level, nil
+}
+
+func levelName(level zap.Level) string {
+	for name, l := range levelNames {
+		if l == level {
+			return name
+		}
+	}
+	return level.String()
+}
+
+// Levels holds the minimum level of the loggers of each component, a
+// component being named after a session. A logger takes the level of its
+// innermost session with one, or the default level. Levels can be changed
+// while the loggers are in use.
+type Levels struct {
+	version uint64
+
+	lock       sync.RWMutex
+	level      zap.Level
+	components map[string]zap.Level
+
+	sampler *sampler
+}
+
+// NewLevels returns the levels named by level and components
+func NewLevels(level string, components map[string]string) (*Levels, error) {
+	l := &Levels{}
+	if err := l.Set(level, components); err != nil {
+		return nil, err
+	}
+	return l, nil
+}
+
+// Set replaces the default level and the levels of the components
+func (l *Levels) Set(level string, components map[string]string) error {
+	def, err := ParseLevel(level)
+	if err != nil {
+		return err
+	}
+	parsed := make(map[string]zap.Level, len(components))
+	for component, name := range components {
+		if parsed[component], err = ParseLevel(name); err != nil {
+			return err
+		}
+	}
+
+	l.lock.Lock()
+	l.level = def
+	l.components = parsed
+	l.lock.Unlock()
+
+	atomic.AddUint64(&l.version, 1)
+	return nil
+}
+
+// Get returns the default level and the levels of the components
+func (l *Levels) Get() (string, map[string]string) {
+	l.lock.RLock()
+	defer l.lock.RUnlock()
+
+	components := make(map[string]string, len(l.components))
+	for component, level := range l.components {
+		components[component] = levelName(level)
+	}
+	return levelName(l.level), components
+}
+
+// Sample limits each of messages, when logged at debug or info level, to
+// the first entries of every second and every thereafter-th entry after
+// them. It must be called before the loggers are used.
+func (l *Levels) Sample(messages []string, first, thereafter int) {
+	if len(messages) == 0 {
+		return
+	}
+	s := &sampler{
+		counts:     make(map[string]*sampleCount, len(messages)),
+		first:      uint64(first),
+		thereafter: uint64(thereafter),
+	}
+	for _, message := range messages {
+		s.counts[message] = &sampleCount{}
+	}
+	l.sampler = s
+}
+
+// levelFor returns the level of a logger with the given sessions, innermost
+// last
+func (l *Levels) levelFor(sessions []string) zap.Level {
+	l.lock.RLock()
+	defer l.lock.RUnlock()
+
+	for i := len(sessions) - 1; i >= 0; i-- {
+		if level, ok := l.components[sessions[i]]; ok {
+			return level
+		}
+	}
+	return l.level
+}
+
+func (l *Levels) sampled(level zap.Level, message string) bool {
+	if l.sampler == nil || level > zap.InfoLevel {
+		return true
+	}
+	return l.sampler.allow(message, time.Now())
+}
+
+type sampleCount struct {
+	second int64
+	n      uint64
+}
+
+type sampler struct {
+	counts     map[string]*sampleCount
+	first      uint64
+	thereafter uint64
+}
+
+func (s *sampler) allow(message string, now time.Time) bool {
+	c, ok := s.counts[message]
+	if !ok {
+		return true
+	}
+
+	second := now.Unix()
+	if old := atomic.LoadInt64(&c.second); old != second && atomic.CompareAndSwapInt64(&c.second, old, second) {
+		atomic.StoreUint64(&c.n, 0)
+	}
+	n := atomic.AddUint64(&c.n, 1)
+	if n <= s.first {
+		return true
+	}
+	return s.thereafter > 0 && (n-s.first)%s.thereafter == 0
+}
+
+// levelCache is the level a logger resolved for a version of its Levels
+type levelCache struct {
+	version uint64
+	level   zap.Level
+}
+
+type levelsJSON struct {
+	Level      string            `json:"level"`
+	Components map[string]string `json:"components"`
+}
+
+type levelsHandler struct {
+	levels *Levels
+}
+
+// NewLevelsHandler returns the log levels API: GET returns the default level
+// and the levels of the components, PUT replaces them
+func NewLevelsHandler(levels *Levels) http.Handler {
+	return &levelsHandler{levels: levels}
+}
+
+func (h *levelsHandler) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	w.Header().Set("Connection", "close")
+
+	switch req.Method {
+	case "GET":
+	case "PUT":
+		var body levelsJSON
+		err := json.NewDecoder(req.Body).Decode(&body)
+		if err == nil {
+			err = h.levels.Set(body.Level, body.Components)
+		}
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+	default:
+		w.Header().Set("Allow", "GET, PUT")
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+
+	level, components := h.levels.Get()
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(levelsJSON{Level: level, Components: components})
+}
@@ -0,0 +1,117 @@
+package logger_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+
+	. "code.cloudfoundry.org/gorouter/logger"
+	"code.cloudfoundry.org/gorouter/test_util"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/onsi/gomega/gbytes"
+	"github.com/uber-go/zap"
+)
+
+var _ = Describe("Levels", func() {
+	var (
+		levels   *Levels
+		logger   Logger
+		testSink *test_util.TestZapSink
+	)
+
+	BeforeEach(func() {
+		var err error
+		levels, err = NewLevels("info", map[string]string{"registry": "debug", "route-services": "error"})
+		Expect(err).NotTo(HaveOccurred())
+
+		testSink = &test_util.TestZapSink{Buffer: gbytes.NewBuffer()}
+		logger = NewLoggerWithLevels("my-component", levels, nil, zap.Output(testSink))
+	})
+
+	It("logs each component at its own level", func() {
+		logger.Session("registry").Debug("registry-debug")
+		logger.Session("proxy").Debug("proxy-debug")
+		logger.Session("proxy").Info("proxy-info")
+
+		Expect(testSink.Lines()).To(HaveLen(2))
+		Expect(testSink.Lines()[0]).To(ContainSubstring("registry-debug"))
+		Expect(testSink.Lines()[1]).To(ContainSubstring("proxy-info"))
+	})
+
+	It("takes the level of the innermost session with one", func() {
+		logger.Session("registry").Session("pruner").Debug("pruner-debug")
+		logger.Session("proxy").Session("route-services").Info("route-services-info")
+
+		Expect(testSink.Lines()).To(HaveLen(1))
+		Expect(testSink.Lines()[0]).To(ContainSubstring("pruner-debug"))
+	})
+
+	It("applies changed levels to the loggers in use", func() {
+		proxy := logger.Session("proxy")
+		proxy.Debug("before")
+
+		Expect(levels.Set("info", map[string]string{"proxy": "debug"})).To(Succeed())
+		proxy.Debug("after")
+
+		Expect(testSink.Lines()).To(HaveLen(1))
+		Expect(testSink.Lines()[0]).To(ContainSubstring("after"))
+	})
+
+	It("rejects unknown levels", func() {
+		Expect(levels.Set("loud", nil)).NotTo(Succeed())
+		Expect(levels.Set("info", map[string]string{"proxy": "loud"})).NotTo(Succeed())
+
+		level, components := levels.Get()
+		Expect(level).To(Equal("info"))
+		Expect(components).To(Equal(map[string]string{"registry": "debug", "route-services": "error"}))
+	})
+
+	It("samples the configured messages", func() {
+		levels.Sample([]string{"endpoint-registered"}, 2, 0)
+		registry := logger.Session("registry")
+		for i := 0; i < 10; i++ {
+			registry.Debug("endpoint-registered")
+			registry.Debug("endpoint-unregistered")
+		}
+
+		Expect(strings.Count(string(testSink.Contents()), `"endpoint-registered"`)).To(BeNumerically("<", 10))
+		Expect(strings.Count(string(testSink.Contents()), `"endpoint-unregistered"`)).To(Equal(10))
+	})
+
+	It("does not sample warnings and errors", func() {
+		levels.Sample([]string{"endpoint-registered"}, 1, 0)
+		for i := 0; i < 3; i++ {
+			logger.Warn("endpoint-registered")
+		}
+
+		Expect(testSink.Lines()).To(HaveLen(3))
+	})
+
+	Describe("NewLevelsHandler", func() {
+		serve := func(method, body string) *httptest.ResponseRecorder {
+			recorder := httptest.NewRecorder()
+			NewLevelsHandler(levels).ServeHTTP(recorder, httptest.NewRequest(method, "/log_levels", strings.NewReader(body)))
+			return recorder
+		}
+
+		It("reports the levels", func() {
+			recorder := serve("GET", "")
+			Expect(recorder.Code).To(Equal(http.StatusOK))
+			Expect(recorder.Body.String()).To(MatchJSON(`{"level":"info","components":{"registry":"debug","route-services":"error"}}`))
+		})
+
+		It("replaces the levels", func() {
+			recorder := serve("PUT", `{"level":"warn","components":{"mbus":"debug"}}`)
+			Expect(recorder.Code).To(Equal(http.StatusOK))
+			Expect(recorder.Body.String()).To(MatchJSON(`{"level":"warn","components":{"mbus":"debug"}}`))
+		})
+
+		It("rejects invalid levels", func() {
+			Expect(serve("PUT", `{"level":"loud"}`).Code).To(Equal(http.StatusBadRequest))
+			Expect(serve("PUT", `not json`).Code).To(Equal(http.StatusBadRequest))
+			Expect(serve("DELETE", "").Code).To(Equal(http.StatusMethodNotAllowed))
+		})
+	})
+})
@@ -1,6 +1,10 @@
 package logger
 
-import "github.com/uber-go/zap"
+import (
+	"sync/atomic"
+
+	"github.com/uber-go/zap"
+)
 
 // Logger is the zap.Logger interface with additional Session methods.
 //go:generate counterfeiter -o fakes/fake_logger.go . Logger
@@ -24,14 +28,22 @@ type logger struct {
 	origLogger zap.Logger
 	context    []zap.Field
 	zap.Logger
+
+	levels   *Levels
+	sessions []string
+	level    atomic.Value
 }
 
 // NewLogger returns a new zap logger that implements the Logger interface.
 func NewLogger(component string, options ...zap.Option) Logger {
+	return newLogger(component, zap.EpochFormatter("timestamp"), options...)
+}
+
+func newLogger(component string, timeFormatter zap.TimeFormatter, options ...zap.Option) Logger {
 	enc := zap.NewJSONEncoder(
 		zap.LevelString("log_level"),
 		zap.MessageKey("message"),
-		zap.EpochFormatter("timestamp"),
+		timeFormatter,
 		numberLevelFormatter(),
 	)
 	origLogger := zap.New(enc, options...)
@@ -43,13 +55,29 @@ func NewLogger(component string, options ...zap.Option) Logger {
 	}
 }
 
+// NewLoggerWithLevels returns a logger whose sessions log at the levels of
+// their components in levels. The levels replace any level in options. A nil
+// timeFormatter writes epoch timestamps.
+func NewLoggerWithLevels(component string, levels *Levels, timeFormatter zap.TimeFormatter, options ...zap.Option) Logger {
+	if timeFormatter == nil {
+		timeFormatter = zap.EpochFormatter("timestamp")
+	}
+	l := newLogger(component, timeFormatter, append(options, zap.DebugLevel)...).(*logger)
+	l.levels = levels
+	return l
+}
+
 func (l *logger) Session(component string) Logger {
 	newSource := l.source + "." + component
+	sessions := make([]string, len(l.sessions), len(l.sessions)+1)
+	copy(sessions, l.sessions)
 	lggr := &logger{
 		source:     newSource,
 		origLogger: l.origLogger,
 		Logger:     l.origLogger.With(zap.String("source", newSource)),
 		context:    l.context,
+		levels:     l.levels,
+		sessions:   append(sessions, component),
 	}
 	return lggr
 }
@@ -69,10 +97,38 @@ func (l *logger) With(fields ...zap.Field) Logger {
 		origLogger: l.origLogger,
 		Logger:     l.Logger,
 		context:    append(l.context, fields...),
+		levels:     l.levels,
+		sessions:   l.sessions,
+	}
+}
+
+// enabled reports whether entries at level pass the level of the logger's
+// component. The level is resolved again only once the levels change.
+func (l *logger) enabled(level zap.Level) bool {
+	if l.levels == nil {
+		return true
+	}
+
+	version := atomic.LoadUint64(&l.levels.version)
+	cached, ok := l.level.Load().(levelCache)
+	if !ok || cached.version != version {
+		cached = levelCache{version: version, level: l.levels.levelFor(l.sessions)}
+		l.level.Store(cached)
 	}
+	return level >= cached.level
+}
+
+func (l *logger) Check(level zap.Level, msg string) *zap.CheckedMessage {
+	if !l.enabled(level) {
+		return nil
+	}
+	return l.Logger.Check(level, msg)
 }
 
 func (l *logger) Log(level zap.Level, msg string, fields ...zap.Field) {
+	if !l.enabled(level) || (l.levels != nil && !l.levels.sampled(level, msg)) {
+		return
+	}
 	l.Logger.Log(level, msg, l.wrapDataFields(fields...))
 }
 func (l *logger) Debug(msg string, fields ...zap.Field) {
@@ -0,0 +1,169 @@
+package logger
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+)
+
+// backupTimeFormat names the rotated files. It sorts lexically in time order.
+const backupTimeFormat = "20060102T150405.000000000"
+
+// RotatingFile is a log file that rotates itself once it reaches a size or
+// an age, so that the router needs no external logrotate. Rotated files are
+// renamed after the time of their rotation, next to the file.
+type RotatingFile struct {
+	path       string
+	maxSize    int64
+	interval   time.Duration
+	maxBackups int
+
+	lock     sync.Mutex
+	file     *os.File
+	size     int64
+	rotateAt time.Time
+	now      func() time.Time
+}
+
+// NewRotatingFile opens path for appending. The file is rotated before a
+// write would grow it beyond maxSize bytes and every interval, zero disabling
+// either. Only the newest maxBackups rotated files are kept, or all when zero.
+func NewRotatingFile(path string, maxSize int64, interval time.Duration, maxBackups int) (*RotatingFile, error) {
+	f := &RotatingFile{
+		path:       path,
+		maxSize:    maxSize,
+		interval:   interval,
+		maxBackups: maxBackups,
+		now:        time.Now,
+	}
+	if err := f.open(); err != nil {
+		return nil, err
+	}
+	return f, nil
+}
+
+func (f *RotatingFile) open() error {
+	file, err := os.OpenFile(f.path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0644)
+	if err != nil {
+		return err
+	}
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return err
+	}
+
+	f.file = file
+	f.size = info.Size()
+	if f.interval > 0 {
+		f.rotateAt = f.now().Add(f.interval)
+	}
+	return nil
+}
+
+// Write appends p to the file, rotating it first when due
+func (f *RotatingFile) Write(p []byte) (int, error) {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	if f.file == nil {
+		return 0, os.ErrClosed
+	}
+	if f.due(int64(len(p))) {
+		if err := f.rotate(); err != nil {
+			return 0, err
+		}
+	}
+
+	n, err := f.file.Write(p)
+	f.size += int64(n)
+	return n, err
+}
+
+func (f *RotatingFile) due(n int64) bool {
+	if f.maxSize > 0 && f.size > 0 && f.size+n > f.maxSize {
+		return true
+	}
+	return f.interval > 0 && !f.now().Before(f.rotateAt)
+}
+
+func (f *RotatingFile) rotate() error {
+	if err := f.file.Close(); err != nil {
+		return err
+	}
+	f.file = nil
+
+	backup := fmt.Sprintf("%s.%s", f.path, f.now().UTC().Format(backupTimeFormat))
+	if err := os.Rename(f.path, backup); err != nil {
+		return err
+	}
+	if err := f.open(); err != nil {
+		return err
+	}
+	return f.removeBackups()
+}
+
+// removeBackups removes the oldest rotated files beyond maxBackups
+func (f *RotatingFile) removeBackups() error {
+	if f.maxBackups <= 0 {
+		return nil
+	}
+	backups, err := f.Backups()
+	if err != nil {
+		return err
+	}
+	for len(backups) > f.maxBackups {
+		if err := os.Remove(backups[0]); err != nil {
+			return err
+		}
+		backups = backups[1:]
+	}
+	return nil
+}
+
+// Backups returns the paths of the rotated files, oldest first
+func (f *RotatingFile) Backups() ([]string, error) {
+	backups, err := filepath.Glob(f.path + ".*")
+	if err != nil {
+		return nil, err
+	}
+
+	n := 0
+	for _, backup := range backups {
+		stamp := backup[len(f.path)+1:]
+		if _, err := time.Parse(backupTimeFormat, stamp); err == nil {
+			backups[n] = backup
+			n++
+		}
+	}
+	backups = backups[:n]
+	sort.Strings(backups)
+	return backups, nil
+}
+
+// Sync commits the written logs to disk
+func (f *RotatingFile) Sync() error {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	if f.file == nil {
+		return os.ErrClosed
+	}
+	return f.file.Sync()
+}
+
+// Close closes the file. Later writes fail.
+func (f *RotatingFile) Close() error {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	if f.file == nil {
+		return nil
+	}
+	err := f.file.Close()
+	f.file = nil
+	return err
+}
@@ -0,0 +1,119 @@
+package logger_test
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"time"
+
+	. "code.cloudfoundry.org/gorouter/logger"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("RotatingFile", func() {
+	var (
+		dir  string
+		path string
+		file *RotatingFile
+	)
+
+	BeforeEach(func() {
+		var err error
+		dir, err = ioutil.TempDir("", "rotate")
+		Expect(err).NotTo(HaveOccurred())
+		path = filepath.Join(dir, "gorouter.log")
+	})
+
+	AfterEach(func() {
+		if file != nil {
+			file.Close()
+		}
+		os.RemoveAll(dir)
+	})
+
+	write := func(line string) {
+		_, err := file.Write([]byte(line))
+		Expect(err).NotTo(HaveOccurred())
+	}
+
+	contents := func(path string) string {
+		b, err := ioutil.ReadFile(path)
+		Expect(err).NotTo(HaveOccurred())
+		return string(b)
+	}
+
+	It("appends to an existing file", func() {
+		Expect(ioutil.WriteFile(path, []byte("old\n"), 0644)).To(Succeed())
+
+		var err error
+		file, err = NewRotatingFile(path, 0, 0, 0)
+		Expect(err).NotTo(HaveOccurred())
+		write("new\n")
+
+		Expect(contents(path)).To(Equal("old\nnew\n"))
+	})
+
+	It("rotates before a write would exceed the size", func() {
+		var err error
+		file, err = NewRotatingFile(path, 10, 0, 0)
+		Expect(err).NotTo(HaveOccurred())
+		write("first\n")
+		write("second\n")
+
+		backups, err := file.Backups()
+		Expect(err).NotTo(HaveOccurred())
+		Expect(backups).To(HaveLen(1))
+		Expect(contents(backups[0])).To(Equal("first\n"))
+		Expect(contents(path)).To(Equal("second\n"))
+	})
+
+	It("writes a line larger than the size to an empty file", func() {
+		var err error
+		file, err = NewRotatingFile(path, 4, 0, 0)
+		Expect(err).NotTo(HaveOccurred())
+		write("too long\n")
+
+		Expect(file.Backups()).To(BeEmpty())
+		Expect(contents(path)).To(Equal("too long\n"))
+	})
+
+	It("rotates every interval", func() {
+		var err error
+		file, err = NewRotatingFile(path, 0, 50*time.Millisecond, 0)
+		Expect(err).NotTo(HaveOccurred())
+		write("first\n")
+		time.Sleep(60 * time.Millisecond)
+		write("second\n")
+
+		Expect(file.Backups()).To(HaveLen(1))
+		Expect(contents(path)).To(Equal("second\n"))
+	})
+
+	It("keeps only the newest backups", func() {
+		var err error
+		file, err = NewRotatingFile(path, 2, 0, 2)
+		Expect(err).NotTo(HaveOccurred())
+		for _, line := range []string{"1\n", "2\n", "3\n", "4\n"} {
+			write(line)
+		}
+
+		backups, err := file.Backups()
+		Expect(err).NotTo(HaveOccurred())
+		Expect(backups).To(HaveLen(2))
+		Expect(contents(backups[0])).To(Equal("2\n"))
+		Expect(contents(backups[1])).To(Equal("3\n"))
+		Expect(contents(path)).To(Equal("4\n"))
+	})
+
+	It("fails writes once closed", func() {
+		var err error
+		file, err = NewRotatingFile(path, 0, 0, 0)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(file.Close()).To(Succeed())
+
+		_, err = file.Write([]byte("late\n"))
+		Expect(err).To(HaveOccurred())
+	})
+})
@@ -0,0 +1,27 @@
+package logger
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/uber-go/zap"
+)
+
+// TimeFormatter returns the formatter writing the time of the log entries
+// under key in format, one of config.TimestampFormats
+func TimeFormatter(key, format string) (zap.TimeFormatter, error) {
+	switch format {
+	case "", "epoch":
+		return zap.EpochFormatter(key), nil
+	case "rfc3339":
+		return zap.RFC3339Formatter(key), nil
+	case "rfc3339nano":
+		return zap.RFC3339NanoFormatter(key), nil
+	case "unix_millis":
+		return zap.TimeFormatter(func(t time.Time) zap.Field {
+			return zap.Int64(key, t.UnixNano()/int64(time.Millisecond))
+		}), nil
+	default:
+		return nil, fmt.Errorf("unknown timestamp format: %s", format)
+	}
+}
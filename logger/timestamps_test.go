@@ -0,0 +1,47 @@
+package logger_test
+
+import (
+	. "code.cloudfoundry.org/gorouter/logger"
+	"code.cloudfoundry.org/gorouter/test_util"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/onsi/gomega/gbytes"
+	"github.com/uber-go/zap"
+)
+
+var _ = Describe("TimeFormatter", func() {
+	var testSink *test_util.TestZapSink
+
+	BeforeEach(func() {
+		testSink = &test_util.TestZapSink{Buffer: gbytes.NewBuffer()}
+	})
+
+	log := func(format string) string {
+		timeFormatter, err := TimeFormatter("timestamp", format)
+		Expect(err).NotTo(HaveOccurred())
+		levels, err := NewLevels("info", nil)
+		Expect(err).NotTo(HaveOccurred())
+
+		NewLoggerWithLevels("my-component", levels, timeFormatter, zap.Output(testSink)).Info("my-action")
+		Expect(testSink.Lines()).To(HaveLen(1))
+		return testSink.Lines()[0]
+	}
+
+	It("writes epoch timestamps by default", func() {
+		Expect(log("")).To(MatchRegexp(`"timestamp":\d+\.\d+,`))
+	})
+
+	It("writes RFC 3339 timestamps with nanoseconds", func() {
+		Expect(log("rfc3339nano")).To(MatchRegexp(`"timestamp":"\d{4}-\d{2}-\d{2}T\d{2}:\d{2}:\d{2}\.\d+`))
+	})
+
+	It("writes unix milliseconds", func() {
+		Expect(log("unix_millis")).To(MatchRegexp(`"timestamp":\d{13},`))
+	})
+
+	It("rejects unknown formats", func() {
+		_, err := TimeFormatter("timestamp", "iso")
+		Expect(err).To(HaveOccurred())
+	})
+})
@@ -0,0 +1,116 @@
+package mbus
+
+import (
+	"hash/fnv"
+	"sync"
+	"time"
+)
+
+// sourceWindow counts the registrations of a source in the current second
+type sourceWindow struct {
+	start time.Time
+	count int
+}
+
+// registrationLimiter drops the registrations of sources sending more than
+// maxPerSecond of them, and repeats of a registration within coalesceWindow,
+// which would only refresh the endpoint again. Zero disables either.
+type registrationLimiter struct {
+	maxPerSecond   int
+	coalesceWindow time.Duration
+
+	lock    sync.Mutex
+	sources map[string]*sourceWindow
+	seen    map[uint64]time.Time
+	swept   time.Time
+}
+
+func newRegistrationLimiter(maxPerSecond int, coalesceWindow time.Duration) *registrationLimiter {
+	return &registrationLimiter{
+		maxPerSecond:   maxPerSecond,
+		coalesceWindow: coalesceWindow,
+		sources:        make(map[string]*sourceWindow),
+		seen:           make(map[uint64]time.Time),
+	}
+}
+
+// allow counts a registration of source and reports whether it is within the
+// rate, and whether it is the first one of the second beyond it
+func (l *registrationLimiter) allow(source string, now time.Time) (allowed, flood bool) {
+	if l.maxPerSecond <= 0 {
+		return true, false
+	}
+
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	l.sweep(now)
+
+	w, ok := l.sources[source]
+	if !ok || now.Sub(w.start) >= time.Second {
+		w = &sourceWindow{start: now}
+		l.sources[source] = w
+	}
+	w.count++
+	return w.count <= l.maxPerSecond, w.count == l.maxPerSecond+1
+}
+
+// duplicate reports whether the registration data was already seen within
+// the coalescing window, and records it otherwise
+func (l *registrationLimiter) duplicate(data []byte, now time.Time) bool {
+	if l.coalesceWindow <= 0 {
+		return false
+	}
+
+	sum := checksum(data)
+
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	l.sweep(now)
+
+	if seen, ok := l.seen[sum]; ok && now.Sub(seen) < l.coalesceWindow {
+		return true
+	}
+	l.seen[sum] = now
+	return false
+}
+
+// forget drops the registrations seen, so that one repeated after an
+// unregistration is processed again
+func (l *registrationLimiter) forget() {
+	if l.coalesceWindow <= 0 {
+		return
+	}
+
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	l.seen = make(map[uint64]time.Time)
+}
+
+// sweep forgets the sources and registrations that no longer matter, at most
+// once a second. It must be called with the lock held.
+func (l *registrationLimiter) sweep(now time.Time) {
+	if now.Sub(l.swept) < time.Second {
+		return
+	}
+	l.swept = now
+
+	for source, w := range l.sources {
+		if now.Sub(w.start) >= time.Second {
+			delete(l.sources, source)
+		}
+	}
+	for sum, seen := range l.seen {
+		if now.Sub(seen) >= l.coalesceWindow {
+			delete(l.seen, sum)
+		}
+	}
+}
+
+func checksum(data []byte) uint64 {
+	h := fnv.New64a()
+	h.Write(data)
+	return h.Sum64()
+}
@@ -0,0 +1,142 @@
+package mbus
+
+import (
+	"sync"
+	"time"
+)
+
+// rateWindow is the period over which the registration rate is measured
+const rateWindow = 10 * time.Second
+
+// registrationRate measures the registrations received per second over the
+// last complete window
+type registrationRate struct {
+	lock     sync.Mutex
+	start    time.Time
+	count    int
+	previous float64
+}
+
+func (r *registrationRate) record(n int, now time.Time) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	r.roll(now)
+	r.count += n
+}
+
+func (r *registrationRate) perSecond(now time.Time) float64 {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	r.roll(now)
+	return r.previous
+}
+
+// roll closes the current window once it is over. A window followed by an
+// idle one no longer counts. It must be called with the lock held.
+func (r *registrationRate) roll(now time.Time) {
+	elapsed := now.Sub(r.start)
+	if elapsed < rateWindow {
+		return
+	}
+
+	if elapsed < 2*rateWindow {
+		r.previous = float64(r.count) / rateWindow.Seconds()
+	} else {
+		r.previous = 0
+	}
+	r.start = now
+	r.count = 0
+}
+
+// registerInterval returns the interval, in seconds, emitters should register
+// on: the minimum, stretched in proportion to the rate beyond the target so
+// that the same emitters would send no more than it, up to the maximum
+func registerInterval(minimum, maximum, target int, rate float64) int {
+	if target <= 0 || rate <= float64(target) {
+		return minimum
+	}
+
+	interval := int(float64(minimum)*rate/float64(target) + 0.999)
+	if interval > maximum {
+		return maximum
+	}
+	return interval
+}
+
+// refresh is the last registration of an endpoint's routes
+type refresh struct {
+	at     time.Time
+	sum    uint64
+	warned time.Time
+}
+
+// refreshTracker tracks how often each endpoint registers its routes, to
+// find emitters refreshing them more often than the minimum interval. A
+// refresh within half of it is early, allowing for jitter.
+type refreshTracker struct {
+	minimum time.Duration
+
+	lock      sync.Mutex
+	endpoints map[string]*refresh
+	swept     time.Time
+}
+
+func newRefreshTracker(minimum time.Duration) *refreshTracker {
+	return &refreshTracker{
+		minimum:   minimum,
+		endpoints: make(map[string]*refresh),
+	}
+}
+
+// track records a registration of key and reports whether it came early,
+// whether it repeated the previous one, and whether the emitter should be
+// warned, which happens at most once per interval for each endpoint
+func (t *refreshTracker) track(key string, sum uint64, now time.Time) (early, repeat, warn bool) {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	t.sweep(now)
+
+	r, ok := t.endpoints[key]
+	if !ok {
+		t.endpoints[key] = &refresh{at: now, sum: sum}
+		return false, false, false
+	}
+
+	early = now.Sub(r.at) < t.minimum/2
+	repeat = r.sum == sum
+	if early && now.Sub(r.warned) >= t.minimum {
+		r.warned = now
+		warn = true
+	}
+	if !early || !repeat {
+		r.at = now
+		r.sum = sum
+	}
+	return early, repeat, warn
+}
+
+// forget drops the endpoint, so that it registers again without delay
+func (t *refreshTracker) forget(key string) {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	delete(t.endpoints, key)
+}
+
+// sweep drops the endpoints that no longer refresh, at most once per
+// interval. It must be called with the lock held.
+func (t *refreshTracker) sweep(now time.Time) {
+	if now.Sub(t.swept) < t.minimum {
+		return
+	}
+	t.swept = now
+
+	for key, r := range t.endpoints {
+		if now.Sub(r.at) >= 2*t.minimum {
+			delete(t.endpoints, key)
+		}
+	}
+}
@@ -0,0 +1,92 @@
+package mbus
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+)
+
+// SignedMessage is the envelope carrying a signed registry message. Signature
+// is the hex encoded HMAC-SHA256 of the raw Message bytes, computed with the
+// key belonging to KeyID, or with the shared key when KeyID is empty.
+type SignedMessage struct {
+	KeyID     string          `json:"key_id,omitempty"`
+	Signature string          `json:"signature"`
+	Message   json.RawMessage `json:"message"`
+}
+
+var (
+	ErrMessageNotSigned = errors.New("message is not signed")
+	ErrInvalidSignature = errors.New("invalid message signature")
+)
+
+// MessageVerifier authenticates signed registry messages
+type MessageVerifier struct {
+	sharedKey     []byte
+	publisherKeys map[string][]byte
+}
+
+// NewMessageVerifier returns a MessageVerifier accepting messages signed with
+// the shared key or with one of the per-publisher keys
+func NewMessageVerifier(sharedKey string, publisherKeys map[string]string) *MessageVerifier {
+	keys := make(map[string][]byte, len(publisherKeys))
+	for id, key := range publisherKeys {
+		keys[id] = []byte(key)
+	}
+
+	return &MessageVerifier{
+		sharedKey:     []byte(sharedKey),
+		publisherKeys: keys,
+	}
+}
+
+// Verify checks the signature of a SignedMessage and returns the inner message
+func (v *MessageVerifier) Verify(data []byte) ([]byte, error) {
+	var signed SignedMessage
+	err := json.Unmarshal(data, &signed)
+	if err != nil || signed.Signature == "" || len(signed.Message) == 0 {
+		return nil, ErrMessageNotSigned
+	}
+
+	key := v.sharedKey
+	if signed.KeyID != "" {
+		var ok bool
+		key, ok = v.publisherKeys[signed.KeyID]
+		if !ok {
+			return nil, fmt.Errorf("unknown signing key %q", signed.KeyID)
+		}
+	}
+	if len(key) == 0 {
+		return nil, ErrInvalidSignature
+	}
+
+	signature, err := hex.DecodeString(signed.Signature)
+	if err != nil {
+		return nil, ErrInvalidSignature
+	}
+
+	if !hmac.Equal(signature, computeSignature(key, signed.Message)) {
+		return nil, ErrInvalidSignature
+	}
+
+	return signed.Message, nil
+}
+
+// SignMessage wraps payload in a SignedMessage signed with key. An empty keyID
+// signs with the shared key.
+func SignMessage(keyID string, key, payload []byte) ([]byte, error) {
+	return json.Marshal(SignedMessage{
+		KeyID:     keyID,
+		Signature: hex.EncodeToString(computeSignature(key, payload)),
+		Message:   payload,
+	})
+}
+
+func computeSignature(key, payload []byte) []byte {
+	mac := hmac.New(sha256.New, key)
+	_, _ = mac.Write(payload)
+	return mac.Sum(nil)
+}
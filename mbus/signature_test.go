@@ -0,0 +1,75 @@
+package mbus_test
+
+import (
+	"encoding/json"
+
+	. "code.cloudfoundry.org/gorouter/mbus"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("MessageVerifier", func() {
+	var (
+		verifier *MessageVerifier
+		payload  []byte
+	)
+
+	BeforeEach(func() {
+		verifier = NewMessageVerifier("shared-secret", map[string]string{"cc": "cc-secret"})
+		payload = []byte(`{"host":"1.2.3.4","port":1234,"uris":["test.com"]}`)
+	})
+
+	It("accepts messages signed with the shared key", func() {
+		data, err := SignMessage("", []byte("shared-secret"), payload)
+		Expect(err).NotTo(HaveOccurred())
+
+		msg, err := verifier.Verify(data)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(msg).To(MatchJSON(payload))
+	})
+
+	It("accepts messages signed with a publisher key", func() {
+		data, err := SignMessage("cc", []byte("cc-secret"), payload)
+		Expect(err).NotTo(HaveOccurred())
+
+		msg, err := verifier.Verify(data)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(msg).To(MatchJSON(payload))
+	})
+
+	It("rejects unsigned messages", func() {
+		_, err := verifier.Verify(payload)
+		Expect(err).To(Equal(ErrMessageNotSigned))
+	})
+
+	It("rejects messages signed with the wrong key", func() {
+		data, err := SignMessage("cc", []byte("shared-secret"), payload)
+		Expect(err).NotTo(HaveOccurred())
+
+		_, err = verifier.Verify(data)
+		Expect(err).To(Equal(ErrInvalidSignature))
+	})
+
+	It("rejects messages naming an unknown key", func() {
+		data, err := SignMessage("rogue", []byte("rogue-secret"), payload)
+		Expect(err).NotTo(HaveOccurred())
+
+		_, err = verifier.Verify(data)
+		Expect(err).To(HaveOccurred())
+	})
+
+	It("rejects messages whose payload was tampered with", func() {
+		data, err := SignMessage("", []byte("shared-secret"), payload)
+		Expect(err).NotTo(HaveOccurred())
+
+		var signed SignedMessage
+		Expect(json.Unmarshal(data, &signed)).To(Succeed())
+		signed.Message = []byte(`{"host":"6.6.6.6","port":1234,"uris":["test.com"]}`)
+		data, err = json.Marshal(signed)
+		Expect(err).NotTo(HaveOccurred())
+
+		_, err = verifier.Verify(data)
+		Expect(err).To(Equal(ErrInvalidSignature))
+	})
+})
@@ -3,11 +3,16 @@ package mbus
 import (
 	"encoding/json"
 	"errors"
+	"fmt"
 	"os"
+	"sort"
 	"strings"
+	"time"
 
 	"code.cloudfoundry.org/gorouter/common"
+	"code.cloudfoundry.org/gorouter/config"
 	"code.cloudfoundry.org/gorouter/logger"
+	"code.cloudfoundry.org/gorouter/metrics"
 	"code.cloudfoundry.org/gorouter/registry"
 	"code.cloudfoundry.org/gorouter/route"
 	"code.cloudfoundry.org/localip"
@@ -29,10 +34,34 @@ type RegistryMessage struct {
 	PrivateInstanceID       string            `json:"private_instance_id"`
 	PrivateInstanceIndex    string            `json:"private_instance_index"`
 	IsolationSegment        string            `json:"isolation_segment"`
+	// ErrorPage names a page configured in error_pages.files and
+	// ErrorPageHTML carries a page inline; either is served when none of the
+	// route's endpoints can handle a request
+	ErrorPage     string `json:"error_page,omitempty"`
+	ErrorPageHTML string `json:"error_page_html,omitempty"`
+	// AllowedMethods, when set, restricts the route to these methods
+	AllowedMethods []string `json:"allowed_methods,omitempty"`
+	// CORS, when set, has the router handle CORS for the route
+	CORS *config.CORSPolicy `json:"cors,omitempty"`
+	// SecurityHeaders, when set, overrides the configured security headers
+	SecurityHeaders *config.SecurityHeaders `json:"security_headers,omitempty"`
+	// Visibility "internal" serves the route on the internal listener only
+	Visibility string `json:"visibility,omitempty"`
+	// BandwidthLimit, when set, overrides the configured bandwidth limit
+	BandwidthLimit *config.BandwidthLimit `json:"bandwidth_limit,omitempty"`
+	// PathRewrite, when set, rewrites the path prefix of the route before
+	// requests are forwarded to the endpoint
+	PathRewrite *config.PathRewrite `json:"path_rewrite,omitempty"`
+	// QueryMatch, when set, restricts the endpoint to the requests carrying
+	// these query parameter values
+	QueryMatch map[string]string `json:"query_match,omitempty"`
+	// UpstreamProxy, when set, names the configured proxy to dial the
+	// endpoint through
+	UpstreamProxy string `json:"upstream_proxy,omitempty"`
 }
 
 func (rm *RegistryMessage) makeEndpoint() *route.Endpoint {
-	return route.NewEndpoint(
+	endpoint := route.NewEndpoint(
 		rm.App,
 		rm.Host,
 		rm.Port,
@@ -44,6 +73,20 @@ func (rm *RegistryMessage) makeEndpoint() *route.Endpoint {
 		models.ModificationTag{},
 		rm.IsolationSegment,
 	)
+	endpoint.ErrorPage = rm.ErrorPage
+	endpoint.ErrorPageHTML = rm.ErrorPageHTML
+	for _, method := range rm.AllowedMethods {
+		endpoint.AllowedMethods = append(endpoint.AllowedMethods, strings.ToUpper(method))
+	}
+	endpoint.CORS = rm.CORS
+	endpoint.SecurityHeaders = rm.SecurityHeaders
+	endpoint.Visibility = strings.ToLower(rm.Visibility)
+	endpoint.BandwidthLimit = rm.BandwidthLimit
+	endpoint.PathRewrite = rm.PathRewrite
+	endpoint.QueryMatch = rm.QueryMatch
+	endpoint.RouteServiceOnly = rm.Host == "" && rm.RouteServiceURL != ""
+	endpoint.UpstreamProxy = rm.UpstreamProxy
+	return endpoint
 }
 
 // ValidateMessage checks to ensure the registry message is valid
@@ -51,6 +94,30 @@ func (rm *RegistryMessage) ValidateMessage() bool {
 	return rm.RouteServiceURL == "" || strings.HasPrefix(rm.RouteServiceURL, "https")
 }
 
+// Capabilities advertised to emitters in router.start and router.greet
+// messages
+const (
+	CapabilityBatchRegistration  = "batch_registration"
+	CapabilitySignedRegistration = "signed_registration"
+	CapabilityRouteServices      = "route_services"
+	CapabilityErrorPages         = "error_pages"
+	CapabilityAllowedMethods     = "allowed_methods"
+	CapabilityCORS               = "cors"
+	CapabilitySecurityHeaders    = "security_headers"
+	CapabilityRouteVisibility    = "route_visibility"
+	CapabilityBandwidthLimit     = "bandwidth_limit"
+	CapabilityPathRewrite        = "path_rewrite"
+	CapabilityQueryMatch         = "query_match"
+	CapabilityRouteServiceOnly   = "route_service_only"
+	CapabilityUpstreamProxy      = "upstream_proxy"
+)
+
+// RegistryBatchMessage defines the format of a router.register_batch
+// message, carrying the registrations of many routes
+type RegistryBatchMessage struct {
+	Registrations []RegistryMessage `json:"registrations"`
+}
+
 // Subscriber subscribes to NATS for all router.* messages and handles them
 type Subscriber struct {
 	logger        logger.Logger
@@ -58,6 +125,10 @@ type Subscriber struct {
 	startMsgChan  <-chan struct{}
 	opts          *SubscriberOpts
 	routeRegistry registry.Registry
+	reporter      metrics.RouteRegistryReporter
+	limiter       *registrationLimiter
+	rate          *registrationRate
+	refreshes     *refreshTracker
 }
 
 // SubscriberOpts contains configuration for Subscriber struct
@@ -65,6 +136,30 @@ type SubscriberOpts struct {
 	ID                               string
 	MinimumRegisterIntervalInSeconds int
 	PruneThresholdInSeconds          int
+	// Verifier, when set, rejects register and unregister messages that are
+	// not signed with a known key
+	Verifier *MessageVerifier
+	// MaxErrorPageBytes, when positive, drops inline error pages larger than
+	// it from registrations
+	MaxErrorPageBytes int
+	// MaxRegistrationsPerSecond, when positive, drops the registrations of
+	// an endpoint host beyond it
+	MaxRegistrationsPerSecond int
+	// CoalesceWindow, when positive, drops registrations identical to one
+	// processed within it
+	CoalesceWindow time.Duration
+	// TargetRegistrationsPerSecond, when positive, stretches the advertised
+	// registration interval up to MaxRegisterIntervalInSeconds while more
+	// registrations than it arrive
+	TargetRegistrationsPerSecond int
+	MaxRegisterIntervalInSeconds int
+	// EnforceRegisterInterval reports and logs endpoints refreshing their
+	// registration within half of MinimumRegisterIntervalInSeconds, and
+	// DropEarlyRefreshes drops such refreshes when they change nothing
+	EnforceRegisterInterval bool
+	DropEarlyRefreshes      bool
+	// Capabilities are advertised to emitters in start and greet messages
+	Capabilities []string
 }
 
 // NewSubscriber returns a new Subscriber
@@ -72,6 +167,7 @@ func NewSubscriber(
 	logger logger.Logger,
 	natsClient *nats.Conn,
 	routeRegistry registry.Registry,
+	reporter metrics.RouteRegistryReporter,
 	startMsgChan <-chan struct{},
 	opts *SubscriberOpts,
 ) *Subscriber {
@@ -79,8 +175,12 @@ func NewSubscriber(
 		logger:        logger,
 		natsClient:    natsClient,
 		routeRegistry: routeRegistry,
+		reporter:      reporter,
 		startMsgChan:  startMsgChan,
 		opts:          opts,
+		limiter:       newRegistrationLimiter(opts.MaxRegistrationsPerSecond, opts.CoalesceWindow),
+		rate:          &registrationRate{},
+		refreshes:     newRefreshTracker(time.Duration(opts.MinimumRegisterIntervalInSeconds) * time.Second),
 	}
 }
 
@@ -127,7 +227,22 @@ func (s *Subscriber) subscribeToGreetMessage() error {
 
 func (s *Subscriber) subscribeRoutes() error {
 	natsSubscriber, err := s.natsClient.Subscribe("router.*", func(message *nats.Msg) {
-		msg, regErr := createRegistryMessage(message.Data)
+		data, authErr := s.authenticate(message)
+		if authErr != nil {
+			s.logger.Error("unauthenticated-registry-message",
+				zap.Error(authErr),
+				zap.String("subject", message.Subject),
+			)
+			s.reporter.CaptureRejectedRegistryMessage()
+			return
+		}
+
+		if message.Subject == "router.register_batch" {
+			s.registerBatch(data)
+			return
+		}
+
+		msg, regErr := createRegistryMessage(data)
 		if regErr != nil {
 			s.logger.Error("validation-error",
 				zap.Error(regErr),
@@ -138,8 +253,16 @@ func (s *Subscriber) subscribeRoutes() error {
 		}
 		switch message.Subject {
 		case "router.register":
+			s.rate.record(1, time.Now())
+			if s.limited(msg, data) || s.early(msg, data) {
+				return
+			}
 			s.registerEndpoint(msg)
 		case "router.unregister":
+			s.limiter.forget()
+			if s.opts.EnforceRegisterInterval {
+				s.refreshes.forget(refreshKey(msg))
+			}
 			s.unregisterEndpoint(msg)
 			s.logger.Info("unregister-route", zap.String("message", string(message.Data)))
 		default:
@@ -151,13 +274,133 @@ func (s *Subscriber) subscribeRoutes() error {
 	return err
 }
 
+// authenticate returns the registry message carried by a NATS message,
+// verifying its signature when registration authentication is enabled
+func (s *Subscriber) authenticate(message *nats.Msg) ([]byte, error) {
+	if s.opts.Verifier == nil {
+		return message.Data, nil
+	}
+
+	switch message.Subject {
+	case "router.register", "router.register_batch", "router.unregister":
+		return s.opts.Verifier.Verify(message.Data)
+	default:
+		return message.Data, nil
+	}
+}
+
+// limited reports whether a registration is dropped, as a repeat or because
+// its source, the endpoint host, exceeded its rate
+func (s *Subscriber) limited(msg *RegistryMessage, data []byte) bool {
+	now := time.Now()
+	if s.limiter.duplicate(data, now) {
+		return true
+	}
+
+	allowed, flood := s.limiter.allow(msg.Host, now)
+	if flood {
+		s.logger.Info("registration-flood-detected",
+			zap.String("source", msg.Host),
+			zap.Int("max-per-second", s.opts.MaxRegistrationsPerSecond),
+		)
+		s.reporter.CaptureRegistrationFlood()
+	}
+	if !allowed {
+		s.reporter.CaptureRateLimitedRegistryMessage()
+	}
+	return !allowed
+}
+
+// early reports whether a registration is dropped as an unchanged refresh
+// arriving too soon after the previous one of its endpoint
+func (s *Subscriber) early(msg *RegistryMessage, data []byte) bool {
+	if !s.opts.EnforceRegisterInterval {
+		return false
+	}
+
+	early, repeat, warn := s.refreshes.track(refreshKey(msg), checksum(data), time.Now())
+	if !early {
+		return false
+	}
+
+	drop := repeat && s.opts.DropEarlyRefreshes
+	if warn {
+		s.logger.Info("registration-too-frequent",
+			zap.String("app", msg.App),
+			zap.String("host", msg.Host),
+			zap.Int("port", int(msg.Port)),
+			zap.Int("minimum-register-interval-in-seconds", s.opts.MinimumRegisterIntervalInSeconds),
+		)
+	}
+	s.reporter.CaptureEarlyRegistryRefresh(drop)
+	return drop
+}
+
+// refreshKey identifies the routes of an endpoint a registration refreshes
+func refreshKey(msg *RegistryMessage) string {
+	uris := make([]string, len(msg.Uris))
+	for i, uri := range msg.Uris {
+		uris[i] = strings.ToLower(string(uri))
+	}
+	sort.Strings(uris)
+	return fmt.Sprintf("%s:%d %s", msg.Host, msg.Port, strings.Join(uris, " "))
+}
+
 func (s *Subscriber) registerEndpoint(msg *RegistryMessage) {
+	s.limitErrorPage(msg)
+
 	endpoint := msg.makeEndpoint()
 	for _, uri := range msg.Uris {
 		s.routeRegistry.Register(uri, endpoint)
 	}
 }
 
+// registerBatch registers every route of a batch message at once. A batch
+// with an invalid registration is dropped whole.
+func (s *Subscriber) registerBatch(data []byte) {
+	batch, err := createRegistryBatchMessage(data)
+	if err != nil {
+		s.logger.Error("validation-error",
+			zap.Error(err),
+			zap.String("payload", string(data)),
+			zap.String("subject", "router.register_batch"),
+		)
+		return
+	}
+
+	now := time.Now()
+	s.rate.record(len(batch.Registrations), now)
+	if s.limiter.duplicate(data, now) {
+		return
+	}
+
+	var registrations []registry.Registration
+	for i := range batch.Registrations {
+		msg := &batch.Registrations[i]
+		s.limitErrorPage(msg)
+
+		endpoint := msg.makeEndpoint()
+		for _, uri := range msg.Uris {
+			registrations = append(registrations, registry.Registration{URI: uri, Endpoint: endpoint})
+		}
+	}
+	if len(registrations) > 0 {
+		s.routeRegistry.RegisterBatch(registrations)
+	}
+}
+
+// limitErrorPage drops an inline error page larger than allowed
+func (s *Subscriber) limitErrorPage(msg *RegistryMessage) {
+	if s.opts.MaxErrorPageBytes > 0 && len(msg.ErrorPageHTML) > s.opts.MaxErrorPageBytes {
+		s.logger.Info("error-page-too-large",
+			zap.String("app", msg.App),
+			zap.Int("bytes", len(msg.ErrorPageHTML)),
+			zap.Int("max-bytes", s.opts.MaxErrorPageBytes),
+		)
+		msg.ErrorPageHTML = ""
+	}
+}
+
 func (s *Subscriber) unregisterEndpoint(msg *RegistryMessage) {
 	endpoint := msg.makeEndpoint()
 	for _, uri := range msg.Uris {
@@ -174,8 +417,14 @@ func (s *Subscriber) startMessage() ([]byte, error) {
 	d := common.RouterStart{
 		Id:    s.opts.ID,
 		Hosts: []string{host},
-		MinimumRegisterIntervalInSeconds: s.opts.MinimumRegisterIntervalInSeconds,
-		PruneThresholdInSeconds:          s.opts.PruneThresholdInSeconds,
+		MinimumRegisterIntervalInSeconds: registerInterval(
+			s.opts.MinimumRegisterIntervalInSeconds,
+			s.opts.MaxRegisterIntervalInSeconds,
+			s.opts.TargetRegistrationsPerSecond,
+			s.rate.perSecond(time.Now()),
+		),
+		PruneThresholdInSeconds: s.opts.PruneThresholdInSeconds,
+		Capabilities:            s.opts.Capabilities,
 	}
 	message, err := json.Marshal(d)
 	if err != nil {
@@ -208,3 +457,20 @@ func createRegistryMessage(data []byte) (*RegistryMessage, error) {
 
 	return &msg, nil
 }
+
+func createRegistryBatchMessage(data []byte) (*RegistryBatchMessage, error) {
+	var batch RegistryBatchMessage
+
+	jsonErr := json.Unmarshal(data, &batch)
+	if jsonErr != nil {
+		return nil, jsonErr
+	}
+
+	for _, msg := range batch.Registrations {
+		if !msg.ValidateMessage() {
+			return nil, errors.New("Unable to validate message. route_service_url must be https")
+		}
+	}
+
+	return &batch, nil
+}
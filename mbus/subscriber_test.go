@@ -4,10 +4,13 @@ import (
 	"encoding/json"
 	"os"
 	"sync/atomic"
+	"time"
 
 	"code.cloudfoundry.org/gorouter/common"
+	"code.cloudfoundry.org/gorouter/config"
 	"code.cloudfoundry.org/gorouter/logger"
 	"code.cloudfoundry.org/gorouter/mbus"
+	metricFakes "code.cloudfoundry.org/gorouter/metrics/fakes"
 	"code.cloudfoundry.org/gorouter/registry/fakes"
 	"code.cloudfoundry.org/gorouter/route"
 	"code.cloudfoundry.org/gorouter/test_util"
@@ -15,6 +18,7 @@ import (
 	"github.com/nats-io/nats"
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/gomega"
+	"github.com/onsi/gomega/gbytes"
 	"github.com/tedsuo/ifrit"
 )
 
@@ -25,6 +29,7 @@ var _ = Describe("Subscriber", func() {
 		process ifrit.Process
 
 		registry *fakes.FakeRegistry
+		reporter *metricFakes.FakeRouteRegistryReporter
 
 		natsRunner   *test_util.NATSRunner
 		natsPort     uint16
@@ -42,6 +47,7 @@ var _ = Describe("Subscriber", func() {
 		natsClient = natsRunner.MessageBus
 
 		registry = new(fakes.FakeRegistry)
+		reporter = new(metricFakes.FakeRouteRegistryReporter)
 
 		logger = test_util.NewTestZapLogger("mbus-test")
 
@@ -53,7 +59,7 @@ var _ = Describe("Subscriber", func() {
 			PruneThresholdInSeconds:          120,
 		}
 
-		sub = mbus.NewSubscriber(logger, natsClient, registry, startMsgChan, subOpts)
+		sub = mbus.NewSubscriber(logger, natsClient, registry, reporter, startMsgChan, subOpts)
 	})
 
 	AfterEach(func() {
@@ -102,7 +108,7 @@ var _ = Describe("Subscriber", func() {
 	})
 
 	It("errors when publish start message fails", func() {
-		sub = mbus.NewSubscriber(logger, nil, registry, startMsgChan, subOpts)
+		sub = mbus.NewSubscriber(logger, nil, registry, reporter, startMsgChan, subOpts)
 		process = ifrit.Invoke(sub)
 
 		var err error
@@ -184,6 +190,46 @@ var _ = Describe("Subscriber", func() {
 			Expect(message.Hosts).ToNot(BeEmpty())
 			Expect(message.MinimumRegisterIntervalInSeconds).To(Equal(subOpts.MinimumRegisterIntervalInSeconds))
 			Expect(message.PruneThresholdInSeconds).To(Equal(subOpts.PruneThresholdInSeconds))
+			Expect(message.Capabilities).To(BeEmpty())
+		})
+
+		It("advertises the router's capabilities", func() {
+			subOpts.Capabilities = []string{mbus.CapabilityBatchRegistration, mbus.CapabilityCORS}
+			msgChan := make(chan *nats.Msg, 1)
+
+			_, err := natsClient.ChanSubscribe("router.greet.test.response", msgChan)
+			Expect(err).ToNot(HaveOccurred())
+
+			err = natsClient.PublishRequest("router.greet", "router.greet.test.response", []byte{})
+			Expect(err).ToNot(HaveOccurred())
+
+			var msg *nats.Msg
+			Eventually(msgChan).Should(Receive(&msg))
+
+			var message common.RouterStart
+			err = json.Unmarshal(msg.Data, &message)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(message.Capabilities).To(ConsistOf("batch_registration", "cors"))
+		})
+
+		It("advertises the minimum interval while registrations stay below the target", func() {
+			subOpts.TargetRegistrationsPerSecond = 100
+			subOpts.MaxRegisterIntervalInSeconds = 90
+			msgChan := make(chan *nats.Msg, 1)
+
+			_, err := natsClient.ChanSubscribe("router.greet.test.response", msgChan)
+			Expect(err).ToNot(HaveOccurred())
+
+			err = natsClient.PublishRequest("router.greet", "router.greet.test.response", []byte{})
+			Expect(err).ToNot(HaveOccurred())
+
+			var msg *nats.Msg
+			Eventually(msgChan).Should(Receive(&msg))
+
+			var message common.RouterStart
+			err = json.Unmarshal(msg.Data, &message)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(message.MinimumRegisterIntervalInSeconds).To(Equal(60))
 		})
 	})
 
@@ -220,7 +266,7 @@ var _ = Describe("Subscriber", func() {
 	})
 	Context("when a route is unregistered", func() {
 		BeforeEach(func() {
-			sub = mbus.NewSubscriber(logger, natsClient, registry, startMsgChan, subOpts)
+			sub = mbus.NewSubscriber(logger, natsClient, registry, reporter, startMsgChan, subOpts)
 			process = ifrit.Invoke(sub)
 			Eventually(process.Ready()).Should(BeClosed())
 		})
@@ -325,4 +371,425 @@ var _ = Describe("Subscriber", func() {
 			}
 		})
 	})
+
+	Context("when registrations carry error pages", func() {
+		BeforeEach(func() {
+			subOpts.MaxErrorPageBytes = 16
+			sub = mbus.NewSubscriber(logger, natsClient, registry, reporter, startMsgChan, subOpts)
+			process = ifrit.Invoke(sub)
+			Eventually(process.Ready()).Should(BeClosed())
+		})
+
+		It("registers the endpoint with its error page", func() {
+			data, err := json.Marshal(mbus.RegistryMessage{
+				Host:          "host",
+				App:           "app",
+				Port:          1111,
+				Uris:          []route.Uri{"test.example.com"},
+				ErrorPage:     "branded",
+				ErrorPageHTML: "<h1>oops</h1>",
+			})
+			Expect(err).NotTo(HaveOccurred())
+
+			err = natsClient.Publish("router.register", data)
+			Expect(err).ToNot(HaveOccurred())
+
+			Eventually(registry.RegisterCallCount).Should(Equal(1))
+			_, endpoint := registry.RegisterArgsForCall(0)
+			Expect(endpoint.ErrorPage).To(Equal("branded"))
+			Expect(endpoint.ErrorPageHTML).To(Equal("<h1>oops</h1>"))
+		})
+
+		It("drops inline pages that are too large", func() {
+			data, err := json.Marshal(mbus.RegistryMessage{
+				Host:          "host",
+				App:           "app",
+				Port:          1111,
+				Uris:          []route.Uri{"test.example.com"},
+				ErrorPageHTML: "<h1>a much longer page</h1>",
+			})
+			Expect(err).NotTo(HaveOccurred())
+
+			err = natsClient.Publish("router.register", data)
+			Expect(err).ToNot(HaveOccurred())
+
+			Eventually(registry.RegisterCallCount).Should(Equal(1))
+			_, endpoint := registry.RegisterArgsForCall(0)
+			Expect(endpoint.ErrorPageHTML).To(BeEmpty())
+		})
+	})
+
+	Context("when registrations restrict requests", func() {
+		BeforeEach(func() {
+			sub = mbus.NewSubscriber(logger, natsClient, registry, reporter, startMsgChan, subOpts)
+			process = ifrit.Invoke(sub)
+			Eventually(process.Ready()).Should(BeClosed())
+		})
+
+		It("registers the endpoint with its methods in upper case", func() {
+			data, err := json.Marshal(mbus.RegistryMessage{
+				Host:           "host",
+				App:            "app",
+				Port:           1111,
+				Uris:           []route.Uri{"test.example.com"},
+				AllowedMethods: []string{"get", "HEAD"},
+			})
+			Expect(err).NotTo(HaveOccurred())
+
+			err = natsClient.Publish("router.register", data)
+			Expect(err).ToNot(HaveOccurred())
+
+			Eventually(registry.RegisterCallCount).Should(Equal(1))
+			_, endpoint := registry.RegisterArgsForCall(0)
+			Expect(endpoint.AllowedMethods).To(Equal([]string{"GET", "HEAD"}))
+		})
+
+		It("registers the endpoint with its CORS policy", func() {
+			data, err := json.Marshal(mbus.RegistryMessage{
+				Host: "host",
+				App:  "app",
+				Port: 1111,
+				Uris: []route.Uri{"test.example.com"},
+				CORS: &config.CORSPolicy{AllowedOrigins: []string{"https://app.example.org"}},
+			})
+			Expect(err).NotTo(HaveOccurred())
+
+			err = natsClient.Publish("router.register", data)
+			Expect(err).ToNot(HaveOccurred())
+
+			Eventually(registry.RegisterCallCount).Should(Equal(1))
+			_, endpoint := registry.RegisterArgsForCall(0)
+			Expect(endpoint.CORS.AllowedOrigins).To(Equal([]string{"https://app.example.org"}))
+		})
+
+		It("registers the endpoint with its security headers", func() {
+			data, err := json.Marshal(mbus.RegistryMessage{
+				Host:            "host",
+				App:             "app",
+				Port:            1111,
+				Uris:            []route.Uri{"test.example.com"},
+				SecurityHeaders: &config.SecurityHeaders{FrameOptions: "DENY"},
+			})
+			Expect(err).NotTo(HaveOccurred())
+
+			err = natsClient.Publish("router.register", data)
+			Expect(err).ToNot(HaveOccurred())
+
+			Eventually(registry.RegisterCallCount).Should(Equal(1))
+			_, endpoint := registry.RegisterArgsForCall(0)
+			Expect(endpoint.SecurityHeaders.FrameOptions).To(Equal("DENY"))
+		})
+
+		It("registers the endpoint with its visibility", func() {
+			data, err := json.Marshal(mbus.RegistryMessage{
+				Host:       "host",
+				App:        "app",
+				Port:       1111,
+				Uris:       []route.Uri{"test.example.com"},
+				Visibility: "Internal",
+			})
+			Expect(err).NotTo(HaveOccurred())
+
+			err = natsClient.Publish("router.register", data)
+			Expect(err).ToNot(HaveOccurred())
+
+			Eventually(registry.RegisterCallCount).Should(Equal(1))
+			_, endpoint := registry.RegisterArgsForCall(0)
+			Expect(endpoint.Visibility).To(Equal("internal"))
+		})
+
+		It("registers the endpoint with its bandwidth limit", func() {
+			data, err := json.Marshal(mbus.RegistryMessage{
+				Host:           "host",
+				App:            "app",
+				Port:           1111,
+				Uris:           []route.Uri{"test.example.com"},
+				BandwidthLimit: &config.BandwidthLimit{PerClient: 1048576},
+			})
+			Expect(err).NotTo(HaveOccurred())
+
+			err = natsClient.Publish("router.register", data)
+			Expect(err).ToNot(HaveOccurred())
+
+			Eventually(registry.RegisterCallCount).Should(Equal(1))
+			_, endpoint := registry.RegisterArgsForCall(0)
+			Expect(endpoint.BandwidthLimit.PerClient).To(BeEquivalentTo(1048576))
+		})
+
+		It("registers the endpoint with its query match", func() {
+			msg := []byte(`{"host":"host","app":"app","port":1111,"uris":["test.example.com"],"query_match":{"version":"2"}}`)
+
+			err := natsClient.Publish("router.register", msg)
+			Expect(err).ToNot(HaveOccurred())
+
+			Eventually(registry.RegisterCallCount).Should(Equal(1))
+			_, endpoint := registry.RegisterArgsForCall(0)
+			Expect(endpoint.QueryMatch).To(Equal(map[string]string{"version": "2"}))
+		})
+
+		It("registers a route service without a host as route-service-only", func() {
+			msg := []byte(`{"app":"app","uris":["test.example.com"],"route_service_url":"https://auth.example.com"}`)
+
+			err := natsClient.Publish("router.register", msg)
+			Expect(err).ToNot(HaveOccurred())
+
+			Eventually(registry.RegisterCallCount).Should(Equal(1))
+			_, endpoint := registry.RegisterArgsForCall(0)
+			Expect(endpoint.RouteServiceOnly).To(BeTrue())
+			Expect(endpoint.RouteServiceUrl).To(Equal("https://auth.example.com"))
+		})
+
+		It("does not mark endpoints with a host route-service-only", func() {
+			msg := []byte(`{"host":"host","app":"app","port":1111,"uris":["test.example.com"],"route_service_url":"https://auth.example.com"}`)
+
+			err := natsClient.Publish("router.register", msg)
+			Expect(err).ToNot(HaveOccurred())
+
+			Eventually(registry.RegisterCallCount).Should(Equal(1))
+			_, endpoint := registry.RegisterArgsForCall(0)
+			Expect(endpoint.RouteServiceOnly).To(BeFalse())
+		})
+
+		It("registers the endpoint with its upstream proxy", func() {
+			msg := []byte(`{"host":"backend.corp","app":"app","port":8080,"uris":["test.example.com"],"upstream_proxy":"egress"}`)
+
+			err := natsClient.Publish("router.register", msg)
+			Expect(err).ToNot(HaveOccurred())
+
+			Eventually(registry.RegisterCallCount).Should(Equal(1))
+			_, endpoint := registry.RegisterArgsForCall(0)
+			Expect(endpoint.UpstreamProxy).To(Equal("egress"))
+		})
+
+		It("registers the endpoint with its path rewrite", func() {
+			msg := []byte(`{"host":"host","app":"app","port":1111,"uris":["test.example.com/api/v1"],"path_rewrite":{"strip_prefix":true}}`)
+
+			err := natsClient.Publish("router.register", msg)
+			Expect(err).ToNot(HaveOccurred())
+
+			Eventually(registry.RegisterCallCount).Should(Equal(1))
+			_, endpoint := registry.RegisterArgsForCall(0)
+			Expect(endpoint.PathRewrite).To(Equal(&config.PathRewrite{StripPrefix: true}))
+		})
+	})
+
+	Context("when route registration authentication is enabled", func() {
+		var data []byte
+
+		BeforeEach(func() {
+			subOpts.Verifier = mbus.NewMessageVerifier("shared-secret", nil)
+			sub = mbus.NewSubscriber(logger, natsClient, registry, reporter, startMsgChan, subOpts)
+			process = ifrit.Invoke(sub)
+			Eventually(process.Ready()).Should(BeClosed())
+
+			var err error
+			data, err = json.Marshal(mbus.RegistryMessage{
+				Host: "host",
+				App:  "app",
+				Port: 1111,
+				Uris: []route.Uri{"test.example.com"},
+			})
+			Expect(err).NotTo(HaveOccurred())
+		})
+
+		It("registers routes from signed messages", func() {
+			signed, err := mbus.SignMessage("", []byte("shared-secret"), data)
+			Expect(err).NotTo(HaveOccurred())
+
+			err = natsClient.Publish("router.register", signed)
+			Expect(err).ToNot(HaveOccurred())
+
+			Eventually(registry.RegisterCallCount).Should(Equal(1))
+			uri, endpoint := registry.RegisterArgsForCall(0)
+			Expect(uri).To(Equal(route.Uri("test.example.com")))
+			Expect(endpoint.ApplicationId).To(Equal("app"))
+			Expect(reporter.CaptureRejectedRegistryMessageCallCount()).To(Equal(0))
+		})
+
+		It("rejects unsigned messages", func() {
+			err := natsClient.Publish("router.register", data)
+			Expect(err).ToNot(HaveOccurred())
+
+			Eventually(reporter.CaptureRejectedRegistryMessageCallCount).Should(Equal(1))
+			Consistently(registry.RegisterCallCount).Should(BeZero())
+		})
+
+		It("rejects messages with an invalid signature", func() {
+			signed, err := mbus.SignMessage("", []byte("rogue-secret"), data)
+			Expect(err).NotTo(HaveOccurred())
+
+			err = natsClient.Publish("router.unregister", signed)
+			Expect(err).ToNot(HaveOccurred())
+
+			Eventually(reporter.CaptureRejectedRegistryMessageCallCount).Should(Equal(1))
+			Consistently(registry.UnregisterCallCount).Should(BeZero())
+		})
+	})
+
+	Context("when registrations are limited", func() {
+		register := func(host string, uri route.Uri) {
+			data, err := json.Marshal(mbus.RegistryMessage{
+				Host: host,
+				App:  "app",
+				Port: 1111,
+				Uris: []route.Uri{uri},
+			})
+			Expect(err).NotTo(HaveOccurred())
+
+			err = natsClient.Publish("router.register", data)
+			Expect(err).ToNot(HaveOccurred())
+		}
+
+		JustBeforeEach(func() {
+			sub = mbus.NewSubscriber(logger, natsClient, registry, reporter, startMsgChan, subOpts)
+			process = ifrit.Invoke(sub)
+			Eventually(process.Ready()).Should(BeClosed())
+		})
+
+		Context("by rate", func() {
+			BeforeEach(func() {
+				subOpts.MaxRegistrationsPerSecond = 2
+			})
+
+			It("drops the registrations of a source beyond its rate", func() {
+				for _, uri := range []route.Uri{"a.example.com", "b.example.com", "c.example.com", "d.example.com"} {
+					register("10.0.0.1", uri)
+				}
+				register("10.0.0.2", "e.example.com")
+
+				Eventually(reporter.CaptureRateLimitedRegistryMessageCallCount).Should(Equal(2))
+				Eventually(registry.RegisterCallCount).Should(Equal(3))
+				Expect(reporter.CaptureRegistrationFloodCallCount()).To(Equal(1))
+			})
+		})
+
+		Context("by coalescing", func() {
+			BeforeEach(func() {
+				subOpts.CoalesceWindow = time.Minute
+			})
+
+			It("drops repeats of a registration", func() {
+				register("10.0.0.1", "a.example.com")
+				register("10.0.0.1", "a.example.com")
+				register("10.0.0.1", "b.example.com")
+
+				Eventually(registry.RegisterCallCount).Should(Equal(2))
+				Consistently(registry.RegisterCallCount).Should(Equal(2))
+			})
+
+			It("processes a repeat following an unregistration", func() {
+				register("10.0.0.1", "a.example.com")
+				Eventually(registry.RegisterCallCount).Should(Equal(1))
+
+				data, err := json.Marshal(mbus.RegistryMessage{Host: "10.0.0.1", App: "app", Port: 1111, Uris: []route.Uri{"a.example.com"}})
+				Expect(err).NotTo(HaveOccurred())
+				Expect(natsClient.Publish("router.unregister", data)).To(Succeed())
+				Eventually(registry.UnregisterCallCount).Should(Equal(1))
+
+				register("10.0.0.1", "a.example.com")
+				Eventually(registry.RegisterCallCount).Should(Equal(2))
+			})
+		})
+
+		Context("by register interval", func() {
+			BeforeEach(func() {
+				subOpts.EnforceRegisterInterval = true
+			})
+
+			It("reports refreshes sooner than the minimum interval", func() {
+				register("10.0.0.1", "a.example.com")
+				register("10.0.0.1", "a.example.com")
+				register("10.0.0.2", "a.example.com")
+
+				Eventually(registry.RegisterCallCount).Should(Equal(3))
+				Expect(reporter.CaptureEarlyRegistryRefreshCallCount()).To(Equal(1))
+				Expect(reporter.CaptureEarlyRegistryRefreshArgsForCall(0)).To(BeFalse())
+				Expect(logger).To(gbytes.Say("registration-too-frequent"))
+			})
+
+			Context("when dropping early refreshes", func() {
+				BeforeEach(func() {
+					subOpts.DropEarlyRefreshes = true
+				})
+
+				It("drops refreshes that change nothing", func() {
+					register("10.0.0.1", "a.example.com")
+					register("10.0.0.1", "a.example.com")
+
+					Eventually(reporter.CaptureEarlyRegistryRefreshCallCount).Should(Equal(1))
+					Expect(reporter.CaptureEarlyRegistryRefreshArgsForCall(0)).To(BeTrue())
+					Consistently(registry.RegisterCallCount).Should(Equal(1))
+				})
+
+				It("applies early registrations that change the endpoint", func() {
+					register("10.0.0.1", "a.example.com")
+					data, err := json.Marshal(mbus.RegistryMessage{
+						Host: "10.0.0.1",
+						App:  "app",
+						Port: 1111,
+						Uris: []route.Uri{"a.example.com"},
+						Tags: map[string]string{"component": "new"},
+					})
+					Expect(err).NotTo(HaveOccurred())
+					Expect(natsClient.Publish("router.register", data)).To(Succeed())
+
+					Eventually(registry.RegisterCallCount).Should(Equal(2))
+				})
+
+				It("applies a registration following an unregistration", func() {
+					register("10.0.0.1", "a.example.com")
+					Eventually(registry.RegisterCallCount).Should(Equal(1))
+
+					data, err := json.Marshal(mbus.RegistryMessage{Host: "10.0.0.1", App: "app", Port: 1111, Uris: []route.Uri{"a.example.com"}})
+					Expect(err).NotTo(HaveOccurred())
+					Expect(natsClient.Publish("router.unregister", data)).To(Succeed())
+					Eventually(registry.UnregisterCallCount).Should(Equal(1))
+
+					register("10.0.0.1", "a.example.com")
+					Eventually(registry.RegisterCallCount).Should(Equal(2))
+				})
+			})
+		})
+	})
+
+	Context("when a batch of registrations is received", func() {
+		JustBeforeEach(func() {
+			sub = mbus.NewSubscriber(logger, natsClient, registry, reporter, startMsgChan, subOpts)
+			process = ifrit.Invoke(sub)
+			Eventually(process.Ready()).Should(BeClosed())
+		})
+
+		It("registers all of its routes at once", func() {
+			data, err := json.Marshal(mbus.RegistryBatchMessage{
+				Registrations: []mbus.RegistryMessage{
+					{Host: "10.0.0.1", App: "app1", Port: 1111, Uris: []route.Uri{"a.example.com", "b.example.com"}},
+					{Host: "10.0.0.2", App: "app2", Port: 2222, Uris: []route.Uri{"c.example.com"}},
+				},
+			})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(natsClient.Publish("router.register_batch", data)).To(Succeed())
+
+			Eventually(registry.RegisterBatchCallCount).Should(Equal(1))
+			registrations := registry.RegisterBatchArgsForCall(0)
+			Expect(registrations).To(HaveLen(3))
+			Expect(registrations[0].URI).To(Equal(route.Uri("a.example.com")))
+			Expect(registrations[1].URI).To(Equal(route.Uri("b.example.com")))
+			Expect(registrations[2].URI).To(Equal(route.Uri("c.example.com")))
+			Expect(registrations[2].Endpoint.CanonicalAddr()).To(Equal("10.0.0.2:2222"))
+			Expect(registry.RegisterCallCount()).To(Equal(0))
+		})
+
+		It("drops a batch with an invalid registration", func() {
+			data, err := json.Marshal(mbus.RegistryBatchMessage{
+				Registrations: []mbus.RegistryMessage{
+					{Host: "10.0.0.1", App: "app1", Port: 1111, Uris: []route.Uri{"a.example.com"}},
+					{Host: "10.0.0.2", App: "app2", Port: 2222, Uris: []route.Uri{"c.example.com"}, RouteServiceURL: "http://insecure"},
+				},
+			})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(natsClient.Publish("router.register_batch", data)).To(Succeed())
+
+			Consistently(registry.RegisterBatchCallCount).Should(Equal(0))
+		})
+	})
 })
@@ -0,0 +1,154 @@
+package metrics
+
+import (
+	"math"
+	"os"
+	"sync"
+	"time"
+)
+
+// minStdDev keeps routes whose baseline has never varied, usually because
+// they never failed, from alerting on a single error
+const minStdDev = 0.01
+
+// Anomaly describes a window in which the 5xx rate of a route spiked beyond
+// its baseline
+type Anomaly struct {
+	Route     string
+	Requests  uint64
+	Errors    uint64
+	ErrorRate float64
+	Baseline  float64
+	StdDev    float64
+}
+
+type routeBaseline struct {
+	requests, errors uint64
+	windows          int
+	idleWindows      int
+	mean, variance   float64
+}
+
+// AnomalyDetector keeps a baseline of the 5xx rate of every route, as an
+// exponentially weighted mean and variance over fixed windows, and reports
+// the routes whose rate in the last window exceeds the baseline by more than
+// sigma standard deviations.
+type AnomalyDetector struct {
+	window          time.Duration
+	baselineWindows int
+	sigma           float64
+	minRequests     uint64
+	maxRoutes       int
+	onAnomaly       func(Anomaly)
+
+	lock   sync.Mutex
+	routes map[string]*routeBaseline
+}
+
+// NewAnomalyDetector returns a detector closing a window every window. Routes
+// are only checked once their baseline covers baselineWindows windows, and
+// windows with fewer than minRequests requests are ignored.
+func NewAnomalyDetector(window time.Duration, baselineWindows int, sigma float64, minRequests, maxRoutes int, onAnomaly func(Anomaly)) *AnomalyDetector {
+	return &AnomalyDetector{
+		window:          window,
+		baselineWindows: baselineWindows,
+		sigma:           sigma,
+		minRequests:     uint64(minRequests),
+		maxRoutes:       maxRoutes,
+		onAnomaly:       onAnomaly,
+		routes:          make(map[string]*routeBaseline),
+	}
+}
+
+// CaptureResponse counts a response of route in the current window. Routes
+// beyond the limit are not tracked.
+func (d *AnomalyDetector) CaptureResponse(route string, statusCode int) {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+
+	b, ok := d.routes[route]
+	if !ok {
+		if len(d.routes) >= d.maxRoutes {
+			return
+		}
+		b = &routeBaseline{}
+		d.routes[route] = b
+	}
+
+	b.requests++
+	if statusCode >= 500 && statusCode < 600 {
+		b.errors++
+	}
+}
+
+// Evaluate closes the current window of every route, reports anomalies and
+// folds the window into the baselines
+func (d *AnomalyDetector) Evaluate() {
+	var anomalies []Anomaly
+
+	d.lock.Lock()
+	for route, b := range d.routes {
+		if b.requests == 0 {
+			b.idleWindows++
+			if b.idleWindows >= d.baselineWindows {
+				delete(d.routes, route)
+			}
+			continue
+		}
+		b.idleWindows = 0
+
+		if b.requests >= d.minRequests {
+			rate := float64(b.errors) / float64(b.requests)
+			stdDev := math.Sqrt(b.variance)
+			if b.windows >= d.baselineWindows && b.errors > 0 && rate > b.mean+d.sigma*math.Max(stdDev, minStdDev) {
+				anomalies = append(anomalies, Anomaly{
+					Route:     route,
+					Requests:  b.requests,
+					Errors:    b.errors,
+					ErrorRate: rate,
+					Baseline:  b.mean,
+					StdDev:    stdDev,
+				})
+			}
+			d.update(b, rate)
+		}
+		b.requests, b.errors = 0, 0
+	}
+	d.lock.Unlock()
+
+	for _, anomaly := range anomalies {
+		d.onAnomaly(anomaly)
+	}
+}
+
+// update folds rate into the baseline. Until the baseline covers
+// baselineWindows windows it is a plain average, so the first windows are not
+// underweighted.
+func (d *AnomalyDetector) update(b *routeBaseline, rate float64) {
+	b.windows++
+	alpha := 2 / float64(d.baselineWindows+1)
+	if 1/float64(b.windows) > alpha {
+		alpha = 1 / float64(b.windows)
+	}
+
+	diff := rate - b.mean
+	incr := alpha * diff
+	b.mean += incr
+	b.variance = (1 - alpha) * (b.variance + diff*incr)
+}
+
+// Run evaluates the routes every window until signaled
+func (d *AnomalyDetector) Run(signals <-chan os.Signal, ready chan<- struct{}) error {
+	ticker := time.NewTicker(d.window)
+	defer ticker.Stop()
+	close(ready)
+
+	for {
+		select {
+		case <-ticker.C:
+			d.Evaluate()
+		case <-signals:
+			return nil
+		}
+	}
+}
@@ -0,0 +1,92 @@
+package metrics_test
+
+import (
+	"net/http"
+	"os"
+	"time"
+
+	"code.cloudfoundry.org/gorouter/metrics"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/tedsuo/ifrit"
+)
+
+var _ = Describe("AnomalyDetector", func() {
+	var (
+		detector  *metrics.AnomalyDetector
+		anomalies []metrics.Anomaly
+	)
+
+	window := func(route string, requests, errors int) {
+		for i := 0; i < requests; i++ {
+			status := http.StatusOK
+			if i < errors {
+				status = http.StatusBadGateway
+			}
+			detector.CaptureResponse(route, status)
+		}
+		detector.Evaluate()
+	}
+
+	BeforeEach(func() {
+		anomalies = nil
+		detector = metrics.NewAnomalyDetector(time.Minute, 5, 3, 10, 2, func(a metrics.Anomaly) {
+			anomalies = append(anomalies, a)
+		})
+	})
+
+	It("reports a route whose error rate spikes beyond its baseline", func() {
+		for i := 0; i < 5; i++ {
+			window("app.example.com", 100, i%2)
+		}
+		Expect(anomalies).To(BeEmpty())
+
+		window("app.example.com", 100, 40)
+		Expect(anomalies).To(HaveLen(1))
+		Expect(anomalies[0].Route).To(Equal("app.example.com"))
+		Expect(anomalies[0].Requests).To(BeEquivalentTo(100))
+		Expect(anomalies[0].Errors).To(BeEquivalentTo(40))
+		Expect(anomalies[0].ErrorRate).To(BeNumerically("~", 0.4))
+		Expect(anomalies[0].Baseline).To(BeNumerically("~", 0.004, 0.001))
+	})
+
+	It("does not report routes with a steady error rate", func() {
+		for i := 0; i < 10; i++ {
+			window("app.example.com", 100, 20)
+		}
+		Expect(anomalies).To(BeEmpty())
+	})
+
+	It("does not report routes before the baseline is established", func() {
+		window("app.example.com", 100, 0)
+		window("app.example.com", 100, 90)
+		Expect(anomalies).To(BeEmpty())
+	})
+
+	It("ignores windows with too few requests", func() {
+		for i := 0; i < 5; i++ {
+			window("app.example.com", 100, 0)
+		}
+		window("app.example.com", 5, 5)
+		Expect(anomalies).To(BeEmpty())
+	})
+
+	It("does not track routes beyond the limit", func() {
+		for i := 0; i < 5; i++ {
+			detector.CaptureResponse("a.example.com", http.StatusOK)
+			detector.CaptureResponse("b.example.com", http.StatusOK)
+			window("c.example.com", 100, 0)
+		}
+		window("c.example.com", 100, 100)
+		Expect(anomalies).To(BeEmpty())
+	})
+
+	It("evaluates the routes every window until signaled", func() {
+		detector = metrics.NewAnomalyDetector(10*time.Millisecond, 1, 3, 1, 10, func(a metrics.Anomaly) {})
+		process := ifrit.Invoke(detector)
+
+		process.Signal(os.Interrupt)
+		Eventually(process.Wait()).Should(Receive(BeNil()))
+	})
+})
@@ -0,0 +1,130 @@
+package metrics
+
+import (
+	"encoding/json"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+)
+
+// AuthFailureStats counts the 401 and 403 responses of a route's backends
+type AuthFailureStats struct {
+	Unauthorized uint64 `json:"unauthorized"`
+	Forbidden    uint64 `json:"forbidden"`
+
+	window uint64
+}
+
+// AuthFailureAlert describes a window in which a route's backends answered at
+// least the threshold of requests with 401 or 403
+type AuthFailureAlert struct {
+	Route    string
+	Failures uint64
+}
+
+// AuthFailureAggregator keeps cumulative per-route counters of backend 401 and
+// 403 responses, served as JSON, and reports the routes whose failures in a
+// window reach the threshold. Repeated failures from one route usually mean
+// credentials are being guessed against its app.
+type AuthFailureAggregator struct {
+	window    time.Duration
+	threshold uint64
+	maxRoutes int
+	onAlert   func(AuthFailureAlert)
+
+	lock   sync.Mutex
+	routes map[string]*AuthFailureStats
+}
+
+// NewAuthFailureAggregator returns an aggregator closing a window every
+// window and tracking at most maxRoutes routes. A zero threshold never
+// alerts.
+func NewAuthFailureAggregator(window time.Duration, threshold, maxRoutes int, onAlert func(AuthFailureAlert)) *AuthFailureAggregator {
+	return &AuthFailureAggregator{
+		window:    window,
+		threshold: uint64(threshold),
+		maxRoutes: maxRoutes,
+		onAlert:   onAlert,
+		routes:    make(map[string]*AuthFailureStats),
+	}
+}
+
+// CaptureResponse counts a backend response of route when it is a 401 or a
+// 403. Routes beyond the limit are not tracked.
+func (a *AuthFailureAggregator) CaptureResponse(route string, statusCode int) {
+	if statusCode != http.StatusUnauthorized && statusCode != http.StatusForbidden {
+		return
+	}
+
+	a.lock.Lock()
+	defer a.lock.Unlock()
+
+	s, ok := a.routes[route]
+	if !ok {
+		if len(a.routes) >= a.maxRoutes {
+			return
+		}
+		s = &AuthFailureStats{}
+		a.routes[route] = s
+	}
+
+	if statusCode == http.StatusUnauthorized {
+		s.Unauthorized++
+	} else {
+		s.Forbidden++
+	}
+	s.window++
+}
+
+// Evaluate closes the current window of every route and reports the routes
+// that reached the threshold in it
+func (a *AuthFailureAggregator) Evaluate() {
+	var alerts []AuthFailureAlert
+
+	a.lock.Lock()
+	for route, s := range a.routes {
+		if a.threshold > 0 && s.window >= a.threshold {
+			alerts = append(alerts, AuthFailureAlert{Route: route, Failures: s.window})
+		}
+		s.window = 0
+	}
+	a.lock.Unlock()
+
+	for _, alert := range alerts {
+		a.onAlert(alert)
+	}
+}
+
+// Snapshot returns a copy of the current counters, by route
+func (a *AuthFailureAggregator) Snapshot() map[string]AuthFailureStats {
+	a.lock.Lock()
+	defer a.lock.Unlock()
+
+	snapshot := make(map[string]AuthFailureStats, len(a.routes))
+	for route, s := range a.routes {
+		snapshot[route] = AuthFailureStats{Unauthorized: s.Unauthorized, Forbidden: s.Forbidden}
+	}
+	return snapshot
+}
+
+func (a *AuthFailureAggregator) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(a.Snapshot())
+}
+
+// Run evaluates the routes every window until signaled
+func (a *AuthFailureAggregator) Run(signals <-chan os.Signal, ready chan<- struct{}) error {
+	ticker := time.NewTicker(a.window)
+	defer ticker.Stop()
+	close(ready)
+
+	for {
+		select {
+		case <-ticker.C:
+			a.Evaluate()
+		case <-signals:
+			return nil
+		}
+	}
+}
@@ -0,0 +1,90 @@
+package metrics_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"time"
+
+	"code.cloudfoundry.org/gorouter/metrics"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("AuthFailureAggregator", func() {
+	var (
+		aggregator *metrics.AuthFailureAggregator
+		alerts     []metrics.AuthFailureAlert
+	)
+
+	BeforeEach(func() {
+		alerts = nil
+		aggregator = metrics.NewAuthFailureAggregator(time.Minute, 3, 2, func(a metrics.AuthFailureAlert) {
+			alerts = append(alerts, a)
+		})
+	})
+
+	It("counts 401 and 403 responses per route", func() {
+		aggregator.CaptureResponse("app.example.com", http.StatusUnauthorized)
+		aggregator.CaptureResponse("app.example.com", http.StatusForbidden)
+		aggregator.CaptureResponse("app.example.com", http.StatusUnauthorized)
+		aggregator.CaptureResponse("app.example.com", http.StatusOK)
+		aggregator.CaptureResponse("api.example.com", http.StatusNotFound)
+
+		Expect(aggregator.Snapshot()).To(Equal(map[string]metrics.AuthFailureStats{
+			"app.example.com": {Unauthorized: 2, Forbidden: 1},
+		}))
+	})
+
+	It("does not track routes beyond the limit", func() {
+		for _, route := range []string{"a.example.com", "b.example.com", "c.example.com"} {
+			aggregator.CaptureResponse(route, http.StatusForbidden)
+		}
+
+		Expect(aggregator.Snapshot()).To(HaveLen(2))
+	})
+
+	It("alerts on routes reaching the threshold within a window", func() {
+		for i := 0; i < 3; i++ {
+			aggregator.CaptureResponse("app.example.com", http.StatusUnauthorized)
+		}
+		aggregator.CaptureResponse("api.example.com", http.StatusUnauthorized)
+		aggregator.Evaluate()
+
+		Expect(alerts).To(Equal([]metrics.AuthFailureAlert{{Route: "app.example.com", Failures: 3}}))
+	})
+
+	It("starts every window over", func() {
+		aggregator.CaptureResponse("app.example.com", http.StatusUnauthorized)
+		aggregator.CaptureResponse("app.example.com", http.StatusUnauthorized)
+		aggregator.Evaluate()
+		aggregator.CaptureResponse("app.example.com", http.StatusUnauthorized)
+		aggregator.Evaluate()
+
+		Expect(alerts).To(BeEmpty())
+		Expect(aggregator.Snapshot()["app.example.com"].Unauthorized).To(BeEquivalentTo(3))
+	})
+
+	It("never alerts with a zero threshold", func() {
+		aggregator = metrics.NewAuthFailureAggregator(time.Minute, 0, 2, func(a metrics.AuthFailureAlert) {
+			alerts = append(alerts, a)
+		})
+		aggregator.CaptureResponse("app.example.com", http.StatusUnauthorized)
+		aggregator.Evaluate()
+
+		Expect(alerts).To(BeEmpty())
+	})
+
+	It("serves the counters as JSON", func() {
+		aggregator.CaptureResponse("app.example.com", http.StatusForbidden)
+
+		resp := httptest.NewRecorder()
+		aggregator.ServeHTTP(resp, httptest.NewRequest("GET", "/metrics/auth_failures", nil))
+		Expect(resp.Header().Get("Content-Type")).To(Equal("application/json"))
+
+		var snapshot map[string]metrics.AuthFailureStats
+		Expect(json.Unmarshal(resp.Body.Bytes(), &snapshot)).To(Succeed())
+		Expect(snapshot["app.example.com"].Forbidden).To(BeEquivalentTo(1))
+	})
+})
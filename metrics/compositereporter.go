@@ -25,8 +25,16 @@ type ProxyReporter interface {
 	CaptureRoutingResponse(statusCode int)
 	CaptureRoutingResponseLatency(b *route.Endpoint, d time.Duration)
 	CaptureRouteServiceResponse(res *http.Response)
+	CaptureRouteServiceDirectResponse(statusCode int)
 	CaptureWebSocketUpdate()
 	CaptureWebSocketFailure()
+	CaptureInformationalResponse(statusCode int)
+	CaptureRangeRequest(b *route.Endpoint, statusCode int)
+	CaptureEndpointGroupResponse(b *route.Endpoint, group string, statusCode int, d time.Duration)
+	CaptureMethodNotAllowed()
+	CaptureClientWriteStall()
+	CaptureRouterError(kind string)
+	CaptureCoalescedRequest()
 }
 
 type ComponentTagged interface {
@@ -36,9 +44,74 @@ type ComponentTagged interface {
 //go:generate counterfeiter -o fakes/fake_registry_reporter.go . RouteRegistryReporter
 type RouteRegistryReporter interface {
 	CaptureRouteStats(totalRoutes int, msSinceLastUpdate uint64)
+	CaptureRegistryMemory(trieNodes int, trieBytes, poolBytes, endpointBytes int64)
 	CaptureLookupTime(t time.Duration)
 	CaptureRegistryMessage(msg ComponentTagged)
 	CaptureUnregistryMessage(msg ComponentTagged)
+	CaptureRejectedRegistryMessage()
+	CaptureStaleRegistryUpdate(policy string)
+	CaptureRateLimitedRegistryMessage()
+	CaptureRegistrationFlood()
+	CaptureRegistryBatch(routes int)
+	CaptureEarlyRegistryRefresh(dropped bool)
+	CaptureSourceConflict(resolution string)
+	CaptureRouteTableLimitReached(limit string)
+	CapturePruneCycle(d time.Duration, endpointsPruned, poolsDeleted int)
+	CaptureTimeSinceLastPrune(d time.Duration)
+}
+
+//go:generate counterfeiter -o fakes/fake_pluginreporter.go . PluginReporter
+type PluginReporter interface {
+	CapturePluginCall(name string, d time.Duration)
+	CapturePluginFailure(name string)
+	CapturePluginHealth(name string, healthy bool)
+}
+
+//go:generate counterfeiter -o fakes/fake_anomalyreporter.go . AnomalyReporter
+type AnomalyReporter interface {
+	CaptureRouteErrorAnomaly()
+}
+
+//go:generate counterfeiter -o fakes/fake_authfailurereporter.go . AuthFailureReporter
+type AuthFailureReporter interface {
+	CaptureAuthFailureThresholdExceeded()
+}
+
+//go:generate counterfeiter -o fakes/fake_concurrencylimitreporter.go . ConcurrencyLimitReporter
+type ConcurrencyLimitReporter interface {
+	CaptureConcurrencyLimited()
+}
+
+//go:generate counterfeiter -o fakes/fake_ocspreporter.go . OCSPReporter
+type OCSPReporter interface {
+	CaptureOCSPStapleValidity(remaining time.Duration)
+	CaptureOCSPFetchFailure()
+}
+
+//go:generate counterfeiter -o fakes/fake_tlsreporter.go . TLSReporter
+type TLSReporter interface {
+	CaptureTLSHandshake(listener string, version, cipherSuite uint16)
+}
+
+//go:generate counterfeiter -o fakes/fake_requestvalidationreporter.go . RequestValidationReporter
+type RequestValidationReporter interface {
+	CaptureInvalidRequest(violation string, rejected bool)
+}
+
+//go:generate counterfeiter -o fakes/fake_connectionlimitreporter.go . ConnectionLimitReporter
+type ConnectionLimitReporter interface {
+	CaptureConnectionLimited(policy string)
+}
+
+//go:generate counterfeiter -o fakes/fake_ingressreporter.go . IngressReporter
+type IngressReporter interface {
+	CaptureIngressRejected(listener, reason string)
+}
+
+//go:generate counterfeiter -o fakes/fake_acceptreporter.go . AcceptReporter
+type AcceptReporter interface {
+	CaptureAccept(listener string)
+	CaptureAcceptError(listener string, temporary bool)
 }
 
 //go:generate counterfeiter -o fakes/fake_combinedreporter.go . CombinedReporter
@@ -49,8 +122,16 @@ type CombinedReporter interface {
 	CaptureRoutingResponse(statusCode int)
 	CaptureRoutingResponseLatency(b *route.Endpoint, statusCode int, t time.Time, d time.Duration)
 	CaptureRouteServiceResponse(res *http.Response)
+	CaptureRouteServiceDirectResponse(statusCode int)
 	CaptureWebSocketUpdate()
 	CaptureWebSocketFailure()
+	CaptureInformationalResponse(statusCode int)
+	CaptureRangeRequest(b *route.Endpoint, statusCode int)
+	CaptureEndpointGroupResponse(b *route.Endpoint, group string, statusCode int, d time.Duration)
+	CaptureMethodNotAllowed()
+	CaptureClientWriteStall()
+	CaptureRouterError(kind string)
+	CaptureCoalescedRequest()
 }
 
 type CompositeReporter struct {
@@ -84,6 +165,10 @@ func (c *CompositeReporter) CaptureRouteServiceResponse(res *http.Response) {
 	c.proxyReporter.CaptureRouteServiceResponse(res)
 }
 
+func (c *CompositeReporter) CaptureRouteServiceDirectResponse(statusCode int) {
+	c.proxyReporter.CaptureRouteServiceDirectResponse(statusCode)
+}
+
 func (c *CompositeReporter) CaptureRoutingResponse(statusCode int) {
 	c.proxyReporter.CaptureRoutingResponse(statusCode)
 }
@@ -100,3 +185,39 @@ func (c *CompositeReporter) CaptureWebSocketUpdate() {
 func (c *CompositeReporter) CaptureWebSocketFailure() {
 	c.proxyReporter.CaptureWebSocketFailure()
 }
+
+func (c *CompositeReporter) CaptureInformationalResponse(statusCode int) {
+	c.proxyReporter.CaptureInformationalResponse(statusCode)
+}
+
+func (c *CompositeReporter) CaptureRangeRequest(b *route.Endpoint, statusCode int) {
+	c.proxyReporter.CaptureRangeRequest(b, statusCode)
+}
+
+func (c *CompositeReporter) CaptureEndpointGroupResponse(b *route.Endpoint, group string, statusCode int, d time.Duration) {
+	c.proxyReporter.CaptureEndpointGroupResponse(b, group, statusCode, d)
+}
+
+func (c *CompositeReporter) CaptureMethodNotAllowed() {
+	c.proxyReporter.CaptureMethodNotAllowed()
+}
+
+func (c *CompositeReporter) CaptureClientWriteStall() {
+	c.proxyReporter.CaptureClientWriteStall()
+}
+
+func (c *CompositeReporter) CaptureRouterError(kind string) {
+	c.proxyReporter.CaptureRouterError(kind)
+}
+
+func (c *CompositeReporter) CaptureCoalescedRequest() {
+	c.proxyReporter.CaptureCoalescedRequest()
+}
+
+// CaptureConcurrencyLimited forwards to the proxy reporter when it counts
+// requests rejected by concurrency limits
+func (c *CompositeReporter) CaptureConcurrencyLimited() {
+	if r, ok := c.proxyReporter.(ConcurrencyLimitReporter); ok {
+		r.CaptureConcurrencyLimited()
+	}
+}
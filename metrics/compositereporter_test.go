@@ -86,6 +86,13 @@ var _ = Describe("CompositeReporter", func() {
 		Expect(callResponse).To(Equal(response))
 	})
 
+	It("forwards CaptureRouteServiceDirectResponse to proxy reporter", func() {
+		composite.CaptureRouteServiceDirectResponse(response.StatusCode)
+
+		Expect(fakeProxyReporter.CaptureRouteServiceDirectResponseCallCount()).To(Equal(1))
+		Expect(fakeProxyReporter.CaptureRouteServiceDirectResponseArgsForCall(0)).To(Equal(response.StatusCode))
+	})
+
 	It("forwards CaptureRoutingResponse to proxy reporter", func() {
 		composite.CaptureRoutingResponse(response.StatusCode)
 
@@ -106,4 +113,56 @@ var _ = Describe("CompositeReporter", func() {
 
 		Expect(fakeProxyReporter.CaptureWebSocketFailureCallCount()).To(Equal(1))
 	})
+
+	It("forwards CaptureInformationalResponse to proxy reporter", func() {
+		composite.CaptureInformationalResponse(http.StatusEarlyHints)
+
+		Expect(fakeProxyReporter.CaptureInformationalResponseCallCount()).To(Equal(1))
+		Expect(fakeProxyReporter.CaptureInformationalResponseArgsForCall(0)).To(Equal(http.StatusEarlyHints))
+	})
+
+	It("forwards CaptureRangeRequest to proxy reporter", func() {
+		composite.CaptureRangeRequest(endpoint, http.StatusPartialContent)
+
+		Expect(fakeProxyReporter.CaptureRangeRequestCallCount()).To(Equal(1))
+		callEndpoint, statusCode := fakeProxyReporter.CaptureRangeRequestArgsForCall(0)
+		Expect(callEndpoint).To(Equal(endpoint))
+		Expect(statusCode).To(Equal(http.StatusPartialContent))
+	})
+
+	It("forwards CaptureEndpointGroupResponse to proxy reporter", func() {
+		composite.CaptureEndpointGroupResponse(endpoint, "canary", http.StatusOK, responseDuration)
+
+		Expect(fakeProxyReporter.CaptureEndpointGroupResponseCallCount()).To(Equal(1))
+		callEndpoint, group, statusCode, duration := fakeProxyReporter.CaptureEndpointGroupResponseArgsForCall(0)
+		Expect(callEndpoint).To(Equal(endpoint))
+		Expect(group).To(Equal("canary"))
+		Expect(statusCode).To(Equal(http.StatusOK))
+		Expect(duration).To(Equal(responseDuration))
+	})
+
+	It("forwards CaptureMethodNotAllowed to proxy reporter", func() {
+		composite.CaptureMethodNotAllowed()
+
+		Expect(fakeProxyReporter.CaptureMethodNotAllowedCallCount()).To(Equal(1))
+	})
+
+	It("forwards CaptureClientWriteStall to proxy reporter", func() {
+		composite.CaptureClientWriteStall()
+
+		Expect(fakeProxyReporter.CaptureClientWriteStallCallCount()).To(Equal(1))
+	})
+
+	It("forwards CaptureCoalescedRequest to proxy reporter", func() {
+		composite.CaptureCoalescedRequest()
+
+		Expect(fakeProxyReporter.CaptureCoalescedRequestCallCount()).To(Equal(1))
+	})
+
+	It("forwards CaptureRouterError to proxy reporter", func() {
+		composite.CaptureRouterError("no_endpoints")
+
+		Expect(fakeProxyReporter.CaptureRouterErrorCallCount()).To(Equal(1))
+		Expect(fakeProxyReporter.CaptureRouterErrorArgsForCall(0)).To(Equal("no_endpoints"))
+	})
 })
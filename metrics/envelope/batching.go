@@ -0,0 +1,168 @@
+package envelope
+
+import (
+	"errors"
+	"sync"
+	"time"
+
+	"code.cloudfoundry.org/gorouter/config"
+	"github.com/cloudfoundry/dropsonde/emitter"
+	"github.com/cloudfoundry/sonde-go/events"
+)
+
+// DroppedEnvelopes counts the envelopes that were dropped, either because a
+// full batch was waiting or because sending them failed
+const DroppedEnvelopes = "dropped_envelopes"
+
+// ErrBatchFull is returned for the envelopes emitted while a full batch waits
+// to be sent
+var ErrBatchFull = errors.New("envelope batch is full")
+
+// BatchingEmitter is a dropsonde event emitter queueing envelopes rather than
+// sending them from the goroutines emitting them. A goroutine of its own sends
+// the queued envelopes every flush interval, or as soon as a batch is full.
+// The loggregator agent takes one envelope per datagram, so envelopes are
+// still sent one by one.
+type BatchingEmitter struct {
+	inner        emitter.ByteEmitter
+	origin       string
+	maxBatchSize int
+
+	lock    sync.Mutex
+	pending []*events.Envelope
+	dropped uint64
+
+	// reported is the number of dropped envelopes last counted, only used by
+	// the flushing goroutine
+	reported uint64
+
+	full      chan struct{}
+	stop      chan struct{}
+	stopped   chan struct{}
+	closeOnce sync.Once
+}
+
+// NewBatchingEmitter starts sending the envelopes emitted with the origin to
+// inner
+func NewBatchingEmitter(inner emitter.ByteEmitter, origin string, cfg config.EnvelopeBatchingConfig) *BatchingEmitter {
+	e := &BatchingEmitter{
+		inner:        inner,
+		origin:       origin,
+		maxBatchSize: cfg.MaxBatchSize,
+		pending:      make([]*events.Envelope, 0, cfg.MaxBatchSize),
+		full:         make(chan struct{}, 1),
+		stop:         make(chan struct{}),
+		stopped:      make(chan struct{}),
+	}
+	go e.run(cfg.FlushInterval)
+	return e
+}
+
+func (e *BatchingEmitter) Origin() string {
+	return e.origin
+}
+
+func (e *BatchingEmitter) Emit(event events.Event) error {
+	envelope, err := emitter.Wrap(event, e.origin)
+	if err != nil {
+		return err
+	}
+	return e.EmitEnvelope(envelope)
+}
+
+// EmitEnvelope queues the envelope for the next batch
+func (e *BatchingEmitter) EmitEnvelope(envelope *events.Envelope) error {
+	e.lock.Lock()
+	if len(e.pending) >= e.maxBatchSize {
+		e.dropped++
+		e.lock.Unlock()
+		return ErrBatchFull
+	}
+	e.pending = append(e.pending, envelope)
+	full := len(e.pending) == e.maxBatchSize
+	e.lock.Unlock()
+
+	if full {
+		select {
+		case e.full <- struct{}{}:
+		default:
+		}
+	}
+	return nil
+}
+
+// Close sends the queued envelopes and closes inner. Envelopes emitted from
+// then on are not sent.
+func (e *BatchingEmitter) Close() {
+	e.closeOnce.Do(func() {
+		close(e.stop)
+		<-e.stopped
+		e.inner.Close()
+	})
+}
+
+func (e *BatchingEmitter) run(interval time.Duration) {
+	defer close(e.stopped)
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+		case <-e.full:
+		case <-e.stop:
+			e.flush()
+			return
+		}
+		e.flush()
+	}
+}
+
+func (e *BatchingEmitter) flush() {
+	e.lock.Lock()
+	batch := e.pending
+	if len(batch) > 0 {
+		e.pending = make([]*events.Envelope, 0, e.maxBatchSize)
+	}
+	dropped := e.dropped
+	e.lock.Unlock()
+
+	if dropped > e.reported {
+		batch = append(batch, e.droppedCounter(dropped))
+	}
+
+	var failed uint64
+	for _, envelope := range batch {
+		if envelope == nil {
+			continue
+		}
+		data, err := envelope.Marshal()
+		if err == nil {
+			err = e.inner.Emit(data)
+		}
+		if err != nil {
+			failed++
+		}
+	}
+
+	if failed > 0 {
+		e.lock.Lock()
+		e.dropped += failed
+		e.lock.Unlock()
+	}
+}
+
+// droppedCounter counts the envelopes dropped since the last counter, or is
+// nil when it cannot be created
+func (e *BatchingEmitter) droppedCounter(dropped uint64) *events.Envelope {
+	name := DroppedEnvelopes
+	delta := dropped - e.reported
+	total := dropped
+	envelope, err := emitter.Wrap(&events.CounterEvent{Name: &name, Delta: &delta, Total: &total}, e.origin)
+	if err != nil {
+		return nil
+	}
+	e.reported = dropped
+	return envelope
+}
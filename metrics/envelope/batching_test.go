@@ -0,0 +1,146 @@
+package envelope_test
+
+import (
+	"sync"
+	"time"
+
+	"code.cloudfoundry.org/gorouter/config"
+	"code.cloudfoundry.org/gorouter/metrics/envelope"
+	"github.com/cloudfoundry/sonde-go/events"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+// fakeByteEmitter keeps the envelopes it is given, first waiting for block to
+// be closed when set
+type fakeByteEmitter struct {
+	lock      sync.Mutex
+	envelopes []*events.Envelope
+	closed    bool
+	sending   bool
+	block     chan struct{}
+}
+
+func (f *fakeByteEmitter) Emit(data []byte) error {
+	f.lock.Lock()
+	f.sending = true
+	f.lock.Unlock()
+	if f.block != nil {
+		<-f.block
+	}
+	envelope := new(events.Envelope)
+	Expect(envelope.Unmarshal(data)).To(Succeed())
+
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	f.envelopes = append(f.envelopes, envelope)
+	return nil
+}
+
+func (f *fakeByteEmitter) Close() {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	f.closed = true
+}
+
+func (f *fakeByteEmitter) isSending() bool {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	return f.sending
+}
+
+func (f *fakeByteEmitter) sent() []*events.Envelope {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	return append([]*events.Envelope(nil), f.envelopes...)
+}
+
+var _ = Describe("BatchingEmitter", func() {
+	var (
+		inner   *fakeByteEmitter
+		cfg     config.EnvelopeBatchingConfig
+		batches *envelope.BatchingEmitter
+	)
+
+	valueMetric := func(name string) events.Event {
+		value, unit := 1.0, "ms"
+		return &events.ValueMetric{Name: &name, Value: &value, Unit: &unit}
+	}
+
+	BeforeEach(func() {
+		inner = new(fakeByteEmitter)
+		cfg = config.EnvelopeBatchingConfig{Enabled: true, FlushInterval: time.Hour, MaxBatchSize: 2}
+	})
+
+	JustBeforeEach(func() {
+		batches = envelope.NewBatchingEmitter(inner, "gorouter", cfg)
+	})
+
+	AfterEach(func() {
+		if inner.block != nil {
+			select {
+			case <-inner.block:
+			default:
+				close(inner.block)
+			}
+		}
+		batches.Close()
+	})
+
+	Context("with a short flush interval", func() {
+		BeforeEach(func() {
+			cfg.FlushInterval = 10 * time.Millisecond
+		})
+
+		It("sends the envelopes every flush interval", func() {
+			Expect(batches.Emit(valueMetric("latency"))).To(Succeed())
+
+			Eventually(inner.sent).Should(HaveLen(1))
+			Expect(inner.sent()[0].GetOrigin()).To(Equal("gorouter"))
+			Expect(inner.sent()[0].GetValueMetric().GetName()).To(Equal("latency"))
+		})
+	})
+
+	It("sends full batches without waiting for the interval", func() {
+		Expect(batches.Emit(valueMetric("first"))).To(Succeed())
+		Consistently(inner.sent, 50*time.Millisecond).Should(BeEmpty())
+
+		Expect(batches.Emit(valueMetric("second"))).To(Succeed())
+		Eventually(inner.sent).Should(HaveLen(2))
+	})
+
+	It("sends the queued envelopes and closes the inner emitter when closed", func() {
+		Expect(batches.Emit(valueMetric("latency"))).To(Succeed())
+		batches.Close()
+
+		Expect(inner.sent()).To(HaveLen(1))
+		Expect(inner.closed).To(BeTrue())
+	})
+
+	Context("when a full batch waits to be sent", func() {
+		BeforeEach(func() {
+			inner.block = make(chan struct{})
+		})
+
+		It("drops the envelopes emitted meanwhile and counts them", func() {
+			Expect(batches.Emit(valueMetric("first"))).To(Succeed())
+			Expect(batches.Emit(valueMetric("second"))).To(Succeed())
+
+			// the first batch is being sent, the second fills up
+			Eventually(inner.isSending).Should(BeTrue())
+			Expect(batches.Emit(valueMetric("third"))).To(Succeed())
+			Expect(batches.Emit(valueMetric("fourth"))).To(Succeed())
+			Expect(batches.Emit(valueMetric("fifth"))).To(Equal(envelope.ErrBatchFull))
+			Expect(batches.Emit(valueMetric("sixth"))).To(Equal(envelope.ErrBatchFull))
+
+			close(inner.block)
+			Eventually(inner.sent).Should(HaveLen(5))
+
+			counter := inner.sent()[4].GetCounterEvent()
+			Expect(counter.GetName()).To(Equal(envelope.DroppedEnvelopes))
+			Expect(counter.GetDelta()).To(BeEquivalentTo(2))
+			Expect(counter.GetTotal()).To(BeEquivalentTo(2))
+		})
+	})
+})
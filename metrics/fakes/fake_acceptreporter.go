@@ -0,0 +1,71 @@
+// This file was generated by counterfeiter
+package fakes
+
+import (
+	"sync"
+
+	"code.cloudfoundry.org/gorouter/metrics"
+)
+
+type FakeAcceptReporter struct {
+	CaptureAcceptStub        func(listener string)
+	captureAcceptMutex       sync.RWMutex
+	captureAcceptArgsForCall []struct {
+		listener string
+	}
+	CaptureAcceptErrorStub        func(listener string, temporary bool)
+	captureAcceptErrorMutex       sync.RWMutex
+	captureAcceptErrorArgsForCall []struct {
+		listener  string
+		temporary bool
+	}
+}
+
+func (fake *FakeAcceptReporter) CaptureAccept(listener string) {
+	fake.captureAcceptMutex.Lock()
+	fake.captureAcceptArgsForCall = append(fake.captureAcceptArgsForCall, struct {
+		listener string
+	}{listener})
+	fake.captureAcceptMutex.Unlock()
+	if fake.CaptureAcceptStub != nil {
+		fake.CaptureAcceptStub(listener)
+	}
+}
+
+func (fake *FakeAcceptReporter) CaptureAcceptCallCount() int {
+	fake.captureAcceptMutex.RLock()
+	defer fake.captureAcceptMutex.RUnlock()
+	return len(fake.captureAcceptArgsForCall)
+}
+
+func (fake *FakeAcceptReporter) CaptureAcceptArgsForCall(i int) string {
+	fake.captureAcceptMutex.RLock()
+	defer fake.captureAcceptMutex.RUnlock()
+	return fake.captureAcceptArgsForCall[i].listener
+}
+
+func (fake *FakeAcceptReporter) CaptureAcceptError(listener string, temporary bool) {
+	fake.captureAcceptErrorMutex.Lock()
+	fake.captureAcceptErrorArgsForCall = append(fake.captureAcceptErrorArgsForCall, struct {
+		listener  string
+		temporary bool
+	}{listener, temporary})
+	fake.captureAcceptErrorMutex.Unlock()
+	if fake.CaptureAcceptErrorStub != nil {
+		fake.CaptureAcceptErrorStub(listener, temporary)
+	}
+}
+
+func (fake *FakeAcceptReporter) CaptureAcceptErrorCallCount() int {
+	fake.captureAcceptErrorMutex.RLock()
+	defer fake.captureAcceptErrorMutex.RUnlock()
+	return len(fake.captureAcceptErrorArgsForCall)
+}
+
+func (fake *FakeAcceptReporter) CaptureAcceptErrorArgsForCall(i int) (string, bool) {
+	fake.captureAcceptErrorMutex.RLock()
+	defer fake.captureAcceptErrorMutex.RUnlock()
+	return fake.captureAcceptErrorArgsForCall[i].listener, fake.captureAcceptErrorArgsForCall[i].temporary
+}
+
+var _ metrics.AcceptReporter = new(FakeAcceptReporter)
@@ -0,0 +1,31 @@
+// This file was generated by counterfeiter
+package fakes
+
+import (
+	"sync"
+
+	"code.cloudfoundry.org/gorouter/metrics"
+)
+
+type FakeAnomalyReporter struct {
+	CaptureRouteErrorAnomalyStub        func()
+	captureRouteErrorAnomalyMutex       sync.RWMutex
+	captureRouteErrorAnomalyArgsForCall []struct{}
+}
+
+func (fake *FakeAnomalyReporter) CaptureRouteErrorAnomaly() {
+	fake.captureRouteErrorAnomalyMutex.Lock()
+	fake.captureRouteErrorAnomalyArgsForCall = append(fake.captureRouteErrorAnomalyArgsForCall, struct{}{})
+	fake.captureRouteErrorAnomalyMutex.Unlock()
+	if fake.CaptureRouteErrorAnomalyStub != nil {
+		fake.CaptureRouteErrorAnomalyStub()
+	}
+}
+
+func (fake *FakeAnomalyReporter) CaptureRouteErrorAnomalyCallCount() int {
+	fake.captureRouteErrorAnomalyMutex.RLock()
+	defer fake.captureRouteErrorAnomalyMutex.RUnlock()
+	return len(fake.captureRouteErrorAnomalyArgsForCall)
+}
+
+var _ metrics.AnomalyReporter = new(FakeAnomalyReporter)
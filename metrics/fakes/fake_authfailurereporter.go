@@ -0,0 +1,31 @@
+// This file was generated by counterfeiter
+package fakes
+
+import (
+	"sync"
+
+	"code.cloudfoundry.org/gorouter/metrics"
+)
+
+type FakeAuthFailureReporter struct {
+	CaptureAuthFailureThresholdExceededStub        func()
+	captureAuthFailureThresholdExceededMutex       sync.RWMutex
+	captureAuthFailureThresholdExceededArgsForCall []struct{}
+}
+
+func (fake *FakeAuthFailureReporter) CaptureAuthFailureThresholdExceeded() {
+	fake.captureAuthFailureThresholdExceededMutex.Lock()
+	fake.captureAuthFailureThresholdExceededArgsForCall = append(fake.captureAuthFailureThresholdExceededArgsForCall, struct{}{})
+	fake.captureAuthFailureThresholdExceededMutex.Unlock()
+	if fake.CaptureAuthFailureThresholdExceededStub != nil {
+		fake.CaptureAuthFailureThresholdExceededStub()
+	}
+}
+
+func (fake *FakeAuthFailureReporter) CaptureAuthFailureThresholdExceededCallCount() int {
+	fake.captureAuthFailureThresholdExceededMutex.RLock()
+	defer fake.captureAuthFailureThresholdExceededMutex.RUnlock()
+	return len(fake.captureAuthFailureThresholdExceededArgsForCall)
+}
+
+var _ metrics.AuthFailureReporter = new(FakeAuthFailureReporter)
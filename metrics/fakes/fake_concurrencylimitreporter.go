@@ -0,0 +1,31 @@
+// This file was generated by counterfeiter
+package fakes
+
+import (
+	"sync"
+
+	"code.cloudfoundry.org/gorouter/metrics"
+)
+
+type FakeConcurrencyLimitReporter struct {
+	CaptureConcurrencyLimitedStub        func()
+	captureConcurrencyLimitedMutex       sync.RWMutex
+	captureConcurrencyLimitedArgsForCall []struct{}
+}
+
+func (fake *FakeConcurrencyLimitReporter) CaptureConcurrencyLimited() {
+	fake.captureConcurrencyLimitedMutex.Lock()
+	fake.captureConcurrencyLimitedArgsForCall = append(fake.captureConcurrencyLimitedArgsForCall, struct{}{})
+	fake.captureConcurrencyLimitedMutex.Unlock()
+	if fake.CaptureConcurrencyLimitedStub != nil {
+		fake.CaptureConcurrencyLimitedStub()
+	}
+}
+
+func (fake *FakeConcurrencyLimitReporter) CaptureConcurrencyLimitedCallCount() int {
+	fake.captureConcurrencyLimitedMutex.RLock()
+	defer fake.captureConcurrencyLimitedMutex.RUnlock()
+	return len(fake.captureConcurrencyLimitedArgsForCall)
+}
+
+var _ metrics.ConcurrencyLimitReporter = new(FakeConcurrencyLimitReporter)
@@ -0,0 +1,41 @@
+// This file was generated by counterfeiter
+package fakes
+
+import (
+	"sync"
+
+	"code.cloudfoundry.org/gorouter/metrics"
+)
+
+type FakeConnectionLimitReporter struct {
+	CaptureConnectionLimitedStub        func(policy string)
+	captureConnectionLimitedMutex       sync.RWMutex
+	captureConnectionLimitedArgsForCall []struct {
+		policy string
+	}
+}
+
+func (fake *FakeConnectionLimitReporter) CaptureConnectionLimited(policy string) {
+	fake.captureConnectionLimitedMutex.Lock()
+	fake.captureConnectionLimitedArgsForCall = append(fake.captureConnectionLimitedArgsForCall, struct {
+		policy string
+	}{policy})
+	fake.captureConnectionLimitedMutex.Unlock()
+	if fake.CaptureConnectionLimitedStub != nil {
+		fake.CaptureConnectionLimitedStub(policy)
+	}
+}
+
+func (fake *FakeConnectionLimitReporter) CaptureConnectionLimitedCallCount() int {
+	fake.captureConnectionLimitedMutex.RLock()
+	defer fake.captureConnectionLimitedMutex.RUnlock()
+	return len(fake.captureConnectionLimitedArgsForCall)
+}
+
+func (fake *FakeConnectionLimitReporter) CaptureConnectionLimitedArgsForCall(i int) string {
+	fake.captureConnectionLimitedMutex.RLock()
+	defer fake.captureConnectionLimitedMutex.RUnlock()
+	return fake.captureConnectionLimitedArgsForCall[i].policy
+}
+
+var _ metrics.ConnectionLimitReporter = new(FakeConnectionLimitReporter)
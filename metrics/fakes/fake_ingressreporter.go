@@ -0,0 +1,43 @@
+// This file was generated by counterfeiter
+package fakes
+
+import (
+	"sync"
+
+	"code.cloudfoundry.org/gorouter/metrics"
+)
+
+type FakeIngressReporter struct {
+	CaptureIngressRejectedStub        func(listener, reason string)
+	captureIngressRejectedMutex       sync.RWMutex
+	captureIngressRejectedArgsForCall []struct {
+		listener string
+		reason   string
+	}
+}
+
+func (fake *FakeIngressReporter) CaptureIngressRejected(listener string, reason string) {
+	fake.captureIngressRejectedMutex.Lock()
+	fake.captureIngressRejectedArgsForCall = append(fake.captureIngressRejectedArgsForCall, struct {
+		listener string
+		reason   string
+	}{listener, reason})
+	fake.captureIngressRejectedMutex.Unlock()
+	if fake.CaptureIngressRejectedStub != nil {
+		fake.CaptureIngressRejectedStub(listener, reason)
+	}
+}
+
+func (fake *FakeIngressReporter) CaptureIngressRejectedCallCount() int {
+	fake.captureIngressRejectedMutex.RLock()
+	defer fake.captureIngressRejectedMutex.RUnlock()
+	return len(fake.captureIngressRejectedArgsForCall)
+}
+
+func (fake *FakeIngressReporter) CaptureIngressRejectedArgsForCall(i int) (string, string) {
+	fake.captureIngressRejectedMutex.RLock()
+	defer fake.captureIngressRejectedMutex.RUnlock()
+	return fake.captureIngressRejectedArgsForCall[i].listener, fake.captureIngressRejectedArgsForCall[i].reason
+}
+
+var _ metrics.IngressReporter = new(FakeIngressReporter)
@@ -0,0 +1,111 @@
+// This file was generated by counterfeiter
+package fakes
+
+import (
+	"sync"
+	"time"
+
+	"code.cloudfoundry.org/gorouter/metrics"
+)
+
+type FakeLabeledReporter struct {
+	IncrementCounterStub        func(name string, labels metrics.Labels)
+	incrementCounterMutex       sync.RWMutex
+	incrementCounterArgsForCall []struct {
+		name   string
+		labels metrics.Labels
+	}
+	SendValueStub        func(name string, value float64, unit string, labels metrics.Labels)
+	sendValueMutex       sync.RWMutex
+	sendValueArgsForCall []struct {
+		name   string
+		value  float64
+		unit   string
+		labels metrics.Labels
+	}
+	SendDurationStub        func(name string, d time.Duration, labels metrics.Labels)
+	sendDurationMutex       sync.RWMutex
+	sendDurationArgsForCall []struct {
+		name   string
+		d      time.Duration
+		labels metrics.Labels
+	}
+}
+
+func (fake *FakeLabeledReporter) IncrementCounter(name string, labels metrics.Labels) {
+	fake.incrementCounterMutex.Lock()
+	fake.incrementCounterArgsForCall = append(fake.incrementCounterArgsForCall, struct {
+		name   string
+		labels metrics.Labels
+	}{name, labels})
+	fake.incrementCounterMutex.Unlock()
+	if fake.IncrementCounterStub != nil {
+		fake.IncrementCounterStub(name, labels)
+	}
+}
+
+func (fake *FakeLabeledReporter) IncrementCounterCallCount() int {
+	fake.incrementCounterMutex.RLock()
+	defer fake.incrementCounterMutex.RUnlock()
+	return len(fake.incrementCounterArgsForCall)
+}
+
+func (fake *FakeLabeledReporter) IncrementCounterArgsForCall(i int) (string, metrics.Labels) {
+	fake.incrementCounterMutex.RLock()
+	defer fake.incrementCounterMutex.RUnlock()
+	return fake.incrementCounterArgsForCall[i].name, fake.incrementCounterArgsForCall[i].labels
+}
+
+func (fake *FakeLabeledReporter) SendValue(name string, value float64, unit string, labels metrics.Labels) {
+	fake.sendValueMutex.Lock()
+	fake.sendValueArgsForCall = append(fake.sendValueArgsForCall, struct {
+		name   string
+		value  float64
+		unit   string
+		labels metrics.Labels
+	}{name, value, unit, labels})
+	fake.sendValueMutex.Unlock()
+	if fake.SendValueStub != nil {
+		fake.SendValueStub(name, value, unit, labels)
+	}
+}
+
+func (fake *FakeLabeledReporter) SendValueCallCount() int {
+	fake.sendValueMutex.RLock()
+	defer fake.sendValueMutex.RUnlock()
+	return len(fake.sendValueArgsForCall)
+}
+
+func (fake *FakeLabeledReporter) SendValueArgsForCall(i int) (string, float64, string, metrics.Labels) {
+	fake.sendValueMutex.RLock()
+	defer fake.sendValueMutex.RUnlock()
+	args := fake.sendValueArgsForCall[i]
+	return args.name, args.value, args.unit, args.labels
+}
+
+func (fake *FakeLabeledReporter) SendDuration(name string, d time.Duration, labels metrics.Labels) {
+	fake.sendDurationMutex.Lock()
+	fake.sendDurationArgsForCall = append(fake.sendDurationArgsForCall, struct {
+		name   string
+		d      time.Duration
+		labels metrics.Labels
+	}{name, d, labels})
+	fake.sendDurationMutex.Unlock()
+	if fake.SendDurationStub != nil {
+		fake.SendDurationStub(name, d, labels)
+	}
+}
+
+func (fake *FakeLabeledReporter) SendDurationCallCount() int {
+	fake.sendDurationMutex.RLock()
+	defer fake.sendDurationMutex.RUnlock()
+	return len(fake.sendDurationArgsForCall)
+}
+
+func (fake *FakeLabeledReporter) SendDurationArgsForCall(i int) (string, time.Duration, metrics.Labels) {
+	fake.sendDurationMutex.RLock()
+	defer fake.sendDurationMutex.RUnlock()
+	return fake.sendDurationArgsForCall[i].name, fake.sendDurationArgsForCall[i].d, fake.sendDurationArgsForCall[i].labels
+}
+
+var _ metrics.LabeledReporter = new(FakeLabeledReporter)
@@ -0,0 +1,60 @@
+// This file was generated by counterfeiter
+package fakes
+
+import (
+	"sync"
+	"time"
+
+	"code.cloudfoundry.org/gorouter/metrics"
+)
+
+type FakeOCSPReporter struct {
+	CaptureOCSPStapleValidityStub        func(remaining time.Duration)
+	captureOCSPStapleValidityMutex       sync.RWMutex
+	captureOCSPStapleValidityArgsForCall []struct {
+		remaining time.Duration
+	}
+	CaptureOCSPFetchFailureStub        func()
+	captureOCSPFetchFailureMutex       sync.RWMutex
+	captureOCSPFetchFailureArgsForCall []struct{}
+}
+
+func (fake *FakeOCSPReporter) CaptureOCSPStapleValidity(remaining time.Duration) {
+	fake.captureOCSPStapleValidityMutex.Lock()
+	fake.captureOCSPStapleValidityArgsForCall = append(fake.captureOCSPStapleValidityArgsForCall, struct {
+		remaining time.Duration
+	}{remaining})
+	fake.captureOCSPStapleValidityMutex.Unlock()
+	if fake.CaptureOCSPStapleValidityStub != nil {
+		fake.CaptureOCSPStapleValidityStub(remaining)
+	}
+}
+
+func (fake *FakeOCSPReporter) CaptureOCSPStapleValidityCallCount() int {
+	fake.captureOCSPStapleValidityMutex.RLock()
+	defer fake.captureOCSPStapleValidityMutex.RUnlock()
+	return len(fake.captureOCSPStapleValidityArgsForCall)
+}
+
+func (fake *FakeOCSPReporter) CaptureOCSPStapleValidityArgsForCall(i int) time.Duration {
+	fake.captureOCSPStapleValidityMutex.RLock()
+	defer fake.captureOCSPStapleValidityMutex.RUnlock()
+	return fake.captureOCSPStapleValidityArgsForCall[i].remaining
+}
+
+func (fake *FakeOCSPReporter) CaptureOCSPFetchFailure() {
+	fake.captureOCSPFetchFailureMutex.Lock()
+	fake.captureOCSPFetchFailureArgsForCall = append(fake.captureOCSPFetchFailureArgsForCall, struct{}{})
+	fake.captureOCSPFetchFailureMutex.Unlock()
+	if fake.CaptureOCSPFetchFailureStub != nil {
+		fake.CaptureOCSPFetchFailureStub()
+	}
+}
+
+func (fake *FakeOCSPReporter) CaptureOCSPFetchFailureCallCount() int {
+	fake.captureOCSPFetchFailureMutex.RLock()
+	defer fake.captureOCSPFetchFailureMutex.RUnlock()
+	return len(fake.captureOCSPFetchFailureArgsForCall)
+}
+
+var _ metrics.OCSPReporter = new(FakeOCSPReporter)
@@ -0,0 +1,102 @@
+// This file was generated by counterfeiter
+package fakes
+
+import (
+	"sync"
+	"time"
+
+	"code.cloudfoundry.org/gorouter/metrics"
+)
+
+type FakePluginReporter struct {
+	CapturePluginCallStub        func(name string, d time.Duration)
+	capturePluginCallMutex       sync.RWMutex
+	capturePluginCallArgsForCall []struct {
+		name string
+		d    time.Duration
+	}
+	CapturePluginFailureStub        func(name string)
+	capturePluginFailureMutex       sync.RWMutex
+	capturePluginFailureArgsForCall []struct {
+		name string
+	}
+	CapturePluginHealthStub        func(name string, healthy bool)
+	capturePluginHealthMutex       sync.RWMutex
+	capturePluginHealthArgsForCall []struct {
+		name    string
+		healthy bool
+	}
+}
+
+func (fake *FakePluginReporter) CapturePluginCall(name string, d time.Duration) {
+	fake.capturePluginCallMutex.Lock()
+	fake.capturePluginCallArgsForCall = append(fake.capturePluginCallArgsForCall, struct {
+		name string
+		d    time.Duration
+	}{name, d})
+	fake.capturePluginCallMutex.Unlock()
+	if fake.CapturePluginCallStub != nil {
+		fake.CapturePluginCallStub(name, d)
+	}
+}
+
+func (fake *FakePluginReporter) CapturePluginCallCallCount() int {
+	fake.capturePluginCallMutex.RLock()
+	defer fake.capturePluginCallMutex.RUnlock()
+	return len(fake.capturePluginCallArgsForCall)
+}
+
+func (fake *FakePluginReporter) CapturePluginCallArgsForCall(i int) (string, time.Duration) {
+	fake.capturePluginCallMutex.RLock()
+	defer fake.capturePluginCallMutex.RUnlock()
+	return fake.capturePluginCallArgsForCall[i].name, fake.capturePluginCallArgsForCall[i].d
+}
+
+func (fake *FakePluginReporter) CapturePluginFailure(name string) {
+	fake.capturePluginFailureMutex.Lock()
+	fake.capturePluginFailureArgsForCall = append(fake.capturePluginFailureArgsForCall, struct {
+		name string
+	}{name})
+	fake.capturePluginFailureMutex.Unlock()
+	if fake.CapturePluginFailureStub != nil {
+		fake.CapturePluginFailureStub(name)
+	}
+}
+
+func (fake *FakePluginReporter) CapturePluginFailureCallCount() int {
+	fake.capturePluginFailureMutex.RLock()
+	defer fake.capturePluginFailureMutex.RUnlock()
+	return len(fake.capturePluginFailureArgsForCall)
+}
+
+func (fake *FakePluginReporter) CapturePluginFailureArgsForCall(i int) string {
+	fake.capturePluginFailureMutex.RLock()
+	defer fake.capturePluginFailureMutex.RUnlock()
+	return fake.capturePluginFailureArgsForCall[i].name
+}
+
+func (fake *FakePluginReporter) CapturePluginHealth(name string, healthy bool) {
+	fake.capturePluginHealthMutex.Lock()
+	fake.capturePluginHealthArgsForCall = append(fake.capturePluginHealthArgsForCall, struct {
+		name    string
+		healthy bool
+	}{name, healthy})
+	fake.capturePluginHealthMutex.Unlock()
+	if fake.CapturePluginHealthStub != nil {
+		fake.CapturePluginHealthStub(name, healthy)
+	}
+}
+
+func (fake *FakePluginReporter) CapturePluginHealthCallCount() int {
+	fake.capturePluginHealthMutex.RLock()
+	defer fake.capturePluginHealthMutex.RUnlock()
+	return len(fake.capturePluginHealthArgsForCall)
+}
+
+func (fake *FakePluginReporter) CapturePluginHealthArgsForCall(i int) (string, bool) {
+	fake.capturePluginHealthMutex.RLock()
+	defer fake.capturePluginHealthMutex.RUnlock()
+	return fake.capturePluginHealthArgsForCall[i].name, fake.capturePluginHealthArgsForCall[i].healthy
+}
+
+var _ metrics.PluginReporter = new(FakePluginReporter)
@@ -38,12 +38,50 @@ type FakeProxyReporter struct {
 	captureRouteServiceResponseArgsForCall []struct {
 		res *http.Response
 	}
-	CaptureWebSocketUpdateStub         func()
-	captureWebSocketUpdateMutex        sync.RWMutex
-	captureWebSocketUpdateArgsForCall  []struct{}
-	CaptureWebSocketFailureStub        func()
-	captureWebSocketFailureMutex       sync.RWMutex
-	captureWebSocketFailureArgsForCall []struct{}
+	CaptureWebSocketUpdateStub              func()
+	captureWebSocketUpdateMutex             sync.RWMutex
+	captureWebSocketUpdateArgsForCall       []struct{}
+	CaptureWebSocketFailureStub             func()
+	captureWebSocketFailureMutex            sync.RWMutex
+	captureWebSocketFailureArgsForCall      []struct{}
+	CaptureInformationalResponseStub        func(statusCode int)
+	captureInformationalResponseMutex       sync.RWMutex
+	captureInformationalResponseArgsForCall []struct {
+		statusCode int
+	}
+	CaptureRangeRequestStub        func(b *route.Endpoint, statusCode int)
+	captureRangeRequestMutex       sync.RWMutex
+	captureRangeRequestArgsForCall []struct {
+		b          *route.Endpoint
+		statusCode int
+	}
+	CaptureEndpointGroupResponseStub        func(b *route.Endpoint, group string, statusCode int, d time.Duration)
+	captureEndpointGroupResponseMutex       sync.RWMutex
+	captureEndpointGroupResponseArgsForCall []struct {
+		b          *route.Endpoint
+		group      string
+		statusCode int
+		d          time.Duration
+	}
+	CaptureRouteServiceDirectResponseStub        func(statusCode int)
+	captureRouteServiceDirectResponseMutex       sync.RWMutex
+	captureRouteServiceDirectResponseArgsForCall []struct {
+		statusCode int
+	}
+	CaptureMethodNotAllowedStub        func()
+	captureMethodNotAllowedMutex       sync.RWMutex
+	captureMethodNotAllowedArgsForCall []struct{}
+	CaptureClientWriteStallStub        func()
+	captureClientWriteStallMutex       sync.RWMutex
+	captureClientWriteStallArgsForCall []struct{}
+	CaptureCoalescedRequestStub        func()
+	captureCoalescedRequestMutex       sync.RWMutex
+	captureCoalescedRequestArgsForCall []struct{}
+	CaptureRouterErrorStub             func(kind string)
+	captureRouterErrorMutex            sync.RWMutex
+	captureRouterErrorArgsForCall      []struct {
+		kind string
+	}
 }
 
 func (fake *FakeProxyReporter) CaptureBadRequest() {
@@ -199,4 +237,168 @@ func (fake *FakeProxyReporter) CaptureWebSocketFailureCallCount() int {
 	return len(fake.captureWebSocketFailureArgsForCall)
 }
 
+func (fake *FakeProxyReporter) CaptureInformationalResponse(statusCode int) {
+	fake.captureInformationalResponseMutex.Lock()
+	fake.captureInformationalResponseArgsForCall = append(fake.captureInformationalResponseArgsForCall, struct {
+		statusCode int
+	}{statusCode})
+	fake.captureInformationalResponseMutex.Unlock()
+	if fake.CaptureInformationalResponseStub != nil {
+		fake.CaptureInformationalResponseStub(statusCode)
+	}
+}
+
+func (fake *FakeProxyReporter) CaptureInformationalResponseCallCount() int {
+	fake.captureInformationalResponseMutex.RLock()
+	defer fake.captureInformationalResponseMutex.RUnlock()
+	return len(fake.captureInformationalResponseArgsForCall)
+}
+
+func (fake *FakeProxyReporter) CaptureInformationalResponseArgsForCall(i int) int {
+	fake.captureInformationalResponseMutex.RLock()
+	defer fake.captureInformationalResponseMutex.RUnlock()
+	return fake.captureInformationalResponseArgsForCall[i].statusCode
+}
+
+func (fake *FakeProxyReporter) CaptureRangeRequest(b *route.Endpoint, statusCode int) {
+	fake.captureRangeRequestMutex.Lock()
+	fake.captureRangeRequestArgsForCall = append(fake.captureRangeRequestArgsForCall, struct {
+		b          *route.Endpoint
+		statusCode int
+	}{b, statusCode})
+	fake.captureRangeRequestMutex.Unlock()
+	if fake.CaptureRangeRequestStub != nil {
+		fake.CaptureRangeRequestStub(b, statusCode)
+	}
+}
+
+func (fake *FakeProxyReporter) CaptureRangeRequestCallCount() int {
+	fake.captureRangeRequestMutex.RLock()
+	defer fake.captureRangeRequestMutex.RUnlock()
+	return len(fake.captureRangeRequestArgsForCall)
+}
+
+func (fake *FakeProxyReporter) CaptureRangeRequestArgsForCall(i int) (*route.Endpoint, int) {
+	fake.captureRangeRequestMutex.RLock()
+	defer fake.captureRangeRequestMutex.RUnlock()
+	return fake.captureRangeRequestArgsForCall[i].b, fake.captureRangeRequestArgsForCall[i].statusCode
+}
+
+func (fake *FakeProxyReporter) CaptureEndpointGroupResponse(b *route.Endpoint, group string, statusCode int, d time.Duration) {
+	fake.captureEndpointGroupResponseMutex.Lock()
+	fake.captureEndpointGroupResponseArgsForCall = append(fake.captureEndpointGroupResponseArgsForCall, struct {
+		b          *route.Endpoint
+		group      string
+		statusCode int
+		d          time.Duration
+	}{b, group, statusCode, d})
+	fake.captureEndpointGroupResponseMutex.Unlock()
+	if fake.CaptureEndpointGroupResponseStub != nil {
+		fake.CaptureEndpointGroupResponseStub(b, group, statusCode, d)
+	}
+}
+
+func (fake *FakeProxyReporter) CaptureEndpointGroupResponseCallCount() int {
+	fake.captureEndpointGroupResponseMutex.RLock()
+	defer fake.captureEndpointGroupResponseMutex.RUnlock()
+	return len(fake.captureEndpointGroupResponseArgsForCall)
+}
+
+func (fake *FakeProxyReporter) CaptureEndpointGroupResponseArgsForCall(i int) (*route.Endpoint, string, int, time.Duration) {
+	fake.captureEndpointGroupResponseMutex.RLock()
+	defer fake.captureEndpointGroupResponseMutex.RUnlock()
+	return fake.captureEndpointGroupResponseArgsForCall[i].b, fake.captureEndpointGroupResponseArgsForCall[i].group, fake.captureEndpointGroupResponseArgsForCall[i].statusCode, fake.captureEndpointGroupResponseArgsForCall[i].d
+}
+
+func (fake *FakeProxyReporter) CaptureRouteServiceDirectResponse(statusCode int) {
+	fake.captureRouteServiceDirectResponseMutex.Lock()
+	fake.captureRouteServiceDirectResponseArgsForCall = append(fake.captureRouteServiceDirectResponseArgsForCall, struct {
+		statusCode int
+	}{statusCode})
+	fake.captureRouteServiceDirectResponseMutex.Unlock()
+	if fake.CaptureRouteServiceDirectResponseStub != nil {
+		fake.CaptureRouteServiceDirectResponseStub(statusCode)
+	}
+}
+
+func (fake *FakeProxyReporter) CaptureRouteServiceDirectResponseCallCount() int {
+	fake.captureRouteServiceDirectResponseMutex.RLock()
+	defer fake.captureRouteServiceDirectResponseMutex.RUnlock()
+	return len(fake.captureRouteServiceDirectResponseArgsForCall)
+}
+
+func (fake *FakeProxyReporter) CaptureRouteServiceDirectResponseArgsForCall(i int) int {
+	fake.captureRouteServiceDirectResponseMutex.RLock()
+	defer fake.captureRouteServiceDirectResponseMutex.RUnlock()
+	return fake.captureRouteServiceDirectResponseArgsForCall[i].statusCode
+}
+
+func (fake *FakeProxyReporter) CaptureMethodNotAllowed() {
+	fake.captureMethodNotAllowedMutex.Lock()
+	fake.captureMethodNotAllowedArgsForCall = append(fake.captureMethodNotAllowedArgsForCall, struct{}{})
+	fake.captureMethodNotAllowedMutex.Unlock()
+	if fake.CaptureMethodNotAllowedStub != nil {
+		fake.CaptureMethodNotAllowedStub()
+	}
+}
+
+func (fake *FakeProxyReporter) CaptureMethodNotAllowedCallCount() int {
+	fake.captureMethodNotAllowedMutex.RLock()
+	defer fake.captureMethodNotAllowedMutex.RUnlock()
+	return len(fake.captureMethodNotAllowedArgsForCall)
+}
+
+func (fake *FakeProxyReporter) CaptureClientWriteStall() {
+	fake.captureClientWriteStallMutex.Lock()
+	fake.captureClientWriteStallArgsForCall = append(fake.captureClientWriteStallArgsForCall, struct{}{})
+	fake.captureClientWriteStallMutex.Unlock()
+	if fake.CaptureClientWriteStallStub != nil {
+		fake.CaptureClientWriteStallStub()
+	}
+}
+
+func (fake *FakeProxyReporter) CaptureClientWriteStallCallCount() int {
+	fake.captureClientWriteStallMutex.RLock()
+	defer fake.captureClientWriteStallMutex.RUnlock()
+	return len(fake.captureClientWriteStallArgsForCall)
+}
+
+func (fake *FakeProxyReporter) CaptureRouterError(kind string) {
+	fake.captureRouterErrorMutex.Lock()
+	fake.captureRouterErrorArgsForCall = append(fake.captureRouterErrorArgsForCall, struct {
+		kind string
+	}{kind})
+	fake.captureRouterErrorMutex.Unlock()
+	if fake.CaptureRouterErrorStub != nil {
+		fake.CaptureRouterErrorStub(kind)
+	}
+}
+
+func (fake *FakeProxyReporter) CaptureRouterErrorCallCount() int {
+	fake.captureRouterErrorMutex.RLock()
+	defer fake.captureRouterErrorMutex.RUnlock()
+	return len(fake.captureRouterErrorArgsForCall)
+}
+
+func (fake *FakeProxyReporter) CaptureRouterErrorArgsForCall(i int) string {
+	fake.captureRouterErrorMutex.RLock()
+	defer fake.captureRouterErrorMutex.RUnlock()
+	return fake.captureRouterErrorArgsForCall[i].kind
+}
+
+func (fake *FakeProxyReporter) CaptureCoalescedRequest() {
+	fake.captureCoalescedRequestMutex.Lock()
+	fake.captureCoalescedRequestArgsForCall = append(fake.captureCoalescedRequestArgsForCall, struct{}{})
+	fake.captureCoalescedRequestMutex.Unlock()
+	if fake.CaptureCoalescedRequestStub != nil {
+		fake.CaptureCoalescedRequestStub()
+	}
+}
+
+func (fake *FakeProxyReporter) CaptureCoalescedRequestCallCount() int {
+	fake.captureCoalescedRequestMutex.RLock()
+	defer fake.captureCoalescedRequestMutex.RUnlock()
+	return len(fake.captureCoalescedRequestArgsForCall)
+}
+
 var _ metrics.ProxyReporter = new(FakeProxyReporter)
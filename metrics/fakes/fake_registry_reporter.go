@@ -30,6 +30,60 @@ type FakeRouteRegistryReporter struct {
 	captureUnregistryMessageArgsForCall []struct {
 		msg metrics.ComponentTagged
 	}
+	CaptureRejectedRegistryMessageStub        func()
+	captureRejectedRegistryMessageMutex       sync.RWMutex
+	captureRejectedRegistryMessageArgsForCall []struct{}
+	CaptureStaleRegistryUpdateStub            func(policy string)
+	captureStaleRegistryUpdateMutex           sync.RWMutex
+	captureStaleRegistryUpdateArgsForCall     []struct {
+		policy string
+	}
+	CaptureRegistryMemoryStub        func(trieNodes int, trieBytes int64, poolBytes int64, endpointBytes int64)
+	captureRegistryMemoryMutex       sync.RWMutex
+	captureRegistryMemoryArgsForCall []struct {
+		trieNodes     int
+		trieBytes     int64
+		poolBytes     int64
+		endpointBytes int64
+	}
+	CaptureRateLimitedRegistryMessageStub        func()
+	captureRateLimitedRegistryMessageMutex       sync.RWMutex
+	captureRateLimitedRegistryMessageArgsForCall []struct{}
+	CaptureRegistrationFloodStub                 func()
+	captureRegistrationFloodMutex                sync.RWMutex
+	captureRegistrationFloodArgsForCall          []struct{}
+	CaptureRegistryBatchStub                     func(routes int)
+	captureRegistryBatchMutex                    sync.RWMutex
+	captureRegistryBatchArgsForCall              []struct {
+		routes int
+	}
+	CaptureEarlyRegistryRefreshStub        func(dropped bool)
+	captureEarlyRegistryRefreshMutex       sync.RWMutex
+	captureEarlyRegistryRefreshArgsForCall []struct {
+		dropped bool
+	}
+	CaptureSourceConflictStub        func(resolution string)
+	captureSourceConflictMutex       sync.RWMutex
+	captureSourceConflictArgsForCall []struct {
+		resolution string
+	}
+	CaptureRouteTableLimitReachedStub        func(limit string)
+	captureRouteTableLimitReachedMutex       sync.RWMutex
+	captureRouteTableLimitReachedArgsForCall []struct {
+		limit string
+	}
+	CapturePruneCycleStub        func(d time.Duration, endpointsPruned, poolsDeleted int)
+	capturePruneCycleMutex       sync.RWMutex
+	capturePruneCycleArgsForCall []struct {
+		d               time.Duration
+		endpointsPruned int
+		poolsDeleted    int
+	}
+	CaptureTimeSinceLastPruneStub        func(d time.Duration)
+	captureTimeSinceLastPruneMutex       sync.RWMutex
+	captureTimeSinceLastPruneArgsForCall []struct {
+		d time.Duration
+	}
 }
 
 func (fake *FakeRouteRegistryReporter) CaptureRouteStats(totalRoutes int, msSinceLastUpdate uint64) {
@@ -125,4 +179,238 @@ func (fake *FakeRouteRegistryReporter) CaptureUnregistryMessageArgsForCall(i int
 	return fake.captureUnregistryMessageArgsForCall[i].msg
 }
 
+func (fake *FakeRouteRegistryReporter) CaptureRejectedRegistryMessage() {
+	fake.captureRejectedRegistryMessageMutex.Lock()
+	fake.captureRejectedRegistryMessageArgsForCall = append(fake.captureRejectedRegistryMessageArgsForCall, struct{}{})
+	fake.captureRejectedRegistryMessageMutex.Unlock()
+	if fake.CaptureRejectedRegistryMessageStub != nil {
+		fake.CaptureRejectedRegistryMessageStub()
+	}
+}
+
+func (fake *FakeRouteRegistryReporter) CaptureRejectedRegistryMessageCallCount() int {
+	fake.captureRejectedRegistryMessageMutex.RLock()
+	defer fake.captureRejectedRegistryMessageMutex.RUnlock()
+	return len(fake.captureRejectedRegistryMessageArgsForCall)
+}
+
+func (fake *FakeRouteRegistryReporter) CaptureStaleRegistryUpdate(policy string) {
+	fake.captureStaleRegistryUpdateMutex.Lock()
+	fake.captureStaleRegistryUpdateArgsForCall = append(fake.captureStaleRegistryUpdateArgsForCall, struct {
+		policy string
+	}{policy})
+	fake.captureStaleRegistryUpdateMutex.Unlock()
+	if fake.CaptureStaleRegistryUpdateStub != nil {
+		fake.CaptureStaleRegistryUpdateStub(policy)
+	}
+}
+
+func (fake *FakeRouteRegistryReporter) CaptureStaleRegistryUpdateCallCount() int {
+	fake.captureStaleRegistryUpdateMutex.RLock()
+	defer fake.captureStaleRegistryUpdateMutex.RUnlock()
+	return len(fake.captureStaleRegistryUpdateArgsForCall)
+}
+
+func (fake *FakeRouteRegistryReporter) CaptureStaleRegistryUpdateArgsForCall(i int) string {
+	fake.captureStaleRegistryUpdateMutex.RLock()
+	defer fake.captureStaleRegistryUpdateMutex.RUnlock()
+	return fake.captureStaleRegistryUpdateArgsForCall[i].policy
+}
+
+func (fake *FakeRouteRegistryReporter) CaptureRegistryMemory(trieNodes int, trieBytes int64, poolBytes int64, endpointBytes int64) {
+	fake.captureRegistryMemoryMutex.Lock()
+	fake.captureRegistryMemoryArgsForCall = append(fake.captureRegistryMemoryArgsForCall, struct {
+		trieNodes     int
+		trieBytes     int64
+		poolBytes     int64
+		endpointBytes int64
+	}{trieNodes, trieBytes, poolBytes, endpointBytes})
+	fake.captureRegistryMemoryMutex.Unlock()
+	if fake.CaptureRegistryMemoryStub != nil {
+		fake.CaptureRegistryMemoryStub(trieNodes, trieBytes, poolBytes, endpointBytes)
+	}
+}
+
+func (fake *FakeRouteRegistryReporter) CaptureRegistryMemoryCallCount() int {
+	fake.captureRegistryMemoryMutex.RLock()
+	defer fake.captureRegistryMemoryMutex.RUnlock()
+	return len(fake.captureRegistryMemoryArgsForCall)
+}
+
+func (fake *FakeRouteRegistryReporter) CaptureRegistryMemoryArgsForCall(i int) (int, int64, int64, int64) {
+	fake.captureRegistryMemoryMutex.RLock()
+	defer fake.captureRegistryMemoryMutex.RUnlock()
+	return fake.captureRegistryMemoryArgsForCall[i].trieNodes, fake.captureRegistryMemoryArgsForCall[i].trieBytes, fake.captureRegistryMemoryArgsForCall[i].poolBytes, fake.captureRegistryMemoryArgsForCall[i].endpointBytes
+}
+
+func (fake *FakeRouteRegistryReporter) CaptureRateLimitedRegistryMessage() {
+	fake.captureRateLimitedRegistryMessageMutex.Lock()
+	fake.captureRateLimitedRegistryMessageArgsForCall = append(fake.captureRateLimitedRegistryMessageArgsForCall, struct{}{})
+	fake.captureRateLimitedRegistryMessageMutex.Unlock()
+	if fake.CaptureRateLimitedRegistryMessageStub != nil {
+		fake.CaptureRateLimitedRegistryMessageStub()
+	}
+}
+
+func (fake *FakeRouteRegistryReporter) CaptureRateLimitedRegistryMessageCallCount() int {
+	fake.captureRateLimitedRegistryMessageMutex.RLock()
+	defer fake.captureRateLimitedRegistryMessageMutex.RUnlock()
+	return len(fake.captureRateLimitedRegistryMessageArgsForCall)
+}
+
+func (fake *FakeRouteRegistryReporter) CaptureRegistrationFlood() {
+	fake.captureRegistrationFloodMutex.Lock()
+	fake.captureRegistrationFloodArgsForCall = append(fake.captureRegistrationFloodArgsForCall, struct{}{})
+	fake.captureRegistrationFloodMutex.Unlock()
+	if fake.CaptureRegistrationFloodStub != nil {
+		fake.CaptureRegistrationFloodStub()
+	}
+}
+
+func (fake *FakeRouteRegistryReporter) CaptureRegistrationFloodCallCount() int {
+	fake.captureRegistrationFloodMutex.RLock()
+	defer fake.captureRegistrationFloodMutex.RUnlock()
+	return len(fake.captureRegistrationFloodArgsForCall)
+}
+
+func (fake *FakeRouteRegistryReporter) CaptureRegistryBatch(routes int) {
+	fake.captureRegistryBatchMutex.Lock()
+	fake.captureRegistryBatchArgsForCall = append(fake.captureRegistryBatchArgsForCall, struct {
+		routes int
+	}{routes})
+	fake.captureRegistryBatchMutex.Unlock()
+	if fake.CaptureRegistryBatchStub != nil {
+		fake.CaptureRegistryBatchStub(routes)
+	}
+}
+
+func (fake *FakeRouteRegistryReporter) CaptureRegistryBatchCallCount() int {
+	fake.captureRegistryBatchMutex.RLock()
+	defer fake.captureRegistryBatchMutex.RUnlock()
+	return len(fake.captureRegistryBatchArgsForCall)
+}
+
+func (fake *FakeRouteRegistryReporter) CaptureRegistryBatchArgsForCall(i int) int {
+	fake.captureRegistryBatchMutex.RLock()
+	defer fake.captureRegistryBatchMutex.RUnlock()
+	return fake.captureRegistryBatchArgsForCall[i].routes
+}
+
+func (fake *FakeRouteRegistryReporter) CaptureEarlyRegistryRefresh(dropped bool) {
+	fake.captureEarlyRegistryRefreshMutex.Lock()
+	fake.captureEarlyRegistryRefreshArgsForCall = append(fake.captureEarlyRegistryRefreshArgsForCall, struct {
+		dropped bool
+	}{dropped})
+	fake.captureEarlyRegistryRefreshMutex.Unlock()
+	if fake.CaptureEarlyRegistryRefreshStub != nil {
+		fake.CaptureEarlyRegistryRefreshStub(dropped)
+	}
+}
+
+func (fake *FakeRouteRegistryReporter) CaptureEarlyRegistryRefreshCallCount() int {
+	fake.captureEarlyRegistryRefreshMutex.RLock()
+	defer fake.captureEarlyRegistryRefreshMutex.RUnlock()
+	return len(fake.captureEarlyRegistryRefreshArgsForCall)
+}
+
+func (fake *FakeRouteRegistryReporter) CaptureEarlyRegistryRefreshArgsForCall(i int) bool {
+	fake.captureEarlyRegistryRefreshMutex.RLock()
+	defer fake.captureEarlyRegistryRefreshMutex.RUnlock()
+	return fake.captureEarlyRegistryRefreshArgsForCall[i].dropped
+}
+
+func (fake *FakeRouteRegistryReporter) CaptureSourceConflict(resolution string) {
+	fake.captureSourceConflictMutex.Lock()
+	fake.captureSourceConflictArgsForCall = append(fake.captureSourceConflictArgsForCall, struct {
+		resolution string
+	}{resolution})
+	fake.captureSourceConflictMutex.Unlock()
+	if fake.CaptureSourceConflictStub != nil {
+		fake.CaptureSourceConflictStub(resolution)
+	}
+}
+
+func (fake *FakeRouteRegistryReporter) CaptureSourceConflictCallCount() int {
+	fake.captureSourceConflictMutex.RLock()
+	defer fake.captureSourceConflictMutex.RUnlock()
+	return len(fake.captureSourceConflictArgsForCall)
+}
+
+func (fake *FakeRouteRegistryReporter) CaptureSourceConflictArgsForCall(i int) string {
+	fake.captureSourceConflictMutex.RLock()
+	defer fake.captureSourceConflictMutex.RUnlock()
+	return fake.captureSourceConflictArgsForCall[i].resolution
+}
+
+func (fake *FakeRouteRegistryReporter) CaptureRouteTableLimitReached(limit string) {
+	fake.captureRouteTableLimitReachedMutex.Lock()
+	fake.captureRouteTableLimitReachedArgsForCall = append(fake.captureRouteTableLimitReachedArgsForCall, struct {
+		limit string
+	}{limit})
+	fake.captureRouteTableLimitReachedMutex.Unlock()
+	if fake.CaptureRouteTableLimitReachedStub != nil {
+		fake.CaptureRouteTableLimitReachedStub(limit)
+	}
+}
+
+func (fake *FakeRouteRegistryReporter) CaptureRouteTableLimitReachedCallCount() int {
+	fake.captureRouteTableLimitReachedMutex.RLock()
+	defer fake.captureRouteTableLimitReachedMutex.RUnlock()
+	return len(fake.captureRouteTableLimitReachedArgsForCall)
+}
+
+func (fake *FakeRouteRegistryReporter) CaptureRouteTableLimitReachedArgsForCall(i int) string {
+	fake.captureRouteTableLimitReachedMutex.RLock()
+	defer fake.captureRouteTableLimitReachedMutex.RUnlock()
+	return fake.captureRouteTableLimitReachedArgsForCall[i].limit
+}
+
+func (fake *FakeRouteRegistryReporter) CapturePruneCycle(d time.Duration, endpointsPruned int, poolsDeleted int) {
+	fake.capturePruneCycleMutex.Lock()
+	fake.capturePruneCycleArgsForCall = append(fake.capturePruneCycleArgsForCall, struct {
+		d               time.Duration
+		endpointsPruned int
+		poolsDeleted    int
+	}{d, endpointsPruned, poolsDeleted})
+	fake.capturePruneCycleMutex.Unlock()
+	if fake.CapturePruneCycleStub != nil {
+		fake.CapturePruneCycleStub(d, endpointsPruned, poolsDeleted)
+	}
+}
+
+func (fake *FakeRouteRegistryReporter) CapturePruneCycleCallCount() int {
+	fake.capturePruneCycleMutex.RLock()
+	defer fake.capturePruneCycleMutex.RUnlock()
+	return len(fake.capturePruneCycleArgsForCall)
+}
+
+func (fake *FakeRouteRegistryReporter) CapturePruneCycleArgsForCall(i int) (time.Duration, int, int) {
+	fake.capturePruneCycleMutex.RLock()
+	defer fake.capturePruneCycleMutex.RUnlock()
+	return fake.capturePruneCycleArgsForCall[i].d, fake.capturePruneCycleArgsForCall[i].endpointsPruned, fake.capturePruneCycleArgsForCall[i].poolsDeleted
+}
+
+func (fake *FakeRouteRegistryReporter) CaptureTimeSinceLastPrune(d time.Duration) {
+	fake.captureTimeSinceLastPruneMutex.Lock()
+	fake.captureTimeSinceLastPruneArgsForCall = append(fake.captureTimeSinceLastPruneArgsForCall, struct {
+		d time.Duration
+	}{d})
+	fake.captureTimeSinceLastPruneMutex.Unlock()
+	if fake.CaptureTimeSinceLastPruneStub != nil {
+		fake.CaptureTimeSinceLastPruneStub(d)
+	}
+}
+
+func (fake *FakeRouteRegistryReporter) CaptureTimeSinceLastPruneCallCount() int {
+	fake.captureTimeSinceLastPruneMutex.RLock()
+	defer fake.captureTimeSinceLastPruneMutex.RUnlock()
+	return len(fake.captureTimeSinceLastPruneArgsForCall)
+}
+
+func (fake *FakeRouteRegistryReporter) CaptureTimeSinceLastPruneArgsForCall(i int) time.Duration {
+	fake.captureTimeSinceLastPruneMutex.RLock()
+	defer fake.captureTimeSinceLastPruneMutex.RUnlock()
+	return fake.captureTimeSinceLastPruneArgsForCall[i].d
+}
+
 var _ metrics.RouteRegistryReporter = new(FakeRouteRegistryReporter)
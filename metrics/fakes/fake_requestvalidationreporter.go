@@ -0,0 +1,43 @@
+// This file was generated by counterfeiter
+package fakes
+
+import (
+	"sync"
+
+	"code.cloudfoundry.org/gorouter/metrics"
+)
+
+type FakeRequestValidationReporter struct {
+	CaptureInvalidRequestStub        func(violation string, rejected bool)
+	captureInvalidRequestMutex       sync.RWMutex
+	captureInvalidRequestArgsForCall []struct {
+		violation string
+		rejected  bool
+	}
+}
+
+func (fake *FakeRequestValidationReporter) CaptureInvalidRequest(violation string, rejected bool) {
+	fake.captureInvalidRequestMutex.Lock()
+	fake.captureInvalidRequestArgsForCall = append(fake.captureInvalidRequestArgsForCall, struct {
+		violation string
+		rejected  bool
+	}{violation, rejected})
+	fake.captureInvalidRequestMutex.Unlock()
+	if fake.CaptureInvalidRequestStub != nil {
+		fake.CaptureInvalidRequestStub(violation, rejected)
+	}
+}
+
+func (fake *FakeRequestValidationReporter) CaptureInvalidRequestCallCount() int {
+	fake.captureInvalidRequestMutex.RLock()
+	defer fake.captureInvalidRequestMutex.RUnlock()
+	return len(fake.captureInvalidRequestArgsForCall)
+}
+
+func (fake *FakeRequestValidationReporter) CaptureInvalidRequestArgsForCall(i int) (string, bool) {
+	fake.captureInvalidRequestMutex.RLock()
+	defer fake.captureInvalidRequestMutex.RUnlock()
+	return fake.captureInvalidRequestArgsForCall[i].violation, fake.captureInvalidRequestArgsForCall[i].rejected
+}
+
+var _ metrics.RequestValidationReporter = new(FakeRequestValidationReporter)
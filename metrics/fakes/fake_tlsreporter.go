@@ -0,0 +1,45 @@
+// This file was generated by counterfeiter
+package fakes
+
+import (
+	"sync"
+
+	"code.cloudfoundry.org/gorouter/metrics"
+)
+
+type FakeTLSReporter struct {
+	CaptureTLSHandshakeStub        func(listener string, version, cipherSuite uint16)
+	captureTLSHandshakeMutex       sync.RWMutex
+	captureTLSHandshakeArgsForCall []struct {
+		listener    string
+		version     uint16
+		cipherSuite uint16
+	}
+}
+
+func (fake *FakeTLSReporter) CaptureTLSHandshake(listener string, version, cipherSuite uint16) {
+	fake.captureTLSHandshakeMutex.Lock()
+	fake.captureTLSHandshakeArgsForCall = append(fake.captureTLSHandshakeArgsForCall, struct {
+		listener    string
+		version     uint16
+		cipherSuite uint16
+	}{listener, version, cipherSuite})
+	fake.captureTLSHandshakeMutex.Unlock()
+	if fake.CaptureTLSHandshakeStub != nil {
+		fake.CaptureTLSHandshakeStub(listener, version, cipherSuite)
+	}
+}
+
+func (fake *FakeTLSReporter) CaptureTLSHandshakeCallCount() int {
+	fake.captureTLSHandshakeMutex.RLock()
+	defer fake.captureTLSHandshakeMutex.RUnlock()
+	return len(fake.captureTLSHandshakeArgsForCall)
+}
+
+func (fake *FakeTLSReporter) CaptureTLSHandshakeArgsForCall(i int) (string, uint16, uint16) {
+	fake.captureTLSHandshakeMutex.RLock()
+	defer fake.captureTLSHandshakeMutex.RUnlock()
+	return fake.captureTLSHandshakeArgsForCall[i].listener, fake.captureTLSHandshakeArgsForCall[i].version, fake.captureTLSHandshakeArgsForCall[i].cipherSuite
+}
+
+var _ metrics.TLSReporter = new(FakeTLSReporter)
@@ -0,0 +1,139 @@
+package metrics
+
+import (
+	"encoding/json"
+	"net/http"
+	"os"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// InFlightReporter receives the total number of requests in flight. The counts
+// per route and per backend endpoint, unbounded in number, are only served
+// over HTTP.
+type InFlightReporter interface {
+	CaptureInFlightRequests(total int64)
+}
+
+// InFlightSnapshot holds the number of requests in flight at one point in time
+type InFlightSnapshot struct {
+	Total     int64            `json:"total"`
+	Routes    map[string]int64 `json:"routes"`
+	Endpoints map[string]int64 `json:"endpoints"`
+}
+
+// InFlightTracker counts the requests in flight through the proxy. Routes and
+// endpoints are only kept while they have requests in flight. A nil
+// *InFlightTracker tracks nothing.
+type InFlightTracker struct {
+	interval  time.Duration
+	reporters []InFlightReporter
+
+	total     int64
+	lock      sync.Mutex
+	routes    map[string]int64
+	endpoints map[string]int64
+}
+
+// NewInFlightTracker returns a tracker that reports to reporters every
+// interval while it runs
+func NewInFlightTracker(interval time.Duration, reporters ...InFlightReporter) *InFlightTracker {
+	return &InFlightTracker{
+		interval:  interval,
+		reporters: reporters,
+		routes:    make(map[string]int64),
+		endpoints: make(map[string]int64),
+	}
+}
+
+// StartRequest counts a request accepted by the proxy until the returned
+// function is called
+func (t *InFlightTracker) StartRequest() func() {
+	if t == nil {
+		return nop
+	}
+	atomic.AddInt64(&t.total, 1)
+	return func() { atomic.AddInt64(&t.total, -1) }
+}
+
+// StartRoute counts a request to route until the returned function is called
+func (t *InFlightTracker) StartRoute(route string) func() {
+	if t == nil {
+		return nop
+	}
+	return t.start(t.routes, route)
+}
+
+// StartEndpoint counts a request to the endpoint at addr until the returned
+// function is called. Calling the function more than once has no effect.
+func (t *InFlightTracker) StartEndpoint(addr string) func() {
+	if t == nil {
+		return nop
+	}
+	return t.start(t.endpoints, addr)
+}
+
+func (t *InFlightTracker) start(counts map[string]int64, key string) func() {
+	t.lock.Lock()
+	counts[key]++
+	t.lock.Unlock()
+
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			t.lock.Lock()
+			defer t.lock.Unlock()
+
+			if counts[key]--; counts[key] <= 0 {
+				delete(counts, key)
+			}
+		})
+	}
+}
+
+// Snapshot returns the requests currently in flight
+func (t *InFlightTracker) Snapshot() InFlightSnapshot {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	return InFlightSnapshot{
+		Total:     atomic.LoadInt64(&t.total),
+		Routes:    copyCounts(t.routes),
+		Endpoints: copyCounts(t.endpoints),
+	}
+}
+
+func (t *InFlightTracker) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(t.Snapshot())
+}
+
+// Run reports the total of requests in flight every interval until signaled
+func (t *InFlightTracker) Run(signals <-chan os.Signal, ready chan<- struct{}) error {
+	ticker := time.NewTicker(t.interval)
+	defer ticker.Stop()
+	close(ready)
+
+	for {
+		select {
+		case <-ticker.C:
+			total := atomic.LoadInt64(&t.total)
+			for _, reporter := range t.reporters {
+				reporter.CaptureInFlightRequests(total)
+			}
+		case <-signals:
+			return nil
+		}
+	}
+}
+
+func nop() {}
+
+func copyCounts(counts map[string]int64) map[string]int64 {
+	c := make(map[string]int64, len(counts))
+	for key, count := range counts {
+		c[key] = count
+	}
+	return c
+}
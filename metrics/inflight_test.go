@@ -0,0 +1,101 @@
+package metrics_test
+
+import (
+	"encoding/json"
+	"net/http/httptest"
+	"os"
+	"sync"
+	"time"
+
+	"code.cloudfoundry.org/gorouter/metrics"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/tedsuo/ifrit"
+)
+
+type fakeInFlightReporter struct {
+	lock   sync.Mutex
+	totals []int64
+}
+
+func (f *fakeInFlightReporter) CaptureInFlightRequests(total int64) {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	f.totals = append(f.totals, total)
+}
+
+func (f *fakeInFlightReporter) Totals() []int64 {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	return append([]int64(nil), f.totals...)
+}
+
+var _ = Describe("InFlightTracker", func() {
+	var tracker *metrics.InFlightTracker
+
+	BeforeEach(func() {
+		tracker = metrics.NewInFlightTracker(time.Second)
+	})
+
+	It("counts requests until they are done", func() {
+		doneRequest := tracker.StartRequest()
+		doneRoute := tracker.StartRoute("app.example.com")
+		tracker.StartRoute("app.example.com")
+		doneEndpoint := tracker.StartEndpoint("10.0.0.1:8080")
+
+		Expect(tracker.Snapshot()).To(Equal(metrics.InFlightSnapshot{
+			Total:     1,
+			Routes:    map[string]int64{"app.example.com": 2},
+			Endpoints: map[string]int64{"10.0.0.1:8080": 1},
+		}))
+
+		doneRequest()
+		doneRoute()
+		doneEndpoint()
+		doneEndpoint()
+
+		Expect(tracker.Snapshot()).To(Equal(metrics.InFlightSnapshot{
+			Total:     0,
+			Routes:    map[string]int64{"app.example.com": 1},
+			Endpoints: map[string]int64{},
+		}))
+	})
+
+	It("tracks nothing when nil", func() {
+		var nilTracker *metrics.InFlightTracker
+		nilTracker.StartRequest()()
+		nilTracker.StartRoute("app.example.com")()
+		nilTracker.StartEndpoint("10.0.0.1:8080")()
+	})
+
+	It("serves the requests in flight as JSON", func() {
+		tracker.StartRoute("app.example.com")
+
+		resp := httptest.NewRecorder()
+		tracker.ServeHTTP(resp, httptest.NewRequest("GET", "/metrics/in_flight", nil))
+
+		var snapshot metrics.InFlightSnapshot
+		Expect(json.Unmarshal(resp.Body.Bytes(), &snapshot)).To(Succeed())
+		Expect(snapshot.Routes).To(Equal(map[string]int64{"app.example.com": 1}))
+	})
+
+	It("reports the total every interval", func() {
+		reporter := &fakeInFlightReporter{}
+		tracker = metrics.NewInFlightTracker(10*time.Millisecond, reporter)
+		done := tracker.StartRequest()
+
+		process := ifrit.Invoke(tracker)
+		defer func() {
+			process.Signal(os.Interrupt)
+			Eventually(process.Wait()).Should(Receive())
+		}()
+
+		Eventually(reporter.Totals).Should(ContainElement(BeEquivalentTo(1)))
+		done()
+		Eventually(func() int64 {
+			totals := reporter.Totals()
+			return totals[len(totals)-1]
+		}).Should(BeEquivalentTo(0))
+	})
+})
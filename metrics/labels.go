@@ -0,0 +1,143 @@
+package metrics
+
+import (
+	"strings"
+	"time"
+)
+
+// Labels are the dimensions of a captured metric, by label name
+type Labels map[string]string
+
+// The labels common to the captures of the router
+const (
+	LabelRoute       = "route"
+	LabelStatusClass = "status_class"
+	LabelAZ          = "az"
+	LabelSource      = "source"
+)
+
+// StatusClass is the value of LabelStatusClass for a status code: 2xx to 5xx,
+// or xxx for any other code
+func StatusClass(statusCode int) string {
+	return getResponseCounterName(statusCode)
+}
+
+// With returns a copy of the labels with name set to value
+func (l Labels) With(name, value string) Labels {
+	labels := make(Labels, len(l)+1)
+	for n, v := range l {
+		labels[n] = v
+	}
+	labels[name] = value
+	return labels
+}
+
+// LabeledReporter is the second version of the reporters. Rather than having
+// a method per metric, its captures name the metric and carry the labels
+// describing it, so that new metrics and dimensions need no interface change.
+//
+//go:generate counterfeiter -o fakes/fake_labeledreporter.go . LabeledReporter
+type LabeledReporter interface {
+	IncrementCounter(name string, labels Labels)
+	SendValue(name string, value float64, unit string, labels Labels)
+	SendDuration(name string, d time.Duration, labels Labels)
+}
+
+// Labeled adapts the reporter to LabeledReporter. Dropsonde metrics carry no
+// labels, so the values of nameLabels, when set, are appended in order to the
+// metric names as the fixed-argument captures do, e.g. responses.2xx for a
+// responses counter labeled with its status class. Other labels are dropped.
+func (m *MetricsReporter) Labeled(nameLabels ...string) LabeledReporter {
+	return &labeledMetricsReporter{
+		reporter:   m,
+		nameLabels: nameLabels,
+	}
+}
+
+type labeledMetricsReporter struct {
+	reporter   *MetricsReporter
+	nameLabels []string
+}
+
+func (r *labeledMetricsReporter) IncrementCounter(name string, labels Labels) {
+	r.reporter.batcher.BatchIncrementCounter(r.metricName(name, labels))
+}
+
+func (r *labeledMetricsReporter) SendValue(name string, value float64, unit string, labels Labels) {
+	r.reporter.sender.SendValue(r.metricName(name, labels), value, unit)
+}
+
+func (r *labeledMetricsReporter) SendDuration(name string, d time.Duration, labels Labels) {
+	r.SendValue(name, float64(d/time.Millisecond), "ms", labels)
+}
+
+// labelValueReplacer keeps label values, such as routes, from adding levels to
+// the dotted metric names
+var labelValueReplacer = strings.NewReplacer(".", "_", "/", "_", " ", "_")
+
+func (r *labeledMetricsReporter) metricName(name string, labels Labels) string {
+	for _, label := range r.nameLabels {
+		if value := labels[label]; value != "" {
+			name += "." + labelValueReplacer.Replace(value)
+		}
+	}
+	return name
+}
+
+// WithLabels returns a reporter adding labels to the captures of reporter,
+// such as the AZ of the router. Labels of the captures take precedence.
+func WithLabels(reporter LabeledReporter, labels Labels) LabeledReporter {
+	return &constantLabelsReporter{
+		reporter: reporter,
+		labels:   labels,
+	}
+}
+
+type constantLabelsReporter struct {
+	reporter LabeledReporter
+	labels   Labels
+}
+
+func (r *constantLabelsReporter) IncrementCounter(name string, labels Labels) {
+	r.reporter.IncrementCounter(name, r.merge(labels))
+}
+
+func (r *constantLabelsReporter) SendValue(name string, value float64, unit string, labels Labels) {
+	r.reporter.SendValue(name, value, unit, r.merge(labels))
+}
+
+func (r *constantLabelsReporter) SendDuration(name string, d time.Duration, labels Labels) {
+	r.reporter.SendDuration(name, d, r.merge(labels))
+}
+
+func (r *constantLabelsReporter) merge(labels Labels) Labels {
+	merged := make(Labels, len(r.labels)+len(labels))
+	for n, v := range r.labels {
+		merged[n] = v
+	}
+	for n, v := range labels {
+		merged[n] = v
+	}
+	return merged
+}
+
+// MultiLabeledReporter hands every capture to each of its reporters
+type MultiLabeledReporter []LabeledReporter
+
+func (m MultiLabeledReporter) IncrementCounter(name string, labels Labels) {
+	for _, r := range m {
+		r.IncrementCounter(name, labels)
+	}
+}
+
+func (m MultiLabeledReporter) SendValue(name string, value float64, unit string, labels Labels) {
+	for _, r := range m {
+		r.SendValue(name, value, unit, labels)
+	}
+}
+
+func (m MultiLabeledReporter) SendDuration(name string, d time.Duration, labels Labels) {
+	for _, r := range m {
+		r.SendDuration(name, d, labels)
+	}
+}
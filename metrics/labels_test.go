@@ -0,0 +1,92 @@
+package metrics_test
+
+import (
+	"net/http"
+	"time"
+
+	"code.cloudfoundry.org/gorouter/metrics"
+	"code.cloudfoundry.org/gorouter/metrics/fakes"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Labels", func() {
+	It("copies the labels to set another", func() {
+		labels := metrics.Labels{metrics.LabelSource: "nats"}
+		with := labels.With(metrics.LabelStatusClass, metrics.StatusClass(http.StatusNotFound))
+
+		Expect(with).To(Equal(metrics.Labels{metrics.LabelSource: "nats", metrics.LabelStatusClass: "4xx"}))
+		Expect(labels).To(HaveLen(1))
+	})
+
+	Describe("MetricsReporter.Labeled", func() {
+		var (
+			sender   *fakes.MetricSender
+			batcher  *fakes.MetricBatcher
+			reporter metrics.LabeledReporter
+		)
+
+		BeforeEach(func() {
+			sender = new(fakes.MetricSender)
+			batcher = new(fakes.MetricBatcher)
+			reporter = metrics.NewMetricsReporter(sender, batcher).Labeled(metrics.LabelRoute, metrics.LabelStatusClass)
+		})
+
+		It("appends the values of the name labels to counter names", func() {
+			reporter.IncrementCounter("responses", metrics.Labels{
+				metrics.LabelRoute:       "app.example.com/api",
+				metrics.LabelStatusClass: "2xx",
+				metrics.LabelAZ:          "z1",
+			})
+
+			Expect(batcher.BatchIncrementCounterCallCount()).To(Equal(1))
+			Expect(batcher.BatchIncrementCounterArgsForCall(0)).To(Equal("responses.app_example_com_api.2xx"))
+		})
+
+		It("skips the name labels that are not set", func() {
+			reporter.IncrementCounter("responses", metrics.Labels{metrics.LabelStatusClass: "5xx"})
+
+			Expect(batcher.BatchIncrementCounterArgsForCall(0)).To(Equal("responses.5xx"))
+		})
+
+		It("sends values and durations", func() {
+			reporter.SendValue("queue_depth", 3, "requests", nil)
+			reporter.SendDuration("latency", 1500*time.Microsecond, metrics.Labels{metrics.LabelStatusClass: "2xx"})
+
+			Expect(sender.SendValueCallCount()).To(Equal(2))
+			name, value, unit := sender.SendValueArgsForCall(0)
+			Expect(name).To(Equal("queue_depth"))
+			Expect(value).To(BeEquivalentTo(3))
+			Expect(unit).To(Equal("requests"))
+
+			name, value, unit = sender.SendValueArgsForCall(1)
+			Expect(name).To(Equal("latency.2xx"))
+			Expect(value).To(BeEquivalentTo(1))
+			Expect(unit).To(Equal("ms"))
+		})
+	})
+
+	Describe("WithLabels", func() {
+		It("adds its labels to the captures, which take precedence", func() {
+			fake := new(fakes.FakeLabeledReporter)
+			reporter := metrics.WithLabels(fake, metrics.Labels{metrics.LabelAZ: "z1", metrics.LabelSource: "nats"})
+
+			reporter.IncrementCounter("responses", metrics.Labels{metrics.LabelSource: "routing-api"})
+
+			name, labels := fake.IncrementCounterArgsForCall(0)
+			Expect(name).To(Equal("responses"))
+			Expect(labels).To(Equal(metrics.Labels{metrics.LabelAZ: "z1", metrics.LabelSource: "routing-api"}))
+		})
+	})
+
+	It("fans captures out to every reporter of a MultiLabeledReporter", func() {
+		first, second := new(fakes.FakeLabeledReporter), new(fakes.FakeLabeledReporter)
+		reporter := metrics.MultiLabeledReporter{first, second}
+
+		reporter.SendDuration("latency", time.Second, nil)
+
+		Expect(first.SendDurationCallCount()).To(Equal(1))
+		Expect(second.SendDurationCallCount()).To(Equal(1))
+	})
+})
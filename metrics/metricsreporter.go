@@ -1,6 +1,7 @@
 package metrics
 
 import (
+	"crypto/tls"
 	"fmt"
 	"net/http"
 	"strings"
@@ -51,6 +52,13 @@ func (m *MetricsReporter) CaptureRouteServiceResponse(res *http.Response) {
 	m.batcher.BatchIncrementCounter("responses.route_services")
 }
 
+// CaptureRouteServiceDirectResponse counts responses a route service sent
+// itself, without forwarding the request to the route's backends
+func (m *MetricsReporter) CaptureRouteServiceDirectResponse(statusCode int) {
+	m.batcher.BatchIncrementCounter(fmt.Sprintf("responses.route_services.direct.%s", getResponseCounterName(statusCode)))
+	m.batcher.BatchIncrementCounter("responses.route_services.direct")
+}
+
 func (m *MetricsReporter) CaptureRoutingResponse(statusCode int) {
 	m.batcher.BatchIncrementCounter(fmt.Sprintf("responses.%s", getResponseCounterName(statusCode)))
 	m.batcher.BatchIncrementCounter("responses")
@@ -77,6 +85,14 @@ func (m *MetricsReporter) CaptureRouteStats(totalRoutes int, msSinceLastUpdate u
 	m.sender.SendValue("ms_since_last_registry_update", float64(msSinceLastUpdate), "ms")
 }
 
+func (m *MetricsReporter) CaptureRegistryMemory(trieNodes int, trieBytes, poolBytes, endpointBytes int64) {
+	m.sender.SendValue("registry_memory.trie_nodes", float64(trieNodes), "")
+	m.sender.SendValue("registry_memory.trie", float64(trieBytes), "bytes")
+	m.sender.SendValue("registry_memory.pools", float64(poolBytes), "bytes")
+	m.sender.SendValue("registry_memory.endpoints", float64(endpointBytes), "bytes")
+	m.sender.SendValue("registry_memory.total", float64(trieBytes+poolBytes+endpointBytes), "bytes")
+}
+
 func (m *MetricsReporter) CaptureRegistryMessage(msg ComponentTagged) {
 	var componentName string
 	if msg.Component() == "" {
@@ -97,6 +113,71 @@ func (m *MetricsReporter) CaptureUnregistryMessage(msg ComponentTagged) {
 	m.sender.IncrementCounter(componentName)
 }
 
+func (m *MetricsReporter) CaptureRejectedRegistryMessage() {
+	m.sender.IncrementCounter("rejected_registry_messages")
+}
+
+// CaptureRateLimitedRegistryMessage counts registrations dropped because
+// their source exceeded its rate
+func (m *MetricsReporter) CaptureRateLimitedRegistryMessage() {
+	m.sender.IncrementCounter("rate_limited_registry_messages")
+}
+
+// CaptureRegistrationFlood counts the seconds in which a source started
+// exceeding its registration rate
+func (m *MetricsReporter) CaptureRegistrationFlood() {
+	m.sender.IncrementCounter("registration_floods")
+}
+
+// CaptureRegistryBatch counts a batch registration message and the routes
+// it registered
+func (m *MetricsReporter) CaptureRegistryBatch(routes int) {
+	m.sender.IncrementCounter("registry_batch_messages")
+	m.sender.AddToCounter("registry_message", uint64(routes))
+}
+
+// CaptureEarlyRegistryRefresh counts registrations refreshing an endpoint
+// sooner than the minimum register interval allows, and those of them dropped
+func (m *MetricsReporter) CaptureEarlyRegistryRefresh(dropped bool) {
+	m.sender.IncrementCounter("early_registry_refreshes")
+	if dropped {
+		m.sender.IncrementCounter("early_registry_refreshes.dropped")
+	}
+}
+
+// CaptureSourceConflict counts registrations for routes holding endpoints of
+// other sources, by how they were resolved
+func (m *MetricsReporter) CaptureSourceConflict(resolution string) {
+	m.sender.IncrementCounter("route_source_conflicts")
+	m.sender.IncrementCounter("route_source_conflicts." + resolution)
+}
+
+// CaptureRouteTableLimitReached counts registrations rejected by a limit of
+// the routing table
+func (m *MetricsReporter) CaptureRouteTableLimitReached(limit string) {
+	m.sender.IncrementCounter("route_table_limit_rejections")
+	m.sender.IncrementCounter("route_table_limit_rejections." + limit)
+}
+
+// CapturePruneCycle reports how long a cycle pruning stale endpoints held the
+// routing table locked and what it removed
+func (m *MetricsReporter) CapturePruneCycle(d time.Duration, endpointsPruned, poolsDeleted int) {
+	m.sender.SendValue("prune_cycle.duration", float64(d)/float64(time.Millisecond), "ms")
+	m.sender.SendValue("prune_cycle.endpoints_pruned", float64(endpointsPruned), "")
+	m.sender.SendValue("prune_cycle.pools_deleted", float64(poolsDeleted), "")
+}
+
+// CaptureTimeSinceLastPrune reports the time since stale endpoints were last
+// pruned, which grows while pruning is suspended
+func (m *MetricsReporter) CaptureTimeSinceLastPrune(d time.Duration) {
+	m.sender.SendValue("ms_since_last_prune", float64(d/time.Millisecond), "ms")
+}
+
+func (m *MetricsReporter) CaptureStaleRegistryUpdate(policy string) {
+	m.sender.IncrementCounter("stale_registry_updates")
+	m.sender.IncrementCounter("stale_registry_updates." + policy)
+}
+
 func (m *MetricsReporter) CaptureWebSocketUpdate() {
 	m.batcher.BatchIncrementCounter("websocket_upgrades")
 }
@@ -105,6 +186,178 @@ func (m *MetricsReporter) CaptureWebSocketFailure() {
 	m.batcher.BatchIncrementCounter("websocket_failures")
 }
 
+func (m *MetricsReporter) CaptureInformationalResponse(statusCode int) {
+	m.batcher.BatchIncrementCounter(fmt.Sprintf("responses.informational.%d", statusCode))
+	m.batcher.BatchIncrementCounter("responses.informational")
+}
+
+// CaptureMethodNotAllowed counts requests rejected for a method their route
+// does not allow
+func (m *MetricsReporter) CaptureMethodNotAllowed() {
+	m.batcher.BatchIncrementCounter("method_not_allowed_requests")
+}
+
+// CaptureClientWriteStall counts responses abandoned because the client stopped
+// reading them
+func (m *MetricsReporter) CaptureClientWriteStall() {
+	m.batcher.BatchIncrementCounter("client_write_stalls")
+}
+
+// CaptureRouterError counts the requests failed by kind of routing error
+func (m *MetricsReporter) CaptureRouterError(kind string) {
+	m.batcher.BatchIncrementCounter(fmt.Sprintf("router_errors.%s", kind))
+}
+
+// CaptureRangeRequest counts requests carrying a Range header and, of those,
+// the ones answered with 206 Partial Content.
+func (m *MetricsReporter) CaptureRangeRequest(b *route.Endpoint, statusCode int) {
+	componentName := b.Tags["component"]
+
+	m.batcher.BatchIncrementCounter("range_requests")
+	if componentName != "" {
+		m.batcher.BatchIncrementCounter(fmt.Sprintf("range_requests.%s", componentName))
+	}
+
+	if statusCode == http.StatusPartialContent {
+		m.batcher.BatchIncrementCounter("partial_responses")
+		if componentName != "" {
+			m.batcher.BatchIncrementCounter(fmt.Sprintf("partial_responses.%s", componentName))
+		}
+	}
+}
+
+// CaptureEndpointGroupResponse emits status class counters and latency for
+// one endpoint group of an application, e.g. its canary or stable instances.
+func (m *MetricsReporter) CaptureEndpointGroupResponse(b *route.Endpoint, group string, statusCode int, d time.Duration) {
+	prefix := fmt.Sprintf("endpoint_groups.%s.%s", b.ApplicationId, group)
+	if b.ApplicationId == "" {
+		prefix = fmt.Sprintf("endpoint_groups.%s", group)
+	}
+
+	m.batcher.BatchIncrementCounter(fmt.Sprintf("%s.responses.%s", prefix, getResponseCounterName(statusCode)))
+	m.batcher.BatchIncrementCounter(prefix + ".responses")
+	m.sender.SendValue(prefix+".latency", float64(d/time.Millisecond), "ms")
+}
+
+// CapturePluginCall counts calls to an external plugin and emits their latency
+func (m *MetricsReporter) CapturePluginCall(name string, d time.Duration) {
+	m.batcher.BatchIncrementCounter(fmt.Sprintf("plugins.%s.calls", name))
+	m.sender.SendValue(fmt.Sprintf("plugins.%s.latency", name), float64(d/time.Millisecond), "ms")
+}
+
+// CapturePluginFailure counts calls to an external plugin that failed or timed
+// out, whatever the failure policy did with the request.
+func (m *MetricsReporter) CapturePluginFailure(name string) {
+	m.batcher.BatchIncrementCounter(fmt.Sprintf("plugins.%s.failures", name))
+}
+
+// CapturePluginHealth emits 1 while an external plugin answers health checks
+// and 0 otherwise.
+func (m *MetricsReporter) CapturePluginHealth(name string, healthy bool) {
+	var value float64
+	if healthy {
+		value = 1
+	}
+	m.sender.SendValue(fmt.Sprintf("plugins.%s.healthy", name), value, "")
+}
+
+// CaptureRouteErrorAnomaly counts windows in which a route's 5xx rate spiked
+// beyond its baseline
+func (m *MetricsReporter) CaptureRouteErrorAnomaly() {
+	m.batcher.BatchIncrementCounter("route_error_anomalies")
+}
+
+// CaptureAuthFailureThresholdExceeded counts windows in which a route's
+// backends answered the auth failure threshold of requests with 401 or 403
+func (m *MetricsReporter) CaptureAuthFailureThresholdExceeded() {
+	m.batcher.BatchIncrementCounter("auth_failure_threshold_exceeded")
+}
+
+// CaptureConcurrencyLimited counts requests rejected because their route was
+// at its adaptive concurrency limit
+func (m *MetricsReporter) CaptureConcurrencyLimited() {
+	m.batcher.BatchIncrementCounter("shed_requests.concurrency_limit")
+}
+
+// CaptureCoalescedRequest counts requests served the response of an identical
+// request in flight instead of reaching a backend
+func (m *MetricsReporter) CaptureCoalescedRequest() {
+	m.batcher.BatchIncrementCounter("coalesced_requests")
+}
+
+// CaptureOCSPStapleValidity emits the seconds until the stapled OCSP response
+// expires, or 0 while none is stapled
+func (m *MetricsReporter) CaptureOCSPStapleValidity(remaining time.Duration) {
+	m.sender.SendValue("ocsp.staple_remaining_validity", remaining.Seconds(), "s")
+}
+
+// CaptureOCSPFetchFailure counts OCSP responses that could not be fetched or
+// were rejected
+func (m *MetricsReporter) CaptureOCSPFetchFailure() {
+	m.batcher.BatchIncrementCounter("ocsp.fetch_failures")
+}
+
+// CaptureTLSHandshake counts the TLS versions and cipher suites clients of a
+// listener negotiate, to tell when an old one can be disabled
+func (m *MetricsReporter) CaptureTLSHandshake(listener string, version, cipherSuite uint16) {
+	m.batcher.BatchIncrementCounter(fmt.Sprintf("tls.%s.versions.%s", listener, tlsVersionName(version)))
+	m.batcher.BatchIncrementCounter(fmt.Sprintf("tls.%s.cipher_suites.%s", listener, tls.CipherSuiteName(cipherSuite)))
+}
+
+// CaptureInvalidRequest counts requests whose framing violated HTTP/1.1, by
+// violation, and those of them that were rejected
+func (m *MetricsReporter) CaptureInvalidRequest(violation string, rejected bool) {
+	m.batcher.BatchIncrementCounter("invalid_requests." + violation)
+	if rejected {
+		m.batcher.BatchIncrementCounter("invalid_requests.rejected")
+	}
+}
+
+// CaptureConnectionLimited counts connections turned away because their client
+// IP had too many, by policy
+func (m *MetricsReporter) CaptureConnectionLimited(policy string) {
+	m.batcher.BatchIncrementCounter("connections_limited." + policy)
+}
+
+// CaptureIngressRejected counts the conns of listener rejected for not
+// arriving through a trusted load balancer, by reason
+func (m *MetricsReporter) CaptureIngressRejected(listener, reason string) {
+	m.batcher.BatchIncrementCounter("ingress_rejected." + listener + "." + reason)
+}
+
+// CaptureAccept counts the conns accepted by listener
+func (m *MetricsReporter) CaptureAccept(listener string) {
+	m.batcher.BatchIncrementCounter("accept." + listener + ".accepted")
+}
+
+// CaptureAcceptError counts the errors accepting conns on listener, and those
+// of them that were temporary
+func (m *MetricsReporter) CaptureAcceptError(listener string, temporary bool) {
+	m.batcher.BatchIncrementCounter("accept." + listener + ".errors")
+	if temporary {
+		m.batcher.BatchIncrementCounter("accept." + listener + ".temporary_errors")
+	}
+}
+
+// CaptureInFlightRequests emits the total of requests in flight
+func (m *MetricsReporter) CaptureInFlightRequests(total int64) {
+	m.sender.SendValue("in_flight_requests", float64(total), "")
+}
+
+func tlsVersionName(version uint16) string {
+	switch version {
+	case tls.VersionTLS10:
+		return "tls1_0"
+	case tls.VersionTLS11:
+		return "tls1_1"
+	case tls.VersionTLS12:
+		return "tls1_2"
+	case tls.VersionTLS13:
+		return "tls1_3"
+	}
+	return fmt.Sprintf("0x%04x", version)
+}
+
 func getResponseCounterName(statusCode int) string {
 	statusCode = statusCode / 100
 	if statusCode >= 2 && statusCode <= 5 {
@@ -1,6 +1,7 @@
 package metrics_test
 
 import (
+	"crypto/tls"
 	"net/http"
 	"time"
 
@@ -206,6 +207,13 @@ var _ = Describe("MetricsReporter", func() {
 			Expect(batcher.BatchIncrementCounterCallCount()).To(Equal(4))
 			Expect(batcher.BatchIncrementCounterArgsForCall(3)).To(Equal("responses.route_services"))
 		})
+
+		It("counts direct responses separately", func() {
+			metricReporter.CaptureRouteServiceDirectResponse(403)
+			Expect(batcher.BatchIncrementCounterCallCount()).To(Equal(2))
+			Expect(batcher.BatchIncrementCounterArgsForCall(0)).To(Equal("responses.route_services.direct.4xx"))
+			Expect(batcher.BatchIncrementCounterArgsForCall(1)).To(Equal("responses.route_services.direct"))
+		})
 	})
 
 	Context("increments the response metrics", func() {
@@ -349,6 +357,21 @@ var _ = Describe("MetricsReporter", func() {
 			Expect(unit).To(Equal("ms"))
 		})
 
+		It("sends the registry memory estimate", func() {
+			metricReporter.CaptureRegistryMemory(3, 100, 200, 300)
+
+			Expect(sender.SendValueCallCount()).To(Equal(5))
+			name, value, unit := sender.SendValueArgsForCall(0)
+			Expect(name).To(Equal("registry_memory.trie_nodes"))
+			Expect(value).To(BeEquivalentTo(3))
+			Expect(unit).To(Equal(""))
+
+			name, value, unit = sender.SendValueArgsForCall(4)
+			Expect(name).To(Equal("registry_memory.total"))
+			Expect(value).To(BeEquivalentTo(600))
+			Expect(unit).To(Equal("bytes"))
+		})
+
 		It("sends the lookup time for routing table", func() {
 			metricReporter.CaptureLookupTime(time.Duration(9) * time.Second)
 
@@ -397,6 +420,100 @@ var _ = Describe("MetricsReporter", func() {
 		})
 	})
 
+	It("increments the rejected registry message counter", func() {
+		metricReporter.CaptureRejectedRegistryMessage()
+
+		Expect(sender.IncrementCounterCallCount()).To(Equal(1))
+		Expect(sender.IncrementCounterArgsForCall(0)).To(Equal("rejected_registry_messages"))
+	})
+
+	It("increments the rate limited registry message counter", func() {
+		metricReporter.CaptureRateLimitedRegistryMessage()
+
+		Expect(sender.IncrementCounterCallCount()).To(Equal(1))
+		Expect(sender.IncrementCounterArgsForCall(0)).To(Equal("rate_limited_registry_messages"))
+	})
+
+	It("increments the registration flood counter", func() {
+		metricReporter.CaptureRegistrationFlood()
+
+		Expect(sender.IncrementCounterCallCount()).To(Equal(1))
+		Expect(sender.IncrementCounterArgsForCall(0)).To(Equal("registration_floods"))
+	})
+
+	It("counts registry batch messages and the routes they register", func() {
+		metricReporter.CaptureRegistryBatch(3)
+
+		Expect(sender.IncrementCounterCallCount()).To(Equal(1))
+		Expect(sender.IncrementCounterArgsForCall(0)).To(Equal("registry_batch_messages"))
+		Expect(sender.AddToCounterCallCount()).To(Equal(1))
+		name, delta := sender.AddToCounterArgsForCall(0)
+		Expect(name).To(Equal("registry_message"))
+		Expect(delta).To(BeEquivalentTo(3))
+	})
+
+	It("increments the early registry refresh counters", func() {
+		metricReporter.CaptureEarlyRegistryRefresh(false)
+		metricReporter.CaptureEarlyRegistryRefresh(true)
+
+		Expect(sender.IncrementCounterCallCount()).To(Equal(3))
+		Expect(sender.IncrementCounterArgsForCall(0)).To(Equal("early_registry_refreshes"))
+		Expect(sender.IncrementCounterArgsForCall(1)).To(Equal("early_registry_refreshes"))
+		Expect(sender.IncrementCounterArgsForCall(2)).To(Equal("early_registry_refreshes.dropped"))
+	})
+
+	It("increments the route source conflict counters", func() {
+		metricReporter.CaptureSourceConflict("rejected")
+
+		Expect(sender.IncrementCounterCallCount()).To(Equal(2))
+		Expect(sender.IncrementCounterArgsForCall(0)).To(Equal("route_source_conflicts"))
+		Expect(sender.IncrementCounterArgsForCall(1)).To(Equal("route_source_conflicts.rejected"))
+	})
+
+	It("increments the route table limit rejection counters", func() {
+		metricReporter.CaptureRouteTableLimitReached("uris")
+
+		Expect(sender.IncrementCounterCallCount()).To(Equal(2))
+		Expect(sender.IncrementCounterArgsForCall(0)).To(Equal("route_table_limit_rejections"))
+		Expect(sender.IncrementCounterArgsForCall(1)).To(Equal("route_table_limit_rejections.uris"))
+	})
+
+	It("sends the prune cycle metrics", func() {
+		metricReporter.CapturePruneCycle(1500*time.Microsecond, 7, 2)
+
+		Expect(sender.SendValueCallCount()).To(Equal(3))
+		name, value, unit := sender.SendValueArgsForCall(0)
+		Expect(name).To(Equal("prune_cycle.duration"))
+		Expect(value).To(BeEquivalentTo(1.5))
+		Expect(unit).To(Equal("ms"))
+
+		name, value, _ = sender.SendValueArgsForCall(1)
+		Expect(name).To(Equal("prune_cycle.endpoints_pruned"))
+		Expect(value).To(BeEquivalentTo(7))
+
+		name, value, _ = sender.SendValueArgsForCall(2)
+		Expect(name).To(Equal("prune_cycle.pools_deleted"))
+		Expect(value).To(BeEquivalentTo(2))
+	})
+
+	It("sends the time since the last prune", func() {
+		metricReporter.CaptureTimeSinceLastPrune(3 * time.Second)
+
+		Expect(sender.SendValueCallCount()).To(Equal(1))
+		name, value, unit := sender.SendValueArgsForCall(0)
+		Expect(name).To(Equal("ms_since_last_prune"))
+		Expect(value).To(BeEquivalentTo(3000))
+		Expect(unit).To(Equal("ms"))
+	})
+
+	It("increments the stale registry update counters", func() {
+		metricReporter.CaptureStaleRegistryUpdate("drop")
+
+		Expect(sender.IncrementCounterCallCount()).To(Equal(2))
+		Expect(sender.IncrementCounterArgsForCall(0)).To(Equal("stale_registry_updates"))
+		Expect(sender.IncrementCounterArgsForCall(1)).To(Equal("stale_registry_updates.drop"))
+	})
+
 	Context("websocket metrics", func() {
 		It("increments the total responses metric", func() {
 			metricReporter.CaptureWebSocketUpdate()
@@ -410,4 +527,231 @@ var _ = Describe("MetricsReporter", func() {
 		})
 	})
 
+	Context("informational responses", func() {
+		It("increments the informational response metrics", func() {
+			metricReporter.CaptureInformationalResponse(http.StatusEarlyHints)
+			Expect(batcher.BatchIncrementCounterCallCount()).To(Equal(2))
+			Expect(batcher.BatchIncrementCounterArgsForCall(0)).To(Equal("responses.informational.103"))
+			Expect(batcher.BatchIncrementCounterArgsForCall(1)).To(Equal("responses.informational"))
+		})
+	})
+
+	Context("range requests", func() {
+		It("increments the range request metric", func() {
+			metricReporter.CaptureRangeRequest(endpoint, http.StatusOK)
+			Expect(batcher.BatchIncrementCounterCallCount()).To(Equal(1))
+			Expect(batcher.BatchIncrementCounterArgsForCall(0)).To(Equal("range_requests"))
+		})
+
+		It("increments the partial response metric for 206 responses", func() {
+			metricReporter.CaptureRangeRequest(endpoint, http.StatusPartialContent)
+			Expect(batcher.BatchIncrementCounterCallCount()).To(Equal(2))
+			Expect(batcher.BatchIncrementCounterArgsForCall(0)).To(Equal("range_requests"))
+			Expect(batcher.BatchIncrementCounterArgsForCall(1)).To(Equal("partial_responses"))
+		})
+
+		It("increments the component metrics when the endpoint has a component tag", func() {
+			endpoint.Tags = map[string]string{"component": "CloudController"}
+			metricReporter.CaptureRangeRequest(endpoint, http.StatusPartialContent)
+			Expect(batcher.BatchIncrementCounterCallCount()).To(Equal(4))
+			Expect(batcher.BatchIncrementCounterArgsForCall(1)).To(Equal("range_requests.CloudController"))
+			Expect(batcher.BatchIncrementCounterArgsForCall(3)).To(Equal("partial_responses.CloudController"))
+		})
+	})
+
+	It("counts requests with methods their route does not allow", func() {
+		metricReporter.CaptureMethodNotAllowed()
+
+		Expect(batcher.BatchIncrementCounterCallCount()).To(Equal(1))
+		Expect(batcher.BatchIncrementCounterArgsForCall(0)).To(Equal("method_not_allowed_requests"))
+	})
+
+	It("counts responses abandoned for stalled clients", func() {
+		metricReporter.CaptureClientWriteStall()
+
+		Expect(batcher.BatchIncrementCounterCallCount()).To(Equal(1))
+		Expect(batcher.BatchIncrementCounterArgsForCall(0)).To(Equal("client_write_stalls"))
+	})
+
+	It("counts failed requests by kind of router error", func() {
+		metricReporter.CaptureRouterError("dial_failed")
+
+		Expect(batcher.BatchIncrementCounterCallCount()).To(Equal(1))
+		Expect(batcher.BatchIncrementCounterArgsForCall(0)).To(Equal("router_errors.dial_failed"))
+	})
+
+	Context("endpoint group responses", func() {
+		It("emits status class counters and latency for the group of the application", func() {
+			metricReporter.CaptureEndpointGroupResponse(endpoint, "canary", http.StatusBadGateway, 25*time.Millisecond)
+
+			Expect(batcher.BatchIncrementCounterCallCount()).To(Equal(2))
+			Expect(batcher.BatchIncrementCounterArgsForCall(0)).To(Equal("endpoint_groups.someId.canary.responses.5xx"))
+			Expect(batcher.BatchIncrementCounterArgsForCall(1)).To(Equal("endpoint_groups.someId.canary.responses"))
+
+			Expect(sender.SendValueCallCount()).To(Equal(1))
+			name, value, unit := sender.SendValueArgsForCall(0)
+			Expect(name).To(Equal("endpoint_groups.someId.canary.latency"))
+			Expect(value).To(BeEquivalentTo(25))
+			Expect(unit).To(Equal("ms"))
+		})
+
+		It("omits the application when the endpoint has none", func() {
+			endpoint.ApplicationId = ""
+			metricReporter.CaptureEndpointGroupResponse(endpoint, "stable", http.StatusOK, time.Millisecond)
+
+			Expect(batcher.BatchIncrementCounterArgsForCall(0)).To(Equal("endpoint_groups.stable.responses.2xx"))
+		})
+	})
+
+	It("emits the total of requests in flight", func() {
+		metricReporter.CaptureInFlightRequests(3)
+
+		Expect(sender.SendValueCallCount()).To(Equal(1))
+		name, value, _ := sender.SendValueArgsForCall(0)
+		Expect(name).To(Equal("in_flight_requests"))
+		Expect(value).To(BeEquivalentTo(3))
+	})
+
+	It("counts route error anomalies", func() {
+		metricReporter.CaptureRouteErrorAnomaly()
+
+		Expect(batcher.BatchIncrementCounterCallCount()).To(Equal(1))
+		Expect(batcher.BatchIncrementCounterArgsForCall(0)).To(Equal("route_error_anomalies"))
+	})
+
+	It("counts routes exceeding the auth failure threshold", func() {
+		metricReporter.CaptureAuthFailureThresholdExceeded()
+
+		Expect(batcher.BatchIncrementCounterCallCount()).To(Equal(1))
+		Expect(batcher.BatchIncrementCounterArgsForCall(0)).To(Equal("auth_failure_threshold_exceeded"))
+	})
+
+	It("counts requests rejected by the concurrency limit", func() {
+		metricReporter.CaptureConcurrencyLimited()
+
+		Expect(batcher.BatchIncrementCounterCallCount()).To(Equal(1))
+		Expect(batcher.BatchIncrementCounterArgsForCall(0)).To(Equal("shed_requests.concurrency_limit"))
+	})
+
+	It("counts coalesced requests", func() {
+		metricReporter.CaptureCoalescedRequest()
+
+		Expect(batcher.BatchIncrementCounterCallCount()).To(Equal(1))
+		Expect(batcher.BatchIncrementCounterArgsForCall(0)).To(Equal("coalesced_requests"))
+	})
+
+	Context("external plugins", func() {
+		It("counts calls and emits their latency", func() {
+			metricReporter.CapturePluginCall("waf", 3*time.Millisecond)
+
+			Expect(batcher.BatchIncrementCounterCallCount()).To(Equal(1))
+			Expect(batcher.BatchIncrementCounterArgsForCall(0)).To(Equal("plugins.waf.calls"))
+
+			Expect(sender.SendValueCallCount()).To(Equal(1))
+			name, value, unit := sender.SendValueArgsForCall(0)
+			Expect(name).To(Equal("plugins.waf.latency"))
+			Expect(value).To(BeEquivalentTo(3))
+			Expect(unit).To(Equal("ms"))
+		})
+
+		It("counts failures", func() {
+			metricReporter.CapturePluginFailure("waf")
+
+			Expect(batcher.BatchIncrementCounterCallCount()).To(Equal(1))
+			Expect(batcher.BatchIncrementCounterArgsForCall(0)).To(Equal("plugins.waf.failures"))
+		})
+
+		It("emits the health of the plugin", func() {
+			metricReporter.CapturePluginHealth("waf", true)
+			metricReporter.CapturePluginHealth("waf", false)
+
+			Expect(sender.SendValueCallCount()).To(Equal(2))
+			name, value, _ := sender.SendValueArgsForCall(0)
+			Expect(name).To(Equal("plugins.waf.healthy"))
+			Expect(value).To(BeEquivalentTo(1))
+			_, value, _ = sender.SendValueArgsForCall(1)
+			Expect(value).To(BeEquivalentTo(0))
+		})
+	})
+
+	It("counts the negotiated TLS versions and cipher suites", func() {
+		metricReporter.CaptureTLSHandshake("frontend", tls.VersionTLS12, tls.TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256)
+
+		Expect(batcher.BatchIncrementCounterCallCount()).To(Equal(2))
+		Expect(batcher.BatchIncrementCounterArgsForCall(0)).To(Equal("tls.frontend.versions.tls1_2"))
+		Expect(batcher.BatchIncrementCounterArgsForCall(1)).To(Equal("tls.frontend.cipher_suites.TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256"))
+	})
+
+	Context("ocsp stapling", func() {
+		It("emits the remaining validity of the staple", func() {
+			metricReporter.CaptureOCSPStapleValidity(90 * time.Minute)
+
+			Expect(sender.SendValueCallCount()).To(Equal(1))
+			name, value, unit := sender.SendValueArgsForCall(0)
+			Expect(name).To(Equal("ocsp.staple_remaining_validity"))
+			Expect(value).To(BeEquivalentTo(5400))
+			Expect(unit).To(Equal("s"))
+		})
+
+		It("counts fetch failures", func() {
+			metricReporter.CaptureOCSPFetchFailure()
+
+			Expect(batcher.BatchIncrementCounterCallCount()).To(Equal(1))
+			Expect(batcher.BatchIncrementCounterArgsForCall(0)).To(Equal("ocsp.fetch_failures"))
+		})
+	})
+
+	Context("request validation", func() {
+		It("counts invalid requests by violation", func() {
+			metricReporter.CaptureInvalidRequest("obs_fold", false)
+
+			Expect(batcher.BatchIncrementCounterCallCount()).To(Equal(1))
+			Expect(batcher.BatchIncrementCounterArgsForCall(0)).To(Equal("invalid_requests.obs_fold"))
+		})
+
+		It("counts rejected requests", func() {
+			metricReporter.CaptureInvalidRequest("conflicting_framing", true)
+
+			Expect(batcher.BatchIncrementCounterCallCount()).To(Equal(2))
+			Expect(batcher.BatchIncrementCounterArgsForCall(0)).To(Equal("invalid_requests.conflicting_framing"))
+			Expect(batcher.BatchIncrementCounterArgsForCall(1)).To(Equal("invalid_requests.rejected"))
+		})
+	})
+
+	Describe("CaptureConnectionLimited", func() {
+		It("counts limited connections by policy", func() {
+			metricReporter.CaptureConnectionLimited("reset")
+
+			Expect(batcher.BatchIncrementCounterCallCount()).To(Equal(1))
+			Expect(batcher.BatchIncrementCounterArgsForCall(0)).To(Equal("connections_limited.reset"))
+		})
+	})
+
+	Describe("CaptureIngressRejected", func() {
+		It("counts rejected conns by listener and reason", func() {
+			metricReporter.CaptureIngressRejected("https", "client_cert")
+
+			Expect(batcher.BatchIncrementCounterCallCount()).To(Equal(1))
+			Expect(batcher.BatchIncrementCounterArgsForCall(0)).To(Equal("ingress_rejected.https.client_cert"))
+		})
+	})
+
+	Describe("CaptureAccept", func() {
+		It("counts accepted conns by listener", func() {
+			metricReporter.CaptureAccept("https")
+
+			Expect(batcher.BatchIncrementCounterCallCount()).To(Equal(1))
+			Expect(batcher.BatchIncrementCounterArgsForCall(0)).To(Equal("accept.https.accepted"))
+		})
+
+		It("counts accept errors and the temporary ones", func() {
+			metricReporter.CaptureAcceptError("http", true)
+			metricReporter.CaptureAcceptError("http", false)
+
+			Expect(batcher.BatchIncrementCounterCallCount()).To(Equal(3))
+			Expect(batcher.BatchIncrementCounterArgsForCall(0)).To(Equal("accept.http.errors"))
+			Expect(batcher.BatchIncrementCounterArgsForCall(1)).To(Equal("accept.http.temporary_errors"))
+			Expect(batcher.BatchIncrementCounterArgsForCall(2)).To(Equal("accept.http.errors"))
+		})
+	})
 })
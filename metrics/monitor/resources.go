@@ -0,0 +1,181 @@
+package monitor
+
+import (
+	"os"
+	"runtime"
+	"sync/atomic"
+	"time"
+
+	"code.cloudfoundry.org/gorouter/logger"
+
+	"github.com/cloudfoundry/dropsonde/metrics"
+	"github.com/uber-go/zap"
+)
+
+const (
+	ShedGoroutines = "goroutines"
+	ShedOpenFiles  = "open_files"
+)
+
+// resumeRatio is the share of a budget usage has to drop below before a
+// watchdog that started shedding stops again, so it does not flap
+const resumeRatio = 0.9
+
+// lowPriorityRatio is the share of a budget at which low priority requests
+// start being shed
+const lowPriorityRatio = 0.8
+
+// ResourceWatchdog samples the number of goroutines and open file descriptors
+// every interval. Once either reaches its budget, requests are shed until
+// usage drops back below 90% of the budget. Low priority requests are shed
+// from 80% of the budget on. A budget of 0 is not checked.
+type ResourceWatchdog struct {
+	maxGoroutines int
+	maxOpenFiles  int
+	interval      time.Duration
+	logger        logger.Logger
+
+	reason             atomic.Value
+	lowPriorityReason  atomic.Value
+	shedGoroutines     uint64
+	shedOpenFiles      uint64
+	reportedGoroutines uint64
+	reportedOpenFiles  uint64
+}
+
+func NewResourceWatchdog(maxGoroutines, maxOpenFiles int, interval time.Duration, logger logger.Logger) *ResourceWatchdog {
+	w := &ResourceWatchdog{
+		maxGoroutines: maxGoroutines,
+		maxOpenFiles:  maxOpenFiles,
+		interval:      interval,
+		logger:        logger,
+	}
+	w.reason.Store("")
+	w.lowPriorityReason.Store("")
+	return w
+}
+
+// Shed reports whether a request must be shed, and why. Every shed request is
+// counted.
+func (w *ResourceWatchdog) Shed() (string, bool) {
+	return w.shed(w.reason.Load().(string))
+}
+
+// ShedLowPriority is Shed for low priority requests
+func (w *ResourceWatchdog) ShedLowPriority() (string, bool) {
+	return w.shed(w.lowPriorityReason.Load().(string))
+}
+
+func (w *ResourceWatchdog) shed(reason string) (string, bool) {
+	switch reason {
+	case ShedGoroutines:
+		atomic.AddUint64(&w.shedGoroutines, 1)
+	case ShedOpenFiles:
+		atomic.AddUint64(&w.shedOpenFiles, 1)
+	default:
+		return "", false
+	}
+	return reason, true
+}
+
+// Check samples the resources, decides whether to shed and emits the samples
+// and the number of requests shed since the previous check
+func (w *ResourceWatchdog) Check() {
+	goroutines := runtime.NumGoroutine()
+	metrics.SendValue("goroutines", float64(goroutines), "")
+
+	openFiles := -1
+	if w.maxOpenFiles > 0 {
+		var err error
+		openFiles, err = countOpenFiles()
+		if err != nil {
+			w.logger.Error("counting-open-files-failed", zap.Error(err))
+			openFiles = -1
+		} else {
+			metrics.SendValue("open_files", float64(openFiles), "")
+		}
+	}
+
+	w.update(&w.lowPriorityReason, "low-priority-shedding", goroutines, openFiles, lowPriorityRatio)
+	w.update(&w.reason, "load-shedding", goroutines, openFiles, 1)
+
+	w.reportShed("shed_requests.goroutines", &w.shedGoroutines, &w.reportedGoroutines)
+	w.reportShed("shed_requests.open_files", &w.shedOpenFiles, &w.reportedOpenFiles)
+}
+
+// update decides whether to shed at ratio of the budgets, logging when
+// shedding starts or stops
+func (w *ResourceWatchdog) update(state *atomic.Value, action string, goroutines, openFiles int, ratio float64) {
+	previous := state.Load().(string)
+	reason := ""
+	switch {
+	case overBudget(goroutines, w.maxGoroutines, ratio, previous == ShedGoroutines):
+		reason = ShedGoroutines
+	case openFiles >= 0 && overBudget(openFiles, w.maxOpenFiles, ratio, previous == ShedOpenFiles):
+		reason = ShedOpenFiles
+	}
+	state.Store(reason)
+
+	if reason != previous {
+		if reason != "" {
+			w.logger.Info(action+"-started", zap.String("reason", reason),
+				zap.Int("goroutines", goroutines), zap.Int("open-files", openFiles))
+		} else {
+			w.logger.Info(action+"-stopped",
+				zap.Int("goroutines", goroutines), zap.Int("open-files", openFiles))
+		}
+	}
+}
+
+func (w *ResourceWatchdog) reportShed(name string, shed, reported *uint64) {
+	total := atomic.LoadUint64(shed)
+	if delta := total - *reported; delta > 0 {
+		metrics.AddToCounter(name, delta)
+		*reported = total
+	}
+}
+
+// Run checks the resources every interval until signaled
+func (w *ResourceWatchdog) Run(signals <-chan os.Signal, ready chan<- struct{}) error {
+	w.Check()
+	ticker := time.NewTicker(w.interval)
+	defer ticker.Stop()
+	close(ready)
+
+	for {
+		select {
+		case <-ticker.C:
+			w.Check()
+		case <-signals:
+			return nil
+		}
+	}
+}
+
+func overBudget(usage, budget int, ratio float64, shedding bool) bool {
+	if budget <= 0 {
+		return false
+	}
+	limit := ratio * float64(budget)
+	if shedding {
+		return float64(usage) >= resumeRatio*limit
+	}
+	return float64(usage) >= limit
+}
+
+// countOpenFiles counts the process' file descriptors. It needs /proc and
+// fails elsewhere.
+func countOpenFiles() (int, error) {
+	dir, err := os.Open("/proc/self/fd")
+	if err != nil {
+		return 0, err
+	}
+	defer dir.Close()
+
+	names, err := dir.Readdirnames(-1)
+	if err != nil {
+		return 0, err
+	}
+	// the directory itself is open while it is listed
+	return len(names) - 1, nil
+}
@@ -0,0 +1,146 @@
+package monitor_test
+
+import (
+	"io/ioutil"
+	"os"
+	"runtime"
+	"time"
+
+	logger_fakes "code.cloudfoundry.org/gorouter/logger/fakes"
+	"code.cloudfoundry.org/gorouter/metrics/monitor"
+
+	"github.com/cloudfoundry/sonde-go/events"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("ResourceWatchdog", func() {
+	var (
+		watchdog   *monitor.ResourceWatchdog
+		fakeLogger *logger_fakes.FakeLogger
+		stop       chan struct{}
+	)
+
+	// startGoroutines parks n goroutines until the test ends
+	startGoroutines := func(n int) {
+		for i := 0; i < n; i++ {
+			go func() { <-stop }()
+		}
+	}
+
+	BeforeEach(func() {
+		fakeEventEmitter.Reset()
+		fakeLogger = new(logger_fakes.FakeLogger)
+		stop = make(chan struct{})
+	})
+
+	AfterEach(func() {
+		close(stop)
+	})
+
+	Context("when the goroutine budget is reached", func() {
+		BeforeEach(func() {
+			watchdog = monitor.NewResourceWatchdog(runtime.NumGoroutine()+50, 0, time.Second, fakeLogger)
+		})
+
+		It("sheds requests until usage drops below 90% of the budget", func() {
+			watchdog.Check()
+			_, shed := watchdog.Shed()
+			Expect(shed).To(BeFalse())
+
+			startGoroutines(60)
+			watchdog.Check()
+			reason, shed := watchdog.Shed()
+			Expect(shed).To(BeTrue())
+			Expect(reason).To(Equal(monitor.ShedGoroutines))
+
+			close(stop)
+			stop = make(chan struct{})
+			Eventually(func() bool {
+				watchdog.Check()
+				_, shed := watchdog.Shed()
+				return shed
+			}).Should(BeFalse())
+		})
+
+		It("sheds low priority requests first", func() {
+			watchdog = monitor.NewResourceWatchdog(runtime.NumGoroutine()+100, 0, time.Second, fakeLogger)
+			startGoroutines(85)
+			watchdog.Check()
+
+			reason, shed := watchdog.ShedLowPriority()
+			Expect(shed).To(BeTrue())
+			Expect(reason).To(Equal(monitor.ShedGoroutines))
+
+			_, shed = watchdog.Shed()
+			Expect(shed).To(BeFalse())
+		})
+
+		It("emits the goroutines and the requests shed", func() {
+			startGoroutines(60)
+			watchdog.Check()
+			watchdog.Shed()
+			watchdog.Shed()
+			watchdog.Check()
+
+			var counted uint64
+			var sampled bool
+			for _, msg := range fakeEventEmitter.GetMessages() {
+				switch event := msg.Event.(type) {
+				case *events.ValueMetric:
+					sampled = sampled || *event.Name == "goroutines"
+				case *events.CounterEvent:
+					if *event.Name == "shed_requests.goroutines" {
+						counted += *event.Delta
+					}
+				}
+			}
+			Expect(sampled).To(BeTrue())
+			Expect(counted).To(BeEquivalentTo(2))
+		})
+	})
+
+	Context("when the open files budget is reached", func() {
+		var files []*os.File
+
+		BeforeEach(func() {
+			if runtime.GOOS != "linux" {
+				Skip("open files are counted from /proc")
+			}
+			watchdog = monitor.NewResourceWatchdog(0, 10000, time.Second, fakeLogger)
+			watchdog.Check()
+		})
+
+		AfterEach(func() {
+			for _, f := range files {
+				f.Close()
+				os.Remove(f.Name())
+			}
+		})
+
+		It("sheds requests", func() {
+			_, shed := watchdog.Shed()
+			Expect(shed).To(BeFalse())
+
+			var openFiles float64
+			for _, msg := range fakeEventEmitter.GetMessages() {
+				if event, ok := msg.Event.(*events.ValueMetric); ok && *event.Name == "open_files" {
+					openFiles = *event.Value
+				}
+			}
+			Expect(openFiles).To(BeNumerically(">", 0))
+
+			watchdog = monitor.NewResourceWatchdog(0, int(openFiles)+5, time.Second, fakeLogger)
+			for i := 0; i < 10; i++ {
+				f, err := ioutil.TempFile("", "watchdog")
+				Expect(err).NotTo(HaveOccurred())
+				files = append(files, f)
+			}
+			watchdog.Check()
+
+			reason, shed := watchdog.Shed()
+			Expect(shed).To(BeTrue())
+			Expect(reason).To(Equal(monitor.ShedOpenFiles))
+		})
+	})
+})
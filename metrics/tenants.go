@@ -0,0 +1,164 @@
+package metrics
+
+import (
+	"encoding/json"
+	"net/http"
+	"sync"
+	"time"
+
+	"code.cloudfoundry.org/gorouter/route"
+)
+
+// OtherTenant collects the responses of tenants seen after the aggregator
+// reached its limit
+const OtherTenant = "_other"
+
+// LatencySummary summarizes the response latency of a tenant
+type LatencySummary struct {
+	Count uint64  `json:"count"`
+	SumMs float64 `json:"sum_ms"`
+	MinMs float64 `json:"min_ms"`
+	MaxMs float64 `json:"max_ms"`
+}
+
+// TenantStats are the counters kept for an org or a space. Org is only set
+// for spaces.
+type TenantStats struct {
+	Org       string            `json:"org,omitempty"`
+	Requests  uint64            `json:"requests"`
+	Responses map[string]uint64 `json:"responses"`
+	Latency   LatencySummary    `json:"latency"`
+}
+
+func (s *TenantStats) add(statusCode int, d time.Duration) {
+	ms := float64(d) / float64(time.Millisecond)
+
+	s.Requests++
+	s.Responses[getResponseCounterName(statusCode)]++
+	if s.Latency.Count == 0 || ms < s.Latency.MinMs {
+		s.Latency.MinMs = ms
+	}
+	if ms > s.Latency.MaxMs {
+		s.Latency.MaxMs = ms
+	}
+	s.Latency.Count++
+	s.Latency.SumMs += ms
+}
+
+func (s *TenantStats) copy() *TenantStats {
+	c := *s
+	c.Responses = make(map[string]uint64, len(s.Responses))
+	for class, count := range s.Responses {
+		c.Responses[class] = count
+	}
+	return &c
+}
+
+// TenantSnapshot holds the counters of every org and space, by the value of
+// their endpoint tags
+type TenantSnapshot struct {
+	Orgs   map[string]*TenantStats `json:"orgs"`
+	Spaces map[string]*TenantStats `json:"spaces"`
+}
+
+// TenantAggregator keeps per-org and per-space response counters and latency
+// summaries, identifying tenants by the tags of the endpoints that served the
+// responses. The counters are cumulative and served as JSON.
+type TenantAggregator struct {
+	orgTag     string
+	spaceTag   string
+	maxTenants int
+
+	lock   sync.Mutex
+	orgs   map[string]*TenantStats
+	spaces map[string]*TenantStats
+}
+
+// NewTenantAggregator returns an aggregator keeping at most maxTenants orgs and
+// as many spaces
+func NewTenantAggregator(orgTag, spaceTag string, maxTenants int) *TenantAggregator {
+	return &TenantAggregator{
+		orgTag:     orgTag,
+		spaceTag:   spaceTag,
+		maxTenants: maxTenants,
+		orgs:       make(map[string]*TenantStats),
+		spaces:     make(map[string]*TenantStats),
+	}
+}
+
+// CaptureResponse records a response from an endpoint. Endpoints without the
+// org tag, such as platform components, are not counted.
+func (a *TenantAggregator) CaptureResponse(b *route.Endpoint, statusCode int, d time.Duration) {
+	if b == nil {
+		return
+	}
+	org := b.Tags[a.orgTag]
+	if org == "" {
+		return
+	}
+	space := b.Tags[a.spaceTag]
+
+	a.lock.Lock()
+	defer a.lock.Unlock()
+
+	a.stats(a.orgs, org, "").add(statusCode, d)
+	if space != "" {
+		a.stats(a.spaces, space, org).add(statusCode, d)
+	}
+}
+
+func (a *TenantAggregator) stats(tenants map[string]*TenantStats, name, org string) *TenantStats {
+	s, ok := tenants[name]
+	if ok {
+		return s
+	}
+	if len(tenants) >= a.maxTenants {
+		name, org = OtherTenant, ""
+		if s, ok = tenants[name]; ok {
+			return s
+		}
+	}
+
+	s = &TenantStats{Org: org, Responses: make(map[string]uint64)}
+	tenants[name] = s
+	return s
+}
+
+// Snapshot returns a copy of the current counters
+func (a *TenantAggregator) Snapshot() TenantSnapshot {
+	a.lock.Lock()
+	defer a.lock.Unlock()
+
+	snapshot := TenantSnapshot{
+		Orgs:   make(map[string]*TenantStats, len(a.orgs)),
+		Spaces: make(map[string]*TenantStats, len(a.spaces)),
+	}
+	for name, s := range a.orgs {
+		snapshot.Orgs[name] = s.copy()
+	}
+	for name, s := range a.spaces {
+		snapshot.Spaces[name] = s.copy()
+	}
+	return snapshot
+}
+
+func (a *TenantAggregator) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(a.Snapshot())
+}
+
+type tenantReporter struct {
+	CombinedReporter
+	tenants *TenantAggregator
+}
+
+// NewTenantReporter returns a CombinedReporter that also records every routed
+// response in tenants
+func NewTenantReporter(reporter CombinedReporter, tenants *TenantAggregator) CombinedReporter {
+	return &tenantReporter{CombinedReporter: reporter, tenants: tenants}
+}
+
+func (t *tenantReporter) CaptureRoutingResponseLatency(b *route.Endpoint, statusCode int, ts time.Time, d time.Duration) {
+	t.tenants.CaptureResponse(b, statusCode, d)
+	t.CombinedReporter.CaptureRoutingResponseLatency(b, statusCode, ts, d)
+}
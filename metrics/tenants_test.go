@@ -0,0 +1,104 @@
+package metrics_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"time"
+
+	"code.cloudfoundry.org/gorouter/metrics"
+	"code.cloudfoundry.org/gorouter/metrics/fakes"
+	"code.cloudfoundry.org/gorouter/route"
+	"code.cloudfoundry.org/routing-api/models"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("TenantAggregator", func() {
+	var aggregator *metrics.TenantAggregator
+
+	newEndpoint := func(tags map[string]string) *route.Endpoint {
+		return route.NewEndpoint("someId", "host", 2222, "privateId", "2", tags, 30, "", models.ModificationTag{}, "")
+	}
+
+	BeforeEach(func() {
+		aggregator = metrics.NewTenantAggregator("organization_id", "space_id", 2)
+	})
+
+	It("aggregates responses per org and per space", func() {
+		endpoint := newEndpoint(map[string]string{"organization_id": "org-1", "space_id": "space-1"})
+		aggregator.CaptureResponse(endpoint, http.StatusOK, 10*time.Millisecond)
+		aggregator.CaptureResponse(endpoint, http.StatusBadGateway, 30*time.Millisecond)
+		aggregator.CaptureResponse(newEndpoint(map[string]string{"organization_id": "org-1", "space_id": "space-2"}), http.StatusOK, 20*time.Millisecond)
+
+		snapshot := aggregator.Snapshot()
+		Expect(snapshot.Orgs).To(HaveLen(1))
+		Expect(*snapshot.Orgs["org-1"]).To(Equal(metrics.TenantStats{
+			Requests:  3,
+			Responses: map[string]uint64{"2xx": 2, "5xx": 1},
+			Latency:   metrics.LatencySummary{Count: 3, SumMs: 60, MinMs: 10, MaxMs: 30},
+		}))
+
+		Expect(snapshot.Spaces).To(HaveLen(2))
+		Expect(snapshot.Spaces["space-1"].Org).To(Equal("org-1"))
+		Expect(snapshot.Spaces["space-1"].Requests).To(BeEquivalentTo(2))
+		Expect(snapshot.Spaces["space-2"].Latency.MinMs).To(BeEquivalentTo(20))
+	})
+
+	It("ignores endpoints without the org tag", func() {
+		aggregator.CaptureResponse(newEndpoint(map[string]string{"component": "uaa"}), http.StatusOK, time.Millisecond)
+		aggregator.CaptureResponse(nil, http.StatusBadGateway, time.Millisecond)
+
+		snapshot := aggregator.Snapshot()
+		Expect(snapshot.Orgs).To(BeEmpty())
+		Expect(snapshot.Spaces).To(BeEmpty())
+	})
+
+	It("collects tenants beyond the limit under one entry", func() {
+		for _, org := range []string{"org-1", "org-2", "org-3", "org-4"} {
+			aggregator.CaptureResponse(newEndpoint(map[string]string{"organization_id": org}), http.StatusOK, time.Millisecond)
+		}
+
+		snapshot := aggregator.Snapshot()
+		Expect(snapshot.Orgs).To(HaveLen(3))
+		Expect(snapshot.Orgs[metrics.OtherTenant].Requests).To(BeEquivalentTo(2))
+	})
+
+	It("returns a copy of the counters", func() {
+		endpoint := newEndpoint(map[string]string{"organization_id": "org-1"})
+		aggregator.CaptureResponse(endpoint, http.StatusOK, time.Millisecond)
+		snapshot := aggregator.Snapshot()
+
+		aggregator.CaptureResponse(endpoint, http.StatusOK, time.Millisecond)
+		Expect(snapshot.Orgs["org-1"].Requests).To(BeEquivalentTo(1))
+		Expect(snapshot.Orgs["org-1"].Responses["2xx"]).To(BeEquivalentTo(1))
+	})
+
+	It("serves the counters as JSON", func() {
+		aggregator.CaptureResponse(newEndpoint(map[string]string{"organization_id": "org-1"}), http.StatusNotFound, time.Millisecond)
+
+		resp := httptest.NewRecorder()
+		aggregator.ServeHTTP(resp, httptest.NewRequest("GET", "/metrics/tenants", nil))
+		Expect(resp.Header().Get("Content-Type")).To(Equal("application/json"))
+
+		var snapshot metrics.TenantSnapshot
+		Expect(json.Unmarshal(resp.Body.Bytes(), &snapshot)).To(Succeed())
+		Expect(snapshot.Orgs["org-1"].Responses).To(Equal(map[string]uint64{"4xx": 1}))
+	})
+
+	Describe("NewTenantReporter", func() {
+		It("records routed responses and forwards them", func() {
+			fakeReporter := new(fakes.FakeCombinedReporter)
+			reporter := metrics.NewTenantReporter(fakeReporter, aggregator)
+
+			endpoint := newEndpoint(map[string]string{"organization_id": "org-1"})
+			reporter.CaptureRoutingResponseLatency(endpoint, http.StatusOK, time.Now(), time.Millisecond)
+			reporter.CaptureBadGateway()
+
+			Expect(aggregator.Snapshot().Orgs["org-1"].Requests).To(BeEquivalentTo(1))
+			Expect(fakeReporter.CaptureRoutingResponseLatencyCallCount()).To(Equal(1))
+			Expect(fakeReporter.CaptureBadGatewayCallCount()).To(Equal(1))
+		})
+	})
+})
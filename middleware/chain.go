@@ -0,0 +1,136 @@
+package middleware
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+
+	"code.cloudfoundry.org/gorouter/config"
+
+	"github.com/urfave/negroni"
+)
+
+type enabledPlugin struct {
+	Plugin
+	routes []string
+}
+
+// appliesTo reports whether the plugin is enabled for requests to host
+func (p *enabledPlugin) appliesTo(host string) bool {
+	if len(p.routes) == 0 {
+		return true
+	}
+
+	host = strings.ToLower(hostWithoutPort(host))
+	for _, route := range p.routes {
+		if route == host {
+			return true
+		}
+		if strings.HasPrefix(route, "*.") && strings.HasSuffix(host, route[1:]) {
+			return true
+		}
+	}
+	return false
+}
+
+// Chain holds the plugins enabled in middleware_plugins, grouped by stage
+type Chain struct {
+	stages map[Stage][]*enabledPlugin
+}
+
+// NewChain returns the chain of the plugins enabled by cfgs. It fails when a
+// configured plugin is not registered or is configured twice.
+func NewChain(cfgs []config.MiddlewarePluginConfig) (*Chain, error) {
+	c := &Chain{stages: make(map[Stage][]*enabledPlugin)}
+	seen := make(map[string]bool, len(cfgs))
+
+	for _, cfg := range cfgs {
+		p, ok := Lookup(cfg.Name)
+		if !ok {
+			return nil, fmt.Errorf("middleware plugin %s is not registered", cfg.Name)
+		}
+		if seen[cfg.Name] {
+			return nil, fmt.Errorf("middleware plugin %s is configured more than once", cfg.Name)
+		}
+		seen[cfg.Name] = true
+
+		routes := make([]string, len(cfg.Routes))
+		for i, route := range cfg.Routes {
+			routes[i] = strings.ToLower(route)
+		}
+		c.stages[p.Stage()] = append(c.stages[p.Stage()], &enabledPlugin{Plugin: p, routes: routes})
+	}
+
+	for _, stage := range c.stages {
+		sort.Sort(byOrder(stage))
+	}
+	return c, nil
+}
+
+// Handler returns a handler running the plugins of stage
+func (c *Chain) Handler(stage Stage) negroni.Handler {
+	plugins := c.stages[stage]
+	if stage == PostProxy {
+		return &postProxyHandler{plugins: plugins}
+	}
+	return &stageHandler{plugins: plugins}
+}
+
+type stageHandler struct {
+	plugins []*enabledPlugin
+}
+
+func (h *stageHandler) ServeHTTP(rw http.ResponseWriter, r *http.Request, next http.HandlerFunc) {
+	h.serve(0, rw, r, next)
+}
+
+func (h *stageHandler) serve(i int, rw http.ResponseWriter, r *http.Request, next http.HandlerFunc) {
+	for ; i < len(h.plugins); i++ {
+		if h.plugins[i].appliesTo(r.Host) {
+			break
+		}
+	}
+	if i == len(h.plugins) {
+		next(rw, r)
+		return
+	}
+
+	h.plugins[i].ServeHTTP(rw, r, func(rw http.ResponseWriter, r *http.Request) {
+		h.serve(i+1, rw, r, next)
+	})
+}
+
+type postProxyHandler struct {
+	plugins []*enabledPlugin
+}
+
+func (h *postProxyHandler) ServeHTTP(rw http.ResponseWriter, r *http.Request, next http.HandlerFunc) {
+	next(rw, r)
+
+	for _, p := range h.plugins {
+		if p.appliesTo(r.Host) {
+			p.ServeHTTP(rw, r, noop)
+		}
+	}
+}
+
+func noop(http.ResponseWriter, *http.Request) {}
+
+type byOrder []*enabledPlugin
+
+func (p byOrder) Len() int      { return len(p) }
+func (p byOrder) Swap(i, j int) { p[i], p[j] = p[j], p[i] }
+func (p byOrder) Less(i, j int) bool {
+	if p[i].Order() != p[j].Order() {
+		return p[i].Order() < p[j].Order()
+	}
+	return p[i].Name() < p[j].Name()
+}
+
+func hostWithoutPort(host string) string {
+	if pos := strings.Index(host, ":"); pos >= 0 {
+		return host[:pos]
+	}
+	return host
+}
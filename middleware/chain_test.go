@@ -0,0 +1,176 @@
+package middleware_test
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+
+	"code.cloudfoundry.org/gorouter/config"
+	"code.cloudfoundry.org/gorouter/middleware"
+	"code.cloudfoundry.org/gorouter/test_util"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/urfave/negroni"
+)
+
+type testPlugin struct {
+	name  string
+	stage middleware.Stage
+	order int
+	calls *[]string
+	stop  bool
+}
+
+func (p *testPlugin) Name() string            { return p.name }
+func (p *testPlugin) Stage() middleware.Stage { return p.stage }
+func (p *testPlugin) Order() int              { return p.order }
+
+func (p *testPlugin) ServeHTTP(rw http.ResponseWriter, r *http.Request, next http.HandlerFunc) {
+	*p.calls = append(*p.calls, p.name)
+	if p.stop {
+		rw.WriteHeader(http.StatusForbidden)
+		return
+	}
+	next(rw, r)
+}
+
+var pluginCount int32
+
+// uniqueName keeps plugins from different tests apart in the global registry
+func uniqueName(name string) string {
+	return fmt.Sprintf("%s-%d", name, atomic.AddInt32(&pluginCount, 1))
+}
+
+var _ = Describe("Chain", func() {
+	var (
+		calls []string
+		cfgs  []config.MiddlewarePluginConfig
+	)
+
+	register := func(name string, stage middleware.Stage, order int) *testPlugin {
+		p := &testPlugin{name: uniqueName(name), stage: stage, order: order, calls: &calls}
+		Expect(middleware.Register(p)).To(Succeed())
+		return p
+	}
+
+	serve := func(host string) *httptest.ResponseRecorder {
+		chain, err := middleware.NewChain(cfgs)
+		Expect(err).NotTo(HaveOccurred())
+
+		n := negroni.New()
+		n.Use(chain.Handler(middleware.PostProxy))
+		n.Use(chain.Handler(middleware.PreLookup))
+		n.Use(chain.Handler(middleware.PostLookup))
+		n.UseHandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+			calls = append(calls, "backend")
+		})
+
+		resp := httptest.NewRecorder()
+		n.ServeHTTP(resp, test_util.NewRequest("GET", host, "/", nil))
+		return resp
+	}
+
+	BeforeEach(func() {
+		calls = nil
+		cfgs = nil
+	})
+
+	It("runs enabled plugins at their stage, by order", func() {
+		post := register("post", middleware.PostProxy, 0)
+		late := register("late", middleware.PreLookup, 10)
+		early := register("early", middleware.PreLookup, 1)
+		lookup := register("lookup", middleware.PostLookup, 0)
+		cfgs = []config.MiddlewarePluginConfig{{Name: post.name}, {Name: late.name}, {Name: early.name}, {Name: lookup.name}}
+
+		serve("example.com")
+		Expect(calls).To(Equal([]string{early.name, late.name, lookup.name, "backend", post.name}))
+	})
+
+	It("does not run registered plugins that are not enabled", func() {
+		register("disabled", middleware.PreLookup, 0)
+
+		serve("example.com")
+		Expect(calls).To(Equal([]string{"backend"}))
+	})
+
+	It("only runs plugins for their configured routes", func() {
+		exact := register("exact", middleware.PreLookup, 0)
+		wildcard := register("wildcard", middleware.PreLookup, 1)
+		cfgs = []config.MiddlewarePluginConfig{
+			{Name: exact.name, Routes: []string{"app.example.com"}},
+			{Name: wildcard.name, Routes: []string{"*.internal.example.com"}},
+		}
+
+		serve("APP.example.com:8080")
+		Expect(calls).To(Equal([]string{exact.name, "backend"}))
+
+		calls = nil
+		serve("api.internal.example.com")
+		Expect(calls).To(Equal([]string{wildcard.name, "backend"}))
+
+		calls = nil
+		serve("other.example.com")
+		Expect(calls).To(Equal([]string{"backend"}))
+	})
+
+	It("lets a plugin end the request", func() {
+		auth := register("auth", middleware.PreLookup, 0)
+		auth.stop = true
+		cfgs = []config.MiddlewarePluginConfig{{Name: auth.name}}
+
+		resp := serve("example.com")
+		Expect(resp.Code).To(Equal(http.StatusForbidden))
+		Expect(calls).To(Equal([]string{auth.name}))
+	})
+
+	It("fails for plugins that are not registered", func() {
+		_, err := middleware.NewChain([]config.MiddlewarePluginConfig{{Name: "missing"}})
+		Expect(err).To(HaveOccurred())
+	})
+
+	It("fails for plugins configured twice", func() {
+		p := register("twice", middleware.PreProxy, 0)
+		_, err := middleware.NewChain([]config.MiddlewarePluginConfig{{Name: p.name}, {Name: p.name}})
+		Expect(err).To(HaveOccurred())
+	})
+})
+
+var _ = Describe("Register", func() {
+	It("rejects duplicate names", func() {
+		var calls []string
+		name := uniqueName("duplicate")
+		Expect(middleware.Register(&testPlugin{name: name, calls: &calls})).To(Succeed())
+		Expect(middleware.Register(&testPlugin{name: name, calls: &calls})).NotTo(Succeed())
+		Expect(middleware.Registered()).To(ContainElement(name))
+	})
+
+	It("rejects unknown stages", func() {
+		var calls []string
+		Expect(middleware.Register(&testPlugin{name: uniqueName("stage"), stage: 42, calls: &calls})).NotTo(Succeed())
+	})
+})
+
+var _ = Describe("Unregister", func() {
+	It("lets the name be registered again", func() {
+		var calls []string
+		name := uniqueName("unregister")
+		p := &testPlugin{name: name, calls: &calls}
+		Expect(middleware.Register(p)).To(Succeed())
+
+		middleware.Unregister(p)
+		_, ok := middleware.Lookup(name)
+		Expect(ok).To(BeFalse())
+		Expect(middleware.Register(&testPlugin{name: name, calls: &calls})).To(Succeed())
+	})
+
+	It("leaves another plugin of the same name alone", func() {
+		var calls []string
+		name := uniqueName("unregister")
+		Expect(middleware.Register(&testPlugin{name: name, calls: &calls})).To(Succeed())
+
+		middleware.Unregister(&testPlugin{name: name, calls: &calls})
+		Expect(middleware.Registered()).To(ContainElement(name))
+	})
+})
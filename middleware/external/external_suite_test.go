@@ -0,0 +1,13 @@
+package external_test
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"testing"
+)
+
+func TestExternal(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "External Suite")
+}
@@ -0,0 +1,165 @@
+package external
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"os"
+	"os/exec"
+	"time"
+
+	"code.cloudfoundry.org/gorouter/config"
+	"code.cloudfoundry.org/gorouter/logger"
+	"code.cloudfoundry.org/gorouter/metrics"
+	"code.cloudfoundry.org/gorouter/middleware"
+
+	"github.com/hashicorp/go-plugin"
+	"github.com/uber-go/zap"
+)
+
+var stages = map[string]middleware.Stage{
+	config.EXTERNAL_PLUGIN_STAGE_PRE_LOOKUP:  middleware.PreLookup,
+	config.EXTERNAL_PLUGIN_STAGE_POST_LOOKUP: middleware.PostLookup,
+	config.EXTERNAL_PLUGIN_STAGE_PRE_PROXY:   middleware.PreProxy,
+}
+
+// Plugin is a middleware plugin backed by a Filter, usually one running in a
+// plugin process. As an ifrit runner it health checks the process and kills
+// it when signaled.
+type Plugin struct {
+	cfg      config.ExternalPluginConfig
+	filter   Filter
+	client   *plugin.Client
+	ping     func() error
+	reporter metrics.PluginReporter
+	logger   logger.Logger
+}
+
+// Start starts the plugin binary configured in cfg and connects to it
+func Start(cfg config.ExternalPluginConfig, reporter metrics.PluginReporter, logger logger.Logger) (*Plugin, error) {
+	client := plugin.NewClient(&plugin.ClientConfig{
+		HandshakeConfig:  Handshake,
+		Plugins:          plugin.PluginSet{pluginName: &FilterPlugin{}},
+		Cmd:              exec.Command(cfg.Path, cfg.Args...),
+		AllowedProtocols: []plugin.Protocol{plugin.ProtocolGRPC},
+	})
+
+	protocol, err := client.Client()
+	if err != nil {
+		client.Kill()
+		return nil, fmt.Errorf("starting external plugin %s: %s", cfg.Name, err)
+	}
+	raw, err := protocol.Dispense(pluginName)
+	if err != nil {
+		client.Kill()
+		return nil, fmt.Errorf("connecting to external plugin %s: %s", cfg.Name, err)
+	}
+
+	p := NewPlugin(cfg, raw.(Filter), reporter, logger)
+	p.client = client
+	p.ping = protocol.Ping
+	return p, nil
+}
+
+// NewPlugin returns a Plugin calling filter directly, without a plugin process
+func NewPlugin(cfg config.ExternalPluginConfig, filter Filter, reporter metrics.PluginReporter, logger logger.Logger) *Plugin {
+	return &Plugin{
+		cfg:      cfg,
+		filter:   filter,
+		ping:     func() error { return nil },
+		reporter: reporter,
+		logger:   logger,
+	}
+}
+
+func (p *Plugin) Name() string { return p.cfg.Name }
+
+func (p *Plugin) Stage() middleware.Stage { return stages[p.cfg.Stage] }
+
+func (p *Plugin) Order() int { return p.cfg.Order }
+
+func (p *Plugin) ServeHTTP(rw http.ResponseWriter, r *http.Request, next http.HandlerFunc) {
+	ctx, cancel := context.WithTimeout(r.Context(), p.cfg.Timeout)
+	defer cancel()
+
+	started := time.Now()
+	resp, err := p.filter.Filter(ctx, &FilterRequest{
+		Method:     r.Method,
+		Host:       r.Host,
+		URI:        r.URL.RequestURI(),
+		RemoteAddr: r.RemoteAddr,
+		Headers:    r.Header,
+	})
+	p.reporter.CapturePluginCall(p.cfg.Name, time.Since(started))
+
+	if err != nil {
+		p.reporter.CapturePluginFailure(p.cfg.Name)
+		p.logger.Error("external-plugin-failed",
+			zap.String("plugin", p.cfg.Name),
+			zap.String("failure-policy", p.cfg.FailurePolicy),
+			zap.Error(err),
+		)
+
+		if p.cfg.FailurePolicy == config.EXTERNAL_PLUGIN_FAIL_OPEN {
+			next(rw, r)
+			return
+		}
+		rw.Header().Set("X-Cf-RouterError", "plugin_failure")
+		http.Error(rw, fmt.Sprintf("%d %s: Request filter is unavailable.", http.StatusServiceUnavailable,
+			http.StatusText(http.StatusServiceUnavailable)), http.StatusServiceUnavailable)
+		return
+	}
+
+	if resp.StatusCode != 0 {
+		for name, values := range resp.SetHeaders {
+			rw.Header()[http.CanonicalHeaderKey(name)] = values
+		}
+		rw.WriteHeader(resp.StatusCode)
+		_, _ = rw.Write(resp.Body)
+		return
+	}
+
+	for name, values := range resp.SetHeaders {
+		r.Header[http.CanonicalHeaderKey(name)] = values
+	}
+	for _, name := range resp.RemoveHeaders {
+		r.Header.Del(name)
+	}
+	next(rw, r)
+}
+
+// Run reports the plugin's health every health_check_interval until signaled,
+// then kills the plugin process.
+func (p *Plugin) Run(signals <-chan os.Signal, ready chan<- struct{}) error {
+	p.checkHealth()
+	close(ready)
+
+	ticker := time.NewTicker(p.cfg.HealthCheckInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			p.checkHealth()
+		case <-signals:
+			p.Kill()
+			return nil
+		}
+	}
+}
+
+// Kill stops the plugin process and unregisters the plugin
+func (p *Plugin) Kill() {
+	middleware.Unregister(p)
+	if p.client != nil {
+		p.client.Kill()
+	}
+}
+
+func (p *Plugin) checkHealth() {
+	err := p.ping()
+	if err != nil {
+		p.logger.Error("external-plugin-unhealthy", zap.String("plugin", p.cfg.Name), zap.Error(err))
+	}
+	p.reporter.CapturePluginHealth(p.cfg.Name, err == nil)
+}
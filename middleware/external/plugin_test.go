@@ -0,0 +1,201 @@
+package external_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"time"
+
+	"code.cloudfoundry.org/gorouter/config"
+	logger_fakes "code.cloudfoundry.org/gorouter/logger/fakes"
+	metrics_fakes "code.cloudfoundry.org/gorouter/metrics/fakes"
+	"code.cloudfoundry.org/gorouter/middleware"
+	"code.cloudfoundry.org/gorouter/middleware/external"
+	"code.cloudfoundry.org/gorouter/test_util"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/tedsuo/ifrit"
+)
+
+type fakeFilter struct {
+	filter func(ctx context.Context, req *external.FilterRequest) (*external.FilterResponse, error)
+}
+
+func (f *fakeFilter) Filter(ctx context.Context, req *external.FilterRequest) (*external.FilterResponse, error) {
+	return f.filter(ctx, req)
+}
+
+var _ = Describe("Plugin", func() {
+	var (
+		cfg          config.ExternalPluginConfig
+		filter       *fakeFilter
+		fakeReporter *metrics_fakes.FakePluginReporter
+		fakeLogger   *logger_fakes.FakeLogger
+		plugin       *external.Plugin
+
+		req         *http.Request
+		resp        *httptest.ResponseRecorder
+		nextCalled  bool
+		nextRequest *http.Request
+	)
+
+	BeforeEach(func() {
+		cfg = config.ExternalPluginConfig{
+			Name:                "waf",
+			Path:                "/bin/waf",
+			Stage:               config.EXTERNAL_PLUGIN_STAGE_POST_LOOKUP,
+			Order:               3,
+			Timeout:             50 * time.Millisecond,
+			FailurePolicy:       config.EXTERNAL_PLUGIN_FAIL_CLOSED,
+			HealthCheckInterval: 10 * time.Millisecond,
+		}
+		filter = &fakeFilter{filter: func(context.Context, *external.FilterRequest) (*external.FilterResponse, error) {
+			return &external.FilterResponse{}, nil
+		}}
+		fakeReporter = new(metrics_fakes.FakePluginReporter)
+		fakeLogger = new(logger_fakes.FakeLogger)
+
+		req = test_util.NewRequest("GET", "example.com", "/foo?bar=baz", nil)
+		req.Header.Set("X-Remove-Me", "please")
+		resp = httptest.NewRecorder()
+		nextCalled = false
+		nextRequest = nil
+	})
+
+	JustBeforeEach(func() {
+		plugin = external.NewPlugin(cfg, filter, fakeReporter, fakeLogger)
+	})
+
+	serve := func() {
+		plugin.ServeHTTP(resp, req, func(rw http.ResponseWriter, r *http.Request) {
+			nextCalled = true
+			nextRequest = r
+		})
+	}
+
+	It("is a middleware plugin at the configured stage", func() {
+		Expect(plugin.Name()).To(Equal("waf"))
+		Expect(plugin.Stage()).To(Equal(middleware.PostLookup))
+		Expect(plugin.Order()).To(Equal(3))
+	})
+
+	It("sends the request to the filter", func() {
+		var filtered *external.FilterRequest
+		filter.filter = func(_ context.Context, r *external.FilterRequest) (*external.FilterResponse, error) {
+			filtered = r
+			return &external.FilterResponse{}, nil
+		}
+
+		serve()
+		Expect(filtered.Method).To(Equal("GET"))
+		Expect(filtered.Host).To(Equal("example.com"))
+		Expect(filtered.URI).To(Equal("/foo?bar=baz"))
+		Expect(filtered.Headers.Get("X-Remove-Me")).To(Equal("please"))
+
+		Expect(fakeReporter.CapturePluginCallCallCount()).To(Equal(1))
+		name, _ := fakeReporter.CapturePluginCallArgsForCall(0)
+		Expect(name).To(Equal("waf"))
+	})
+
+	It("continues with the headers changed by the filter", func() {
+		filter.filter = func(context.Context, *external.FilterRequest) (*external.FilterResponse, error) {
+			return &external.FilterResponse{
+				SetHeaders:    http.Header{"X-User": []string{"alice"}},
+				RemoveHeaders: []string{"X-Remove-Me"},
+			}, nil
+		}
+
+		serve()
+		Expect(nextCalled).To(BeTrue())
+		Expect(nextRequest.Header.Get("X-User")).To(Equal("alice"))
+		Expect(nextRequest.Header).NotTo(HaveKey("X-Remove-Me"))
+	})
+
+	It("ends the request when the filter responds", func() {
+		filter.filter = func(context.Context, *external.FilterRequest) (*external.FilterResponse, error) {
+			return &external.FilterResponse{
+				StatusCode: http.StatusUnauthorized,
+				Body:       []byte("go away"),
+				SetHeaders: http.Header{"Www-Authenticate": []string{"Basic"}},
+			}, nil
+		}
+
+		serve()
+		Expect(nextCalled).To(BeFalse())
+		Expect(resp.Code).To(Equal(http.StatusUnauthorized))
+		Expect(resp.Body.String()).To(Equal("go away"))
+		Expect(resp.Header().Get("WWW-Authenticate")).To(Equal("Basic"))
+	})
+
+	Context("when the filter does not answer in time", func() {
+		BeforeEach(func() {
+			filter.filter = func(ctx context.Context, _ *external.FilterRequest) (*external.FilterResponse, error) {
+				<-ctx.Done()
+				return nil, ctx.Err()
+			}
+		})
+
+		It("fails closed by default", func() {
+			serve()
+			Expect(nextCalled).To(BeFalse())
+			Expect(resp.Code).To(Equal(http.StatusServiceUnavailable))
+			Expect(resp.Header().Get("X-Cf-RouterError")).To(Equal("plugin_failure"))
+
+			Expect(fakeReporter.CapturePluginFailureCallCount()).To(Equal(1))
+			Expect(fakeReporter.CapturePluginFailureArgsForCall(0)).To(Equal("waf"))
+		})
+
+		Context("with the fail_open policy", func() {
+			BeforeEach(func() {
+				cfg.FailurePolicy = config.EXTERNAL_PLUGIN_FAIL_OPEN
+			})
+
+			It("continues with the request", func() {
+				serve()
+				Expect(nextCalled).To(BeTrue())
+				Expect(fakeReporter.CapturePluginFailureCallCount()).To(Equal(1))
+			})
+		})
+	})
+
+	It("fails closed when the filter errors", func() {
+		filter.filter = func(context.Context, *external.FilterRequest) (*external.FilterResponse, error) {
+			return nil, errors.New("boom")
+		}
+
+		serve()
+		Expect(resp.Code).To(Equal(http.StatusServiceUnavailable))
+		Expect(fakeLogger.ErrorCallCount()).To(Equal(1))
+	})
+
+	It("reports its health until signaled", func() {
+		process := ifrit.Invoke(plugin)
+
+		Eventually(fakeReporter.CapturePluginHealthCallCount).Should(BeNumerically(">=", 2))
+		name, healthy := fakeReporter.CapturePluginHealthArgsForCall(0)
+		Expect(name).To(Equal("waf"))
+		Expect(healthy).To(BeTrue())
+
+		process.Signal(os.Interrupt)
+		Eventually(process.Wait()).Should(Receive(BeNil()))
+	})
+	It("unregisters itself when signaled", func() {
+		Expect(middleware.Register(plugin)).To(Succeed())
+		process := ifrit.Invoke(plugin)
+
+		process.Signal(os.Interrupt)
+		Eventually(process.Wait()).Should(Receive(BeNil()))
+		_, ok := middleware.Lookup("waf")
+		Expect(ok).To(BeFalse())
+
+		restarted := external.NewPlugin(cfg, filter, fakeReporter, fakeLogger)
+		Expect(middleware.Register(restarted)).To(Succeed())
+		middleware.Unregister(plugin)
+		_, ok = middleware.Lookup("waf")
+		Expect(ok).To(BeTrue())
+		middleware.Unregister(restarted)
+	})
+})
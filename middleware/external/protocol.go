@@ -0,0 +1,128 @@
+// Package external runs filter plugins out of process over gRPC, using
+// hashicorp/go-plugin, for teams that cannot build their own gorouter. A
+// plugin binary calls Serve with its Filter; gorouter starts the binary and
+// registers it as a middleware plugin.
+package external
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+
+	"github.com/hashicorp/go-plugin"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/encoding"
+)
+
+// Handshake is shared by gorouter and its plugins. A plugin binary refuses to
+// run unless it is started by gorouter.
+var Handshake = plugin.HandshakeConfig{
+	ProtocolVersion:  1,
+	MagicCookieKey:   "GOROUTER_PLUGIN",
+	MagicCookieValue: "6e1c3bd5-1f2a-4b7e-9f0a-d8a43c2e7b19",
+}
+
+const pluginName = "filter"
+
+// FilterRequest describes the request being filtered. Bodies are not sent to
+// plugins.
+type FilterRequest struct {
+	Method     string      `json:"method"`
+	Host       string      `json:"host"`
+	URI        string      `json:"uri"`
+	RemoteAddr string      `json:"remote_addr"`
+	Headers    http.Header `json:"headers"`
+}
+
+// FilterResponse tells gorouter what to do with a request. When StatusCode is
+// set the request ends with that status, Body and SetHeaders as the response.
+// Otherwise the request continues with SetHeaders set on it and
+// RemoveHeaders removed from it.
+type FilterResponse struct {
+	StatusCode    int         `json:"status_code,omitempty"`
+	Body          []byte      `json:"body,omitempty"`
+	SetHeaders    http.Header `json:"set_headers,omitempty"`
+	RemoveHeaders []string    `json:"remove_headers,omitempty"`
+}
+
+// Filter is implemented by plugins. It is called with the request timeout of
+// the plugin configuration already applied to ctx.
+type Filter interface {
+	Filter(ctx context.Context, req *FilterRequest) (*FilterResponse, error)
+}
+
+// Serve runs f as a gorouter plugin. It is called from the plugin's main and
+// does not return.
+func Serve(f Filter) {
+	plugin.Serve(&plugin.ServeConfig{
+		HandshakeConfig: Handshake,
+		Plugins:         plugin.PluginSet{pluginName: &FilterPlugin{Impl: f}},
+		GRPCServer:      plugin.DefaultGRPCServer,
+	})
+}
+
+// FilterPlugin connects a Filter to go-plugin's gRPC transport
+type FilterPlugin struct {
+	plugin.NetRPCUnsupportedPlugin
+	Impl Filter
+}
+
+func (p *FilterPlugin) GRPCServer(_ *plugin.GRPCBroker, s *grpc.Server) error {
+	s.RegisterService(&filterServiceDesc, p.Impl)
+	return nil
+}
+
+func (p *FilterPlugin) GRPCClient(_ context.Context, _ *plugin.GRPCBroker, conn *grpc.ClientConn) (interface{}, error) {
+	return &filterClient{conn: conn}, nil
+}
+
+// The filter service is described by hand and carries JSON rather than
+// protobuf, so neither side needs generated code.
+const filterMethod = "/gorouter.plugin.Filter/Filter"
+
+var filterServiceDesc = grpc.ServiceDesc{
+	ServiceName: "gorouter.plugin.Filter",
+	HandlerType: (*Filter)(nil),
+	Methods: []grpc.MethodDesc{
+		{MethodName: "Filter", Handler: filterHandler},
+	},
+}
+
+func filterHandler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(FilterRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(Filter).Filter(ctx, in)
+	}
+
+	info := &grpc.UnaryServerInfo{Server: srv, FullMethod: filterMethod}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(Filter).Filter(ctx, req.(*FilterRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+type filterClient struct {
+	conn *grpc.ClientConn
+}
+
+func (c *filterClient) Filter(ctx context.Context, req *FilterRequest) (*FilterResponse, error) {
+	resp := new(FilterResponse)
+	err := c.conn.Invoke(ctx, filterMethod, req, resp, grpc.CallContentSubtype(jsonCodec{}.Name()))
+	if err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
+
+type jsonCodec struct{}
+
+func (jsonCodec) Marshal(v interface{}) ([]byte, error)      { return json.Marshal(v) }
+func (jsonCodec) Unmarshal(data []byte, v interface{}) error { return json.Unmarshal(data, v) }
+func (jsonCodec) Name() string                               { return "json" }
+
+func init() {
+	encoding.RegisterCodec(jsonCodec{})
+}
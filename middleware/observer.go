@@ -0,0 +1,118 @@
+package middleware
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	"code.cloudfoundry.org/gorouter/route"
+)
+
+// Attempt is one attempt of the round tripper to send a request to a backend
+// endpoint or to a route service
+type Attempt struct {
+	Request *http.Request
+	// Endpoint is the backend tried, or a placeholder without an address for
+	// route services
+	Endpoint *route.Endpoint
+	// Number counts the attempts of the request from 1
+	Number       int
+	RouteService bool
+}
+
+// AttemptObserver is told about every attempt of the round tripper, which
+// lets tracing, circuit breakers or custom metrics follow retries without
+// changes to the round tripper. Observers are called on the request's
+// goroutine and must not block; they must not change the request.
+type AttemptObserver interface {
+	Name() string
+	// BeforeAttempt is called just before the attempt is sent
+	BeforeAttempt(a Attempt)
+	// AfterAttempt is called once the response headers arrived or the
+	// attempt failed, d after it was sent
+	AfterAttempt(a Attempt, res *http.Response, err error, d time.Duration)
+}
+
+var (
+	observersLock sync.RWMutex
+	observers     = make(map[string]AttemptObserver)
+)
+
+// RegisterObserver makes an observer available to attempt_observers. It fails
+// when an observer with the same name is already registered.
+func RegisterObserver(o AttemptObserver) error {
+	if o.Name() == "" {
+		return fmt.Errorf("attempt observer has no name")
+	}
+
+	observersLock.Lock()
+	defer observersLock.Unlock()
+
+	if _, ok := observers[o.Name()]; ok {
+		return fmt.Errorf("attempt observer %s is already registered", o.Name())
+	}
+	observers[o.Name()] = o
+	return nil
+}
+
+// LookupObserver returns the registered observer called name
+func LookupObserver(name string) (AttemptObserver, bool) {
+	observersLock.RLock()
+	defer observersLock.RUnlock()
+
+	o, ok := observers[name]
+	return o, ok
+}
+
+// RegisteredObservers returns the names of all registered observers, sorted
+func RegisteredObservers() []string {
+	observersLock.RLock()
+	defer observersLock.RUnlock()
+
+	names := make([]string, 0, len(observers))
+	for name := range observers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// AttemptObservers are the observers enabled in attempt_observers, called in
+// the configured order
+type AttemptObservers []AttemptObserver
+
+// NewAttemptObservers returns the observers enabled by names. It fails when an
+// observer is not registered or is configured twice.
+func NewAttemptObservers(names []string) (AttemptObservers, error) {
+	var enabled AttemptObservers
+	seen := make(map[string]bool, len(names))
+
+	for _, name := range names {
+		o, ok := LookupObserver(name)
+		if !ok {
+			return nil, fmt.Errorf("attempt observer %s is not registered", name)
+		}
+		if seen[name] {
+			return nil, fmt.Errorf("attempt observer %s is configured more than once", name)
+		}
+		seen[name] = true
+		enabled = append(enabled, o)
+	}
+	return enabled, nil
+}
+
+// BeforeAttempt calls BeforeAttempt on every observer
+func (obs AttemptObservers) BeforeAttempt(a Attempt) {
+	for _, o := range obs {
+		o.BeforeAttempt(a)
+	}
+}
+
+// AfterAttempt calls AfterAttempt on every observer
+func (obs AttemptObservers) AfterAttempt(a Attempt, res *http.Response, err error, d time.Duration) {
+	for _, o := range obs {
+		o.AfterAttempt(a, res, err, d)
+	}
+}
@@ -0,0 +1,95 @@
+package middleware_test
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"code.cloudfoundry.org/gorouter/middleware"
+	"code.cloudfoundry.org/gorouter/route"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+type testObserver struct {
+	name  string
+	calls *[]string
+}
+
+func (o *testObserver) Name() string { return o.name }
+
+func (o *testObserver) BeforeAttempt(a middleware.Attempt) {
+	*o.calls = append(*o.calls, "before "+o.name)
+}
+
+func (o *testObserver) AfterAttempt(a middleware.Attempt, res *http.Response, err error, d time.Duration) {
+	*o.calls = append(*o.calls, "after "+o.name)
+}
+
+var _ = Describe("AttemptObservers", func() {
+	var calls []string
+
+	register := func(name string) *testObserver {
+		o := &testObserver{name: uniqueName(name), calls: &calls}
+		Expect(middleware.RegisterObserver(o)).To(Succeed())
+		return o
+	}
+
+	BeforeEach(func() {
+		calls = nil
+	})
+
+	It("calls the enabled observers in the configured order", func() {
+		first := register("first")
+		second := register("second")
+		register("disabled")
+
+		observers, err := middleware.NewAttemptObservers([]string{second.name, first.name})
+		Expect(err).NotTo(HaveOccurred())
+
+		attempt := middleware.Attempt{Endpoint: &route.Endpoint{ApplicationId: "app"}, Number: 1}
+		observers.BeforeAttempt(attempt)
+		observers.AfterAttempt(attempt, nil, errors.New("dial failed"), time.Millisecond)
+
+		Expect(calls).To(Equal([]string{
+			"before " + second.name, "before " + first.name,
+			"after " + second.name, "after " + first.name,
+		}))
+	})
+
+	It("does nothing without observers", func() {
+		observers, err := middleware.NewAttemptObservers(nil)
+		Expect(err).NotTo(HaveOccurred())
+
+		observers.BeforeAttempt(middleware.Attempt{})
+		observers.AfterAttempt(middleware.Attempt{}, nil, nil, 0)
+		Expect(calls).To(BeEmpty())
+	})
+
+	It("fails for observers that are not registered", func() {
+		_, err := middleware.NewAttemptObservers([]string{"missing"})
+		Expect(err).To(MatchError(ContainSubstring("not registered")))
+	})
+
+	It("fails for observers configured twice", func() {
+		o := register("twice")
+
+		_, err := middleware.NewAttemptObservers([]string{o.name, o.name})
+		Expect(err).To(MatchError(ContainSubstring("more than once")))
+	})
+})
+
+var _ = Describe("RegisterObserver", func() {
+	It("rejects duplicate names", func() {
+		o := &testObserver{name: uniqueName("duplicate")}
+		Expect(middleware.RegisterObserver(o)).To(Succeed())
+
+		Expect(middleware.RegisterObserver(o)).To(MatchError(ContainSubstring("already registered")))
+		Expect(middleware.RegisteredObservers()).To(ContainElement(o.name))
+	})
+
+	It("rejects observers without a name", func() {
+		Expect(middleware.RegisterObserver(&testObserver{})).To(HaveOccurred())
+	})
+})
@@ -0,0 +1,109 @@
+// Package middleware lets packages compiled into gorouter insert handlers into
+// the proxy's handler chain. A plugin registers itself, usually from an init
+// function, and runs once it is enabled in middleware_plugins.
+package middleware
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"sync"
+)
+
+// Stage is a point in the handler chain where plugins run
+type Stage int
+
+const (
+	// PreLookup runs before the route is looked up; no endpoint is known yet
+	PreLookup Stage = iota
+	// PostLookup runs once the route pool is known, before route services
+	PostLookup
+	// PreProxy runs just before the request is proxied to the route service
+	// or backend
+	PreProxy
+	// PostProxy runs after the response has been written to the client. The
+	// response can no longer be changed and next is a no-op.
+	PostProxy
+)
+
+var stageNames = map[Stage]string{
+	PreLookup:  "pre-lookup",
+	PostLookup: "post-lookup",
+	PreProxy:   "pre-proxy",
+	PostProxy:  "post-proxy",
+}
+
+func (s Stage) String() string {
+	if name, ok := stageNames[s]; ok {
+		return name
+	}
+	return fmt.Sprintf("stage(%d)", int(s))
+}
+
+// Plugin is a handler inserted at a stage of the handler chain. Within a stage
+// plugins run by ascending Order, and by name when orders are equal. A plugin
+// must call next to continue the chain unless it writes the response itself.
+type Plugin interface {
+	Name() string
+	Stage() Stage
+	Order() int
+	ServeHTTP(rw http.ResponseWriter, r *http.Request, next http.HandlerFunc)
+}
+
+var (
+	pluginsLock sync.RWMutex
+	plugins     = make(map[string]Plugin)
+)
+
+// Register makes a plugin available to middleware_plugins. It fails when a
+// plugin with the same name is already registered.
+func Register(p Plugin) error {
+	if p.Name() == "" {
+		return fmt.Errorf("middleware plugin has no name")
+	}
+	if _, ok := stageNames[p.Stage()]; !ok {
+		return fmt.Errorf("middleware plugin %s has unknown stage %s", p.Name(), p.Stage())
+	}
+
+	pluginsLock.Lock()
+	defer pluginsLock.Unlock()
+
+	if _, ok := plugins[p.Name()]; ok {
+		return fmt.Errorf("middleware plugin %s is already registered", p.Name())
+	}
+	plugins[p.Name()] = p
+	return nil
+}
+
+// Unregister removes p so that it can no longer be enabled. A plugin of the
+// same name registered by someone else is left alone.
+func Unregister(p Plugin) {
+	pluginsLock.Lock()
+	defer pluginsLock.Unlock()
+
+	if registered, ok := plugins[p.Name()]; ok && registered == p {
+		delete(plugins, p.Name())
+	}
+}
+
+// Lookup returns the registered plugin called name
+func Lookup(name string) (Plugin, bool) {
+	pluginsLock.RLock()
+	defer pluginsLock.RUnlock()
+
+	p, ok := plugins[name]
+	return p, ok
+}
+
+// Registered returns the names of all registered plugins, sorted
+func Registered() []string {
+	pluginsLock.RLock()
+	defer pluginsLock.RUnlock()
+
+	names := make([]string, 0, len(plugins))
+	for name := range plugins {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
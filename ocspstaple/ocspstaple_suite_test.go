@@ -0,0 +1,141 @@
+package ocspstaple_test
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"io/ioutil"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"time"
+
+	"golang.org/x/crypto/ocsp"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"testing"
+)
+
+func TestOCSPStaple(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "OCSPStaple Suite")
+}
+
+// pki is a CA with a certificate it issued, and an OCSP responder for it
+type pki struct {
+	ca       *x509.Certificate
+	caKey    crypto.Signer
+	leaf     *x509.Certificate
+	cert     tls.Certificate
+	server   *httptest.Server
+	lock     sync.Mutex
+	template ocsp.Response
+	requests int
+	fail     bool
+}
+
+func newPKI() *pki {
+	p := &pki{}
+	p.server = httptest.NewServer(http.HandlerFunc(p.respond))
+
+	caKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	Expect(err).ToNot(HaveOccurred())
+	caTemplate := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "Test CA"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(24 * time.Hour),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageCertSign | x509.KeyUsageDigitalSignature,
+	}
+	caDER, err := x509.CreateCertificate(rand.Reader, caTemplate, caTemplate, &caKey.PublicKey, caKey)
+	Expect(err).ToNot(HaveOccurred())
+	p.ca, err = x509.ParseCertificate(caDER)
+	Expect(err).ToNot(HaveOccurred())
+	p.caKey = caKey
+
+	leafKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	Expect(err).ToNot(HaveOccurred())
+	leafTemplate := &x509.Certificate{
+		SerialNumber: big.NewInt(2),
+		Subject:      pkix.Name{CommonName: "router.example.com"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(24 * time.Hour),
+		OCSPServer:   []string{p.server.URL},
+	}
+	leafDER, err := x509.CreateCertificate(rand.Reader, leafTemplate, p.ca, &leafKey.PublicKey, caKey)
+	Expect(err).ToNot(HaveOccurred())
+	p.leaf, err = x509.ParseCertificate(leafDER)
+	Expect(err).ToNot(HaveOccurred())
+
+	p.cert = tls.Certificate{Certificate: [][]byte{leafDER, caDER}, PrivateKey: leafKey}
+	p.setResponse(ocsp.Good, time.Hour)
+	return p
+}
+
+// setResponse makes the responder answer with status, valid for validity
+func (p *pki) setResponse(status int, validity time.Duration) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	p.template = ocsp.Response{
+		Status:       status,
+		SerialNumber: p.leaf.SerialNumber,
+		ThisUpdate:   time.Now().Add(-time.Minute),
+		NextUpdate:   time.Now().Add(validity),
+	}
+	if status == ocsp.Revoked {
+		p.template.RevokedAt = time.Now().Add(-time.Minute)
+	}
+}
+
+func (p *pki) setFailing(fail bool) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	p.fail = fail
+}
+
+func (p *pki) requestCount() int {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	return p.requests
+}
+
+func (p *pki) response() []byte {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	resp, err := ocsp.CreateResponse(p.ca, p.ca, p.template, p.caKey)
+	Expect(err).ToNot(HaveOccurred())
+	return resp
+}
+
+func (p *pki) respond(w http.ResponseWriter, r *http.Request) {
+	defer GinkgoRecover()
+
+	body, err := ioutil.ReadAll(r.Body)
+	Expect(err).ToNot(HaveOccurred())
+	req, err := ocsp.ParseRequest(body)
+	Expect(err).ToNot(HaveOccurred())
+	Expect(req.SerialNumber).To(Equal(p.leaf.SerialNumber))
+
+	p.lock.Lock()
+	p.requests++
+	fail := p.fail
+	p.lock.Unlock()
+
+	if fail {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/ocsp-response")
+	w.Write(p.response())
+}
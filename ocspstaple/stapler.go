@@ -0,0 +1,291 @@
+// Package ocspstaple keeps an OCSP response for the router's certificate to
+// staple to TLS handshakes.
+package ocspstaple
+
+import (
+	"bytes"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/uber-go/zap"
+	"golang.org/x/crypto/ocsp"
+
+	"code.cloudfoundry.org/gorouter/config"
+	"code.cloudfoundry.org/gorouter/logger"
+	"code.cloudfoundry.org/gorouter/metrics"
+)
+
+const (
+	// maxResponseBytes bounds the responses read from a responder
+	maxResponseBytes = 1024 * 1024
+	// defaultRefreshInterval refreshes responses that carry no next update
+	defaultRefreshInterval = time.Hour
+	// reportInterval is how often the validity of the staple is emitted
+	reportInterval = time.Minute
+)
+
+// Stapler fetches OCSP responses for a certificate and serves the certificate
+// with the latest good response stapled. A response is stapled until it
+// expires, even when refreshing it fails.
+type Stapler struct {
+	cert      tls.Certificate
+	leaf      *x509.Certificate
+	issuer    *x509.Certificate
+	responder string
+	cacheFile string
+	retry     time.Duration
+	client    *http.Client
+	reporter  metrics.OCSPReporter
+	logger    logger.Logger
+
+	lock       sync.RWMutex
+	stapled    *tls.Certificate
+	nextUpdate time.Time
+	refreshAt  time.Time
+}
+
+// NewStapler creates a stapler for cert, whose chain must include its issuer.
+// A response cached by an earlier run is stapled straight away when it is
+// still valid; fetching waits for Run.
+func NewStapler(cert tls.Certificate, c config.OCSPStaplingConfig, reporter metrics.OCSPReporter, logger logger.Logger) (*Stapler, error) {
+	if len(cert.Certificate) < 2 {
+		return nil, errors.New("ocsp stapling requires the issuer in the certificate chain")
+	}
+	leaf, err := x509.ParseCertificate(cert.Certificate[0])
+	if err != nil {
+		return nil, err
+	}
+	issuer, err := x509.ParseCertificate(cert.Certificate[1])
+	if err != nil {
+		return nil, err
+	}
+
+	responder := c.ResponderURL
+	if responder == "" {
+		if len(leaf.OCSPServer) == 0 {
+			return nil, errors.New("certificate names no ocsp server, set a responder url")
+		}
+		responder = leaf.OCSPServer[0]
+	}
+
+	s := &Stapler{
+		cert:      cert,
+		leaf:      leaf,
+		issuer:    issuer,
+		responder: responder,
+		cacheFile: c.CacheFile,
+		retry:     c.RetryInterval,
+		client:    &http.Client{Timeout: c.Timeout},
+		reporter:  reporter,
+		logger:    logger,
+	}
+	s.stapled = &s.cert
+	s.loadCache()
+	return s, nil
+}
+
+// GetCertificate returns the certificate with the current response stapled,
+// for use as tls.Config.GetCertificate
+func (s *Stapler) GetCertificate(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+
+	if s.stapled.OCSPStaple != nil && !time.Now().Before(s.nextUpdate) {
+		return &s.cert, nil
+	}
+	return s.stapled, nil
+}
+
+// Refresh fetches a new response and staples it when it is good
+func (s *Stapler) Refresh() error {
+	raw, resp, err := s.fetch()
+	if err != nil {
+		s.reporter.CaptureOCSPFetchFailure()
+		s.lock.Lock()
+		s.refreshAt = time.Now().Add(s.retry)
+		s.lock.Unlock()
+		return err
+	}
+
+	s.staple(raw, resp)
+	if s.cacheFile != "" {
+		if err := writeFile(s.cacheFile, raw); err != nil {
+			s.logger.Error("ocsp-cache-write-failed", zap.String("path", s.cacheFile), zap.Error(err))
+		}
+	}
+
+	s.logger.Info("ocsp-response-stapled",
+		zap.String("this_update", resp.ThisUpdate.Format(time.RFC3339)),
+		zap.String("next_update", resp.NextUpdate.Format(time.RFC3339)),
+	)
+	return nil
+}
+
+// Run refreshes the response when it is half way through its validity, or
+// every retry interval while fetching fails, until signaled
+func (s *Stapler) Run(signals <-chan os.Signal, ready chan<- struct{}) error {
+	close(ready)
+
+	report := time.NewTicker(reportInterval)
+	defer report.Stop()
+
+	refresh := time.NewTimer(s.untilRefresh())
+	defer refresh.Stop()
+
+	for {
+		select {
+		case <-refresh.C:
+			if err := s.Refresh(); err != nil {
+				s.logger.Error("ocsp-refresh-failed", zap.String("responder", s.responder), zap.Error(err))
+			}
+			s.reportValidity()
+			refresh.Reset(s.untilRefresh())
+		case <-report.C:
+			s.reportValidity()
+		case <-signals:
+			return nil
+		}
+	}
+}
+
+func (s *Stapler) fetch() ([]byte, *ocsp.Response, error) {
+	req, err := ocsp.CreateRequest(s.leaf, s.issuer, nil)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	httpResp, err := s.client.Post(s.responder, "application/ocsp-request", bytes.NewReader(req))
+	if err != nil {
+		return nil, nil, err
+	}
+	defer httpResp.Body.Close()
+
+	if httpResp.StatusCode != http.StatusOK {
+		return nil, nil, fmt.Errorf("ocsp responder returned %d", httpResp.StatusCode)
+	}
+	raw, err := ioutil.ReadAll(io.LimitReader(httpResp.Body, maxResponseBytes))
+	if err != nil {
+		return nil, nil, err
+	}
+
+	resp, err := s.parse(raw)
+	if err != nil {
+		return nil, nil, err
+	}
+	return raw, resp, nil
+}
+
+// parse checks that raw is a current, good response for the certificate
+func (s *Stapler) parse(raw []byte) (*ocsp.Response, error) {
+	resp, err := ocsp.ParseResponseForCert(raw, s.leaf, s.issuer)
+	if err != nil {
+		return nil, err
+	}
+
+	switch resp.Status {
+	case ocsp.Good:
+	case ocsp.Revoked:
+		return nil, fmt.Errorf("certificate was revoked at %s", resp.RevokedAt.Format(time.RFC3339))
+	default:
+		return nil, errors.New("ocsp responder does not know the certificate")
+	}
+
+	if !resp.NextUpdate.IsZero() && !time.Now().Before(resp.NextUpdate) {
+		return nil, errors.New("ocsp response has expired")
+	}
+	return resp, nil
+}
+
+func (s *Stapler) staple(raw []byte, resp *ocsp.Response) {
+	stapled := s.cert
+	stapled.OCSPStaple = raw
+
+	// a response without a next update is valid until a newer one is fetched
+	nextUpdate := resp.NextUpdate
+	refreshAt := resp.ThisUpdate.Add(resp.NextUpdate.Sub(resp.ThisUpdate) / 2)
+	if nextUpdate.IsZero() {
+		nextUpdate = time.Now().Add(defaultRefreshInterval + s.retry)
+		refreshAt = time.Now().Add(defaultRefreshInterval)
+	}
+
+	s.lock.Lock()
+	s.stapled = &stapled
+	s.nextUpdate = nextUpdate
+	s.refreshAt = refreshAt
+	s.lock.Unlock()
+}
+
+func (s *Stapler) loadCache() {
+	if s.cacheFile == "" {
+		return
+	}
+
+	raw, err := ioutil.ReadFile(s.cacheFile)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			s.logger.Error("ocsp-cache-read-failed", zap.String("path", s.cacheFile), zap.Error(err))
+		}
+		return
+	}
+
+	resp, err := s.parse(raw)
+	if err != nil {
+		s.logger.Info("ocsp-cache-discarded", zap.String("path", s.cacheFile), zap.Error(err))
+		return
+	}
+	s.staple(raw, resp)
+}
+
+// untilRefresh returns how long to wait before the next refresh
+func (s *Stapler) untilRefresh() time.Duration {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+
+	wait := s.refreshAt.Sub(time.Now())
+	if wait < 0 {
+		return 0
+	}
+	return wait
+}
+
+func (s *Stapler) reportValidity() {
+	s.lock.RLock()
+	remaining := time.Duration(0)
+	if s.stapled.OCSPStaple != nil {
+		remaining = s.nextUpdate.Sub(time.Now())
+	}
+	s.lock.RUnlock()
+
+	if remaining < 0 {
+		remaining = 0
+	}
+	s.reporter.CaptureOCSPStapleValidity(remaining)
+}
+
+// writeFile replaces path with contents without ever leaving it partly
+// written
+func writeFile(path string, contents []byte) error {
+	tmp, err := ioutil.TempFile(filepath.Dir(path), filepath.Base(path))
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(contents); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
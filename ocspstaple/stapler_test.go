@@ -0,0 +1,143 @@
+package ocspstaple_test
+
+import (
+	"crypto/tls"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"time"
+
+	"golang.org/x/crypto/ocsp"
+
+	"code.cloudfoundry.org/gorouter/config"
+	"code.cloudfoundry.org/gorouter/metrics/fakes"
+	"code.cloudfoundry.org/gorouter/ocspstaple"
+	"code.cloudfoundry.org/gorouter/test_util"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/tedsuo/ifrit"
+)
+
+var _ = Describe("Stapler", func() {
+	var (
+		p        *pki
+		cfg      config.OCSPStaplingConfig
+		reporter *fakes.FakeOCSPReporter
+		dir      string
+	)
+
+	newStapler := func() *ocspstaple.Stapler {
+		stapler, err := ocspstaple.NewStapler(p.cert, cfg, reporter, test_util.NewTestZapLogger("ocsp"))
+		Expect(err).ToNot(HaveOccurred())
+		return stapler
+	}
+
+	staple := func(stapler *ocspstaple.Stapler) []byte {
+		cert, err := stapler.GetCertificate(&tls.ClientHelloInfo{})
+		Expect(err).ToNot(HaveOccurred())
+		return cert.OCSPStaple
+	}
+
+	BeforeEach(func() {
+		p = newPKI()
+		reporter = new(fakes.FakeOCSPReporter)
+
+		var err error
+		dir, err = ioutil.TempDir("", "ocsp")
+		Expect(err).ToNot(HaveOccurred())
+
+		cfg = config.OCSPStaplingConfig{
+			Enabled:       true,
+			CacheFile:     filepath.Join(dir, "staple.der"),
+			RetryInterval: time.Minute,
+			Timeout:       time.Second,
+		}
+	})
+
+	AfterEach(func() {
+		p.server.Close()
+		os.RemoveAll(dir)
+	})
+
+	It("requires the issuer in the certificate chain", func() {
+		p.cert.Certificate = p.cert.Certificate[:1]
+		_, err := ocspstaple.NewStapler(p.cert, cfg, reporter, test_util.NewTestZapLogger("ocsp"))
+		Expect(err).To(HaveOccurred())
+	})
+
+	It("staples a good response and caches it", func() {
+		stapler := newStapler()
+		Expect(staple(stapler)).To(BeNil())
+
+		Expect(stapler.Refresh()).To(Succeed())
+		stapled := staple(stapler)
+		resp, err := ocsp.ParseResponseForCert(stapled, p.leaf, p.ca)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(resp.Status).To(Equal(ocsp.Good))
+
+		cached, err := ioutil.ReadFile(cfg.CacheFile)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(cached).To(Equal(stapled))
+	})
+
+	It("staples a cached response after a restart without fetching", func() {
+		Expect(newStapler().Refresh()).To(Succeed())
+		Expect(p.requestCount()).To(Equal(1))
+
+		stapler := newStapler()
+		Expect(staple(stapler)).ToNot(BeNil())
+		Expect(p.requestCount()).To(Equal(1))
+	})
+
+	It("discards an expired cached response", func() {
+		p.setResponse(ocsp.Good, time.Second)
+		Expect(newStapler().Refresh()).To(Succeed())
+
+		time.Sleep(1100 * time.Millisecond)
+		Expect(staple(newStapler())).To(BeNil())
+	})
+
+	It("keeps the previous response when a refresh fails", func() {
+		stapler := newStapler()
+		Expect(stapler.Refresh()).To(Succeed())
+		stapled := staple(stapler)
+
+		p.setFailing(true)
+		Expect(stapler.Refresh()).ToNot(Succeed())
+		Expect(reporter.CaptureOCSPFetchFailureCallCount()).To(Equal(1))
+		Expect(staple(stapler)).To(Equal(stapled))
+	})
+
+	It("does not staple a revoked response", func() {
+		p.setResponse(ocsp.Revoked, time.Hour)
+		stapler := newStapler()
+
+		Expect(stapler.Refresh()).To(MatchError(ContainSubstring("revoked")))
+		Expect(reporter.CaptureOCSPFetchFailureCallCount()).To(Equal(1))
+		Expect(staple(stapler)).To(BeNil())
+	})
+
+	It("stops stapling a response once it expires", func() {
+		p.setResponse(ocsp.Good, time.Second)
+		stapler := newStapler()
+		Expect(stapler.Refresh()).To(Succeed())
+		Expect(staple(stapler)).ToNot(BeNil())
+
+		time.Sleep(1100 * time.Millisecond)
+		Expect(staple(stapler)).To(BeNil())
+	})
+
+	It("fetches a response when run and reports its validity", func() {
+		stapler := newStapler()
+		process := ifrit.Invoke(stapler)
+		defer func() {
+			process.Signal(os.Interrupt)
+			Eventually(process.Wait()).Should(Receive())
+		}()
+
+		Eventually(func() []byte { return staple(stapler) }).ShouldNot(BeNil())
+		Eventually(reporter.CaptureOCSPStapleValidityCallCount).Should(Equal(1))
+		Expect(reporter.CaptureOCSPStapleValidityArgsForCall(0)).To(BeNumerically("~", time.Hour, time.Minute))
+	})
+})
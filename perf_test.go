@@ -34,7 +34,7 @@ var _ = Describe("AccessLogRecord", func() {
 		Expect(err).ToNot(HaveOccurred())
 
 		proxy.NewProxy(logger, accesslog, c, r, combinedReporter, &routeservice.RouteServiceConfig{},
-			&tls.Config{}, nil)
+			&tls.Config{}, nil, nil, nil)
 
 		b.Time("RegisterTime", func() {
 			for i := 0; i < 1000; i++ {
@@ -0,0 +1,157 @@
+package dnscache
+
+import (
+	"net"
+	"sync"
+	"time"
+
+	"github.com/cloudfoundry/dropsonde/metrics"
+)
+
+// LookupFunc resolves a host name to its addresses, like net.LookupHost
+type LookupFunc func(host string) ([]string, error)
+
+// Cache keeps the addresses of the host names the proxy dials, such as route
+// service hosts and backends registered by host name. The system resolver
+// does not expose the TTLs of the records it returns, so addresses are kept
+// for ttl and failed lookups for negativeTTL. Concurrent lookups of a host
+// share a single query to the resolver.
+type Cache struct {
+	lock        sync.Mutex
+	entries     map[string]*entry
+	ttl         time.Duration
+	negativeTTL time.Duration
+	maxEntries  int
+	lookup      LookupFunc
+}
+
+type entry struct {
+	// closed once the lookup completed and the fields below are set
+	ready   chan struct{}
+	addrs   []string
+	err     error
+	expires time.Time
+}
+
+func (e *entry) resolved() bool {
+	select {
+	case <-e.ready:
+		return true
+	default:
+		return false
+	}
+}
+
+func New(ttl, negativeTTL time.Duration, maxEntries int, lookup LookupFunc) *Cache {
+	return &Cache{
+		entries:     make(map[string]*entry),
+		ttl:         ttl,
+		negativeTTL: negativeTTL,
+		maxEntries:  maxEntries,
+		lookup:      lookup,
+	}
+}
+
+// Lookup returns the addresses of host, from the cache while they are fresh
+func (c *Cache) Lookup(host string) ([]string, error) {
+	c.lock.Lock()
+	e, ok := c.entries[host]
+	if ok && !e.resolved() {
+		c.lock.Unlock()
+		<-e.ready
+		metrics.BatchIncrementCounter("dns_cache.hits")
+		return e.addrs, e.err
+	}
+	if ok && time.Now().Before(e.expires) {
+		c.lock.Unlock()
+		if e.err != nil {
+			metrics.BatchIncrementCounter("dns_cache.negative_hits")
+		} else {
+			metrics.BatchIncrementCounter("dns_cache.hits")
+		}
+		return e.addrs, e.err
+	}
+
+	if !ok && len(c.entries) >= c.maxEntries {
+		c.evictExpired()
+	}
+	if !ok && len(c.entries) >= c.maxEntries {
+		c.lock.Unlock()
+		metrics.BatchIncrementCounter("dns_cache.misses")
+		return c.resolve(host)
+	}
+
+	e = &entry{ready: make(chan struct{})}
+	c.entries[host] = e
+	c.lock.Unlock()
+
+	metrics.BatchIncrementCounter("dns_cache.misses")
+	e.addrs, e.err = c.resolve(host)
+	if e.err != nil {
+		e.expires = time.Now().Add(c.negativeTTL)
+	} else {
+		e.expires = time.Now().Add(c.ttl)
+	}
+	close(e.ready)
+
+	return e.addrs, e.err
+}
+
+// Invalidate forgets the addresses of host, so that the next dial resolves it
+// again
+func (c *Cache) Invalidate(host string) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	if e, ok := c.entries[host]; ok && e.resolved() {
+		delete(c.entries, host)
+	}
+}
+
+// Dial wraps dial so that host names are resolved through the cache. The
+// addresses of a host are tried in turn; when none accepts the connection the
+// host is resolved again on the next dial.
+func (c *Cache) Dial(dial func(network, addr string) (net.Conn, error)) func(network, addr string) (net.Conn, error) {
+	return func(network, addr string) (net.Conn, error) {
+		host, port, err := net.SplitHostPort(addr)
+		if err != nil || net.ParseIP(host) != nil {
+			return dial(network, addr)
+		}
+
+		addrs, err := c.Lookup(host)
+		if err != nil {
+			return nil, err
+		}
+
+		var conn net.Conn
+		for _, ip := range addrs {
+			conn, err = dial(network, net.JoinHostPort(ip, port))
+			if err == nil {
+				return conn, nil
+			}
+		}
+		c.Invalidate(host)
+		return nil, err
+	}
+}
+
+func (c *Cache) resolve(host string) ([]string, error) {
+	addrs, err := c.lookup(host)
+	if err == nil && len(addrs) == 0 {
+		err = &net.DNSError{Err: "no such host", Name: host}
+	}
+	if err != nil {
+		metrics.BatchIncrementCounter("dns_cache.lookup_failures")
+	}
+	return addrs, err
+}
+
+// evictExpired drops the expired entries. The caller holds the lock.
+func (c *Cache) evictExpired() {
+	now := time.Now()
+	for host, e := range c.entries {
+		if e.resolved() && now.After(e.expires) {
+			delete(c.entries, host)
+		}
+	}
+}
@@ -0,0 +1,23 @@
+package dnscache_test
+
+import (
+	"code.cloudfoundry.org/gorouter/metrics/fakes"
+
+	"github.com/cloudfoundry/dropsonde/metrics"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"testing"
+)
+
+func TestDNSCache(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "DNSCache Suite")
+}
+
+var fakeBatcher *fakes.MetricBatcher
+
+var _ = BeforeEach(func() {
+	fakeBatcher = new(fakes.MetricBatcher)
+	metrics.Initialize(nil, fakeBatcher)
+})
@@ -0,0 +1,186 @@
+package dnscache_test
+
+import (
+	"errors"
+	"net"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"code.cloudfoundry.org/gorouter/proxy/dnscache"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Cache", func() {
+	var (
+		cache      *dnscache.Cache
+		lookups    int32
+		lookupErr  error
+		addrs      []string
+		ttl        time.Duration
+		maxEntries int
+	)
+
+	counted := func(name string) int {
+		count := 0
+		for i := 0; i < fakeBatcher.BatchIncrementCounterCallCount(); i++ {
+			if fakeBatcher.BatchIncrementCounterArgsForCall(i) == name {
+				count++
+			}
+		}
+		return count
+	}
+
+	BeforeEach(func() {
+		atomic.StoreInt32(&lookups, 0)
+		lookupErr = nil
+		addrs = []string{"10.0.0.1", "10.0.0.2"}
+		ttl = time.Minute
+		maxEntries = 10
+	})
+
+	JustBeforeEach(func() {
+		cache = dnscache.New(ttl, time.Minute, maxEntries, func(host string) ([]string, error) {
+			atomic.AddInt32(&lookups, 1)
+			return addrs, lookupErr
+		})
+	})
+
+	It("resolves a host once while its addresses are fresh", func() {
+		for i := 0; i < 3; i++ {
+			resolved, err := cache.Lookup("rs.example.com")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(resolved).To(Equal([]string{"10.0.0.1", "10.0.0.2"}))
+		}
+
+		Expect(atomic.LoadInt32(&lookups)).To(BeEquivalentTo(1))
+		Expect(counted("dns_cache.misses")).To(Equal(1))
+		Expect(counted("dns_cache.hits")).To(Equal(2))
+	})
+
+	Context("when the addresses expire", func() {
+		BeforeEach(func() {
+			ttl = time.Millisecond
+		})
+
+		It("resolves the host again", func() {
+			_, err := cache.Lookup("rs.example.com")
+			Expect(err).NotTo(HaveOccurred())
+			time.Sleep(5 * time.Millisecond)
+			_, err = cache.Lookup("rs.example.com")
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(atomic.LoadInt32(&lookups)).To(BeEquivalentTo(2))
+		})
+	})
+
+	Context("when the lookup fails", func() {
+		BeforeEach(func() {
+			lookupErr = errors.New("no such host")
+		})
+
+		It("caches the failure", func() {
+			_, err := cache.Lookup("rs.example.com")
+			Expect(err).To(MatchError("no such host"))
+			_, err = cache.Lookup("rs.example.com")
+			Expect(err).To(MatchError("no such host"))
+
+			Expect(atomic.LoadInt32(&lookups)).To(BeEquivalentTo(1))
+			Expect(counted("dns_cache.lookup_failures")).To(Equal(1))
+			Expect(counted("dns_cache.negative_hits")).To(Equal(1))
+		})
+	})
+
+	It("shares concurrent lookups of a host", func() {
+		release := make(chan struct{})
+		cache = dnscache.New(time.Minute, time.Minute, 10, func(host string) ([]string, error) {
+			atomic.AddInt32(&lookups, 1)
+			<-release
+			return addrs, nil
+		})
+
+		var wg sync.WaitGroup
+		for i := 0; i < 5; i++ {
+			wg.Add(1)
+			go func() {
+				defer GinkgoRecover()
+				defer wg.Done()
+				_, err := cache.Lookup("rs.example.com")
+				Expect(err).NotTo(HaveOccurred())
+			}()
+		}
+		Eventually(func() int32 { return atomic.LoadInt32(&lookups) }).Should(BeEquivalentTo(1))
+		close(release)
+		wg.Wait()
+
+		Expect(atomic.LoadInt32(&lookups)).To(BeEquivalentTo(1))
+	})
+
+	Context("when the cache is full", func() {
+		BeforeEach(func() {
+			maxEntries = 1
+		})
+
+		It("resolves other hosts without caching them", func() {
+			_, err := cache.Lookup("one.example.com")
+			Expect(err).NotTo(HaveOccurred())
+			_, err = cache.Lookup("two.example.com")
+			Expect(err).NotTo(HaveOccurred())
+			_, err = cache.Lookup("two.example.com")
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(atomic.LoadInt32(&lookups)).To(BeEquivalentTo(3))
+		})
+	})
+
+	Describe("Dial", func() {
+		var dialed []string
+
+		dial := func(network, addr string) (net.Conn, error) {
+			dialed = append(dialed, addr)
+			if addr == "10.0.0.1:443" {
+				return nil, errors.New("connection refused")
+			}
+			client, _ := net.Pipe()
+			return client, nil
+		}
+
+		BeforeEach(func() {
+			dialed = nil
+		})
+
+		It("dials the resolved addresses in turn", func() {
+			conn, err := cache.Dial(dial)("tcp", "rs.example.com:443")
+			Expect(err).NotTo(HaveOccurred())
+			conn.Close()
+
+			Expect(dialed).To(Equal([]string{"10.0.0.1:443", "10.0.0.2:443"}))
+		})
+
+		It("dials IP addresses directly", func() {
+			conn, err := cache.Dial(dial)("tcp", "10.0.0.3:8080")
+			Expect(err).NotTo(HaveOccurred())
+			conn.Close()
+
+			Expect(dialed).To(Equal([]string{"10.0.0.3:8080"}))
+			Expect(atomic.LoadInt32(&lookups)).To(BeEquivalentTo(0))
+		})
+
+		Context("when no address accepts the connection", func() {
+			BeforeEach(func() {
+				addrs = []string{"10.0.0.1"}
+			})
+
+			It("resolves the host again on the next dial", func() {
+				_, err := cache.Dial(dial)("tcp", "rs.example.com:443")
+				Expect(err).To(MatchError("connection refused"))
+				_, err = cache.Dial(dial)("tcp", "rs.example.com:443")
+				Expect(err).To(HaveOccurred())
+
+				Expect(atomic.LoadInt32(&lookups)).To(BeEquivalentTo(2))
+			})
+		})
+	})
+})
@@ -0,0 +1,58 @@
+package handler
+
+import (
+	"net"
+	"net/http"
+	"strings"
+
+	"code.cloudfoundry.org/gorouter/config"
+	"code.cloudfoundry.org/gorouter/route"
+)
+
+// NewEndpointIterator selects the endpoint iterator for the configured load
+// balancing algorithm. The consistent-hash algorithm needs the request to
+// derive its key, which is why this does not live on route.Pool.
+func NewEndpointIterator(
+	pool *route.Pool,
+	request *http.Request,
+	loadBalance string,
+	hashConfig config.ConsistentHashConfig,
+	stickyEndpointID string,
+) route.EndpointIterator {
+	if loadBalance == config.LOAD_BALANCE_CH {
+		key := ConsistentHashKey(request, hashConfig)
+		return route.NewConsistentHash(pool, stickyEndpointID, key, hashConfig.Replicas)
+	}
+
+	return pool.Endpoints(loadBalance, stickyEndpointID)
+}
+
+// ConsistentHashKey returns the value of the configured header, falling back to
+// the configured cookie and finally to the client IP.
+func ConsistentHashKey(request *http.Request, hashConfig config.ConsistentHashConfig) string {
+	if hashConfig.Header != "" {
+		if v := request.Header.Get(hashConfig.Header); v != "" {
+			return v
+		}
+	}
+
+	if hashConfig.Cookie != "" {
+		if c, err := request.Cookie(hashConfig.Cookie); err == nil && c.Value != "" {
+			return c.Value
+		}
+	}
+
+	return clientIP(request)
+}
+
+func clientIP(request *http.Request) string {
+	if xff := request.Header.Get("X-Forwarded-For"); xff != "" {
+		return strings.TrimSpace(strings.Split(xff, ",")[0])
+	}
+
+	host, _, err := net.SplitHostPort(request.RemoteAddr)
+	if err != nil {
+		return request.RemoteAddr
+	}
+	return host
+}
@@ -2,7 +2,6 @@ package handler
 
 import (
 	"bufio"
-	"errors"
 	"fmt"
 	"io"
 	"net"
@@ -16,6 +15,7 @@ import (
 	"code.cloudfoundry.org/gorouter/metrics"
 	"code.cloudfoundry.org/gorouter/proxy/utils"
 	"code.cloudfoundry.org/gorouter/route"
+	"code.cloudfoundry.org/gorouter/routererr"
 	"github.com/uber-go/zap"
 )
 
@@ -23,7 +23,7 @@ const (
 	MaxRetries = 3
 )
 
-var NoEndpointsAvailable = errors.New("No endpoints available")
+var NoEndpointsAvailable error = routererr.ErrNoEndpoints
 
 type RequestHandler struct {
 	logger   logger.Logger
@@ -60,8 +60,9 @@ func (h *RequestHandler) Logger() logger.Logger {
 
 func (h *RequestHandler) HandleBadGateway(err error, request *http.Request) {
 	h.reporter.CaptureBadGateway()
+	h.reporter.CaptureRouterError(routererr.Name(err))
 
-	h.response.Header().Set("X-Cf-RouterError", "endpoint_failure")
+	h.response.Header().Set("X-Cf-RouterError", routererr.RouterError(err))
 	h.writeStatus(http.StatusBadGateway, "Registered endpoint failed to handle the request.")
 	h.response.Done()
 }
@@ -1,6 +1,7 @@
 package proxy
 
 import (
+	"context"
 	"crypto/tls"
 	"errors"
 	"net"
@@ -11,13 +12,17 @@ import (
 	"time"
 
 	"code.cloudfoundry.org/gorouter/access_log"
+	"code.cloudfoundry.org/gorouter/capture"
 	router_http "code.cloudfoundry.org/gorouter/common/http"
 	"code.cloudfoundry.org/gorouter/config"
 	"code.cloudfoundry.org/gorouter/handlers"
 	"code.cloudfoundry.org/gorouter/logger"
 	"code.cloudfoundry.org/gorouter/metrics"
+	"code.cloudfoundry.org/gorouter/middleware"
+	"code.cloudfoundry.org/gorouter/proxy/dnscache"
 	"code.cloudfoundry.org/gorouter/proxy/handler"
 	"code.cloudfoundry.org/gorouter/proxy/round_tripper"
+	"code.cloudfoundry.org/gorouter/proxy/upstream"
 	"code.cloudfoundry.org/gorouter/proxy/utils"
 	"code.cloudfoundry.org/gorouter/registry"
 	"code.cloudfoundry.org/gorouter/route"
@@ -35,6 +40,14 @@ type Proxy interface {
 	ServeHTTP(responseWriter http.ResponseWriter, request *http.Request)
 }
 
+type addressChangeNotifier interface {
+	OnAddressChange(registry.AddressChangeHandler)
+}
+
+type departureNotifier interface {
+	OnEndpointDeparted(registry.EndpointDepartedHandler)
+}
+
 type proxy struct {
 	ip                       string
 	traceKey                 string
@@ -47,6 +60,12 @@ type proxy struct {
 	healthCheckUserAgent     string
 	forceForwardedProtoHttps bool
 	defaultLoadBalance       string
+	consistentHash           config.ConsistentHashConfig
+	routeServiceResponses    config.RouteServiceResponsesConfig
+	errorPages               config.ErrorPagesConfig
+	retries                  config.RetriesConfig
+	attemptObservers         middleware.AttemptObservers
+	altSvc                   config.AltSvcConfig
 	bufferPool               httputil.BufferPool
 }
 
@@ -59,6 +78,9 @@ func NewProxy(
 	routeServiceConfig *routeservice.RouteServiceConfig,
 	tlsConfig *tls.Config,
 	heartbeatOK *int32,
+	captureRecorder *capture.Recorder,
+	inFlight *metrics.InFlightTracker,
+	extraHandlers ...negroni.Handler,
 ) Proxy {
 
 	p := &proxy{
@@ -73,49 +95,170 @@ func NewProxy(
 		healthCheckUserAgent:     c.HealthCheckUserAgent,
 		forceForwardedProtoHttps: c.ForceForwardedProtoHttps,
 		defaultLoadBalance:       c.LoadBalance,
-		bufferPool:               NewBufferPool(),
+		consistentHash:           c.ConsistentHash,
+		routeServiceResponses:    c.RouteServiceResponses,
+		errorPages:               c.ErrorPages,
+		retries:                  c.Retries,
+		altSvc:                   c.AltSvc,
+		bufferPool:               NewBufferPool(c.ResponseStreaming.BufferSize),
 	}
 
-	httpTransport := &http.Transport{
-		Dial: func(network, addr string) (net.Conn, error) {
-			conn, err := net.DialTimeout(network, addr, 5*time.Second)
-			if err != nil {
-				return conn, err
-			}
-			if c.EndpointTimeout > 0 {
-				err = conn.SetDeadline(time.Now().Add(c.EndpointTimeout))
-			}
+	observers, err := middleware.NewAttemptObservers(c.AttemptObservers)
+	if err != nil {
+		logger.Error("attempt-observers-disabled", zap.Error(err))
+	}
+	p.attemptObservers = observers
+
+	dial := func(network, addr string) (net.Conn, error) {
+		return net.DialTimeout(network, addr, 5*time.Second)
+	}
+	if c.DNSCache.Enabled {
+		dial = dnscache.New(c.DNSCache.TTL, c.DNSCache.NegativeTTL, c.DNSCache.MaxEntries, net.LookupHost).Dial(dial)
+	}
+	upstreamProxies, err := upstream.NewProxies(c.UpstreamProxies, dial)
+	if err != nil {
+		logger.Error("upstream-proxies-disabled", zap.Error(err))
+		upstreamProxies, _ = upstream.NewProxies(config.UpstreamProxiesConfig{}, dial)
+	}
+	dialBackend := func(ctx context.Context, network, addr string) (net.Conn, error) {
+		conn, err := upstreamProxies.DialContext(ctx, network, addr)
+		if err != nil {
 			return conn, err
-		},
+		}
+		if c.EndpointTimeout > 0 {
+			err = conn.SetDeadline(time.Now().Add(c.EndpointTimeout))
+		}
+		return conn, err
+	}
+
+	idleConns := round_tripper.NewIdleConnTracker()
+	httpTransport := &http.Transport{
+		DialContext:         idleConns.DialContext(dialBackend),
 		DisableKeepAlives:   c.DisableKeepAlives,
 		MaxIdleConns:        c.MaxIdleConns,
 		IdleConnTimeout:     90 * time.Second, // setting the value to golang default transport
 		MaxIdleConnsPerHost: c.MaxIdleConnsPerHost,
+		ReadBufferSize:      c.ResponseStreaming.BufferSize,
+		WriteBufferSize:     c.ResponseStreaming.BufferSize,
 		DisableCompression:  true,
 		TLSClientConfig:     tlsConfig,
+		// The request body is held back until the backend answers with
+		// 100 Continue or the timeout expires. The client only receives its
+		// own 100 Continue once the body is read, so a backend rejecting the
+		// request early never causes the client to send the body.
+		ExpectContinueTimeout: c.ExpectContinueTimeout,
 	}
 
 	rproxy := &ReverseProxy{
 		Director:       p.setupProxyRequest,
-		Transport:      p.proxyRoundTripper(httpTransport, c.Port),
+		Transport:      p.proxyRoundTripper(idleConns.Wrap(httpTransport), c.Port, inFlight),
 		FlushInterval:  50 * time.Millisecond,
 		BufferPool:     p.bufferPool,
 		ModifyResponse: p.modifyResponse,
+		Forward1xx:     !c.DisableInformationalResponses,
+		On1xxResponse:  reporter.CaptureInformationalResponse,
+
+		ZeroCopyMinBytes:  c.ResponseStreaming.ZeroCopyMinBytes,
+		WriteStallTimeout: c.ResponseStreaming.WriteStallTimeout,
+		OnWriteStall: func(req *http.Request) {
+			reporter.CaptureClientWriteStall()
+			logger.Info("client-write-stalled",
+				zap.String("host", req.Host),
+				zap.String("path", req.URL.Path),
+				zap.Duration("timeout", c.ResponseStreaming.WriteStallTimeout),
+			)
+		},
+	}
+
+	// Connections kept idle to an address an instance has moved away from
+	// would otherwise only fail once reused
+	if notifier, ok := registry.(addressChangeNotifier); ok {
+		notifier.OnAddressChange(func(oldEndpoint, _ *route.Endpoint) {
+			closed := idleConns.CloseIdle(oldEndpoint.CanonicalAddr())
+			logger.Info("closed-idle-backend-connections",
+				zap.String("backend", oldEndpoint.CanonicalAddr()),
+				zap.Int("count", closed),
+			)
+		})
+	}
+
+	if notifier, ok := registry.(departureNotifier); ok {
+		notifier.OnEndpointDeparted(func(addr string) {
+			idleConns.CloseIdle(addr)
+		})
+	}
+
+	plugins, err := middleware.NewChain(c.MiddlewarePlugins)
+	if err != nil {
+		logger.Error("middleware-plugins-disabled", zap.Error(err))
+		plugins, _ = middleware.NewChain(nil)
 	}
 
 	zipkinHandler := handlers.NewZipkin(c.Tracing.EnableZipkin, c.ExtraHeadersToLog, logger)
 	n := negroni.New()
 	n.Use(handlers.NewRequestInfo())
 	n.Use(handlers.NewProxyWriter(logger))
+	n.Use(handlers.NewInFlight(inFlight))
 	n.Use(handlers.NewsetVcapRequestIdHeader(logger))
 	n.Use(handlers.NewAccessLog(accessLogger, zipkinHandler.HeadersToLog(), logger))
 	n.Use(handlers.NewReporter(reporter, logger))
+	n.Use(handlers.NewCanaryAnalysis(c.CanaryAnalysis, reporter, logger))
+	n.Use(handlers.NewSlowRequestLog(c.SlowRequestLog, logger.Session("slow-request-log")))
+	if captureRecorder != nil {
+		n.Use(handlers.NewRequestCapture(captureRecorder, logger))
+	}
 
-	n.Use(handlers.NewProxyHealthcheck(c.HealthCheckUserAgent, p.heartbeatOK, logger))
+	n.Use(handlers.NewProxyHealthcheck(c.HealthChecks(), p.heartbeatOK, logger))
 	n.Use(zipkinHandler)
 	n.Use(handlers.NewProtocolCheck(logger))
+	n.Use(handlers.NewUpgradeProtocolCheck(c.UpgradeProtocols, logger))
+	if c.URLNormalization.Enabled {
+		n.Use(handlers.NewURLNormalization(c.URLNormalization, logger))
+	}
+	if c.SecurityHeaders.Enabled {
+		n.Use(handlers.NewSecurityHeaders(c.SecurityHeaders.SecurityHeaders, logger))
+	}
+	if len(c.DomainProfiles) > 0 {
+		n.Use(handlers.NewDomainProfiles(c.DomainProfiles, logger))
+	}
+	if c.TraceKey != "" {
+		n.Use(handlers.NewRouteDebug(c.TraceKey, logger))
+	}
+	for _, h := range extraHandlers {
+		n.Use(h)
+	}
+	n.Use(plugins.Handler(middleware.PostProxy))
+	n.Use(plugins.Handler(middleware.PreLookup))
 	n.Use(handlers.NewLookup(registry, reporter, logger))
-	n.Use(handlers.NewRouteService(routeServiceConfig, logger, registry))
+	n.Use(handlers.NewRouteVisibility(c.RouteVisibility.InternalDomains, reporter, logger))
+	if len(c.Experiments) > 0 {
+		n.Use(handlers.NewExperiments(c.Experiments, logger))
+	}
+	n.Use(handlers.NewCORS(c.CORS.Rules, logger))
+	n.Use(handlers.NewMethodAllowlist(reporter, logger))
+	if c.BandwidthLimit.Enabled {
+		n.Use(handlers.NewBandwidthLimit(c.BandwidthLimit, logger))
+	}
+	n.Use(handlers.NewRouteInFlight(inFlight, logger))
+	if c.AdaptiveConcurrency.Enabled {
+		limitReporter, ok := reporter.(metrics.ConcurrencyLimitReporter)
+		if !ok {
+			limitReporter = nopConcurrencyLimitReporter{}
+		}
+		n.Use(handlers.NewAdaptiveConcurrency(c.AdaptiveConcurrency, limitReporter, logger))
+	}
+	n.Use(plugins.Handler(middleware.PostLookup))
+	n.Use(handlers.NewRouteService(routeServiceConfig, c.RouteServiceForwardedURL, logger.Session("route-services"), registry))
+	if c.RequestCoalescing.Enabled {
+		n.Use(handlers.NewRequestCoalescing(c.RequestCoalescing, reporter, logger))
+	}
+	if c.DeadlinePropagation.Enabled {
+		n.Use(handlers.NewDeadlinePropagation(c.DeadlinePropagation, c.EndpointTimeout, logger))
+	}
+	if c.RequestStart.Enabled {
+		n.Use(handlers.NewRequestStart(c.RequestStart, logger))
+	}
+	n.Use(plugins.Handler(middleware.PreProxy))
 	n.Use(p)
 	n.UseHandler(rproxy)
 
@@ -134,29 +277,35 @@ func hostWithoutPort(req *http.Request) string {
 	return host
 }
 
-func (p *proxy) proxyRoundTripper(transport round_tripper.ProxyRoundTripper, port uint16) round_tripper.ProxyRoundTripper {
+func (p *proxy) proxyRoundTripper(transport round_tripper.ProxyRoundTripper, port uint16, inFlight *metrics.InFlightTracker) round_tripper.ProxyRoundTripper {
 	return round_tripper.NewProxyRoundTripper(
 		round_tripper.NewDropsondeRoundTripper(transport),
-		p.logger, p.traceKey, p.ip, p.defaultLoadBalance,
+		p.logger, p.traceKey, p.ip, p.defaultLoadBalance, p.consistentHash,
 		p.reporter, p.secureCookies,
-		port,
+		port, inFlight, p.routeServiceResponses, p.errorPages, p.retries, p.attemptObservers,
 	)
 }
 
+func (p *proxy) endpointIterator(pool *route.Pool, request *http.Request, stickyEndpointId string) route.EndpointIterator {
+	return handler.NewEndpointIterator(pool, request, p.defaultLoadBalance, p.consistentHash, stickyEndpointId)
+}
+
 type bufferPool struct {
 	pool *sync.Pool
+	size int
 }
 
-func NewBufferPool() httputil.BufferPool {
+func NewBufferPool(size int) httputil.BufferPool {
 	return &bufferPool{
 		pool: new(sync.Pool),
+		size: size,
 	}
 }
 
 func (b *bufferPool) Get() []byte {
 	buf := b.pool.Get()
 	if buf == nil {
-		return make([]byte, 8192)
+		return make([]byte, b.size)
 	}
 	return buf.([]byte)
 }
@@ -178,9 +327,18 @@ func (p *proxy) ServeHTTP(responseWriter http.ResponseWriter, request *http.Requ
 		p.logger.Fatal("request-info-err", zap.Error(errors.New("failed-to-access-RoutePool")))
 	}
 
+	tcp := isTcpUpgrade(request)
+	if !tcp && !isWebSocketUpgrade(request) {
+		// the round tripper takes its own iterator for HTTP requests
+		next(responseWriter, request)
+		return
+	}
+
 	stickyEndpointId := getStickySession(request)
+	nested := p.endpointIterator(reqInfo.RoutePool, request, stickyEndpointId)
+	defer route.ReleaseIterator(nested)
 	iter := &wrappedIterator{
-		nested: reqInfo.RoutePool.Endpoints(p.defaultLoadBalance, stickyEndpointId),
+		nested: nested,
 
 		afterNext: func(endpoint *route.Endpoint) {
 			if endpoint != nil {
@@ -190,17 +348,11 @@ func (p *proxy) ServeHTTP(responseWriter http.ResponseWriter, request *http.Requ
 		},
 	}
 
-	if isTcpUpgrade(request) {
+	if tcp {
 		handler.HandleTcpRequest(iter)
-		return
-	}
-
-	if isWebSocketUpgrade(request) {
+	} else {
 		handler.HandleWebSocketRequest(iter)
-		return
 	}
-
-	next(responseWriter, request)
 }
 
 func (p *proxy) setupProxyRequest(target *http.Request) {
@@ -219,12 +371,38 @@ func (p *proxy) setupProxyRequest(target *http.Request) {
 	target.URL.Opaque = target.RequestURI
 	target.URL.RawQuery = ""
 	target.URL.ForceQuery = false
+	rewritePath(target)
 
 	handler.SetRequestXRequestStart(target)
 	target.Header.Del(router_http.CfAppInstance)
 }
 
+// rewritePath applies the path rewrite of the route to requests forwarded to
+// its endpoints. Hops to route services are left alone, so they see the URL
+// the client sent. The URL is copied first as it is shared with the incoming
+// request, which the access log records.
+func rewritePath(target *http.Request) {
+	reqInfo, err := handlers.ContextRequestInfo(target)
+	if err != nil || reqInfo.RoutePool == nil || reqInfo.RouteServiceURL != nil {
+		return
+	}
+	rewrite := reqInfo.RoutePool.PathRewrite()
+	if rewrite == nil {
+		return
+	}
+
+	u := *target.URL
+	u.Opaque = rewrite.Rewrite(reqInfo.RoutePool.ContextPath(), target.RequestURI)
+	target.URL = &u
+}
+
 func (p *proxy) modifyResponse(backendResp *http.Response) error {
+	if !p.altSvc.AllowBackend {
+		backendResp.Header.Del("Alt-Svc")
+	}
+	if p.altSvc.Advertise != "" && backendResp.Header.Get("Alt-Svc") == "" {
+		backendResp.Header.Set("Alt-Svc", p.altSvc.Advertise)
+	}
 	return nil
 }
 
@@ -251,6 +429,10 @@ func (i *wrappedIterator) PostRequest(e *route.Endpoint) {
 	i.nested.PostRequest(e)
 }
 
+type nopConcurrencyLimitReporter struct{}
+
+func (nopConcurrencyLimitReporter) CaptureConcurrencyLimited() {}
+
 func getStickySession(request *http.Request) string {
 	// Try choosing a backend using sticky session
 	if _, err := request.Cookie(StickyCookieKey); err == nil {
@@ -103,7 +103,7 @@ var _ = JustBeforeEach(func() {
 	Expect(err).ToNot(HaveOccurred())
 	conf.Port = uint16(intPort)
 
-	p = proxy.NewProxy(testLogger, accessLog, conf, r, fakeReporter, routeServiceConfig, tlsConfig, &heartbeatOK)
+	p = proxy.NewProxy(testLogger, accessLog, conf, r, fakeReporter, routeServiceConfig, tlsConfig, &heartbeatOK, nil, nil)
 
 	server := http.Server{Handler: p}
 	go server.Serve(proxyServer)
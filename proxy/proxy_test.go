@@ -10,6 +10,7 @@ import (
 	"net"
 	"net/http"
 	"net/http/httputil"
+	"net/textproto"
 	"net/url"
 	"os"
 	"regexp"
@@ -20,6 +21,7 @@ import (
 	"time"
 
 	router_http "code.cloudfoundry.org/gorouter/common/http"
+	"code.cloudfoundry.org/gorouter/config"
 	"code.cloudfoundry.org/gorouter/handlers"
 	"code.cloudfoundry.org/gorouter/registry"
 	"code.cloudfoundry.org/gorouter/route"
@@ -196,6 +198,80 @@ var _ = Describe("Proxy", func() {
 		conn.CheckLine("HTTP/1.0 200 OK")
 	})
 
+	Context("when the route registers a path rewrite", func() {
+		registerWithPathRewrite := func(path string, rewrite *config.PathRewrite, handler connHandler) net.Listener {
+			ln, err := net.Listen("tcp", "127.0.0.1:0")
+			Expect(err).NotTo(HaveOccurred())
+			go runBackendInstance(ln, handler)
+
+			host, portStr, err := net.SplitHostPort(ln.Addr().String())
+			Expect(err).NotTo(HaveOccurred())
+			port, err := strconv.Atoi(portStr)
+			Expect(err).NotTo(HaveOccurred())
+
+			endpoint := route.NewEndpoint("", host, uint16(port), "", "", nil, -1, "", models.ModificationTag{}, "")
+			endpoint.PathRewrite = rewrite
+			r.Register(route.Uri(path), endpoint)
+			return ln
+		}
+
+		It("forwards the request with the prefix stripped", func() {
+			ln := registerWithPathRewrite("test/api/v1", &config.PathRewrite{StripPrefix: true}, func(conn *test_util.HttpConn) {
+				conn.CheckLine("GET /users?page=2 HTTP/1.1")
+
+				conn.WriteResponse(test_util.NewResponse(http.StatusOK))
+			})
+			defer ln.Close()
+
+			conn := dialProxy(proxyServer)
+
+			conn.WriteRequest(test_util.NewRequest("GET", "test", "/api/v1/users?page=2", nil))
+
+			resp, _ := conn.ReadResponse()
+			Expect(resp.StatusCode).To(Equal(http.StatusOK))
+		})
+
+		It("forwards the request with the prefix replaced", func() {
+			ln := registerWithPathRewrite("test/api/v1", &config.PathRewrite{ReplacePrefix: "/v1"}, func(conn *test_util.HttpConn) {
+				conn.CheckLine("GET /v1/users HTTP/1.1")
+
+				conn.WriteResponse(test_util.NewResponse(http.StatusOK))
+			})
+			defer ln.Close()
+
+			conn := dialProxy(proxyServer)
+
+			conn.WriteRequest(test_util.NewRequest("GET", "test", "/api/v1/users", nil))
+
+			resp, _ := conn.ReadResponse()
+			Expect(resp.StatusCode).To(Equal(http.StatusOK))
+		})
+
+		It("logs the path requested by the client", func() {
+			ln := registerWithPathRewrite("test/api/v1", &config.PathRewrite{StripPrefix: true}, func(conn *test_util.HttpConn) {
+				conn.CheckLine("GET /users HTTP/1.1")
+
+				conn.WriteResponse(test_util.NewResponse(http.StatusOK))
+			})
+			defer ln.Close()
+
+			conn := dialProxy(proxyServer)
+
+			conn.WriteRequest(test_util.NewRequest("GET", "test", "/api/v1/users", nil))
+
+			resp, _ := conn.ReadResponse()
+			Expect(resp.StatusCode).To(Equal(http.StatusOK))
+
+			var payload []byte
+			Eventually(func() int {
+				accessLogFile.Read(&payload)
+				return len(payload)
+			}).ShouldNot(BeZero())
+
+			Expect(string(payload)).To(ContainSubstring(`"GET /api/v1/users HTTP/1.1" 200`))
+		})
+	})
+
 	It("responds to http/1.0", func() {
 		ln := registerHandler(r, "test", func(conn *test_util.HttpConn) {
 			conn.CheckLine("GET / HTTP/1.1")
@@ -1251,6 +1327,98 @@ var _ = Describe("Proxy", func() {
 		}
 	})
 
+	Context("trailers", func() {
+		It("propagates announced response trailers", func() {
+			ln := registerHandler(r, "trailers", func(conn *test_util.HttpConn) {
+				_, err := http.ReadRequest(conn.Reader)
+				Expect(err).NotTo(HaveOccurred())
+
+				conn.WriteLines([]string{
+					"HTTP/1.1 200 OK",
+					"Transfer-Encoding: chunked",
+					"Trailer: Grpc-Status",
+				})
+				conn.WriteLines([]string{
+					"5",
+					"hello",
+					"0",
+					"Grpc-Status: 0",
+				})
+				conn.Close()
+			})
+			defer ln.Close()
+
+			conn := dialProxy(proxyServer)
+			conn.WriteRequest(test_util.NewRequest("GET", "trailers", "/", nil))
+
+			resp, body := conn.ReadResponse()
+			Expect(resp.StatusCode).To(Equal(http.StatusOK))
+			Expect(body).To(Equal("hello"))
+			Expect(resp.Trailer.Get("Grpc-Status")).To(Equal("0"))
+		})
+
+		It("propagates response trailers that were not announced", func() {
+			ln := registerHandler(r, "trailers", func(conn *test_util.HttpConn) {
+				_, err := http.ReadRequest(conn.Reader)
+				Expect(err).NotTo(HaveOccurred())
+
+				conn.WriteLines([]string{
+					"HTTP/1.1 200 OK",
+					"Transfer-Encoding: chunked",
+				})
+				conn.WriteLines([]string{
+					"5",
+					"hello",
+					"0",
+					"X-Checksum: abc",
+				})
+				conn.Close()
+			})
+			defer ln.Close()
+
+			conn := dialProxy(proxyServer)
+			conn.WriteRequest(test_util.NewRequest("GET", "trailers", "/", nil))
+
+			resp, body := conn.ReadResponse()
+			Expect(body).To(Equal("hello"))
+			Expect(resp.Trailer.Get("X-Checksum")).To(Equal("abc"))
+		})
+
+		It("propagates request trailers to the backend", func() {
+			trailers := make(chan http.Header, 1)
+			ln := registerHandler(r, "trailers", func(conn *test_util.HttpConn) {
+				req, body := conn.ReadRequest()
+				Expect(body).To(Equal("hello"))
+				trailers <- req.Trailer
+
+				conn.WriteResponse(test_util.NewResponse(http.StatusOK))
+				conn.Close()
+			})
+			defer ln.Close()
+
+			conn := dialProxy(proxyServer)
+			conn.WriteLines([]string{
+				"POST / HTTP/1.1",
+				"Host: trailers",
+				"Transfer-Encoding: chunked",
+				"Trailer: X-Checksum",
+			})
+			conn.WriteLines([]string{
+				"5",
+				"hello",
+				"0",
+				"X-Checksum: abc",
+			})
+
+			resp, _ := conn.ReadResponse()
+			Expect(resp.StatusCode).To(Equal(http.StatusOK))
+
+			var received http.Header
+			Eventually(trailers).Should(Receive(&received))
+			Expect(received.Get("X-Checksum")).To(Equal("abc"))
+		})
+	})
+
 	It("status no content was no Transfer Encoding response header", func() {
 		ln := registerHandler(r, "not-modified", func(conn *test_util.HttpConn) {
 			_, err := http.ReadRequest(conn.Reader)
@@ -1549,6 +1717,208 @@ var _ = Describe("Proxy", func() {
 		}
 	})
 
+	Context("when the client sends Expect: 100-continue", func() {
+		writeExpectContinueRequest := func(conn *test_util.HttpConn, host string) {
+			conn.WriteLines([]string{
+				"POST / HTTP/1.1",
+				"Host: " + host,
+				"Content-Length: 4",
+				"Expect: 100-continue",
+			})
+		}
+
+		It("sends the body only after the backend continues", func() {
+			ln := registerHandler(r, "continue", func(conn *test_util.HttpConn) {
+				req, err := http.ReadRequest(conn.Reader)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(req.Header.Get("Expect")).To(Equal("100-continue"))
+
+				conn.WriteLine("HTTP/1.1 100 Continue")
+				conn.WriteLine("")
+
+				body, err := ioutil.ReadAll(req.Body)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(string(body)).To(Equal("abcd"))
+
+				conn.WriteResponse(test_util.NewResponse(http.StatusOK))
+				conn.Close()
+			})
+			defer ln.Close()
+
+			conn := dialProxy(proxyServer)
+			writeExpectContinueRequest(conn, "continue")
+
+			conn.CheckLine("HTTP/1.1 100 Continue")
+			conn.CheckLine("")
+			conn.Writer.WriteString("abcd")
+			conn.Writer.Flush()
+
+			resp, _ := conn.ReadResponse()
+			Expect(resp.StatusCode).To(Equal(http.StatusOK))
+		})
+
+		It("returns the final response without asking for the body when the backend rejects the request", func() {
+			ln := registerHandler(r, "reject-continue", func(conn *test_util.HttpConn) {
+				_, err := http.ReadRequest(conn.Reader)
+				Expect(err).NotTo(HaveOccurred())
+
+				conn.WriteResponse(test_util.NewResponse(http.StatusExpectationFailed))
+				conn.Close()
+			})
+			defer ln.Close()
+
+			conn := dialProxy(proxyServer)
+			writeExpectContinueRequest(conn, "reject-continue")
+
+			resp, _ := conn.ReadResponse()
+			Expect(resp.StatusCode).To(Equal(http.StatusExpectationFailed))
+		})
+
+		It("retries another endpoint when one fails before sending 100 Continue", func() {
+			ln := registerHandler(r, "retry-continue", func(conn *test_util.HttpConn) {
+				req, err := http.ReadRequest(conn.Reader)
+				Expect(err).NotTo(HaveOccurred())
+
+				conn.WriteLine("HTTP/1.1 100 Continue")
+				conn.WriteLine("")
+
+				body, err := ioutil.ReadAll(req.Body)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(string(body)).To(Equal("abcd"))
+
+				conn.WriteResponse(test_util.NewResponse(http.StatusOK))
+				conn.Close()
+			})
+			defer ln.Close()
+
+			registerAddr(r, "retry-continue", "", "localhost:81", "instanceId", "2", "")
+
+			for i := 0; i < 5; i++ {
+				conn := dialProxy(proxyServer)
+				writeExpectContinueRequest(conn, "retry-continue")
+
+				conn.CheckLine("HTTP/1.1 100 Continue")
+				conn.CheckLine("")
+				conn.Writer.WriteString("abcd")
+				conn.Writer.Flush()
+
+				resp, _ := conn.ReadResponse()
+				Expect(resp.StatusCode).To(Equal(http.StatusOK))
+			}
+		})
+	})
+
+	Context("when the backend sends 103 Early Hints", func() {
+		var ln net.Listener
+
+		JustBeforeEach(func() {
+			ln = registerHandler(r, "early-hints", func(conn *test_util.HttpConn) {
+				_, err := http.ReadRequest(conn.Reader)
+				Expect(err).NotTo(HaveOccurred())
+
+				conn.WriteLines([]string{
+					"HTTP/1.1 103 Early Hints",
+					"Link: </style.css>; rel=preload",
+				})
+
+				resp := test_util.NewResponse(http.StatusOK)
+				resp.Header.Set("Content-Type", "text/html")
+				conn.WriteResponse(resp)
+				conn.Close()
+			})
+		})
+
+		AfterEach(func() {
+			ln.Close()
+		})
+
+		It("forwards the interim response to the client", func() {
+			conn := dialProxy(proxyServer)
+			conn.WriteRequest(test_util.NewRequest("GET", "early-hints", "/", nil))
+
+			conn.CheckLine("HTTP/1.1 103 Early Hints")
+			hints, err := textproto.NewReader(conn.Reader).ReadMIMEHeader()
+			Expect(err).NotTo(HaveOccurred())
+			Expect(hints.Get("Link")).To(Equal("</style.css>; rel=preload"))
+			Expect(hints.Get("Content-Type")).To(BeEmpty())
+
+			resp, _ := conn.ReadResponse()
+			Expect(resp.StatusCode).To(Equal(http.StatusOK))
+			Expect(resp.Header.Get("Content-Type")).To(Equal("text/html"))
+			Expect(resp.Header.Get("Link")).To(BeEmpty())
+
+			Expect(fakeReporter.CaptureInformationalResponseCallCount()).To(Equal(1))
+			Expect(fakeReporter.CaptureInformationalResponseArgsForCall(0)).To(Equal(http.StatusEarlyHints))
+		})
+
+		Context("when forwarding is disabled", func() {
+			BeforeEach(func() {
+				conf.DisableInformationalResponses = true
+			})
+
+			It("only sends the final response", func() {
+				conn := dialProxy(proxyServer)
+				conn.WriteRequest(test_util.NewRequest("GET", "early-hints", "/", nil))
+
+				resp, _ := conn.ReadResponse()
+				Expect(resp.StatusCode).To(Equal(http.StatusOK))
+				Expect(fakeReporter.CaptureInformationalResponseCallCount()).To(Equal(0))
+			})
+		})
+	})
+
+	Context("Alt-Svc", func() {
+		var ln net.Listener
+
+		JustBeforeEach(func() {
+			ln = registerHandler(r, "alt-svc", func(conn *test_util.HttpConn) {
+				_, err := http.ReadRequest(conn.Reader)
+				Expect(err).NotTo(HaveOccurred())
+
+				resp := test_util.NewResponse(http.StatusOK)
+				resp.Header.Set("Alt-Svc", `h2="backend.internal:8443"`)
+				conn.WriteResponse(resp)
+				conn.Close()
+			})
+		})
+
+		AfterEach(func() {
+			ln.Close()
+		})
+
+		get := func() *http.Response {
+			conn := dialProxy(proxyServer)
+			conn.WriteRequest(test_util.NewRequest("GET", "alt-svc", "/", nil))
+			resp, _ := conn.ReadResponse()
+			Expect(resp.StatusCode).To(Equal(http.StatusOK))
+			return resp
+		}
+
+		It("removes the backend's Alt-Svc", func() {
+			Expect(get().Header).NotTo(HaveKey("Alt-Svc"))
+		})
+
+		Context("when an Alt-Svc is advertised", func() {
+			BeforeEach(func() {
+				conf.AltSvc.Advertise = `h3=":443"; ma=86400`
+			})
+
+			It("replaces the backend's Alt-Svc", func() {
+				Expect(get().Header.Get("Alt-Svc")).To(Equal(`h3=":443"; ma=86400`))
+			})
+
+			Context("when backends are allowed to set it", func() {
+				BeforeEach(func() {
+					conf.AltSvc.AllowBackend = true
+				})
+
+				It("keeps the backend's Alt-Svc", func() {
+					Expect(get().Header.Get("Alt-Svc")).To(Equal(`h2="backend.internal:8443"`))
+				})
+			})
+		})
+	})
+
 	Context("Access log", func() {
 		It("Logs a request", func() {
 			ln := registerHandlerWithAppId(r, "test", "", func(conn *test_util.HttpConn) {
@@ -10,10 +10,13 @@ package proxy
 
 import (
 	"context"
+	"errors"
 	"io"
 	"log"
 	"net"
 	"net/http"
+	"net/http/httptrace"
+	"net/textproto"
 	"net/url"
 	"strings"
 	"sync"
@@ -61,6 +64,33 @@ type ReverseProxy struct {
 	// modifies the Response from the backend.
 	// If it returns an error, the proxy returns a StatusBadGateway error.
 	ModifyResponse func(*http.Response) error
+
+	// Forward1xx enables copying interim 1xx responses, such as 103 Early
+	// Hints, from the backend to the client ahead of the final response.
+	// 100 Continue is left to the server, which sends it once the request
+	// body is read.
+	Forward1xx bool
+
+	// On1xxResponse is an optional function called for every interim
+	// response forwarded to the client.
+	On1xxResponse func(code int)
+
+	// WriteStallTimeout, when set, bounds how long a write of the response
+	// body to the client may block. Once it is exceeded the backend's
+	// response body is closed, releasing the backend connection, and the
+	// copy is abandoned.
+	WriteStallTimeout time.Duration
+
+	// OnWriteStall is an optional function called when a response is
+	// abandoned because of WriteStallTimeout.
+	OnWriteStall func(req *http.Request)
+
+	// ZeroCopyMinBytes, when positive, hands response bodies with a
+	// Content-Length of at least this many bytes to the ReadFrom of the
+	// client's response writer instead of copying them through a buffer,
+	// so that the server can send them with splice or sendfile. It is
+	// ignored when WriteStallTimeout is set.
+	ZeroCopyMinBytes int64
 }
 
 // A BufferPool is an interface for getting and returning temporary
@@ -116,6 +146,12 @@ func copyHeader(dst, src http.Header) {
 	}
 }
 
+func clearHeader(h http.Header) {
+	for k := range h {
+		delete(h, k)
+	}
+}
+
 // Hop-by-hop headers. These are removed when sent to the backend.
 // http://www.w3.org/Protocols/rfc2616/rfc2616-sec13.html
 var hopHeaders = []string{
@@ -204,6 +240,33 @@ func (p *ReverseProxy) ServeHTTP(rw http.ResponseWriter, req *http.Request) {
 		outreq.Header.Set("X-Forwarded-For", clientIP)
 	}
 
+	if p.Forward1xx {
+		trace := &httptrace.ClientTrace{
+			Got1xxResponse: func(code int, header textproto.MIMEHeader) error {
+				if code == http.StatusContinue {
+					return nil
+				}
+
+				h := rw.Header()
+				prior := make(http.Header, len(h))
+				copyHeader(prior, h)
+
+				clearHeader(h)
+				copyHeader(h, http.Header(header))
+				rw.WriteHeader(code)
+
+				clearHeader(h)
+				copyHeader(h, prior)
+
+				if p.On1xxResponse != nil {
+					p.On1xxResponse(code)
+				}
+				return nil
+			},
+		}
+		outreq = outreq.WithContext(httptrace.WithClientTrace(outreq.Context(), trace))
+	}
+
 	res, err := transport.RoundTrip(outreq)
 	if err != nil {
 		p.logf("http: proxy error: %v", err)
@@ -237,7 +300,8 @@ func (p *ReverseProxy) ServeHTTP(rw http.ResponseWriter, req *http.Request) {
 
 	// The "Trailer" header isn't included in the Transport's response,
 	// at least for *http.Transport. Build it up from Trailer.
-	if len(res.Trailer) > 0 {
+	announcedTrailers := len(res.Trailer)
+	if announcedTrailers > 0 {
 		trailerKeys := make([]string, 0, len(res.Trailer))
 		for k := range res.Trailer {
 			trailerKeys = append(trailerKeys, k)
@@ -254,12 +318,29 @@ func (p *ReverseProxy) ServeHTTP(rw http.ResponseWriter, req *http.Request) {
 			fl.Flush()
 		}
 	}
-	p.copyResponse(rw, res.Body)
+	if p.zeroCopy(res.ContentLength) {
+		p.copyDirect(rw, res.Body)
+	} else {
+		p.copyResponse(rw, res.Body, req)
+	}
 	res.Body.Close() // close now, instead of defer, to populate res.Trailer
-	copyHeader(rw.Header(), res.Trailer)
+
+	if len(res.Trailer) == announcedTrailers {
+		copyHeader(rw.Header(), res.Trailer)
+		return
+	}
+
+	// Trailers the backend did not announce up front can only be sent
+	// using the TrailerPrefix convention.
+	for k, vv := range res.Trailer {
+		k = http.TrailerPrefix + k
+		for _, v := range vv {
+			rw.Header().Add(k, v)
+		}
+	}
 }
 
-func (p *ReverseProxy) copyResponse(dst io.Writer, src io.Reader) {
+func (p *ReverseProxy) copyResponse(dst io.Writer, src io.ReadCloser, req *http.Request) {
 	if p.FlushInterval != 0 {
 		if wf, ok := dst.(writeFlusher); ok {
 			mlw := &maxLatencyWriter{
@@ -272,6 +353,18 @@ func (p *ReverseProxy) copyResponse(dst io.Writer, src io.Reader) {
 			dst = mlw
 		}
 	}
+	if p.WriteStallTimeout > 0 {
+		dst = &stallWriter{
+			dst:     dst,
+			timeout: p.WriteStallTimeout,
+			onStall: func() {
+				src.Close()
+				if p.OnWriteStall != nil {
+					p.OnWriteStall(req)
+				}
+			},
+		}
+	}
 
 	var buf []byte
 	if p.BufferPool != nil {
@@ -283,6 +376,34 @@ func (p *ReverseProxy) copyResponse(dst io.Writer, src io.Reader) {
 	}
 }
 
+// zeroCopy reports whether a body of contentLength bytes is copied without
+// buffers. Such a body is neither flushed periodically, which only matters
+// for data held in buffers, nor watched for stalls.
+func (p *ReverseProxy) zeroCopy(contentLength int64) bool {
+	return p.ZeroCopyMinBytes > 0 && contentLength >= p.ZeroCopyMinBytes && p.WriteStallTimeout == 0
+}
+
+// copyDirect copies src to dst with dst's ReadFrom, or src's WriteTo, falling
+// back to a pooled buffer when neither has one
+func (p *ReverseProxy) copyDirect(dst io.Writer, src io.Reader) {
+	var err error
+	if rf, ok := dst.(io.ReaderFrom); ok {
+		_, err = rf.ReadFrom(src)
+	} else if wt, ok := src.(io.WriterTo); ok {
+		_, err = wt.WriteTo(dst)
+	} else {
+		var buf []byte
+		if p.BufferPool != nil {
+			buf = p.BufferPool.Get()
+			defer p.BufferPool.Put(buf)
+		}
+		_, err = p.copyBuffer(dst, src, buf)
+	}
+	if err != nil && err != io.EOF {
+		p.logf("httputil: ReverseProxy error during body copy: %v", err)
+	}
+}
+
 func (p *ReverseProxy) copyBuffer(dst io.Writer, src io.Reader, buf []byte) (int64, error) {
 	if len(buf) == 0 {
 		buf = make([]byte, 32*1024)
@@ -319,6 +440,25 @@ func (p *ReverseProxy) logf(format string, args ...interface{}) {
 	}
 }
 
+var errWriteStalled = errors.New("write to client stalled")
+
+// stallWriter calls onStall when a write to dst blocks for longer than
+// timeout, i.e. the client stopped reading the response
+type stallWriter struct {
+	dst     io.Writer
+	timeout time.Duration
+	onStall func()
+}
+
+func (w *stallWriter) Write(p []byte) (int, error) {
+	t := time.AfterFunc(w.timeout, w.onStall)
+	n, err := w.dst.Write(p)
+	if !t.Stop() && err == nil {
+		err = errWriteStalled
+	}
+	return n, err
+}
+
 type writeFlusher interface {
 	io.Writer
 	http.Flusher
@@ -0,0 +1,65 @@
+package proxy_test
+
+import (
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"testing"
+	"time"
+
+	"code.cloudfoundry.org/gorouter/proxy"
+)
+
+const benchmarkBodySize = 4 * 1024 * 1024
+
+// proxyLargeBodies proxies a large response from a local backend per
+// operation, copying it with or without buffers
+func proxyLargeBodies(b *testing.B, zeroCopyMinBytes int64) {
+	body := make([]byte, benchmarkBodySize)
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Length", strconv.Itoa(len(body)))
+		w.Write(body)
+	}))
+	defer backend.Close()
+
+	backendURL, err := url.Parse(backend.URL)
+	if err != nil {
+		b.Fatal(err)
+	}
+	front := httptest.NewServer(&proxy.ReverseProxy{
+		Director: func(req *http.Request) {
+			req.URL.Scheme = backendURL.Scheme
+			req.URL.Host = backendURL.Host
+		},
+		Transport:        &http.Transport{MaxIdleConnsPerHost: 16},
+		FlushInterval:    50 * time.Millisecond,
+		BufferPool:       proxy.NewBufferPool(8192),
+		ZeroCopyMinBytes: zeroCopyMinBytes,
+	})
+	defer front.Close()
+
+	client := &http.Client{Transport: &http.Transport{MaxIdleConnsPerHost: 16}}
+
+	b.SetBytes(benchmarkBodySize)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for n := 0; n < b.N; n++ {
+		res, err := client.Get(front.URL)
+		if err != nil {
+			b.Fatal(err)
+		}
+		io.Copy(ioutil.Discard, res.Body)
+		res.Body.Close()
+	}
+}
+
+func BenchmarkProxyLargeBodyBuffered(b *testing.B) {
+	proxyLargeBodies(b, 0)
+}
+
+func BenchmarkProxyLargeBodyZeroCopy(b *testing.B) {
+	proxyLargeBodies(b, 1024*1024)
+}
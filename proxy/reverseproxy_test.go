@@ -0,0 +1,148 @@
+package proxy_test
+
+import (
+	"errors"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"time"
+
+	"code.cloudfoundry.org/gorouter/proxy"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+type roundTripperFunc func(*http.Request) (*http.Response, error)
+
+func (f roundTripperFunc) RoundTrip(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
+
+// endlessBody streams until closed, like a large download
+type endlessBody struct {
+	closed int32
+}
+
+func (b *endlessBody) Read(p []byte) (int, error) {
+	if atomic.LoadInt32(&b.closed) == 1 {
+		return 0, errors.New("read on closed body")
+	}
+	return len(p), nil
+}
+
+func (b *endlessBody) Close() error {
+	atomic.StoreInt32(&b.closed, 1)
+	return nil
+}
+
+// stalledWriter blocks every write until released, like a client that
+// stopped reading
+type stalledWriter struct {
+	*httptest.ResponseRecorder
+	release chan struct{}
+}
+
+func (w *stalledWriter) Write(p []byte) (int, error) {
+	<-w.release
+	return len(p), nil
+}
+
+// readerFromRecorder records whether the body was handed to ReadFrom
+type readerFromRecorder struct {
+	*httptest.ResponseRecorder
+	readFrom bool
+}
+
+func (w *readerFromRecorder) ReadFrom(r io.Reader) (int64, error) {
+	w.readFrom = true
+	return io.Copy(w.ResponseRecorder, r)
+}
+
+var _ = Describe("ReverseProxy", func() {
+	var (
+		body    *endlessBody
+		rproxy  *proxy.ReverseProxy
+		writer  *stalledWriter
+		stalled chan *http.Request
+	)
+
+	BeforeEach(func() {
+		body = &endlessBody{}
+		stalled = make(chan *http.Request, 1)
+		writer = &stalledWriter{ResponseRecorder: httptest.NewRecorder(), release: make(chan struct{})}
+		rproxy = &proxy.ReverseProxy{
+			Director: func(*http.Request) {},
+			Transport: roundTripperFunc(func(*http.Request) (*http.Response, error) {
+				return &http.Response{StatusCode: http.StatusOK, Header: http.Header{}, Body: body}, nil
+			}),
+			WriteStallTimeout: 50 * time.Millisecond,
+			OnWriteStall: func(req *http.Request) {
+				stalled <- req
+			},
+		}
+	})
+
+	Context("when the client stops reading the response", func() {
+		It("closes the backend's response body and gives up", func() {
+			req := httptest.NewRequest("GET", "http://download.example.com/big", nil)
+			done := make(chan struct{})
+			go func() {
+				defer GinkgoRecover()
+				rproxy.ServeHTTP(writer, req)
+				close(done)
+			}()
+
+			var stalledReq *http.Request
+			Eventually(stalled).Should(Receive(&stalledReq))
+			Expect(stalledReq.Host).To(Equal("download.example.com"))
+			Expect(atomic.LoadInt32(&body.closed)).To(BeEquivalentTo(1))
+
+			close(writer.release)
+			Eventually(done).Should(BeClosed())
+		})
+	})
+
+	Describe("zero-copy", func() {
+		var recorder *readerFromRecorder
+
+		serve := func(size int64) {
+			rproxy.Transport = roundTripperFunc(func(*http.Request) (*http.Response, error) {
+				return &http.Response{
+					StatusCode:    http.StatusOK,
+					Header:        http.Header{},
+					ContentLength: size,
+					Body:          ioutil.NopCloser(io.LimitReader(body, size)),
+				}, nil
+			})
+			recorder = &readerFromRecorder{ResponseRecorder: httptest.NewRecorder()}
+			rproxy.ServeHTTP(recorder, httptest.NewRequest("GET", "http://download.example.com/big", nil))
+		}
+
+		BeforeEach(func() {
+			rproxy.WriteStallTimeout = 0
+			rproxy.ZeroCopyMinBytes = 1024
+		})
+
+		It("hands large bodies to the writer's ReadFrom", func() {
+			serve(4096)
+			Expect(recorder.readFrom).To(BeTrue())
+			Expect(recorder.Body.Len()).To(Equal(4096))
+		})
+
+		It("copies smaller bodies through a buffer", func() {
+			serve(512)
+			Expect(recorder.readFrom).To(BeFalse())
+			Expect(recorder.Body.Len()).To(Equal(512))
+		})
+
+		It("copies through a buffer when write stalls are detected", func() {
+			rproxy.WriteStallTimeout = time.Minute
+			serve(4096)
+			Expect(recorder.readFrom).To(BeFalse())
+			Expect(recorder.Body.Len()).To(Equal(4096))
+		})
+	})
+})
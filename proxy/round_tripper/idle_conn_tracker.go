@@ -0,0 +1,153 @@
+package round_tripper
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/http/httptrace"
+	"sync"
+)
+
+// IdleConnTracker follows the backend connections dialed by a transport and
+// whether the transport is currently holding them idle, so that the idle
+// connections to a single backend can be closed without disturbing requests
+// in flight or connections to other backends.
+type IdleConnTracker struct {
+	lock  sync.Mutex
+	conns map[string]map[*trackedConn]bool
+}
+
+type trackedConn struct {
+	net.Conn
+	addr      string
+	tracker   *IdleConnTracker
+	closeOnce sync.Once
+}
+
+func (c *trackedConn) Close() error {
+	c.closeOnce.Do(func() {
+		c.tracker.forget(c)
+	})
+	return c.Conn.Close()
+}
+
+func NewIdleConnTracker() *IdleConnTracker {
+	return &IdleConnTracker{
+		conns: make(map[string]map[*trackedConn]bool),
+	}
+}
+
+// Dial wraps dial so that the connections it returns are tracked
+func (t *IdleConnTracker) Dial(dial func(network, addr string) (net.Conn, error)) func(network, addr string) (net.Conn, error) {
+	dialContext := t.DialContext(func(_ context.Context, network, addr string) (net.Conn, error) {
+		return dial(network, addr)
+	})
+	return func(network, addr string) (net.Conn, error) {
+		return dialContext(context.Background(), network, addr)
+	}
+}
+
+// DialContext wraps dial so that the connections it returns are tracked
+func (t *IdleConnTracker) DialContext(
+	dial func(ctx context.Context, network, addr string) (net.Conn, error),
+) func(ctx context.Context, network, addr string) (net.Conn, error) {
+	return func(ctx context.Context, network, addr string) (net.Conn, error) {
+		conn, err := dial(ctx, network, addr)
+		if err != nil {
+			return conn, err
+		}
+
+		c := &trackedConn{Conn: conn, addr: addr, tracker: t}
+		t.lock.Lock()
+		if t.conns[addr] == nil {
+			t.conns[addr] = make(map[*trackedConn]bool)
+		}
+		t.conns[addr][c] = false
+		t.lock.Unlock()
+
+		return c, nil
+	}
+}
+
+// Wrap returns a round tripper that reports to the tracker when connections
+// are handed to a request and when they are returned to the idle pool
+func (t *IdleConnTracker) Wrap(p ProxyRoundTripper) ProxyRoundTripper {
+	return &idleTrackingRoundTripper{p: p, tracker: t}
+}
+
+// CloseIdle closes the idle connections to addr and returns how many were
+// closed
+func (t *IdleConnTracker) CloseIdle(addr string) int {
+	var idle []*trackedConn
+
+	t.lock.Lock()
+	for c, isIdle := range t.conns[addr] {
+		if isIdle {
+			idle = append(idle, c)
+		}
+	}
+	t.lock.Unlock()
+
+	for _, c := range idle {
+		c.Close()
+	}
+	return len(idle)
+}
+
+func (t *IdleConnTracker) setIdle(c *trackedConn, idle bool) {
+	t.lock.Lock()
+	if conns, ok := t.conns[c.addr]; ok {
+		if _, ok := conns[c]; ok {
+			conns[c] = idle
+		}
+	}
+	t.lock.Unlock()
+}
+
+func (t *IdleConnTracker) forget(c *trackedConn) {
+	t.lock.Lock()
+	delete(t.conns[c.addr], c)
+	if len(t.conns[c.addr]) == 0 {
+		delete(t.conns, c.addr)
+	}
+	t.lock.Unlock()
+}
+
+type idleTrackingRoundTripper struct {
+	p       ProxyRoundTripper
+	tracker *IdleConnTracker
+}
+
+func (i *idleTrackingRoundTripper) RoundTrip(r *http.Request) (*http.Response, error) {
+	var (
+		lock sync.Mutex
+		conn *trackedConn
+	)
+
+	trace := &httptrace.ClientTrace{
+		GotConn: func(info httptrace.GotConnInfo) {
+			c, ok := info.Conn.(*trackedConn)
+			if !ok {
+				return
+			}
+			lock.Lock()
+			conn = c
+			lock.Unlock()
+			i.tracker.setIdle(c, false)
+		},
+		PutIdleConn: func(err error) {
+			lock.Lock()
+			c := conn
+			lock.Unlock()
+			if err == nil && c != nil {
+				i.tracker.setIdle(c, true)
+			}
+		},
+	}
+
+	return i.p.RoundTrip(r.WithContext(httptrace.WithClientTrace(r.Context(), trace)))
+}
+
+func (i *idleTrackingRoundTripper) CancelRequest(r *http.Request) {
+	i.p.CancelRequest(r)
+}
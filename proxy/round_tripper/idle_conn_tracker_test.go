@@ -0,0 +1,80 @@
+package round_tripper_test
+
+import (
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+
+	"code.cloudfoundry.org/gorouter/proxy/round_tripper"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("IdleConnTracker", func() {
+	var (
+		tracker    *round_tripper.IdleConnTracker
+		transport  *http.Transport
+		client     *http.Client
+		server     *httptest.Server
+		otherAddr  string
+		serverAddr string
+		release    chan struct{}
+	)
+
+	BeforeEach(func() {
+		release = make(chan struct{})
+		server = httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+			if r.URL.Path == "/slow" {
+				<-release
+			}
+			rw.WriteHeader(http.StatusOK)
+		}))
+		serverAddr = server.Listener.Addr().String()
+		otherAddr = "10.0.0.1:8080"
+
+		tracker = round_tripper.NewIdleConnTracker()
+		transport = &http.Transport{Dial: tracker.Dial(net.Dial)}
+		client = &http.Client{Transport: tracker.Wrap(transport)}
+	})
+
+	AfterEach(func() {
+		transport.CloseIdleConnections()
+		server.Close()
+	})
+
+	get := func(path string) {
+		resp, err := client.Get(server.URL + path)
+		Expect(err).NotTo(HaveOccurred())
+		_, err = ioutil.ReadAll(resp.Body)
+		Expect(err).NotTo(HaveOccurred())
+		resp.Body.Close()
+	}
+
+	It("closes idle connections to the address", func() {
+		get("/")
+
+		Eventually(func() int { return tracker.CloseIdle(serverAddr) }).Should(Equal(1))
+		Expect(tracker.CloseIdle(serverAddr)).To(Equal(0))
+	})
+
+	It("does not close connections to other addresses", func() {
+		get("/")
+
+		Expect(tracker.CloseIdle(otherAddr)).To(Equal(0))
+	})
+
+	It("does not close connections serving a request", func() {
+		done := make(chan struct{})
+		go func() {
+			defer GinkgoRecover()
+			defer close(done)
+			get("/slow")
+		}()
+
+		Consistently(func() int { return tracker.CloseIdle(serverAddr) }).Should(Equal(0))
+		close(release)
+		Eventually(done).Should(BeClosed())
+	})
+})
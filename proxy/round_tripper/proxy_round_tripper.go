@@ -1,10 +1,11 @@
 package round_tripper
 
 import (
+	"context"
 	"errors"
 	"fmt"
+	"io"
 	"io/ioutil"
-	"net"
 	"net/http"
 	"net/url"
 	"time"
@@ -12,11 +13,15 @@ import (
 	"github.com/uber-go/zap"
 
 	router_http "code.cloudfoundry.org/gorouter/common/http"
+	"code.cloudfoundry.org/gorouter/config"
 	"code.cloudfoundry.org/gorouter/handlers"
 	"code.cloudfoundry.org/gorouter/logger"
 	"code.cloudfoundry.org/gorouter/metrics"
+	"code.cloudfoundry.org/gorouter/middleware"
 	"code.cloudfoundry.org/gorouter/proxy/handler"
+	"code.cloudfoundry.org/gorouter/proxy/upstream"
 	"code.cloudfoundry.org/gorouter/route"
+	"code.cloudfoundry.org/gorouter/routererr"
 )
 
 const (
@@ -40,9 +45,15 @@ func NewProxyRoundTripper(
 	traceKey string,
 	routerIP string,
 	defaultLoadBalance string,
+	consistentHash config.ConsistentHashConfig,
 	combinedReporter metrics.CombinedReporter,
 	secureCookies bool,
 	localPort uint16,
+	inFlight *metrics.InFlightTracker,
+	routeServiceResponses config.RouteServiceResponsesConfig,
+	errorPages config.ErrorPagesConfig,
+	retries config.RetriesConfig,
+	observers middleware.AttemptObservers,
 ) ProxyRoundTripper {
 	return &roundTripper{
 		logger:             logger,
@@ -50,9 +61,16 @@ func NewProxyRoundTripper(
 		traceKey:           traceKey,
 		routerIP:           routerIP,
 		defaultLoadBalance: defaultLoadBalance,
+		consistentHash:     consistentHash,
 		combinedReporter:   combinedReporter,
 		secureCookies:      secureCookies,
 		localPort:          localPort,
+		inFlight:           inFlight,
+
+		routeServiceResponses: routeServiceResponses,
+		errorPages:            errorPages,
+		retries:               retries,
+		observers:             observers,
 	}
 }
 
@@ -62,9 +80,16 @@ type roundTripper struct {
 	traceKey           string
 	routerIP           string
 	defaultLoadBalance string
+	consistentHash     config.ConsistentHashConfig
 	combinedReporter   metrics.CombinedReporter
 	secureCookies      bool
 	localPort          uint16
+	inFlight           *metrics.InFlightTracker
+
+	routeServiceResponses config.RouteServiceResponsesConfig
+	errorPages            config.ErrorPagesConfig
+	retries               config.RetriesConfig
+	observers             middleware.AttemptObservers
 }
 
 func (rt *roundTripper) RoundTrip(request *http.Request) (*http.Response, error) {
@@ -93,10 +118,17 @@ func (rt *roundTripper) RoundTrip(request *http.Request) (*http.Response, error)
 	}
 
 	stickyEndpointID := getStickySession(request)
-	iter := reqInfo.RoutePool.Endpoints(rt.defaultLoadBalance, stickyEndpointID)
+	iter := handler.NewEndpointIterator(
+		reqInfo.RoutePool, request, rt.defaultLoadBalance, rt.consistentHash, stickyEndpointID,
+	)
+	defer route.ReleaseIterator(iter)
+
+	rangeHeaders := captureRangeHeaders(request.Header)
+
+	maxAttempts := rt.maxAttempts(reqInfo)
 
 	logger := rt.logger
-	for retry := 0; retry < handler.MaxRetries; retry++ {
+	for retry := 0; retry < maxAttempts; retry++ {
 
 		if reqInfo.RouteServiceURL == nil {
 			logger.Debug("backend", zap.Int("attempt", retry))
@@ -104,13 +136,28 @@ func (rt *roundTripper) RoundTrip(request *http.Request) (*http.Response, error)
 			if err != nil {
 				break
 			}
+			if retry > 0 {
+				restoreRangeHeaders(request.Header, rangeHeaders)
+			}
 			logger = logger.With(zap.Nest("route-endpoint", endpoint.ToLogData()...))
+			attempt := middleware.Attempt{Request: request, Endpoint: endpoint, Number: retry + 1}
+			rt.observers.BeforeAttempt(attempt)
+			startedAt := time.Now()
 			res, err = rt.backendRoundTrip(request, endpoint, iter)
-			if err == nil || !retryableError(err) {
+			duration := time.Since(startedAt)
+			err = clientClosed(request, err)
+			recordAttempt(endpoint, err)
+			rt.observers.AfterAttempt(attempt, res, err, duration)
+			reqInfo.Attempts = append(reqInfo.Attempts, handlers.BackendAttempt{
+				Endpoint: endpoint.CanonicalAddr(), StartedAt: startedAt, Duration: duration, Err: err,
+			})
+			// a request its client closed fails the same way on every endpoint,
+			// none of which is to blame
+			if err == nil || !routererr.Retryable(err) || errors.Is(err, routererr.ErrClientClosed) {
 				break
 			}
 			iter.EndpointFailed()
-			logger.Error("backend-endpoint-failed", zap.Error(err))
+			logger.Error("backend-endpoint-failed", zap.Error(err), zap.String("error-kind", routererr.Name(err)))
 		} else {
 			logger.Debug(
 				"route-service",
@@ -127,21 +174,26 @@ func (rt *roundTripper) RoundTrip(request *http.Request) (*http.Response, error)
 				request.URL.Host = fmt.Sprintf("localhost:%d", rt.localPort)
 			}
 
-			res, err = rt.transport.RoundTrip(request)
+			attempt := middleware.Attempt{Request: request, Endpoint: endpoint, Number: retry + 1, RouteService: true}
+			rt.observers.BeforeAttempt(attempt)
+			startedAt := time.Now()
+			res, err = rt.transport.RoundTrip(upstream.Direct(request))
+			duration := time.Since(startedAt)
+			err = clientClosed(request, err)
+			rt.observers.AfterAttempt(attempt, res, err, duration)
+			reqInfo.Attempts = append(reqInfo.Attempts, handlers.BackendAttempt{
+				Endpoint: request.URL.Host, StartedAt: startedAt, Duration: duration, Err: err,
+			})
 			if err == nil {
-				if res != nil && (res.StatusCode < 200 || res.StatusCode >= 300) {
-					logger.Info(
-						"route-service-response",
-						zap.String("endpoint", request.URL.String()),
-						zap.Int("status-code", res.StatusCode),
-					)
+				if res != nil {
+					rt.routeServiceResponse(logger, reqInfo, request, res)
 				}
 				break
 			}
-			if !retryableError(err) {
+			if !routererr.Retryable(err) || errors.Is(err, routererr.ErrClientClosed) {
 				break
 			}
-			logger.Error("route-service-connection-failed", zap.Error(err))
+			logger.Error("route-service-connection-failed", zap.Error(err), zap.String("error-kind", routererr.Name(err)))
 		}
 	}
 
@@ -149,24 +201,63 @@ func (rt *roundTripper) RoundTrip(request *http.Request) (*http.Response, error)
 	reqInfo.StoppedAt = time.Now()
 
 	if err != nil {
+		// the error of the transport is returned as it is, its kind only
+		// decides the response and the metrics
+		failure := routererr.Classify(err)
+		closed := errors.Is(failure, routererr.ErrClientClosed)
+		if reqInfo.RouteServiceURL != nil && !closed {
+			failure = routererr.Wrap(routererr.ErrRouteServiceFailed, failure)
+		}
+
 		responseWriter := reqInfo.ProxyResponseWriter
-		responseWriter.Header().Set(router_http.CfRouterError, "endpoint_failure")
+		responseWriter.Header().Set(router_http.CfRouterError, routererr.RouterError(failure))
+
+		// no one is left to read the response, whose status only tells the
+		// access log and the metrics the request was abandoned
+		if closed {
+			logger.Info("client-closed-request", zap.Error(err))
+			responseWriter.WriteHeader(router_http.StatusClientClosedRequest)
+			rt.combinedReporter.CaptureRouterError(routererr.Name(failure))
+			responseWriter.Done()
+			return nil, err
+		}
+		if reqInfo.FromRouteService {
+			responseWriter.Header().Set(router_http.CfRouteServiceForwarded, "true")
+		}
 
-		logger.Info("status", zap.String("body", BadGatewayMessage))
+		if page := rt.errorPage(logger, reqInfo); page != nil {
+			logger.Info("status", zap.String("body", "custom error page"))
 
-		http.Error(responseWriter, BadGatewayMessage, http.StatusBadGateway)
+			responseWriter.Header().Set("Content-Type", "text/html; charset=utf-8")
+			responseWriter.Header().Set("X-Content-Type-Options", "nosniff")
+			responseWriter.WriteHeader(http.StatusBadGateway)
+			_, _ = responseWriter.Write(page)
+		} else {
+			logger.Info("status", zap.String("body", BadGatewayMessage))
+
+			http.Error(responseWriter, BadGatewayMessage, http.StatusBadGateway)
+		}
 		responseWriter.Header().Del("Connection")
 
-		logger.Error("endpoint-failed", zap.Error(err))
+		logger.Error("endpoint-failed", zap.Error(err), zap.String("error-kind", routererr.Name(failure)))
 
 		rt.combinedReporter.CaptureBadGateway()
+		rt.combinedReporter.CaptureRouterError(routererr.Name(failure))
 
 		responseWriter.Done()
 
 		return nil, err
 	}
 
-	if rt.traceKey != "" && request.Header.Get(router_http.VcapTraceHeader) == rt.traceKey {
+	if res != nil && reqInfo.FromRouteService {
+		if res.Header == nil {
+			res.Header = http.Header{}
+		}
+		res.Header.Set(router_http.CfRouteServiceForwarded, "true")
+	}
+
+	passThrough := reqInfo.RouteServiceDirectResponse && rt.routeServiceResponses.PassThrough
+	if rt.traceKey != "" && request.Header.Get(router_http.VcapTraceHeader) == rt.traceKey && !passThrough {
 		if res != nil && endpoint != nil {
 			res.Header.Set(router_http.VcapRouterHeader, rt.routerIP)
 			res.Header.Set(router_http.VcapBackendHeader, endpoint.CanonicalAddr())
@@ -184,6 +275,104 @@ func (rt *roundTripper) RoundTrip(request *http.Request) (*http.Response, error)
 	return res, nil
 }
 
+// maxAttempts returns how many times a request may be attempted: the
+// max_attempts of its domain profile or of the config, limited to the number
+// of endpoints of its route unless it goes to a route service
+func (rt *roundTripper) maxAttempts(reqInfo *handlers.RequestInfo) int {
+	attempts := rt.retries.MaxAttempts
+	if attempts <= 0 {
+		attempts = handler.MaxRetries
+	}
+	if reqInfo.DomainProfile != nil && reqInfo.DomainProfile.MaxAttempts > 0 {
+		attempts = reqInfo.DomainProfile.MaxAttempts
+	}
+
+	if rt.retries.LimitToPoolSize && reqInfo.RouteServiceURL == nil {
+		if size := reqInfo.RoutePool.NumEndpoints(); size > 0 && size < attempts {
+			attempts = size
+		}
+	}
+	return attempts
+}
+
+// errorPage returns the page registered for the route of a request its
+// endpoints failed to handle, or nil when there is none
+func (rt *roundTripper) errorPage(logger logger.Logger, reqInfo *handlers.RequestInfo) []byte {
+	if reqInfo.RouteServiceURL != nil {
+		return nil
+	}
+
+	name, html := reqInfo.RoutePool.ErrorPage()
+	if html != "" {
+		return []byte(html)
+	}
+	if name == "" {
+		return nil
+	}
+	page, ok := rt.errorPages.Pages[name]
+	if !ok {
+		logger.Info("error-page-not-configured", zap.String("error-page", name))
+		return nil
+	}
+	return page
+}
+
+// routeServiceResponse reports the response of a route service, telling the
+// responses it sent itself from the ones to requests it forwarded. Those are
+// reported and logged by the gorouter that served the forwarded request.
+func (rt *roundTripper) routeServiceResponse(logger logger.Logger, reqInfo *handlers.RequestInfo, request *http.Request, res *http.Response) {
+	forwarded := res.Header.Get(router_http.CfRouteServiceForwarded) != ""
+	res.Header.Del(router_http.CfRouteServiceForwarded)
+
+	rt.combinedReporter.CaptureRouteServiceResponse(res)
+	if forwarded {
+		return
+	}
+
+	reqInfo.RouteServiceDirectResponse = true
+	rt.combinedReporter.CaptureRouteServiceDirectResponse(res.StatusCode)
+	if res.StatusCode >= 200 && res.StatusCode < 300 {
+		return
+	}
+
+	fields := []zap.Field{
+		zap.String("endpoint", request.URL.String()),
+		zap.Int("status-code", res.StatusCode),
+	}
+	switch {
+	case rt.routeServiceResponses.ErrorStatus > 0 && res.StatusCode >= rt.routeServiceResponses.ErrorStatus:
+		logger.Error("route-service-response", fields...)
+	case rt.routeServiceResponses.LogLevel == "debug":
+		logger.Debug("route-service-response", fields...)
+	default:
+		logger.Info("route-service-response", fields...)
+	}
+}
+
+var rangeHeaderNames = []string{"Range", "If-Range"}
+
+// captureRangeHeaders copies the headers a retried attempt must send unchanged
+// for the backend to answer with the same partial content.
+func captureRangeHeaders(h http.Header) http.Header {
+	captured := http.Header{}
+	for _, name := range rangeHeaderNames {
+		if v, ok := h[name]; ok {
+			captured[name] = append([]string(nil), v...)
+		}
+	}
+	return captured
+}
+
+func restoreRangeHeaders(h http.Header, captured http.Header) {
+	for _, name := range rangeHeaderNames {
+		if v, ok := captured[name]; ok {
+			h[name] = append([]string(nil), v...)
+		} else {
+			h.Del(name)
+		}
+	}
+}
+
 func (rt *roundTripper) CancelRequest(request *http.Request) {
 	rt.transport.CancelRequest(request)
 }
@@ -202,13 +391,40 @@ func (rt *roundTripper) backendRoundTrip(
 	iter.PreRequest(endpoint)
 
 	rt.combinedReporter.CaptureRoutingRequest(endpoint)
-	res, err := rt.transport.RoundTrip(request)
+	done := rt.inFlight.StartEndpoint(endpoint.CanonicalAddr())
+	res, err := rt.transport.RoundTrip(upstream.WithEndpoint(request, endpoint))
 
 	// decrement connection stats
 	iter.PostRequest(endpoint)
+
+	// the request stays in flight until its response has been copied
+	if res != nil && res.Body != nil {
+		res.Body = &inFlightBody{ReadCloser: res.Body, done: done}
+	} else {
+		done()
+	}
 	return res, err
 }
 
+// clientClosed wraps err with ErrClientClosed when the client of request went
+// away, whatever failure the transport made of it
+func clientClosed(request *http.Request, err error) error {
+	if err != nil && request.Context().Err() == context.Canceled {
+		return routererr.Wrap(routererr.ErrClientClosed, err)
+	}
+	return err
+}
+
+type inFlightBody struct {
+	io.ReadCloser
+	done func()
+}
+
+func (b *inFlightBody) Close() error {
+	b.done()
+	return b.ReadCloser.Close()
+}
+
 func (rt *roundTripper) selectEndpoint(iter route.EndpointIterator, request *http.Request) (*route.Endpoint, error) {
 	endpoint := iter.Next()
 	if endpoint == nil {
@@ -274,16 +490,22 @@ func getStickySession(request *http.Request) string {
 	return ""
 }
 
-func retryableError(err error) bool {
-	ne, netErr := err.(*net.OpError)
-	if netErr && (ne.Op == "dial" || ne.Op == "read" && ne.Err.Error() == "read: connection reset by peer") {
-		return true
-	}
-	return false
-}
-
 func newRouteServiceEndpoint() *route.Endpoint {
 	return &route.Endpoint{
 		Tags: map[string]string{},
 	}
 }
+
+// recordAttempt keeps the outcome of an attempt in the stats of endpoint,
+// reported by the route health endpoint. Attempts abandoned by the client
+// count for nothing.
+func recordAttempt(endpoint *route.Endpoint, err error) {
+	if endpoint.Stats == nil || errors.Is(err, routererr.ErrClientClosed) {
+		return
+	}
+	if err != nil {
+		endpoint.Stats.Failed()
+	} else {
+		endpoint.Stats.Succeeded(time.Now())
+	}
+}
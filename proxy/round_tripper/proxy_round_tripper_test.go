@@ -2,7 +2,9 @@ package round_tripper_test
 
 import (
 	"bytes"
+	"context"
 	"errors"
+	"fmt"
 	"io/ioutil"
 	"net"
 	"net/http"
@@ -13,13 +15,17 @@ import (
 	"time"
 
 	"code.cloudfoundry.org/gorouter/access_log/schema"
+	"code.cloudfoundry.org/gorouter/config"
 	"code.cloudfoundry.org/gorouter/handlers"
+	"code.cloudfoundry.org/gorouter/metrics"
 	"code.cloudfoundry.org/gorouter/metrics/fakes"
+	"code.cloudfoundry.org/gorouter/middleware"
 	"code.cloudfoundry.org/gorouter/proxy/handler"
 	"code.cloudfoundry.org/gorouter/proxy/round_tripper"
 	roundtripperfakes "code.cloudfoundry.org/gorouter/proxy/round_tripper/fakes"
 	"code.cloudfoundry.org/gorouter/proxy/utils"
 	"code.cloudfoundry.org/gorouter/route"
+	"code.cloudfoundry.org/gorouter/routererr"
 	"code.cloudfoundry.org/gorouter/test_util"
 	"code.cloudfoundry.org/routing-api/models"
 
@@ -43,6 +49,21 @@ func (t *testBody) Close() error {
 	return nil
 }
 
+type recordingObserver struct {
+	before []middleware.Attempt
+	after  []error
+}
+
+func (o *recordingObserver) Name() string { return "recording" }
+
+func (o *recordingObserver) BeforeAttempt(a middleware.Attempt) {
+	o.before = append(o.before, a)
+}
+
+func (o *recordingObserver) AfterAttempt(a middleware.Attempt, res *http.Response, err error, d time.Duration) {
+	o.after = append(o.after, err)
+}
+
 var _ = Describe("ProxyRoundTripper", func() {
 	Context("RoundTrip", func() {
 		var (
@@ -104,9 +125,9 @@ var _ = Describe("ProxyRoundTripper", func() {
 			combinedReporter = new(fakes.FakeCombinedReporter)
 
 			proxyRoundTripper = round_tripper.NewProxyRoundTripper(
-				transport, logger, "my_trace_key", routerIP, "",
+				transport, logger, "my_trace_key", routerIP, "", config.ConsistentHashConfig{},
 				combinedReporter, false,
-				1234,
+				1234, nil, config.RouteServiceResponsesConfig{LogLevel: "info"}, config.ErrorPagesConfig{}, config.RetriesConfig{}, nil,
 			)
 		})
 
@@ -140,6 +161,61 @@ var _ = Describe("ProxyRoundTripper", func() {
 			})
 		})
 
+		Context("when requests in flight are tracked", func() {
+			var inFlight *metrics.InFlightTracker
+
+			BeforeEach(func() {
+				inFlight = metrics.NewInFlightTracker(time.Second)
+				proxyRoundTripper = round_tripper.NewProxyRoundTripper(
+					transport, logger, "my_trace_key", routerIP, "", config.ConsistentHashConfig{},
+					combinedReporter, false,
+					1234, inFlight, config.RouteServiceResponsesConfig{LogLevel: "info"}, config.ErrorPagesConfig{}, config.RetriesConfig{}, nil,
+				)
+			})
+
+			It("counts the request to the endpoint until the response body is closed", func() {
+				transport.RoundTripReturns(&http.Response{
+					StatusCode: http.StatusOK,
+					Body:       ioutil.NopCloser(bytes.NewBufferString("hello")),
+				}, nil)
+
+				res, err := proxyRoundTripper.RoundTrip(req)
+				Expect(err).ToNot(HaveOccurred())
+				Expect(inFlight.Snapshot().Endpoints).To(Equal(map[string]int64{"1.1.1.1:9090": 1}))
+
+				Expect(res.Body.Close()).To(Succeed())
+				Expect(inFlight.Snapshot().Endpoints).To(BeEmpty())
+			})
+
+			It("stops counting failed attempts right away", func() {
+				transport.RoundTripReturns(nil, errors.New("boom"))
+
+				_, err := proxyRoundTripper.RoundTrip(req)
+				Expect(err).To(HaveOccurred())
+				Expect(inFlight.Snapshot().Endpoints).To(BeEmpty())
+			})
+		})
+
+		Context("endpoint stats", func() {
+			It("records when the endpoint last answered", func() {
+				transport.RoundTripReturns(resp.Result(), nil)
+
+				_, err := proxyRoundTripper.RoundTrip(req)
+				Expect(err).ToNot(HaveOccurred())
+				Expect(endpoint.Stats.LastSuccess()).To(BeTemporally("~", time.Now(), time.Second))
+				Expect(endpoint.Stats.Failures()).To(BeZero())
+			})
+
+			It("counts the failed attempts", func() {
+				transport.RoundTripReturns(nil, dialError)
+
+				_, err := proxyRoundTripper.RoundTrip(req)
+				Expect(err).To(HaveOccurred())
+				Expect(endpoint.Stats.Failures()).To(BeEquivalentTo(3))
+				Expect(endpoint.Stats.LastSuccess().IsZero()).To(BeTrue())
+			})
+		})
+
 		Context("HTTP headers", func() {
 			BeforeEach(func() {
 				transport.RoundTripReturns(resp.Result(), nil)
@@ -181,6 +257,19 @@ var _ = Describe("ProxyRoundTripper", func() {
 				})
 			})
 
+			Context("when the request was forwarded by a route service", func() {
+				BeforeEach(func() {
+					reqInfo.FromRouteService = true
+				})
+
+				It("marks the response as forwarded", func() {
+					backendResp, err := proxyRoundTripper.RoundTrip(req)
+					Expect(err).ToNot(HaveOccurred())
+
+					Expect(backendResp.Header.Get(router_http.CfRouteServiceForwarded)).To(Equal("true"))
+				})
+			})
+
 			Context("when VcapTraceHeader is not set", func() {
 				It("does not set the trace headers on the response", func() {
 					backendResp, err := proxyRoundTripper.RoundTrip(req)
@@ -243,6 +332,67 @@ var _ = Describe("ProxyRoundTripper", func() {
 			})
 		})
 
+		Context("when the client closes the request", func() {
+			BeforeEach(func() {
+				ctx, cancel := context.WithCancel(req.Context())
+				req = req.WithContext(ctx)
+				cancel()
+				transport.RoundTripReturns(nil, context.Canceled)
+			})
+
+			It("records status 499 rather than bad gateway", func() {
+				_, err := proxyRoundTripper.RoundTrip(req)
+				Expect(errors.Is(err, routererr.ErrClientClosed)).To(BeTrue())
+				Expect(transport.RoundTripCallCount()).To(Equal(1))
+
+				Expect(reqInfo.ProxyResponseWriter.Status()).To(Equal(router_http.StatusClientClosedRequest))
+				Expect(resp.Header().Get(router_http.CfRouterError)).To(Equal("client_closed"))
+				Expect(reqInfo.RouteEndpoint).To(Equal(endpoint))
+			})
+
+			It("captures the closed request rather than a bad gateway", func() {
+				_, err := proxyRoundTripper.RoundTrip(req)
+				Expect(err).To(HaveOccurred())
+
+				Expect(combinedReporter.CaptureBadGatewayCallCount()).To(Equal(0))
+				Expect(combinedReporter.CaptureRouterErrorCallCount()).To(Equal(1))
+				Expect(combinedReporter.CaptureRouterErrorArgsForCall(0)).To(Equal("client_closed"))
+			})
+
+			It("does not count the attempt against the endpoint", func() {
+				_, err := proxyRoundTripper.RoundTrip(req)
+				Expect(err).To(HaveOccurred())
+
+				Expect(endpoint.Stats.Failures()).To(BeZero())
+				Expect(logger.Buffer()).To(gbytes.Say(`client-closed-request`))
+			})
+
+			Context("while dialing the backend", func() {
+				BeforeEach(func() {
+					transport.RoundTripReturns(nil, dialError)
+					routePool.Put(route.NewEndpoint("appId", "1.1.1.2", uint16(9090), "instanceId2", "1",
+						map[string]string{}, 0, "", models.ModificationTag{}, ""))
+				})
+
+				It("neither retries nor reports the endpoint failure", func() {
+					_, err := proxyRoundTripper.RoundTrip(req)
+					Expect(errors.Is(err, routererr.ErrClientClosed)).To(BeTrue())
+					Expect(transport.RoundTripCallCount()).To(Equal(1))
+
+					Expect(logger.Buffer()).ToNot(gbytes.Say(`backend-endpoint-failed`))
+					Expect(reqInfo.ProxyResponseWriter.Status()).To(Equal(router_http.StatusClientClosedRequest))
+				})
+
+				It("does not mark the endpoint failed", func() {
+					_, err := proxyRoundTripper.RoundTrip(req)
+					Expect(err).To(HaveOccurred())
+
+					iter := route.NewRoundRobin(routePool, "")
+					Expect([]*route.Endpoint{iter.Next(), iter.Next()}).To(ContainElement(reqInfo.RouteEndpoint))
+				})
+			})
+		})
+
 		Context("when backend is unavailable due to dial error", func() {
 			BeforeEach(func() {
 				transport.RoundTripReturns(nil, dialError)
@@ -262,6 +412,58 @@ var _ = Describe("ProxyRoundTripper", func() {
 				Expect(reqInfo.StoppedAt).To(BeTemporally("~", time.Now(), 50*time.Millisecond))
 			})
 
+			Context("when the domain profile of the request limits the attempts", func() {
+				BeforeEach(func() {
+					reqInfo.DomainProfile = &config.DomainProfile{Domain: "myapp.com", MaxAttempts: 1}
+				})
+
+				It("tries only that many times", func() {
+					_, err := proxyRoundTripper.RoundTrip(req)
+					Expect(err).To(MatchError(dialError))
+					Expect(transport.RoundTripCallCount()).To(Equal(1))
+					Expect(resp.Code).To(Equal(http.StatusBadGateway))
+				})
+			})
+
+			Context("when the attempts are limited to the pool size", func() {
+				var retries config.RetriesConfig
+
+				BeforeEach(func() {
+					retries = config.RetriesConfig{MaxAttempts: 3, LimitToPoolSize: true}
+				})
+
+				JustBeforeEach(func() {
+					proxyRoundTripper = round_tripper.NewProxyRoundTripper(
+						transport, logger, "my_trace_key", routerIP, "", config.ConsistentHashConfig{},
+						combinedReporter, false,
+						1234, nil, config.RouteServiceResponsesConfig{LogLevel: "info"}, config.ErrorPagesConfig{}, retries, nil,
+					)
+				})
+
+				It("tries the single endpoint once", func() {
+					_, err := proxyRoundTripper.RoundTrip(req)
+					Expect(err).To(MatchError(dialError))
+					Expect(transport.RoundTripCallCount()).To(Equal(1))
+					Expect(resp.Code).To(Equal(http.StatusBadGateway))
+				})
+
+				Context("when the pool is larger than the max attempts", func() {
+					BeforeEach(func() {
+						for i := 2; i <= 5; i++ {
+							routePool.Put(route.NewEndpoint("appId", fmt.Sprintf("1.1.1.%d", i), uint16(9090), fmt.Sprintf("instanceId%d", i), "1",
+								map[string]string{}, 0, "", models.ModificationTag{}, ""))
+						}
+						retries.MaxAttempts = 4
+					})
+
+					It("tries up to the max attempts", func() {
+						_, err := proxyRoundTripper.RoundTrip(req)
+						Expect(err).To(MatchError(dialError))
+						Expect(transport.RoundTripCallCount()).To(Equal(4))
+					})
+				})
+			})
+
 			It("captures each routing request to the backend", func() {
 				_, err := proxyRoundTripper.RoundTrip(req)
 				Expect(err).To(MatchError(dialError))
@@ -279,6 +481,14 @@ var _ = Describe("ProxyRoundTripper", func() {
 				Expect(combinedReporter.CaptureBadGatewayCallCount()).To(Equal(1))
 			})
 
+			It("captures the kind of the failure in the metrics reporter", func() {
+				_, err := proxyRoundTripper.RoundTrip(req)
+				Expect(err).To(MatchError(dialError))
+
+				Expect(combinedReporter.CaptureRouterErrorCallCount()).To(Equal(1))
+				Expect(combinedReporter.CaptureRouterErrorArgsForCall(0)).To(Equal("dial_failed"))
+			})
+
 			It("does not log anything about route services", func() {
 				_, err := proxyRoundTripper.RoundTrip(req)
 				Expect(err).To(MatchError(dialError))
@@ -295,6 +505,80 @@ var _ = Describe("ProxyRoundTripper", func() {
 					Expect(logger.Buffer()).To(gbytes.Say(`backend-endpoint-failed.*dial`))
 				}
 			})
+
+			Context("with an attempt observer", func() {
+				var observer *recordingObserver
+
+				BeforeEach(func() {
+					observer = &recordingObserver{}
+					proxyRoundTripper = round_tripper.NewProxyRoundTripper(
+						transport, logger, "my_trace_key", routerIP, "", config.ConsistentHashConfig{},
+						combinedReporter, false,
+						1234, nil, config.RouteServiceResponsesConfig{LogLevel: "info"}, config.ErrorPagesConfig{}, config.RetriesConfig{},
+						middleware.AttemptObservers{observer},
+					)
+				})
+
+				It("tells the observer about every attempt", func() {
+					_, err := proxyRoundTripper.RoundTrip(req)
+					Expect(err).To(MatchError(dialError))
+
+					Expect(observer.before).To(HaveLen(3))
+					for i, attempt := range observer.before {
+						Expect(attempt.Number).To(Equal(i + 1))
+						Expect(attempt.Endpoint).To(Equal(endpoint))
+						Expect(attempt.RouteService).To(BeFalse())
+					}
+					Expect(observer.after).To(Equal([]error{dialError, dialError, dialError}))
+				})
+			})
+		})
+
+		Context("when the route registered an error page", func() {
+			BeforeEach(func() {
+				transport.RoundTripReturns(nil, dialError)
+				proxyRoundTripper = round_tripper.NewProxyRoundTripper(
+					transport, logger, "my_trace_key", routerIP, "", config.ConsistentHashConfig{},
+					combinedReporter, false,
+					1234, nil, config.RouteServiceResponsesConfig{LogLevel: "info"},
+					config.ErrorPagesConfig{Pages: map[string][]byte{"branded": []byte("<h1>branded</h1>")}},
+					config.RetriesConfig{}, nil,
+				)
+			})
+
+			It("serves the inline page with status bad gateway", func() {
+				endpoint.ErrorPageHTML = "<h1>inline</h1>"
+
+				_, err := proxyRoundTripper.RoundTrip(req)
+				Expect(err).To(MatchError(dialError))
+
+				Expect(resp.Code).To(Equal(http.StatusBadGateway))
+				Expect(resp.Header().Get("Content-Type")).To(Equal("text/html; charset=utf-8"))
+				Expect(resp.Header().Get(router_http.CfRouterError)).To(Equal("endpoint_failure"))
+				Expect(resp.Body.String()).To(Equal("<h1>inline</h1>"))
+				Expect(combinedReporter.CaptureBadGatewayCallCount()).To(Equal(1))
+			})
+
+			It("serves the configured page it names", func() {
+				endpoint.ErrorPage = "branded"
+
+				_, err := proxyRoundTripper.RoundTrip(req)
+				Expect(err).To(MatchError(dialError))
+
+				Expect(resp.Code).To(Equal(http.StatusBadGateway))
+				Expect(resp.Body.String()).To(Equal("<h1>branded</h1>"))
+			})
+
+			It("falls back to the generic message when the named page is not configured", func() {
+				endpoint.ErrorPage = "unknown"
+
+				_, err := proxyRoundTripper.RoundTrip(req)
+				Expect(err).To(MatchError(dialError))
+
+				Expect(resp.Code).To(Equal(http.StatusBadGateway))
+				Expect(resp.Body.String()).To(ContainSubstring(round_tripper.BadGatewayMessage))
+				Expect(logger.Buffer()).To(gbytes.Say(`error-page-not-configured`))
+			})
 		})
 
 		Context("when backend is unavailable due to connection reset error", func() {
@@ -440,6 +724,52 @@ var _ = Describe("ProxyRoundTripper", func() {
 				Expect(reqInfo.StoppedAt).To(BeTemporally("~", time.Now(), 50*time.Millisecond))
 			})
 
+			It("records every attempt on the request info", func() {
+				_, err := proxyRoundTripper.RoundTrip(req)
+				Expect(err).ToNot(HaveOccurred())
+
+				Expect(reqInfo.Attempts).To(HaveLen(2))
+				Expect(reqInfo.Attempts[0].Endpoint).To(Equal(endpoint.CanonicalAddr()))
+				Expect(reqInfo.Attempts[0].Err).To(Equal(dialError))
+				Expect(reqInfo.Attempts[1].Err).NotTo(HaveOccurred())
+				Expect(reqInfo.Attempts[1].StartedAt).NotTo(BeTemporally("<", reqInfo.Attempts[0].StartedAt))
+			})
+
+			Context("when the request has range headers", func() {
+				var rangeHeaders []http.Header
+
+				BeforeEach(func() {
+					req.Header.Set("Range", "bytes=100-199")
+					req.Header.Set("If-Range", `"etag"`)
+					rangeHeaders = nil
+
+					transport.RoundTripStub = func(req *http.Request) (*http.Response, error) {
+						rangeHeaders = append(rangeHeaders, http.Header{
+							"Range":    req.Header["Range"],
+							"If-Range": req.Header["If-Range"],
+						})
+						if firstRequest {
+							firstRequest = false
+							req.Header.Del("Range")
+							req.Header.Set("If-Range", "mangled")
+							return nil, dialError
+						}
+						return &http.Response{StatusCode: http.StatusPartialContent}, nil
+					}
+				})
+
+				It("sends the original range headers on every attempt", func() {
+					_, err := proxyRoundTripper.RoundTrip(req)
+					Expect(err).ToNot(HaveOccurred())
+
+					Expect(rangeHeaders).To(HaveLen(2))
+					for _, h := range rangeHeaders {
+						Expect(h.Get("Range")).To(Equal("bytes=100-199"))
+						Expect(h.Get("If-Range")).To(Equal(`"etag"`))
+					}
+				})
+			})
+
 			It("logs one error and reports the endpoint failure", func() {
 				// TODO: Test "iter.EndpointFailed"
 				_, err := proxyRoundTripper.RoundTrip(req)
@@ -535,7 +865,53 @@ var _ = Describe("ProxyRoundTripper", func() {
 					_, err := proxyRoundTripper.RoundTrip(req)
 					Expect(err).ToNot(HaveOccurred())
 
-					Expect(logger.Buffer()).To(gbytes.Say(`response.*status-code":418`))
+					Expect(logger.Buffer()).To(gbytes.Say(`"log_level":1.*response.*status-code":418`))
+				})
+
+				It("reports the direct response", func() {
+					_, err := proxyRoundTripper.RoundTrip(req)
+					Expect(err).ToNot(HaveOccurred())
+
+					Expect(combinedReporter.CaptureRouteServiceResponseCallCount()).To(Equal(1))
+					Expect(combinedReporter.CaptureRouteServiceDirectResponseCallCount()).To(Equal(1))
+					Expect(combinedReporter.CaptureRouteServiceDirectResponseArgsForCall(0)).To(Equal(http.StatusTeapot))
+					Expect(reqInfo.RouteServiceDirectResponse).To(BeTrue())
+				})
+
+				Context("when direct responses are logged as errors from a status on", func() {
+					BeforeEach(func() {
+						proxyRoundTripper = round_tripper.NewProxyRoundTripper(
+							transport, logger, "my_trace_key", routerIP, "", config.ConsistentHashConfig{},
+							combinedReporter, false,
+							1234, nil, config.RouteServiceResponsesConfig{LogLevel: "debug", ErrorStatus: 400}, config.ErrorPagesConfig{}, config.RetriesConfig{}, nil,
+						)
+					})
+
+					It("logs the response as an error", func() {
+						_, err := proxyRoundTripper.RoundTrip(req)
+						Expect(err).ToNot(HaveOccurred())
+
+						Expect(logger.Buffer()).To(gbytes.Say(`"log_level":2.*route-service-response.*status-code":418`))
+					})
+				})
+			})
+
+			Context("when the route service forwarded the request", func() {
+				BeforeEach(func() {
+					transport.RoundTripReturns(&http.Response{
+						StatusCode: http.StatusBadGateway,
+						Header:     http.Header{router_http.CfRouteServiceForwarded: []string{"true"}},
+					}, nil)
+				})
+
+				It("does not treat the response as a direct response", func() {
+					res, err := proxyRoundTripper.RoundTrip(req)
+					Expect(err).ToNot(HaveOccurred())
+
+					Expect(res.Header.Get(router_http.CfRouteServiceForwarded)).To(BeEmpty())
+					Expect(combinedReporter.CaptureRouteServiceResponseCallCount()).To(Equal(1))
+					Expect(combinedReporter.CaptureRouteServiceDirectResponseCallCount()).To(Equal(0))
+					Expect(logger.Buffer()).ToNot(gbytes.Say(`route-service-response`))
 				})
 			})
 
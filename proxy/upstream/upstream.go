@@ -0,0 +1,299 @@
+package upstream
+
+import (
+	"bufio"
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"code.cloudfoundry.org/gorouter/config"
+	"code.cloudfoundry.org/gorouter/route"
+)
+
+// DialFunc dials an address, like net.Dial
+type DialFunc func(network, addr string) (net.Conn, error)
+
+// HandshakeTimeout bounds the exchange with a proxy once connected to it
+const HandshakeTimeout = 5 * time.Second
+
+// Proxy dials addresses through an HTTP CONNECT or SOCKS5 proxy
+type Proxy struct {
+	url  *url.URL
+	dial DialFunc
+}
+
+// New creates a proxy from a URL of the forms accepted in
+// config.UpstreamProxy, connecting to it with dial
+func New(rawURL string, dial DialFunc) (*Proxy, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, err
+	}
+	if u.Scheme != "http" && u.Scheme != "socks5" {
+		return nil, fmt.Errorf("unsupported upstream proxy scheme %q", u.Scheme)
+	}
+	if u.Host == "" {
+		return nil, errors.New("upstream proxy url has no host")
+	}
+	return &Proxy{url: u, dial: dial}, nil
+}
+
+// Dial connects to addr through the proxy. Failing to reach the proxy or to
+// have it connect to addr are both reported as dial errors, as no request was
+// sent yet.
+func (p *Proxy) Dial(network, addr string) (net.Conn, error) {
+	conn, err := p.dial("tcp", p.url.Host)
+	if err != nil {
+		return nil, err
+	}
+
+	conn.SetDeadline(time.Now().Add(HandshakeTimeout))
+	if p.url.Scheme == "socks5" {
+		err = p.socks5(conn, addr)
+	} else {
+		conn, err = p.connect(conn, addr)
+	}
+	if err != nil {
+		conn.Close()
+		return nil, &net.OpError{Op: "dial", Net: network, Addr: proxyAddr(addr), Err: err}
+	}
+	conn.SetDeadline(time.Time{})
+	return conn, nil
+}
+
+// connect has an HTTP proxy tunnel conn to addr
+func (p *Proxy) connect(conn net.Conn, addr string) (net.Conn, error) {
+	req := &http.Request{
+		Method: "CONNECT",
+		URL:    &url.URL{Opaque: addr},
+		Host:   addr,
+		Header: http.Header{},
+	}
+	if user := p.url.User; user != nil {
+		password, _ := user.Password()
+		credentials := base64.StdEncoding.EncodeToString([]byte(user.Username() + ":" + password))
+		req.Header.Set("Proxy-Authorization", "Basic "+credentials)
+	}
+	if err := req.Write(conn); err != nil {
+		return conn, err
+	}
+
+	br := bufio.NewReader(conn)
+	res, err := http.ReadResponse(br, req)
+	if err != nil {
+		return conn, err
+	}
+	res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		return conn, fmt.Errorf("upstream proxy refused to connect: %s", res.Status)
+	}
+
+	if br.Buffered() > 0 {
+		return &bufferedConn{Conn: conn, r: br}, nil
+	}
+	return conn, nil
+}
+
+// bufferedConn reads the bytes the backend sent along with the response of
+// the proxy before those still on conn
+type bufferedConn struct {
+	net.Conn
+	r *bufio.Reader
+}
+
+func (c *bufferedConn) Read(b []byte) (int, error) {
+	return c.r.Read(b)
+}
+
+const (
+	socks5Version          = 0x05
+	socks5NoAuth           = 0x00
+	socks5UserPassword     = 0x02
+	socks5Connect          = 0x01
+	socks5IPv4             = 0x01
+	socks5DomainName       = 0x03
+	socks5IPv6             = 0x04
+	socks5UserPasswordAuth = 0x01
+)
+
+// socks5 has a SOCKS5 proxy connect conn to addr, as described in RFC 1928,
+// authenticating with a user name and password as in RFC 1929
+func (p *Proxy) socks5(conn net.Conn, addr string) error {
+	host, portStr, err := net.SplitHostPort(addr)
+	if err != nil {
+		return err
+	}
+	port, err := strconv.ParseUint(portStr, 10, 16)
+	if err != nil {
+		return fmt.Errorf("invalid port %q", portStr)
+	}
+
+	method := byte(socks5NoAuth)
+	if p.url.User != nil {
+		method = socks5UserPassword
+	}
+	if _, err := conn.Write([]byte{socks5Version, 1, method}); err != nil {
+		return err
+	}
+	reply := make([]byte, 2)
+	if _, err := io.ReadFull(conn, reply); err != nil {
+		return err
+	}
+	if reply[0] != socks5Version {
+		return errors.New("upstream proxy does not speak SOCKS5")
+	}
+	if reply[1] != method {
+		return errors.New("upstream proxy accepts none of the offered authentication methods")
+	}
+
+	if method == socks5UserPassword {
+		username := p.url.User.Username()
+		password, _ := p.url.User.Password()
+		if len(username) > 255 || len(password) > 255 {
+			return errors.New("upstream proxy credentials are too long")
+		}
+		auth := []byte{socks5UserPasswordAuth, byte(len(username))}
+		auth = append(auth, username...)
+		auth = append(auth, byte(len(password)))
+		auth = append(auth, password...)
+		if _, err := conn.Write(auth); err != nil {
+			return err
+		}
+		if _, err := io.ReadFull(conn, reply); err != nil {
+			return err
+		}
+		if reply[1] != 0 {
+			return errors.New("upstream proxy rejected the credentials")
+		}
+	}
+
+	request := []byte{socks5Version, socks5Connect, 0}
+	if ip := net.ParseIP(host); ip == nil {
+		if len(host) > 255 {
+			return fmt.Errorf("host name %q is too long", host)
+		}
+		request = append(request, socks5DomainName, byte(len(host)))
+		request = append(request, host...)
+	} else if ip4 := ip.To4(); ip4 != nil {
+		request = append(request, socks5IPv4)
+		request = append(request, ip4...)
+	} else {
+		request = append(request, socks5IPv6)
+		request = append(request, ip...)
+	}
+	request = append(request, byte(port>>8), byte(port))
+	if _, err := conn.Write(request); err != nil {
+		return err
+	}
+
+	header := make([]byte, 4)
+	if _, err := io.ReadFull(conn, header); err != nil {
+		return err
+	}
+	if header[1] != 0 {
+		return fmt.Errorf("upstream proxy failed to connect: SOCKS5 reply %d", header[1])
+	}
+	// the address the proxy bound is of no use, but must be read
+	var bound int
+	switch header[3] {
+	case socks5IPv4:
+		bound = net.IPv4len
+	case socks5IPv6:
+		bound = net.IPv6len
+	case socks5DomainName:
+		length := make([]byte, 1)
+		if _, err := io.ReadFull(conn, length); err != nil {
+			return err
+		}
+		bound = int(length[0])
+	default:
+		return fmt.Errorf("upstream proxy replied with address type %d", header[3])
+	}
+	_, err = io.ReadFull(conn, make([]byte, bound+2))
+	return err
+}
+
+type proxyAddr string
+
+func (a proxyAddr) Network() string { return "tcp" }
+func (a proxyAddr) String() string  { return string(a) }
+
+// Proxies dials backends through the upstream proxies their endpoints name
+type Proxies struct {
+	proxies      map[string]*Proxy
+	defaultProxy *Proxy
+	dial         DialFunc
+}
+
+// NewProxies creates the configured proxies, which connect with dial, as do
+// requests dialed directly
+func NewProxies(c config.UpstreamProxiesConfig, dial DialFunc) (*Proxies, error) {
+	ps := &Proxies{
+		proxies: make(map[string]*Proxy, len(c.Proxies)),
+		dial:    dial,
+	}
+	for _, proxy := range c.Proxies {
+		p, err := New(proxy.URL, dial)
+		if err != nil {
+			return nil, fmt.Errorf("upstream proxy %s: %s", proxy.Name, err)
+		}
+		ps.proxies[proxy.Name] = p
+	}
+	if c.Default != "" {
+		ps.defaultProxy = ps.proxies[c.Default]
+		if ps.defaultProxy == nil {
+			return nil, fmt.Errorf("default upstream proxy %s is not configured", c.Default)
+		}
+	}
+	return ps, nil
+}
+
+type contextKey struct{}
+
+// direct marks requests dialed without a proxy in their context
+const direct = ""
+
+// WithEndpoint returns the request to be sent to endpoint, dialed through the
+// upstream proxy the endpoint names. Requests to endpoints naming none are
+// returned as they are, and dialed through the default proxy.
+func WithEndpoint(req *http.Request, endpoint *route.Endpoint) *http.Request {
+	if endpoint.UpstreamProxy == "" {
+		return req
+	}
+	return req.WithContext(context.WithValue(req.Context(), contextKey{}, endpoint.UpstreamProxy))
+}
+
+// Direct returns req dialed without any proxy, as are the requests to route
+// services
+func Direct(req *http.Request) *http.Request {
+	return req.WithContext(context.WithValue(req.Context(), contextKey{}, direct))
+}
+
+// DialContext dials addr through the proxy named in ctx, see WithEndpoint and
+// Direct
+func (ps *Proxies) DialContext(ctx context.Context, network, addr string) (net.Conn, error) {
+	proxy := ps.defaultProxy
+	if name, ok := ctx.Value(contextKey{}).(string); ok {
+		if name == direct {
+			return ps.dial(network, addr)
+		}
+		proxy = ps.proxies[name]
+		if proxy == nil {
+			return nil, &net.OpError{
+				Op: "dial", Net: network, Addr: proxyAddr(addr),
+				Err: fmt.Errorf("upstream proxy %s is not configured", name),
+			}
+		}
+	}
+	if proxy == nil {
+		return ps.dial(network, addr)
+	}
+	return proxy.Dial(network, addr)
+}
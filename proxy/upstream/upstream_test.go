@@ -0,0 +1,388 @@
+package upstream_test
+
+import (
+	"bufio"
+	"context"
+	"encoding/binary"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync"
+
+	"code.cloudfoundry.org/gorouter/config"
+	"code.cloudfoundry.org/gorouter/proxy/upstream"
+	"code.cloudfoundry.org/gorouter/route"
+	"code.cloudfoundry.org/routing-api/models"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+// testServer accepts conns on a local port, handing each to handle
+type testServer struct {
+	listener net.Listener
+	lock     sync.Mutex
+	accepted int
+}
+
+func newTestServer(handle func(net.Conn)) *testServer {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	Expect(err).NotTo(HaveOccurred())
+
+	s := &testServer{listener: listener}
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			s.lock.Lock()
+			s.accepted++
+			s.lock.Unlock()
+			go func() {
+				defer conn.Close()
+				handle(conn)
+			}()
+		}
+	}()
+	return s
+}
+
+func (s *testServer) addr() string { return s.listener.Addr().String() }
+
+func (s *testServer) acceptedConns() int {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	return s.accepted
+}
+
+func (s *testServer) close() { s.listener.Close() }
+
+func tunnel(client net.Conn, target string) {
+	backend, err := net.Dial("tcp", target)
+	if err != nil {
+		return
+	}
+	defer backend.Close()
+	go io.Copy(backend, client)
+	io.Copy(client, backend)
+}
+
+func dial(network, addr string) (net.Conn, error) {
+	return net.Dial(network, addr)
+}
+
+func greeting(conn net.Conn) string {
+	line, err := bufio.NewReader(conn).ReadString('\n')
+	Expect(err).NotTo(HaveOccurred())
+	return line
+}
+
+var _ = Describe("Upstream proxies", func() {
+	var backend *testServer
+
+	BeforeEach(func() {
+		backend = newTestServer(func(conn net.Conn) {
+			conn.Write([]byte("hello from the backend\n"))
+		})
+	})
+
+	AfterEach(func() {
+		backend.close()
+	})
+
+	Describe("New", func() {
+		It("rejects unsupported schemes", func() {
+			_, err := upstream.New("https://egress.example.com", dial)
+			Expect(err).To(HaveOccurred())
+		})
+
+		It("requires a host", func() {
+			_, err := upstream.New("socks5://", dial)
+			Expect(err).To(HaveOccurred())
+		})
+	})
+
+	Context("with an HTTP proxy", func() {
+		var (
+			proxyServer *testServer
+			status      int
+			lock        sync.Mutex
+			authHeader  string
+			target      string
+		)
+
+		BeforeEach(func() {
+			status = http.StatusOK
+			proxyServer = newTestServer(func(conn net.Conn) {
+				req, err := http.ReadRequest(bufio.NewReader(conn))
+				if err != nil {
+					return
+				}
+				lock.Lock()
+				authHeader = req.Header.Get("Proxy-Authorization")
+				target = req.Host
+				lock.Unlock()
+
+				if status != http.StatusOK || req.Method != "CONNECT" {
+					conn.Write([]byte("HTTP/1.1 " + strconv.Itoa(status) + " " + http.StatusText(status) + "\r\n\r\n"))
+					return
+				}
+				conn.Write([]byte("HTTP/1.1 200 Connection established\r\n\r\n"))
+				tunnel(conn, req.Host)
+			})
+		})
+
+		AfterEach(func() {
+			proxyServer.close()
+		})
+
+		It("tunnels to the address with CONNECT", func() {
+			p, err := upstream.New("http://"+proxyServer.addr(), dial)
+			Expect(err).NotTo(HaveOccurred())
+
+			conn, err := p.Dial("tcp", backend.addr())
+			Expect(err).NotTo(HaveOccurred())
+			defer conn.Close()
+
+			Expect(greeting(conn)).To(Equal("hello from the backend\n"))
+			lock.Lock()
+			defer lock.Unlock()
+			Expect(target).To(Equal(backend.addr()))
+			Expect(authHeader).To(BeEmpty())
+		})
+
+		It("authenticates with the credentials of the URL", func() {
+			p, err := upstream.New("http://user:secret@"+proxyServer.addr(), dial)
+			Expect(err).NotTo(HaveOccurred())
+
+			conn, err := p.Dial("tcp", backend.addr())
+			Expect(err).NotTo(HaveOccurred())
+			conn.Close()
+
+			lock.Lock()
+			defer lock.Unlock()
+			Expect(authHeader).To(Equal("Basic dXNlcjpzZWNyZXQ="))
+		})
+
+		It("fails to dial when the proxy refuses to connect", func() {
+			status = http.StatusProxyAuthRequired
+			p, err := upstream.New("http://"+proxyServer.addr(), dial)
+			Expect(err).NotTo(HaveOccurred())
+
+			_, err = p.Dial("tcp", backend.addr())
+			Expect(err).To(MatchError(ContainSubstring("407")))
+			opErr, ok := err.(*net.OpError)
+			Expect(ok).To(BeTrue())
+			Expect(opErr.Op).To(Equal("dial"))
+		})
+	})
+
+	Context("with a SOCKS5 proxy", func() {
+		var (
+			proxyServer *testServer
+			lock        sync.Mutex
+			credentials string
+		)
+
+		BeforeEach(func() {
+			credentials = ""
+			proxyServer = newTestServer(func(conn net.Conn) {
+				r := bufio.NewReader(conn)
+				header := make([]byte, 2)
+				if _, err := io.ReadFull(r, header); err != nil {
+					return
+				}
+				methods := make([]byte, header[1])
+				io.ReadFull(r, methods)
+				conn.Write([]byte{0x05, methods[0]})
+
+				if methods[0] == 0x02 {
+					auth := make([]byte, 2)
+					io.ReadFull(r, auth)
+					username := make([]byte, auth[1])
+					io.ReadFull(r, username)
+					length, _ := r.ReadByte()
+					password := make([]byte, length)
+					io.ReadFull(r, password)
+
+					lock.Lock()
+					credentials = string(username) + ":" + string(password)
+					lock.Unlock()
+					if string(password) != "secret" {
+						conn.Write([]byte{0x01, 0x01})
+						return
+					}
+					conn.Write([]byte{0x01, 0x00})
+				}
+
+				request := make([]byte, 4)
+				io.ReadFull(r, request)
+				var host string
+				switch request[3] {
+				case 0x01:
+					ip := make([]byte, 4)
+					io.ReadFull(r, ip)
+					host = net.IP(ip).String()
+				case 0x03:
+					length, _ := r.ReadByte()
+					name := make([]byte, length)
+					io.ReadFull(r, name)
+					host = string(name)
+				}
+				port := make([]byte, 2)
+				io.ReadFull(r, port)
+
+				conn.Write([]byte{0x05, 0x00, 0x00, 0x01, 127, 0, 0, 1, 0, 0})
+				tunnel(conn, net.JoinHostPort(host, strconv.Itoa(int(binary.BigEndian.Uint16(port)))))
+			})
+		})
+
+		AfterEach(func() {
+			proxyServer.close()
+		})
+
+		It("connects to IP addresses", func() {
+			p, err := upstream.New("socks5://"+proxyServer.addr(), dial)
+			Expect(err).NotTo(HaveOccurred())
+
+			conn, err := p.Dial("tcp", backend.addr())
+			Expect(err).NotTo(HaveOccurred())
+			defer conn.Close()
+
+			Expect(greeting(conn)).To(Equal("hello from the backend\n"))
+		})
+
+		It("has the proxy resolve host names", func() {
+			p, err := upstream.New("socks5://"+proxyServer.addr(), dial)
+			Expect(err).NotTo(HaveOccurred())
+
+			_, port, _ := net.SplitHostPort(backend.addr())
+			conn, err := p.Dial("tcp", "localhost:"+port)
+			Expect(err).NotTo(HaveOccurred())
+			defer conn.Close()
+
+			Expect(greeting(conn)).To(Equal("hello from the backend\n"))
+		})
+
+		It("authenticates with the credentials of the URL", func() {
+			p, err := upstream.New("socks5://user:secret@"+proxyServer.addr(), dial)
+			Expect(err).NotTo(HaveOccurred())
+
+			conn, err := p.Dial("tcp", backend.addr())
+			Expect(err).NotTo(HaveOccurred())
+			defer conn.Close()
+
+			Expect(greeting(conn)).To(Equal("hello from the backend\n"))
+			lock.Lock()
+			defer lock.Unlock()
+			Expect(credentials).To(Equal("user:secret"))
+		})
+
+		It("fails to dial when the credentials are rejected", func() {
+			p, err := upstream.New("socks5://user:guess@"+proxyServer.addr(), dial)
+			Expect(err).NotTo(HaveOccurred())
+
+			_, err = p.Dial("tcp", backend.addr())
+			Expect(err).To(MatchError(ContainSubstring("rejected the credentials")))
+		})
+	})
+
+	Describe("Proxies", func() {
+		var (
+			egress  *testServer
+			proxies *upstream.Proxies
+			cfg     config.UpstreamProxiesConfig
+		)
+
+		BeforeEach(func() {
+			egress = newTestServer(func(conn net.Conn) {
+				req, err := http.ReadRequest(bufio.NewReader(conn))
+				if err != nil {
+					return
+				}
+				conn.Write([]byte("HTTP/1.1 200 Connection established\r\n\r\n"))
+				tunnel(conn, req.Host)
+			})
+			cfg = config.UpstreamProxiesConfig{
+				Proxies: []config.UpstreamProxy{{Name: "egress", URL: "http://" + egress.addr()}},
+			}
+		})
+
+		AfterEach(func() {
+			egress.close()
+		})
+
+		JustBeforeEach(func() {
+			var err error
+			proxies, err = upstream.NewProxies(cfg, dial)
+			Expect(err).NotTo(HaveOccurred())
+		})
+
+		endpoint := func(upstreamProxy string) *route.Endpoint {
+			host, portStr, _ := net.SplitHostPort(backend.addr())
+			port, _ := strconv.Atoi(portStr)
+			e := route.NewEndpoint("app", host, uint16(port), "", "", nil, -1, "", models.ModificationTag{}, "")
+			e.UpstreamProxy = upstreamProxy
+			return e
+		}
+
+		dialFor := func(req *http.Request) error {
+			conn, err := proxies.DialContext(req.Context(), "tcp", backend.addr())
+			if err == nil {
+				conn.Close()
+			}
+			return err
+		}
+
+		It("dials endpoints naming no proxy directly", func() {
+			req := upstream.WithEndpoint(httptest.NewRequest("GET", "/", nil), endpoint(""))
+			Expect(dialFor(req)).To(Succeed())
+			Expect(egress.acceptedConns()).To(Equal(0))
+		})
+
+		It("dials endpoints through the proxy they name", func() {
+			req := upstream.WithEndpoint(httptest.NewRequest("GET", "/", nil), endpoint("egress"))
+			Expect(dialFor(req)).To(Succeed())
+			Expect(egress.acceptedConns()).To(Equal(1))
+		})
+
+		It("fails to dial endpoints naming an unknown proxy", func() {
+			req := upstream.WithEndpoint(httptest.NewRequest("GET", "/", nil), endpoint("other"))
+			Expect(dialFor(req)).To(MatchError(ContainSubstring("upstream proxy other is not configured")))
+		})
+
+		Context("with a default proxy", func() {
+			BeforeEach(func() {
+				cfg.Default = "egress"
+			})
+
+			It("dials endpoints naming no proxy through it", func() {
+				req := upstream.WithEndpoint(httptest.NewRequest("GET", "/", nil), endpoint(""))
+				Expect(dialFor(req)).To(Succeed())
+				Expect(egress.acceptedConns()).To(Equal(1))
+			})
+
+			It("dials requests marked direct without it", func() {
+				req := upstream.Direct(httptest.NewRequest("GET", "/", nil))
+				Expect(dialFor(req)).To(Succeed())
+				Expect(egress.acceptedConns()).To(Equal(0))
+			})
+		})
+
+		It("rejects an unknown default", func() {
+			cfg.Default = "other"
+			_, err := upstream.NewProxies(cfg, dial)
+			Expect(err).To(HaveOccurred())
+		})
+
+		It("dials without a request context directly", func() {
+			conn, err := proxies.DialContext(context.Background(), "tcp", backend.addr())
+			Expect(err).NotTo(HaveOccurred())
+			conn.Close()
+			Expect(egress.acceptedConns()).To(Equal(0))
+		})
+	})
+})
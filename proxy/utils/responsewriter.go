@@ -3,8 +3,11 @@ package utils
 import (
 	"bufio"
 	"errors"
+	"io"
 	"net"
 	"net/http"
+
+	"code.cloudfoundry.org/gorouter/smuggling"
 )
 
 type ProxyResponseWriter interface {
@@ -54,7 +57,11 @@ func (p *proxyResponseWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
 	if !ok {
 		return nil, nil, errors.New("response writer cannot hijack")
 	}
-	return hijacker.Hijack()
+	conn, rw, err := hijacker.Hijack()
+	if err == nil {
+		smuggling.Passthrough(conn)
+	}
+	return conn, rw, err
 }
 
 func (p *proxyResponseWriter) Write(b []byte) (int, error) {
@@ -70,11 +77,52 @@ func (p *proxyResponseWriter) Write(b []byte) (int, error) {
 	return size, err
 }
 
+// ReadFrom copies r to the response with the ReadFrom of the writer wrapped,
+// which lets the server send the body with splice or sendfile
+func (p *proxyResponseWriter) ReadFrom(r io.Reader) (int64, error) {
+	if p.done {
+		return 0, nil
+	}
+
+	if p.status == 0 {
+		p.WriteHeader(http.StatusOK)
+	}
+	rf, ok := p.w.(io.ReaderFrom)
+	if !ok {
+		return io.Copy(writerOnly{p}, r)
+	}
+	n, err := rf.ReadFrom(r)
+	p.size += int(n)
+	return n, err
+}
+
+// ReadFrom copies r to w, with w's ReadFrom when it has one. Writers wrapping
+// a ProxyResponseWriter use it so as not to hide the ReadFrom of the writer
+// they wrap.
+func ReadFrom(w ProxyResponseWriter, r io.Reader) (int64, error) {
+	if rf, ok := w.(io.ReaderFrom); ok {
+		return rf.ReadFrom(r)
+	}
+	return io.Copy(writerOnly{w}, r)
+}
+
+// writerOnly hides the ReadFrom of a writer from io.Copy
+type writerOnly struct {
+	io.Writer
+}
+
 func (p *proxyResponseWriter) WriteHeader(s int) {
 	if p.done {
 		return
 	}
 
+	// interim responses are followed by the final one, which is the status
+	// worth recording
+	if isInformational(s) {
+		p.w.WriteHeader(s)
+		return
+	}
+
 	// if Content-Type not in response, nil out to suppress Go's auto-detect
 	if _, ok := p.w.Header()["Content-Type"]; !ok {
 		p.w.Header()["Content-Type"] = nil
@@ -87,6 +135,10 @@ func (p *proxyResponseWriter) WriteHeader(s int) {
 	}
 }
 
+func isInformational(s int) bool {
+	return s >= 100 && s < 200 && s != http.StatusSwitchingProtocols
+}
+
 func (p *proxyResponseWriter) Done() {
 	p.done = true
 }
@@ -0,0 +1,61 @@
+package utils_test
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+
+	"code.cloudfoundry.org/gorouter/proxy/utils"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+// readerFromRecorder records whether the body was handed to ReadFrom
+type readerFromRecorder struct {
+	*httptest.ResponseRecorder
+	readFrom bool
+}
+
+func (w *readerFromRecorder) ReadFrom(r io.Reader) (int64, error) {
+	w.readFrom = true
+	return io.Copy(w.ResponseRecorder, r)
+}
+
+var _ = Describe("ProxyResponseWriter", func() {
+	Describe("ReadFrom", func() {
+		It("uses the ReadFrom of the writer wrapped", func() {
+			recorder := &readerFromRecorder{ResponseRecorder: httptest.NewRecorder()}
+			writer := utils.NewProxyResponseWriter(recorder)
+
+			n, err := utils.ReadFrom(writer, strings.NewReader("some body"))
+			Expect(err).NotTo(HaveOccurred())
+			Expect(n).To(BeEquivalentTo(9))
+
+			Expect(recorder.readFrom).To(BeTrue())
+			Expect(recorder.Body.String()).To(Equal("some body"))
+			Expect(writer.Status()).To(Equal(http.StatusOK))
+			Expect(writer.Size()).To(Equal(9))
+		})
+
+		It("falls back to writing when the writer wrapped has no ReadFrom", func() {
+			recorder := httptest.NewRecorder()
+			writer := utils.NewProxyResponseWriter(recorder)
+
+			_, err := writer.ReadFrom(strings.NewReader("some body"))
+			Expect(err).NotTo(HaveOccurred())
+			Expect(recorder.Body.String()).To(Equal("some body"))
+			Expect(writer.Size()).To(Equal(9))
+		})
+
+		It("discards the body once done", func() {
+			recorder := &readerFromRecorder{ResponseRecorder: httptest.NewRecorder()}
+			writer := utils.NewProxyResponseWriter(recorder)
+			writer.Done()
+
+			_, err := writer.ReadFrom(strings.NewReader("some body"))
+			Expect(err).NotTo(HaveOccurred())
+			Expect(recorder.readFrom).To(BeFalse())
+		})
+	})
+})
@@ -0,0 +1,29 @@
+package registry
+
+import (
+	"encoding/json"
+
+	"code.cloudfoundry.org/gorouter/route"
+)
+
+// EndpointsActivity returns the activity of the endpoints of every route
+func (r *RouteRegistry) EndpointsActivity() map[route.Uri][]route.EndpointActivity {
+	activity := make(map[route.Uri][]route.EndpointActivity)
+	r.EachEndpoint(func(uri route.Uri, e *route.Endpoint) {
+		activity[uri] = append(activity[uri], e.Activity())
+	})
+	return activity
+}
+
+// ActivityReport serves the activity of the endpoints of every route as JSON
+type ActivityReport struct {
+	registry *RouteRegistry
+}
+
+func NewActivityReport(r *RouteRegistry) *ActivityReport {
+	return &ActivityReport{registry: r}
+}
+
+func (a *ActivityReport) MarshalJSON() ([]byte, error) {
+	return json.Marshal(a.registry.EndpointsActivity())
+}
@@ -0,0 +1,70 @@
+package registry_test
+
+import (
+	"encoding/json"
+	"time"
+
+	"code.cloudfoundry.org/gorouter/config"
+	"code.cloudfoundry.org/gorouter/metrics/fakes"
+	. "code.cloudfoundry.org/gorouter/registry"
+	"code.cloudfoundry.org/gorouter/route"
+	"code.cloudfoundry.org/gorouter/test_util"
+	"code.cloudfoundry.org/routing-api/models"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/onsi/gomega/gbytes"
+)
+
+var _ = Describe("Endpoint activity", func() {
+	var (
+		r         *RouteRegistry
+		logger    *test_util.TestZapLogger
+		configObj *config.Config
+	)
+
+	endpoint := func(host string) *route.Endpoint {
+		return route.NewEndpoint("app", host, 8080, "", "", nil, -1, "", models.ModificationTag{}, "")
+	}
+
+	BeforeEach(func() {
+		configObj = config.DefaultConfig()
+		configObj.PruneStaleDropletsInterval = 50 * time.Millisecond
+		configObj.DropletStaleThreshold = 24 * time.Millisecond
+	})
+
+	JustBeforeEach(func() {
+		logger = test_util.NewTestZapLogger("test")
+		r = NewRouteRegistry(logger, configObj, new(fakes.FakeRouteRegistryReporter))
+	})
+
+	It("reports the endpoints of every route", func() {
+		e := endpoint("10.0.0.1")
+		r.Register("app.example.com", e)
+		r.Register("other.example.com", endpoint("10.0.0.2"))
+		succeeded := time.Date(2026, 10, 1, 12, 0, 0, 0, time.UTC)
+		e.Stats.Succeeded(succeeded)
+
+		activity := r.EndpointsActivity()
+		Expect(activity).To(HaveLen(2))
+		Expect(activity["app.example.com"]).To(HaveLen(1))
+		Expect(activity["app.example.com"][0].Address).To(Equal("10.0.0.1:8080"))
+		Expect(activity["app.example.com"][0].LastSeen).NotTo(BeNil())
+		Expect(activity["app.example.com"][0].LastSucceeded.Equal(succeeded)).To(BeTrue())
+		Expect(activity["other.example.com"][0].LastSucceeded).To(BeNil())
+
+		data, err := json.Marshal(NewActivityReport(r))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(string(data)).To(ContainSubstring(`"last_succeeded":"2026-10-01T12:00:00Z"`))
+	})
+
+	It("logs the activity of pruned endpoints", func() {
+		e := endpoint("10.0.0.1")
+		r.Register("app.example.com", e)
+		e.Stats.Succeeded(time.Date(2026, 10, 1, 12, 0, 0, 0, time.UTC))
+
+		r.StartPruningCycle()
+		defer r.StopPruningCycle()
+
+		Eventually(logger).Should(gbytes.Say(`pruned-route.*"endpoint_activity":\[\{"address":"10.0.0.1:8080","last_seen":"[^"]+","last_succeeded":"2026-10-01T12:00:00Z"`))
+	})
+})
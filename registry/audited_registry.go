@@ -0,0 +1,69 @@
+package registry
+
+import (
+	"time"
+
+	"code.cloudfoundry.org/gorouter/audit"
+	"code.cloudfoundry.org/gorouter/route"
+)
+
+type auditedRegistry struct {
+	Registry
+	source      string
+	auditLogger audit.Logger
+}
+
+// NewAuditedRegistry returns a Registry that records the registrations and
+// unregistrations made through it in the audit log, attributed to source,
+// and marks the endpoints it registers with source. Only the changes r
+// applied are recorded when r is an ApplyingRegistry.
+func NewAuditedRegistry(r Registry, source string, auditLogger audit.Logger) Registry {
+	return &auditedRegistry{
+		Registry:    r,
+		source:      source,
+		auditLogger: auditLogger,
+	}
+}
+
+func (a *auditedRegistry) Register(uri route.Uri, endpoint *route.Endpoint) {
+	a.attribute(endpoint)
+	if RegisterApplied(a.Registry, uri, endpoint) {
+		a.record(audit.ActionRegister, uri, endpoint)
+	}
+}
+
+func (a *auditedRegistry) RegisterBatch(registrations []Registration) {
+	for _, reg := range registrations {
+		a.attribute(reg.Endpoint)
+	}
+	applied := RegisterBatchApplied(a.Registry, registrations)
+	for i, reg := range registrations {
+		if applied[i] {
+			a.record(audit.ActionRegister, reg.URI, reg.Endpoint)
+		}
+	}
+}
+
+func (a *auditedRegistry) Unregister(uri route.Uri, endpoint *route.Endpoint) {
+	if UnregisterApplied(a.Registry, uri, endpoint) {
+		a.record(audit.ActionUnregister, uri, endpoint)
+	}
+}
+
+// attribute marks the endpoint as registered from the source, unless it
+// already carries the source it was originally registered from
+func (a *auditedRegistry) attribute(endpoint *route.Endpoint) {
+	if endpoint.Source == "" {
+		endpoint.Source = a.source
+	}
+}
+
+func (a *auditedRegistry) record(action string, uri route.Uri, endpoint *route.Endpoint) {
+	a.auditLogger.Log(audit.Record{
+		Time:     time.Now(),
+		Action:   action,
+		Source:   a.source,
+		URI:      uri,
+		Endpoint: endpoint,
+	})
+}
@@ -0,0 +1,119 @@
+package registry_test
+
+import (
+	"time"
+
+	"code.cloudfoundry.org/gorouter/audit"
+	auditFakes "code.cloudfoundry.org/gorouter/audit/fakes"
+	"code.cloudfoundry.org/gorouter/config"
+	metricFakes "code.cloudfoundry.org/gorouter/metrics/fakes"
+	. "code.cloudfoundry.org/gorouter/registry"
+	"code.cloudfoundry.org/gorouter/registry/fakes"
+	"code.cloudfoundry.org/gorouter/route"
+	"code.cloudfoundry.org/gorouter/test_util"
+	"code.cloudfoundry.org/routing-api/models"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("AuditedRegistry", func() {
+	var (
+		inner       *fakes.FakeRegistry
+		auditLogger *auditFakes.FakeLogger
+		r           Registry
+		endpoint    *route.Endpoint
+	)
+
+	BeforeEach(func() {
+		inner = new(fakes.FakeRegistry)
+		auditLogger = new(auditFakes.FakeLogger)
+		r = NewAuditedRegistry(inner, audit.SourceRoutingAPI, auditLogger)
+		endpoint = route.NewEndpoint("app-id", "192.168.1.1", 1234, "id1", "0", nil, -1, "",
+			models.ModificationTag{Guid: "abc", Index: 2}, "")
+	})
+
+	It("records registrations with their source", func() {
+		r.Register("foo.example.com", endpoint)
+
+		Expect(inner.RegisterCallCount()).To(Equal(1))
+		Expect(auditLogger.LogCallCount()).To(Equal(1))
+
+		record := auditLogger.LogArgsForCall(0)
+		Expect(record.Action).To(Equal(audit.ActionRegister))
+		Expect(record.Source).To(Equal(audit.SourceRoutingAPI))
+		Expect(record.URI).To(Equal(route.Uri("foo.example.com")))
+		Expect(record.Endpoint.ModificationTag).To(Equal(models.ModificationTag{Guid: "abc", Index: 2}))
+		Expect(record.Time).NotTo(BeZero())
+	})
+
+	It("marks the endpoints it registers with its source", func() {
+		r.Register("foo.example.com", endpoint)
+		Expect(endpoint.Source).To(Equal(audit.SourceRoutingAPI))
+	})
+
+	It("keeps the source an endpoint was originally registered from", func() {
+		endpoint.Source = audit.SourceNATS
+		r.RegisterBatch([]Registration{{URI: "foo.example.com", Endpoint: endpoint}})
+
+		Expect(inner.RegisterBatchCallCount()).To(Equal(1))
+		Expect(endpoint.Source).To(Equal(audit.SourceNATS))
+		Expect(auditLogger.LogCallCount()).To(Equal(1))
+	})
+
+	It("records unregistrations with their source", func() {
+		r.Unregister("foo.example.com", endpoint)
+
+		Expect(inner.UnregisterCallCount()).To(Equal(1))
+		Expect(auditLogger.LogCallCount()).To(Equal(1))
+		Expect(auditLogger.LogArgsForCall(0).Action).To(Equal(audit.ActionUnregister))
+	})
+
+	Context("with a registry telling which changes it applied", func() {
+		var table *RouteRegistry
+
+		BeforeEach(func() {
+			configObj := config.DefaultConfig()
+			configObj.DropletStaleThreshold = 2 * time.Minute
+			configObj.RouteTableLimits = config.RouteTableLimitsConfig{MaxURIs: 1}
+			table = NewRouteRegistry(test_util.NewTestZapLogger("test"), configObj, new(metricFakes.FakeRouteRegistryReporter))
+			r = NewAuditedRegistry(table, audit.SourceRoutingAPI, auditLogger)
+		})
+
+		It("does not record registrations the registry rejected", func() {
+			r.Register("foo.example.com", endpoint)
+			r.Register("bar.example.com", endpoint)
+
+			Expect(table.Lookup("bar.example.com")).To(BeNil())
+			Expect(auditLogger.LogCallCount()).To(Equal(1))
+			Expect(auditLogger.LogArgsForCall(0).URI).To(Equal(route.Uri("foo.example.com")))
+		})
+
+		It("records only the applied registrations of a batch", func() {
+			r.RegisterBatch([]Registration{
+				{URI: "foo.example.com", Endpoint: endpoint},
+				{URI: "bar.example.com", Endpoint: endpoint},
+			})
+
+			Expect(auditLogger.LogCallCount()).To(Equal(1))
+			Expect(auditLogger.LogArgsForCall(0).URI).To(Equal(route.Uri("foo.example.com")))
+		})
+
+		It("does not record unregistrations of endpoints that were not registered", func() {
+			r.Unregister("foo.example.com", endpoint)
+			Expect(auditLogger.LogCallCount()).To(Equal(0))
+
+			r.Register("foo.example.com", endpoint)
+			r.Unregister("foo.example.com", endpoint)
+			Expect(auditLogger.LogCallCount()).To(Equal(2))
+			Expect(auditLogger.LogArgsForCall(1).Action).To(Equal(audit.ActionUnregister))
+		})
+	})
+
+	It("does not record lookups", func() {
+		r.Lookup("foo.example.com")
+
+		Expect(inner.LookupCallCount()).To(Equal(1))
+		Expect(auditLogger.LogCallCount()).To(Equal(0))
+	})
+})
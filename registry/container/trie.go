@@ -2,6 +2,7 @@ package container
 
 import (
 	"strings"
+	"unsafe"
 
 	"code.cloudfoundry.org/gorouter/route"
 )
@@ -16,6 +17,17 @@ type Trie struct {
 
 // Find returns a *route.Pool that matches exactly the URI parameter, nil if no match was found.
 func (r *Trie) Find(uri route.Uri) *route.Pool {
+	node := r.FindNode(uri)
+	if node == nil {
+		return nil
+	}
+
+	return node.Pool
+}
+
+// FindNode returns the node of exactly the URI parameter, nil if there is
+// none. The node may have no pool.
+func (r *Trie) FindNode(uri route.Uri) *Trie {
 	key := strings.TrimPrefix(uri.String(), "/")
 	node := r
 
@@ -37,11 +49,7 @@ func (r *Trie) Find(uri route.Uri) *route.Pool {
 		key = pathParts[1]
 	}
 
-	if nil != node.Pool {
-		return node.Pool
-	}
-
-	return nil
+	return node
 }
 
 // MatchUri returns the longest route that matches the URI parameter, nil if nothing matches.
@@ -195,6 +203,26 @@ func (r *Trie) EachNodeWithPool(f func(*Trie)) {
 	}
 }
 
+// EachNode calls f for this node and every node below it
+func (r *Trie) EachNode(f func(*Trie)) {
+	f(r)
+
+	for _, child := range r.ChildNodes {
+		child.EachNode(f)
+	}
+}
+
+// ApproximateSize estimates the bytes held by this node alone, excluding its
+// children and its pool
+func (r *Trie) ApproximateSize() int64 {
+	size := int64(unsafe.Sizeof(*r)) + int64(len(r.Segment))
+	for segment := range r.ChildNodes {
+		size += int64(unsafe.Sizeof(segment)+unsafe.Sizeof(r)) + route.MapEntryOverhead + int64(len(segment))
+	}
+
+	return size
+}
+
 func (r *Trie) EndpointCount() int {
 	m := make(map[string]struct{})
 
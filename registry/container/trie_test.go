@@ -51,6 +51,29 @@ var _ = Describe("Trie", func() {
 		})
 	})
 
+	Describe(".FindNode", func() {
+		It("finds the node of an existing key", func() {
+			p := route.NewPool(42, "")
+			r.Insert("/foo/bar", p)
+			node := r.FindNode("foo/bar")
+			Expect(node).NotTo(BeNil())
+			Expect(node.Pool).To(Equal(p))
+			Expect(node.ToPath()).To(Equal("foo/bar"))
+		})
+
+		It("finds intermediate nodes without a pool", func() {
+			r.Insert("/foo/bar", route.NewPool(42, ""))
+			node := r.FindNode("/foo")
+			Expect(node).NotTo(BeNil())
+			Expect(node.Pool).To(BeNil())
+		})
+
+		It("returns nil when there is no node", func() {
+			r.Insert("/foo/bar", route.NewPool(42, ""))
+			Expect(r.FindNode("/foo/bar/baz")).To(BeNil())
+		})
+	})
+
 	Describe(".MatchUri", func() {
 		It("works for the root node", func() {
 			p := route.NewPool(42, "")
@@ -414,4 +437,28 @@ var _ = Describe("Trie", func() {
 		Expect(pools).To(ContainElement(p1))
 		Expect(pools).To(ContainElement(p2))
 	})
+
+	It("applies a function to every node", func() {
+		r.Insert("/foo", route.NewPool(42, ""))
+		r.Insert("/foo/bar/baz", route.NewPool(42, ""))
+
+		paths := []string{}
+		r.EachNode(func(node *container.Trie) {
+			if node.Parent != nil {
+				paths = append(paths, node.ToPath())
+			}
+		})
+
+		Expect(paths).To(ConsistOf("foo", "foo/bar", "foo/bar/baz"))
+	})
+
+	Describe(".ApproximateSize", func() {
+		It("grows with the node's children", func() {
+			empty := r.ApproximateSize()
+			Expect(empty).To(BeNumerically(">", 0))
+
+			r.Insert("/foo", route.NewPool(42, ""))
+			Expect(r.ApproximateSize()).To(BeNumerically(">", empty))
+		})
+	})
 })
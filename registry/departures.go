@@ -0,0 +1,96 @@
+package registry
+
+import (
+	"sync"
+
+	"code.cloudfoundry.org/gorouter/route"
+)
+
+// EndpointDepartedHandler is called with the address of an endpoint that is
+// no longer registered in any pool
+type EndpointDepartedHandler func(addr string)
+
+// departures counts the pools each backend address is registered in. It
+// observes every pool of the registry, so the counts follow additions,
+// replacements, unregistrations and pruning alike.
+type departures struct {
+	lock     sync.Mutex
+	refs     map[string]int
+	pending  map[string]struct{}
+	handlers []EndpointDepartedHandler
+}
+
+func newDepartures() *departures {
+	return &departures{
+		refs:    make(map[string]int),
+		pending: make(map[string]struct{}),
+	}
+}
+
+func (d *departures) OnEndpointAdded(endpoint *route.Endpoint) {
+	d.lock.Lock()
+	d.refs[endpoint.CanonicalAddr()]++
+	d.lock.Unlock()
+}
+
+func (d *departures) OnEndpointRemoved(endpoint *route.Endpoint) {
+	addr := endpoint.CanonicalAddr()
+
+	d.lock.Lock()
+	d.refs[addr]--
+	if d.refs[addr] <= 0 {
+		delete(d.refs, addr)
+		d.pending[addr] = struct{}{}
+	}
+	d.lock.Unlock()
+}
+
+// referenced reports whether addr is registered in any pool
+func (d *departures) referenced(addr string) bool {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+
+	return d.refs[addr] > 0
+}
+
+// addresses returns the number of addresses registered in any pool
+func (d *departures) addresses() int {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+
+	return len(d.refs)
+}
+
+func (d *departures) subscribe(h EndpointDepartedHandler) {
+	d.lock.Lock()
+	d.handlers = append(d.handlers, h)
+	d.lock.Unlock()
+}
+
+// flush calls the handlers for addresses that left their last pool. A
+// replaced endpoint is removed and re-added at the same address within one
+// registry operation, so addresses are only reported once the operation is
+// complete and they are still unreferenced.
+func (d *departures) flush() {
+	d.lock.Lock()
+	if len(d.pending) == 0 {
+		d.lock.Unlock()
+		return
+	}
+
+	var departed []string
+	for addr := range d.pending {
+		if d.refs[addr] == 0 {
+			departed = append(departed, addr)
+		}
+	}
+	d.pending = make(map[string]struct{})
+	handlers := d.handlers
+	d.lock.Unlock()
+
+	for _, addr := range departed {
+		for _, h := range handlers {
+			h(addr)
+		}
+	}
+}
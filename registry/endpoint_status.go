@@ -0,0 +1,205 @@
+package registry
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"sort"
+
+	"github.com/uber-go/zap"
+
+	"code.cloudfoundry.org/gorouter/registry/container"
+	"code.cloudfoundry.org/gorouter/route"
+)
+
+// Endpoint statuses
+const (
+	StatusActive   = "active"
+	StatusDraining = "draining"
+)
+
+// EndpointStatus selects the endpoints of an application, or of one version
+// of it when Tag is set, by the value of that tag
+type EndpointStatus struct {
+	AppID  string `json:"app_id"`
+	Tag    string `json:"tag,omitempty"`
+	Value  string `json:"value,omitempty"`
+	Status string `json:"status"`
+}
+
+func (s EndpointStatus) validate() error {
+	if s.AppID == "" {
+		return errors.New("app_id is required")
+	}
+	if s.Tag == "" && s.Value != "" {
+		return errors.New("value requires a tag")
+	}
+	if s.Status != StatusActive && s.Status != StatusDraining {
+		return errors.New(`status must be "active" or "draining"`)
+	}
+	return nil
+}
+
+type drainRule struct {
+	appID string
+	tag   string
+	value string
+}
+
+func (d drainRule) matches(e *route.Endpoint) bool {
+	return e.ApplicationId == d.appID && (d.tag == "" || e.Tags[d.tag] == d.value)
+}
+
+// SetEndpointStatus applies statuses in one step, so that traffic can be moved
+// from one version of an application to another without a window where both
+// or neither are active. An endpoint is draining while any draining status
+// selects it; the statuses stick to endpoints registered later until they are
+// set active again. The number of endpoints each status selects is returned.
+func (r *RouteRegistry) SetEndpointStatus(statuses []EndpointStatus) []int {
+	r.Lock()
+	defer r.Unlock()
+
+	rules := make([]drainRule, len(statuses))
+	for i, s := range statuses {
+		rules[i] = drainRule{appID: s.AppID, tag: s.Tag, value: s.Value}
+		if s.Status == StatusDraining {
+			r.drainRules[rules[i]] = struct{}{}
+		} else {
+			delete(r.drainRules, rules[i])
+		}
+	}
+
+	seen := make([]map[string]struct{}, len(rules))
+	for i := range seen {
+		seen[i] = map[string]struct{}{}
+	}
+	changed := false
+	r.byURI.EachNodeWithPool(func(t *container.Trie) {
+		t.Pool.Each(func(e *route.Endpoint) {
+			selected := false
+			for i, rule := range rules {
+				if rule.matches(e) {
+					seen[i][e.CanonicalAddr()] = struct{}{}
+					selected = true
+				}
+			}
+			if selected {
+				draining := r.drainingLocked(e)
+				changed = changed || draining != e.IsDraining()
+				e.SetDraining(draining)
+			}
+		})
+	})
+	if changed {
+		r.sequence++
+	}
+
+	counts := make([]int, len(rules))
+	for i, s := range statuses {
+		counts[i] = len(seen[i])
+		r.logger.Info("endpoint-status-changed",
+			zap.String("app_id", s.AppID),
+			zap.String("tag", s.Tag),
+			zap.String("value", s.Value),
+			zap.String("status", s.Status),
+			zap.Int("endpoints", counts[i]),
+		)
+	}
+	return counts
+}
+
+// DrainingStatuses returns the draining statuses in effect
+func (r *RouteRegistry) DrainingStatuses() []EndpointStatus {
+	r.RLock()
+	statuses := make([]EndpointStatus, 0, len(r.drainRules))
+	for rule := range r.drainRules {
+		statuses = append(statuses, EndpointStatus{AppID: rule.appID, Tag: rule.tag, Value: rule.value, Status: StatusDraining})
+	}
+	r.RUnlock()
+
+	sort.Slice(statuses, func(i, j int) bool {
+		a, b := statuses[i], statuses[j]
+		if a.AppID != b.AppID {
+			return a.AppID < b.AppID
+		}
+		if a.Tag != b.Tag {
+			return a.Tag < b.Tag
+		}
+		return a.Value < b.Value
+	})
+	return statuses
+}
+
+// drainingLocked must be called with the registry lock held
+func (r *RouteRegistry) drainingLocked(e *route.Endpoint) bool {
+	for rule := range r.drainRules {
+		if rule.matches(e) {
+			return true
+		}
+	}
+	return false
+}
+
+// EndpointStatusRequest is the body of a POST to the endpoint status API
+type EndpointStatusRequest struct {
+	Operations []EndpointStatus `json:"operations"`
+}
+
+// EndpointStatusResult reports the endpoints selected by an operation
+type EndpointStatusResult struct {
+	EndpointStatus
+	Endpoints int `json:"endpoints"`
+}
+
+type endpointStatusHandler struct {
+	registry *RouteRegistry
+}
+
+// NewEndpointStatusHandler returns the endpoint status API of registry: GET
+// lists the draining statuses and POST applies a batch of operations
+// atomically
+func NewEndpointStatusHandler(registry *RouteRegistry) http.Handler {
+	return &endpointStatusHandler{registry: registry}
+}
+
+func (h *endpointStatusHandler) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	w.Header().Set("Connection", "close")
+
+	switch req.Method {
+	case "GET":
+		writeJSON(w, http.StatusOK, map[string]interface{}{"draining": h.registry.DrainingStatuses()})
+	case "POST":
+		var body EndpointStatusRequest
+		err := json.NewDecoder(req.Body).Decode(&body)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if len(body.Operations) == 0 {
+			http.Error(w, "operations are required", http.StatusBadRequest)
+			return
+		}
+		for _, op := range body.Operations {
+			if err := op.validate(); err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+		}
+
+		counts := h.registry.SetEndpointStatus(body.Operations)
+		results := make([]EndpointStatusResult, len(counts))
+		for i, count := range counts {
+			results[i] = EndpointStatusResult{EndpointStatus: body.Operations[i], Endpoints: count}
+		}
+		writeJSON(w, http.StatusOK, map[string]interface{}{"operations": results})
+	default:
+		w.Header().Set("Allow", "GET, POST")
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	}
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(v)
+}
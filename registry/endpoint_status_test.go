@@ -0,0 +1,182 @@
+package registry_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"time"
+
+	"code.cloudfoundry.org/gorouter/config"
+	"code.cloudfoundry.org/gorouter/metrics/fakes"
+	. "code.cloudfoundry.org/gorouter/registry"
+	"code.cloudfoundry.org/gorouter/route"
+	"code.cloudfoundry.org/gorouter/test_util"
+	"code.cloudfoundry.org/routing-api/models"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Endpoint status", func() {
+	var (
+		r          *RouteRegistry
+		v1a, v1b   *route.Endpoint
+		v2, other  *route.Endpoint
+		newVersion func(host, version string) *route.Endpoint
+	)
+
+	BeforeEach(func() {
+		configObj := config.DefaultConfig()
+		configObj.DropletStaleThreshold = 2 * time.Minute
+		r = NewRouteRegistry(test_util.NewTestZapLogger("test"), configObj, new(fakes.FakeRouteRegistryReporter))
+
+		newVersion = func(host, version string) *route.Endpoint {
+			return route.NewEndpoint("app-1", host, 8080, host, "0",
+				map[string]string{"version": version}, -1, "", models.ModificationTag{}, "")
+		}
+		v1a = newVersion("10.0.0.1", "v1")
+		v1b = newVersion("10.0.0.2", "v1")
+		v2 = newVersion("10.0.0.3", "v2")
+		other = route.NewEndpoint("app-2", "10.0.0.4", 8080, "", "",
+			map[string]string{"version": "v1"}, -1, "", models.ModificationTag{}, "")
+
+		for _, e := range []*route.Endpoint{v1a, v1b, v2} {
+			r.Register("app.example.com", e)
+		}
+		r.Register("other.example.com", other)
+	})
+
+	Describe("SetEndpointStatus", func() {
+		It("drains the endpoints of an app version", func() {
+			counts := r.SetEndpointStatus([]EndpointStatus{
+				{AppID: "app-1", Tag: "version", Value: "v1", Status: StatusDraining},
+			})
+			Expect(counts).To(Equal([]int{2}))
+
+			Expect(v1a.IsDraining()).To(BeTrue())
+			Expect(v1b.IsDraining()).To(BeTrue())
+			Expect(v2.IsDraining()).To(BeFalse())
+			Expect(other.IsDraining()).To(BeFalse())
+		})
+
+		It("flips traffic between versions in one call", func() {
+			r.SetEndpointStatus([]EndpointStatus{
+				{AppID: "app-1", Tag: "version", Value: "v2", Status: StatusDraining},
+			})
+			Expect(v2.IsDraining()).To(BeTrue())
+
+			counts := r.SetEndpointStatus([]EndpointStatus{
+				{AppID: "app-1", Tag: "version", Value: "v1", Status: StatusDraining},
+				{AppID: "app-1", Tag: "version", Value: "v2", Status: StatusActive},
+			})
+			Expect(counts).To(Equal([]int{2, 1}))
+			Expect(v1a.IsDraining()).To(BeTrue())
+			Expect(v2.IsDraining()).To(BeFalse())
+
+			iter := r.Lookup("app.example.com").Endpoints("", "")
+			for i := 0; i < 5; i++ {
+				Expect(iter.Next()).To(Equal(v2))
+			}
+		})
+
+		It("drains every endpoint of an app without a tag", func() {
+			counts := r.SetEndpointStatus([]EndpointStatus{{AppID: "app-1", Status: StatusDraining}})
+			Expect(counts).To(Equal([]int{3}))
+			Expect(v2.IsDraining()).To(BeTrue())
+		})
+
+		It("keeps an endpoint draining while another status selects it", func() {
+			r.SetEndpointStatus([]EndpointStatus{
+				{AppID: "app-1", Status: StatusDraining},
+				{AppID: "app-1", Tag: "version", Value: "v1", Status: StatusDraining},
+			})
+			r.SetEndpointStatus([]EndpointStatus{{AppID: "app-1", Status: StatusActive}})
+
+			Expect(v1a.IsDraining()).To(BeTrue())
+			Expect(v2.IsDraining()).To(BeFalse())
+		})
+
+		It("applies the status to endpoints registered later", func() {
+			r.SetEndpointStatus([]EndpointStatus{
+				{AppID: "app-1", Tag: "version", Value: "v1", Status: StatusDraining},
+			})
+
+			reregistered := newVersion("10.0.0.1", "v1")
+			r.Register("app.example.com", reregistered)
+			Expect(reregistered.IsDraining()).To(BeTrue())
+
+			r.SetEndpointStatus([]EndpointStatus{
+				{AppID: "app-1", Tag: "version", Value: "v1", Status: StatusActive},
+			})
+			reregistered = newVersion("10.0.0.1", "v1")
+			r.Register("app.example.com", reregistered)
+			Expect(reregistered.IsDraining()).To(BeFalse())
+		})
+
+		It("lists the draining statuses", func() {
+			r.SetEndpointStatus([]EndpointStatus{
+				{AppID: "app-2", Status: StatusDraining},
+				{AppID: "app-1", Tag: "version", Value: "v1", Status: StatusDraining},
+			})
+
+			Expect(r.DrainingStatuses()).To(Equal([]EndpointStatus{
+				{AppID: "app-1", Tag: "version", Value: "v1", Status: StatusDraining},
+				{AppID: "app-2", Status: StatusDraining},
+			}))
+		})
+	})
+
+	Describe("NewEndpointStatusHandler", func() {
+		var handler http.Handler
+
+		BeforeEach(func() {
+			handler = NewEndpointStatusHandler(r)
+		})
+
+		serve := func(method, body string) *httptest.ResponseRecorder {
+			recorder := httptest.NewRecorder()
+			handler.ServeHTTP(recorder, httptest.NewRequest(method, "/endpoint_status", strings.NewReader(body)))
+			return recorder
+		}
+
+		It("applies the operations and reports the endpoints selected", func() {
+			recorder := serve("POST", `{"operations":[
+				{"app_id":"app-1","tag":"version","value":"v1","status":"draining"},
+				{"app_id":"app-1","tag":"version","value":"v2","status":"active"}
+			]}`)
+			Expect(recorder.Code).To(Equal(http.StatusOK))
+
+			var resp struct {
+				Operations []EndpointStatusResult `json:"operations"`
+			}
+			Expect(json.Unmarshal(recorder.Body.Bytes(), &resp)).To(Succeed())
+			Expect(resp.Operations).To(HaveLen(2))
+			Expect(resp.Operations[0].Endpoints).To(Equal(2))
+			Expect(resp.Operations[1].Endpoints).To(Equal(1))
+			Expect(v1a.IsDraining()).To(BeTrue())
+
+			recorder = serve("GET", "")
+			Expect(recorder.Code).To(Equal(http.StatusOK))
+			Expect(recorder.Body.String()).To(MatchJSON(`{"draining":[{"app_id":"app-1","tag":"version","value":"v1","status":"draining"}]}`))
+		})
+
+		It("rejects invalid operations without applying any", func() {
+			recorder := serve("POST", `{"operations":[
+				{"app_id":"app-1","tag":"version","value":"v1","status":"draining"},
+				{"app_id":"app-1","status":"paused"}
+			]}`)
+			Expect(recorder.Code).To(Equal(http.StatusBadRequest))
+			Expect(v1a.IsDraining()).To(BeFalse())
+
+			Expect(serve("POST", `{"operations":[{"status":"draining"}]}`).Code).To(Equal(http.StatusBadRequest))
+			Expect(serve("POST", `{"operations":[]}`).Code).To(Equal(http.StatusBadRequest))
+			Expect(serve("POST", `not json`).Code).To(Equal(http.StatusBadRequest))
+		})
+
+		It("rejects other methods", func() {
+			recorder := serve("DELETE", "")
+			Expect(recorder.Code).To(Equal(http.StatusMethodNotAllowed))
+			Expect(recorder.Header().Get("Allow")).To(Equal("GET, POST"))
+		})
+	})
+})
@@ -15,6 +15,11 @@ type FakeRegistry struct {
 		uri      route.Uri
 		endpoint *route.Endpoint
 	}
+	RegisterBatchStub        func(registrations []registry.Registration)
+	registerBatchMutex       sync.RWMutex
+	registerBatchArgsForCall []struct {
+		registrations []registry.Registration
+	}
 	UnregisterStub        func(uri route.Uri, endpoint *route.Endpoint)
 	unregisterMutex       sync.RWMutex
 	unregisterArgsForCall []struct {
@@ -93,6 +98,35 @@ func (fake *FakeRegistry) RegisterArgsForCall(i int) (route.Uri, *route.Endpoint
 	return fake.registerArgsForCall[i].uri, fake.registerArgsForCall[i].endpoint
 }
 
+func (fake *FakeRegistry) RegisterBatch(registrations []registry.Registration) {
+	var registrationsCopy []registry.Registration
+	if registrations != nil {
+		registrationsCopy = make([]registry.Registration, len(registrations))
+		copy(registrationsCopy, registrations)
+	}
+	fake.registerBatchMutex.Lock()
+	fake.registerBatchArgsForCall = append(fake.registerBatchArgsForCall, struct {
+		registrations []registry.Registration
+	}{registrationsCopy})
+	fake.recordInvocation("RegisterBatch", []interface{}{registrationsCopy})
+	fake.registerBatchMutex.Unlock()
+	if fake.RegisterBatchStub != nil {
+		fake.RegisterBatchStub(registrations)
+	}
+}
+
+func (fake *FakeRegistry) RegisterBatchCallCount() int {
+	fake.registerBatchMutex.RLock()
+	defer fake.registerBatchMutex.RUnlock()
+	return len(fake.registerBatchArgsForCall)
+}
+
+func (fake *FakeRegistry) RegisterBatchArgsForCall(i int) []registry.Registration {
+	fake.registerBatchMutex.RLock()
+	defer fake.registerBatchMutex.RUnlock()
+	return fake.registerBatchArgsForCall[i].registrations
+}
+
 func (fake *FakeRegistry) Unregister(uri route.Uri, endpoint *route.Endpoint) {
 	fake.unregisterMutex.Lock()
 	fake.unregisterArgsForCall = append(fake.unregisterArgsForCall, struct {
@@ -294,6 +328,8 @@ func (fake *FakeRegistry) Invocations() map[string][][]interface{} {
 	defer fake.invocationsMutex.RUnlock()
 	fake.registerMutex.RLock()
 	defer fake.registerMutex.RUnlock()
+	fake.registerBatchMutex.RLock()
+	defer fake.registerBatchMutex.RUnlock()
 	fake.unregisterMutex.RLock()
 	defer fake.unregisterMutex.RUnlock()
 	fake.lookupMutex.RLock()
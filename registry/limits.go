@@ -0,0 +1,130 @@
+package registry
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/uber-go/zap"
+
+	"code.cloudfoundry.org/gorouter/route"
+)
+
+// Limits of the routing table that were reached
+const (
+	LimitURIs      = "uris"
+	LimitEndpoints = "endpoints"
+)
+
+// RouteTableLimits caps the URIs and backend addresses of the routing table.
+// Zero is unlimited.
+type RouteTableLimits struct {
+	MaxURIs      int `json:"max_uris"`
+	MaxEndpoints int `json:"max_endpoints"`
+}
+
+func (l RouteTableLimits) validate() error {
+	if l.MaxURIs < 0 || l.MaxEndpoints < 0 {
+		return errors.New("limits must not be negative")
+	}
+	return nil
+}
+
+// limitReached returns the limit a registration of endpoint for a new URI,
+// when isNew, would exceed, or "". It must be called with the lock held.
+func (r *RouteRegistry) limitReached(endpoint *route.Endpoint, isNew bool) string {
+	if isNew && r.limits.MaxURIs > 0 && r.uris >= r.limits.MaxURIs {
+		return LimitURIs
+	}
+	if r.limits.MaxEndpoints > 0 && !r.departures.referenced(endpoint.CanonicalAddr()) &&
+		r.departures.addresses() >= r.limits.MaxEndpoints {
+		return LimitEndpoints
+	}
+	return ""
+}
+
+// rejectedByLimit reports a registration rejected by a limit, logging at most
+// once every limitLogInterval with the number of rejections since
+func (r *RouteRegistry) rejectedByLimit(uri route.Uri, endpoint *route.Endpoint, limit string) {
+	r.reporter.CaptureRouteTableLimitReached(limit)
+
+	r.limitLock.Lock()
+	r.limitRejections++
+	now := time.Now()
+	if now.Sub(r.limitLogged) < limitLogInterval {
+		r.limitLock.Unlock()
+		return
+	}
+	rejections := r.limitRejections
+	r.limitRejections = 0
+	r.limitLogged = now
+	r.limitLock.Unlock()
+
+	r.logger.Error("route-table-limit-reached", append(zapData(uri, endpoint),
+		zap.String("limit", limit),
+		zap.Int("rejected_registrations", rejections),
+	)...)
+}
+
+const limitLogInterval = 10 * time.Second
+
+// SetLimits replaces the limits of the routing table. Lowering them does not
+// remove routes, it only rejects new ones until the table shrinks below them.
+func (r *RouteRegistry) SetLimits(limits RouteTableLimits) {
+	r.Lock()
+	r.limits = limits
+	r.Unlock()
+
+	r.logger.Info("route-table-limits-changed",
+		zap.Int("max_uris", limits.MaxURIs),
+		zap.Int("max_endpoints", limits.MaxEndpoints),
+	)
+}
+
+// Limits returns the limits of the routing table
+func (r *RouteRegistry) Limits() RouteTableLimits {
+	r.RLock()
+	defer r.RUnlock()
+
+	return r.limits
+}
+
+type limitsHandler struct {
+	registry *RouteRegistry
+}
+
+// NewLimitsHandler returns the route table limits API of registry: GET
+// returns the limits and the current size, PUT replaces the limits
+func NewLimitsHandler(registry *RouteRegistry) http.Handler {
+	return &limitsHandler{registry: registry}
+}
+
+func (h *limitsHandler) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	w.Header().Set("Connection", "close")
+
+	switch req.Method {
+	case "GET":
+	case "PUT":
+		var limits RouteTableLimits
+		err := json.NewDecoder(req.Body).Decode(&limits)
+		if err == nil {
+			err = limits.validate()
+		}
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		h.registry.SetLimits(limits)
+	default:
+		w.Header().Set("Allow", "GET, PUT")
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"limits":    h.registry.Limits(),
+		"uris":      h.registry.NumUris(),
+		"endpoints": h.registry.NumEndpoints(),
+	})
+}
@@ -0,0 +1,116 @@
+package registry_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"time"
+
+	"code.cloudfoundry.org/gorouter/config"
+	"code.cloudfoundry.org/gorouter/metrics/fakes"
+	. "code.cloudfoundry.org/gorouter/registry"
+	"code.cloudfoundry.org/gorouter/route"
+	"code.cloudfoundry.org/gorouter/test_util"
+	"code.cloudfoundry.org/routing-api/models"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/onsi/gomega/gbytes"
+)
+
+var _ = Describe("Route table limits", func() {
+	var (
+		r         *RouteRegistry
+		reporter  *fakes.FakeRouteRegistryReporter
+		logger    *test_util.TestZapLogger
+		configObj *config.Config
+	)
+
+	endpoint := func(host string) *route.Endpoint {
+		return route.NewEndpoint("app", host, 8080, "", "", nil, -1, "", models.ModificationTag{}, "")
+	}
+
+	BeforeEach(func() {
+		configObj = config.DefaultConfig()
+		configObj.DropletStaleThreshold = 2 * time.Minute
+		configObj.RouteTableLimits = config.RouteTableLimitsConfig{MaxURIs: 2, MaxEndpoints: 2}
+	})
+
+	JustBeforeEach(func() {
+		logger = test_util.NewTestZapLogger("test")
+		reporter = new(fakes.FakeRouteRegistryReporter)
+		r = NewRouteRegistry(logger, configObj, reporter)
+	})
+
+	It("rejects registrations for new uris beyond the limit", func() {
+		r.Register("a.example.com", endpoint("10.0.0.1"))
+		r.Register("b.example.com", endpoint("10.0.0.1"))
+		r.Register("c.example.com", endpoint("10.0.0.1"))
+
+		Expect(r.NumUris()).To(Equal(2))
+		Expect(r.Lookup("c.example.com")).To(BeNil())
+		Expect(reporter.CaptureRouteTableLimitReachedCallCount()).To(Equal(1))
+		Expect(reporter.CaptureRouteTableLimitReachedArgsForCall(0)).To(Equal(LimitURIs))
+		Expect(logger).To(gbytes.Say("route-table-limit-reached"))
+	})
+
+	It("rejects registrations of new endpoints beyond the limit", func() {
+		r.Register("a.example.com", endpoint("10.0.0.1"))
+		r.Register("a.example.com", endpoint("10.0.0.2"))
+		r.Register("a.example.com", endpoint("10.0.0.3"))
+		r.Register("b.example.com", endpoint("10.0.0.2"))
+
+		Expect(r.NumEndpoints()).To(Equal(2))
+		Expect(r.NumUris()).To(Equal(2))
+		Expect(reporter.CaptureRouteTableLimitReachedCallCount()).To(Equal(1))
+		Expect(reporter.CaptureRouteTableLimitReachedArgsForCall(0)).To(Equal(LimitEndpoints))
+	})
+
+	It("accepts new registrations once routes are unregistered", func() {
+		r.Register("a.example.com", endpoint("10.0.0.1"))
+		r.Register("b.example.com", endpoint("10.0.0.2"))
+		r.Unregister("b.example.com", endpoint("10.0.0.2"))
+		r.Register("c.example.com", endpoint("10.0.0.3"))
+
+		Expect(r.Lookup("c.example.com")).NotTo(BeNil())
+		Expect(reporter.CaptureRouteTableLimitReachedCallCount()).To(Equal(0))
+	})
+
+	It("accepts new registrations once the limits are raised", func() {
+		r.Register("a.example.com", endpoint("10.0.0.1"))
+		r.Register("b.example.com", endpoint("10.0.0.2"))
+		r.SetLimits(RouteTableLimits{MaxURIs: 3, MaxEndpoints: 3})
+		r.Register("c.example.com", endpoint("10.0.0.3"))
+
+		Expect(r.Lookup("c.example.com")).NotTo(BeNil())
+		Expect(r.Limits()).To(Equal(RouteTableLimits{MaxURIs: 3, MaxEndpoints: 3}))
+	})
+
+	Describe("NewLimitsHandler", func() {
+		serve := func(method, body string) *httptest.ResponseRecorder {
+			recorder := httptest.NewRecorder()
+			NewLimitsHandler(r).ServeHTTP(recorder, httptest.NewRequest(method, "/route_table/limits", strings.NewReader(body)))
+			return recorder
+		}
+
+		It("reports the limits and the size of the table", func() {
+			r.Register("a.example.com", endpoint("10.0.0.1"))
+
+			recorder := serve("GET", "")
+			Expect(recorder.Code).To(Equal(http.StatusOK))
+			Expect(recorder.Body.String()).To(MatchJSON(`{"limits":{"max_uris":2,"max_endpoints":2},"uris":1,"endpoints":1}`))
+		})
+
+		It("replaces the limits", func() {
+			recorder := serve("PUT", `{"max_uris":10,"max_endpoints":0}`)
+			Expect(recorder.Code).To(Equal(http.StatusOK))
+			Expect(r.Limits()).To(Equal(RouteTableLimits{MaxURIs: 10}))
+		})
+
+		It("rejects invalid limits", func() {
+			Expect(serve("PUT", `{"max_uris":-1}`).Code).To(Equal(http.StatusBadRequest))
+			Expect(serve("PUT", `not json`).Code).To(Equal(http.StatusBadRequest))
+			Expect(serve("DELETE", "").Code).To(Equal(http.StatusMethodNotAllowed))
+			Expect(r.Limits()).To(Equal(RouteTableLimits{MaxURIs: 2, MaxEndpoints: 2}))
+		})
+	})
+})
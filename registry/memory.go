@@ -0,0 +1,108 @@
+package registry
+
+import (
+	"encoding/json"
+	"strings"
+
+	"code.cloudfoundry.org/gorouter/registry/container"
+	"code.cloudfoundry.org/gorouter/route"
+)
+
+// MemoryUsage is an estimate of the memory held by (part of) the routing
+// table. The estimate covers the data structures and strings owned by the
+// table; it does not account for allocator overhead.
+type MemoryUsage struct {
+	TrieNodes     int   `json:"trie_nodes"`
+	Pools         int   `json:"pools"`
+	Endpoints     int   `json:"endpoints"`
+	TrieBytes     int64 `json:"trie_bytes"`
+	PoolBytes     int64 `json:"pool_bytes"`
+	EndpointBytes int64 `json:"endpoint_bytes"`
+	TotalBytes    int64 `json:"total_bytes"`
+}
+
+// MemoryUsage estimates the memory held by the routing table, in total and
+// broken down by domain. An endpoint registered for several routes is counted
+// once in the total and once in every domain it serves.
+func (r *RouteRegistry) MemoryUsage() (MemoryUsage, map[string]MemoryUsage) {
+	r.RLock()
+	defer r.RUnlock()
+
+	var total MemoryUsage
+	byDomain := make(map[string]MemoryUsage)
+	seen := make(map[*route.Endpoint]struct{})
+
+	total.TrieNodes = 1
+	total.TrieBytes = r.byURI.ApproximateSize()
+
+	for host, node := range r.byURI.ChildNodes {
+		domain := domainOf(host)
+		usage := byDomain[domain]
+		inDomain := make(map[*route.Endpoint]struct{})
+
+		node.EachNode(func(t *container.Trie) {
+			nodeBytes := t.ApproximateSize()
+			usage.TrieNodes++
+			usage.TrieBytes += nodeBytes
+			total.TrieNodes++
+			total.TrieBytes += nodeBytes
+
+			if t.Pool == nil {
+				return
+			}
+
+			poolBytes := t.Pool.ApproximateSize()
+			usage.Pools++
+			usage.PoolBytes += poolBytes
+			total.Pools++
+			total.PoolBytes += poolBytes
+
+			t.Pool.Each(func(e *route.Endpoint) {
+				endpointBytes := e.ApproximateSize()
+				if _, ok := inDomain[e]; !ok {
+					inDomain[e] = struct{}{}
+					usage.Endpoints++
+					usage.EndpointBytes += endpointBytes
+				}
+				if _, ok := seen[e]; !ok {
+					seen[e] = struct{}{}
+					total.Endpoints++
+					total.EndpointBytes += endpointBytes
+				}
+			})
+		})
+
+		usage.TotalBytes = usage.TrieBytes + usage.PoolBytes + usage.EndpointBytes
+		byDomain[domain] = usage
+	}
+
+	total.TotalBytes = total.TrieBytes + total.PoolBytes + total.EndpointBytes
+	return total, byDomain
+}
+
+// domainOf strips the first label from a host, so that app.example.com and
+// *.example.com are both reported under example.com
+func domainOf(host string) string {
+	if idx := strings.Index(host, "."); idx >= 0 && idx < len(host)-1 {
+		return host[idx+1:]
+	}
+	return host
+}
+
+// MemoryReport serves the routing table memory estimate as JSON
+type MemoryReport struct {
+	registry *RouteRegistry
+}
+
+func NewMemoryReport(r *RouteRegistry) *MemoryReport {
+	return &MemoryReport{registry: r}
+}
+
+func (m *MemoryReport) MarshalJSON() ([]byte, error) {
+	total, byDomain := m.registry.MemoryUsage()
+
+	return json.Marshal(struct {
+		Total   MemoryUsage            `json:"total"`
+		Domains map[string]MemoryUsage `json:"domains"`
+	}{total, byDomain})
+}
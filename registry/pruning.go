@@ -0,0 +1,50 @@
+package registry
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// PruningState describes the cycle pruning stale endpoints
+type PruningState struct {
+	Running bool `json:"running"`
+	// Suspended is set while pruning is suspended because NATS is
+	// unavailable
+	Suspended        bool      `json:"suspended"`
+	LastPrune        time.Time `json:"last_prune"`
+	MsSinceLastPrune int64     `json:"ms_since_last_prune"`
+}
+
+// PruningState returns the state of the pruning cycle. LastPrune is zero
+// until the cycle has been started.
+func (r *RouteRegistry) PruningState() PruningState {
+	r.pruneLock.Lock()
+	running := r.pruneCancel != nil
+	r.pruneLock.Unlock()
+
+	r.RLock()
+	state := PruningState{
+		Running:   running,
+		Suspended: r.pruningStatus == DISCONNECTED,
+		LastPrune: r.lastPrune,
+	}
+	r.RUnlock()
+
+	if !state.LastPrune.IsZero() {
+		state.MsSinceLastPrune = int64(time.Since(state.LastPrune) / time.Millisecond)
+	}
+	return state
+}
+
+// PruningReport serves the state of the pruning cycle as JSON
+type PruningReport struct {
+	registry *RouteRegistry
+}
+
+func NewPruningReport(r *RouteRegistry) *PruningReport {
+	return &PruningReport{registry: r}
+}
+
+func (p *PruningReport) MarshalJSON() ([]byte, error) {
+	return json.Marshal(p.registry.PruningState())
+}
@@ -0,0 +1,79 @@
+package registry_test
+
+import (
+	"time"
+
+	"code.cloudfoundry.org/gorouter/config"
+	"code.cloudfoundry.org/gorouter/metrics/fakes"
+	. "code.cloudfoundry.org/gorouter/registry"
+	"code.cloudfoundry.org/gorouter/route"
+	"code.cloudfoundry.org/gorouter/test_util"
+	"code.cloudfoundry.org/routing-api/models"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/onsi/gomega/gbytes"
+)
+
+var _ = Describe("Endpoint quarantine", func() {
+	var (
+		r         *RouteRegistry
+		logger    *test_util.TestZapLogger
+		configObj *config.Config
+	)
+
+	endpoint := func(host string) *route.Endpoint {
+		return route.NewEndpoint("app", host, 8080, "instance-id", "", nil, -1, "", models.ModificationTag{}, "")
+	}
+
+	BeforeEach(func() {
+		configObj = config.DefaultConfig()
+		configObj.PruneStaleDropletsInterval = 50 * time.Millisecond
+		configObj.DropletStaleThreshold = 24 * time.Millisecond
+		configObj.EndpointQuarantinePeriod = time.Hour
+	})
+
+	JustBeforeEach(func() {
+		logger = test_util.NewTestZapLogger("test")
+		r = NewRouteRegistry(logger, configObj, new(fakes.FakeRouteRegistryReporter))
+	})
+
+	AfterEach(func() {
+		r.StopPruningCycle()
+	})
+
+	It("keeps the route of quarantined endpoints", func() {
+		r.Register("app.example.com", endpoint("10.0.0.1"))
+		r.StartPruningCycle()
+
+		Eventually(logger).Should(gbytes.Say(`quarantined-route.*"endpoints":\["10.0.0.1:8080"\]`))
+		pool := r.Lookup("app.example.com")
+		Expect(pool).NotTo(BeNil())
+		Expect(pool.Endpoints("", "").Next()).To(BeNil())
+		Expect(pool.Endpoints("", "instance-id").Next()).NotTo(BeNil())
+		Expect(r.NumUris()).To(Equal(1))
+	})
+
+	It("revives quarantined endpoints registering again", func() {
+		r.Register("app.example.com", endpoint("10.0.0.1"))
+		r.StartPruningCycle()
+		Eventually(logger).Should(gbytes.Say("quarantined-route"))
+
+		r.Register("app.example.com", endpoint("10.0.0.1"))
+		Expect(logger).To(gbytes.Say("endpoint-revived"))
+	})
+
+	Context("when the quarantine period expires", func() {
+		BeforeEach(func() {
+			configObj.EndpointQuarantinePeriod = time.Millisecond
+		})
+
+		It("removes the endpoints", func() {
+			r.Register("app.example.com", endpoint("10.0.0.1"))
+			r.StartPruningCycle()
+
+			Eventually(logger).Should(gbytes.Say(`pruned-route.*"endpoints":\["10.0.0.1:8080"\]`))
+			Expect(r.Lookup("app.example.com")).To(BeNil())
+			Expect(r.NumUris()).To(Equal(0))
+		})
+	})
+})
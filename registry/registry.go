@@ -1,13 +1,16 @@
 package registry
 
 import (
-	"encoding/json"
+	"bytes"
+	"context"
+	"fmt"
 	"strings"
 	"sync"
 	"time"
 
 	"github.com/uber-go/zap"
 
+	"code.cloudfoundry.org/gorouter/audit"
 	"code.cloudfoundry.org/gorouter/config"
 	"code.cloudfoundry.org/gorouter/logger"
 	"code.cloudfoundry.org/gorouter/metrics"
@@ -18,6 +21,7 @@ import (
 //go:generate counterfeiter -o fakes/fake_registry.go . Registry
 type Registry interface {
 	Register(uri route.Uri, endpoint *route.Endpoint)
+	RegisterBatch(registrations []Registration)
 	Unregister(uri route.Uri, endpoint *route.Endpoint)
 	Lookup(uri route.Uri) *route.Pool
 	LookupWithInstance(uri route.Uri, appID, appIndex string) *route.Pool
@@ -28,6 +32,52 @@ type Registry interface {
 	MarshalJSON() ([]byte, error)
 }
 
+// ApplyingRegistry is implemented by the registries telling whether the
+// changes made through them were applied, rather than rejected or ignored
+type ApplyingRegistry interface {
+	RegisterApplied(uri route.Uri, endpoint *route.Endpoint) bool
+	RegisterBatchApplied(registrations []Registration) []bool
+	UnregisterApplied(uri route.Uri, endpoint *route.Endpoint) bool
+}
+
+// RegisterApplied registers endpoint with r, reporting whether r applied the
+// registration. Registries that cannot tell are taken to have applied it.
+func RegisterApplied(r Registry, uri route.Uri, endpoint *route.Endpoint) bool {
+	if a, ok := r.(ApplyingRegistry); ok {
+		return a.RegisterApplied(uri, endpoint)
+	}
+	r.Register(uri, endpoint)
+	return true
+}
+
+// RegisterBatchApplied registers the batch with r, reporting whether r applied
+// each registration
+func RegisterBatchApplied(r Registry, registrations []Registration) []bool {
+	if a, ok := r.(ApplyingRegistry); ok {
+		return a.RegisterBatchApplied(registrations)
+	}
+	r.RegisterBatch(registrations)
+	applied := make([]bool, len(registrations))
+	for i := range applied {
+		applied[i] = true
+	}
+	return applied
+}
+
+// UnregisterApplied unregisters endpoint from r, reporting whether r removed
+// it
+func UnregisterApplied(r Registry, uri route.Uri, endpoint *route.Endpoint) bool {
+	if a, ok := r.(ApplyingRegistry); ok {
+		return a.UnregisterApplied(uri, endpoint)
+	}
+	r.Unregister(uri, endpoint)
+	return true
+}
+
+// AddressChangeHandler is called when an endpoint re-registers under the same
+// private instance id at a new address
+type AddressChangeHandler func(oldEndpoint, newEndpoint *route.Endpoint)
+
 type PruneStatus int
 
 const (
@@ -49,14 +99,51 @@ type RouteRegistry struct {
 
 	pruneStaleDropletsInterval time.Duration
 	dropletStaleThreshold      time.Duration
+	pruneCycleWarningDuration  time.Duration
+	pruneChunkSize             int
+	// endpointQuarantinePeriod is how long stale endpoints are quarantined
+	// before being removed, not at all when zero
+	endpointQuarantinePeriod time.Duration
+	staleThresholdClasses    []config.StaleThresholdClass
+	// lastPrune is when stale endpoints were last pruned, or the pruning
+	// cycle started
+	lastPrune time.Time
+
+	reporter    metrics.RouteRegistryReporter
+	auditLogger audit.Logger
+
+	// pruneLock serializes starting and stopping the pruning cycle, which
+	// runs while pruneCancel is set
+	pruneLock   sync.Mutex
+	pruneCancel context.CancelFunc
+	pruneDone   chan struct{}
 
-	reporter metrics.RouteRegistryReporter
-
-	ticker           *time.Ticker
 	timeOfLastUpdate time.Time
 
 	routingTableShardingMode string
 	isolationSegments        []string
+	staleUpdatePolicy        string
+	sourcePrecedence         sourcePrecedence
+
+	addressChangeHandlers []AddressChangeHandler
+	departures            *departures
+
+	// drainRules select the endpoints that are draining, including those
+	// registered after the rule was set
+	drainRules map[drainRule]struct{}
+
+	// uris counts the pools of byURI, checked against limits
+	uris   int
+	limits RouteTableLimits
+
+	// sequence counts the changes of the routing table as MarshalJSON
+	// reports it, since the registry was created at epoch
+	sequence uint64
+	epoch    int64
+
+	limitLock       sync.Mutex
+	limitRejections int
+	limitLogged     time.Time
 }
 
 func NewRouteRegistry(logger logger.Logger, c *config.Config, reporter metrics.RouteRegistryReporter) *RouteRegistry {
@@ -66,43 +153,188 @@ func NewRouteRegistry(logger logger.Logger, c *config.Config, reporter metrics.R
 
 	r.pruneStaleDropletsInterval = c.PruneStaleDropletsInterval
 	r.dropletStaleThreshold = c.DropletStaleThreshold
+	r.pruneCycleWarningDuration = c.PruneCycleWarningDuration
+	r.pruneChunkSize = c.PruneChunkSize
+	r.endpointQuarantinePeriod = c.EndpointQuarantinePeriod
+	r.staleThresholdClasses = c.StaleThresholdClasses
 	r.suspendPruning = func() bool { return false }
 
 	r.reporter = reporter
+	r.auditLogger = &audit.NullLogger{}
+	r.departures = newDepartures()
+	r.drainRules = map[drainRule]struct{}{}
 
 	r.routingTableShardingMode = c.RoutingTableShardingMode
 	r.isolationSegments = c.IsolationSegments
+	r.staleUpdatePolicy = c.StaleUpdatePolicy
+	r.sourcePrecedence = newSourcePrecedence(c.RouteSources.Precedence)
+	r.limits = RouteTableLimits{MaxURIs: c.RouteTableLimits.MaxURIs, MaxEndpoints: c.RouteTableLimits.MaxEndpoints}
+	r.epoch = time.Now().UnixNano()
 
 	return r
 }
 
 func (r *RouteRegistry) Register(uri route.Uri, endpoint *route.Endpoint) {
+	r.RegisterApplied(uri, endpoint)
+}
+
+// RegisterApplied registers as Register does, reporting whether the endpoint
+// was put in the table
+func (r *RouteRegistry) RegisterApplied(uri route.Uri, endpoint *route.Endpoint) bool {
 	if !r.endpointInRouterShard(endpoint) {
-		return
+		return false
 	}
 
-	t := time.Now()
+	r.Lock()
+	result := r.registerLocked(uri, endpoint)
+	r.timeOfLastUpdate = time.Now()
+	addressChangeHandlers := r.addressChangeHandlers
+	r.Unlock()
+	r.departures.flush()
+
+	r.registered(uri, endpoint, result, addressChangeHandlers)
+	r.reporter.CaptureRegistryMessage(endpoint)
+	return result.added
+}
+
+// Registration is a route and the endpoint registered for it
+type Registration struct {
+	URI      route.Uri
+	Endpoint *route.Endpoint
+}
+
+// RegisterBatch registers every route of a batch under a single acquisition
+// of the lock, reporting the batch once, so that emitters of many routes do
+// not contend with lookups for each of them
+func (r *RouteRegistry) RegisterBatch(registrations []Registration) {
+	r.RegisterBatchApplied(registrations)
+}
+
+// RegisterBatchApplied registers as RegisterBatch does, reporting whether each
+// endpoint was put in the table
+func (r *RouteRegistry) RegisterBatchApplied(registrations []Registration) []bool {
+	applied := make([]bool, len(registrations))
+	inShard := make([]int, 0, len(registrations))
+	for i, reg := range registrations {
+		if r.endpointInRouterShard(reg.Endpoint) {
+			inShard = append(inShard, i)
+		}
+	}
+	if len(inShard) == 0 {
+		return applied
+	}
+
+	results := make([]registerResult, len(inShard))
 
 	r.Lock()
+	for i, n := range inShard {
+		results[i] = r.registerLocked(registrations[n].URI, registrations[n].Endpoint)
+	}
+	r.timeOfLastUpdate = time.Now()
+	addressChangeHandlers := r.addressChangeHandlers
+	r.Unlock()
+	r.departures.flush()
 
+	for i, n := range inShard {
+		r.registered(registrations[n].URI, registrations[n].Endpoint, results[i], addressChangeHandlers)
+		applied[n] = results[i].added
+	}
+	r.reporter.CaptureRegistryBatch(len(inShard))
+	return applied
+}
+
+type registerResult struct {
+	previous *route.Endpoint
+	added    bool
+	stale    bool
+	revived  bool
+	conflict string
+	limit    string
+}
+
+// registerLocked puts the endpoint in the pool of uri. It must be called
+// with the lock held.
+func (r *RouteRegistry) registerLocked(uri route.Uri, endpoint *route.Endpoint) registerResult {
 	routekey := uri.RouteKey()
 
 	pool := r.byURI.Find(routekey)
-	if pool == nil {
+	if limit := r.limitReached(endpoint, pool == nil); limit != "" {
+		return registerResult{limit: limit}
+	}
+	var generation uint64
+	if pool != nil {
+		generation = pool.Generation()
+	} else {
 		contextPath := parseContextPath(uri)
 		pool = route.NewPool(r.dropletStaleThreshold/4, contextPath)
+		pool.Subscribe(r.departures)
 		r.byURI.Insert(routekey, pool)
+		r.uris++
 		r.logger.Debug("uri-added", zap.Stringer("uri", routekey))
 	}
 
-	endpointAdded := pool.Put(endpoint)
+	defer func() {
+		if pool.Generation() != generation {
+			r.sequence++
+		}
+	}()
 
-	r.timeOfLastUpdate = t
-	r.Unlock()
+	conflict := r.sourcePrecedence.resolve(pool, endpoint)
+	if conflict == SourceConflictRejected {
+		return registerResult{conflict: conflict}
+	}
 
-	r.reporter.CaptureRegistryMessage(endpoint)
+	// a re-registration replaces the endpoint, so its status is restored
+	// from the rules
+	endpoint.SetDraining(r.drainingLocked(endpoint))
+
+	previous := pool.FindByPrivateInstanceId(endpoint.PrivateInstanceId)
+	revived := pool.IsQuarantined(endpoint)
+	added, stale := pool.PutEndpoint(endpoint, r.staleUpdatePolicy != config.STALE_UPDATE_DROP)
+	return registerResult{previous: previous, added: added, stale: stale, revived: revived, conflict: conflict}
+}
+
+// registered notifies and logs a registration once the lock is released
+func (r *RouteRegistry) registered(uri route.Uri, endpoint *route.Endpoint, result registerResult, addressChangeHandlers []AddressChangeHandler) {
+	if result.limit != "" {
+		r.rejectedByLimit(uri, endpoint, result.limit)
+	}
+
+	previous := result.previous
+	if result.added && previous != nil && previous.CanonicalAddr() != endpoint.CanonicalAddr() {
+		r.logger.Info("endpoint-address-changed",
+			zap.Stringer("uri", uri),
+			zap.String("private_instance_id", endpoint.PrivateInstanceId),
+			zap.String("old_backend", previous.CanonicalAddr()),
+			zap.String("new_backend", endpoint.CanonicalAddr()),
+		)
+		for _, h := range addressChangeHandlers {
+			h(previous, endpoint)
+		}
+	}
+
+	if result.conflict != "" {
+		r.reporter.CaptureSourceConflict(result.conflict)
+		if result.conflict == SourceConflictReplaced {
+			r.logger.Info("endpoints-replaced-by-source", append(zapData(uri, endpoint), zap.String("source", endpoint.Source))...)
+		}
+	}
 
-	if endpointAdded {
+	if result.stale {
+		r.reporter.CaptureStaleRegistryUpdate(r.staleUpdatePolicy)
+		switch r.staleUpdatePolicy {
+		case config.STALE_UPDATE_DROP:
+			r.logger.Info("stale-endpoint-update-dropped", zapData(uri, endpoint)...)
+		case config.STALE_UPDATE_LOG_ONLY:
+			r.logger.Info("stale-endpoint-update-applied", zapData(uri, endpoint)...)
+		}
+	}
+
+	if result.revived {
+		r.logger.Info("endpoint-revived", zapData(uri, endpoint)...)
+	}
+
+	if result.added {
 		r.logger.Debug("endpoint-registered", zapData(uri, endpoint)...)
 	} else {
 		r.logger.Debug("endpoint-not-registered", zapData(uri, endpoint)...)
@@ -110,18 +342,26 @@ func (r *RouteRegistry) Register(uri route.Uri, endpoint *route.Endpoint) {
 }
 
 func (r *RouteRegistry) Unregister(uri route.Uri, endpoint *route.Endpoint) {
+	r.UnregisterApplied(uri, endpoint)
+}
+
+// UnregisterApplied unregisters as Unregister does, reporting whether the
+// endpoint was removed from the table
+func (r *RouteRegistry) UnregisterApplied(uri route.Uri, endpoint *route.Endpoint) bool {
 	if !r.endpointInRouterShard(endpoint) {
-		return
+		return false
 	}
 
 	r.Lock()
 
 	uri = uri.RouteKey()
 
+	var endpointRemoved bool
 	pool := r.byURI.Find(uri)
 	if pool != nil {
-		endpointRemoved := pool.Remove(endpoint)
+		endpointRemoved = pool.Remove(endpoint)
 		if endpointRemoved {
+			r.sequence++
 			r.logger.Debug("endpoint-unregistered", zapData(uri, endpoint)...)
 		} else {
 			r.logger.Debug("endpoint-not-unregistered", zapData(uri, endpoint)...)
@@ -129,30 +369,51 @@ func (r *RouteRegistry) Unregister(uri route.Uri, endpoint *route.Endpoint) {
 
 		if pool.IsEmpty() {
 			r.byURI.Delete(uri)
+			r.uris--
 		}
 	}
 
 	r.Unlock()
+	r.departures.flush()
 	r.reporter.CaptureUnregistryMessage(endpoint)
+	return endpointRemoved
 }
 
 func (r *RouteRegistry) Lookup(uri route.Uri) *route.Pool {
+	pool, _ := r.lookup(uri, false)
+	return pool
+}
+
+// LookupSteps looks up uri as Lookup does, also returning the keys it tried:
+// uri itself, then the wildcards of its parent domains until one matched
+func (r *RouteRegistry) LookupSteps(uri route.Uri) (*route.Pool, []route.Uri) {
+	return r.lookup(uri, true)
+}
+
+func (r *RouteRegistry) lookup(uri route.Uri, trace bool) (*route.Pool, []route.Uri) {
 	started := time.Now()
 
 	r.RLock()
 
+	var steps []route.Uri
 	uri = uri.RouteKey()
+	if trace {
+		steps = append(steps, uri)
+	}
 	var err error
 	pool := r.byURI.MatchUri(uri)
 	for pool == nil && err == nil {
 		uri, err = uri.NextWildcard()
+		if trace && err == nil {
+			steps = append(steps, uri)
+		}
 		pool = r.byURI.MatchUri(uri)
 	}
 
 	r.RUnlock()
 	endLookup := time.Now()
 	r.reporter.CaptureLookupTime(endLookup.Sub(started))
-	return pool
+	return pool, steps
 }
 
 func (r *RouteRegistry) endpointInRouterShard(endpoint *route.Endpoint) bool {
@@ -181,44 +442,73 @@ func (r *RouteRegistry) LookupWithInstance(uri route.Uri, appID string, appIndex
 		return nil
 	}
 
-	var surgicalPool *route.Pool
-
-	p.Each(func(e *route.Endpoint) {
-		if (e.ApplicationId == appID) && (e.PrivateInstanceIndex == appIndex) {
-			surgicalPool = route.NewPool(0, "")
-			surgicalPool.Put(e)
-		}
+	return p.Filter(func(e *route.Endpoint) bool {
+		return e.ApplicationId == appID && e.PrivateInstanceIndex == appIndex
 	})
-	return surgicalPool
 }
 
+// StartPruningCycle starts pruning stale endpoints every
+// prune_stale_droplets_interval. Starting a cycle already running does
+// nothing, and a stopped cycle can be started again.
 func (r *RouteRegistry) StartPruningCycle() {
-	if r.pruneStaleDropletsInterval > 0 {
-		r.Lock()
-		r.ticker = time.NewTicker(r.pruneStaleDropletsInterval)
-		r.Unlock()
+	if r.pruneStaleDropletsInterval <= 0 {
+		return
+	}
 
-		go func() {
-			for {
-				select {
-				case <-r.ticker.C:
-					r.logger.Info("start-pruning-routes")
-					r.pruneStaleDroplets()
-					r.logger.Info("finished-pruning-routes")
-					msSinceLastUpdate := uint64(time.Since(r.TimeOfLastUpdate()) / time.Millisecond)
-					r.reporter.CaptureRouteStats(r.NumUris(), msSinceLastUpdate)
-				}
-			}
-		}()
+	r.pruneLock.Lock()
+	defer r.pruneLock.Unlock()
+	if r.pruneCancel != nil {
+		return
 	}
+
+	r.Lock()
+	r.lastPrune = time.Now()
+	r.Unlock()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	r.pruneCancel = cancel
+	r.pruneDone = done
+	go r.pruneLoop(ctx, done)
 }
 
+// StopPruningCycle stops the pruning cycle, returning once a prune in
+// progress finished
 func (r *RouteRegistry) StopPruningCycle() {
-	r.Lock()
-	if r.ticker != nil {
-		r.ticker.Stop()
+	r.pruneLock.Lock()
+	defer r.pruneLock.Unlock()
+	if r.pruneCancel == nil {
+		return
+	}
+
+	r.pruneCancel()
+	<-r.pruneDone
+	r.pruneCancel = nil
+	r.pruneDone = nil
+}
+
+func (r *RouteRegistry) pruneLoop(ctx context.Context, done chan<- struct{}) {
+	defer close(done)
+
+	ticker := time.NewTicker(r.pruneStaleDropletsInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			r.logger.Info("start-pruning-routes")
+			r.pruneStaleDroplets()
+			r.departures.flush()
+			r.logger.Info("finished-pruning-routes")
+			msSinceLastUpdate := uint64(time.Since(r.TimeOfLastUpdate()) / time.Millisecond)
+			r.reporter.CaptureRouteStats(r.NumUris(), msSinceLastUpdate)
+			usage, _ := r.MemoryUsage()
+			r.reporter.CaptureRegistryMemory(usage.TrieNodes, usage.TrieBytes, usage.PoolBytes, usage.EndpointBytes)
+			r.reporter.CaptureTimeSinceLastPrune(time.Since(r.LastPrune()))
+		}
 	}
-	r.Unlock()
 }
 
 func (registry *RouteRegistry) NumUris() int {
@@ -237,6 +527,16 @@ func (r *RouteRegistry) TimeOfLastUpdate() time.Time {
 	return t
 }
 
+// LastPrune returns when stale endpoints were last pruned, or the pruning
+// cycle started if they have not been yet
+func (r *RouteRegistry) LastPrune() time.Time {
+	r.RLock()
+	t := r.lastPrune
+	r.RUnlock()
+
+	return t
+}
+
 func (r *RouteRegistry) NumEndpoints() int {
 	r.RLock()
 	count := r.byURI.EndpointCount()
@@ -245,22 +545,80 @@ func (r *RouteRegistry) NumEndpoints() int {
 	return count
 }
 
-func (r *RouteRegistry) MarshalJSON() ([]byte, error) {
+// EachEndpoint calls f for every endpoint in the routing table. f must not
+// call back into the registry.
+func (r *RouteRegistry) EachEndpoint(f func(uri route.Uri, endpoint *route.Endpoint)) {
 	r.RLock()
 	defer r.RUnlock()
 
-	return json.Marshal(r.byURI.ToMap())
+	r.byURI.EachNodeWithPool(func(t *container.Trie) {
+		uri := route.Uri(t.ToPath())
+		t.Pool.Each(func(e *route.Endpoint) {
+			f(uri, e)
+		})
+	})
+}
+
+// Sequence counts the changes of the routing table as MarshalJSON reports it.
+// Refreshing an endpoint is not a change.
+func (r *RouteRegistry) Sequence() uint64 {
+	r.RLock()
+	sequence := r.sequence
+	r.RUnlock()
+
+	return sequence
+}
+
+// ETag identifies the routing table MarshalJSON reports, the sequence within
+// the life of the registry
+func (r *RouteRegistry) ETag() string {
+	return fmt.Sprintf(`"%x-%d"`, r.epoch, r.Sequence())
+}
+
+// MarshalJSON returns the endpoints of every route by route. The lock is only
+// held while the pools are collected, each pool being marshaled from a copy
+// of its endpoints, so that marshaling a large table does not stall
+// registrations.
+func (r *RouteRegistry) MarshalJSON() ([]byte, error) {
+	var buf bytes.Buffer
+	if err := writeRoutesJSON(&buf, r.routePools()); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
 }
 
 func (r *RouteRegistry) pruneStaleDroplets() {
-	r.Lock()
-	defer r.Unlock()
+	started := time.Now()
+	endpointsPruned, poolsDeleted, pruned := r.pruneStaleEndpoints()
+	if !pruned {
+		return
+	}
 
+	duration := time.Since(started)
+	r.reporter.CapturePruneCycle(duration, endpointsPruned, poolsDeleted)
+	if r.pruneCycleWarningDuration > 0 && duration > r.pruneCycleWarningDuration {
+		r.logger.Warn("prune-cycle-slow",
+			zap.Duration("duration", duration),
+			zap.Duration("warning_duration", r.pruneCycleWarningDuration),
+			zap.Int("endpoints_pruned", endpointsPruned),
+			zap.Int("pools_deleted", poolsDeleted),
+		)
+	}
+}
+
+// pruneStaleEndpoints removes the stale endpoints, returning how many
+// endpoints it removed and how many pools these emptied. pruned is false when
+// pruning is suspended. The routing table is locked for at most
+// pruneChunkSize routes at a time, so that lookups and registrations are not
+// stalled while a large table is pruned.
+func (r *RouteRegistry) pruneStaleEndpoints() (endpointsPruned, poolsDeleted int, pruned bool) {
+	r.Lock()
 	// suspend pruning if option enabled and if NATS is unavailable
 	if r.suspendPruning() {
 		r.logger.Info("prune-suspended")
 		r.pruningStatus = DISCONNECTED
-		return
+		r.Unlock()
+		return 0, 0, false
 	}
 	if r.pruningStatus == DISCONNECTED {
 		// if we are coming back from being disconnected from source,
@@ -270,26 +628,150 @@ func (r *RouteRegistry) pruneStaleDroplets() {
 		r.logger.Debug("prune-unsuspended-refresh-routes-complete")
 	}
 	r.pruningStatus = CONNECTED
+	r.Unlock()
 
+	r.RLock()
+	uris := make([]route.Uri, 0, r.uris)
 	r.byURI.EachNodeWithPool(func(t *container.Trie) {
-		endpoints := t.Pool.PruneEndpoints(r.dropletStaleThreshold)
-		t.Snip()
-		if len(endpoints) > 0 {
-			addresses := []string{}
-			for _, e := range endpoints {
-				addresses = append(addresses, e.CanonicalAddr())
+		uris = append(uris, route.Uri(t.ToPath()))
+	})
+	r.RUnlock()
+
+	now := time.Now()
+	for len(uris) > 0 {
+		chunk := len(uris)
+		if r.pruneChunkSize > 0 && r.pruneChunkSize < chunk {
+			chunk = r.pruneChunkSize
+		}
+
+		r.Lock()
+		for _, uri := range uris[:chunk] {
+			endpoints, deleted := r.pruneRouteLocked(uri, now)
+			endpointsPruned += endpoints
+			if deleted {
+				poolsDeleted++
 			}
-			isolationSegment := endpoints[0].IsolationSegment
-			if isolationSegment == "" {
-				isolationSegment = "-"
+		}
+		r.Unlock()
+		uris = uris[chunk:]
+	}
+
+	r.Lock()
+	r.lastPrune = time.Now()
+	r.Unlock()
+	return endpointsPruned, poolsDeleted, true
+}
+
+// pruneRouteLocked removes the stale endpoints of uri, returning how many it
+// removed and whether that emptied its pool. Routes unregistered since the
+// cycle listed them are skipped.
+func (r *RouteRegistry) pruneRouteLocked(uri route.Uri, now time.Time) (int, bool) {
+	t := r.byURI.FindNode(uri)
+	if t == nil || t.Pool == nil {
+		return 0, false
+	}
+
+	var quarantined, endpoints []*route.Endpoint
+	if r.endpointQuarantinePeriod > 0 {
+		quarantined, endpoints = t.Pool.QuarantineEndpoints(r.staleThreshold(uri), r.endpointQuarantinePeriod)
+	} else {
+		endpoints = t.Pool.PruneStaleEndpoints(r.staleThreshold(uri))
+	}
+	deleted := len(endpoints) > 0 && t.Pool.IsEmpty()
+	if deleted {
+		r.uris--
+	}
+	if len(quarantined) > 0 || len(endpoints) > 0 {
+		r.sequence++
+	}
+	t.Snip()
+	if len(quarantined) > 0 {
+		r.quarantined(uri, quarantined)
+	}
+	if len(endpoints) == 0 {
+		return 0, false
+	}
+
+	addresses := []string{}
+	activity := []route.EndpointActivity{}
+	for _, e := range endpoints {
+		addresses = append(addresses, e.CanonicalAddr())
+		activity = append(activity, e.Activity())
+		r.auditLogger.Log(audit.Record{
+			Time:     now,
+			Action:   audit.ActionPrune,
+			Source:   audit.SourcePruner,
+			URI:      uri,
+			Endpoint: e,
+		})
+	}
+	isolationSegment := endpoints[0].IsolationSegment
+	if isolationSegment == "" {
+		isolationSegment = "-"
+	}
+	r.logger.Info("pruned-route",
+		zap.String("uri", uri.String()),
+		zap.Object("endpoints", addresses),
+		zap.Object("endpoint_activity", activity),
+		zap.Object("isolation_segment", isolationSegment),
+	)
+	return len(endpoints), deleted
+}
+
+// staleThreshold returns the stale threshold of the endpoints of uri: that of
+// the first class they belong to, or droplet_stale_threshold
+func (r *RouteRegistry) staleThreshold(uri route.Uri) route.StaleThreshold {
+	if len(r.staleThresholdClasses) == 0 {
+		return route.FixedStaleThreshold(r.dropletStaleThreshold)
+	}
+
+	host := strings.SplitN(uri.String(), "/", 2)[0]
+	return func(e *route.Endpoint) time.Duration {
+		for i := range r.staleThresholdClasses {
+			if r.staleThresholdClasses[i].Matches(host, e.Tags) {
+				return r.staleThresholdClasses[i].Threshold
 			}
-			r.logger.Info("pruned-route",
-				zap.String("uri", t.ToPath()),
-				zap.Object("endpoints", addresses),
-				zap.Object("isolation_segment", isolationSegment),
-			)
 		}
-	})
+		return r.dropletStaleThreshold
+	}
+}
+
+func (r *RouteRegistry) quarantined(uri route.Uri, endpoints []*route.Endpoint) {
+	addresses := make([]string, 0, len(endpoints))
+	activity := make([]route.EndpointActivity, 0, len(endpoints))
+	for _, e := range endpoints {
+		addresses = append(addresses, e.CanonicalAddr())
+		activity = append(activity, e.Activity())
+	}
+	r.logger.Info("quarantined-route",
+		zap.String("uri", uri.String()),
+		zap.Object("endpoints", addresses),
+		zap.Object("endpoint_activity", activity),
+		zap.Duration("quarantine_period", r.endpointQuarantinePeriod),
+	)
+}
+
+// OnAddressChange registers a handler for endpoints that re-register at a new
+// address. Handlers are called outside the registry lock.
+func (r *RouteRegistry) OnAddressChange(h AddressChangeHandler) {
+	r.Lock()
+	handlers := make([]AddressChangeHandler, len(r.addressChangeHandlers), len(r.addressChangeHandlers)+1)
+	copy(handlers, r.addressChangeHandlers)
+	r.addressChangeHandlers = append(handlers, h)
+	r.Unlock()
+}
+
+// OnEndpointDeparted registers a handler for backend addresses that are no
+// longer registered for any route
+func (r *RouteRegistry) OnEndpointDeparted(h EndpointDepartedHandler) {
+	r.departures.subscribe(h)
+}
+
+// SetAuditLogger records pruned endpoints in the given audit log
+func (r *RouteRegistry) SetAuditLogger(l audit.Logger) {
+	r.Lock()
+	r.auditLogger = l
+	r.Unlock()
 }
 
 func (r *RouteRegistry) SuspendPruning(f func() bool) {
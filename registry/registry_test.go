@@ -3,6 +3,8 @@ package registry_test
 import (
 	"fmt"
 
+	"code.cloudfoundry.org/gorouter/audit"
+	auditFakes "code.cloudfoundry.org/gorouter/audit/fakes"
 	"code.cloudfoundry.org/gorouter/logger"
 	. "code.cloudfoundry.org/gorouter/registry"
 	"code.cloudfoundry.org/gorouter/test_util"
@@ -346,6 +348,11 @@ var _ = Describe("RouteRegistry", func() {
 							Expect(ep.ModificationTag).To(Equal(modTag))
 							Expect(ep).To(Equal(endpoint2))
 						})
+
+						It("reports the stale update", func() {
+							Expect(reporter.CaptureStaleRegistryUpdateCallCount()).To(Equal(1))
+							Expect(reporter.CaptureStaleRegistryUpdateArgsForCall(0)).To(Equal("drop"))
+						})
 					})
 				})
 
@@ -367,6 +374,188 @@ var _ = Describe("RouteRegistry", func() {
 			})
 
 		})
+
+		Context("when an instance re-registers at a new address", func() {
+			var changes [][2]*route.Endpoint
+
+			BeforeEach(func() {
+				changes = nil
+				r.OnAddressChange(func(oldEndpoint, newEndpoint *route.Endpoint) {
+					changes = append(changes, [2]*route.Endpoint{oldEndpoint, newEndpoint})
+				})
+			})
+
+			It("notifies the address change handlers", func() {
+				moved := route.NewEndpoint("12345", "192.168.1.9", 1234, "id1", "0", nil, -1, "", modTag, "")
+				r.Register("foo", fooEndpoint)
+				r.Register("foo", moved)
+
+				Expect(changes).To(HaveLen(1))
+				Expect(changes[0][0]).To(Equal(fooEndpoint))
+				Expect(changes[0][1]).To(Equal(moved))
+				Expect(logger).To(gbytes.Say("endpoint-address-changed"))
+			})
+
+			It("does not notify for other instances", func() {
+				r.Register("foo", fooEndpoint)
+				r.Register("foo", barEndpoint)
+				r.Register("foo", fooEndpoint)
+
+				Expect(changes).To(BeEmpty())
+			})
+		})
+
+		Context("Stale update policies", func() {
+			var newer, older *route.Endpoint
+
+			BeforeEach(func() {
+				newer = route.NewEndpoint("", "1.1.1.1", 1234, "", "", nil, -1, "",
+					models.ModificationTag{Guid: "abc", Index: 2}, "")
+				older = route.NewEndpoint("", "1.1.1.1", 1234, "", "", nil, -1, "",
+					models.ModificationTag{Guid: "abc", Index: 1}, "")
+			})
+
+			Context("overwrite", func() {
+				BeforeEach(func() {
+					configObj.StaleUpdatePolicy = config.STALE_UPDATE_OVERWRITE
+					r = NewRouteRegistry(logger, configObj, reporter)
+					r.Register("foo.com", newer)
+					r.Register("foo.com", older)
+				})
+
+				It("replaces the endpoint with the older registration", func() {
+					Expect(r.Lookup("foo.com").Endpoints("", "").Next()).To(Equal(older))
+					Expect(reporter.CaptureStaleRegistryUpdateCallCount()).To(Equal(1))
+					Expect(reporter.CaptureStaleRegistryUpdateArgsForCall(0)).To(Equal("overwrite"))
+				})
+			})
+
+			Context("log-only", func() {
+				BeforeEach(func() {
+					configObj.StaleUpdatePolicy = config.STALE_UPDATE_LOG_ONLY
+					r = NewRouteRegistry(logger, configObj, reporter)
+					r.Register("foo.com", newer)
+					r.Register("foo.com", older)
+				})
+
+				It("replaces the endpoint and logs the stale update", func() {
+					Expect(r.Lookup("foo.com").Endpoints("", "").Next()).To(Equal(older))
+					Expect(reporter.CaptureStaleRegistryUpdateArgsForCall(0)).To(Equal("log-only"))
+					Expect(logger).To(gbytes.Say("stale-endpoint-update-applied"))
+				})
+			})
+		})
+	})
+
+	Context("RegisterBatch", func() {
+		It("registers every route of the batch", func() {
+			r.RegisterBatch([]Registration{
+				{URI: "foo", Endpoint: fooEndpoint},
+				{URI: "bar", Endpoint: barEndpoint},
+				{URI: "bar", Endpoint: bar2Endpoint},
+			})
+
+			Expect(r.NumUris()).To(Equal(2))
+			Expect(r.NumEndpoints()).To(Equal(3))
+			Expect(r.Lookup("bar").IsEmpty()).To(BeFalse())
+		})
+
+		It("reports the batch once", func() {
+			r.RegisterBatch([]Registration{
+				{URI: "foo", Endpoint: fooEndpoint},
+				{URI: "bar", Endpoint: barEndpoint},
+			})
+
+			Expect(reporter.CaptureRegistryMessageCallCount()).To(Equal(0))
+			Expect(reporter.CaptureRegistryBatchCallCount()).To(Equal(1))
+			Expect(reporter.CaptureRegistryBatchArgsForCall(0)).To(Equal(2))
+		})
+
+		It("records the time of the update", func() {
+			r.RegisterBatch([]Registration{{URI: "foo", Endpoint: fooEndpoint}})
+			Expect(r.TimeOfLastUpdate()).NotTo(BeZero())
+		})
+
+		Context("when sharding by isolation segment", func() {
+			BeforeEach(func() {
+				configObj.RoutingTableShardingMode = config.SHARD_SEGMENTS
+				configObj.IsolationSegments = []string{"foo"}
+				r = NewRouteRegistry(logger, configObj, reporter)
+			})
+
+			It("registers only the routes of the router's segments", func() {
+				inSegment := route.NewEndpoint("", "192.168.1.1", 1234, "", "", nil, -1, "", modTag, "foo")
+				r.RegisterBatch([]Registration{
+					{URI: "foo", Endpoint: inSegment},
+					{URI: "bar", Endpoint: barEndpoint},
+				})
+
+				Expect(r.NumUris()).To(Equal(1))
+				Expect(reporter.CaptureRegistryBatchArgsForCall(0)).To(Equal(1))
+			})
+		})
+	})
+
+	Context("when a route is registered by several sources", func() {
+		var natsEndpoint, apiEndpoint *route.Endpoint
+
+		BeforeEach(func() {
+			natsEndpoint = route.NewEndpoint("", "192.168.1.1", 1234, "", "", nil, -1, "", modTag, "")
+			natsEndpoint.Source = "nats"
+			apiEndpoint = route.NewEndpoint("", "192.168.1.2", 1234, "", "", nil, -1, "", modTag, "")
+			apiEndpoint.Source = "routing_api"
+		})
+
+		It("merges their endpoints and reports the conflict", func() {
+			r.Register("foo", natsEndpoint)
+			r.Register("foo", apiEndpoint)
+
+			Expect(r.Lookup("foo").Sources()).To(ConsistOf("nats", "routing_api"))
+			Expect(reporter.CaptureSourceConflictCallCount()).To(Equal(1))
+			Expect(reporter.CaptureSourceConflictArgsForCall(0)).To(Equal(SourceConflictMerged))
+		})
+
+		Context("with a precedence", func() {
+			BeforeEach(func() {
+				configObj.RouteSources.Precedence = []string{"routing_api", "nats"}
+				r = NewRouteRegistry(logger, configObj, reporter)
+			})
+
+			It("replaces the endpoints of a lower ranked source", func() {
+				r.Register("foo", natsEndpoint)
+				r.Register("foo", apiEndpoint)
+
+				Expect(r.Lookup("foo").Sources()).To(ConsistOf("routing_api"))
+				Expect(r.NumEndpoints()).To(Equal(1))
+				Expect(reporter.CaptureSourceConflictArgsForCall(0)).To(Equal(SourceConflictReplaced))
+				Expect(logger).To(gbytes.Say("endpoints-replaced-by-source"))
+			})
+
+			It("rejects the endpoints of a lower ranked source", func() {
+				r.Register("foo", apiEndpoint)
+				r.Register("foo", natsEndpoint)
+
+				Expect(r.Lookup("foo").Sources()).To(ConsistOf("routing_api"))
+				Expect(reporter.CaptureSourceConflictArgsForCall(0)).To(Equal(SourceConflictRejected))
+			})
+
+			It("ranks unlisted sources last", func() {
+				staticEndpoint := route.NewEndpoint("", "192.168.1.3", 1234, "", "", nil, -1, "", modTag, "")
+				staticEndpoint.Source = "static_routes"
+				r.Register("foo", natsEndpoint)
+				r.Register("foo", staticEndpoint)
+
+				Expect(r.Lookup("foo").Sources()).To(ConsistOf("nats"))
+			})
+
+			It("does not affect other routes", func() {
+				r.Register("foo", apiEndpoint)
+				r.Register("bar", natsEndpoint)
+
+				Expect(r.NumEndpoints()).To(Equal(2))
+				Expect(reporter.CaptureSourceConflictCallCount()).To(Equal(0))
+			})
+		})
 	})
 
 	Context("Unregister", func() {
@@ -778,14 +967,41 @@ var _ = Describe("RouteRegistry", func() {
 		})
 	})
 
+	Context("LookupSteps", func() {
+		It("returns the keys tried until a wildcard route matched", func() {
+			app := route.NewEndpoint("", "192.168.1.2", 1234, "", "", nil, -1, "", modTag, "")
+			r.Register("*.wild.card", app)
+
+			p, steps := r.LookupSteps("Foo.Space.wild.card")
+			Expect(p).ToNot(BeNil())
+			Expect(steps).To(Equal([]route.Uri{"foo.space.wild.card", "*.space.wild.card", "*.wild.card"}))
+		})
+
+		It("returns only the uri when it matched", func() {
+			app := route.NewEndpoint("", "192.168.1.1", 1234, "", "", nil, -1, "", modTag, "")
+			r.Register("not.wild.card", app)
+
+			p, steps := r.LookupSteps("not.wild.card")
+			Expect(p).ToNot(BeNil())
+			Expect(steps).To(Equal([]route.Uri{"not.wild.card"}))
+		})
+
+		It("returns every key tried when none matched", func() {
+			p, steps := r.LookupSteps("non.existent")
+			Expect(p).To(BeNil())
+			Expect(steps).To(Equal([]route.Uri{"non.existent", "*.existent"}))
+		})
+	})
+
 	Context("LookupWithInstance", func() {
 		var (
 			appId    string
 			appIndex string
+			m1       *route.Endpoint
 		)
 
 		BeforeEach(func() {
-			m1 := route.NewEndpoint("app-1-ID", "192.168.1.1", 1234, "", "0", nil, -1, "", modTag, "")
+			m1 = route.NewEndpoint("app-1-ID", "192.168.1.1", 1234, "", "0", nil, -1, "", modTag, "")
 			m2 := route.NewEndpoint("app-2-ID", "192.168.1.2", 1235, "", "0", nil, -1, "", modTag, "")
 
 			r.Register("bar", m1)
@@ -809,6 +1025,14 @@ var _ = Describe("RouteRegistry", func() {
 			Expect(r.NumEndpoints()).To(Equal(2))
 		})
 
+		It("does not refresh the registration of the instance", func() {
+			seen := m1.Stats.LastSeen()
+
+			p := r.LookupWithInstance("bar", appId, appIndex)
+			Expect(p.Endpoints("", "").Next()).To(Equal(m1))
+			Expect(m1.Stats.LastSeen()).To(Equal(seen))
+		})
+
 		Context("when lookup fails to find any routes", func() {
 			It("returns nil", func() {
 				p := r.LookupWithInstance("foo", appId, appIndex)
@@ -845,6 +1069,135 @@ var _ = Describe("RouteRegistry", func() {
 		})
 	})
 
+	Context("OnEndpointDeparted", func() {
+		var departed chan string
+
+		BeforeEach(func() {
+			departed = make(chan string, 10)
+			r.OnEndpointDeparted(func(addr string) {
+				departed <- addr
+			})
+		})
+
+		It("reports an address once it is unregistered from its last route", func() {
+			r.Register("foo", fooEndpoint)
+			r.Register("bar", fooEndpoint)
+
+			r.Unregister("foo", fooEndpoint)
+			Consistently(departed).ShouldNot(Receive())
+
+			r.Unregister("bar", fooEndpoint)
+			Eventually(departed).Should(Receive(Equal("192.168.1.1:1234")))
+		})
+
+		It("does not report an endpoint replaced at the same address", func() {
+			r.Register("foo", fooEndpoint)
+			replacement := route.NewEndpoint("12345", "192.168.1.1", 1234, "id1", "0", nil, -1, "",
+				models.ModificationTag{Guid: "abc"}, "")
+			r.Register("foo", replacement)
+
+			Consistently(departed).ShouldNot(Receive())
+		})
+
+		It("reports pruned addresses", func() {
+			r.Register("foo", fooEndpoint)
+			r.StartPruningCycle()
+			defer r.StopPruningCycle()
+
+			Eventually(departed).Should(Receive(Equal("192.168.1.1:1234")))
+		})
+	})
+
+	Context("MemoryUsage", func() {
+		It("is empty for an empty table", func() {
+			total, byDomain := r.MemoryUsage()
+			Expect(total.Endpoints).To(Equal(0))
+			Expect(total.Pools).To(Equal(0))
+			Expect(byDomain).To(BeEmpty())
+		})
+
+		It("breaks the estimate down by domain", func() {
+			r.Register("foo.example.com", fooEndpoint)
+			r.Register("foo.example.com/path", fooEndpoint)
+			r.Register("bar.example.com", barEndpoint)
+			r.Register("bar.other.com", barEndpoint)
+
+			total, byDomain := r.MemoryUsage()
+			Expect(total.Pools).To(Equal(4))
+			Expect(total.Endpoints).To(Equal(2))
+			Expect(total.TotalBytes).To(Equal(total.TrieBytes + total.PoolBytes + total.EndpointBytes))
+
+			Expect(byDomain).To(HaveLen(2))
+			Expect(byDomain["example.com"].Pools).To(Equal(3))
+			Expect(byDomain["example.com"].Endpoints).To(Equal(2))
+			Expect(byDomain["other.com"].Pools).To(Equal(1))
+			Expect(byDomain["other.com"].Endpoints).To(Equal(1))
+			Expect(byDomain["other.com"].TotalBytes).To(BeNumerically("<", byDomain["example.com"].TotalBytes))
+		})
+
+		It("reports the estimate on each pruning cycle", func() {
+			r.Register("foo.example.com", fooEndpoint)
+			r.StartPruningCycle()
+			defer r.StopPruningCycle()
+
+			Eventually(reporter.CaptureRegistryMemoryCallCount).Should(BeNumerically(">=", 1))
+			trieNodes, trieBytes, _, _ := reporter.CaptureRegistryMemoryArgsForCall(0)
+			Expect(trieNodes).To(BeNumerically(">=", 1))
+			Expect(trieBytes).To(BeNumerically(">", 0))
+		})
+	})
+
+	Context("Pruning lifecycle", func() {
+		AfterEach(func() {
+			r.StopPruningCycle()
+		})
+
+		It("is not running until started", func() {
+			state := r.PruningState()
+			Expect(state.Running).To(BeFalse())
+			Expect(state.LastPrune).To(BeZero())
+		})
+
+		It("stops pruning when stopped", func() {
+			r.StartPruningCycle()
+			Expect(r.PruningState().Running).To(BeTrue())
+			Eventually(reporter.CapturePruneCycleCallCount).Should(BeNumerically(">=", 1))
+
+			r.StopPruningCycle()
+			Expect(r.PruningState().Running).To(BeFalse())
+
+			cycles := reporter.CapturePruneCycleCallCount()
+			Consistently(reporter.CapturePruneCycleCallCount, 3*configObj.PruneStaleDropletsInterval).Should(Equal(cycles))
+		})
+
+		It("does not start a second cycle when started twice", func() {
+			r.StartPruningCycle()
+			r.StartPruningCycle()
+
+			time.Sleep(configObj.PruneStaleDropletsInterval / 2)
+			Eventually(reporter.CapturePruneCycleCallCount).Should(Equal(1))
+			Consistently(reporter.CapturePruneCycleCallCount, configObj.PruneStaleDropletsInterval/2).Should(Equal(1))
+		})
+
+		It("can be started again once stopped", func() {
+			r.StartPruningCycle()
+			r.StopPruningCycle()
+			r.StopPruningCycle()
+
+			r.Register("foo", fooEndpoint)
+			r.StartPruningCycle()
+			Eventually(r.NumUris).Should(Equal(0))
+			Expect(r.PruningState().Running).To(BeTrue())
+		})
+
+		It("reports pruning as suspended while NATS is unavailable", func() {
+			r.SuspendPruning(func() bool { return true })
+			r.StartPruningCycle()
+
+			Eventually(func() bool { return r.PruningState().Suspended }).Should(BeTrue())
+		})
+	})
+
 	Context("Prunes Stale Droplets", func() {
 		AfterEach(func() {
 			r.StopPruningCycle()
@@ -864,6 +1217,21 @@ var _ = Describe("RouteRegistry", func() {
 			Expect(logger).To(gbytes.Say(`"log_level":1.*prune.*bar.com/path1/path2/path3.*endpoints.*isolation_segment`))
 		})
 
+		It("records pruned endpoints in the audit log", func() {
+			auditLogger := new(auditFakes.FakeLogger)
+			r.SetAuditLogger(auditLogger)
+			r.Register("bar.com/path1", barEndpoint)
+
+			r.StartPruningCycle()
+			Eventually(auditLogger.LogCallCount).Should(Equal(1))
+
+			record := auditLogger.LogArgsForCall(0)
+			Expect(record.Action).To(Equal(audit.ActionPrune))
+			Expect(record.Source).To(Equal(audit.SourcePruner))
+			Expect(record.URI).To(Equal(route.Uri("bar.com/path1")))
+			Expect(record.Endpoint).To(Equal(barEndpoint))
+		})
+
 		It("removes stale droplets", func() {
 			r.Register("foo", fooEndpoint)
 			r.Register("fooo", fooEndpoint)
@@ -943,6 +1311,75 @@ var _ = Describe("RouteRegistry", func() {
 			Expect(p).To(BeNil())
 		})
 
+		It("reports the prune cycles", func() {
+			r.Register("foo", fooEndpoint)
+			r.Register("foo/path", fooEndpoint)
+			r.Register("bar", barEndpoint)
+
+			r.StartPruningCycle()
+			Eventually(reporter.CapturePruneCycleCallCount).Should(BeNumerically(">=", 1))
+
+			duration, endpointsPruned, poolsDeleted := reporter.CapturePruneCycleArgsForCall(0)
+			Expect(duration).To(BeNumerically(">", 0))
+			Expect(endpointsPruned).To(Equal(3))
+			Expect(poolsDeleted).To(Equal(3))
+
+			Eventually(reporter.CaptureTimeSinceLastPruneCallCount).Should(BeNumerically(">=", 1))
+			Expect(reporter.CaptureTimeSinceLastPruneArgsForCall(0)).To(BeNumerically("<", configObj.PruneStaleDropletsInterval))
+		})
+
+		Context("when the table is pruned in chunks", func() {
+			BeforeEach(func() {
+				configObj.PruneChunkSize = 1
+				r = NewRouteRegistry(logger, configObj, reporter)
+			})
+
+			It("removes stale droplets from every chunk", func() {
+				r.Register("foo", fooEndpoint)
+				r.Register("foo/path", fooEndpoint)
+				r.Register("bar", barEndpoint)
+				r.Register("baar", barEndpoint)
+
+				r.StartPruningCycle()
+				Eventually(reporter.CapturePruneCycleCallCount).Should(BeNumerically(">=", 1))
+
+				_, endpointsPruned, poolsDeleted := reporter.CapturePruneCycleArgsForCall(0)
+				Expect(endpointsPruned).To(Equal(4))
+				Expect(poolsDeleted).To(Equal(4))
+				Expect(r.NumUris()).To(Equal(0))
+				Expect(r.NumEndpoints()).To(Equal(0))
+			})
+
+			It("keeps fresh droplets", func() {
+				r.Register("foo", fooEndpoint)
+				r.Register("bar", barEndpoint)
+
+				r.StartPruningCycle()
+				Eventually(func() int {
+					e := *barEndpoint
+					r.Register("bar", &e)
+					return reporter.CapturePruneCycleCallCount()
+				}).Should(BeNumerically(">=", 1))
+
+				Expect(r.Lookup("foo")).To(BeNil())
+				Expect(r.Lookup("bar")).NotTo(BeNil())
+			})
+		})
+
+		Context("when a prune cycle lasts longer than the warning duration", func() {
+			BeforeEach(func() {
+				configObj.PruneCycleWarningDuration = time.Nanosecond
+				r = NewRouteRegistry(logger, configObj, reporter)
+			})
+
+			It("logs a warning", func() {
+				r.Register("foo", fooEndpoint)
+				r.StartPruningCycle()
+
+				Eventually(logger).Should(gbytes.Say(`prune-cycle-slow.*endpoints_pruned`))
+			})
+		})
+
 		It("does not block when pruning", func() {
 			// when pruning stale droplets,
 			// and the stale check takes a while,
@@ -1030,6 +1467,12 @@ var _ = Describe("RouteRegistry", func() {
 				time.Sleep(configObj.PruneStaleDropletsInterval + configObj.DropletStaleThreshold)
 			})
 
+			It("does not report prune cycles", func() {
+				Expect(reporter.CapturePruneCycleCallCount()).To(Equal(0))
+				Eventually(reporter.CaptureTimeSinceLastPruneCallCount).Should(BeNumerically(">=", 1))
+				Expect(r.LastPrune()).To(BeTemporally("<", time.Now().Add(-configObj.DropletStaleThreshold)))
+			})
+
 			It("does not remove any routes", func() {
 				Expect(r.NumUris()).To(Equal(totalRoutes))
 				Expect(r.NumEndpoints()).To(Equal(totalRoutes))
@@ -1104,4 +1547,28 @@ var _ = Describe("RouteRegistry", func() {
 		Expect(err).NotTo(HaveOccurred())
 		Expect(string(marshalled)).To(Equal(`{}`))
 	})
+
+	It("marshals the routes in order while they are registered", func() {
+		r.Register("b", fooEndpoint)
+		r.Register("a", barEndpoint)
+
+		done := make(chan struct{})
+		go func() {
+			defer GinkgoRecover()
+			defer close(done)
+			for i := 0; i < 100; i++ {
+				r.Register(route.Uri(fmt.Sprintf("app%d.example.com", i)), fooEndpoint)
+			}
+		}()
+
+		for i := 0; i < 10; i++ {
+			marshalled, err := json.Marshal(r)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(string(marshalled)).To(HavePrefix(`{"a":[`))
+
+			var routes map[string]interface{}
+			Expect(json.Unmarshal(marshalled, &routes)).To(Succeed())
+		}
+		<-done
+	})
 })
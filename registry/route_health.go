@@ -0,0 +1,97 @@
+package registry
+
+import (
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"code.cloudfoundry.org/gorouter/route"
+)
+
+// RouteHealthPath is the path the route health endpoint is served under,
+// followed by the host
+const RouteHealthPath = "/health/routes/"
+
+// RouteHealth is the health of one route of a host
+type RouteHealth struct {
+	Route string `json:"route"`
+	route.PoolHealth
+}
+
+// HostHealth is the health of the routes of a host, which is routable while
+// any of them has fresh endpoints
+type HostHealth struct {
+	Host     string        `json:"host"`
+	Routable bool          `json:"routable"`
+	Routes   []RouteHealth `json:"routes"`
+}
+
+// HostHealth returns the health of the route of host and of the routes of its
+// paths, and false when none of them is registered
+func (r *RouteRegistry) HostHealth(host string) (HostHealth, bool) {
+	uri := route.Uri(host).RouteKey()
+
+	r.RLock()
+	var pools map[route.Uri]*route.Pool
+	if node := r.byURI.FindNode(uri); node != nil {
+		pools = node.ToMap()
+	}
+	r.RUnlock()
+
+	if len(pools) == 0 {
+		return HostHealth{}, false
+	}
+
+	now := time.Now()
+	health := HostHealth{Host: uri.String(), Routes: make([]RouteHealth, 0, len(pools))}
+	for uri, pool := range pools {
+		routeHealth := RouteHealth{Route: uri.String(), PoolHealth: pool.Health(now, r.staleThreshold(uri))}
+		if routeHealth.FreshEndpoints > 0 {
+			health.Routable = true
+		}
+		health.Routes = append(health.Routes, routeHealth)
+	}
+	sort.Slice(health.Routes, func(i, j int) bool {
+		return health.Routes[i].Route < health.Routes[j].Route
+	})
+	return health, true
+}
+
+type routeHealthHandler struct {
+	registry *RouteRegistry
+}
+
+// NewRouteHealthHandler returns the health of the host following
+// RouteHealthPath, with status 200 when it is routable, 503 when it is not and
+// 404 when it has no routes
+func NewRouteHealthHandler(registry *RouteRegistry) http.Handler {
+	return &routeHealthHandler{registry: registry}
+}
+
+func (h *routeHealthHandler) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	w.Header().Set("Connection", "close")
+
+	if req.Method != "GET" {
+		w.Header().Set("Allow", "GET")
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+
+	host := strings.TrimPrefix(req.URL.Path, RouteHealthPath)
+	if host == "" || strings.Contains(host, "/") {
+		http.Error(w, "a host is required", http.StatusBadRequest)
+		return
+	}
+
+	health, ok := h.registry.HostHealth(host)
+	if !ok {
+		http.Error(w, "no routes for "+host, http.StatusNotFound)
+		return
+	}
+	status := http.StatusOK
+	if !health.Routable {
+		status = http.StatusServiceUnavailable
+	}
+	writeJSON(w, status, health)
+}
@@ -0,0 +1,100 @@
+package registry_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"time"
+
+	"code.cloudfoundry.org/gorouter/config"
+	"code.cloudfoundry.org/gorouter/metrics/fakes"
+	. "code.cloudfoundry.org/gorouter/registry"
+	"code.cloudfoundry.org/gorouter/route"
+	"code.cloudfoundry.org/gorouter/test_util"
+	"code.cloudfoundry.org/routing-api/models"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Route health", func() {
+	var (
+		r         *RouteRegistry
+		configObj *config.Config
+	)
+
+	endpoint := func(host string) *route.Endpoint {
+		return route.NewEndpoint("app", host, 8080, "", "", nil, -1, "", models.ModificationTag{}, "")
+	}
+
+	serve := func(path string) *httptest.ResponseRecorder {
+		recorder := httptest.NewRecorder()
+		NewRouteHealthHandler(r).ServeHTTP(recorder, httptest.NewRequest("GET", path, nil))
+		return recorder
+	}
+
+	BeforeEach(func() {
+		configObj = config.DefaultConfig()
+		configObj.DropletStaleThreshold = time.Minute
+	})
+
+	JustBeforeEach(func() {
+		r = NewRouteRegistry(test_util.NewTestZapLogger("test"), configObj, new(fakes.FakeRouteRegistryReporter))
+	})
+
+	It("reports the routes of the host and of its paths", func() {
+		e := endpoint("10.0.0.1")
+		r.Register("app.example.com", e)
+		r.Register("app.example.com/api", endpoint("10.0.0.2"))
+		r.Register("other.example.com", endpoint("10.0.0.3"))
+
+		e.Stats.Failed()
+		succeeded := time.Date(2026, 10, 1, 12, 0, 0, 0, time.UTC)
+		e.Stats.Succeeded(succeeded)
+
+		health, ok := r.HostHealth("App.Example.com")
+		Expect(ok).To(BeTrue())
+		Expect(health.Host).To(Equal("app.example.com"))
+		Expect(health.Routable).To(BeTrue())
+		Expect(health.Routes).To(HaveLen(2))
+
+		Expect(health.Routes[0].Route).To(Equal("app.example.com"))
+		Expect(health.Routes[0].Endpoints).To(Equal(1))
+		Expect(health.Routes[0].FreshEndpoints).To(Equal(1))
+		Expect(health.Routes[0].Failures).To(BeEquivalentTo(1))
+		Expect(health.Routes[0].LastSuccess.Equal(succeeded)).To(BeTrue())
+
+		Expect(health.Routes[1].Route).To(Equal("app.example.com/api"))
+		Expect(health.Routes[1].LastSuccess).To(BeNil())
+	})
+
+	It("serves the health as JSON", func() {
+		r.Register("app.example.com", endpoint("10.0.0.1"))
+
+		recorder := serve("/health/routes/app.example.com")
+		Expect(recorder.Code).To(Equal(http.StatusOK))
+		Expect(recorder.Body.String()).To(MatchJSON(`{"host":"app.example.com","routable":true,"routes":[{"route":"app.example.com","endpoints":1,"fresh_endpoints":1,"failures":0}]}`))
+	})
+
+	It("answers 404 for hosts without routes", func() {
+		Expect(serve("/health/routes/unknown.example.com").Code).To(Equal(http.StatusNotFound))
+	})
+
+	It("requires a host", func() {
+		Expect(serve("/health/routes/").Code).To(Equal(http.StatusBadRequest))
+	})
+
+	Context("when the endpoints are stale", func() {
+		BeforeEach(func() {
+			configObj.DropletStaleThreshold = 10 * time.Millisecond
+		})
+
+		It("answers 503", func() {
+			r.Register("app.example.com", endpoint("10.0.0.1"))
+			time.Sleep(20 * time.Millisecond)
+
+			recorder := serve("/health/routes/app.example.com")
+			Expect(recorder.Code).To(Equal(http.StatusServiceUnavailable))
+			Expect(recorder.Body.String()).To(ContainSubstring(`"fresh_endpoints":0`))
+		})
+	})
+
+})
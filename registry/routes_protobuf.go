@@ -0,0 +1,119 @@
+package registry
+
+import (
+	"bufio"
+	"encoding/binary"
+	"sort"
+
+	"code.cloudfoundry.org/gorouter/route"
+)
+
+// The protobuf encoding of the routing table is a stream of Route messages,
+// each preceded by its size as a varint, so that it can be decoded a route at
+// a time:
+//
+//	message Route {
+//	  string uri = 1;
+//	  repeated Endpoint endpoints = 2;
+//	}
+//
+//	message Endpoint {
+//	  string address = 1;
+//	  int32 ttl = 2;
+//	  string route_service_url = 3;
+//	  map<string, string> tags = 4;
+//	  string isolation_segment = 5;
+//	  bool draining = 6;
+//	  map<string, string> query_match = 7;
+//	}
+func writeRoutesProtobuf(w *bufio.Writer, routes []routePool) error {
+	var msg, endpoint protoBuffer
+	for _, r := range routes {
+		msg = msg[:0]
+		msg.string(1, r.uri.String())
+		r.pool.Each(func(e *route.Endpoint) {
+			endpoint = endpoint[:0]
+			endpoint.string(1, e.CanonicalAddr())
+			endpoint.int(2, int64(e.StaleThreshold().Seconds()))
+			endpoint.string(3, e.RouteServiceUrl)
+			endpoint.stringMap(4, e.Tags)
+			endpoint.string(5, e.IsolationSegment)
+			endpoint.bool(6, e.IsDraining())
+			endpoint.stringMap(7, e.QueryMatch)
+			msg.bytes(2, endpoint)
+		})
+
+		var size protoBuffer
+		size.varint(uint64(len(msg)))
+		w.Write(size)
+		if _, err := w.Write(msg); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// protoBuffer appends fields in the protobuf wire format, leaving out those
+// with default values as proto3 does
+type protoBuffer []byte
+
+const (
+	wireVarint = 0
+	wireBytes  = 2
+)
+
+func (b *protoBuffer) varint(v uint64) {
+	var scratch [binary.MaxVarintLen64]byte
+	n := binary.PutUvarint(scratch[:], v)
+	*b = append(*b, scratch[:n]...)
+}
+
+func (b *protoBuffer) key(field, wireType int) {
+	b.varint(uint64(field<<3 | wireType))
+}
+
+func (b *protoBuffer) bytes(field int, v []byte) {
+	b.key(field, wireBytes)
+	b.varint(uint64(len(v)))
+	*b = append(*b, v...)
+}
+
+func (b *protoBuffer) string(field int, v string) {
+	if v == "" {
+		return
+	}
+	b.key(field, wireBytes)
+	b.varint(uint64(len(v)))
+	*b = append(*b, v...)
+}
+
+func (b *protoBuffer) int(field int, v int64) {
+	if v == 0 {
+		return
+	}
+	b.key(field, wireVarint)
+	b.varint(uint64(v))
+}
+
+func (b *protoBuffer) bool(field int, v bool) {
+	if v {
+		b.key(field, wireVarint)
+		b.varint(1)
+	}
+}
+
+// stringMap encodes a map as its entries, sorted by key
+func (b *protoBuffer) stringMap(field int, m map[string]string) {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	for _, k := range keys {
+		var entry protoBuffer
+		entry.string(1, k)
+		entry.string(2, m[k])
+		b.bytes(field, entry)
+	}
+}
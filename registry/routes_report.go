@@ -0,0 +1,156 @@
+package registry
+
+import (
+	"bufio"
+	"compress/gzip"
+	"encoding/json"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+
+	router_http "code.cloudfoundry.org/gorouter/common/http"
+	"code.cloudfoundry.org/gorouter/registry/container"
+	"code.cloudfoundry.org/gorouter/route"
+)
+
+// ProtobufContentType is the media type of the protobuf encoding of the
+// routing table
+const ProtobufContentType = "application/x-protobuf"
+
+// RoutesReport serves the routing table, as JSON unless protobuf is asked for
+// in Accept, gzipped when Accept-Encoding allows it. The table is streamed a
+// route at a time rather than marshaled at once, so that dumping a large
+// table neither builds it in memory nor holds the registry lock while the
+// client reads it.
+type RoutesReport struct {
+	registry *RouteRegistry
+}
+
+func NewRoutesReport(r *RouteRegistry) *RoutesReport {
+	return &RoutesReport{registry: r}
+}
+
+func (rr *RoutesReport) MarshalJSON() ([]byte, error) {
+	return rr.registry.MarshalJSON()
+}
+
+func (rr *RoutesReport) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	protobuf := accepts(req.Header.Get("Accept"), ProtobufContentType)
+	gzipped := accepts(req.Header.Get("Accept-Encoding"), "gzip")
+
+	// each encoding is a representation of its own, with its own etag
+	etag := rr.registry.ETag()
+	if protobuf {
+		etag = strings.TrimSuffix(etag, `"`) + `-protobuf"`
+	}
+	if gzipped {
+		etag = strings.TrimSuffix(etag, `"`) + `-gzip"`
+	}
+	w.Header().Set("ETag", etag)
+	w.Header().Set("Vary", "Accept, Accept-Encoding")
+	if router_http.ETagMatches(req.Header.Get("If-None-Match"), etag) {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+
+	if protobuf {
+		w.Header().Set("Content-Type", ProtobufContentType)
+	} else {
+		w.Header().Set("Content-Type", "application/json")
+	}
+	var out io.Writer = w
+	var gz *gzip.Writer
+	if gzipped {
+		w.Header().Set("Content-Encoding", "gzip")
+		gz = gzip.NewWriter(w)
+		out = gz
+	}
+	w.WriteHeader(http.StatusOK)
+
+	buf := bufio.NewWriterSize(out, 32*1024)
+	routes := rr.registry.routePools()
+	if protobuf {
+		writeRoutesProtobuf(buf, routes)
+	} else if writeRoutesJSON(buf, routes) == nil {
+		buf.WriteByte('\n')
+	}
+	buf.Flush()
+	if gz != nil {
+		gz.Close()
+	}
+}
+
+type routePool struct {
+	uri  route.Uri
+	pool *route.Pool
+}
+
+// routePools lists the pools of the routing table by route, holding the lock
+// only while they are collected
+func (r *RouteRegistry) routePools() []routePool {
+	r.RLock()
+	routes := make([]routePool, 0, r.uris)
+	r.byURI.EachNodeWithPool(func(t *container.Trie) {
+		routes = append(routes, routePool{uri: route.Uri(t.ToPath()), pool: t.Pool})
+	})
+	r.RUnlock()
+
+	sort.Slice(routes, func(i, j int) bool {
+		return routes[i].uri < routes[j].uri
+	})
+	return routes
+}
+
+// jsonWriter is implemented by bufio.Writer and bytes.Buffer
+type jsonWriter interface {
+	io.Writer
+	WriteByte(c byte) error
+	WriteString(s string) (int, error)
+}
+
+// writeRoutesJSON writes the JSON object of the endpoints of routes by route,
+// a route at a time
+func writeRoutesJSON(w jsonWriter, routes []routePool) error {
+	w.WriteByte('{')
+	for i, r := range routes {
+		if i > 0 {
+			w.WriteByte(',')
+		}
+		uri, err := json.Marshal(r.uri.String())
+		if err != nil {
+			return err
+		}
+		endpoints, err := r.pool.MarshalJSON()
+		if err != nil {
+			return err
+		}
+		w.Write(uri)
+		w.WriteByte(':')
+		if _, err := w.Write(endpoints); err != nil {
+			return err
+		}
+	}
+	return w.WriteByte('}')
+}
+
+// accepts reports whether an Accept or Accept-Encoding header value lists
+// value with a non-zero quality
+func accepts(header, value string) bool {
+	for _, part := range strings.Split(header, ",") {
+		params := strings.Split(part, ";")
+		if !strings.EqualFold(strings.TrimSpace(params[0]), value) {
+			continue
+		}
+		for _, param := range params[1:] {
+			param = strings.TrimSpace(param)
+			if strings.HasPrefix(param, "q=") {
+				q, err := strconv.ParseFloat(param[2:], 64)
+				return err == nil && q > 0
+			}
+		}
+		return true
+	}
+	return false
+}
@@ -0,0 +1,112 @@
+package registry_test
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"encoding/binary"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+
+	"code.cloudfoundry.org/gorouter/config"
+	"code.cloudfoundry.org/gorouter/metrics/fakes"
+	. "code.cloudfoundry.org/gorouter/registry"
+	"code.cloudfoundry.org/gorouter/route"
+	"code.cloudfoundry.org/gorouter/test_util"
+	"code.cloudfoundry.org/routing-api/models"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("RoutesReport", func() {
+	var (
+		r      *RouteRegistry
+		report *RoutesReport
+	)
+
+	serve := func(headers map[string]string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("GET", "/routes", nil)
+		for name, value := range headers {
+			req.Header.Set(name, value)
+		}
+		recorder := httptest.NewRecorder()
+		report.ServeHTTP(recorder, req)
+		return recorder
+	}
+
+	BeforeEach(func() {
+		r = NewRouteRegistry(test_util.NewTestZapLogger("test"), config.DefaultConfig(), new(fakes.FakeRouteRegistryReporter))
+		r.Register("b.example.com/api", route.NewEndpoint("app", "10.0.0.1", 8080, "", "", map[string]string{"component": "api"}, 30, "", models.ModificationTag{}, ""))
+		r.Register("a.example.com", route.NewEndpoint("app", "10.0.0.2", 8080, "", "", nil, -1, "", models.ModificationTag{}, ""))
+		report = NewRoutesReport(r)
+	})
+
+	It("streams the JSON of the routing table", func() {
+		expected, err := r.MarshalJSON()
+		Expect(err).NotTo(HaveOccurred())
+
+		resp := serve(nil)
+		Expect(resp.Code).To(Equal(http.StatusOK))
+		Expect(resp.Header().Get("Content-Type")).To(Equal("application/json"))
+		Expect(resp.Body.String()).To(Equal(string(expected) + "\n"))
+	})
+
+	It("gzips the table when accepted", func() {
+		expected, err := r.MarshalJSON()
+		Expect(err).NotTo(HaveOccurred())
+
+		resp := serve(map[string]string{"Accept-Encoding": "deflate, gzip"})
+		Expect(resp.Header().Get("Content-Encoding")).To(Equal("gzip"))
+
+		reader, err := gzip.NewReader(resp.Body)
+		Expect(err).NotTo(HaveOccurred())
+		body, err := ioutil.ReadAll(reader)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(string(body)).To(Equal(string(expected) + "\n"))
+	})
+
+	It("does not gzip the table when refused", func() {
+		resp := serve(map[string]string{"Accept-Encoding": "gzip;q=0"})
+		Expect(resp.Header().Get("Content-Encoding")).To(BeEmpty())
+	})
+
+	It("encodes the table as delimited protobuf messages when asked to", func() {
+		resp := serve(map[string]string{"Accept": "application/x-protobuf"})
+		Expect(resp.Header().Get("Content-Type")).To(Equal(ProtobufContentType))
+
+		reader := bufio.NewReader(resp.Body)
+		var routes [][]byte
+		for {
+			size, err := binary.ReadUvarint(reader)
+			if err == io.EOF {
+				break
+			}
+			Expect(err).NotTo(HaveOccurred())
+			msg := make([]byte, size)
+			_, err = io.ReadFull(reader, msg)
+			Expect(err).NotTo(HaveOccurred())
+			routes = append(routes, msg)
+		}
+
+		Expect(routes).To(HaveLen(2))
+		// field 1, a string of 13 bytes
+		Expect(string(routes[0])).To(HavePrefix("\x0a\x0da.example.com"))
+		Expect(bytes.Contains(routes[1], []byte("10.0.0.1:8080"))).To(BeTrue())
+		Expect(bytes.Contains(routes[1], []byte("component"))).To(BeTrue())
+	})
+
+	It("answers requests for the current version of a representation with 304", func() {
+		etag := serve(map[string]string{"Accept-Encoding": "gzip"}).Header().Get("ETag")
+		Expect(etag).NotTo(Equal(serve(nil).Header().Get("ETag")))
+
+		resp := serve(map[string]string{"Accept-Encoding": "gzip", "If-None-Match": etag})
+		Expect(resp.Code).To(Equal(http.StatusNotModified))
+		Expect(resp.Body.Len()).To(BeZero())
+
+		r.Register("c.example.com", route.NewEndpoint("app", "10.0.0.3", 8080, "", "", nil, -1, "", models.ModificationTag{}, ""))
+		resp = serve(map[string]string{"Accept-Encoding": "gzip", "If-None-Match": etag})
+		Expect(resp.Code).To(Equal(http.StatusOK))
+	})
+})
@@ -0,0 +1,58 @@
+package registry_test
+
+import (
+	"code.cloudfoundry.org/gorouter/config"
+	"code.cloudfoundry.org/gorouter/metrics/fakes"
+	. "code.cloudfoundry.org/gorouter/registry"
+	"code.cloudfoundry.org/gorouter/route"
+	"code.cloudfoundry.org/gorouter/test_util"
+	"code.cloudfoundry.org/routing-api/models"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Routing table sequence", func() {
+	var r *RouteRegistry
+
+	endpoint := func(host string, tags map[string]string) *route.Endpoint {
+		return route.NewEndpoint("app-guid", host, 8080, "", "", tags, -1, "", models.ModificationTag{}, "")
+	}
+
+	BeforeEach(func() {
+		r = NewRouteRegistry(test_util.NewTestZapLogger("test"), config.DefaultConfig(), new(fakes.FakeRouteRegistryReporter))
+	})
+
+	It("counts the registrations changing the table", func() {
+		r.Register("app.example.com", endpoint("10.0.0.1", nil))
+		Expect(r.Sequence()).To(BeEquivalentTo(1))
+
+		r.Register("app.example.com", endpoint("10.0.0.2", nil))
+		Expect(r.Sequence()).To(BeEquivalentTo(2))
+
+		r.Unregister("app.example.com", endpoint("10.0.0.2", nil))
+		Expect(r.Sequence()).To(BeEquivalentTo(3))
+	})
+
+	It("does not count refreshed endpoints", func() {
+		r.Register("app.example.com", endpoint("10.0.0.1", map[string]string{"component": "app"}))
+		r.Register("app.example.com", endpoint("10.0.0.1", map[string]string{"component": "app"}))
+
+		Expect(r.Sequence()).To(BeEquivalentTo(1))
+	})
+
+	It("counts draining endpoints", func() {
+		r.Register("app.example.com", endpoint("10.0.0.1", nil))
+		r.SetEndpointStatus([]EndpointStatus{{AppID: "app-guid", Status: StatusDraining}})
+
+		Expect(r.Sequence()).To(BeEquivalentTo(2))
+	})
+
+	It("changes its etag with the sequence", func() {
+		etag := r.ETag()
+		Expect(etag).To(MatchRegexp(`^"[0-9a-f]+-0"$`))
+
+		r.Register("app.example.com", endpoint("10.0.0.1", nil))
+		Expect(r.ETag()).NotTo(Equal(etag))
+		Expect(r.ETag()).To(HaveSuffix(`-1"`))
+	})
+})
@@ -0,0 +1,76 @@
+package registry
+
+import "code.cloudfoundry.org/gorouter/route"
+
+// Resolutions of a registration for a route holding endpoints of other
+// sources
+const (
+	SourceConflictMerged   = "merged"
+	SourceConflictRejected = "rejected"
+	SourceConflictReplaced = "replaced"
+)
+
+// sourcePrecedence ranks the sources of endpoints, the first ranked 0. An
+// empty precedence merges the endpoints of every source.
+type sourcePrecedence map[string]int
+
+func newSourcePrecedence(sources []string) sourcePrecedence {
+	p := make(sourcePrecedence, len(sources))
+	for i, source := range sources {
+		if _, ok := p[source]; !ok {
+			p[source] = i
+		}
+	}
+	return p
+}
+
+// rank returns the rank of source, unlisted sources ranking last
+func (p sourcePrecedence) rank(source string) int {
+	if rank, ok := p[source]; ok {
+		return rank
+	}
+	return len(p)
+}
+
+// resolve decides whether endpoint joins a pool holding endpoints of other
+// sources, removing those it outranks. It returns the resolution, or "" when
+// the pool holds no other source.
+func (p sourcePrecedence) resolve(pool *route.Pool, endpoint *route.Endpoint) string {
+	rank := p.rank(endpoint.Source)
+	conflict := false
+	outranked := map[string]struct{}{}
+	for _, source := range pool.Sources() {
+		if source == endpoint.Source {
+			continue
+		}
+		conflict = true
+		if len(p) == 0 {
+			continue
+		}
+
+		switch other := p.rank(source); {
+		case other < rank:
+			return SourceConflictRejected
+		case other > rank:
+			outranked[source] = struct{}{}
+		}
+	}
+
+	if !conflict {
+		return ""
+	}
+	if len(outranked) == 0 {
+		return SourceConflictMerged
+	}
+
+	var removed []*route.Endpoint
+	pool.Each(func(e *route.Endpoint) {
+		if _, ok := outranked[e.Source]; ok {
+			removed = append(removed, e)
+		}
+	})
+	for _, e := range removed {
+		pool.Remove(e)
+	}
+	return SourceConflictReplaced
+}
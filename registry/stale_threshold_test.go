@@ -0,0 +1,63 @@
+package registry_test
+
+import (
+	"time"
+
+	"code.cloudfoundry.org/gorouter/config"
+	"code.cloudfoundry.org/gorouter/metrics/fakes"
+	. "code.cloudfoundry.org/gorouter/registry"
+	"code.cloudfoundry.org/gorouter/route"
+	"code.cloudfoundry.org/gorouter/test_util"
+	"code.cloudfoundry.org/routing-api/models"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Stale threshold classes", func() {
+	var (
+		r         *RouteRegistry
+		configObj *config.Config
+	)
+
+	endpoint := func(host string, tags map[string]string) *route.Endpoint {
+		return route.NewEndpoint("app", host, 8080, "", "", tags, -1, "", models.ModificationTag{}, "")
+	}
+
+	BeforeEach(func() {
+		configObj = config.DefaultConfig()
+		configObj.PruneStaleDropletsInterval = 50 * time.Millisecond
+		configObj.DropletStaleThreshold = 24 * time.Millisecond
+		configObj.StaleThresholdClasses = []config.StaleThresholdClass{
+			{Name: "system", Domain: "sys.example.com", Threshold: time.Hour},
+			{Name: "uaa", Tags: map[string]string{"component": "uaa"}, Threshold: time.Hour},
+		}
+	})
+
+	JustBeforeEach(func() {
+		r = NewRouteRegistry(test_util.NewTestZapLogger("test"), configObj, new(fakes.FakeRouteRegistryReporter))
+	})
+
+	AfterEach(func() {
+		r.StopPruningCycle()
+	})
+
+	It("prunes the endpoints of each class by its threshold", func() {
+		r.Register("api.sys.example.com/v2", endpoint("10.0.0.1", nil))
+		r.Register("login.example.com", endpoint("10.0.0.2", map[string]string{"component": "uaa"}))
+		r.Register("app.example.com", endpoint("10.0.0.3", nil))
+		r.StartPruningCycle()
+
+		Eventually(func() *route.Pool { return r.Lookup("app.example.com") }).Should(BeNil())
+		Expect(r.Lookup("api.sys.example.com/v2")).NotTo(BeNil())
+		Expect(r.Lookup("login.example.com")).NotTo(BeNil())
+	})
+
+	It("reports the endpoints of a class as fresh within its threshold", func() {
+		r.Register("api.sys.example.com", endpoint("10.0.0.1", nil))
+		time.Sleep(50 * time.Millisecond)
+
+		health, ok := r.HostHealth("api.sys.example.com")
+		Expect(ok).To(BeTrue())
+		Expect(health.Routable).To(BeTrue())
+	})
+})
@@ -0,0 +1,70 @@
+package replay
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"time"
+)
+
+// Main implements `gorouter replay [flags] [access-log-file ...]`. It reads
+// standard input when no files are given and returns the exit code.
+func Main(args []string, stdin io.Reader, stdout, stderr io.Writer) int {
+	flags := flag.NewFlagSet("replay", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+
+	var opts Options
+	flags.StringVar(&opts.Target, "target", "127.0.0.1:80", "Address (host:port) of the router to replay requests through")
+	flags.Float64Var(&opts.Rate, "rate", 10, "Requests per second; 0 replays as fast as possible")
+	flags.IntVar(&opts.Concurrency, "concurrency", 10, "Number of requests in flight at once")
+	flags.IntVar(&opts.Limit, "limit", 0, "Stop after this many requests; 0 replays the whole log")
+	flags.DurationVar(&opts.Timeout, "timeout", 30*time.Second, "Timeout for each request")
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+
+	replayer, err := NewReplayer(opts)
+	if err != nil {
+		fmt.Fprintln(stderr, err)
+		return 2
+	}
+
+	var src io.Reader = stdin
+	if flags.NArg() > 0 {
+		readers := make([]io.Reader, 0, flags.NArg())
+		for _, path := range flags.Args() {
+			f, err := os.Open(path)
+			if err != nil {
+				fmt.Fprintln(stderr, err)
+				return 1
+			}
+			defer f.Close()
+			readers = append(readers, f)
+		}
+		src = io.MultiReader(readers...)
+	}
+
+	result, err := replayer.Run(src)
+	printResult(stdout, result)
+	if err != nil {
+		fmt.Fprintln(stderr, err)
+		return 1
+	}
+	return 0
+}
+
+func printResult(w io.Writer, result Result) {
+	fmt.Fprintf(w, "sent: %d failed: %d skipped: %d duration: %s\n",
+		result.Sent, result.Failed, result.Skipped, result.Duration)
+
+	statuses := make([]int, 0, len(result.Statuses))
+	for status := range result.Statuses {
+		statuses = append(statuses, status)
+	}
+	sort.Ints(statuses)
+	for _, status := range statuses {
+		fmt.Fprintf(w, "  %d: %d\n", status, result.Statuses[status])
+	}
+}
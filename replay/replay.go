@@ -0,0 +1,183 @@
+// Package replay re-issues requests recorded in the JSON access log format
+// through a router, for load regression testing with production-shaped
+// traffic.
+package replay
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"sync"
+	"time"
+
+	"code.cloudfoundry.org/gorouter/access_log/schema"
+)
+
+// ReplayHeader is set on every replayed request so backends and access logs
+// can tell it apart from live traffic
+const ReplayHeader = "X-Gorouter-Replay"
+
+// maxLineSize bounds a single access log line
+const maxLineSize = 1024 * 1024
+
+// Options control how requests are replayed
+type Options struct {
+	// Target is the host:port of the router receiving the requests
+	Target string
+	// Rate is the number of requests started per second. Zero replays as
+	// fast as the workers allow.
+	Rate float64
+	// Concurrency is the number of requests in flight at once
+	Concurrency int
+	// Limit stops the replay after this many requests when positive
+	Limit int
+	// Timeout bounds each request
+	Timeout time.Duration
+}
+
+// Result summarizes a replay
+type Result struct {
+	Sent     int
+	Failed   int
+	Skipped  int
+	Statuses map[int]int
+	Duration time.Duration
+}
+
+// Replayer issues the requests read from an access log against a router
+type Replayer struct {
+	opts   Options
+	client *http.Client
+}
+
+// NewReplayer returns a Replayer for opts
+func NewReplayer(opts Options) (*Replayer, error) {
+	if opts.Target == "" {
+		return nil, errors.New("replay target is required")
+	}
+	if opts.Rate < 0 {
+		return nil, errors.New("replay rate must not be negative")
+	}
+	if opts.Concurrency <= 0 {
+		opts.Concurrency = 1
+	}
+
+	return &Replayer{
+		opts: opts,
+		client: &http.Client{
+			Timeout: opts.Timeout,
+			Transport: &http.Transport{
+				MaxIdleConnsPerHost: opts.Concurrency,
+			},
+			// Redirects are part of the recorded traffic, not followed
+			CheckRedirect: func(*http.Request, []*http.Request) error {
+				return http.ErrUseLastResponse
+			},
+		},
+	}, nil
+}
+
+// NewRequest rebuilds the request described by an access log entry, addressed
+// to target with the original Host header
+func NewRequest(fields schema.AccessLogFields, target string) (*http.Request, error) {
+	req, err := http.NewRequest(fields.Method, "http://"+target+fields.URI, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Host = fields.Host
+
+	if fields.UserAgent != "" {
+		req.Header.Set("User-Agent", fields.UserAgent)
+	}
+	if fields.Referer != "" {
+		req.Header.Set("Referer", fields.Referer)
+	}
+	if fields.XForwardedProto != "" {
+		req.Header.Set("X-Forwarded-Proto", fields.XForwardedProto)
+	}
+	if fields.Range != "" {
+		req.Header.Set("Range", fields.Range)
+	}
+	req.Header.Set(ReplayHeader, "true")
+
+	return req, nil
+}
+
+// Run replays every request read from src and returns once they have all
+// completed. Lines that are not JSON access log entries are skipped.
+func (r *Replayer) Run(src io.Reader) (Result, error) {
+	result := Result{Statuses: make(map[int]int)}
+	var lock sync.Mutex
+
+	requests := make(chan *http.Request)
+	var wg sync.WaitGroup
+	for i := 0; i < r.opts.Concurrency; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for req := range requests {
+				status, err := r.do(req)
+
+				lock.Lock()
+				if err != nil {
+					result.Failed++
+				} else {
+					result.Statuses[status]++
+				}
+				lock.Unlock()
+			}
+		}()
+	}
+
+	var tick <-chan time.Time
+	if r.opts.Rate > 0 {
+		ticker := time.NewTicker(time.Duration(float64(time.Second) / r.opts.Rate))
+		defer ticker.Stop()
+		tick = ticker.C
+	}
+
+	start := time.Now()
+	scanner := bufio.NewScanner(src)
+	scanner.Buffer(make([]byte, 64*1024), maxLineSize)
+	for scanner.Scan() {
+		if r.opts.Limit > 0 && result.Sent >= r.opts.Limit {
+			break
+		}
+
+		var fields schema.AccessLogFields
+		err := json.Unmarshal(scanner.Bytes(), &fields)
+		if err != nil || fields.Method == "" || fields.URI == "" {
+			result.Skipped++
+			continue
+		}
+		req, err := NewRequest(fields, r.opts.Target)
+		if err != nil {
+			result.Skipped++
+			continue
+		}
+
+		if tick != nil && result.Sent > 0 {
+			<-tick
+		}
+		requests <- req
+		result.Sent++
+	}
+	close(requests)
+	wg.Wait()
+
+	result.Duration = time.Since(start)
+	return result, scanner.Err()
+}
+
+func (r *Replayer) do(req *http.Request) (int, error) {
+	resp, err := r.client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	_, _ = io.Copy(ioutil.Discard, resp.Body)
+	_ = resp.Body.Close()
+	return resp.StatusCode, nil
+}
@@ -0,0 +1,117 @@
+package replay_test
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"time"
+
+	"code.cloudfoundry.org/gorouter/replay"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+const accessLog = `{"host":"app.example.com","method":"GET","uri":"/foo?bar=1","user_agent":"curl","status_code":200}
+foo.example.com - [2017-01-01T00:00:00.000+0000] "GET / HTTP/1.1" 200 0 0 "-" "-"
+{"host":"api.example.com","method":"DELETE","uri":"/v2/apps/1","x_forwarded_proto":"https","status_code":204}
+`
+
+var _ = Describe("Replayer", func() {
+	var (
+		server   *httptest.Server
+		lock     sync.Mutex
+		received []*http.Request
+	)
+
+	BeforeEach(func() {
+		received = nil
+		server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			lock.Lock()
+			received = append(received, r)
+			lock.Unlock()
+			if r.Method == "DELETE" {
+				w.WriteHeader(http.StatusNoContent)
+			}
+		}))
+	})
+
+	AfterEach(func() {
+		server.Close()
+	})
+
+	target := func() string {
+		return strings.TrimPrefix(server.URL, "http://")
+	}
+
+	It("replays the JSON entries through the target", func() {
+		replayer, err := replay.NewReplayer(replay.Options{Target: target()})
+		Expect(err).NotTo(HaveOccurred())
+
+		result, err := replayer.Run(strings.NewReader(accessLog))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(result.Sent).To(Equal(2))
+		Expect(result.Skipped).To(Equal(1))
+		Expect(result.Statuses).To(Equal(map[int]int{200: 1, 204: 1}))
+
+		Expect(received).To(HaveLen(2))
+		Expect(received[0].Host).To(Equal("app.example.com"))
+		Expect(received[0].URL.RequestURI()).To(Equal("/foo?bar=1"))
+		Expect(received[0].Header.Get("User-Agent")).To(Equal("curl"))
+		Expect(received[0].Header.Get(replay.ReplayHeader)).To(Equal("true"))
+		Expect(received[1].Method).To(Equal("DELETE"))
+		Expect(received[1].Header.Get("X-Forwarded-Proto")).To(Equal("https"))
+	})
+
+	It("stops after the limit", func() {
+		replayer, err := replay.NewReplayer(replay.Options{Target: target(), Limit: 1})
+		Expect(err).NotTo(HaveOccurred())
+
+		result, err := replayer.Run(strings.NewReader(accessLog))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(result.Sent).To(Equal(1))
+	})
+
+	It("paces requests at the configured rate", func() {
+		replayer, err := replay.NewReplayer(replay.Options{Target: target(), Rate: 20, Concurrency: 4})
+		Expect(err).NotTo(HaveOccurred())
+
+		log := strings.Repeat(`{"host":"app.example.com","method":"GET","uri":"/"}`+"\n", 5)
+		result, err := replayer.Run(strings.NewReader(log))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(result.Sent).To(Equal(5))
+		Expect(result.Duration).To(BeNumerically(">=", 200*time.Millisecond))
+	})
+
+	It("counts requests that fail", func() {
+		server.Close()
+		replayer, err := replay.NewReplayer(replay.Options{Target: target(), Timeout: time.Second})
+		Expect(err).NotTo(HaveOccurred())
+
+		result, err := replayer.Run(strings.NewReader(accessLog))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(result.Failed).To(Equal(2))
+	})
+
+	It("requires a target", func() {
+		_, err := replay.NewReplayer(replay.Options{})
+		Expect(err).To(HaveOccurred())
+	})
+
+	Describe("Main", func() {
+		It("prints a summary", func() {
+			var stdout, stderr bytes.Buffer
+			code := replay.Main([]string{"-target", target(), "-rate", "0"}, strings.NewReader(accessLog), &stdout, &stderr)
+			Expect(code).To(Equal(0))
+			Expect(stdout.String()).To(ContainSubstring("sent: 2 failed: 0 skipped: 1"))
+			Expect(stdout.String()).To(ContainSubstring("204: 1"))
+		})
+
+		It("fails on unknown flags", func() {
+			var stdout, stderr bytes.Buffer
+			Expect(replay.Main([]string{"-bogus"}, strings.NewReader(""), &stdout, &stderr)).To(Equal(2))
+		})
+	})
+})
@@ -0,0 +1,33 @@
+// Package reqctx declares the keys under which the router stores values on a
+// request context. A key is a pointer, so it only matches itself: keys declared
+// by different packages, or by middleware outside the router, cannot collide
+// even when they share a name, and a package that keeps its keys unexported
+// is the only one able to read or replace its values. Packages wrap their keys
+// in typed accessors rather than exposing them.
+package reqctx
+
+import "context"
+
+// Key identifies a value stored on a context
+type Key struct {
+	name string
+}
+
+// NewKey returns a key distinct from every other. name only describes it.
+func NewKey(name string) *Key {
+	return &Key{name: name}
+}
+
+// With returns a copy of ctx holding v under k
+func (k *Key) With(ctx context.Context, v interface{}) context.Context {
+	return context.WithValue(ctx, k, v)
+}
+
+// Value returns the value ctx holds under k, or nil
+func (k *Key) Value(ctx context.Context) interface{} {
+	return ctx.Value(k)
+}
+
+func (k *Key) String() string {
+	return "gorouter context key " + k.name
+}
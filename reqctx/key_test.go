@@ -0,0 +1,41 @@
+package reqctx_test
+
+import (
+	"context"
+
+	"code.cloudfoundry.org/gorouter/reqctx"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Key", func() {
+	It("returns the value stored under the key", func() {
+		key := reqctx.NewKey("RequestInfo")
+		ctx := key.With(context.Background(), "info")
+
+		Expect(key.Value(ctx)).To(Equal("info"))
+	})
+
+	It("returns nil when nothing is stored under the key", func() {
+		key := reqctx.NewKey("RequestInfo")
+
+		Expect(key.Value(context.Background())).To(BeNil())
+	})
+
+	It("does not collide with keys of the same name", func() {
+		key := reqctx.NewKey("RequestInfo")
+		other := reqctx.NewKey("RequestInfo")
+		ctx := other.With(key.With(context.Background(), "info"), "other")
+
+		Expect(key.Value(ctx)).To(Equal("info"))
+		Expect(other.Value(ctx)).To(Equal("other"))
+	})
+
+	It("does not collide with string keys", func() {
+		key := reqctx.NewKey("RequestInfo")
+		ctx := context.WithValue(context.Background(), "RequestInfo", "forged")
+
+		Expect(key.Value(ctx)).To(BeNil())
+	})
+})
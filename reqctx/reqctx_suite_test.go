@@ -0,0 +1,13 @@
+package reqctx_test
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"testing"
+)
+
+func TestReqctx(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "Reqctx Suite")
+}
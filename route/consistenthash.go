@@ -0,0 +1,179 @@
+package route
+
+import (
+	"hash/crc32"
+	"sort"
+	"strconv"
+	"time"
+)
+
+type ringPoint struct {
+	hash uint32
+	elem *endpointElem
+}
+
+type hashRing []ringPoint
+
+func (r hashRing) Len() int           { return len(r) }
+func (r hashRing) Less(i, j int) bool { return r[i].hash < r[j].hash }
+func (r hashRing) Swap(i, j int)      { r[i], r[j] = r[j], r[i] }
+
+type ConsistentHash struct {
+	pool     *Pool
+	key      []byte
+	hash     uint32
+	replicas int
+
+	initialEndpoint string
+	lastEndpoint    *Endpoint
+}
+
+// NewConsistentHash returns an iterator that maps key onto a ring of the pool's
+// endpoints, so that requests with the same key keep landing on the same
+// endpoint while the pool membership is unchanged. Requests without a key are
+// balanced round-robin.
+func NewConsistentHash(p *Pool, initial, key string, replicas int) EndpointIterator {
+	if key == "" {
+		return NewRoundRobin(p, initial)
+	}
+	if replicas <= 0 {
+		replicas = 1
+	}
+
+	c := consistentHashes.Get().(*ConsistentHash)
+	c.pool = p
+	// the key is copied into a buffer kept with the pooled iterator, as
+	// converting it would allocate
+	c.key = append(c.key[:0], key...)
+	c.hash = crc32.ChecksumIEEE(c.key)
+	c.replicas = replicas
+	c.initialEndpoint = initial
+	return c
+}
+
+func (c *ConsistentHash) Next() *Endpoint {
+	var e *Endpoint
+	if c.initialEndpoint != "" {
+		e = c.pool.findById(c.initialEndpoint)
+		c.initialEndpoint = ""
+	}
+
+	if e == nil {
+		e = c.next()
+	}
+
+	c.lastEndpoint = e
+
+	return e
+}
+
+func (c *ConsistentHash) next() *Endpoint {
+	c.pool.lock.Lock()
+	defer c.pool.lock.Unlock()
+
+	if len(c.pool.endpoints) == 0 {
+		return nil
+	}
+
+	ring := c.pool.hashRing(c.replicas)
+	start := ring.search(c.hash)
+
+	for attempt := 0; attempt < 2; attempt++ {
+		var draining *endpointElem
+		for i := 0; i < len(ring); i++ {
+			e := ring[(start+i)%len(ring)].elem
+
+			if e.failedAt != nil {
+				if time.Now().Sub(*e.failedAt) > c.pool.retryAfterFailure {
+					// exipired failure window
+					e.failedAt = nil
+				}
+			}
+
+			if e.failedAt == nil {
+				if !e.endpoint.IsDraining() {
+					return e.endpoint
+				}
+				if draining == nil {
+					draining = e
+				}
+			}
+		}
+
+		// only draining endpoints are available, so fall back to them
+		if draining != nil {
+			return draining.endpoint
+		}
+
+		// all endpoints are marked failed so reset everything to available
+		for _, e := range c.pool.endpoints {
+			e.failedAt = nil
+		}
+	}
+
+	return nil
+}
+
+func (c *ConsistentHash) EndpointFailed() {
+	if c.lastEndpoint != nil {
+		c.pool.endpointFailed(c.lastEndpoint)
+	}
+}
+
+func (c *ConsistentHash) PreRequest(e *Endpoint) {
+}
+
+func (c *ConsistentHash) PostRequest(e *Endpoint) {
+}
+
+// search returns the index of the first point at or after hash, or len(r).
+// It is sort.Search without the closure, which may allocate.
+func (r hashRing) search(hash uint32) int {
+	i, j := 0, len(r)
+	for i < j {
+		h := int(uint(i+j) >> 1)
+		if r[h].hash < hash {
+			i = h + 1
+		} else {
+			j = h
+		}
+	}
+	return i
+}
+
+// hashRing must be called with the pool lock held. The ring is cached until
+// the pool membership changes. A view keeps the points of the ring of its
+// parent that are its own.
+func (p *Pool) hashRing(replicas int) hashRing {
+	if p.ring != nil && p.ringReplicas == replicas {
+		return p.ring
+	}
+
+	if p.parent != nil {
+		ring := make(hashRing, 0, len(p.endpoints)*replicas)
+		for _, point := range p.parent.hashRing(replicas) {
+			if p.index[point.elem.endpoint.CanonicalAddr()] == point.elem {
+				ring = append(ring, point)
+			}
+		}
+		p.ring = ring
+		p.ringReplicas = replicas
+		return ring
+	}
+
+	ring := make(hashRing, 0, len(p.endpoints)*replicas)
+	for _, e := range p.endpoints {
+		addr := e.endpoint.CanonicalAddr()
+		for i := 0; i < replicas; i++ {
+			ring = append(ring, ringPoint{
+				hash: crc32.ChecksumIEEE([]byte(addr + "-" + strconv.Itoa(i))),
+				elem: e,
+			})
+		}
+	}
+	sort.Sort(ring)
+
+	p.ring = ring
+	p.ringReplicas = replicas
+	return ring
+}
@@ -0,0 +1,104 @@
+package route_test
+
+import (
+	"fmt"
+	"time"
+
+	"code.cloudfoundry.org/gorouter/route"
+	"code.cloudfoundry.org/routing-api/models"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("ConsistentHash", func() {
+	var pool *route.Pool
+	var modTag models.ModificationTag
+
+	BeforeEach(func() {
+		pool = route.NewPool(2*time.Minute, "")
+		modTag = models.ModificationTag{}
+
+		for i := 0; i < 5; i++ {
+			pool.Put(route.NewEndpoint("", "10.0.0.1", uint16(6000+i), fmt.Sprintf("id-%d", i), "", nil, -1, "", modTag, ""))
+		}
+	})
+
+	Describe("Next", func() {
+		It("returns the same endpoint for the same key", func() {
+			first := route.NewConsistentHash(pool, "", "some-key", 100).Next()
+			Expect(first).ToNot(BeNil())
+
+			for i := 0; i < 10; i++ {
+				Expect(route.NewConsistentHash(pool, "", "some-key", 100).Next()).To(Equal(first))
+			}
+		})
+
+		It("spreads different keys across endpoints", func() {
+			seen := map[string]bool{}
+			for i := 0; i < 100; i++ {
+				e := route.NewConsistentHash(pool, "", fmt.Sprintf("key-%d", i), 100).Next()
+				seen[e.CanonicalAddr()] = true
+			}
+			Expect(len(seen)).To(BeNumerically(">", 1))
+		})
+
+		It("only remaps keys owned by a removed endpoint", func() {
+			before := map[string]string{}
+			for i := 0; i < 100; i++ {
+				key := fmt.Sprintf("key-%d", i)
+				before[key] = route.NewConsistentHash(pool, "", key, 100).Next().CanonicalAddr()
+			}
+
+			removed := route.NewEndpoint("", "10.0.0.1", 6002, "id-2", "", nil, -1, "", modTag, "")
+			Expect(pool.Remove(removed)).To(BeTrue())
+
+			for key, addr := range before {
+				e := route.NewConsistentHash(pool, "", key, 100).Next()
+				if addr != removed.CanonicalAddr() {
+					Expect(e.CanonicalAddr()).To(Equal(addr))
+				} else {
+					Expect(e.CanonicalAddr()).ToNot(Equal(addr))
+				}
+			}
+		})
+
+		It("prefers the initial endpoint", func() {
+			for i := 0; i < 10; i++ {
+				e := route.NewConsistentHash(pool, "id-3", fmt.Sprintf("key-%d", i), 100).Next()
+				Expect(e.PrivateInstanceId).To(Equal("id-3"))
+			}
+		})
+
+		It("moves to another endpoint after a failure", func() {
+			iter := route.NewConsistentHash(pool, "", "some-key", 100)
+			first := iter.Next()
+			iter.EndpointFailed()
+
+			second := iter.Next()
+			Expect(second).ToNot(BeNil())
+			Expect(second).ToNot(Equal(first))
+		})
+
+		It("skips draining endpoints", func() {
+			first := route.NewConsistentHash(pool, "", "some-key", 100).Next()
+			first.SetDraining(true)
+
+			second := route.NewConsistentHash(pool, "", "some-key", 100).Next()
+			Expect(second).ToNot(BeNil())
+			Expect(second).ToNot(Equal(first))
+
+			pool.Each(func(e *route.Endpoint) { e.SetDraining(true) })
+			Expect(route.NewConsistentHash(pool, "", "some-key", 100).Next()).To(Equal(first))
+		})
+
+		It("returns nil when no endpoints exist", func() {
+			iter := route.NewConsistentHash(route.NewPool(2*time.Minute, ""), "", "some-key", 100)
+			Expect(iter.Next()).To(BeNil())
+		})
+
+		It("balances round-robin without a key", func() {
+			iter := route.NewConsistentHash(pool, "", "", 100)
+			Expect(iter).To(BeAssignableToTypeOf(&route.RoundRobin{}))
+		})
+	})
+})
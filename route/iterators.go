@@ -0,0 +1,27 @@
+package route
+
+import "sync"
+
+// The iterators are pooled, as one is taken for every proxied request
+var (
+	roundRobins      = sync.Pool{New: func() interface{} { return new(RoundRobin) }}
+	leastConnections = sync.Pool{New: func() interface{} { return new(LeastConnection) }}
+	consistentHashes = sync.Pool{New: func() interface{} { return new(ConsistentHash) }}
+)
+
+// ReleaseIterator returns an iterator of this package to its pool once the
+// request it served is done with it. The iterator must not be used
+// afterwards. Other iterators are left alone.
+func ReleaseIterator(iter EndpointIterator) {
+	switch i := iter.(type) {
+	case *RoundRobin:
+		*i = RoundRobin{}
+		roundRobins.Put(i)
+	case *LeastConnection:
+		*i = LeastConnection{}
+		leastConnections.Put(i)
+	case *ConsistentHash:
+		*i = ConsistentHash{key: i.key[:0]}
+		consistentHashes.Put(i)
+	}
+}
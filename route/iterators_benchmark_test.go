@@ -0,0 +1,60 @@
+package route_test
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"code.cloudfoundry.org/gorouter/route"
+	"code.cloudfoundry.org/routing-api/models"
+)
+
+// newBenchmarkPool returns a pool of total endpoints
+func newBenchmarkPool(total int) *route.Pool {
+	pool := route.NewPool(2*time.Minute, "")
+	for i := 0; i < total; i++ {
+		ip := fmt.Sprintf("10.0.1.%d", i)
+		pool.Put(route.NewEndpoint("", ip, 60000, fmt.Sprintf("id-%d", i), "", nil, -1, "", models.ModificationTag{}, ""))
+	}
+	return pool
+}
+
+// iterateFor takes, uses and releases an iterator per operation, the way a
+// proxied request does, reporting the allocations of each
+func iterateFor(b *testing.B, newIterator func(pool *route.Pool) route.EndpointIterator) {
+	pool := newBenchmarkPool(20)
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			iter := newIterator(pool)
+			loadBalance(iter)
+			route.ReleaseIterator(iter)
+		}
+	})
+}
+
+func BenchmarkRoundRobinPerRequest(b *testing.B) {
+	iterateFor(b, func(pool *route.Pool) route.EndpointIterator {
+		return route.NewRoundRobin(pool, "")
+	})
+}
+
+func BenchmarkLeastConnectionPerRequest(b *testing.B) {
+	iterateFor(b, func(pool *route.Pool) route.EndpointIterator {
+		return route.NewLeastConnection(pool, "")
+	})
+}
+
+func BenchmarkConsistentHashPerRequest(b *testing.B) {
+	iterateFor(b, func(pool *route.Pool) route.EndpointIterator {
+		return route.NewConsistentHash(pool, "", "some-key", 100)
+	})
+}
+
+func BenchmarkStickyPerRequest(b *testing.B) {
+	iterateFor(b, func(pool *route.Pool) route.EndpointIterator {
+		return route.NewRoundRobin(pool, "id-7")
+	})
+}
@@ -0,0 +1,59 @@
+package route_test
+
+import (
+	"time"
+
+	"code.cloudfoundry.org/gorouter/route"
+	"code.cloudfoundry.org/routing-api/models"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("ReleaseIterator", func() {
+	var (
+		pool   *route.Pool
+		e1, e2 *route.Endpoint
+	)
+
+	BeforeEach(func() {
+		pool = route.NewPool(2*time.Minute, "")
+		e1 = route.NewEndpoint("", "1.2.3.4", 5678, "e1", "", nil, -1, "", models.ModificationTag{}, "")
+		e2 = route.NewEndpoint("", "5.6.7.8", 1234, "e2", "", nil, -1, "", models.ModificationTag{}, "")
+		pool.Put(e1)
+		pool.Put(e2)
+	})
+
+	It("does not carry state over to the next iterator", func() {
+		for i := 0; i < 10; i++ {
+			iter := route.NewRoundRobin(pool, "e1")
+			Expect(iter.Next()).To(Equal(e1))
+			route.ReleaseIterator(iter)
+
+			iter = route.NewLeastConnection(pool, "")
+			// nothing was selected, so nothing fails
+			iter.EndpointFailed()
+			route.ReleaseIterator(iter)
+		}
+
+		iter := route.NewRoundRobin(pool, "")
+		seen := map[*route.Endpoint]bool{iter.Next(): true, iter.Next(): true}
+		Expect(seen).To(HaveLen(2))
+	})
+
+	It("keeps the iterators balancing after reuse", func() {
+		e1.Stats.NumberConnections.Increment()
+		for i := 0; i < 10; i++ {
+			iter := route.NewLeastConnection(pool, "")
+			Expect(iter.Next()).To(Equal(e2))
+			route.ReleaseIterator(iter)
+
+			iter = route.NewConsistentHash(pool, "", "some-key", 10)
+			Expect(iter.Next()).NotTo(BeNil())
+			route.ReleaseIterator(iter)
+		}
+	})
+
+	It("ignores other iterators", func() {
+		route.ReleaseIterator(nil)
+	})
+})
@@ -14,10 +14,10 @@ type LeastConnection struct {
 }
 
 func NewLeastConnection(p *Pool, initial string) EndpointIterator {
-	return &LeastConnection{
-		pool:            p,
-		initialEndpoint: initial,
-	}
+	r := leastConnections.Get().(*LeastConnection)
+	r.pool = p
+	r.initialEndpoint = initial
+	return r
 }
 
 func (r *LeastConnection) Next() *Endpoint {
@@ -47,8 +47,6 @@ func (r *LeastConnection) next() *Endpoint {
 	r.pool.lock.Lock()
 	defer r.pool.lock.Unlock()
 
-	var selected *Endpoint
-
 	// none
 	total := len(r.pool.endpoints)
 	if total == 0 {
@@ -63,20 +61,35 @@ func (r *LeastConnection) next() *Endpoint {
 	// more than 1 endpoint
 	// select the least connection endpoint OR
 	// random one within the least connection endpoints
-	randIndices := randomize.Perm(total)
-
-	for i := 0; i < total; i++ {
-		randIdx := randIndices[i]
-		cur := r.pool.endpoints[randIdx].endpoint
+	// draining endpoints are only selected when every endpoint is draining
+	if selected := r.least(true); selected != nil {
+		return selected
+	}
+	return r.least(false)
+}
 
-		// our first is the least
-		if i == 0 {
-			selected = cur
+// least returns the endpoint with the fewest connections, picking uniformly
+// among ties by reservoir sampling rather than shuffling, which would
+// allocate on every request. It must be called with the pool lock held.
+func (r *LeastConnection) least(skipDraining bool) *Endpoint {
+	var selected *Endpoint
+	var fewest int64
+	ties := 0
+	for _, e := range r.pool.endpoints {
+		cur := e.endpoint
+		if skipDraining && cur.IsDraining() {
 			continue
 		}
 
-		if cur.Stats.NumberConnections.Count() < selected.Stats.NumberConnections.Count() {
-			selected = cur
+		count := cur.Stats.NumberConnections.Count()
+		switch {
+		case selected == nil || count < fewest:
+			selected, fewest, ties = cur, count, 1
+		case count == fewest:
+			ties++
+			if randomize.Intn(ties) == 0 {
+				selected = cur
+			}
 		}
 	}
 	return selected
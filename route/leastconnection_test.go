@@ -122,6 +122,26 @@ var _ = Describe("LeastConnection", func() {
 					Expect(okRandoms).Should(ContainElement(iter.Next().CanonicalAddr()))
 				})
 			})
+
+			Context("when endpoints are draining", func() {
+				It("selects the active endpoint with least connection", func() {
+					iter := route.NewLeastConnection(pool, "")
+
+					setConnectionCount(endpoints, []int{0, 3, 15, 2, 7})
+					endpoints[0].SetDraining(true)
+					Expect(iter.Next()).To(Equal(endpoints[3]))
+				})
+
+				It("selects a draining endpoint when all are draining", func() {
+					iter := route.NewLeastConnection(pool, "")
+
+					setConnectionCount(endpoints, []int{5, 3, 15, 2, 7})
+					for _, e := range endpoints {
+						e.SetDraining(true)
+					}
+					Expect(iter.Next()).To(Equal(endpoints[3]))
+				})
+			})
 		})
 	})
 })
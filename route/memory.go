@@ -0,0 +1,49 @@
+package route
+
+import "unsafe"
+
+// MapEntryOverhead approximates the bookkeeping a Go map spends per entry on
+// top of the key and value themselves
+const MapEntryOverhead = 16
+
+var (
+	poolSize          = int64(unsafe.Sizeof(Pool{}))
+	endpointElemSize  = int64(unsafe.Sizeof(endpointElem{}))
+	endpointSize      = int64(unsafe.Sizeof(Endpoint{}))
+	statsSize         = int64(unsafe.Sizeof(Stats{}) + unsafe.Sizeof(Counter{}))
+	pointerSize       = int64(unsafe.Sizeof(uintptr(0)))
+	stringHeaderSize  = int64(unsafe.Sizeof(""))
+	indexEntrySize    = stringHeaderSize + pointerSize + MapEntryOverhead
+	tagEntryFixedSize = 2*stringHeaderSize + MapEntryOverhead
+)
+
+// ApproximateSize estimates the bytes held by the pool itself, excluding the
+// endpoints it references
+func (p *Pool) ApproximateSize() int64 {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	size := poolSize + int64(len(p.contextPath)+len(p.routeServiceUrl))
+	size += int64(cap(p.endpoints)) * pointerSize
+	size += int64(len(p.endpoints)) * endpointElemSize
+	for key := range p.index {
+		size += indexEntrySize + int64(len(key))
+	}
+	size += int64(len(p.ring)) * int64(unsafe.Sizeof(ringPoint{}))
+	size += int64(cap(p.observers)) * 2 * pointerSize
+
+	return size
+}
+
+// ApproximateSize estimates the bytes held by the endpoint
+func (e *Endpoint) ApproximateSize() int64 {
+	size := endpointSize + statsSize
+	size += int64(len(e.ApplicationId) + len(e.addr) + len(e.PrivateInstanceId) +
+		len(e.RouteServiceUrl) + len(e.PrivateInstanceIndex) + len(e.IsolationSegment) +
+		len(e.ModificationTag.Guid))
+	for k, v := range e.Tags {
+		size += tagEntryFixedSize + int64(len(k)+len(v))
+	}
+
+	return size
+}
@@ -3,6 +3,7 @@ package route
 import (
 	"encoding/json"
 	"fmt"
+	"net/url"
 	"sync"
 	"sync/atomic"
 	"time"
@@ -33,6 +34,13 @@ func (c *Counter) Count() int64 {
 
 type Stats struct {
 	NumberConnections *Counter
+
+	// lastSeen and lastSuccess are when the endpoint was last registered and
+	// last answered a request, in Unix nanoseconds. failures counts the
+	// requests the endpoint could not be sent or failed to answer.
+	lastSeen    int64
+	lastSuccess int64
+	failures    int64
 }
 
 func NewStats() *Stats {
@@ -41,6 +49,74 @@ func NewStats() *Stats {
 	}
 }
 
+// Seen records that the endpoint was registered at t
+func (s *Stats) Seen(t time.Time) {
+	atomic.StoreInt64(&s.lastSeen, t.UnixNano())
+}
+
+// LastSeen returns when the endpoint was last registered, or the zero time if
+// it was never added to a pool
+func (s *Stats) LastSeen() time.Time {
+	return unixNanoTime(atomic.LoadInt64(&s.lastSeen))
+}
+
+// Succeeded records that the endpoint answered a request at t
+func (s *Stats) Succeeded(t time.Time) {
+	atomic.StoreInt64(&s.lastSuccess, t.UnixNano())
+}
+
+// LastSuccess returns when the endpoint last answered a request, or the zero
+// time if it never did
+func (s *Stats) LastSuccess() time.Time {
+	return unixNanoTime(atomic.LoadInt64(&s.lastSuccess))
+}
+
+// Failed records a request the endpoint could not be sent or failed to answer
+func (s *Stats) Failed() {
+	atomic.AddInt64(&s.failures, 1)
+}
+
+// Failures returns the number of requests the endpoint could not be sent or
+// failed to answer
+func (s *Stats) Failures() int64 {
+	return atomic.LoadInt64(&s.failures)
+}
+
+// inherit carries the history of the endpoint registered at the same address
+// before over to s. Each registration creates a new endpoint, so without it
+// every heartbeat would forget when the backend last answered.
+func (s *Stats) inherit(previous *Stats) {
+	storeMax(&s.lastSeen, atomic.LoadInt64(&previous.lastSeen))
+	storeMax(&s.lastSuccess, atomic.LoadInt64(&previous.lastSuccess))
+	storeMax(&s.failures, atomic.LoadInt64(&previous.failures))
+}
+
+func storeMax(addr *int64, value int64) {
+	for {
+		current := atomic.LoadInt64(addr)
+		if current >= value || atomic.CompareAndSwapInt64(addr, current, value) {
+			return
+		}
+	}
+}
+
+func unixNanoTime(nanos int64) time.Time {
+	if nanos == 0 {
+		return time.Time{}
+	}
+	return time.Unix(0, nanos).UTC()
+}
+
+// EndpointActivity is when an endpoint was last registered and last answered
+// a request, telling an endpoint that died from one that stopped
+// re-registering
+type EndpointActivity struct {
+	Address       string     `json:"address"`
+	LastSeen      *time.Time `json:"last_seen,omitempty"`
+	LastSucceeded *time.Time `json:"last_succeeded,omitempty"`
+	Failures      int64      `json:"failures"`
+}
+
 type Endpoint struct {
 	ApplicationId        string
 	addr                 string
@@ -52,6 +128,38 @@ type Endpoint struct {
 	ModificationTag      models.ModificationTag
 	Stats                *Stats
 	IsolationSegment     string
+	// ErrorPage names a configured page, and ErrorPageHTML carries a page
+	// inline, served when no endpoint of the route can handle a request
+	ErrorPage     string
+	ErrorPageHTML string
+	// AllowedMethods restricts the route to these methods when not empty
+	AllowedMethods []string
+	// CORS is the route's CORS policy, when it registered one
+	CORS *config.CORSPolicy
+	// SecurityHeaders overrides the configured security headers for the route
+	SecurityHeaders *config.SecurityHeaders
+	// Visibility is the scope the route was registered with, external when
+	// empty
+	Visibility string
+	// BandwidthLimit overrides the configured bandwidth limit for the route
+	BandwidthLimit *config.BandwidthLimit
+	// PathRewrite rewrites the route's path prefix in forwarded requests
+	PathRewrite *config.PathRewrite
+	// QueryMatch restricts the endpoint to the requests of its route carrying
+	// all of these query parameter values
+	QueryMatch map[string]string
+	// Source is where the endpoint was registered from, such as nats or
+	// routing_api
+	Source string
+	// RouteServiceOnly marks an endpoint standing for a route service that
+	// answers the route's requests itself, with no backend behind it
+	RouteServiceOnly bool
+	// UpstreamProxy names the configured proxy the endpoint is dialed through
+	UpstreamProxy string
+
+	// draining is set atomically, as it is flipped on endpoints already
+	// handed out to iterators
+	draining int32
 }
 
 //go:generate counterfeiter -o fakes/fake_endpoint_iterator.go . EndpointIterator
@@ -62,23 +170,58 @@ type EndpointIterator interface {
 	PostRequest(e *Endpoint)
 }
 
+// PoolObserver is notified when endpoints join or leave a Pool. Notifications
+// are delivered after the pool lock has been released, so observers may call
+// back into the pool. Replacing an endpoint registered at the same address is
+// reported as a removal of the old endpoint followed by an addition of the new
+// one.
+type PoolObserver interface {
+	OnEndpointAdded(endpoint *Endpoint)
+	OnEndpointRemoved(endpoint *Endpoint)
+}
+
 type endpointElem struct {
 	endpoint *Endpoint
 	index    int
 	updated  time.Time
 	failedAt *time.Time
+	// quarantinedAt is when the endpoint was taken out of the rotation for
+	// being stale
+	quarantinedAt time.Time
 }
 
 type Pool struct {
-	lock      sync.Mutex
+	// lock is shared with the views of the pool
+	lock      *sync.Mutex
 	endpoints []*endpointElem
 	index     map[string]*endpointElem
+	// quarantine holds the stale endpoints waiting to be revived or removed,
+	// by address
+	quarantine map[string]*endpointElem
+	// generation counts the changes of the endpoints as MarshalJSON reports
+	// them, which refreshing an endpoint is not
+	generation uint64
 
 	contextPath     string
 	routeServiceUrl string
 
 	retryAfterFailure time.Duration
 	nextIdx           int
+
+	ring         hashRing
+	ringReplicas int
+
+	// parent is the pool a view was taken from
+	parent *Pool
+
+	// sources counts the endpoints registered from each source
+	sources map[string]int
+	// queryMatchers counts the endpoints registering a QueryMatch
+	queryMatchers int
+	// routeServiceOnly counts the RouteServiceOnly endpoints
+	routeServiceOnly int
+
+	observers []PoolObserver
 }
 
 func NewEndpoint(
@@ -109,8 +252,10 @@ func NewEndpoint(
 
 func NewPool(retryAfterFailure time.Duration, contextPath string) *Pool {
 	return &Pool{
+		lock:              new(sync.Mutex),
 		endpoints:         make([]*endpointElem, 0, 1),
 		index:             make(map[string]*endpointElem),
+		sources:           make(map[string]int),
 		retryAfterFailure: retryAfterFailure,
 		nextIdx:           -1,
 		contextPath:       contextPath,
@@ -123,39 +268,134 @@ func (p *Pool) ContextPath() string {
 
 // Returns true if endpoint was added or updated, false otherwise
 func (p *Pool) Put(endpoint *Endpoint) bool {
+	updated, _ := p.PutEndpoint(endpoint, false)
+	return updated
+}
+
+// PutEndpoint adds or updates endpoint. stale reports whether the endpoint
+// stored at the same address carries a newer ModificationTag; such an update
+// is only applied when overwriteStale is set.
+func (p *Pool) PutEndpoint(endpoint *Endpoint, overwriteStale bool) (updated bool, stale bool) {
+	var added, removed *Endpoint
+
 	p.lock.Lock()
-	defer p.lock.Unlock()
 
 	e, found := p.index[endpoint.CanonicalAddr()]
 	if found {
 		if e.endpoint != endpoint {
 			if !e.endpoint.ModificationTag.SucceededBy(&endpoint.ModificationTag) {
-				return false
+				stale = e.endpoint.ModificationTag != endpoint.ModificationTag
+				if !stale || !overwriteStale {
+					p.lock.Unlock()
+					return false, stale
+				}
 			}
 
 			oldEndpoint := e.endpoint
+			if endpoint.Stats != nil && oldEndpoint.Stats != nil {
+				endpoint.Stats.inherit(oldEndpoint.Stats)
+			}
+			if !oldEndpoint.reportedAs(endpoint) {
+				p.generation++
+			}
 			e.endpoint = endpoint
+			p.countSource(oldEndpoint.Source, -1)
+			p.countSource(endpoint.Source, 1)
+			p.countQueryMatcher(oldEndpoint, -1)
+			p.countQueryMatcher(endpoint, 1)
+			p.countRouteServiceOnly(oldEndpoint, -1)
+			p.countRouteServiceOnly(endpoint, 1)
 
 			if oldEndpoint.PrivateInstanceId != endpoint.PrivateInstanceId {
 				delete(p.index, oldEndpoint.PrivateInstanceId)
 				p.index[endpoint.PrivateInstanceId] = e
 			}
+
+			removed, added = oldEndpoint, endpoint
 		}
 	} else {
-		e = &endpointElem{
-			endpoint: endpoint,
-			index:    len(p.endpoints),
+		e = p.quarantine[endpoint.CanonicalAddr()]
+		if e != nil {
+			delete(p.quarantine, endpoint.CanonicalAddr())
+			if endpoint.Stats != nil && e.endpoint.Stats != nil && e.endpoint != endpoint {
+				endpoint.Stats.inherit(e.endpoint.Stats)
+			}
+			e.endpoint = endpoint
+			e.index = len(p.endpoints)
+			e.failedAt = nil
+			e.quarantinedAt = time.Time{}
+		} else {
+			e = &endpointElem{
+				endpoint: endpoint,
+				index:    len(p.endpoints),
+			}
 		}
 
 		p.endpoints = append(p.endpoints, e)
+		p.generation++
 
 		p.index[endpoint.CanonicalAddr()] = e
 		p.index[endpoint.PrivateInstanceId] = e
+		p.ring = nil
+		p.countSource(endpoint.Source, 1)
+		p.countQueryMatcher(endpoint, 1)
+		p.countRouteServiceOnly(endpoint, 1)
+
+		added = endpoint
 	}
 
 	e.updated = time.Now()
+	if endpoint.Stats != nil {
+		endpoint.Stats.Seen(e.updated)
+	}
 
-	return true
+	observers := p.observers
+	p.lock.Unlock()
+
+	if removed != nil {
+		notifyRemoved(observers, removed)
+	}
+	if added != nil {
+		notifyAdded(observers, added)
+	}
+
+	return true, stale
+}
+
+// Subscribe registers an observer for endpoint changes. Endpoints already in
+// the pool are not replayed; use Each to seed any derived state.
+func (p *Pool) Subscribe(o PoolObserver) {
+	p.lock.Lock()
+	observers := make([]PoolObserver, len(p.observers), len(p.observers)+1)
+	copy(observers, p.observers)
+	p.observers = append(observers, o)
+	p.lock.Unlock()
+}
+
+func (p *Pool) Unsubscribe(o PoolObserver) {
+	p.lock.Lock()
+	observers := make([]PoolObserver, 0, len(p.observers))
+	for _, existing := range p.observers {
+		if existing != o {
+			observers = append(observers, existing)
+		}
+	}
+	p.observers = observers
+	p.lock.Unlock()
+}
+
+func notifyAdded(observers []PoolObserver, endpoint *Endpoint) {
+	for _, o := range observers {
+		o.OnEndpointAdded(endpoint)
+	}
+}
+
+func notifyRemoved(observers []PoolObserver, endpoints ...*Endpoint) {
+	for _, o := range observers {
+		for _, e := range endpoints {
+			o.OnEndpointRemoved(e)
+		}
+	}
 }
 
 func (p *Pool) RouteServiceUrl() string {
@@ -170,7 +410,24 @@ func (p *Pool) RouteServiceUrl() string {
 	}
 }
 
+// StaleThreshold returns the threshold past which an endpoint is stale unless
+// it registered a shorter one, so that classes of endpoints can be given their
+// own
+type StaleThreshold func(endpoint *Endpoint) time.Duration
+
+// FixedStaleThreshold gives every endpoint the same stale threshold
+func FixedStaleThreshold(threshold time.Duration) StaleThreshold {
+	return func(*Endpoint) time.Duration {
+		return threshold
+	}
+}
+
 func (p *Pool) PruneEndpoints(defaultThreshold time.Duration) []*Endpoint {
+	return p.PruneStaleEndpoints(FixedStaleThreshold(defaultThreshold))
+}
+
+// PruneStaleEndpoints removes the endpoints that are stale by their threshold
+func (p *Pool) PruneStaleEndpoints(threshold StaleThreshold) []*Endpoint {
 	p.lock.Lock()
 
 	last := len(p.endpoints)
@@ -181,12 +438,7 @@ func (p *Pool) PruneEndpoints(defaultThreshold time.Duration) []*Endpoint {
 	for i := 0; i < last; {
 		e := p.endpoints[i]
 
-		staleTime := now.Add(-defaultThreshold)
-		if e.endpoint.staleThreshold > 0 && e.endpoint.staleThreshold < defaultThreshold {
-			staleTime = now.Add(-e.endpoint.staleThreshold)
-		}
-
-		if e.updated.Before(staleTime) {
+		if e.isStale(now, threshold(e.endpoint)) {
 			p.removeEndpoint(e)
 			prunedEndpoints = append(prunedEndpoints, e.endpoint)
 			last--
@@ -195,24 +447,88 @@ func (p *Pool) PruneEndpoints(defaultThreshold time.Duration) []*Endpoint {
 		}
 	}
 
+	observers := p.observers
 	p.lock.Unlock()
+
+	notifyRemoved(observers, prunedEndpoints...)
 	return prunedEndpoints
 }
 
+// QuarantineEndpoints takes the stale endpoints out of the rotation rather
+// than removing them, so that a registration arriving late revives them with
+// their sticky sessions. A quarantined endpoint is only sent the requests of
+// the sessions pinned to it. Endpoints quarantined for longer than grace are
+// removed. It returns the endpoints it quarantined and those it removed.
+func (p *Pool) QuarantineEndpoints(threshold StaleThreshold, grace time.Duration) (quarantined, pruned []*Endpoint) {
+	p.lock.Lock()
+
+	now := time.Now()
+	for addr, e := range p.quarantine {
+		if now.Sub(e.quarantinedAt) >= grace {
+			delete(p.quarantine, addr)
+			pruned = append(pruned, e.endpoint)
+		}
+	}
+
+	for i := 0; i < len(p.endpoints); {
+		e := p.endpoints[i]
+		if !e.isStale(now, threshold(e.endpoint)) {
+			i++
+			continue
+		}
+
+		p.removeEndpoint(e)
+		e.quarantinedAt = now
+		if p.quarantine == nil {
+			p.quarantine = make(map[string]*endpointElem)
+		}
+		p.quarantine[e.endpoint.CanonicalAddr()] = e
+		quarantined = append(quarantined, e.endpoint)
+	}
+
+	observers := p.observers
+	p.lock.Unlock()
+
+	// quarantined endpoints leave the rotation, so they are reported as
+	// removed right away and as added again when revived
+	notifyRemoved(observers, quarantined...)
+	return quarantined, pruned
+}
+
+// IsQuarantined reports whether the endpoint at the address of endpoint is
+// quarantined
+func (p *Pool) IsQuarantined(endpoint *Endpoint) bool {
+	p.lock.Lock()
+	_, ok := p.quarantine[endpoint.CanonicalAddr()]
+	p.lock.Unlock()
+
+	return ok
+}
+
 // Returns true if the endpoint was removed from the Pool, false otherwise.
 func (p *Pool) Remove(endpoint *Endpoint) bool {
 	var e *endpointElem
 
 	p.lock.Lock()
-	defer p.lock.Unlock()
+	// quarantined endpoints were reported as removed already
+	if e = p.quarantine[endpoint.CanonicalAddr()]; e != nil && e.endpoint.modificationTagSameOrNewer(endpoint) {
+		delete(p.quarantine, endpoint.CanonicalAddr())
+		p.lock.Unlock()
+		return true
+	}
 	l := len(p.endpoints)
 	if l > 0 {
 		e = p.index[endpoint.CanonicalAddr()]
 		if e != nil && e.endpoint.modificationTagSameOrNewer(endpoint) {
 			p.removeEndpoint(e)
+			observers := p.observers
+			p.lock.Unlock()
+
+			notifyRemoved(observers, e.endpoint)
 			return true
 		}
 	}
+	p.lock.Unlock()
 
 	return false
 }
@@ -227,9 +543,151 @@ func (p *Pool) removeEndpoint(e *endpointElem) {
 		es[i].index = i
 	}
 	p.endpoints = es
+	p.generation++
 
 	delete(p.index, e.endpoint.CanonicalAddr())
 	delete(p.index, e.endpoint.PrivateInstanceId)
+	p.ring = nil
+	p.countSource(e.endpoint.Source, -1)
+	p.countQueryMatcher(e.endpoint, -1)
+	p.countRouteServiceOnly(e.endpoint, -1)
+}
+
+// countSource must be called with the lock held
+func (p *Pool) countSource(source string, n int) {
+	p.sources[source] += n
+	if p.sources[source] <= 0 {
+		delete(p.sources, source)
+	}
+}
+
+// countQueryMatcher must be called with the lock held
+func (p *Pool) countQueryMatcher(endpoint *Endpoint, n int) {
+	if len(endpoint.QueryMatch) > 0 {
+		p.queryMatchers += n
+	}
+}
+
+// countRouteServiceOnly must be called with the lock held
+func (p *Pool) countRouteServiceOnly(endpoint *Endpoint, n int) {
+	if endpoint.RouteServiceOnly {
+		p.routeServiceOnly += n
+	}
+}
+
+// RouteServiceOnly reports whether the route has no backend, its route
+// service answering every request
+func (p *Pool) RouteServiceOnly() bool {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	return len(p.endpoints) > 0 && p.routeServiceOnly == len(p.endpoints)
+}
+
+// Backends returns the pool of the endpoints requests can be forwarded to,
+// leaving out the RouteServiceOnly ones: the pool itself when there are none
+// of those, and nil when there is no other.
+func (p *Pool) Backends() *Pool {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	if p.routeServiceOnly == 0 {
+		return p
+	}
+
+	var backends []*endpointElem
+	for _, e := range p.endpoints {
+		if !e.endpoint.RouteServiceOnly {
+			backends = append(backends, e)
+		}
+	}
+	return p.subPool(backends)
+}
+
+// MatchQuery returns the pool of the endpoints serving requests with the
+// given raw query. An endpoint registering a QueryMatch only serves the
+// requests carrying all of its parameters, and those matching the most
+// parameters are preferred, so endpoints registering none serve the rest.
+// The pool itself is returned when no endpoint registers a QueryMatch, and
+// nil when no endpoint serves the query.
+func (p *Pool) MatchQuery(rawQuery string) *Pool {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	if p.queryMatchers == 0 {
+		return p
+	}
+
+	query, _ := url.ParseQuery(rawQuery)
+	var matched []*endpointElem
+	best := 0
+	for _, e := range p.endpoints {
+		n, ok := e.endpoint.matchQuery(query)
+		if !ok || n < best {
+			continue
+		}
+		if n > best {
+			matched, best = matched[:0], n
+		}
+		matched = append(matched, e)
+	}
+	return p.subPool(matched)
+}
+
+// Filter returns the pool of the endpoints for which keep returns true, or nil
+// when there are none
+func (p *Pool) Filter(keep func(endpoint *Endpoint) bool) *Pool {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	var kept []*endpointElem
+	for _, e := range p.endpoints {
+		if keep(e.endpoint) {
+			kept = append(kept, e)
+		}
+	}
+	return p.subPool(kept)
+}
+
+// subPool returns a view of the pool holding elems, or nil without elems. The
+// view shares the lock, the quarantine and the elems of the pool, so that
+// failures marked through it count for the pool, and must not be modified. It
+// must be called with the lock held.
+func (p *Pool) subPool(elems []*endpointElem) *Pool {
+	if len(elems) == 0 {
+		return nil
+	}
+
+	sub := &Pool{
+		lock:              p.lock,
+		endpoints:         elems,
+		index:             make(map[string]*endpointElem, 2*len(elems)),
+		quarantine:        p.quarantine,
+		sources:           make(map[string]int),
+		retryAfterFailure: p.retryAfterFailure,
+		nextIdx:           -1,
+		contextPath:       p.contextPath,
+		parent:            p,
+	}
+	for _, e := range elems {
+		sub.index[e.endpoint.CanonicalAddr()] = e
+		sub.index[e.endpoint.PrivateInstanceId] = e
+		sub.countSource(e.endpoint.Source, 1)
+		sub.countRouteServiceOnly(e.endpoint, 1)
+	}
+	return sub
+}
+
+// Sources returns the sources the endpoints of the pool were registered from
+func (p *Pool) Sources() []string {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	sources := make([]string, 0, len(p.sources))
+	for source := range p.sources {
+		sources = append(sources, source)
+	}
+	return sources
 }
 
 func (p *Pool) Endpoints(defaultLoadBalance, initial string) EndpointIterator {
@@ -241,10 +699,27 @@ func (p *Pool) Endpoints(defaultLoadBalance, initial string) EndpointIterator {
 	}
 }
 
+// FindByPrivateInstanceId returns the endpoint registered for the given
+// private instance id, or nil
+func (p *Pool) FindByPrivateInstanceId(id string) *Endpoint {
+	if id == "" {
+		return nil
+	}
+	return p.findById(id)
+}
+
 func (p *Pool) findById(id string) *Endpoint {
 	var endpoint *Endpoint
 	p.lock.Lock()
 	e := p.index[id]
+	if e == nil && id != "" {
+		for _, q := range p.quarantine {
+			if q.endpoint.PrivateInstanceId == id {
+				e = q
+				break
+			}
+		}
+	}
 	if e != nil {
 		endpoint = e.endpoint
 	}
@@ -253,14 +728,45 @@ func (p *Pool) findById(id string) *Endpoint {
 	return endpoint
 }
 
+// IsEmpty reports whether the pool has neither endpoints nor quarantined
+// ones
 func (p *Pool) IsEmpty() bool {
 	p.lock.Lock()
-	l := len(p.endpoints)
+	l := len(p.endpoints) + len(p.quarantine)
 	p.lock.Unlock()
 
 	return l == 0
 }
 
+// Quarantined reports whether the pool has quarantined endpoints and no
+// other
+func (p *Pool) Quarantined() bool {
+	p.lock.Lock()
+	quarantined := len(p.endpoints) == 0 && len(p.quarantine) > 0
+	p.lock.Unlock()
+
+	return quarantined
+}
+
+// Generation changes whenever the endpoints of the pool change as reported by
+// MarshalJSON
+func (p *Pool) Generation() uint64 {
+	p.lock.Lock()
+	g := p.generation
+	p.lock.Unlock()
+
+	return g
+}
+
+// NumEndpoints returns the number of endpoints in the pool
+func (p *Pool) NumEndpoints() int {
+	p.lock.Lock()
+	l := len(p.endpoints)
+	p.lock.Unlock()
+
+	return l
+}
+
 func (p *Pool) MarkUpdated(t time.Time) {
 	p.lock.Lock()
 	for _, e := range p.endpoints {
@@ -278,6 +784,37 @@ func (p *Pool) endpointFailed(endpoint *Endpoint) {
 	p.lock.Unlock()
 }
 
+// PoolHealth summarizes the endpoints of a pool. Fresh endpoints were updated
+// within their stale threshold.
+type PoolHealth struct {
+	Endpoints      int        `json:"endpoints"`
+	FreshEndpoints int        `json:"fresh_endpoints"`
+	Failures       int64      `json:"failures"`
+	LastSuccess    *time.Time `json:"last_success,omitempty"`
+}
+
+// Health summarizes the endpoints of the pool at now, counting those updated
+// within their stale threshold as fresh
+func (p *Pool) Health(now time.Time, threshold StaleThreshold) PoolHealth {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	health := PoolHealth{Endpoints: len(p.endpoints)}
+	for _, e := range p.endpoints {
+		if !e.isStale(now, threshold(e.endpoint)) {
+			health.FreshEndpoints++
+		}
+		if e.endpoint.Stats == nil {
+			continue
+		}
+		health.Failures += e.endpoint.Stats.Failures()
+		if t := e.endpoint.Stats.LastSuccess(); !t.IsZero() && (health.LastSuccess == nil || t.After(*health.LastSuccess)) {
+			health.LastSuccess = &t
+		}
+	}
+	return health
+}
+
 func (p *Pool) Each(f func(endpoint *Endpoint)) {
 	p.lock.Lock()
 	for _, e := range p.endpoints {
@@ -286,22 +823,137 @@ func (p *Pool) Each(f func(endpoint *Endpoint)) {
 	p.lock.Unlock()
 }
 
+// ErrorPage returns the error page of the most recently updated endpoint that
+// registered one
+func (p *Pool) ErrorPage() (name, html string) {
+	latest := p.latest(func(e *Endpoint) bool { return e.ErrorPage != "" || e.ErrorPageHTML != "" })
+	if latest == nil {
+		return "", ""
+	}
+	return latest.ErrorPage, latest.ErrorPageHTML
+}
+
+// AllowedMethods returns the methods allowed by the most recently updated
+// endpoint that restricted them, or nil when every method is allowed
+func (p *Pool) AllowedMethods() []string {
+	latest := p.latest(func(e *Endpoint) bool { return len(e.AllowedMethods) > 0 })
+	if latest == nil {
+		return nil
+	}
+	return latest.AllowedMethods
+}
+
+// CORSPolicy returns the CORS policy of the most recently updated endpoint
+// that registered one
+func (p *Pool) CORSPolicy() *config.CORSPolicy {
+	latest := p.latest(func(e *Endpoint) bool { return e.CORS != nil })
+	if latest == nil {
+		return nil
+	}
+	return latest.CORS
+}
+
+// SecurityHeaders returns the security header overrides of the most recently
+// updated endpoint that registered some
+func (p *Pool) SecurityHeaders() *config.SecurityHeaders {
+	latest := p.latest(func(e *Endpoint) bool { return e.SecurityHeaders != nil })
+	if latest == nil {
+		return nil
+	}
+	return latest.SecurityHeaders
+}
+
+// Internal reports whether the most recently updated endpoint registering a
+// visibility registered the route as internal
+func (p *Pool) Internal() bool {
+	latest := p.latest(func(e *Endpoint) bool { return e.Visibility != "" })
+	return latest != nil && latest.Visibility == config.ROUTE_VISIBILITY_INTERNAL
+}
+
+// BandwidthLimit returns the bandwidth limit of the most recently updated
+// endpoint registering one
+func (p *Pool) BandwidthLimit() *config.BandwidthLimit {
+	latest := p.latest(func(e *Endpoint) bool { return e.BandwidthLimit != nil })
+	if latest == nil {
+		return nil
+	}
+	return latest.BandwidthLimit
+}
+
+// PathRewrite returns the path rewrite of the most recently updated endpoint
+// registering one
+func (p *Pool) PathRewrite() *config.PathRewrite {
+	latest := p.latest(func(e *Endpoint) bool { return e.PathRewrite != nil })
+	if latest == nil {
+		return nil
+	}
+	return latest.PathRewrite
+}
+
+// latest returns the most recently updated endpoint matching f, which is how
+// route-wide settings registered by several endpoints are resolved
+func (p *Pool) latest(f func(*Endpoint) bool) *Endpoint {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	var latest *endpointElem
+	for _, e := range p.endpoints {
+		if !f(e.endpoint) {
+			continue
+		}
+		if latest == nil || e.updated.After(latest.updated) {
+			latest = e
+		}
+	}
+	if latest == nil {
+		return nil
+	}
+	return latest.endpoint
+}
+
 func (p *Pool) MarshalJSON() ([]byte, error) {
 	p.lock.Lock()
-	endpoints := make([]Endpoint, 0, len(p.endpoints))
+	endpoints := make([]*Endpoint, 0, len(p.endpoints))
 	for _, e := range p.endpoints {
-		endpoints = append(endpoints, *e.endpoint)
+		endpoints = append(endpoints, e.endpoint)
 	}
 	p.lock.Unlock()
 
 	return json.Marshal(endpoints)
 }
 
+// isStale reports whether the endpoint was last updated longer ago than its
+// stale threshold, or than defaultThreshold when that is shorter
+func (e *endpointElem) isStale(now time.Time, defaultThreshold time.Duration) bool {
+	staleTime := now.Add(-defaultThreshold)
+	if e.endpoint.staleThreshold > 0 && e.endpoint.staleThreshold < defaultThreshold {
+		staleTime = now.Add(-e.endpoint.staleThreshold)
+	}
+	return e.updated.Before(staleTime)
+}
+
 func (e *endpointElem) failed() {
 	t := time.Now()
 	e.failedAt = &t
 }
 
+// Activity returns the activity of the endpoint, which has no times when it
+// has no stats
+func (e *Endpoint) Activity() EndpointActivity {
+	activity := EndpointActivity{Address: e.CanonicalAddr()}
+	if e.Stats == nil {
+		return activity
+	}
+	if t := e.Stats.LastSeen(); !t.IsZero() {
+		activity.LastSeen = &t
+	}
+	if t := e.Stats.LastSuccess(); !t.IsZero() {
+		activity.LastSucceeded = &t
+	}
+	activity.Failures = e.Stats.Failures()
+	return activity
+}
+
 func (e *Endpoint) MarshalJSON() ([]byte, error) {
 	var jsonObj struct {
 		Address          string            `json:"address"`
@@ -309,6 +961,8 @@ func (e *Endpoint) MarshalJSON() ([]byte, error) {
 		RouteServiceUrl  string            `json:"route_service_url,omitempty"`
 		Tags             map[string]string `json:"tags"`
 		IsolationSegment string            `json:"isolation_segment,omitempty"`
+		Draining         bool              `json:"draining,omitempty"`
+		QueryMatch       map[string]string `json:"query_match,omitempty"`
 	}
 
 	jsonObj.Address = e.addr
@@ -316,13 +970,77 @@ func (e *Endpoint) MarshalJSON() ([]byte, error) {
 	jsonObj.TTL = int(e.staleThreshold.Seconds())
 	jsonObj.Tags = e.Tags
 	jsonObj.IsolationSegment = e.IsolationSegment
+	jsonObj.Draining = e.IsDraining()
+	jsonObj.QueryMatch = e.QueryMatch
 	return json.Marshal(jsonObj)
 }
 
+// reportedAs reports whether MarshalJSON describes the endpoints the same way
+func (e *Endpoint) reportedAs(other *Endpoint) bool {
+	return e.addr == other.addr &&
+		int(e.staleThreshold.Seconds()) == int(other.staleThreshold.Seconds()) &&
+		e.RouteServiceUrl == other.RouteServiceUrl &&
+		e.IsolationSegment == other.IsolationSegment &&
+		e.IsDraining() == other.IsDraining() &&
+		equalStringMaps(e.Tags, other.Tags) &&
+		equalStringMaps(e.QueryMatch, other.QueryMatch)
+}
+
+func equalStringMaps(a, b map[string]string) bool {
+	if len(a) != len(b) || (a == nil) != (b == nil) {
+		return false
+	}
+	for k, v := range a {
+		if bv, ok := b[k]; !ok || bv != v {
+			return false
+		}
+	}
+	return true
+}
+
+// matchQuery reports whether query carries all the parameters of the
+// endpoint's QueryMatch, and how many there are
+func (e *Endpoint) matchQuery(query url.Values) (int, bool) {
+	for name, value := range e.QueryMatch {
+		found := false
+		for _, v := range query[name] {
+			if v == value {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return 0, false
+		}
+	}
+	return len(e.QueryMatch), true
+}
+
+// IsDraining reports whether the endpoint only receives new requests when no
+// other endpoint of its pool is available. Sticky sessions still reach it.
+func (e *Endpoint) IsDraining() bool {
+	return atomic.LoadInt32(&e.draining) == 1
+}
+
+// SetDraining marks the endpoint as draining, or as active again
+func (e *Endpoint) SetDraining(draining bool) {
+	var v int32
+	if draining {
+		v = 1
+	}
+	atomic.StoreInt32(&e.draining, v)
+}
+
 func (e *Endpoint) CanonicalAddr() string {
 	return e.addr
 }
 
+// StaleThreshold is the endpoint specific staleness threshold, or zero when
+// the registry default applies
+func (e *Endpoint) StaleThreshold() time.Duration {
+	return e.staleThreshold
+}
+
 func (rm *Endpoint) Component() string {
 	return rm.Tags["component"]
 }
@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"time"
 
+	"code.cloudfoundry.org/gorouter/config"
 	"code.cloudfoundry.org/gorouter/route"
 	"code.cloudfoundry.org/routing-api/models"
 	. "github.com/onsi/ginkgo"
@@ -77,6 +78,42 @@ var _ = Describe("Pool", func() {
 					Expect(pool.Endpoints("", "").Next().ModificationTag).To(Equal(modTag2))
 				})
 			})
+
+			Context("PutEndpoint", func() {
+				var newerModTag, olderModTag models.ModificationTag
+
+				BeforeEach(func() {
+					newerModTag = models.ModificationTag{Guid: "abc", Index: 2}
+					olderModTag = models.ModificationTag{Guid: "abc", Index: 1}
+					endpoint := route.NewEndpoint("", "1.2.3.4", 5678, "", "", nil, -1, "", newerModTag, "")
+					pool.Put(endpoint)
+				})
+
+				It("reports a stale update without applying it", func() {
+					endpoint := route.NewEndpoint("", "1.2.3.4", 5678, "", "", nil, -1, "", olderModTag, "")
+
+					updated, stale := pool.PutEndpoint(endpoint, false)
+					Expect(updated).To(BeFalse())
+					Expect(stale).To(BeTrue())
+					Expect(pool.Endpoints("", "").Next().ModificationTag).To(Equal(newerModTag))
+				})
+
+				It("applies a stale update when asked to overwrite", func() {
+					endpoint := route.NewEndpoint("", "1.2.3.4", 5678, "", "", nil, -1, "", olderModTag, "")
+
+					updated, stale := pool.PutEndpoint(endpoint, true)
+					Expect(updated).To(BeTrue())
+					Expect(stale).To(BeTrue())
+					Expect(pool.Endpoints("", "").Next().ModificationTag).To(Equal(olderModTag))
+				})
+
+				It("does not report a re-registration with the same tag as stale", func() {
+					endpoint := route.NewEndpoint("", "1.2.3.4", 5678, "", "", nil, -1, "", newerModTag, "")
+
+					_, stale := pool.PutEndpoint(endpoint, true)
+					Expect(stale).To(BeFalse())
+				})
+			})
 		})
 	})
 
@@ -166,6 +203,16 @@ var _ = Describe("Pool", func() {
 		})
 	})
 
+	Context("NumEndpoints", func() {
+		It("counts the endpoints", func() {
+			Expect(pool.NumEndpoints()).To(Equal(0))
+
+			pool.Put(route.NewEndpoint("", "1.2.3.4", 5678, "", "", nil, -1, "", modTag, ""))
+			pool.Put(route.NewEndpoint("", "1.2.3.5", 5678, "", "", nil, -1, "", modTag, ""))
+			Expect(pool.NumEndpoints()).To(Equal(2))
+		})
+	})
+
 	Context("IsEmpty", func() {
 		It("starts empty", func() {
 			Expect(pool.IsEmpty()).To(BeTrue())
@@ -302,6 +349,175 @@ var _ = Describe("Pool", func() {
 		})
 	})
 
+	Context("QuarantineEndpoints", func() {
+		var stale, fresh *route.Endpoint
+
+		BeforeEach(func() {
+			stale = route.NewEndpoint("", "1.2.3.4", 5678, "stale-id", "", nil, -1, "", modTag, "")
+			fresh = route.NewEndpoint("", "1.2.3.5", 5678, "fresh-id", "", nil, -1, "", modTag, "")
+			pool.Put(stale)
+			pool.MarkUpdated(time.Now().Add(-2 * time.Minute))
+			pool.Put(fresh)
+		})
+
+		It("takes the stale endpoints out of the rotation", func() {
+			quarantined, pruned := pool.QuarantineEndpoints(route.FixedStaleThreshold(time.Minute), time.Hour)
+			Expect(quarantined).To(ConsistOf(stale))
+			Expect(pruned).To(BeEmpty())
+
+			Expect(pool.IsEmpty()).To(BeFalse())
+			Expect(pool.NumEndpoints()).To(Equal(1))
+			Expect(pool.IsQuarantined(stale)).To(BeTrue())
+			for i := 0; i < 3; i++ {
+				Expect(pool.Endpoints("", "").Next()).To(Equal(fresh))
+			}
+		})
+
+		It("keeps sending the sticky sessions of quarantined endpoints to them", func() {
+			pool.QuarantineEndpoints(route.FixedStaleThreshold(time.Minute), time.Hour)
+
+			Expect(pool.Endpoints("", "stale-id").Next()).To(Equal(stale))
+			Expect(pool.FindByPrivateInstanceId("stale-id")).To(Equal(stale))
+		})
+
+		It("reports a pool left with quarantined endpoints only", func() {
+			pool.QuarantineEndpoints(route.FixedStaleThreshold(time.Minute), time.Hour)
+			Expect(pool.Quarantined()).To(BeFalse())
+
+			pool.Remove(fresh)
+			Expect(pool.Quarantined()).To(BeTrue())
+			Expect(pool.IsEmpty()).To(BeFalse())
+		})
+
+		It("keeps sending them through the views of the pool", func() {
+			pool.QuarantineEndpoints(route.FixedStaleThreshold(time.Minute), time.Hour)
+
+			filtered := pool.Filter(func(*route.Endpoint) bool { return true })
+			Expect(filtered.Endpoints("", "stale-id").Next()).To(Equal(stale))
+		})
+
+		It("revives quarantined endpoints registering again", func() {
+			pool.QuarantineEndpoints(route.FixedStaleThreshold(time.Minute), time.Hour)
+			stale.Stats.Failed()
+
+			revived := route.NewEndpoint("", "1.2.3.4", 5678, "stale-id", "", nil, -1, "", modTag, "")
+			Expect(pool.Put(revived)).To(BeTrue())
+
+			Expect(pool.IsQuarantined(revived)).To(BeFalse())
+			Expect(pool.NumEndpoints()).To(Equal(2))
+			Expect(revived.Stats.Failures()).To(BeEquivalentTo(1))
+			Expect(pool.FindByPrivateInstanceId("stale-id")).To(Equal(revived))
+		})
+
+		It("removes the endpoints quarantined for longer than the grace period", func() {
+			pool.QuarantineEndpoints(route.FixedStaleThreshold(time.Minute), 0)
+
+			quarantined, pruned := pool.QuarantineEndpoints(route.FixedStaleThreshold(time.Minute), 0)
+			Expect(quarantined).To(BeEmpty())
+			Expect(pruned).To(ConsistOf(stale))
+			Expect(pool.IsQuarantined(stale)).To(BeFalse())
+			Expect(pool.FindByPrivateInstanceId("stale-id")).To(BeNil())
+		})
+
+		It("removes quarantined endpoints when they unregister", func() {
+			pool.QuarantineEndpoints(route.FixedStaleThreshold(time.Minute), time.Hour)
+			pool.Remove(fresh)
+
+			Expect(pool.Remove(stale)).To(BeTrue())
+			Expect(pool.IsEmpty()).To(BeTrue())
+		})
+	})
+
+	Context("Generation", func() {
+		It("changes with the endpoints as reported", func() {
+			modTag = models.ModificationTag{Guid: "abc"}
+			endpoint := route.NewEndpoint("", "1.2.3.4", 5678, "", "", map[string]string{"component": "app"}, -1, "", modTag, "")
+			pool.Put(endpoint)
+			generation := pool.Generation()
+
+			modTag.Increment()
+			Expect(pool.Put(route.NewEndpoint("", "1.2.3.4", 5678, "", "", map[string]string{"component": "app"}, -1, "", modTag, ""))).To(BeTrue())
+			Expect(pool.Generation()).To(Equal(generation))
+
+			modTag.Increment()
+			retagged := route.NewEndpoint("", "1.2.3.4", 5678, "", "", map[string]string{"component": "worker"}, -1, "", modTag, "")
+			Expect(pool.Put(retagged)).To(BeTrue())
+			Expect(pool.Generation()).To(BeNumerically(">", generation))
+			generation = pool.Generation()
+
+			pool.Remove(retagged)
+			Expect(pool.Generation()).To(BeNumerically(">", generation))
+		})
+	})
+
+	Context("Subscribe", func() {
+		var observer *recordingObserver
+
+		BeforeEach(func() {
+			observer = &recordingObserver{}
+			pool.Subscribe(observer)
+		})
+
+		It("notifies when an endpoint is added", func() {
+			endpoint := route.NewEndpoint("", "1.2.3.4", 5678, "", "", nil, -1, "", modTag, "")
+			pool.Put(endpoint)
+
+			Expect(observer.added).To(ConsistOf(endpoint))
+			Expect(observer.removed).To(BeEmpty())
+		})
+
+		It("does not notify when an endpoint is refreshed", func() {
+			endpoint := route.NewEndpoint("", "1.2.3.4", 5678, "", "", nil, -1, "", modTag, "")
+			pool.Put(endpoint)
+			pool.Put(endpoint)
+
+			Expect(observer.added).To(HaveLen(1))
+		})
+
+		It("reports a replaced endpoint as removed and added", func() {
+			oldEndpoint := route.NewEndpoint("", "1.2.3.4", 5678, "a", "", nil, -1, "", modTag, "")
+			pool.Put(oldEndpoint)
+
+			newTag := models.ModificationTag{Guid: "abc", Index: 1}
+			newEndpoint := route.NewEndpoint("", "1.2.3.4", 5678, "b", "", nil, -1, "", newTag, "")
+			pool.Put(newEndpoint)
+
+			Expect(observer.removed).To(ConsistOf(oldEndpoint))
+			Expect(observer.added).To(ConsistOf(oldEndpoint, newEndpoint))
+		})
+
+		It("notifies when an endpoint is removed", func() {
+			endpoint := route.NewEndpoint("", "1.2.3.4", 5678, "", "", nil, -1, "", modTag, "")
+			pool.Put(endpoint)
+			pool.Remove(endpoint)
+
+			Expect(observer.removed).To(ConsistOf(endpoint))
+		})
+
+		It("notifies when an endpoint is pruned", func() {
+			endpoint := route.NewEndpoint("", "1.2.3.4", 5678, "", "", nil, -1, "", modTag, "")
+			pool.Put(endpoint)
+			pool.MarkUpdated(time.Now().Add(-2 * time.Minute))
+			pool.PruneEndpoints(time.Minute)
+
+			Expect(observer.removed).To(ConsistOf(endpoint))
+		})
+
+		It("allows observers to call back into the pool", func() {
+			observer.onAdded = func() { pool.IsEmpty() }
+			pool.Put(route.NewEndpoint("", "1.2.3.4", 5678, "", "", nil, -1, "", modTag, ""))
+
+			Expect(observer.added).To(HaveLen(1))
+		})
+
+		It("stops notifying after Unsubscribe", func() {
+			pool.Unsubscribe(observer)
+			pool.Put(route.NewEndpoint("", "1.2.3.4", 5678, "", "", nil, -1, "", modTag, ""))
+
+			Expect(observer.added).To(BeEmpty())
+		})
+	})
+
 	Context("MarkUpdated", func() {
 		It("updates all endpoints", func() {
 			e1 := route.NewEndpoint("", "1.2.3.4", 5678, "", "", nil, -1, "", modTag, "")
@@ -397,6 +613,382 @@ var _ = Describe("Pool", func() {
 		})
 	})
 
+	Context("ErrorPage", func() {
+		It("returns nothing when no endpoint registered a page", func() {
+			pool.Put(route.NewEndpoint("", "1.2.3.4", 5678, "", "", nil, -1, "", modTag, ""))
+			name, html := pool.ErrorPage()
+			Expect(name).To(BeEmpty())
+			Expect(html).To(BeEmpty())
+		})
+
+		It("returns the page of the most recently updated endpoint", func() {
+			e1 := route.NewEndpoint("", "1.2.3.4", 5678, "", "", nil, -1, "", modTag, "")
+			e1.ErrorPage = "old"
+			pool.Put(e1)
+			time.Sleep(time.Millisecond)
+
+			e2 := route.NewEndpoint("", "5.6.7.8", 5678, "", "", nil, -1, "", modTag, "")
+			e2.ErrorPageHTML = "<h1>new</h1>"
+			pool.Put(e2)
+			pool.Put(route.NewEndpoint("", "9.9.9.9", 5678, "", "", nil, -1, "", modTag, ""))
+
+			name, html := pool.ErrorPage()
+			Expect(name).To(BeEmpty())
+			Expect(html).To(Equal("<h1>new</h1>"))
+		})
+	})
+
+	Context("AllowedMethods", func() {
+		It("allows every method when no endpoint restricted them", func() {
+			pool.Put(route.NewEndpoint("", "1.2.3.4", 5678, "", "", nil, -1, "", modTag, ""))
+			Expect(pool.AllowedMethods()).To(BeNil())
+		})
+
+		It("returns the methods of the most recently updated endpoint", func() {
+			e1 := route.NewEndpoint("", "1.2.3.4", 5678, "", "", nil, -1, "", modTag, "")
+			e1.AllowedMethods = []string{"GET"}
+			pool.Put(e1)
+			time.Sleep(time.Millisecond)
+
+			e2 := route.NewEndpoint("", "5.6.7.8", 5678, "", "", nil, -1, "", modTag, "")
+			e2.AllowedMethods = []string{"GET", "HEAD"}
+			pool.Put(e2)
+			pool.Put(route.NewEndpoint("", "9.9.9.9", 5678, "", "", nil, -1, "", modTag, ""))
+
+			Expect(pool.AllowedMethods()).To(Equal([]string{"GET", "HEAD"}))
+		})
+	})
+
+	Context("CORSPolicy", func() {
+		It("returns the policy of the most recently updated endpoint", func() {
+			e1 := route.NewEndpoint("", "1.2.3.4", 5678, "", "", nil, -1, "", modTag, "")
+			e1.CORS = &config.CORSPolicy{AllowedOrigins: []string{"https://old.example.org"}}
+			pool.Put(e1)
+			time.Sleep(time.Millisecond)
+
+			e2 := route.NewEndpoint("", "5.6.7.8", 5678, "", "", nil, -1, "", modTag, "")
+			e2.CORS = &config.CORSPolicy{AllowedOrigins: []string{"https://new.example.org"}}
+			pool.Put(e2)
+			pool.Put(route.NewEndpoint("", "9.9.9.9", 5678, "", "", nil, -1, "", modTag, ""))
+
+			Expect(pool.CORSPolicy()).To(Equal(e2.CORS))
+		})
+	})
+
+	Context("SecurityHeaders", func() {
+		It("returns the headers of the most recently updated endpoint", func() {
+			e1 := route.NewEndpoint("", "1.2.3.4", 5678, "", "", nil, -1, "", modTag, "")
+			e1.SecurityHeaders = &config.SecurityHeaders{FrameOptions: "DENY"}
+			pool.Put(e1)
+			time.Sleep(time.Millisecond)
+
+			e2 := route.NewEndpoint("", "5.6.7.8", 5678, "", "", nil, -1, "", modTag, "")
+			e2.SecurityHeaders = &config.SecurityHeaders{FrameOptions: "off"}
+			pool.Put(e2)
+			pool.Put(route.NewEndpoint("", "9.9.9.9", 5678, "", "", nil, -1, "", modTag, ""))
+
+			Expect(pool.SecurityHeaders()).To(Equal(e2.SecurityHeaders))
+		})
+	})
+
+	Context("BandwidthLimit", func() {
+		It("returns the limit of the most recently updated endpoint", func() {
+			e1 := route.NewEndpoint("", "1.2.3.4", 5678, "", "", nil, -1, "", modTag, "")
+			e1.BandwidthLimit = &config.BandwidthLimit{PerRoute: 1000}
+			pool.Put(e1)
+			time.Sleep(time.Millisecond)
+
+			e2 := route.NewEndpoint("", "5.6.7.8", 5678, "", "", nil, -1, "", modTag, "")
+			e2.BandwidthLimit = &config.BandwidthLimit{PerClient: 500}
+			pool.Put(e2)
+			pool.Put(route.NewEndpoint("", "9.9.9.9", 5678, "", "", nil, -1, "", modTag, ""))
+
+			Expect(pool.BandwidthLimit()).To(Equal(e2.BandwidthLimit))
+		})
+	})
+
+	Context("Filter", func() {
+		It("returns the endpoints kept", func() {
+			canary := route.NewEndpoint("", "2.2.2.2", 5678, "", "", map[string]string{"group": "canary"}, -1, "", modTag, "")
+			pool.Put(route.NewEndpoint("", "1.1.1.1", 5678, "", "", nil, -1, "", modTag, ""))
+			pool.Put(canary)
+
+			filtered := pool.Filter(func(e *route.Endpoint) bool { return e.Tags["group"] == "canary" })
+			Expect(filtered).NotTo(BeNil())
+			Expect(filtered.NumEndpoints()).To(Equal(1))
+			Expect(filtered.Endpoints("", "").Next()).To(Equal(canary))
+			Expect(pool.NumEndpoints()).To(Equal(2))
+		})
+
+		It("marks the failures of its endpoints on the pool", func() {
+			canary := route.NewEndpoint("", "2.2.2.2", 5678, "", "", map[string]string{"group": "canary"}, -1, "", modTag, "")
+			pool.Put(route.NewEndpoint("", "1.1.1.1", 5678, "", "", nil, -1, "", modTag, ""))
+			pool.Put(canary)
+
+			filtered := pool.Filter(func(e *route.Endpoint) bool { return e.Tags["group"] == "canary" })
+			iter := filtered.Endpoints("", "")
+			Expect(iter.Next()).To(Equal(canary))
+			iter.EndpointFailed()
+
+			for i := 0; i < 3; i++ {
+				Expect(pool.Endpoints("", "").Next()).NotTo(Equal(canary))
+			}
+		})
+
+		It("returns nil when no endpoint is kept", func() {
+			pool.Put(route.NewEndpoint("", "1.1.1.1", 5678, "", "", nil, -1, "", modTag, ""))
+
+			Expect(pool.Filter(func(*route.Endpoint) bool { return false })).To(BeNil())
+		})
+	})
+
+	Context("MatchQuery", func() {
+		var v1, v2, v2beta *route.Endpoint
+
+		BeforeEach(func() {
+			v1 = route.NewEndpoint("", "1.1.1.1", 5678, "", "", nil, -1, "", modTag, "")
+			v2 = route.NewEndpoint("", "2.2.2.2", 5678, "", "", nil, -1, "", modTag, "")
+			v2.QueryMatch = map[string]string{"version": "2"}
+			v2beta = route.NewEndpoint("", "3.3.3.3", 5678, "", "", nil, -1, "", modTag, "")
+			v2beta.QueryMatch = map[string]string{"version": "2", "beta": "true"}
+		})
+
+		addrs := func(p *route.Pool) []string {
+			var addrs []string
+			p.Each(func(e *route.Endpoint) {
+				addrs = append(addrs, e.CanonicalAddr())
+			})
+			return addrs
+		}
+
+		It("returns the pool itself when no endpoint matches on the query", func() {
+			pool.Put(v1)
+
+			Expect(pool.MatchQuery("version=2")).To(BeIdenticalTo(pool))
+		})
+
+		It("returns the endpoints matching the most parameters", func() {
+			pool.Put(v1)
+			pool.Put(v2)
+			pool.Put(v2beta)
+
+			Expect(addrs(pool.MatchQuery("version=2"))).To(ConsistOf("2.2.2.2:5678"))
+			Expect(addrs(pool.MatchQuery("beta=true&version=2"))).To(ConsistOf("3.3.3.3:5678"))
+			Expect(addrs(pool.MatchQuery("version=3"))).To(ConsistOf("1.1.1.1:5678"))
+			Expect(addrs(pool.MatchQuery(""))).To(ConsistOf("1.1.1.1:5678"))
+		})
+
+		It("returns nil when no endpoint serves the query", func() {
+			pool.Put(v2)
+
+			Expect(pool.MatchQuery("version=3")).To(BeNil())
+		})
+
+		It("keeps the context path of the pool", func() {
+			pool = route.NewPool(2*time.Minute, "/api")
+			pool.Put(v2)
+
+			Expect(pool.MatchQuery("version=2").ContextPath()).To(Equal("/api"))
+		})
+
+		It("returns the pool itself once the matching endpoints are removed", func() {
+			pool.Put(v1)
+			pool.Put(v2)
+			pool.Remove(v2)
+
+			Expect(pool.MatchQuery("version=2")).To(BeIdenticalTo(pool))
+		})
+	})
+
+	Context("Health", func() {
+		It("counts the fresh endpoints, the failures and the last success", func() {
+			fresh := route.NewEndpoint("", "1.1.1.1", 5678, "", "", nil, -1, "", modTag, "")
+			stale := route.NewEndpoint("", "1.1.1.2", 5678, "", "", nil, 1, "", modTag, "")
+			pool.Put(fresh)
+			pool.Put(stale)
+
+			fresh.Stats.Failed()
+			stale.Stats.Failed()
+			earlier, later := time.Now().Add(-time.Minute), time.Now()
+			fresh.Stats.Succeeded(earlier)
+			stale.Stats.Succeeded(later)
+
+			health := pool.Health(time.Now().Add(30*time.Second), route.FixedStaleThreshold(time.Minute))
+			Expect(health.Endpoints).To(Equal(2))
+			Expect(health.FreshEndpoints).To(Equal(1))
+			Expect(health.Failures).To(BeEquivalentTo(2))
+			Expect(health.LastSuccess.Equal(later)).To(BeTrue())
+		})
+
+		It("has no last success before any endpoint answered", func() {
+			pool.Put(route.NewEndpoint("", "1.1.1.1", 5678, "", "", nil, -1, "", modTag, ""))
+
+			Expect(pool.Health(time.Now(), route.FixedStaleThreshold(time.Minute)).LastSuccess).To(BeNil())
+		})
+	})
+
+	Context("Activity", func() {
+		It("records when the endpoint was last registered", func() {
+			endpoint := route.NewEndpoint("", "1.1.1.1", 5678, "", "", nil, -1, "", modTag, "")
+			before := time.Now()
+			pool.Put(endpoint)
+
+			activity := endpoint.Activity()
+			Expect(activity.Address).To(Equal("1.1.1.1:5678"))
+			Expect(activity.LastSeen).NotTo(BeNil())
+			Expect(activity.LastSeen.Before(before)).To(BeFalse())
+			Expect(activity.LastSucceeded).To(BeNil())
+		})
+
+		It("keeps the activity of the endpoint registered at the same address before", func() {
+			modTag = models.ModificationTag{Guid: "abc"}
+			previous := route.NewEndpoint("", "1.1.1.1", 5678, "", "", nil, -1, "", modTag, "")
+			pool.Put(previous)
+			succeeded := time.Now().Add(-time.Minute)
+			previous.Stats.Succeeded(succeeded)
+			previous.Stats.Failed()
+
+			modTag.Increment()
+			endpoint := route.NewEndpoint("", "1.1.1.1", 5678, "", "", nil, -1, "", modTag, "")
+			Expect(pool.Put(endpoint)).To(BeTrue())
+
+			activity := endpoint.Activity()
+			Expect(activity.LastSucceeded.Equal(succeeded)).To(BeTrue())
+			Expect(activity.Failures).To(BeEquivalentTo(1))
+			Expect(activity.LastSeen.Before(*previous.Activity().LastSeen)).To(BeFalse())
+		})
+
+		It("has no times for endpoints without stats", func() {
+			Expect((&route.Endpoint{}).Activity().LastSeen).To(BeNil())
+		})
+	})
+
+	Context("RouteServiceOnly", func() {
+		var rsOnly *route.Endpoint
+
+		BeforeEach(func() {
+			rsOnly = route.NewEndpoint("", "", 0, "", "", nil, -1, "https://rs.example.com", modTag, "")
+			rsOnly.RouteServiceOnly = true
+		})
+
+		It("is false for an empty pool", func() {
+			Expect(pool.RouteServiceOnly()).To(BeFalse())
+		})
+
+		It("is true when the route has no backend", func() {
+			pool.Put(rsOnly)
+
+			Expect(pool.RouteServiceOnly()).To(BeTrue())
+			Expect(pool.Backends()).To(BeNil())
+		})
+
+		It("is false once a backend registers", func() {
+			pool.Put(rsOnly)
+			pool.Put(route.NewEndpoint("", "1.1.1.1", 5678, "", "", nil, -1, "https://rs.example.com", modTag, ""))
+
+			Expect(pool.RouteServiceOnly()).To(BeFalse())
+			backends := pool.Backends()
+			Expect(backends.NumEndpoints()).To(Equal(1))
+			Expect(backends.Endpoints("", "").Next().CanonicalAddr()).To(Equal("1.1.1.1:5678"))
+		})
+
+		It("returns the pool itself as its backends when none is route-service-only", func() {
+			pool.Put(route.NewEndpoint("", "1.1.1.1", 5678, "", "", nil, -1, "", modTag, ""))
+
+			Expect(pool.Backends()).To(BeIdenticalTo(pool))
+		})
+
+		It("is true again once the backends are removed", func() {
+			backend := route.NewEndpoint("", "1.1.1.1", 5678, "", "", nil, -1, "https://rs.example.com", modTag, "")
+			pool.Put(rsOnly)
+			pool.Put(backend)
+			pool.Remove(backend)
+
+			Expect(pool.RouteServiceOnly()).To(BeTrue())
+		})
+	})
+
+	Context("PathRewrite", func() {
+		It("is nil when no endpoint registers one", func() {
+			pool.Put(route.NewEndpoint("", "1.2.3.4", 5678, "", "", nil, -1, "", modTag, ""))
+
+			Expect(pool.PathRewrite()).To(BeNil())
+		})
+
+		It("returns the rewrite of the most recently updated endpoint", func() {
+			e1 := route.NewEndpoint("", "1.2.3.4", 5678, "", "", nil, -1, "", modTag, "")
+			e1.PathRewrite = &config.PathRewrite{ReplacePrefix: "/v1"}
+			pool.Put(e1)
+			time.Sleep(time.Millisecond)
+
+			e2 := route.NewEndpoint("", "5.6.7.8", 5678, "", "", nil, -1, "", modTag, "")
+			e2.PathRewrite = &config.PathRewrite{StripPrefix: true}
+			pool.Put(e2)
+
+			Expect(pool.PathRewrite()).To(Equal(e2.PathRewrite))
+		})
+	})
+
+	Context("Internal", func() {
+		It("is false without a visibility", func() {
+			pool.Put(route.NewEndpoint("", "1.2.3.4", 5678, "", "", nil, -1, "", modTag, ""))
+			Expect(pool.Internal()).To(BeFalse())
+		})
+
+		It("follows the most recently updated endpoint registering a visibility", func() {
+			e1 := route.NewEndpoint("", "1.2.3.4", 5678, "", "", nil, -1, "", modTag, "")
+			e1.Visibility = "external"
+			pool.Put(e1)
+			time.Sleep(time.Millisecond)
+
+			e2 := route.NewEndpoint("", "5.6.7.8", 5678, "", "", nil, -1, "", modTag, "")
+			e2.Visibility = "internal"
+			pool.Put(e2)
+			pool.Put(route.NewEndpoint("", "9.9.9.9", 5678, "", "", nil, -1, "", modTag, ""))
+
+			Expect(pool.Internal()).To(BeTrue())
+		})
+	})
+
+	Context("Sources", func() {
+		It("lists the sources of the endpoints", func() {
+			e1 := route.NewEndpoint("", "1.2.3.4", 5678, "", "", nil, -1, "", modTag, "")
+			e1.Source = "nats"
+			e2 := route.NewEndpoint("", "5.6.7.8", 5678, "", "", nil, -1, "", modTag, "")
+			e2.Source = "routing_api"
+			pool.Put(e1)
+			pool.Put(e2)
+
+			Expect(pool.Sources()).To(ConsistOf("nats", "routing_api"))
+
+			pool.Remove(e2)
+			Expect(pool.Sources()).To(ConsistOf("nats"))
+		})
+
+		It("follows an endpoint replaced at the same address", func() {
+			e1 := route.NewEndpoint("", "1.2.3.4", 5678, "", "", nil, -1, "", modTag, "")
+			e1.Source = "nats"
+			pool.Put(e1)
+
+			e2 := route.NewEndpoint("", "1.2.3.4", 5678, "", "", nil, -1, "", modTag, "")
+			e2.Source = "routing_api"
+			pool.Put(e2)
+
+			Expect(pool.Sources()).To(ConsistOf("routing_api"))
+		})
+	})
+
+	Context("when an endpoint is draining", func() {
+		It("marshals its status", func() {
+			e := route.NewEndpoint("", "1.2.3.4", 5678, "", "", nil, -1, "", modTag, "")
+			e.SetDraining(true)
+			pool.Put(e)
+			json, err := pool.MarshalJSON()
+			Expect(err).ToNot(HaveOccurred())
+			Expect(string(json)).To(Equal(`[{"address":"1.2.3.4:5678","ttl":-1,"tags":null,"draining":true}]`))
+		})
+	})
+
 	Context("when endpoints have empty tags", func() {
 		var e *route.Endpoint
 		BeforeEach(func() {
@@ -411,3 +1003,20 @@ var _ = Describe("Pool", func() {
 		})
 	})
 })
+
+type recordingObserver struct {
+	added   []*route.Endpoint
+	removed []*route.Endpoint
+	onAdded func()
+}
+
+func (o *recordingObserver) OnEndpointAdded(e *route.Endpoint) {
+	o.added = append(o.added, e)
+	if o.onAdded != nil {
+		o.onAdded()
+	}
+}
+
+func (o *recordingObserver) OnEndpointRemoved(e *route.Endpoint) {
+	o.removed = append(o.removed, e)
+}
@@ -15,10 +15,10 @@ type RoundRobin struct {
 }
 
 func NewRoundRobin(p *Pool, initial string) EndpointIterator {
-	return &RoundRobin{
-		pool:            p,
-		initialEndpoint: initial,
-	}
+	r := roundRobins.Get().(*RoundRobin)
+	r.pool = p
+	r.initialEndpoint = initial
+	return r
 }
 
 func (r *RoundRobin) Next() *Endpoint {
@@ -54,6 +54,8 @@ func (r *RoundRobin) next() *Endpoint {
 
 	startIdx := r.pool.nextIdx
 	curIdx := startIdx
+	var draining *endpointElem
+	drainingIdx := 0
 	for {
 		e := r.pool.endpoints[curIdx]
 
@@ -71,11 +73,22 @@ func (r *RoundRobin) next() *Endpoint {
 		}
 
 		if e.failedAt == nil {
-			r.pool.nextIdx = curIdx
-			return e.endpoint
+			if !e.endpoint.IsDraining() {
+				r.pool.nextIdx = curIdx
+				return e.endpoint
+			}
+			if draining == nil {
+				draining, drainingIdx = e, curIdx
+			}
 		}
 
 		if curIdx == startIdx {
+			// only draining endpoints are available, so fall back to them
+			if draining != nil {
+				r.pool.nextIdx = drainingIdx
+				return draining.endpoint
+			}
+
 			// all endpoints are marked failed so reset everything to available
 			for _, e2 := range r.pool.endpoints {
 				e2.failedAt = nil
@@ -134,6 +134,48 @@ var _ = Describe("RoundRobin", func() {
 		})
 	})
 
+	Describe("Draining", func() {
+		It("skips draining endpoints", func() {
+			e1 := route.NewEndpoint("", "1.2.3.4", 5678, "", "", nil, -1, "", modTag, "")
+			e2 := route.NewEndpoint("", "5.6.7.8", 1234, "", "", nil, -1, "", modTag, "")
+			pool.Put(e1)
+			pool.Put(e2)
+			e1.SetDraining(true)
+
+			iter := route.NewRoundRobin(pool, "")
+			for i := 0; i < 5; i++ {
+				Expect(iter.Next()).To(Equal(e2))
+			}
+		})
+
+		It("falls back to draining endpoints when no other is available", func() {
+			e1 := route.NewEndpoint("", "1.2.3.4", 5678, "", "", nil, -1, "", modTag, "")
+			e2 := route.NewEndpoint("", "5.6.7.8", 1234, "", "", nil, -1, "", modTag, "")
+			pool.Put(e1)
+			pool.Put(e2)
+			e1.SetDraining(true)
+
+			iter := route.NewRoundRobin(pool, "")
+			Expect(iter.Next()).To(Equal(e2))
+			iter.EndpointFailed()
+			Expect(iter.Next()).To(Equal(e1))
+
+			e2.SetDraining(true)
+			iter = route.NewRoundRobin(pool, "")
+			Expect(iter.Next()).To(Equal(e1))
+		})
+
+		It("still finds a draining initial endpoint", func() {
+			e1 := route.NewEndpoint("", "1.2.3.4", 5678, "a", "", nil, -1, "", modTag, "")
+			pool.Put(e1)
+			pool.Put(route.NewEndpoint("", "5.6.7.8", 1234, "b", "", nil, -1, "", modTag, ""))
+			e1.SetDraining(true)
+
+			iter := route.NewRoundRobin(pool, "a")
+			Expect(iter.Next()).To(Equal(e1))
+		})
+	})
+
 	Describe("Failed", func() {
 		It("skips failed endpoints", func() {
 			e1 := route.NewEndpoint("", "1.2.3.4", 5678, "", "", nil, -1, "", modTag, "")
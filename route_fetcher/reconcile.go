@@ -0,0 +1,89 @@
+package route_fetcher
+
+import (
+	"fmt"
+
+	"code.cloudfoundry.org/gorouter/registry"
+	"code.cloudfoundry.org/gorouter/route"
+	"code.cloudfoundry.org/routing-api/models"
+	"github.com/cloudfoundry/dropsonde/metrics"
+	"github.com/uber-go/zap"
+)
+
+const (
+	DriftMissing = "routing_api_drift.missing"
+	DriftExtra   = "routing_api_drift.extra"
+	DriftRepairs = "routing_api_drift_repairs"
+)
+
+// EndpointIterator visits every endpoint of a route table
+type EndpointIterator interface {
+	EachEndpoint(f func(uri route.Uri, endpoint *route.Endpoint))
+}
+
+// Drift lists the differences between the routing API and the registry: the
+// routes of the routing API missing from the registry, and the endpoints of
+// the registry the routing API no longer has
+type Drift struct {
+	Missing []models.Route
+	Extra   []registry.Registration
+}
+
+// Reconcile compares the routes of the routing API with the endpoints of the
+// registry that came from it, the only ones carrying a modification tag,
+// reports the drift, and repairs it when RepairDrift is set.
+func (r *RouteFetcher) Reconcile() (Drift, error) {
+	// The registry is read before the routing API, so that an event applied
+	// in between can only show as drift the repair agrees with
+	current := make(map[string]registry.Registration)
+	r.Endpoints.EachEndpoint(func(uri route.Uri, endpoint *route.Endpoint) {
+		if endpoint.ModificationTag.Guid == "" {
+			return
+		}
+		current[driftKey(uri, endpoint.CanonicalAddr())] = registry.Registration{URI: uri, Endpoint: endpoint}
+	})
+
+	routes, err := r.fetchRoutesWithTokenRefresh()
+	if err != nil {
+		return Drift{}, err
+	}
+
+	var drift Drift
+	for _, aRoute := range routes {
+		key := driftKey(route.Uri(aRoute.Route), fmt.Sprintf("%s:%d", aRoute.IP, aRoute.Port))
+		if _, ok := current[key]; ok {
+			delete(current, key)
+			continue
+		}
+		drift.Missing = append(drift.Missing, aRoute)
+	}
+	for _, reg := range current {
+		drift.Extra = append(drift.Extra, reg)
+	}
+
+	metrics.SendValue(DriftMissing, float64(len(drift.Missing)), "Metric")
+	metrics.SendValue(DriftExtra, float64(len(drift.Extra)), "Metric")
+	if len(drift.Missing) == 0 && len(drift.Extra) == 0 {
+		return drift, nil
+	}
+
+	r.logger.Info("route-table-drift",
+		zap.Int("missing", len(drift.Missing)),
+		zap.Int("extra", len(drift.Extra)),
+		zap.Bool("repair", r.RepairDrift),
+	)
+	if r.RepairDrift {
+		for _, aRoute := range drift.Missing {
+			r.RouteRegistry.Register(route.Uri(aRoute.Route), makeEndpoint(aRoute))
+		}
+		for _, reg := range drift.Extra {
+			r.RouteRegistry.Unregister(reg.URI, reg.Endpoint)
+		}
+		metrics.AddToCounter(DriftRepairs, uint64(len(drift.Missing)+len(drift.Extra)))
+	}
+	return drift, nil
+}
+
+func driftKey(uri route.Uri, addr string) string {
+	return string(uri.RouteKey()) + " " + addr
+}
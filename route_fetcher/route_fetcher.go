@@ -23,6 +23,11 @@ type RouteFetcher struct {
 	RouteRegistry                      registry.Registry
 	FetchRoutesInterval                time.Duration
 	SubscriptionRetryIntervalInSeconds int
+	// ReconcileInterval, when positive, is how often Endpoints is reconciled
+	// with the routing API
+	ReconcileInterval time.Duration
+	RepairDrift       bool
+	Endpoints         EndpointIterator
 
 	logger          logger.Logger
 	endpoints       []models.Route
@@ -47,6 +52,8 @@ func NewRouteFetcher(logger logger.Logger, uaaClient uaa_client.Client, routeReg
 		RouteRegistry:                      routeRegistry,
 		FetchRoutesInterval:                cfg.PruneStaleDropletsInterval / 2,
 		SubscriptionRetryIntervalInSeconds: subscriptionRetryInterval,
+		ReconcileInterval:                  cfg.RoutingApi.ReconcileInterval,
+		RepairDrift:                        cfg.RoutingApi.RepairDrift,
 
 		client:       client,
 		logger:       logger,
@@ -62,6 +69,13 @@ func (r *RouteFetcher) Run(signals <-chan os.Signal, ready chan<- struct{}) erro
 	r.logger.Debug("created-ticker", zap.Duration("interval", r.FetchRoutesInterval))
 	r.logger.Info("syncer-started")
 
+	var reconcile <-chan time.Time
+	if r.ReconcileInterval > 0 && r.Endpoints != nil {
+		reconcileTicker := r.clock.NewTicker(r.ReconcileInterval)
+		defer reconcileTicker.Stop()
+		reconcile = reconcileTicker.C()
+	}
+
 	close(ready)
 	for {
 		select {
@@ -70,6 +84,11 @@ func (r *RouteFetcher) Run(signals <-chan os.Signal, ready chan<- struct{}) erro
 			if err != nil {
 				r.logger.Error("failed-to-fetch-routes", zap.Error(err))
 			}
+		case <-reconcile:
+			_, err := r.Reconcile()
+			if err != nil {
+				r.logger.Error("failed-to-reconcile-routes", zap.Error(err))
+			}
 		case e := <-r.eventChannel:
 			r.HandleEvent(e)
 
@@ -156,20 +175,8 @@ func (r *RouteFetcher) subscribeToEvents(token *schema.Token) error {
 }
 
 func (r *RouteFetcher) HandleEvent(e routing_api.Event) {
-	eventRoute := e.Route
-	uri := route.Uri(eventRoute.Route)
-	endpoint := route.NewEndpoint(
-		eventRoute.LogGuid,
-		eventRoute.IP,
-		uint16(eventRoute.Port),
-		eventRoute.LogGuid,
-		"",
-		nil,
-		eventRoute.GetTTL(),
-		eventRoute.RouteServiceUrl,
-		eventRoute.ModificationTag,
-		"",
-	)
+	uri := route.Uri(e.Route.Route)
+	endpoint := makeEndpoint(e.Route)
 	switch e.Action {
 	case "Delete":
 		r.RouteRegistry.Unregister(uri, endpoint)
@@ -227,20 +234,7 @@ func (r *RouteFetcher) refreshEndpoints(validRoutes []models.Route) {
 	r.endpoints = validRoutes
 
 	for _, aRoute := range r.endpoints {
-		r.RouteRegistry.Register(
-			route.Uri(aRoute.Route),
-			route.NewEndpoint(
-				aRoute.LogGuid,
-				aRoute.IP,
-				uint16(aRoute.Port),
-				aRoute.LogGuid,
-				"",
-				nil,
-				aRoute.GetTTL(),
-				aRoute.RouteServiceUrl,
-				aRoute.ModificationTag,
-				"",
-			))
+		r.RouteRegistry.Register(route.Uri(aRoute.Route), makeEndpoint(aRoute))
 	}
 }
 
@@ -263,20 +257,7 @@ func (r *RouteFetcher) deleteEndpoints(validRoutes []models.Route) {
 	}
 
 	for _, aRoute := range diff {
-		r.RouteRegistry.Unregister(
-			route.Uri(aRoute.Route),
-			route.NewEndpoint(
-				aRoute.LogGuid,
-				aRoute.IP,
-				uint16(aRoute.Port),
-				aRoute.LogGuid,
-				"",
-				nil,
-				aRoute.GetTTL(),
-				aRoute.RouteServiceUrl,
-				aRoute.ModificationTag,
-				"",
-			))
+		r.RouteRegistry.Unregister(route.Uri(aRoute.Route), makeEndpoint(aRoute))
 	}
 }
 
@@ -287,3 +268,18 @@ func routeEquals(current, desired models.Route) bool {
 
 	return false
 }
+
+func makeEndpoint(aRoute models.Route) *route.Endpoint {
+	return route.NewEndpoint(
+		aRoute.LogGuid,
+		aRoute.IP,
+		uint16(aRoute.Port),
+		aRoute.LogGuid,
+		"",
+		nil,
+		aRoute.GetTTL(),
+		aRoute.RouteServiceUrl,
+		aRoute.ModificationTag,
+		"",
+	)
+}
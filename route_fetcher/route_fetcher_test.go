@@ -9,6 +9,7 @@ import (
 
 	"code.cloudfoundry.org/gorouter/config"
 	"code.cloudfoundry.org/gorouter/logger"
+	rregistry "code.cloudfoundry.org/gorouter/registry"
 	testRegistry "code.cloudfoundry.org/gorouter/registry/fakes"
 	"code.cloudfoundry.org/gorouter/route"
 	. "code.cloudfoundry.org/gorouter/route_fetcher"
@@ -29,6 +30,15 @@ import (
 
 var sender *metrics_fakes.FakeMetricSender
 
+// endpointList is a route table of fixed endpoints
+type endpointList []rregistry.Registration
+
+func (l endpointList) EachEndpoint(f func(uri route.Uri, endpoint *route.Endpoint)) {
+	for _, reg := range l {
+		f(reg.URI, reg.Endpoint)
+	}
+}
+
 func init() {
 	sender = metrics_fakes.NewFakeMetricSender()
 	metrics.Initialize(sender, nil)
@@ -505,4 +515,74 @@ var _ = Describe("RouteFetcher", func() {
 			})
 		})
 	})
+
+	Describe("Reconcile", func() {
+		var (
+			tag      models.ModificationTag
+			foo, bar models.Route
+			gone     *route.Endpoint
+		)
+
+		BeforeEach(func() {
+			uaaClient.FetchTokenReturns(token, nil)
+			tag = models.ModificationTag{Guid: "abc", Index: 1}
+
+			foo = models.NewRoute("foo.example.com", 1111, "1.1.1.1", "foo-guid", "", 0)
+			foo.ModificationTag = tag
+			bar = models.NewRoute("bar.example.com", 2222, "2.2.2.2", "bar-guid", "", 0)
+			bar.ModificationTag = tag
+			client.RoutesReturns([]models.Route{foo, bar}, nil)
+
+			gone = route.NewEndpoint("gone-guid", "3.3.3.3", 3333, "gone-guid", "", nil, 0, "", tag, "")
+			fetcher.Endpoints = endpointList{
+				{URI: "foo.example.com", Endpoint: route.NewEndpoint("foo-guid", "1.1.1.1", 1111, "foo-guid", "", nil, 0, "", tag, "")},
+				{URI: "gone.example.com", Endpoint: gone},
+				{URI: "nats.example.com", Endpoint: route.NewEndpoint("nats", "4.4.4.4", 4444, "", "", nil, 0, "", models.ModificationTag{}, "")},
+			}
+		})
+
+		It("reports the routes missing from the registry and the endpoints the routing api no longer has", func() {
+			drift, err := fetcher.Reconcile()
+			Expect(err).ToNot(HaveOccurred())
+
+			Expect(drift.Missing).To(Equal([]models.Route{bar}))
+			Expect(drift.Extra).To(HaveLen(1))
+			Expect(drift.Extra[0].URI).To(Equal(route.Uri("gone.example.com")))
+			Expect(drift.Extra[0].Endpoint).To(Equal(gone))
+
+			Expect(registry.RegisterCallCount()).To(Equal(0))
+			Expect(registry.UnregisterCallCount()).To(Equal(0))
+			Expect(logger).To(gbytes.Say("route-table-drift"))
+		})
+
+		Context("when repairing drift", func() {
+			BeforeEach(func() {
+				fetcher.RepairDrift = true
+			})
+
+			It("registers the missing routes and unregisters the extra endpoints", func() {
+				_, err := fetcher.Reconcile()
+				Expect(err).ToNot(HaveOccurred())
+
+				Expect(registry.RegisterCallCount()).To(Equal(1))
+				uri, endpoint := registry.RegisterArgsForCall(0)
+				Expect(uri).To(Equal(route.Uri("bar.example.com")))
+				Expect(endpoint.CanonicalAddr()).To(Equal("2.2.2.2:2222"))
+
+				Expect(registry.UnregisterCallCount()).To(Equal(1))
+				uri, endpoint = registry.UnregisterArgsForCall(0)
+				Expect(uri).To(Equal(route.Uri("gone.example.com")))
+				Expect(endpoint).To(Equal(gone))
+			})
+		})
+
+		Context("when the routing api returns an error", func() {
+			It("returns the error", func() {
+				client.RoutesReturns(nil, errors.New("Oops"))
+
+				_, err := fetcher.Reconcile()
+				Expect(err).To(MatchError("Oops"))
+			})
+		})
+	})
 })
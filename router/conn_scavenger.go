@@ -0,0 +1,243 @@
+package router
+
+import (
+	"encoding/json"
+	"io"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/uber-go/zap"
+
+	router_http "code.cloudfoundry.org/gorouter/common/http"
+	"code.cloudfoundry.org/gorouter/config"
+	"code.cloudfoundry.org/gorouter/logger"
+)
+
+// States of the client conns reported by the scavenger
+const (
+	ConnStateNew       = "new"
+	ConnStateIdle      = "idle"
+	ConnStateActive    = "active"
+	ConnStateWebSocket = "websocket"
+	ConnStateDraining  = "draining"
+)
+
+// connScavenger tracks every client conn from its accept to its close, hijacked
+// ones included, which the HTTP server forgets. It closes the conns idle or
+// alive for too long and reports how many there are in each state.
+type connScavenger struct {
+	maxIdle     time.Duration
+	maxLifetime time.Duration
+	interval    time.Duration
+	logger      logger.Logger
+	doneChan    chan chan struct{}
+
+	lock     sync.Mutex
+	conns    map[*trackedConn]struct{}
+	draining bool
+}
+
+func newConnScavenger(c config.ConnScavengerConfig, logger logger.Logger) *connScavenger {
+	return &connScavenger{
+		maxIdle:     c.MaxIdle,
+		maxLifetime: c.MaxLifetime,
+		interval:    c.Interval,
+		logger:      logger,
+		doneChan:    make(chan chan struct{}),
+		conns:       make(map[*trackedConn]struct{}),
+	}
+}
+
+// trackedConn is a client conn known to the scavenger. Its state is guarded
+// by the scavenger lock.
+type trackedConn struct {
+	net.Conn
+	scavenger *connScavenger
+	accepted  time.Time
+
+	state string
+	since time.Time
+}
+
+func (c *trackedConn) Close() error {
+	c.scavenger.forget(c)
+	return c.Conn.Close()
+}
+
+func (c *trackedConn) ReadFrom(r io.Reader) (int64, error) {
+	return router_http.ReadFrom(c.Conn, r)
+}
+
+type trackingListener struct {
+	net.Listener
+	scavenger *connScavenger
+}
+
+func (l *trackingListener) Accept() (net.Conn, error) {
+	conn, err := l.Listener.Accept()
+	if err != nil {
+		return nil, err
+	}
+	return l.scavenger.track(conn, time.Now()), nil
+}
+
+// listener tracks the conns ln accepts
+func (s *connScavenger) listener(ln net.Listener) net.Listener {
+	return &trackingListener{Listener: ln, scavenger: s}
+}
+
+func (s *connScavenger) track(conn net.Conn, now time.Time) *trackedConn {
+	c := &trackedConn{
+		Conn:      conn,
+		scavenger: s,
+		accepted:  now,
+		state:     ConnStateNew,
+		since:     now,
+	}
+
+	s.lock.Lock()
+	s.conns[c] = struct{}{}
+	s.lock.Unlock()
+	return c
+}
+
+func (s *connScavenger) forget(c *trackedConn) {
+	s.lock.Lock()
+	delete(s.conns, c)
+	s.lock.Unlock()
+}
+
+// setState records the state the HTTP server moved conn, or a conn wrapping
+// the tracked one, to. A conn becoming idle past its lifetime is closed.
+func (s *connScavenger) setState(conn net.Conn, state http.ConnState) {
+	c := trackedConnOf(conn)
+	if c == nil {
+		return
+	}
+	now := time.Now()
+
+	s.lock.Lock()
+	switch state {
+	case http.StateActive:
+		c.state, c.since = ConnStateActive, now
+	case http.StateIdle:
+		c.state, c.since = ConnStateIdle, now
+	case http.StateHijacked:
+		c.state, c.since = ConnStateWebSocket, now
+	}
+	expired := state == http.StateIdle && s.expired(c, now)
+	s.lock.Unlock()
+
+	if expired {
+		conn.Close()
+	}
+}
+
+// expired reports whether c is idle past the limits. It must be called with
+// the lock held.
+func (s *connScavenger) expired(c *trackedConn, now time.Time) bool {
+	if c.state != ConnStateIdle && c.state != ConnStateNew {
+		return false
+	}
+	if s.maxIdle > 0 && now.Sub(c.since) > s.maxIdle {
+		return true
+	}
+	return s.maxLifetime > 0 && now.Sub(c.accepted) > s.maxLifetime
+}
+
+// setDraining reports the conns that are not idle as draining from now on
+func (s *connScavenger) setDraining() {
+	s.lock.Lock()
+	s.draining = true
+	s.lock.Unlock()
+}
+
+// scavenge closes the conns idle past the limits and returns how many. They
+// are closed with the lock held, so that none becomes active in between.
+func (s *connScavenger) scavenge(now time.Time) int {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	var n int
+	for c := range s.conns {
+		if s.expired(c, now) {
+			delete(s.conns, c)
+			c.Conn.Close()
+			n++
+		}
+	}
+	return n
+}
+
+// Start scavenges the conns every interval until stopped, when limits are
+// configured
+func (s *connScavenger) Start() {
+	if s.maxIdle <= 0 && s.maxLifetime <= 0 {
+		stopped := <-s.doneChan
+		close(stopped)
+		return
+	}
+
+	ticker := time.NewTicker(s.interval)
+	for {
+		select {
+		case now := <-ticker.C:
+			if n := s.scavenge(now); n > 0 {
+				s.logger.Info("connections-scavenged", zap.Int("count", n))
+			}
+		case stopped := <-s.doneChan:
+			ticker.Stop()
+			close(stopped)
+			return
+		}
+	}
+}
+
+func (s *connScavenger) Stop() {
+	stopped := make(chan struct{})
+	s.doneChan <- stopped
+	<-stopped
+}
+
+// ConnReport counts the client conns by state
+type ConnReport struct {
+	Total         int            `json:"total"`
+	States        map[string]int `json:"states"`
+	OldestSeconds float64        `json:"oldest_seconds"`
+}
+
+// Report counts the conns tracked by state
+func (s *connScavenger) Report(now time.Time) ConnReport {
+	report := ConnReport{States: map[string]int{
+		ConnStateNew:       0,
+		ConnStateIdle:      0,
+		ConnStateActive:    0,
+		ConnStateWebSocket: 0,
+		ConnStateDraining:  0,
+	}}
+
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	for c := range s.conns {
+		state := c.state
+		if s.draining && (state == ConnStateActive || state == ConnStateWebSocket) {
+			state = ConnStateDraining
+		}
+		report.States[state]++
+		report.Total++
+		if age := now.Sub(c.accepted).Seconds(); age > report.OldestSeconds {
+			report.OldestSeconds = age
+		}
+	}
+	return report
+}
+
+// ServeHTTP serves the report of the conns as JSON
+func (s *connScavenger) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	w.Header().Set("Connection", "close")
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(s.Report(time.Now()))
+}
@@ -0,0 +1,521 @@
+package router
+
+import (
+	"context"
+	"os"
+
+	"code.cloudfoundry.org/gorouter/access_log"
+	"code.cloudfoundry.org/gorouter/audit"
+	"code.cloudfoundry.org/gorouter/capture"
+	"code.cloudfoundry.org/gorouter/common/schema"
+	"code.cloudfoundry.org/gorouter/common/secure"
+	"code.cloudfoundry.org/gorouter/config"
+	"code.cloudfoundry.org/gorouter/connlimit"
+	"code.cloudfoundry.org/gorouter/consul"
+	"code.cloudfoundry.org/gorouter/geoip"
+	"code.cloudfoundry.org/gorouter/handlers"
+	"code.cloudfoundry.org/gorouter/ingress"
+	"code.cloudfoundry.org/gorouter/kubernetes"
+	"code.cloudfoundry.org/gorouter/logger"
+	"code.cloudfoundry.org/gorouter/metrics"
+	"code.cloudfoundry.org/gorouter/metrics/monitor"
+	"code.cloudfoundry.org/gorouter/middleware"
+	"code.cloudfoundry.org/gorouter/middleware/external"
+	"code.cloudfoundry.org/gorouter/ocspstaple"
+	"code.cloudfoundry.org/gorouter/registry"
+	"code.cloudfoundry.org/gorouter/routeshare"
+	"code.cloudfoundry.org/gorouter/sessiontickets"
+	"code.cloudfoundry.org/gorouter/staticroutes"
+	"code.cloudfoundry.org/gorouter/varz"
+	"code.cloudfoundry.org/gorouter/xds"
+	"code.cloudfoundry.org/routing-api"
+	"github.com/cloudfoundry/dropsonde"
+	"github.com/cloudfoundry/dropsonde/metric_sender"
+	"github.com/cloudfoundry/dropsonde/metricbatcher"
+	"github.com/nats-io/nats"
+	"github.com/tedsuo/ifrit"
+	"github.com/tedsuo/ifrit/grouper"
+	"github.com/uber-go/zap"
+	"github.com/urfave/negroni"
+
+	"time"
+)
+
+// Option customizes the Gorouter built by New
+type Option func(*options)
+
+type options struct {
+	logger           logger.Logger
+	logLevels        *logger.Levels
+	registry         *registry.RouteRegistry
+	registryReporter metrics.RouteRegistryReporter
+	proxyReporter    metrics.ProxyReporter
+	accessLogger     access_log.AccessLogger
+	handlers         []negroni.Handler
+}
+
+// WithLogger sets the logger the router's components log to
+func WithLogger(l logger.Logger) Option {
+	return func(o *options) { o.logger = l }
+}
+
+// WithLogLevels serves levels, those of the logger set with WithLogger, on
+// the status server so that they can be changed at runtime
+func WithLogLevels(levels *logger.Levels) Option {
+	return func(o *options) { o.logLevels = levels }
+}
+
+// WithRegistry makes the router serve routes from r instead of a registry of
+// its own. The registry reporter is not used for an injected registry.
+func WithRegistry(r *registry.RouteRegistry) Option {
+	return func(o *options) { o.registry = r }
+}
+
+// WithReporters replaces the dropsonde metrics reporters
+func WithReporters(registryReporter metrics.RouteRegistryReporter, proxyReporter metrics.ProxyReporter) Option {
+	return func(o *options) {
+		o.registryReporter = registryReporter
+		o.proxyReporter = proxyReporter
+	}
+}
+
+// WithAccessLogger replaces the access logger configured in access_log
+func WithAccessLogger(a access_log.AccessLogger) Option {
+	return func(o *options) { o.accessLogger = a }
+}
+
+// WithHandlers appends handlers to the proxy's handler chain. They run after
+// the protocol checks and before the route lookup.
+func WithHandlers(handlers ...negroni.Handler) Option {
+	return func(o *options) { o.handlers = append(o.handlers, handlers...) }
+}
+
+// Gorouter is a fully wired router: the proxy and its listeners together with
+// whatever keeps the routing table in sync.
+type Gorouter struct {
+	Registry *registry.RouteRegistry
+	Router   *Router
+
+	logger      logger.Logger
+	members     grouper.Members
+	healthCheck int32
+}
+
+// New connects to NATS and, when configured, the routing API, and builds the
+// router described by c. Dropsonde must already be initialized unless both
+// reporters are replaced with WithReporters.
+func New(c *config.Config, opts ...Option) (*Gorouter, error) {
+	o := &options{}
+	for _, opt := range opts {
+		opt(o)
+	}
+	if o.logger == nil {
+		o.logger = logger.NewLogger("gorouter.stdout", zap.Output(os.Stdout))
+	}
+	if o.registryReporter == nil || o.proxyReporter == nil {
+		sender := metric_sender.NewMetricSender(dropsonde.AutowiredEmitter())
+		// 5 sec is dropsonde default batching interval
+		batcher := metricbatcher.New(sender, 5*time.Second)
+		metricsReporter := metrics.NewMetricsReporter(sender, batcher)
+		if o.registryReporter == nil {
+			o.registryReporter = metricsReporter
+		}
+		if o.proxyReporter == nil {
+			o.proxyReporter = metricsReporter
+		}
+	}
+	lggr := o.logger
+
+	pluginReporter, ok := o.proxyReporter.(metrics.PluginReporter)
+	if !ok {
+		pluginReporter = nopPluginReporter{}
+	}
+	plugins, err := startExternalPlugins(lggr, c, pluginReporter)
+	if err != nil {
+		return nil, err
+	}
+	// stops undoes what was set up so far, in reverse, when New fails
+	var stops []func()
+	built := false
+	defer func() {
+		if !built {
+			for i := len(stops) - 1; i >= 0; i-- {
+				stops[i]()
+			}
+		}
+	}()
+	for _, p := range plugins {
+		stops = append(stops, p.Kill)
+	}
+
+	_, err = middleware.NewChain(c.MiddlewarePlugins)
+	if err != nil {
+		lggr.Error("invalid-middleware-plugins", zap.Error(err))
+		return nil, err
+	}
+	_, err = middleware.NewAttemptObservers(c.AttemptObservers)
+	if err != nil {
+		lggr.Error("invalid-attempt-observers", zap.Error(err))
+		return nil, err
+	}
+
+	g := &Gorouter{logger: lggr}
+	for _, p := range plugins {
+		g.members = append(g.members, grouper.Member{Name: "external-plugin-" + p.Name(), Runner: p})
+	}
+
+	lggr.Info("setting-up-nats-connection")
+	startMsgChan := make(chan struct{})
+	natsClient := connectToNatsServer(lggr.Session("nats"), c, startMsgChan)
+	stops = append(stops, natsClient.Close)
+
+	var routingAPIClient routing_api.Client
+	if c.RoutingApiEnabled() && c.RouteTableSharing.Mode != config.ROUTE_TABLE_SHARING_FOLLOWER {
+		lggr.Info("setting-up-routing-api")
+
+		routingAPIClient, err = setupRoutingAPIClient(lggr, c)
+		if err != nil {
+			lggr.Error("routing-api-connection-failed", zap.Error(err))
+			return nil, err
+		}
+	}
+
+	g.Registry = o.registry
+	if g.Registry == nil {
+		g.Registry = registry.NewRouteRegistry(lggr.Session("registry"), c, o.registryReporter)
+	}
+	if c.SuspendPruningIfNatsUnavailable {
+		g.Registry.SuspendPruning(func() bool { return !(natsClient.Status() == nats.CONNECTED) })
+	}
+
+	auditLogger, err := audit.CreateRunningLogger(lggr.Session("audit-log"), c)
+	if err != nil {
+		lggr.Error("error-creating-audit-logger", zap.Error(err))
+		return nil, err
+	}
+	stops = append(stops, auditLogger.Stop)
+	g.Registry.SetAuditLogger(auditLogger)
+
+	v := varz.NewVarz(g.Registry)
+	compositeReporter := metrics.NewCompositeReporter(v, o.proxyReporter)
+	var tenants *metrics.TenantAggregator
+	if c.TenantMetrics.Enabled {
+		tenants = metrics.NewTenantAggregator(c.TenantMetrics.OrgTag, c.TenantMetrics.SpaceTag, c.TenantMetrics.MaxTenants)
+		compositeReporter = metrics.NewTenantReporter(compositeReporter, tenants)
+	}
+
+	accessLogger := o.accessLogger
+	if accessLogger == nil {
+		accessLogger, err = access_log.CreateRunningAccessLogger(lggr.Session("access-log"), c)
+		if err != nil {
+			lggr.Error("error-creating-access-logger", zap.Error(err))
+			return nil, err
+		}
+		stops = append(stops, accessLogger.Stop)
+	}
+
+	var crypto secure.Crypto
+	var cryptoPrev secure.Crypto
+	if c.RouteServiceEnabled {
+		crypto = createCrypto(lggr, c.RouteServiceSecret)
+		if c.RouteServiceSecretPrev != "" {
+			cryptoPrev = createCrypto(lggr, c.RouteServiceSecretPrev)
+		}
+	}
+
+	captureRecorder := capture.NewRecorder(c.RequestCapture.Enabled, c.RequestCapture.MaxEntries,
+		c.RequestCapture.MaxBodyBytes, c.RequestCapture.MaxDuration)
+
+	extraHandlers := o.handlers
+	if len(c.RouteServiceBypass.TrustedNetworks) > 0 || c.RouteServiceBypass.Secret != "" {
+		extraHandlers = append([]negroni.Handler{handlers.NewRouteServiceBypass(c.RouteServiceBypass, lggr)}, extraHandlers...)
+	}
+
+	if c.GeoIP.Database != "" {
+		locator, err := geoip.NewLocator(c.GeoIP.Database, c.GeoIP.ReloadInterval, lggr.Session("geoip"))
+		if err != nil {
+			lggr.Error("geoip-database-error", zap.Error(err))
+			return nil, err
+		}
+		g.members = append(g.members, grouper.Member{Name: "geoip", Runner: locator})
+		extraHandlers = append([]negroni.Handler{handlers.NewGeoIP(locator, c.GeoIP.AddHeader, c.GeoIP.UseXForwardedFor, lggr)}, extraHandlers...)
+	}
+
+	if c.AnomalyDetection.Enabled {
+		detector := newAnomalyDetector(lggr.Session("anomaly-detector"), c.AnomalyDetection, o.proxyReporter)
+		g.members = append(g.members, grouper.Member{Name: "anomaly-detector", Runner: detector})
+		extraHandlers = append([]negroni.Handler{handlers.NewAnomalyDetection(detector, lggr)}, extraHandlers...)
+	}
+
+	var authFailures *metrics.AuthFailureAggregator
+	if c.AuthFailureMetrics.Enabled {
+		authFailures = newAuthFailureAggregator(lggr.Session("auth-failures"), c.AuthFailureMetrics, o.proxyReporter)
+		g.members = append(g.members, grouper.Member{Name: "auth-failures", Runner: authFailures})
+		extraHandlers = append([]negroni.Handler{handlers.NewAuthFailures(authFailures, lggr)}, extraHandlers...)
+	}
+
+	if c.LoadShedding.MaxGoroutines > 0 || c.LoadShedding.MaxOpenFiles > 0 {
+		watchdog := monitor.NewResourceWatchdog(c.LoadShedding.MaxGoroutines, c.LoadShedding.MaxOpenFiles,
+			c.LoadShedding.CheckInterval, lggr.Session("resource-watchdog"))
+		g.members = append(g.members, grouper.Member{Name: "resource-watchdog", Runner: watchdog})
+		extraHandlers = append([]negroni.Handler{handlers.NewLoadShedding(watchdog, c.LoadShedding.RetryAfter)}, extraHandlers...)
+	}
+
+	if c.AdaptiveConcurrency.Enabled || c.LoadShedding.MaxGoroutines > 0 || c.LoadShedding.MaxOpenFiles > 0 {
+		extraHandlers = append([]negroni.Handler{handlers.NewRequestPriority(c.RequestPriority, lggr)}, extraHandlers...)
+	}
+
+	extraHandlers = append([]negroni.Handler{handlers.NewEarlyData(c.TLSSessions.EarlyData, lggr)}, extraHandlers...)
+
+	var inFlightReporters []metrics.InFlightReporter
+	for _, r := range []interface{}{o.proxyReporter, v} {
+		if reporter, ok := r.(metrics.InFlightReporter); ok {
+			inFlightReporters = append(inFlightReporters, reporter)
+		}
+	}
+	// report as often as dropsonde batches counters
+	inFlight := metrics.NewInFlightTracker(5*time.Second, inFlightReporters...)
+	g.members = append(g.members, grouper.Member{Name: "in-flight-tracker", Runner: inFlight})
+
+	p := buildProxy(lggr.Session("proxy"), c, g.Registry, accessLogger, compositeReporter,
+		crypto, cryptoPrev, &g.healthCheck, captureRecorder, inFlight, extraHandlers)
+	g.Router, err = NewRouter(lggr.Session("router"), c, p, natsClient, g.Registry, v, &g.healthCheck, schema.NewLogCounter(), nil)
+	if err != nil {
+		lggr.Error("initialize-router-error", zap.Error(err))
+		return nil, err
+	}
+	stops = append(stops, g.Router.component.Stop)
+	if c.EnableSSL && c.TLSSessions.TicketKeysFile != "" {
+		g.Router.ticketKeys, err = sessiontickets.NewKeyRing(c.TLSSessions.TicketKeysFile,
+			c.TLSSessions.TicketKeysReloadInterval, lggr.Session("session-tickets"))
+		if err != nil {
+			lggr.Error("session-ticket-keys-error", zap.Error(err))
+			return nil, err
+		}
+		g.members = append(g.members, grouper.Member{Name: "session-ticket-keys", Runner: g.Router.ticketKeys})
+	}
+	if reporter, ok := o.proxyReporter.(metrics.TLSReporter); ok {
+		g.Router.tlsReporter = reporter
+	}
+	if reporter, ok := o.proxyReporter.(metrics.RequestValidationReporter); ok {
+		g.Router.validation = reporter
+	}
+	if reporter, ok := o.proxyReporter.(metrics.AcceptReporter); ok {
+		g.Router.acceptReporter = reporter
+	}
+	if c.ConnectionLimits.MaxPerIP > 0 {
+		reporter, ok := o.proxyReporter.(metrics.ConnectionLimitReporter)
+		if !ok {
+			reporter = nopConnectionLimitReporter{}
+		}
+		g.Router.connLimiter = connlimit.NewLimiter(c.ConnectionLimits, reporter, lggr.Session("connection-limits"))
+	}
+	if len(c.TrustedIngress.SourceNetworks) > 0 || c.TrustedIngress.ClientCAs != nil {
+		reporter, ok := o.proxyReporter.(metrics.IngressReporter)
+		if !ok {
+			reporter = nopIngressReporter{}
+		}
+		g.Router.ingress = ingress.NewVerifier(c.TrustedIngress, reporter, lggr.Session("trusted-ingress"))
+	}
+	if c.EnableSSL && c.OCSPStapling.Enabled {
+		reporter, ok := o.proxyReporter.(metrics.OCSPReporter)
+		if !ok {
+			reporter = nopOCSPReporter{}
+		}
+		g.Router.stapler, err = ocspstaple.NewStapler(c.SSLCertificate, c.OCSPStapling, reporter, lggr.Session("ocsp"))
+		if err != nil {
+			lggr.Error("ocsp-stapling-error", zap.Error(err))
+			return nil, err
+		}
+		g.members = append(g.members, grouper.Member{Name: "ocsp-stapler", Runner: g.Router.stapler})
+	}
+	g.Router.AddStatusHandler("/capture", captureRecorder)
+	if tenants != nil {
+		g.Router.AddStatusHandler("/metrics/tenants", tenants)
+	}
+	if authFailures != nil {
+		g.Router.AddStatusHandler("/metrics/auth_failures", authFailures)
+	}
+	g.Router.AddStatusHandler("/metrics/in_flight", inFlight)
+	g.Router.AddStatusHandler("/endpoint_status", registry.NewEndpointStatusHandler(g.Registry))
+	g.Router.AddStatusHandler("/route_table/limits", registry.NewLimitsHandler(g.Registry))
+	g.Router.AddStatusHandler(registry.RouteHealthPath, registry.NewRouteHealthHandler(g.Registry))
+	if o.logLevels != nil {
+		g.Router.AddStatusHandler("/log_levels", logger.NewLevelsHandler(o.logLevels))
+	}
+
+	if c.RouteTableSharing.Mode == config.ROUTE_TABLE_SHARING_FOLLOWER {
+		// The owning process syncs from NATS and the routing API on our behalf
+		follower := routeshare.NewFollower(
+			registry.NewAuditedRegistry(g.Registry, audit.SourceRouteTableOwner, auditLogger),
+			c.RouteTableSharing.Socket, c.RouteTableSharing.RetryInterval, lggr.Session("route-table-follower"),
+		)
+		g.members = append(g.members, grouper.Member{Name: "route-table-follower", Runner: follower})
+	} else {
+		var syncRegistry registry.Registry = g.Registry
+		if c.RouteTableSharing.Mode == config.ROUTE_TABLE_SHARING_OWNER {
+			owner := routeshare.NewOwner(g.Registry, c.RouteTableSharing.Socket, lggr.Session("route-table-owner"))
+			g.members = append(g.members, grouper.Member{Name: "route-table-owner", Runner: owner})
+			syncRegistry = owner
+		}
+
+		if c.RoutingApiEnabled() {
+			routeFetcher := setupRouteFetcher(lggr.Session("route-fetcher"), c, registry.NewAuditedRegistry(syncRegistry, audit.SourceRoutingAPI, auditLogger), g.Registry, routingAPIClient)
+			g.members = append(g.members, grouper.Member{Name: "router-fetcher", Runner: routeFetcher})
+		}
+
+		if c.StaticRoutes.File != "" {
+			watcher := staticroutes.NewWatcher(registry.NewAuditedRegistry(syncRegistry, audit.SourceStaticRoutes, auditLogger),
+				c.StaticRoutes.File, c.StaticRoutes.PollInterval, lggr.Session("static-routes"))
+			g.members = append(g.members, grouper.Member{Name: "static-routes", Runner: watcher})
+		}
+
+		if c.Kubernetes.Enabled {
+			client, err := kubernetes.NewClient(c.Kubernetes.APIServer, c.Kubernetes.TokenFile, c.Kubernetes.CAFile)
+			if err != nil {
+				lggr.Error("kubernetes-client-error", zap.Error(err))
+				return nil, err
+			}
+			registrar := kubernetes.NewRegistrar(client, registry.NewAuditedRegistry(syncRegistry, audit.SourceKubernetes, auditLogger),
+				c.Kubernetes.Namespace, c.Kubernetes.ResyncInterval, c.Kubernetes.RetryInterval, lggr.Session("kubernetes-registrar"))
+			g.members = append(g.members, grouper.Member{Name: "kubernetes-registrar", Runner: registrar})
+		}
+
+		if c.Consul.Enabled {
+			registrar := consul.NewRegistrar(
+				consul.NewClient(c.Consul.Address, c.Consul.Token, c.Consul.Datacenter),
+				registry.NewAuditedRegistry(syncRegistry, audit.SourceConsul, auditLogger),
+				consul.RegistrarOpts{
+					RouteTemplate:  c.Consul.RouteTemplate,
+					Domain:         c.Consul.Domain,
+					Tag:            c.Consul.Tag,
+					PassingOnly:    c.Consul.PassingOnly,
+					ResyncInterval: c.Consul.ResyncInterval,
+					RetryInterval:  c.Consul.RetryInterval,
+				},
+				lggr.Session("consul-registrar"),
+			)
+			g.members = append(g.members, grouper.Member{Name: "consul-registrar", Runner: registrar})
+		}
+
+		subscriber := createSubscriber(lggr.Session("mbus"), c, natsClient, registry.NewAuditedRegistry(syncRegistry, audit.SourceNATS, auditLogger), o.registryReporter, startMsgChan)
+		g.members = append(g.members, grouper.Member{Name: "subscriber", Runner: subscriber})
+	}
+
+	if c.Xds.Enabled {
+		xdsServer := xds.NewServer(g.Registry, c.Xds.Address, c.Xds.RefreshInterval,
+			c.Xds.RouteConfigName, c.Xds.ConnectTimeout, lggr.Session("xds-server"))
+		g.members = append(g.members, grouper.Member{Name: "xds-server", Runner: xdsServer})
+	}
+
+	g.members = append(g.members, grouper.Member{Name: "router", Runner: g.Router})
+
+	built = true
+	return g, nil
+}
+
+// startExternalPlugins starts the plugin processes in external_plugins and
+// registers them as middleware plugins
+func startExternalPlugins(lggr logger.Logger, c *config.Config, reporter metrics.PluginReporter) ([]*external.Plugin, error) {
+	var plugins []*external.Plugin
+	for _, cfg := range c.ExternalPlugins {
+		p, err := external.Start(cfg, reporter, lggr.Session("external-plugin"))
+		if err == nil {
+			err = middleware.Register(p)
+			if err != nil {
+				p.Kill()
+			}
+		}
+		if err != nil {
+			lggr.Error("external-plugin-failed-to-start", zap.String("plugin", cfg.Name), zap.Error(err))
+			for _, started := range plugins {
+				started.Kill()
+			}
+			return nil, err
+		}
+		plugins = append(plugins, p)
+	}
+	return plugins, nil
+}
+
+func newAnomalyDetector(lggr logger.Logger, c config.AnomalyDetectionConfig, proxyReporter metrics.ProxyReporter) *metrics.AnomalyDetector {
+	reporter, _ := proxyReporter.(metrics.AnomalyReporter)
+	return metrics.NewAnomalyDetector(c.Window, c.BaselineWindows, c.Sigma, c.MinRequests, c.MaxRoutes,
+		func(a metrics.Anomaly) {
+			lggr.Warn("route-error-rate-anomaly",
+				zap.String("route", a.Route),
+				zap.Uint64("requests", a.Requests),
+				zap.Uint64("errors", a.Errors),
+				zap.Float64("error-rate", a.ErrorRate),
+				zap.Float64("baseline", a.Baseline),
+				zap.Float64("stddev", a.StdDev),
+			)
+			if reporter != nil {
+				reporter.CaptureRouteErrorAnomaly()
+			}
+		},
+	)
+}
+
+func newAuthFailureAggregator(lggr logger.Logger, c config.AuthFailureMetricsConfig, proxyReporter metrics.ProxyReporter) *metrics.AuthFailureAggregator {
+	reporter, _ := proxyReporter.(metrics.AuthFailureReporter)
+	return metrics.NewAuthFailureAggregator(c.Window, c.Threshold, c.MaxRoutes,
+		func(a metrics.AuthFailureAlert) {
+			lggr.Warn("route-auth-failure-threshold-exceeded",
+				zap.String("route", a.Route),
+				zap.Uint64("failures", a.Failures),
+				zap.Duration("window", c.Window),
+			)
+			if reporter != nil {
+				reporter.CaptureAuthFailureThresholdExceeded()
+			}
+		},
+	)
+}
+
+type nopPluginReporter struct{}
+
+func (nopPluginReporter) CapturePluginCall(string, time.Duration) {}
+func (nopPluginReporter) CapturePluginFailure(string)             {}
+func (nopPluginReporter) CapturePluginHealth(string, bool)        {}
+
+type nopOCSPReporter struct{}
+
+func (nopOCSPReporter) CaptureOCSPStapleValidity(time.Duration) {}
+func (nopOCSPReporter) CaptureOCSPFetchFailure()                {}
+
+type nopRequestValidationReporter struct{}
+
+func (nopRequestValidationReporter) CaptureInvalidRequest(string, bool) {}
+
+type nopAcceptReporter struct{}
+
+func (nopAcceptReporter) CaptureAccept(string)            {}
+func (nopAcceptReporter) CaptureAcceptError(string, bool) {}
+
+type nopConnectionLimitReporter struct{}
+
+func (nopConnectionLimitReporter) CaptureConnectionLimited(string) {}
+
+type nopIngressReporter struct{}
+
+func (nopIngressReporter) CaptureIngressRejected(string, string) {}
+
+// Runner returns the router's components as a single ifrit runner. Signals
+// sent to it reach the router itself, so SIGUSR1 drains as usual.
+func (g *Gorouter) Runner() ifrit.Runner {
+	return grouper.NewOrdered(os.Interrupt, g.members)
+}
+
+// Run runs the router until ctx is done or one of its components exits
+func (g *Gorouter) Run(ctx context.Context) error {
+	process := ifrit.Invoke(g.Runner())
+
+	select {
+	case err := <-process.Wait():
+		return err
+	case <-ctx.Done():
+		process.Signal(os.Interrupt)
+		return <-process.Wait()
+	}
+}
@@ -0,0 +1,128 @@
+package router_test
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"net"
+	"net/http"
+	"time"
+
+	"code.cloudfoundry.org/gorouter/access_log"
+	cfg "code.cloudfoundry.org/gorouter/config"
+	fakeMetrics "code.cloudfoundry.org/gorouter/metrics/fakes"
+	"code.cloudfoundry.org/gorouter/route"
+	. "code.cloudfoundry.org/gorouter/router"
+	testcommon "code.cloudfoundry.org/gorouter/test/common"
+	"code.cloudfoundry.org/gorouter/test_util"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/urfave/negroni"
+)
+
+var _ = Describe("Gorouter", func() {
+	var (
+		natsRunner *test_util.NATSRunner
+		natsPort   uint16
+		config     *cfg.Config
+		gorouter   *Gorouter
+		cancel     context.CancelFunc
+		done       chan error
+	)
+
+	BeforeEach(func() {
+		natsPort = test_util.NextAvailPort()
+		config = test_util.SpecConfig(test_util.NextAvailPort(), test_util.NextAvailPort(), natsPort)
+
+		natsRunner = test_util.NewNATSRunner(int(natsPort))
+		natsRunner.Start()
+
+		extension := negroni.HandlerFunc(func(rw http.ResponseWriter, r *http.Request, next http.HandlerFunc) {
+			rw.Header().Set("X-Extension", "yes")
+			next(rw, r)
+		})
+
+		var err error
+		gorouter, err = New(config,
+			WithLogger(test_util.NewTestZapLogger("gorouter-test")),
+			WithReporters(new(fakeMetrics.FakeRouteRegistryReporter), new(fakeMetrics.FakeProxyReporter)),
+			WithAccessLogger(&access_log.NullAccessLogger{}),
+			WithHandlers(extension),
+		)
+		Expect(err).NotTo(HaveOccurred())
+
+		var ctx context.Context
+		ctx, cancel = context.WithCancel(context.Background())
+		done = make(chan error, 1)
+		go func() {
+			done <- gorouter.Run(ctx)
+		}()
+	})
+
+	AfterEach(func() {
+		if done != nil {
+			cancel()
+			Eventually(done, 5*time.Second).Should(Receive())
+		}
+		natsRunner.Stop()
+	})
+
+	It("routes registration messages and runs the handler extensions", func() {
+		app := testcommon.NewTestApp([]route.Uri{"library.vcap.me"}, config.Port, natsRunner.MessageBus, nil, "")
+		app.AddHandler("/", func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusOK)
+		})
+		app.Listen()
+
+		Eventually(func() bool {
+			app.Register()
+			return gorouter.Registry.Lookup("library.vcap.me") != nil
+		}).Should(BeTrue())
+
+		req, err := http.NewRequest("GET", fmt.Sprintf("http://127.0.0.1:%d/", config.Port), nil)
+		Expect(err).NotTo(HaveOccurred())
+		req.Host = "library.vcap.me"
+
+		Eventually(func() (int, error) {
+			resp, err := http.DefaultClient.Do(req)
+			if err != nil {
+				return 0, err
+			}
+			resp.Body.Close()
+			Expect(resp.Header.Get("X-Extension")).To(Equal("yes"))
+			return resp.StatusCode, nil
+		}).Should(Equal(http.StatusOK))
+	})
+
+	It("stops when the context is done", func() {
+		cancel()
+
+		var err error
+		Eventually(done, 5*time.Second).Should(Receive(&err))
+		Expect(err).NotTo(HaveOccurred())
+		done = nil
+	})
+
+	It("releases the status port when it fails", func() {
+		cert, err := tls.LoadX509KeyPair("../test/assets/certs/server.pem", "../test/assets/certs/server.key")
+		Expect(err).NotTo(HaveOccurred())
+
+		failing := test_util.SpecConfig(test_util.NextAvailPort(), test_util.NextAvailPort(), natsPort)
+		failing.EnableSSL = true
+		failing.SSLPort = test_util.NextAvailPort()
+		failing.SSLCertificate = cert
+		failing.TLSSessions.TicketKeysFile = "/nonexistent/ticket-keys"
+
+		_, err = New(failing,
+			WithLogger(test_util.NewTestZapLogger("gorouter-test")),
+			WithReporters(new(fakeMetrics.FakeRouteRegistryReporter), new(fakeMetrics.FakeProxyReporter)),
+			WithAccessLogger(&access_log.NullAccessLogger{}),
+		)
+		Expect(err).To(HaveOccurred())
+
+		listener, err := net.Listen("tcp", fmt.Sprintf("127.0.0.1:%d", failing.Status.Port))
+		Expect(err).NotTo(HaveOccurred())
+		listener.Close()
+	})
+})
@@ -10,6 +10,7 @@ import (
 
 	"bytes"
 	"compress/zlib"
+	"context"
 	"crypto/tls"
 	"encoding/json"
 	"errors"
@@ -18,15 +19,23 @@ import (
 	"net/http"
 	"time"
 
+	"code.cloudfoundry.org/gorouter/accept"
 	"code.cloudfoundry.org/gorouter/common"
 	"code.cloudfoundry.org/gorouter/common/health"
 	"code.cloudfoundry.org/gorouter/common/schema"
 	"code.cloudfoundry.org/gorouter/config"
+	"code.cloudfoundry.org/gorouter/connlimit"
 	"code.cloudfoundry.org/gorouter/handlers"
+	"code.cloudfoundry.org/gorouter/ingress"
 	"code.cloudfoundry.org/gorouter/logger"
+	"code.cloudfoundry.org/gorouter/metrics"
 	"code.cloudfoundry.org/gorouter/metrics/monitor"
+	"code.cloudfoundry.org/gorouter/ocspstaple"
 	"code.cloudfoundry.org/gorouter/proxy"
 	"code.cloudfoundry.org/gorouter/registry"
+	"code.cloudfoundry.org/gorouter/reqctx"
+	"code.cloudfoundry.org/gorouter/sessiontickets"
+	"code.cloudfoundry.org/gorouter/smuggling"
 	"code.cloudfoundry.org/gorouter/varz"
 	"github.com/armon/go-proxyproto"
 	"github.com/cloudfoundry/dropsonde"
@@ -53,6 +62,15 @@ type Router struct {
 
 	listener         net.Listener
 	tlsListener      net.Listener
+	internalListener net.Listener
+	ticketKeys       *sessiontickets.KeyRing
+	stapler          *ocspstaple.Stapler
+	tlsReporter      metrics.TLSReporter
+	validation       metrics.RequestValidationReporter
+	connLimiter      *connlimit.Limiter
+	ingress          *ingress.Verifier
+	scavenger        *connScavenger
+	acceptReporter   metrics.AcceptReporter
 	closeConnections bool
 	connLock         sync.Mutex
 	idleConns        map[net.Conn]struct{}
@@ -60,6 +78,7 @@ type Router struct {
 	drainDone        chan struct{}
 	serveDone        chan struct{}
 	tlsServeDone     chan struct{}
+	internalDone     chan struct{}
 	stopping         bool
 	stopLock         sync.Mutex
 	uptimeMonitor    *monitor.Uptime
@@ -96,7 +115,10 @@ func NewRouter(logger logger.Logger, cfg *config.Config, p proxy.Proxy, mbusClie
 		Healthz: healthz,
 		Health:  health,
 		InfoRoutes: map[string]json.Marshaler{
-			"/routes": r,
+			"/routes":           registry.NewRoutesReport(r),
+			"/routes/endpoints": registry.NewActivityReport(r),
+			"/routes/memory":    registry.NewMemoryReport(r),
+			"/routes/pruning":   registry.NewPruningReport(r),
 		},
 		Logger: logger,
 	}
@@ -115,14 +137,18 @@ func NewRouter(logger logger.Logger, cfg *config.Config, p proxy.Proxy, mbusClie
 		component:    component,
 		serveDone:    make(chan struct{}),
 		tlsServeDone: make(chan struct{}),
+		internalDone: make(chan struct{}),
 		idleConns:    make(map[net.Conn]struct{}),
 		activeConns:  make(map[net.Conn]struct{}),
 		logger:       logger,
 		errChan:      routerErrChan,
+		scavenger:    newConnScavenger(cfg.ConnScavenger, logger.Session("conn-scavenger")),
 		HeartbeatOK:  heartbeatOK,
 		stopping:     false,
 	}
 
+	router.AddStatusHandler("/connections", router.scavenger)
+
 	if err := router.component.Start(); err != nil {
 		return nil, err
 	}
@@ -131,13 +157,77 @@ func NewRouter(logger logger.Logger, cfg *config.Config, p proxy.Proxy, mbusClie
 	return router, nil
 }
 
+// AddStatusHandler serves handler on the status server under path, behind
+// the status credentials. It must be called before the router runs.
+func (r *Router) AddStatusHandler(path string, handler http.Handler) {
+	if r.component.Handlers == nil {
+		r.component.Handlers = make(map[string]http.Handler)
+	}
+	r.component.Handlers[path] = handler
+}
+
 type gorouterHandler struct {
 	handler http.Handler
 	logger  logger.Logger
+	// listener is the visibility scope of the listener served
+	listener string
 }
 
+var connKey = reqctx.NewKey("Conn")
+
 func (h *gorouterHandler) ServeHTTP(res http.ResponseWriter, req *http.Request) {
-	h.handler.ServeHTTP(res, req)
+	conn, _ := connKey.Value(req.Context()).(net.Conn)
+	if req.TLS == nil {
+		// the server cannot see TLS conns wrapped for request validation
+		if conn, ok := conn.(*smuggling.Conn); ok {
+			if state, ok := conn.TLSConnectionState(); ok {
+				req.TLS = &state
+			}
+		}
+	}
+	if limited, ok := limitedConn(conn); ok && !limited.Admit(req) {
+		limited.Reject(res)
+		return
+	}
+	h.handler.ServeHTTP(res, handlers.WithListener(req, h.listener))
+}
+
+// unwrapConn returns the conn wrapped by one of the conns the router's
+// listeners return, or nil
+func unwrapConn(conn net.Conn) net.Conn {
+	switch c := conn.(type) {
+	case *smuggling.Conn:
+		return c.Conn
+	case *tls.Conn:
+		return c.NetConn()
+	case *trackedConn:
+		return c.Conn
+	case *connlimit.Conn:
+		return c.Conn
+	}
+	return nil
+}
+
+// limitedConn returns the conn counted by the connection limiter beneath the
+// conns wrapping it
+func limitedConn(conn net.Conn) (*connlimit.Conn, bool) {
+	for ; conn != nil; conn = unwrapConn(conn) {
+		if c, ok := conn.(*connlimit.Conn); ok {
+			return c, true
+		}
+	}
+	return nil, false
+}
+
+// trackedConnOf returns the conn tracked by the scavenger beneath the conns
+// wrapping it, or nil
+func trackedConnOf(conn net.Conn) *trackedConn {
+	for ; conn != nil; conn = unwrapConn(conn) {
+		if c, ok := conn.(*trackedConn); ok {
+			return c
+		}
+	}
+	return nil
 }
 
 func (r *Router) Run(signals <-chan os.Signal, ready chan<- struct{}) error {
@@ -171,12 +261,21 @@ func (r *Router) Run(signals <-chan os.Signal, ready chan<- struct{}) error {
 
 	r.logger.Info("completed-wait")
 
-	handler := gorouterHandler{handler: dropsonde.InstrumentedHandler(r.proxy), logger: r.logger}
+	handler := gorouterHandler{
+		handler:  dropsonde.InstrumentedHandler(r.proxy),
+		logger:   r.logger,
+		listener: config.ROUTE_VISIBILITY_EXTERNAL,
+	}
 
 	server := &http.Server{
 		Handler:   &handler,
 		ConnState: r.HandleConnState,
 	}
+	if r.config.RequestValidation.Level != config.REQUEST_VALIDATION_OFF || r.connLimiter != nil {
+		server.ConnContext = func(ctx context.Context, conn net.Conn) context.Context {
+			return connKey.With(ctx, conn)
+		}
+	}
 
 	err := r.serveHTTP(server, r.errChan)
 	if err != nil {
@@ -188,6 +287,11 @@ func (r *Router) Run(signals <-chan os.Signal, ready chan<- struct{}) error {
 		r.errChan <- err
 		return err
 	}
+	err = r.serveInternal(server, r.errChan)
+	if err != nil {
+		r.errChan <- err
+		return err
+	}
 
 	// create pid file
 	err = r.writePidFile(r.config.PidFile)
@@ -197,6 +301,7 @@ func (r *Router) Run(signals <-chan os.Signal, ready chan<- struct{}) error {
 
 	r.logger.Info("gorouter.started")
 	go r.uptimeMonitor.Start()
+	go r.scavenger.Start()
 
 	close(ready)
 
@@ -259,8 +364,26 @@ func (r *Router) serveHTTPS(server *http.Server, errChan chan error) error {
 	if r.config.EnableSSL {
 		tlsConfig := &tls.Config{
 			Certificates: []tls.Certificate{r.config.SSLCertificate},
-			CipherSuites: r.config.CipherSuites,
 			MinVersion:   tls.VersionTLS12,
+
+			SessionTicketsDisabled: r.config.TLSSessions.DisableTickets,
+		}
+		r.config.TLSPolicyFor(config.TLSListenerFrontend).Apply(tlsConfig)
+		if r.tlsReporter != nil {
+			tlsConfig.VerifyConnection = func(state tls.ConnectionState) error {
+				r.tlsReporter.CaptureTLSHandshake(config.TLSListenerFrontend, state.Version, state.CipherSuite)
+				return nil
+			}
+		}
+		if r.ticketKeys != nil {
+			r.ticketKeys.Install(tlsConfig)
+		}
+		if r.ingress != nil {
+			r.ingress.ConfigureTLS(tlsConfig, "https")
+		}
+		if r.stapler != nil {
+			tlsConfig.Certificates = nil
+			tlsConfig.GetCertificate = r.stapler.GetCertificate
 		}
 
 		listener, err := net.Listen("tcp", fmt.Sprintf(":%d", r.config.SSLPort))
@@ -268,6 +391,7 @@ func (r *Router) serveHTTPS(server *http.Server, errChan chan error) error {
 			r.logger.Fatal("tcp-listener-error", zap.Error(err))
 			return err
 		}
+		listener = r.verifyIngress(r.acceptConns(listener, "https"), "https")
 
 		if r.config.EnablePROXY {
 			listener = &proxyproto.Listener{
@@ -276,7 +400,7 @@ func (r *Router) serveHTTPS(server *http.Server, errChan chan error) error {
 			}
 		}
 
-		r.tlsListener = tls.NewListener(listener, tlsConfig)
+		r.tlsListener = r.validateRequests(tls.NewListener(r.trackConns(r.limitConns(listener)), tlsConfig))
 
 		r.logger.Info("tls-listener-started", zap.Object("address", r.tlsListener.Addr()))
 
@@ -299,6 +423,7 @@ func (r *Router) serveHTTP(server *http.Server, errChan chan error) error {
 		r.logger.Fatal("tcp-listener-error", zap.Error(err))
 		return err
 	}
+	listener = r.verifyIngress(r.acceptConns(listener, "http"), "http")
 
 	r.listener = listener
 	if r.config.EnablePROXY {
@@ -307,6 +432,7 @@ func (r *Router) serveHTTP(server *http.Server, errChan chan error) error {
 			ProxyHeaderTimeout: proxyProtocolHeaderTimeout,
 		}
 	}
+	r.listener = r.validateRequests(r.trackConns(r.limitConns(r.listener)))
 
 	r.logger.Info("tcp-listener-started", zap.Object("address", r.listener.Addr()))
 
@@ -323,6 +449,96 @@ func (r *Router) serveHTTP(server *http.Server, errChan chan error) error {
 	return nil
 }
 
+// serveInternal serves every route, internal ones included, on the internal
+// listener when one is configured
+func (r *Router) serveInternal(server *http.Server, errChan chan error) error {
+	if r.config.RouteVisibility.InternalPort == 0 {
+		return nil
+	}
+
+	listener, err := net.Listen("tcp", fmt.Sprintf(":%d", r.config.RouteVisibility.InternalPort))
+	if err != nil {
+		r.logger.Fatal("tcp-listener-error", zap.Error(err))
+		return err
+	}
+	listener = r.acceptConns(listener, "internal")
+
+	r.internalListener = listener
+	if r.config.EnablePROXY {
+		r.internalListener = &proxyproto.Listener{
+			Listener:           listener,
+			ProxyHeaderTimeout: proxyProtocolHeaderTimeout,
+		}
+	}
+	r.internalListener = r.validateRequests(r.trackConns(r.limitConns(r.internalListener)))
+
+	r.logger.Info("internal-listener-started", zap.Object("address", r.internalListener.Addr()))
+
+	internalServer := &http.Server{
+		Handler: &gorouterHandler{
+			handler:  server.Handler.(*gorouterHandler).handler,
+			logger:   r.logger,
+			listener: config.ROUTE_VISIBILITY_INTERNAL,
+		},
+		ConnState:   server.ConnState,
+		ConnContext: server.ConnContext,
+	}
+
+	go func() {
+		err := internalServer.Serve(r.internalListener)
+		r.stopLock.Lock()
+		if !r.stopping {
+			errChan <- err
+		}
+		r.stopLock.Unlock()
+		close(r.internalDone)
+	}()
+	return nil
+}
+
+// validateRequests checks the framing of the requests read from the conns
+// listener accepts, at the configured request validation level
+func (r *Router) validateRequests(listener net.Listener) net.Listener {
+	reporter := r.validation
+	if reporter == nil {
+		reporter = nopRequestValidationReporter{}
+	}
+	return smuggling.NewListener(listener, r.config.RequestValidation.Level, reporter, r.logger.Session("request-validation"))
+}
+
+// acceptConns accepts the conns of listener with the configured pool of
+// workers
+func (r *Router) acceptConns(listener net.Listener, name string) net.Listener {
+	reporter := r.acceptReporter
+	if reporter == nil {
+		reporter = nopAcceptReporter{}
+	}
+	return accept.NewListener(listener, name, r.config.Accept, reporter, r.logger.Session("accept"))
+}
+
+// verifyIngress closes the conns listener accepts from peers other than the
+// trusted load balancers, when trusted ingress is configured
+func (r *Router) verifyIngress(listener net.Listener, name string) net.Listener {
+	if r.ingress == nil {
+		return listener
+	}
+	return r.ingress.Listener(listener, name)
+}
+
+// trackConns hands the conns listener accepts to the scavenger
+func (r *Router) trackConns(listener net.Listener) net.Listener {
+	return r.scavenger.listener(listener)
+}
+
+// limitConns caps the conns listener accepts from each client IP when
+// connection limits are configured
+func (r *Router) limitConns(listener net.Listener) net.Listener {
+	if r.connLimiter == nil {
+		return listener
+	}
+	return r.connLimiter.Listener(listener)
+}
+
 func (r *Router) Drain(drainWait, drainTimeout time.Duration) error {
 	atomic.StoreInt32(r.HeartbeatOK, 0)
 
@@ -337,6 +553,7 @@ func (r *Router) Drain(drainWait, drainTimeout time.Duration) error {
 	r.logger.Info(fmt.Sprintf("Draining with %d outstanding active connections", len(r.activeConns)))
 	r.logger.Info(fmt.Sprintf("Draining with %d outstanding idle connections", len(r.idleConns)))
 	r.closeIdleConns()
+	r.scavenger.setDraining()
 
 	if len(r.activeConns) == 0 {
 		close(drained)
@@ -369,6 +586,8 @@ func (r *Router) Stop() {
 
 	r.component.Stop()
 	r.uptimeMonitor.Stop()
+	r.scavenger.Stop()
+	r.registry.StopPruningCycle()
 	r.logger.Info(
 		"gorouter.stopped",
 		zap.Duration("took", time.Since(stoppingAt)),
@@ -396,6 +615,11 @@ func (r *Router) stopListening() {
 		<-r.tlsServeDone
 	}
 
+	if r.internalListener != nil {
+		r.internalListener.Close()
+		<-r.internalDone
+	}
+
 	<-r.serveDone
 }
 
@@ -425,6 +649,7 @@ func (r *Router) ScheduleFlushApps() {
 
 func (r *Router) HandleConnState(conn net.Conn, state http.ConnState) {
 	endpointTimeout := r.config.EndpointTimeout
+	r.scavenger.setState(conn, state)
 
 	r.connLock.Lock()
 
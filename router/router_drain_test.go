@@ -220,7 +220,7 @@ var _ = Describe("Router", func() {
 		combinedReporter = metrics.NewCompositeReporter(varz, metricReporter)
 		config.HealthCheckUserAgent = "HTTP-Monitor/1.1"
 		p = proxy.NewProxy(logger, &access_log.NullAccessLogger{}, config, registry, combinedReporter,
-			&routeservice.RouteServiceConfig{}, &tls.Config{}, &healthCheck)
+			&routeservice.RouteServiceConfig{}, &tls.Config{}, &healthCheck, nil, nil)
 
 		errChan := make(chan error, 2)
 		rtr, err = router.NewRouter(logger, config, p, mbusClient, registry, varz, &healthCheck, logcounter, errChan)
@@ -231,7 +231,7 @@ var _ = Describe("Router", func() {
 			MinimumRegisterIntervalInSeconds: int(config.StartResponseDelayInterval.Seconds()),
 			PruneThresholdInSeconds:          int(config.DropletStaleThreshold.Seconds()),
 		}
-		subscriber = ifrit.Background(mbus.NewSubscriber(logger.Session("subscriber"), mbusClient, registry, nil, opts))
+		subscriber = ifrit.Background(mbus.NewSubscriber(logger.Session("subscriber"), mbusClient, registry, new(fakeMetrics.FakeRouteRegistryReporter), nil, opts))
 		<-subscriber.Ready()
 	})
 
@@ -595,7 +595,7 @@ var _ = Describe("Router", func() {
 				healthCheck = 0
 				config.HealthCheckUserAgent = "HTTP-Monitor/1.1"
 				proxy := proxy.NewProxy(logger, &access_log.NullAccessLogger{}, config, registry, combinedReporter,
-					&routeservice.RouteServiceConfig{}, &tls.Config{}, &healthCheck)
+					&routeservice.RouteServiceConfig{}, &tls.Config{}, &healthCheck, nil, nil)
 
 				errChan = make(chan error, 2)
 				var err error
@@ -6,6 +6,7 @@ import (
 	"crypto/tls"
 	"encoding/json"
 	"fmt"
+	"io"
 	"io/ioutil"
 	"net"
 	"net/http"
@@ -93,7 +94,7 @@ var _ = Describe("Router", func() {
 		combinedReporter := metrics.NewCompositeReporter(varz, metricReporter)
 
 		proxy := proxy.NewProxy(logger, &access_log.NullAccessLogger{}, config, registry, combinedReporter,
-			&routeservice.RouteServiceConfig{}, &tls.Config{}, nil)
+			&routeservice.RouteServiceConfig{}, &tls.Config{}, nil, nil, nil)
 
 		var healthCheck int32
 		healthCheck = 0
@@ -107,7 +108,7 @@ var _ = Describe("Router", func() {
 			MinimumRegisterIntervalInSeconds: int(config.StartResponseDelayInterval.Seconds()),
 			PruneThresholdInSeconds:          int(config.DropletStaleThreshold.Seconds()),
 		}
-		subscriber := mbus.NewSubscriber(logger.Session("subscriber"), mbusClient, registry, nil, opts)
+		subscriber := mbus.NewSubscriber(logger.Session("subscriber"), mbusClient, registry, new(fakeMetrics.FakeRouteRegistryReporter), nil, opts)
 
 		members := grouper.Members{
 			{Name: "subscriber", Runner: subscriber},
@@ -467,6 +468,49 @@ var _ = Describe("Router", func() {
 		Expect(string(body)).To(MatchRegexp(".*1\\.2\\.3\\.4:1234.*\n"))
 	})
 
+	It("handles a /routes/memory request", func() {
+		err := mbusClient.Publish("router.register",
+			[]byte(`{"app":"app1","uris":["app.test.com"],"host":"1.2.3.4","port":1234,"tags":{}}`))
+		Expect(err).ToNot(HaveOccurred())
+		time.Sleep(250 * time.Millisecond)
+
+		host := fmt.Sprintf("http://%s:%d/routes/memory", config.Ip, config.Status.Port)
+		req, err := http.NewRequest("GET", host, nil)
+		Expect(err).ToNot(HaveOccurred())
+		req.SetBasicAuth("user", "pass")
+
+		resp, err := http.DefaultClient.Do(req)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(resp.StatusCode).To(Equal(200))
+
+		var report struct {
+			Total   rregistry.MemoryUsage            `json:"total"`
+			Domains map[string]rregistry.MemoryUsage `json:"domains"`
+		}
+		defer resp.Body.Close()
+		Expect(json.NewDecoder(resp.Body).Decode(&report)).To(Succeed())
+		Expect(report.Total.Endpoints).To(BeNumerically(">=", 1))
+		Expect(report.Domains).To(HaveKey("test.com"))
+	})
+
+	It("handles a /routes/pruning request", func() {
+		host := fmt.Sprintf("http://%s:%d/routes/pruning", config.Ip, config.Status.Port)
+		req, err := http.NewRequest("GET", host, nil)
+		Expect(err).ToNot(HaveOccurred())
+		req.SetBasicAuth("user", "pass")
+
+		resp, err := http.DefaultClient.Do(req)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(resp.StatusCode).To(Equal(200))
+
+		var state rregistry.PruningState
+		defer resp.Body.Close()
+		Expect(json.NewDecoder(resp.Body).Decode(&state)).To(Succeed())
+		Expect(state.Running).To(BeTrue())
+		Expect(state.Suspended).To(BeFalse())
+		Expect(state.LastPrune).NotTo(BeZero())
+	})
+
 	Context("when proxy proto is enabled", func() {
 		BeforeEach(func() {
 			config.EnablePROXY = true
@@ -722,6 +766,143 @@ var _ = Describe("Router", func() {
 		})
 	})
 
+	Context("connection scavenger", func() {
+		BeforeEach(func() {
+			config.ConnScavenger = cfg.ConnScavengerConfig{
+				MaxIdle:  100 * time.Millisecond,
+				Interval: 10 * time.Millisecond,
+			}
+		})
+
+		connections := func() ConnReport {
+			req, err := http.NewRequest("GET", fmt.Sprintf("http://%s:%d/connections", config.Ip, config.Status.Port), nil)
+			Expect(err).ToNot(HaveOccurred())
+			req.SetBasicAuth("user", "pass")
+
+			resp, err := http.DefaultClient.Do(req)
+			Expect(err).ToNot(HaveOccurred())
+			defer resp.Body.Close()
+			Expect(resp.StatusCode).To(Equal(http.StatusOK))
+
+			var report ConnReport
+			Expect(json.NewDecoder(resp.Body).Decode(&report)).To(Succeed())
+			return report
+		}
+
+		expectClosed := func(conn net.Conn) {
+			conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+			_, err := conn.Read(make([]byte, 1))
+			Expect(err).To(Equal(io.EOF))
+		}
+
+		It("closes connections idle for longer than max_idle", func() {
+			dialed := time.Now()
+			conn, err := net.Dial("tcp", fmt.Sprintf("127.0.0.1:%d", config.Port))
+			Expect(err).ToNot(HaveOccurred())
+			defer conn.Close()
+
+			Eventually(func() int { return connections().States[ConnStateNew] }).Should(Equal(1))
+			expectClosed(conn)
+			Expect(time.Since(dialed)).To(BeNumerically(">=", 100*time.Millisecond))
+			Expect(time.Since(dialed)).To(BeNumerically("<", config.EndpointTimeout))
+			Eventually(func() int { return connections().Total }).Should(Equal(0))
+		})
+
+		Context("with max_lifetime", func() {
+			BeforeEach(func() {
+				config.ConnScavenger.MaxIdle = 0
+				config.ConnScavenger.MaxLifetime = 300 * time.Millisecond
+			})
+
+			It("closes keep-alive connections older than max_lifetime once idle", func() {
+				app := test.NewGreetApp([]route.Uri{"lifetime.vcap.me"}, config.Port, mbusClient, nil)
+				app.Listen()
+				Eventually(func() bool {
+					return appRegistered(registry, app)
+				}).Should(BeTrue())
+
+				dialed := time.Now()
+				conn, err := net.Dial("tcp", fmt.Sprintf("lifetime.vcap.me:%d", config.Port))
+				Expect(err).ToNot(HaveOccurred())
+				defer conn.Close()
+				x := test_util.NewHttpConn(conn)
+
+				for time.Since(dialed) < 200*time.Millisecond {
+					x.WriteRequest(test_util.NewRequest("GET", "lifetime.vcap.me", "/", nil))
+					resp, _ := x.ReadResponse()
+					Expect(resp.StatusCode).To(Equal(http.StatusOK))
+					time.Sleep(50 * time.Millisecond)
+				}
+
+				expectClosed(conn)
+				Expect(time.Since(dialed)).To(BeNumerically(">=", 300*time.Millisecond))
+			})
+		})
+
+		It("counts websockets and leaves them open", func() {
+			app := test.NewWebSocketApp([]route.Uri{"ws-app.vcap.me"}, config.Port, mbusClient, 0)
+			app.Listen()
+			Eventually(func() bool {
+				return appRegistered(registry, app)
+			}).Should(BeTrue())
+
+			conn, err := net.Dial("tcp", fmt.Sprintf("ws-app.vcap.me:%d", config.Port))
+			Expect(err).NotTo(HaveOccurred())
+			x := test_util.NewHttpConn(conn)
+
+			req := test_util.NewRequest("GET", "ws-app.vcap.me", "/chat", nil)
+			req.Header.Set("Upgrade", "websocket")
+			req.Header.Set("Connection", "upgrade")
+			x.WriteRequest(req)
+			resp, _ := x.ReadResponse()
+			Expect(resp.StatusCode).To(Equal(http.StatusSwitchingProtocols))
+
+			Expect(connections().States[ConnStateWebSocket]).To(Equal(1))
+			time.Sleep(200 * time.Millisecond)
+			x.WriteLine("hello from client")
+			x.CheckLine("hello from server")
+
+			x.Close()
+			Eventually(func() int { return connections().Total }).Should(Equal(0))
+		})
+
+		Context("when draining", func() {
+			BeforeEach(func() {
+				config.EndpointTimeout = 5 * time.Second
+			})
+
+			It("reports the active connections as draining", func() {
+				app := testcommon.NewTestApp([]route.Uri{"drain.vcap.me"}, config.Port, mbusClient, nil, "")
+				blocker := make(chan struct{})
+				app.AddHandler("/", func(w http.ResponseWriter, r *http.Request) {
+					<-blocker
+				})
+				app.Listen()
+				Eventually(func() bool {
+					return appRegistered(registry, app)
+				}).Should(BeTrue())
+
+				conn, err := net.Dial("tcp", fmt.Sprintf("drain.vcap.me:%d", config.Port))
+				Expect(err).ToNot(HaveOccurred())
+				defer conn.Close()
+				x := test_util.NewHttpConn(conn)
+				x.WriteRequest(test_util.NewRequest("GET", "drain.vcap.me", "/", nil))
+				Eventually(func() int { return connections().States[ConnStateActive] }).Should(Equal(1))
+
+				drained := make(chan error, 1)
+				go func() {
+					drained <- router.Drain(0, 5*time.Second)
+				}()
+
+				Eventually(func() int { return connections().States[ConnStateDraining] }).Should(Equal(1))
+				Expect(connections().States[ConnStateActive]).To(Equal(0))
+
+				close(blocker)
+				Eventually(drained).Should(Receive(BeNil()))
+			})
+		})
+	})
+
 	Context("multiple open connections", func() {
 		It("does not return an error handling connections", func() {
 			app := testcommon.NewTestApp([]route.Uri{"app.vcap.me"}, config.Port, mbusClient, nil, "")
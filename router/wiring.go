@@ -0,0 +1,301 @@
+package router
+
+import (
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"net/url"
+	"sync/atomic"
+	"time"
+
+	"code.cloudfoundry.org/clock"
+	"code.cloudfoundry.org/gorouter/access_log"
+	"code.cloudfoundry.org/gorouter/capture"
+	"code.cloudfoundry.org/gorouter/common/secure"
+	"code.cloudfoundry.org/gorouter/common/uuid"
+	"code.cloudfoundry.org/gorouter/config"
+	goRouterLogger "code.cloudfoundry.org/gorouter/logger"
+	"code.cloudfoundry.org/gorouter/mbus"
+	"code.cloudfoundry.org/gorouter/metrics"
+	"code.cloudfoundry.org/gorouter/proxy"
+	rregistry "code.cloudfoundry.org/gorouter/registry"
+	"code.cloudfoundry.org/gorouter/route_fetcher"
+	"code.cloudfoundry.org/gorouter/routeservice"
+	"code.cloudfoundry.org/routing-api"
+	uaa_client "code.cloudfoundry.org/uaa-go-client"
+	uaa_config "code.cloudfoundry.org/uaa-go-client/config"
+	"github.com/nats-io/nats"
+	"github.com/tedsuo/ifrit"
+	"github.com/uber-go/zap"
+	"github.com/urfave/negroni"
+)
+
+func createCrypto(logger goRouterLogger.Logger, secret string) *secure.AesGCM {
+	// generate secure encryption key using key derivation function (pbkdf2)
+	secretPbkdf2 := secure.NewPbkdf2([]byte(secret), 16)
+	crypto, err := secure.NewAesGCM(secretPbkdf2)
+	if err != nil {
+		logger.Fatal("error-creating-route-service-crypto", zap.Error(err))
+	}
+	return crypto
+}
+
+func buildProxy(
+	logger goRouterLogger.Logger,
+	c *config.Config,
+	registry rregistry.Registry,
+	accessLogger access_log.AccessLogger,
+	reporter metrics.CombinedReporter,
+	crypto secure.Crypto,
+	cryptoPrev secure.Crypto,
+	healthCheck *int32,
+	captureRecorder *capture.Recorder,
+	inFlight *metrics.InFlightTracker,
+	extraHandlers []negroni.Handler,
+) proxy.Proxy {
+	routeServiceConfig := routeservice.NewRouteServiceConfig(
+		logger,
+		c.RouteServiceEnabled,
+		c.RouteServiceTimeout,
+		crypto,
+		cryptoPrev,
+		c.RouteServiceRecommendHttps,
+	)
+
+	tlsConfig := &tls.Config{
+		InsecureSkipVerify: c.SkipSSLValidation,
+	}
+	c.TLSPolicyFor(config.TLSListenerBackend).Apply(tlsConfig)
+
+	return proxy.NewProxy(logger, accessLogger, c, registry,
+		reporter, routeServiceConfig, tlsConfig, healthCheck, captureRecorder, inFlight, extraHandlers...)
+}
+
+func setupRoutingAPIClient(logger goRouterLogger.Logger, c *config.Config) (routing_api.Client, error) {
+	routingAPIURI := fmt.Sprintf("%s:%d", c.RoutingApi.Uri, c.RoutingApi.Port)
+	client := routing_api.NewClient(routingAPIURI, false)
+
+	logger.Debug("fetching-token")
+	clock := clock.NewClock()
+
+	uaaClient := newUaaClient(logger, clock, c)
+
+	if !c.RoutingApi.AuthDisabled {
+		token, err := uaaClient.FetchToken(true)
+		if err != nil {
+			return nil, fmt.Errorf("unable-to-fetch-token: %s", err.Error())
+		}
+		if token.AccessToken == "" {
+			return nil, fmt.Errorf("empty token fetched")
+		}
+		client.SetToken(token.AccessToken)
+	}
+	// Test connectivity
+	_, err := client.Routes()
+	if err != nil {
+		return nil, err
+	}
+
+	return client, nil
+}
+
+func setupRouteFetcher(logger goRouterLogger.Logger, c *config.Config, registry rregistry.Registry, endpoints route_fetcher.EndpointIterator, routingAPIClient routing_api.Client) *route_fetcher.RouteFetcher {
+	clock := clock.NewClock()
+
+	uaaClient := newUaaClient(logger, clock, c)
+
+	_, err := uaaClient.FetchToken(true)
+	if err != nil {
+		logger.Fatal("unable-to-fetch-token", zap.Error(err))
+	}
+
+	routeFetcher := route_fetcher.NewRouteFetcher(logger, uaaClient, registry, c, routingAPIClient, 1, clock)
+	routeFetcher.Endpoints = endpoints
+	return routeFetcher
+}
+
+func newUaaClient(logger goRouterLogger.Logger, clock clock.Clock, c *config.Config) uaa_client.Client {
+	if c.RoutingApi.AuthDisabled {
+		logger.Info("using-noop-token-fetcher")
+		return uaa_client.NewNoOpUaaClient()
+	}
+
+	if c.OAuth.Port == -1 {
+		logger.Fatal(
+			"tls-not-enabled",
+			zap.Error(errors.New("GoRouter requires TLS enabled to get OAuth token")),
+			zap.String("token-endpoint", c.OAuth.TokenEndpoint),
+			zap.Int("port", c.OAuth.Port),
+		)
+	}
+
+	tokenURL := fmt.Sprintf("https://%s:%d", c.OAuth.TokenEndpoint, c.OAuth.Port)
+
+	cfg := &uaa_config.Config{
+		UaaEndpoint:           tokenURL,
+		SkipVerification:      c.OAuth.SkipSSLValidation,
+		ClientName:            c.OAuth.ClientName,
+		ClientSecret:          c.OAuth.ClientSecret,
+		CACerts:               c.OAuth.CACerts,
+		MaxNumberOfRetries:    c.TokenFetcherMaxRetries,
+		RetryInterval:         c.TokenFetcherRetryInterval,
+		ExpirationBufferInSec: c.TokenFetcherExpirationBufferTimeInSeconds,
+	}
+
+	uaaClient, err := uaa_client.NewClient(goRouterLogger.NewLagerAdapter(logger), cfg, clock)
+	if err != nil {
+		logger.Fatal("initialize-token-fetcher-error", zap.Error(err))
+	}
+	return uaaClient
+}
+
+func natsOptions(logger goRouterLogger.Logger, c *config.Config, natsHost *atomic.Value, startMsg chan<- struct{}) nats.Options {
+	natsServers := c.NatsServers()
+
+	options := nats.DefaultOptions
+	options.Servers = natsServers
+	options.PingInterval = c.NatsClientPingInterval
+	options.MaxReconnect = -1
+	connectedChan := make(chan struct{})
+
+	options.ClosedCB = func(conn *nats.Conn) {
+		logger.Fatal(
+			"nats-connection-closed",
+			zap.Error(errors.New("unexpected close")),
+			zap.Object("last_error", conn.LastError()),
+		)
+	}
+
+	options.DisconnectedCB = func(conn *nats.Conn) {
+		hostStr := natsHost.Load().(string)
+		logger.Info("nats-connection-disconnected", zap.String("nats-host", hostStr))
+
+		go func() {
+			ticker := time.NewTicker(c.NatsClientPingInterval)
+
+			for {
+				select {
+				case <-connectedChan:
+					return
+				case <-ticker.C:
+					logger.Info("nats-connection-still-disconnected")
+				}
+			}
+		}()
+	}
+
+	options.ReconnectedCB = func(conn *nats.Conn) {
+		connectedChan <- struct{}{}
+
+		natsURL, err := url.Parse(conn.ConnectedUrl())
+		natsHostStr := ""
+		if err != nil {
+			logger.Error("nats-url-parse-error", zap.Error(err))
+		} else {
+			natsHostStr = natsURL.Host
+		}
+		natsHost.Store(natsHostStr)
+
+		logger.Info("nats-connection-reconnected", zap.String("nats-host", natsHostStr))
+		startMsg <- struct{}{}
+	}
+
+	return options
+}
+
+func connectToNatsServer(logger goRouterLogger.Logger, c *config.Config, startMsg chan<- struct{}) *nats.Conn {
+	var natsClient *nats.Conn
+	var natsHost atomic.Value
+	var err error
+
+	options := natsOptions(logger, c, &natsHost, startMsg)
+	attempts := 3
+	for attempts > 0 {
+		natsClient, err = options.Connect()
+		if err == nil {
+			break
+		} else {
+			attempts--
+			time.Sleep(100 * time.Millisecond)
+		}
+	}
+
+	if err != nil {
+		logger.Fatal("nats-connection-error", zap.Error(err))
+	}
+
+	var natsHostStr string
+	natsURL, err := url.Parse(natsClient.ConnectedUrl())
+	if err == nil {
+		natsHostStr = natsURL.Host
+	}
+
+	logger.Info("Successfully-connected-to-nats", zap.String("host", natsHostStr))
+
+	natsHost.Store(natsHostStr)
+	return natsClient
+}
+
+func createSubscriber(
+	logger goRouterLogger.Logger,
+	c *config.Config,
+	natsClient *nats.Conn,
+	registry rregistry.Registry,
+	reporter metrics.RouteRegistryReporter,
+	startMsgChan chan struct{},
+) ifrit.Runner {
+
+	guid, err := uuid.GenerateUUID()
+	if err != nil {
+		logger.Fatal("failed-to-generate-uuid", zap.Error(err))
+	}
+
+	opts := &mbus.SubscriberOpts{
+		ID:                               fmt.Sprintf("%d-%s", c.Index, guid),
+		MinimumRegisterIntervalInSeconds: int(c.StartResponseDelayInterval.Seconds()),
+		PruneThresholdInSeconds:          int(c.DropletStaleThreshold.Seconds()),
+		MaxErrorPageBytes:                c.ErrorPages.MaxInlineBytes,
+		MaxRegistrationsPerSecond:        c.RegistrationLimits.MaxPerSecond,
+		CoalesceWindow:                   c.RegistrationLimits.CoalesceWindow,
+		TargetRegistrationsPerSecond:     c.RegisterInterval.TargetRegistrationsPerSecond,
+		MaxRegisterIntervalInSeconds:     int(c.RegisterInterval.MaxInterval.Seconds()),
+		EnforceRegisterInterval:          c.RegisterInterval.EnforceMinimum,
+		DropEarlyRefreshes:               c.RegisterInterval.DropEarlyRefreshes,
+		Capabilities:                     capabilities(c),
+	}
+	if c.RouteRegistrationAuth.Enabled {
+		opts.Verifier = mbus.NewMessageVerifier(c.RouteRegistrationAuth.SharedKey, c.RouteRegistrationAuth.PublisherKeys)
+	}
+	return mbus.NewSubscriber(logger.Session("subscriber"), natsClient, registry, reporter, startMsgChan, opts)
+}
+
+// capabilities lists the registration features enabled on this router
+func capabilities(c *config.Config) []string {
+	capabilities := []string{
+		mbus.CapabilityBatchRegistration,
+		mbus.CapabilityErrorPages,
+		mbus.CapabilityAllowedMethods,
+		mbus.CapabilityCORS,
+		mbus.CapabilityPathRewrite,
+		mbus.CapabilityQueryMatch,
+	}
+	if c.RouteRegistrationAuth.Enabled {
+		capabilities = append(capabilities, mbus.CapabilitySignedRegistration)
+	}
+	if c.RouteServiceEnabled {
+		capabilities = append(capabilities, mbus.CapabilityRouteServices, mbus.CapabilityRouteServiceOnly)
+	}
+	if c.SecurityHeaders.Enabled {
+		capabilities = append(capabilities, mbus.CapabilitySecurityHeaders)
+	}
+	if c.RouteVisibility.InternalPort != 0 {
+		capabilities = append(capabilities, mbus.CapabilityRouteVisibility)
+	}
+	if c.BandwidthLimit.Enabled {
+		capabilities = append(capabilities, mbus.CapabilityBandwidthLimit)
+	}
+	if len(c.UpstreamProxies.Proxies) > 0 {
+		capabilities = append(capabilities, mbus.CapabilityUpstreamProxy)
+	}
+	return capabilities
+}
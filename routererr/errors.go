@@ -0,0 +1,172 @@
+// Package routererr classifies the errors met routing a request. Each class is
+// a Kind, which is itself a sentinel error, and errors of the transport are
+// wrapped with their kind, so that whether a request is retried, the metric
+// counting its failure and the X-Cf-RouterError header answering it all
+// derive from the kind rather than from matching error messages.
+package routererr
+
+import (
+	"context"
+	"errors"
+	"net"
+	"syscall"
+)
+
+// Kind is a class of routing errors
+type Kind struct {
+	name        string
+	message     string
+	routerError string
+	retryable   bool
+}
+
+func (k *Kind) Error() string {
+	return k.message
+}
+
+// Name identifies the kind in metrics and logs
+func (k *Kind) Name() string {
+	return k.name
+}
+
+// RouterError is the X-Cf-RouterError header of responses failed with the kind
+func (k *Kind) RouterError() string {
+	return k.routerError
+}
+
+// Retryable reports whether a request failed with the kind may be sent to
+// another endpoint, which only holds while the endpoint can have received
+// nothing of it
+func (k *Kind) Retryable() bool {
+	return k.retryable
+}
+
+var (
+	// ErrNoEndpoints is returned when no endpoint of the route is left to try
+	ErrNoEndpoints = &Kind{name: "no_endpoints", message: "No endpoints available", routerError: "endpoint_failure"}
+	// ErrDialFailed wraps the failures to connect to an endpoint
+	ErrDialFailed = &Kind{name: "dial_failed", message: "dial failed", routerError: "endpoint_failure", retryable: true}
+	// ErrConnectionReset wraps the resets of a reused endpoint conn before
+	// the endpoint read the request
+	ErrConnectionReset = &Kind{name: "connection_reset", message: "connection reset", routerError: "endpoint_failure", retryable: true}
+	// ErrBackendTimeout wraps the endpoints failing to respond in time
+	ErrBackendTimeout = &Kind{name: "backend_timeout", message: "backend timed out", routerError: "endpoint_failure"}
+	// ErrBackendFailed wraps the other failures of an endpoint
+	ErrBackendFailed = &Kind{name: "backend_failed", message: "backend failed", routerError: "endpoint_failure"}
+	// ErrRouteServiceFailed wraps the failures of a route service
+	ErrRouteServiceFailed = &Kind{name: "route_service_failed", message: "route service failed", routerError: "endpoint_failure"}
+	// ErrClientClosed wraps the failures of requests whose client went away
+	// while waiting for the response, which are no fault of the endpoint
+	ErrClientClosed = &Kind{name: "client_closed", message: "client closed the request", routerError: "client_closed"}
+)
+
+// Error is an error of a kind, wrapping its cause
+type Error struct {
+	Kind *Kind
+	Err  error
+}
+
+func (e *Error) Error() string {
+	return e.Kind.message + ": " + e.Err.Error()
+}
+
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+// Is makes errors.Is match the kind of e as well as its cause
+func (e *Error) Is(target error) bool {
+	return target == e.Kind
+}
+
+// Wrap returns err as an error of kind, or nil when err is nil
+func Wrap(kind *Kind, err error) error {
+	if err == nil {
+		return nil
+	}
+	return &Error{Kind: kind, Err: err}
+}
+
+// Classify returns err wrapped with its kind, as told by the transport error
+// it is. Errors already of a kind are returned as they are.
+func Classify(err error) error {
+	if err == nil || KindOf(err) != nil {
+		return err
+	}
+	return Wrap(classify(err), err)
+}
+
+func classify(err error) *Kind {
+	var opErr *net.OpError
+	if errors.As(err, &opErr) {
+		switch {
+		case opErr.Op == "dial":
+			return ErrDialFailed
+		case opErr.Op == "read" && errors.Is(opErr.Err, syscall.ECONNRESET):
+			return ErrConnectionReset
+		}
+	}
+
+	if errors.Is(err, context.Canceled) {
+		return ErrClientClosed
+	}
+
+	var netErr net.Error
+	if errors.Is(err, context.DeadlineExceeded) || errors.As(err, &netErr) && netErr.Timeout() {
+		return ErrBackendTimeout
+	}
+	return ErrBackendFailed
+}
+
+// KindOf returns the outermost kind of err, or nil when err has none
+func KindOf(err error) *Kind {
+	for err != nil {
+		switch e := err.(type) {
+		case *Kind:
+			return e
+		case *Error:
+			return e.Kind
+		}
+		err = errors.Unwrap(err)
+	}
+	return nil
+}
+
+// Retryable reports whether err, or an error it wraps, is of a kind that may
+// be retried on another endpoint
+func Retryable(err error) bool {
+	for err != nil {
+		switch e := err.(type) {
+		case *Kind:
+			return e.retryable
+		case *Error:
+			if e.Kind.retryable {
+				return true
+			}
+			err = e.Err
+		default:
+			if KindOf(err) == nil {
+				return classify(err).retryable
+			}
+			err = errors.Unwrap(err)
+		}
+	}
+	return false
+}
+
+// RouterError returns the X-Cf-RouterError header of a response failed with
+// err
+func RouterError(err error) string {
+	if kind := KindOf(err); kind != nil {
+		return kind.routerError
+	}
+	return classify(err).routerError
+}
+
+// Name returns the name of the kind of err, for metrics and logs
+func Name(err error) string {
+	if kind := KindOf(err); kind != nil {
+		return kind.name
+	}
+	return classify(err).name
+}
@@ -0,0 +1,104 @@
+package routererr_test
+
+import (
+	"context"
+	"errors"
+	"net"
+	"os"
+	"syscall"
+
+	"code.cloudfoundry.org/gorouter/routererr"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Classify", func() {
+	var (
+		dialError      error
+		connResetError error
+		timeoutError   error
+	)
+
+	BeforeEach(func() {
+		dialError = &net.OpError{Op: "dial", Err: errors.New("connection refused")}
+		connResetError = &net.OpError{Op: "read", Err: os.NewSyscallError("read", syscall.ECONNRESET)}
+		timeoutError = &net.OpError{Op: "read", Err: os.ErrDeadlineExceeded}
+	})
+
+	It("wraps transport errors with their kind", func() {
+		Expect(errors.Is(routererr.Classify(dialError), routererr.ErrDialFailed)).To(BeTrue())
+		Expect(errors.Is(routererr.Classify(connResetError), routererr.ErrConnectionReset)).To(BeTrue())
+		Expect(errors.Is(routererr.Classify(timeoutError), routererr.ErrBackendTimeout)).To(BeTrue())
+		Expect(errors.Is(routererr.Classify(context.DeadlineExceeded), routererr.ErrBackendTimeout)).To(BeTrue())
+		Expect(errors.Is(routererr.Classify(context.Canceled), routererr.ErrClientClosed)).To(BeTrue())
+		Expect(errors.Is(routererr.Classify(errors.New("boom")), routererr.ErrBackendFailed)).To(BeTrue())
+	})
+
+	It("keeps the cause", func() {
+		err := routererr.Classify(dialError)
+
+		Expect(errors.Is(err, dialError)).To(BeTrue())
+		Expect(err.Error()).To(ContainSubstring("connection refused"))
+	})
+
+	It("leaves errors of a kind as they are", func() {
+		err := routererr.Wrap(routererr.ErrRouteServiceFailed, dialError)
+
+		Expect(routererr.Classify(err)).To(Equal(err))
+		Expect(routererr.Classify(routererr.ErrNoEndpoints)).To(Equal(routererr.ErrNoEndpoints))
+	})
+
+	It("returns nil for nil", func() {
+		Expect(routererr.Classify(nil)).To(BeNil())
+		Expect(routererr.Wrap(routererr.ErrDialFailed, nil)).To(BeNil())
+	})
+})
+
+var _ = Describe("Retryable", func() {
+	It("retries the errors an endpoint cannot have read the request through", func() {
+		Expect(routererr.Retryable(&net.OpError{Op: "dial", Err: errors.New("connection refused")})).To(BeTrue())
+		Expect(routererr.Retryable(&net.OpError{Op: "read", Err: os.NewSyscallError("read", syscall.ECONNRESET)})).To(BeTrue())
+	})
+
+	It("does not retry the other errors", func() {
+		Expect(routererr.Retryable(errors.New("boom"))).To(BeFalse())
+		Expect(routererr.Retryable(&net.OpError{Op: "write", Err: os.NewSyscallError("write", syscall.EPIPE)})).To(BeFalse())
+		Expect(routererr.Retryable(routererr.ErrNoEndpoints)).To(BeFalse())
+		Expect(routererr.Retryable(nil)).To(BeFalse())
+	})
+
+	It("retries a route service failing to connect", func() {
+		err := routererr.Wrap(routererr.ErrRouteServiceFailed, &net.OpError{Op: "dial", Err: errors.New("connection refused")})
+
+		Expect(routererr.Retryable(err)).To(BeTrue())
+	})
+})
+
+var _ = Describe("KindOf", func() {
+	It("returns the outermost kind", func() {
+		err := routererr.Wrap(routererr.ErrRouteServiceFailed, routererr.Classify(errors.New("boom")))
+
+		Expect(routererr.KindOf(err)).To(Equal(routererr.ErrRouteServiceFailed))
+		Expect(errors.Is(err, routererr.ErrBackendFailed)).To(BeTrue())
+	})
+
+	It("returns nil for errors of no kind", func() {
+		Expect(routererr.KindOf(errors.New("boom"))).To(BeNil())
+	})
+})
+
+var _ = Describe("RouterError and Name", func() {
+	It("map the kind of an error to its header and metric", func() {
+		err := routererr.Classify(&net.OpError{Op: "dial", Err: errors.New("connection refused")})
+
+		Expect(routererr.RouterError(err)).To(Equal("endpoint_failure"))
+		Expect(routererr.Name(err)).To(Equal("dial_failed"))
+		Expect(routererr.Name(routererr.ErrNoEndpoints)).To(Equal("no_endpoints"))
+	})
+
+	It("classify errors not wrapped yet", func() {
+		Expect(routererr.Name(errors.New("boom"))).To(Equal("backend_failed"))
+		Expect(routererr.RouterError(errors.New("boom"))).To(Equal("endpoint_failure"))
+	})
+})
@@ -0,0 +1,13 @@
+package routererr_test
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"testing"
+)
+
+func TestRoutererr(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "Routererr Suite")
+}
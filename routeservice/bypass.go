@@ -0,0 +1,58 @@
+package routeservice
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// RouteServiceBypass carries a signed request to skip the route service of the
+// requested host. Its value is "<unix time>:<signature>", where the signature
+// is the hex encoded HMAC-SHA256 of "<host>\n<unix time>".
+const RouteServiceBypass = "X-Cf-Route-Service-Bypass"
+
+var ErrInvalidBypass = errors.New("invalid route service bypass signature")
+var ErrBypassExpired = errors.New("route service bypass expired")
+
+// SignBypass returns a RouteServiceBypass header value for host, signed at t
+func SignBypass(secret []byte, host string, t time.Time) string {
+	timestamp := strconv.FormatInt(t.Unix(), 10)
+	return timestamp + ":" + hex.EncodeToString(bypassSignature(secret, host, timestamp))
+}
+
+// VerifyBypass checks that value was signed for host with secret no longer
+// than maxAge before now
+func VerifyBypass(secret []byte, host, value string, maxAge time.Duration, now time.Time) error {
+	parts := strings.SplitN(value, ":", 2)
+	if len(parts) != 2 {
+		return ErrInvalidBypass
+	}
+
+	signedAt, err := strconv.ParseInt(parts[0], 10, 64)
+	if err != nil {
+		return ErrInvalidBypass
+	}
+	signature, err := hex.DecodeString(parts[1])
+	if err != nil {
+		return ErrInvalidBypass
+	}
+	if !hmac.Equal(signature, bypassSignature(secret, strings.ToLower(host), parts[0])) {
+		return ErrInvalidBypass
+	}
+
+	age := now.Sub(time.Unix(signedAt, 0))
+	if age > maxAge || age < -maxAge {
+		return ErrBypassExpired
+	}
+	return nil
+}
+
+func bypassSignature(secret []byte, host, timestamp string) []byte {
+	mac := hmac.New(sha256.New, secret)
+	_, _ = mac.Write([]byte(strings.ToLower(host) + "\n" + timestamp))
+	return mac.Sum(nil)
+}
@@ -0,0 +1,50 @@
+package routeservice_test
+
+import (
+	"time"
+
+	"code.cloudfoundry.org/gorouter/routeservice"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Route Service Bypass", func() {
+	var (
+		secret []byte
+		now    time.Time
+	)
+
+	BeforeEach(func() {
+		secret = []byte("bypass-secret")
+		now = time.Now()
+	})
+
+	It("accepts a value signed for the host", func() {
+		value := routeservice.SignBypass(secret, "app.example.com", now.Add(-time.Minute))
+		Expect(routeservice.VerifyBypass(secret, "App.example.com", value, 5*time.Minute, now)).To(Succeed())
+	})
+
+	It("rejects a value signed for another host", func() {
+		value := routeservice.SignBypass(secret, "other.example.com", now)
+		err := routeservice.VerifyBypass(secret, "app.example.com", value, 5*time.Minute, now)
+		Expect(err).To(Equal(routeservice.ErrInvalidBypass))
+	})
+
+	It("rejects a value signed with another secret", func() {
+		value := routeservice.SignBypass([]byte("other-secret"), "app.example.com", now)
+		err := routeservice.VerifyBypass(secret, "app.example.com", value, 5*time.Minute, now)
+		Expect(err).To(Equal(routeservice.ErrInvalidBypass))
+	})
+
+	It("rejects an expired value", func() {
+		value := routeservice.SignBypass(secret, "app.example.com", now.Add(-10*time.Minute))
+		err := routeservice.VerifyBypass(secret, "app.example.com", value, 5*time.Minute, now)
+		Expect(err).To(Equal(routeservice.ErrBypassExpired))
+	})
+
+	It("rejects a malformed value", func() {
+		err := routeservice.VerifyBypass(secret, "app.example.com", "not-signed", 5*time.Minute, now)
+		Expect(err).To(Equal(routeservice.ErrInvalidBypass))
+	})
+})
@@ -0,0 +1,112 @@
+package routeshare
+
+import (
+	"net"
+	"strconv"
+
+	"code.cloudfoundry.org/gorouter/config"
+	"code.cloudfoundry.org/gorouter/route"
+	"code.cloudfoundry.org/routing-api/models"
+)
+
+// Actions carried by an Event
+const (
+	ActionRegister   = "register"
+	ActionUnregister = "unregister"
+)
+
+// Event is a single routing table mutation streamed from the owner to its
+// followers as a line of JSON
+type Event struct {
+	Action   string        `json:"action"`
+	URI      route.Uri     `json:"uri"`
+	Endpoint EndpointEvent `json:"endpoint"`
+}
+
+// EndpointEvent carries everything needed to rebuild a route.Endpoint
+type EndpointEvent struct {
+	App                     string                  `json:"app"`
+	Host                    string                  `json:"host"`
+	Port                    uint16                  `json:"port"`
+	Tags                    map[string]string       `json:"tags"`
+	PrivateInstanceID       string                  `json:"private_instance_id"`
+	PrivateInstanceIndex    string                  `json:"private_instance_index"`
+	StaleThresholdInSeconds int                     `json:"stale_threshold_in_seconds"`
+	RouteServiceURL         string                  `json:"route_service_url"`
+	IsolationSegment        string                  `json:"isolation_segment"`
+	ModificationTag         models.ModificationTag  `json:"modification_tag"`
+	ErrorPage               string                  `json:"error_page,omitempty"`
+	ErrorPageHTML           string                  `json:"error_page_html,omitempty"`
+	AllowedMethods          []string                `json:"allowed_methods,omitempty"`
+	CORS                    *config.CORSPolicy      `json:"cors,omitempty"`
+	SecurityHeaders         *config.SecurityHeaders `json:"security_headers,omitempty"`
+	Visibility              string                  `json:"visibility,omitempty"`
+	BandwidthLimit          *config.BandwidthLimit  `json:"bandwidth_limit,omitempty"`
+	Source                  string                  `json:"source,omitempty"`
+	QueryMatch              map[string]string       `json:"query_match,omitempty"`
+	RouteServiceOnly        bool                    `json:"route_service_only,omitempty"`
+	PathRewrite             *config.PathRewrite     `json:"path_rewrite,omitempty"`
+	UpstreamProxy           string                  `json:"upstream_proxy,omitempty"`
+}
+
+func newEvent(action string, uri route.Uri, endpoint *route.Endpoint) Event {
+	host, portStr, _ := net.SplitHostPort(endpoint.CanonicalAddr())
+	port, _ := strconv.ParseUint(portStr, 10, 16)
+
+	return Event{
+		Action: action,
+		URI:    uri,
+		Endpoint: EndpointEvent{
+			App:                     endpoint.ApplicationId,
+			Host:                    host,
+			Port:                    uint16(port),
+			Tags:                    endpoint.Tags,
+			PrivateInstanceID:       endpoint.PrivateInstanceId,
+			PrivateInstanceIndex:    endpoint.PrivateInstanceIndex,
+			StaleThresholdInSeconds: int(endpoint.StaleThreshold().Seconds()),
+			RouteServiceURL:         endpoint.RouteServiceUrl,
+			IsolationSegment:        endpoint.IsolationSegment,
+			ModificationTag:         endpoint.ModificationTag,
+			ErrorPage:               endpoint.ErrorPage,
+			ErrorPageHTML:           endpoint.ErrorPageHTML,
+			AllowedMethods:          endpoint.AllowedMethods,
+			CORS:                    endpoint.CORS,
+			SecurityHeaders:         endpoint.SecurityHeaders,
+			Visibility:              endpoint.Visibility,
+			BandwidthLimit:          endpoint.BandwidthLimit,
+			Source:                  endpoint.Source,
+			QueryMatch:              endpoint.QueryMatch,
+			RouteServiceOnly:        endpoint.RouteServiceOnly,
+			PathRewrite:             endpoint.PathRewrite,
+			UpstreamProxy:           endpoint.UpstreamProxy,
+		},
+	}
+}
+
+func (e *EndpointEvent) makeEndpoint() *route.Endpoint {
+	endpoint := route.NewEndpoint(
+		e.App,
+		e.Host,
+		e.Port,
+		e.PrivateInstanceID,
+		e.PrivateInstanceIndex,
+		e.Tags,
+		e.StaleThresholdInSeconds,
+		e.RouteServiceURL,
+		e.ModificationTag,
+		e.IsolationSegment,
+	)
+	endpoint.ErrorPage = e.ErrorPage
+	endpoint.ErrorPageHTML = e.ErrorPageHTML
+	endpoint.AllowedMethods = e.AllowedMethods
+	endpoint.CORS = e.CORS
+	endpoint.SecurityHeaders = e.SecurityHeaders
+	endpoint.Visibility = e.Visibility
+	endpoint.BandwidthLimit = e.BandwidthLimit
+	endpoint.Source = e.Source
+	endpoint.QueryMatch = e.QueryMatch
+	endpoint.RouteServiceOnly = e.RouteServiceOnly
+	endpoint.PathRewrite = e.PathRewrite
+	endpoint.UpstreamProxy = e.UpstreamProxy
+	return endpoint
+}
@@ -0,0 +1,88 @@
+package routeshare
+
+import (
+	"encoding/json"
+	"net"
+	"os"
+	"time"
+
+	"github.com/uber-go/zap"
+
+	"code.cloudfoundry.org/gorouter/logger"
+	"code.cloudfoundry.org/gorouter/registry"
+)
+
+// Follower keeps a registry in sync with the routing table streamed by an
+// Owner, reconnecting whenever the stream is lost
+type Follower struct {
+	registry      registry.Registry
+	socket        string
+	retryInterval time.Duration
+	logger        logger.Logger
+}
+
+// NewFollower returns a Follower applying the table served on socket to
+// registry
+func NewFollower(registry registry.Registry, socket string, retryInterval time.Duration, logger logger.Logger) *Follower {
+	return &Follower{
+		registry:      registry,
+		socket:        socket,
+		retryInterval: retryInterval,
+		logger:        logger,
+	}
+}
+
+// Run follows the owner until signaled
+func (f *Follower) Run(signals <-chan os.Signal, ready chan<- struct{}) error {
+	close(ready)
+	f.logger.Info("route-table-follower-started", zap.String("socket", f.socket))
+
+	for {
+		conn, err := net.Dial("unix", f.socket)
+		if err != nil {
+			f.logger.Error("route-table-owner-unavailable", zap.String("socket", f.socket), zap.Error(err))
+		} else {
+			done := make(chan error, 1)
+			go func() {
+				done <- f.consume(conn)
+			}()
+
+			select {
+			case err = <-done:
+				conn.Close()
+				f.logger.Error("route-table-stream-lost", zap.Error(err))
+			case <-signals:
+				conn.Close()
+				f.logger.Info("route-table-follower-exited")
+				return nil
+			}
+		}
+
+		select {
+		case <-time.After(f.retryInterval):
+		case <-signals:
+			f.logger.Info("route-table-follower-exited")
+			return nil
+		}
+	}
+}
+
+func (f *Follower) consume(conn net.Conn) error {
+	decoder := json.NewDecoder(conn)
+	for {
+		var event Event
+		if err := decoder.Decode(&event); err != nil {
+			return err
+		}
+
+		endpoint := event.Endpoint.makeEndpoint()
+		switch event.Action {
+		case ActionRegister:
+			f.registry.Register(event.URI, endpoint)
+		case ActionUnregister:
+			f.registry.Unregister(event.URI, endpoint)
+		default:
+			f.logger.Error("unknown-route-table-event", zap.String("action", event.Action))
+		}
+	}
+}
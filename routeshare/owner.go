@@ -0,0 +1,207 @@
+package routeshare
+
+import (
+	"bufio"
+	"encoding/json"
+	"net"
+	"os"
+	"sync"
+
+	"github.com/uber-go/zap"
+
+	"code.cloudfoundry.org/gorouter/logger"
+	"code.cloudfoundry.org/gorouter/registry"
+	"code.cloudfoundry.org/gorouter/route"
+)
+
+// followerBufferSize bounds the events queued for a single follower. A
+// follower that falls this far behind is disconnected and resyncs from a
+// fresh snapshot when it reconnects.
+const followerBufferSize = 4096
+
+// Table is the routing table served by an Owner
+type Table interface {
+	registry.Registry
+	EachEndpoint(f func(uri route.Uri, endpoint *route.Endpoint))
+}
+
+// Owner is a Registry that streams every Register and Unregister to the
+// follower processes connected to its unix socket. Each follower first
+// receives a snapshot of the table.
+type Owner struct {
+	Table
+
+	socket string
+	logger logger.Logger
+
+	lock      sync.Mutex
+	followers map[*followerConn]struct{}
+}
+
+type followerConn struct {
+	conn   net.Conn
+	events chan Event
+}
+
+// NewOwner returns an Owner serving table on the unix socket at socket
+func NewOwner(table Table, socket string, logger logger.Logger) *Owner {
+	return &Owner{
+		Table:     table,
+		socket:    socket,
+		logger:    logger,
+		followers: make(map[*followerConn]struct{}),
+	}
+}
+
+func (o *Owner) Register(uri route.Uri, endpoint *route.Endpoint) {
+	o.RegisterApplied(uri, endpoint)
+}
+
+// RegisterApplied registers as Register does, reporting whether the table
+// applied the registration
+func (o *Owner) RegisterApplied(uri route.Uri, endpoint *route.Endpoint) bool {
+	o.lock.Lock()
+	defer o.lock.Unlock()
+
+	applied := registry.RegisterApplied(o.Table, uri, endpoint)
+	o.broadcast(newEvent(ActionRegister, uri, endpoint))
+	return applied
+}
+
+func (o *Owner) RegisterBatch(registrations []registry.Registration) {
+	o.RegisterBatchApplied(registrations)
+}
+
+// RegisterBatchApplied registers the batch in the table at once, streaming
+// each registration to the followers
+func (o *Owner) RegisterBatchApplied(registrations []registry.Registration) []bool {
+	o.lock.Lock()
+	defer o.lock.Unlock()
+
+	applied := registry.RegisterBatchApplied(o.Table, registrations)
+	for _, reg := range registrations {
+		o.broadcast(newEvent(ActionRegister, reg.URI, reg.Endpoint))
+	}
+	return applied
+}
+
+func (o *Owner) Unregister(uri route.Uri, endpoint *route.Endpoint) {
+	o.UnregisterApplied(uri, endpoint)
+}
+
+// UnregisterApplied unregisters as Unregister does, reporting whether the
+// table removed the endpoint
+func (o *Owner) UnregisterApplied(uri route.Uri, endpoint *route.Endpoint) bool {
+	o.lock.Lock()
+	defer o.lock.Unlock()
+
+	applied := registry.UnregisterApplied(o.Table, uri, endpoint)
+	o.broadcast(newEvent(ActionUnregister, uri, endpoint))
+	return applied
+}
+
+// Run serves the routing table until signaled
+func (o *Owner) Run(signals <-chan os.Signal, ready chan<- struct{}) error {
+	// A socket left behind by a previous process would make Listen fail
+	_ = os.Remove(o.socket)
+
+	listener, err := net.Listen("unix", o.socket)
+	if err != nil {
+		o.logger.Error("route-table-owner-listen-failed", zap.String("socket", o.socket), zap.Error(err))
+		return err
+	}
+
+	go o.accept(listener)
+
+	close(ready)
+	o.logger.Info("route-table-owner-started", zap.String("socket", o.socket))
+
+	<-signals
+	listener.Close()
+
+	o.lock.Lock()
+	for f := range o.followers {
+		o.removeFollower(f)
+	}
+	o.lock.Unlock()
+
+	o.logger.Info("route-table-owner-exited")
+	return nil
+}
+
+func (o *Owner) accept(listener net.Listener) {
+	for {
+		conn, err := listener.Accept()
+		if err != nil {
+			return
+		}
+
+		f := &followerConn{
+			conn:   conn,
+			events: make(chan Event, followerBufferSize),
+		}
+
+		// The snapshot is taken under the same lock that orders broadcasts,
+		// so the follower sees every later mutation exactly once.
+		o.lock.Lock()
+		var snapshot []Event
+		o.Table.EachEndpoint(func(uri route.Uri, endpoint *route.Endpoint) {
+			snapshot = append(snapshot, newEvent(ActionRegister, uri, endpoint))
+		})
+		o.followers[f] = struct{}{}
+		o.lock.Unlock()
+
+		o.logger.Info("route-table-follower-connected", zap.Int("snapshot-size", len(snapshot)))
+		go o.serve(f, snapshot)
+	}
+}
+
+func (o *Owner) serve(f *followerConn, snapshot []Event) {
+	w := bufio.NewWriter(f.conn)
+	encoder := json.NewEncoder(w)
+
+	err := writeEvents(w, encoder, snapshot)
+	for err == nil {
+		event, ok := <-f.events
+		if !ok {
+			return
+		}
+		err = writeEvents(w, encoder, []Event{event})
+	}
+
+	o.logger.Info("route-table-follower-disconnected", zap.Error(err))
+	o.lock.Lock()
+	o.removeFollower(f)
+	o.lock.Unlock()
+}
+
+func writeEvents(w *bufio.Writer, encoder *json.Encoder, events []Event) error {
+	for i := range events {
+		if err := encoder.Encode(&events[i]); err != nil {
+			return err
+		}
+	}
+	return w.Flush()
+}
+
+// broadcast must be called with o.lock held
+func (o *Owner) broadcast(event Event) {
+	for f := range o.followers {
+		select {
+		case f.events <- event:
+		default:
+			o.logger.Error("route-table-follower-too-slow")
+			o.removeFollower(f)
+		}
+	}
+}
+
+// removeFollower must be called with o.lock held
+func (o *Owner) removeFollower(f *followerConn) {
+	if _, ok := o.followers[f]; !ok {
+		return
+	}
+	delete(o.followers, f)
+	close(f.events)
+	f.conn.Close()
+}
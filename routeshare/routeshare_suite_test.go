@@ -0,0 +1,13 @@
+package routeshare_test
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"testing"
+)
+
+func TestRouteshare(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "Routeshare Suite")
+}
@@ -0,0 +1,168 @@
+package routeshare_test
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"time"
+
+	"code.cloudfoundry.org/gorouter/config"
+	"code.cloudfoundry.org/gorouter/logger"
+	"code.cloudfoundry.org/gorouter/metrics/fakes"
+	"code.cloudfoundry.org/gorouter/registry"
+	registryFakes "code.cloudfoundry.org/gorouter/registry/fakes"
+	"code.cloudfoundry.org/gorouter/route"
+	"code.cloudfoundry.org/gorouter/routeshare"
+	"code.cloudfoundry.org/gorouter/test_util"
+	"code.cloudfoundry.org/routing-api/models"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/tedsuo/ifrit"
+)
+
+var _ = Describe("Route table sharing", func() {
+	var (
+		tmpDir   string
+		socket   string
+		logger   logger.Logger
+		table    *registry.RouteRegistry
+		owner    *routeshare.Owner
+		follower *registryFakes.FakeRegistry
+
+		ownerProcess    ifrit.Process
+		followerProcess ifrit.Process
+
+		existing *route.Endpoint
+	)
+
+	BeforeEach(func() {
+		var err error
+		tmpDir, err = ioutil.TempDir("", "routeshare")
+		Expect(err).NotTo(HaveOccurred())
+		socket = filepath.Join(tmpDir, "routes.sock")
+
+		logger = test_util.NewTestZapLogger("routeshare")
+		table = registry.NewRouteRegistry(logger, config.DefaultConfig(), new(fakes.FakeRouteRegistryReporter))
+		owner = routeshare.NewOwner(table, socket, logger)
+		follower = new(registryFakes.FakeRegistry)
+
+		existing = route.NewEndpoint("app-1", "10.0.0.1", 8080, "instance-1", "0",
+			map[string]string{"component": "web"}, 30, "", models.ModificationTag{Guid: "abc", Index: 1}, "")
+		owner.Register("existing.example.com", existing)
+
+		ownerProcess = ifrit.Invoke(owner)
+		followerProcess = ifrit.Invoke(routeshare.NewFollower(follower, socket, 10*time.Millisecond, logger))
+	})
+
+	AfterEach(func() {
+		followerProcess.Signal(os.Interrupt)
+		Eventually(followerProcess.Wait()).Should(Receive())
+		ownerProcess.Signal(os.Interrupt)
+		Eventually(ownerProcess.Wait()).Should(Receive())
+		os.RemoveAll(tmpDir)
+	})
+
+	It("applies the owner's routing table on the follower", func() {
+		Eventually(follower.RegisterCallCount).Should(Equal(1))
+
+		uri, endpoint := follower.RegisterArgsForCall(0)
+		Expect(uri).To(Equal(route.Uri("existing.example.com")))
+		Expect(endpoint.ApplicationId).To(Equal("app-1"))
+		Expect(endpoint.CanonicalAddr()).To(Equal("10.0.0.1:8080"))
+		Expect(endpoint.PrivateInstanceId).To(Equal("instance-1"))
+		Expect(endpoint.Tags).To(Equal(map[string]string{"component": "web"}))
+		Expect(endpoint.StaleThreshold()).To(Equal(30 * time.Second))
+		Expect(endpoint.ModificationTag).To(Equal(models.ModificationTag{Guid: "abc", Index: 1}))
+	})
+
+	It("streams registrations made after the follower connected", func() {
+		Eventually(follower.RegisterCallCount).Should(Equal(1))
+
+		owner.Register("new.example.com", route.NewEndpoint("app-2", "10.0.0.2", 8080, "", "", nil, -1, "", models.ModificationTag{}, ""))
+		Eventually(follower.RegisterCallCount).Should(Equal(2))
+		uri, _ := follower.RegisterArgsForCall(1)
+		Expect(uri).To(Equal(route.Uri("new.example.com")))
+		Expect(table.Lookup("new.example.com")).NotTo(BeNil())
+
+		owner.Unregister("existing.example.com", existing)
+		Eventually(follower.UnregisterCallCount).Should(Equal(1))
+		uri, endpoint := follower.UnregisterArgsForCall(0)
+		Expect(uri).To(Equal(route.Uri("existing.example.com")))
+		Expect(endpoint.CanonicalAddr()).To(Equal("10.0.0.1:8080"))
+	})
+
+	It("carries the query matches of endpoints", func() {
+		Eventually(follower.RegisterCallCount).Should(Equal(1))
+
+		endpoint := route.NewEndpoint("app-2", "10.0.0.2", 8080, "", "", nil, -1, "", models.ModificationTag{}, "")
+		endpoint.QueryMatch = map[string]string{"version": "2"}
+		owner.Register("new.example.com", endpoint)
+
+		Eventually(follower.RegisterCallCount).Should(Equal(2))
+		_, endpoint = follower.RegisterArgsForCall(1)
+		Expect(endpoint.QueryMatch).To(Equal(map[string]string{"version": "2"}))
+	})
+
+	It("carries route service only endpoints", func() {
+		Eventually(follower.RegisterCallCount).Should(Equal(1))
+
+		endpoint := route.NewEndpoint("app-2", "10.0.0.2", 8080, "", "", nil, -1, "", models.ModificationTag{}, "")
+		endpoint.RouteServiceOnly = true
+		owner.Register("new.example.com", endpoint)
+
+		Eventually(follower.RegisterCallCount).Should(Equal(2))
+		_, endpoint = follower.RegisterArgsForCall(1)
+		Expect(endpoint.RouteServiceOnly).To(BeTrue())
+	})
+
+	It("carries the path rewrites of endpoints", func() {
+		Eventually(follower.RegisterCallCount).Should(Equal(1))
+
+		endpoint := route.NewEndpoint("app-2", "10.0.0.2", 8080, "", "", nil, -1, "", models.ModificationTag{}, "")
+		endpoint.PathRewrite = &config.PathRewrite{ReplacePrefix: "/v2"}
+		owner.Register("new.example.com", endpoint)
+
+		Eventually(follower.RegisterCallCount).Should(Equal(2))
+		_, endpoint = follower.RegisterArgsForCall(1)
+		Expect(endpoint.PathRewrite).To(Equal(&config.PathRewrite{ReplacePrefix: "/v2"}))
+	})
+
+	It("carries the upstream proxies of endpoints", func() {
+		Eventually(follower.RegisterCallCount).Should(Equal(1))
+
+		endpoint := route.NewEndpoint("app-2", "10.0.0.2", 8080, "", "", nil, -1, "", models.ModificationTag{}, "")
+		endpoint.UpstreamProxy = "corporate"
+		owner.Register("new.example.com", endpoint)
+
+		Eventually(follower.RegisterCallCount).Should(Equal(2))
+		_, endpoint = follower.RegisterArgsForCall(1)
+		Expect(endpoint.UpstreamProxy).To(Equal("corporate"))
+	})
+
+	It("streams batch registrations", func() {
+		Eventually(follower.RegisterCallCount).Should(Equal(1))
+
+		owner.RegisterBatch([]registry.Registration{
+			{URI: "one.example.com", Endpoint: route.NewEndpoint("app-2", "10.0.0.2", 8080, "", "", nil, -1, "", models.ModificationTag{}, "")},
+			{URI: "two.example.com", Endpoint: route.NewEndpoint("app-3", "10.0.0.3", 8080, "", "", nil, -1, "", models.ModificationTag{}, "")},
+		})
+		Expect(table.Lookup("two.example.com")).NotTo(BeNil())
+
+		Eventually(follower.RegisterCallCount).Should(Equal(3))
+		uri, _ := follower.RegisterArgsForCall(2)
+		Expect(uri).To(Equal(route.Uri("two.example.com")))
+	})
+
+	Context("when the owner restarts", func() {
+		It("resyncs from a fresh snapshot", func() {
+			Eventually(follower.RegisterCallCount).Should(Equal(1))
+
+			ownerProcess.Signal(os.Interrupt)
+			Eventually(ownerProcess.Wait()).Should(Receive())
+
+			ownerProcess = ifrit.Invoke(routeshare.NewOwner(table, socket, logger))
+			Eventually(follower.RegisterCallCount).Should(Equal(2))
+		})
+	})
+})
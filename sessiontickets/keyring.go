@@ -0,0 +1,142 @@
+// Package sessiontickets shares the keys that encrypt TLS session tickets
+// between router instances, so a client can resume its session on whichever
+// router the load balancer picks next.
+package sessiontickets
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/tls"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/uber-go/zap"
+
+	"code.cloudfoundry.org/gorouter/logger"
+)
+
+// KeySize is the size of a session ticket key
+const KeySize = 32
+
+// ParseKeys parses a key file: one base64 encoded key per line, with blank
+// lines and lines starting with # ignored. The first key encrypts new
+// tickets; the others only decrypt tickets issued before a rotation.
+func ParseKeys(contents []byte) ([][KeySize]byte, error) {
+	var keys [][KeySize]byte
+
+	scanner := bufio.NewScanner(bytes.NewReader(contents))
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+
+		raw, err := base64.StdEncoding.DecodeString(text)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %s", line, err)
+		}
+		if len(raw) != KeySize {
+			return nil, fmt.Errorf("line %d: key is %d bytes, expected %d", line, len(raw), KeySize)
+		}
+
+		var key [KeySize]byte
+		copy(key[:], raw)
+		keys = append(keys, key)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	if len(keys) == 0 {
+		return nil, errors.New("no session ticket keys")
+	}
+	return keys, nil
+}
+
+// KeyRing installs the keys of a key file on TLS configs, and installs them
+// again whenever the file changes. Rotating keys is left to whatever writes
+// the file, such as a job fetching them from a KMS; a file that cannot be
+// parsed leaves the previous keys in use.
+type KeyRing struct {
+	path     string
+	interval time.Duration
+	logger   logger.Logger
+
+	lock     sync.Mutex
+	contents []byte
+	keys     [][KeySize]byte
+	configs  []*tls.Config
+}
+
+// NewKeyRing loads the keys at path, failing when they cannot be read
+func NewKeyRing(path string, interval time.Duration, logger logger.Logger) (*KeyRing, error) {
+	k := &KeyRing{path: path, interval: interval, logger: logger}
+	if _, err := k.Reload(); err != nil {
+		return nil, err
+	}
+	return k, nil
+}
+
+// Install sets the keys on config, and keeps them up to date. Tickets are
+// only shared when every router installs the same file.
+func (k *KeyRing) Install(config *tls.Config) {
+	k.lock.Lock()
+	defer k.lock.Unlock()
+
+	config.SetSessionTicketKeys(k.keys)
+	k.configs = append(k.configs, config)
+}
+
+// Reload reads the key file again and reports whether the keys changed
+func (k *KeyRing) Reload() (bool, error) {
+	contents, err := ioutil.ReadFile(k.path)
+	if err != nil {
+		return false, err
+	}
+
+	k.lock.Lock()
+	defer k.lock.Unlock()
+
+	if k.keys != nil && bytes.Equal(contents, k.contents) {
+		return false, nil
+	}
+
+	keys, err := ParseKeys(contents)
+	if err != nil {
+		return false, fmt.Errorf("%s: %s", k.path, err)
+	}
+
+	k.contents = contents
+	k.keys = keys
+	for _, config := range k.configs {
+		config.SetSessionTicketKeys(keys)
+	}
+
+	k.logger.Info("session-ticket-keys-loaded", zap.String("path", k.path), zap.Int("keys", len(keys)))
+	return true, nil
+}
+
+// Run reloads the key file every interval until signaled
+func (k *KeyRing) Run(signals <-chan os.Signal, ready chan<- struct{}) error {
+	close(ready)
+
+	ticker := time.NewTicker(k.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			if _, err := k.Reload(); err != nil {
+				k.logger.Error("session-ticket-keys-reload-failed", zap.Error(err))
+			}
+		case <-signals:
+			return nil
+		}
+	}
+}
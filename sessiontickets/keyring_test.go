@@ -0,0 +1,157 @@
+package sessiontickets_test
+
+import (
+	"bytes"
+	"crypto/tls"
+	"encoding/base64"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"code.cloudfoundry.org/gorouter/sessiontickets"
+	"code.cloudfoundry.org/gorouter/test_util"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("KeyRing", func() {
+	var (
+		dir  string
+		path string
+		cert tls.Certificate
+	)
+
+	key := func(b byte) string {
+		return base64.StdEncoding.EncodeToString(bytes.Repeat([]byte{b}, sessiontickets.KeySize))
+	}
+
+	writeKeys := func(keys ...string) {
+		Expect(ioutil.WriteFile(path, []byte(strings.Join(keys, "\n")+"\n"), 0600)).To(Succeed())
+	}
+
+	// handshake connects client to a server using config, reading one byte so
+	// that tickets sent after a TLS 1.3 handshake arrive
+	handshake := func(server, client *tls.Config) tls.ConnectionState {
+		serverConn, clientConn := net.Pipe()
+		defer clientConn.Close()
+
+		go func() {
+			defer serverConn.Close()
+			conn := tls.Server(serverConn, server)
+			if conn.Handshake() == nil {
+				conn.Write([]byte("x"))
+			}
+		}()
+
+		conn := tls.Client(clientConn, client)
+		Expect(conn.Handshake()).To(Succeed())
+		_, err := conn.Read(make([]byte, 1))
+		Expect(err).ToNot(HaveOccurred())
+		return conn.ConnectionState()
+	}
+
+	BeforeEach(func() {
+		var err error
+		dir, err = ioutil.TempDir("", "session-tickets")
+		Expect(err).ToNot(HaveOccurred())
+		path = filepath.Join(dir, "ticket_keys")
+		cert = generateCertificate()
+	})
+
+	AfterEach(func() {
+		os.RemoveAll(dir)
+	})
+
+	Describe("ParseKeys", func() {
+		It("parses one key per line, skipping comments", func() {
+			keys, err := sessiontickets.ParseKeys([]byte("# current\n" + key(1) + "\n\n" + key(2) + "\n"))
+			Expect(err).ToNot(HaveOccurred())
+			Expect(keys).To(HaveLen(2))
+			Expect(keys[0][0]).To(Equal(byte(1)))
+			Expect(keys[1][0]).To(Equal(byte(2)))
+		})
+
+		It("rejects invalid keys", func() {
+			_, err := sessiontickets.ParseKeys([]byte("not base64!\n"))
+			Expect(err).To(MatchError(ContainSubstring("line 1")))
+
+			_, err = sessiontickets.ParseKeys([]byte(key(1) + "\n" + base64.StdEncoding.EncodeToString([]byte("short")) + "\n"))
+			Expect(err).To(MatchError(ContainSubstring("line 2: key is 5 bytes")))
+
+			_, err = sessiontickets.ParseKeys([]byte("# nothing here\n"))
+			Expect(err).To(HaveOccurred())
+		})
+	})
+
+	It("fails when the key file cannot be loaded", func() {
+		_, err := sessiontickets.NewKeyRing(path, time.Minute, test_util.NewTestZapLogger("tickets"))
+		Expect(err).To(HaveOccurred())
+
+		writeKeys("garbage")
+		_, err = sessiontickets.NewKeyRing(path, time.Minute, test_util.NewTestZapLogger("tickets"))
+		Expect(err).To(HaveOccurred())
+	})
+
+	It("resumes sessions on any router sharing the keys", func() {
+		writeKeys(key(1))
+		ringA, err := sessiontickets.NewKeyRing(path, time.Minute, test_util.NewTestZapLogger("tickets"))
+		Expect(err).ToNot(HaveOccurred())
+		ringB, err := sessiontickets.NewKeyRing(path, time.Minute, test_util.NewTestZapLogger("tickets"))
+		Expect(err).ToNot(HaveOccurred())
+
+		routerA := &tls.Config{Certificates: []tls.Certificate{cert}}
+		routerB := &tls.Config{Certificates: []tls.Certificate{cert}}
+		ringA.Install(routerA)
+		ringB.Install(routerB)
+
+		client := &tls.Config{InsecureSkipVerify: true, ClientSessionCache: tls.NewLRUClientSessionCache(1)}
+		Expect(handshake(routerA, client).DidResume).To(BeFalse())
+		Expect(handshake(routerB, client).DidResume).To(BeTrue())
+	})
+
+	It("installs rotated keys, still decrypting tickets of the previous key", func() {
+		writeKeys(key(1))
+		ring, err := sessiontickets.NewKeyRing(path, time.Minute, test_util.NewTestZapLogger("tickets"))
+		Expect(err).ToNot(HaveOccurred())
+		router := &tls.Config{Certificates: []tls.Certificate{cert}}
+		ring.Install(router)
+
+		client := &tls.Config{InsecureSkipVerify: true, ClientSessionCache: tls.NewLRUClientSessionCache(1)}
+		handshake(router, client)
+
+		reloaded, err := ring.Reload()
+		Expect(err).ToNot(HaveOccurred())
+		Expect(reloaded).To(BeFalse())
+
+		writeKeys(key(2), key(1))
+		reloaded, err = ring.Reload()
+		Expect(err).ToNot(HaveOccurred())
+		Expect(reloaded).To(BeTrue())
+		Expect(handshake(router, client).DidResume).To(BeTrue())
+
+		writeKeys(key(3))
+		_, err = ring.Reload()
+		Expect(err).ToNot(HaveOccurred())
+		Expect(handshake(router, client).DidResume).To(BeFalse())
+	})
+
+	It("keeps the previous keys when the file becomes invalid", func() {
+		writeKeys(key(1))
+		ring, err := sessiontickets.NewKeyRing(path, time.Minute, test_util.NewTestZapLogger("tickets"))
+		Expect(err).ToNot(HaveOccurred())
+		router := &tls.Config{Certificates: []tls.Certificate{cert}}
+		ring.Install(router)
+
+		client := &tls.Config{InsecureSkipVerify: true, ClientSessionCache: tls.NewLRUClientSessionCache(1)}
+		handshake(router, client)
+
+		writeKeys("garbage")
+		_, err = ring.Reload()
+		Expect(err).To(HaveOccurred())
+		Expect(handshake(router, client).DidResume).To(BeTrue())
+	})
+})
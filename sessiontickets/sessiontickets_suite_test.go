@@ -0,0 +1,41 @@
+package sessiontickets_test
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"math/big"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"testing"
+)
+
+func TestSessionTickets(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "SessionTickets Suite")
+}
+
+// generateCertificate creates a self-signed certificate. Clients do not
+// resume sessions with a server whose certificate has expired, which rules
+// out the certificates under test/assets.
+func generateCertificate() tls.Certificate {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	Expect(err).ToNot(HaveOccurred())
+
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "127.0.0.1"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	Expect(err).ToNot(HaveOccurred())
+
+	return tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key}
+}
@@ -0,0 +1,629 @@
+// Package smuggling validates the framing of HTTP/1.1 requests before the
+// HTTP server parses them. Go's parser quietly resolves ambiguities a backend
+// or another proxy might resolve differently, such as a request carrying
+// both Content-Length and Transfer-Encoding, which hides them from handlers.
+// Conns returned by the listener read the raw byte stream, request by
+// request, and normalize or reject requests that could be used to smuggle a
+// second request past the router.
+package smuggling
+
+import (
+	"bytes"
+	"crypto/tls"
+	"io"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/uber-go/zap"
+
+	router_http "code.cloudfoundry.org/gorouter/common/http"
+	"code.cloudfoundry.org/gorouter/config"
+	"code.cloudfoundry.org/gorouter/logger"
+	"code.cloudfoundry.org/gorouter/metrics"
+)
+
+// Violations
+const (
+	// ViolationConflictingFraming is a request with both Content-Length and
+	// Transfer-Encoding. Normalized by removing Content-Length.
+	ViolationConflictingFraming = "conflicting_framing"
+	// ViolationDuplicateContentLength is a request repeating the same
+	// Content-Length. Normalized by keeping one.
+	ViolationDuplicateContentLength = "duplicate_content_length"
+	// ViolationInvalidContentLength is a malformed Content-Length, or
+	// several that differ
+	ViolationInvalidContentLength = "invalid_content_length"
+	// ViolationInvalidTransferEncoding is a Transfer-Encoding other than
+	// chunked
+	ViolationInvalidTransferEncoding = "invalid_transfer_encoding"
+	// ViolationObsFold is a header value continued on the next line.
+	// Normalized by joining the lines.
+	ViolationObsFold = "obs_fold"
+	// ViolationInvalidHeaderName is a header line whose name is not a token,
+	// such as "Transfer-Encoding : chunked"
+	ViolationInvalidHeaderName = "invalid_header_name"
+	// ViolationInvalidChunk is a malformed chunk size or chunk terminator
+	ViolationInvalidChunk = "invalid_chunk"
+	// ViolationInvalidChunkExtension is a malformed chunk extension.
+	// Normalized by removing the extensions of the chunk.
+	ViolationInvalidChunkExtension = "invalid_chunk_extension"
+)
+
+// maxChunkLineBytes bounds chunk size lines, extensions included
+const maxChunkLineBytes = 4096
+
+// rejection is what a rejected request head is replaced with: a malformed
+// request line, which the HTTP server answers with a 400 before closing the
+// conn
+var rejection = []byte("INVALID\r\n\r\n")
+
+type listener struct {
+	net.Listener
+	level    string
+	reporter metrics.RequestValidationReporter
+	logger   logger.Logger
+}
+
+// NewListener validates the requests read from the conns l accepts at one of
+// the config.RequestValidationLevels
+func NewListener(l net.Listener, level string, reporter metrics.RequestValidationReporter, logger logger.Logger) net.Listener {
+	if level == config.REQUEST_VALIDATION_OFF || level == "" {
+		return l
+	}
+	return &listener{Listener: l, level: level, reporter: reporter, logger: logger}
+}
+
+// Passthrough stops validating what is read from conn once a handler hijacked
+// it, e.g. to switch protocols, as what follows is no longer read by the HTTP
+// server. Requests asking for an upgrade are validated as any other until
+// then, as the server reads the next request when the upgrade is refused.
+func Passthrough(conn net.Conn) {
+	if c, ok := conn.(*Conn); ok && c.state != stateRejected {
+		c.state = statePassthrough
+	}
+}
+
+func (l *listener) Accept() (net.Conn, error) {
+	c, err := l.Listener.Accept()
+	if err != nil {
+		return nil, err
+	}
+	return &Conn{Conn: c, listener: l}, nil
+}
+
+type state int
+
+const (
+	stateHead state = iota
+	stateBody
+	stateChunkSize
+	stateChunkData
+	stateChunkEnd
+	stateTrailer
+	statePassthrough
+	stateRejected
+)
+
+// Conn validates the requests read from it
+type Conn struct {
+	net.Conn
+	listener *listener
+
+	in        []byte
+	out       []byte
+	err       error
+	state     state
+	remaining int64
+}
+
+// TLSConnectionState returns the state of the TLS conn wrapped, as the HTTP
+// server only sets Request.TLS for conns it can see are TLS conns
+func (c *Conn) TLSConnectionState() (tls.ConnectionState, bool) {
+	if tlsConn, ok := c.Conn.(*tls.Conn); ok {
+		return tlsConn.ConnectionState(), true
+	}
+	return tls.ConnectionState{}, false
+}
+
+func (c *Conn) ReadFrom(r io.Reader) (int64, error) {
+	// only what is read from the conn needs validating
+	return router_http.ReadFrom(c.Conn, r)
+}
+
+func (c *Conn) Read(p []byte) (int, error) {
+	for len(c.out) == 0 {
+		if c.state == stateRejected {
+			return 0, io.EOF
+		}
+		if !c.process() {
+			if c.err != nil {
+				if isTimeout(c.err) {
+					// the server also aborts its pending read this way
+					// when a handler hijacks the conn, which is read
+					// from again
+					err := c.err
+					c.err = nil
+					return 0, err
+				}
+				if len(c.in) > 0 {
+					// let the server see whatever was left, it fails
+					// to parse it the same way with or without us
+					c.out, c.in = c.in, nil
+					break
+				}
+				return 0, c.err
+			}
+			c.fill()
+		}
+	}
+
+	n := copy(p, c.out)
+	c.out = c.out[n:]
+	return n, nil
+}
+
+func (c *Conn) fill() {
+	buf := make([]byte, 4096)
+	n, err := c.Conn.Read(buf)
+	c.in = append(c.in, buf[:n]...)
+	if err != nil {
+		c.err = err
+	}
+}
+
+// process moves what it can from in to out, and reports whether it did
+func (c *Conn) process() bool {
+	progressed := false
+	for len(c.in) > 0 {
+		var ok bool
+		switch c.state {
+		case stateHead:
+			ok = c.processHead()
+		case stateBody, stateChunkData:
+			n := int64(len(c.in))
+			if n > c.remaining {
+				n = c.remaining
+			}
+			c.emit(int(n))
+			c.remaining -= n
+			if c.remaining == 0 {
+				if c.state == stateBody {
+					c.state = stateHead
+				} else {
+					c.state = stateChunkEnd
+				}
+			}
+			ok = true
+		case stateChunkSize:
+			ok = c.processChunkSize()
+		case stateChunkEnd:
+			ok = c.processChunkEnd()
+		case stateTrailer:
+			ok = c.processTrailer()
+		case statePassthrough:
+			c.emit(len(c.in))
+			ok = true
+		case stateRejected:
+			c.in = nil
+			return true
+		}
+		if !ok {
+			break
+		}
+		progressed = true
+	}
+	return progressed
+}
+
+func (c *Conn) emit(n int) {
+	c.out = append(c.out, c.in[:n]...)
+	c.in = c.in[n:]
+}
+
+// violation reports v, and whether the request is rejected for it
+func (c *Conn) violation(v string, normalizable bool) bool {
+	rejected := c.listener.level == config.REQUEST_VALIDATION_REJECT || (c.listener.level == config.REQUEST_VALIDATION_NORMALIZE && !normalizable)
+	c.listener.reporter.CaptureInvalidRequest(v, rejected)
+	c.listener.logger.Info("invalid-request",
+		zap.String("violation", v),
+		zap.Bool("rejected", rejected),
+		zap.String("remote_addr", c.RemoteAddr().String()),
+	)
+	return rejected
+}
+
+// reject ends the conn. A rejected request head is replaced so the server
+// answers it, while a body is cut short, failing the request being proxied.
+func (c *Conn) reject() {
+	if c.state == stateHead {
+		c.out = append(c.out, rejection...)
+	}
+	c.in = nil
+	c.state = stateRejected
+}
+
+func (c *Conn) normalizing() bool {
+	return c.listener.level != config.REQUEST_VALIDATION_MONITOR
+}
+
+func (c *Conn) processHead() bool {
+	end := headEnd(c.in)
+	if end < 0 {
+		if len(c.in) > http.DefaultMaxHeaderBytes+4096 {
+			// too large for the server to accept anyway
+			c.state = statePassthrough
+			return true
+		}
+		return false
+	}
+
+	head := c.in[:end]
+	lines := bytes.SplitAfter(head, []byte("\n"))
+
+	// empty lines may precede the request line
+	first := 0
+	for first < len(lines) && len(bytes.TrimRight(lines[first], "\r\n")) == 0 {
+		first++
+	}
+	if first == len(lines) {
+		c.emit(end)
+		return true
+	}
+
+	requestLine := string(bytes.TrimRight(lines[first], "\r\n"))
+	fields := strings.Split(requestLine, " ")
+	if len(fields) != 3 || !strings.HasPrefix(fields[2], "HTTP/1.") {
+		// HTTP/2 prior knowledge, or garbage the server rejects itself
+		c.state = statePassthrough
+		return true
+	}
+
+	var (
+		headers        [][]byte
+		contentLengths []string
+		encodings      []string
+		folded         bool
+	)
+	for _, line := range lines[first+1:] {
+		text := bytes.TrimRight(line, "\r\n")
+		if len(text) == 0 {
+			continue
+		}
+
+		if text[0] == ' ' || text[0] == '\t' {
+			if c.violation(ViolationObsFold, len(headers) > 0) {
+				c.reject()
+				return true
+			}
+			if len(headers) > 0 && c.normalizing() {
+				last := bytes.TrimRight(headers[len(headers)-1], "\r\n")
+				headers[len(headers)-1] = append(append(append([]byte(nil), last...), ' '), bytes.TrimLeft(text, " \t")...)
+				folded = true
+				continue
+			}
+			headers = append(headers, text)
+			continue
+		}
+
+		colon := bytes.IndexByte(text, ':')
+		if colon <= 0 || !isToken(text[:colon]) {
+			if c.violation(ViolationInvalidHeaderName, false) {
+				c.reject()
+				return true
+			}
+			headers = append(headers, text)
+			continue
+		}
+
+		name := string(text[:colon])
+		value := strings.TrimSpace(string(text[colon+1:]))
+		switch {
+		case strings.EqualFold(name, "Content-Length"):
+			contentLengths = append(contentLengths, value)
+		case strings.EqualFold(name, "Transfer-Encoding"):
+			encodings = append(encodings, value)
+		}
+		headers = append(headers, text)
+	}
+
+	dropContentLength, dedupContentLength := false, false
+	chunked := false
+
+	if len(encodings) > 0 {
+		codings := strings.Split(strings.Join(encodings, ","), ",")
+		if len(codings) != 1 || !strings.EqualFold(strings.TrimSpace(codings[0]), "chunked") {
+			if c.violation(ViolationInvalidTransferEncoding, false) {
+				c.reject()
+				return true
+			}
+			// the server refuses codings it does not know
+			c.state = statePassthrough
+			return true
+		}
+		chunked = true
+
+		if len(contentLengths) > 0 {
+			if c.violation(ViolationConflictingFraming, true) {
+				c.reject()
+				return true
+			}
+			dropContentLength = true
+		}
+	}
+
+	length := int64(0)
+	if !chunked && len(contentLengths) > 0 {
+		values := strings.Split(strings.Join(contentLengths, ","), ",")
+		for i, value := range values {
+			value = strings.TrimSpace(value)
+			n, err := strconv.ParseInt(value, 10, 64)
+			if err != nil || n < 0 || value[0] == '+' || (i > 0 && n != length) {
+				if c.violation(ViolationInvalidContentLength, false) {
+					c.reject()
+					return true
+				}
+				c.state = statePassthrough
+				return true
+			}
+			length = n
+		}
+		if len(values) > 1 {
+			if c.violation(ViolationDuplicateContentLength, true) {
+				c.reject()
+				return true
+			}
+			dedupContentLength = true
+		}
+	}
+
+	if c.normalizing() && (folded || dropContentLength || dedupContentLength) {
+		var b bytes.Buffer
+		for _, line := range lines[:first+1] {
+			b.Write(line)
+		}
+		wroteContentLength := false
+		for _, header := range headers {
+			if isHeader(header, "Content-Length") {
+				if dropContentLength || wroteContentLength {
+					continue
+				}
+				if dedupContentLength {
+					header = []byte("Content-Length: " + strconv.FormatInt(length, 10))
+				}
+				wroteContentLength = true
+			}
+			b.Write(header)
+			b.WriteString("\r\n")
+		}
+		b.WriteString("\r\n")
+		c.out = append(c.out, b.Bytes()...)
+		c.in = c.in[end:]
+	} else {
+		c.emit(end)
+	}
+
+	switch {
+	case chunked:
+		c.state = stateChunkSize
+	case length > 0:
+		c.state = stateBody
+		c.remaining = length
+	default:
+		c.state = stateHead
+	}
+	return true
+}
+
+func (c *Conn) processChunkSize() bool {
+	i := bytes.IndexByte(c.in, '\n')
+	if i < 0 {
+		if len(c.in) > maxChunkLineBytes {
+			return c.invalidChunk()
+		}
+		return false
+	}
+	if i+1 > maxChunkLineBytes || i == 0 || c.in[i-1] != '\r' {
+		return c.invalidChunk()
+	}
+
+	line := string(c.in[:i-1])
+	size, extensions := line, ""
+	if semi := strings.IndexByte(line, ';'); semi >= 0 {
+		size, extensions = line[:semi], line[semi:]
+	}
+	size = strings.TrimRight(size, " \t")
+
+	n, err := strconv.ParseInt(size, 16, 64)
+	if err != nil || n < 0 || len(size) == 0 || len(size) > 16 || !isHex(size) {
+		return c.invalidChunk()
+	}
+
+	if extensions != "" && !validExtensions(extensions) {
+		if c.violation(ViolationInvalidChunkExtension, true) {
+			c.reject()
+			return true
+		}
+		if c.normalizing() {
+			c.out = append(c.out, size+"\r\n"...)
+			c.in = c.in[i+1:]
+			c.startChunk(n)
+			return true
+		}
+	}
+
+	c.emit(i + 1)
+	c.startChunk(n)
+	return true
+}
+
+func (c *Conn) startChunk(size int64) {
+	if size == 0 {
+		c.state = stateTrailer
+		return
+	}
+	c.state = stateChunkData
+	c.remaining = size
+}
+
+func (c *Conn) processChunkEnd() bool {
+	if len(c.in) < 2 {
+		if len(c.in) == 1 && c.in[0] != '\r' {
+			return c.invalidChunk()
+		}
+		return false
+	}
+	if c.in[0] != '\r' || c.in[1] != '\n' {
+		return c.invalidChunk()
+	}
+	c.emit(2)
+	c.state = stateChunkSize
+	return true
+}
+
+func (c *Conn) processTrailer() bool {
+	i := bytes.IndexByte(c.in, '\n')
+	if i < 0 {
+		if len(c.in) > http.DefaultMaxHeaderBytes {
+			c.state = statePassthrough
+			return true
+		}
+		return false
+	}
+
+	line := bytes.TrimRight(c.in[:i], "\r")
+	c.emit(i + 1)
+	if len(line) == 0 {
+		c.state = stateHead
+	}
+	return true
+}
+
+// invalidChunk handles chunked bodies whose framing is lost
+func (c *Conn) invalidChunk() bool {
+	if c.violation(ViolationInvalidChunk, false) {
+		c.reject()
+		return true
+	}
+	c.state = statePassthrough
+	return true
+}
+
+// headEnd returns the length of the request head at the start of b,
+// including the empty line ending it, or -1 when it is incomplete
+func headEnd(b []byte) int {
+	start := 0
+	// skip the empty lines that may precede the request line
+	for start < len(b) && (b[start] == '\r' || b[start] == '\n') {
+		start++
+	}
+	for i := start; i < len(b); i++ {
+		if b[i] != '\n' {
+			continue
+		}
+		switch {
+		case i+1 < len(b) && b[i+1] == '\n':
+			return i + 2
+		case i+2 < len(b) && b[i+1] == '\r' && b[i+2] == '\n':
+			return i + 3
+		}
+	}
+	return -1
+}
+
+func isHeader(line []byte, name string) bool {
+	colon := bytes.IndexByte(line, ':')
+	return colon > 0 && strings.EqualFold(string(line[:colon]), name)
+}
+
+// validExtensions reports whether s is a list of chunk extensions:
+// *( BWS ";" BWS ext-name [ BWS "=" BWS ( token / quoted-string ) ] )
+func validExtensions(s string) bool {
+	for len(s) > 0 {
+		s = strings.TrimLeft(s, " \t")
+		if len(s) == 0 || s[0] != ';' {
+			return false
+		}
+		s = strings.TrimLeft(s[1:], " \t")
+
+		name := tokenPrefix(s)
+		if name == 0 {
+			return false
+		}
+		s = strings.TrimLeft(s[name:], " \t")
+
+		if len(s) == 0 || s[0] != '=' {
+			continue
+		}
+		s = strings.TrimLeft(s[1:], " \t")
+
+		if len(s) > 0 && s[0] == '"' {
+			end := quotedStringEnd(s)
+			if end < 0 {
+				return false
+			}
+			s = s[end:]
+			continue
+		}
+		value := tokenPrefix(s)
+		if value == 0 {
+			return false
+		}
+		s = s[value:]
+	}
+	return true
+}
+
+func tokenPrefix(s string) int {
+	i := 0
+	for i < len(s) && isTokenChar(s[i]) {
+		i++
+	}
+	return i
+}
+
+// quotedStringEnd returns the length of the quoted string s starts with, or
+// -1 when it is not terminated
+func quotedStringEnd(s string) int {
+	for i := 1; i < len(s); i++ {
+		switch s[i] {
+		case '\\':
+			i++
+		case '"':
+			return i + 1
+		}
+	}
+	return -1
+}
+
+func isTimeout(err error) bool {
+	netErr, ok := err.(net.Error)
+	return ok && netErr.Timeout()
+}
+
+func isToken(b []byte) bool {
+	for _, c := range b {
+		if !isTokenChar(c) {
+			return false
+		}
+	}
+	return len(b) > 0
+}
+
+func isTokenChar(c byte) bool {
+	if 'a' <= c && c <= 'z' || 'A' <= c && c <= 'Z' || '0' <= c && c <= '9' {
+		return true
+	}
+	return strings.IndexByte("!#$%&'*+-.^_`|~", c) >= 0
+}
+
+func isHex(s string) bool {
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if !('0' <= c && c <= '9' || 'a' <= c && c <= 'f' || 'A' <= c && c <= 'F') {
+			return false
+		}
+	}
+	return true
+}
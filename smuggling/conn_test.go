@@ -0,0 +1,248 @@
+package smuggling_test
+
+import (
+	"bufio"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+
+	"code.cloudfoundry.org/gorouter/config"
+	"code.cloudfoundry.org/gorouter/metrics/fakes"
+	"code.cloudfoundry.org/gorouter/smuggling"
+	"code.cloudfoundry.org/gorouter/test_util"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+type pipeListener struct {
+	conns chan net.Conn
+}
+
+func (l *pipeListener) Accept() (net.Conn, error) {
+	conn, ok := <-l.conns
+	if !ok {
+		return nil, fmt.Errorf("listener closed")
+	}
+	return conn, nil
+}
+
+func (l *pipeListener) Close() error   { return nil }
+func (l *pipeListener) Addr() net.Addr { return &net.TCPAddr{} }
+
+var _ = Describe("Conn", func() {
+	var (
+		level    string
+		reporter *fakes.FakeRequestValidationReporter
+		received []string
+	)
+
+	// send writes raw to a server behind the listener, and returns the status
+	// codes of the responses read until the server closes the conn or
+	// expected responses were read
+	send := func(raw string, expected int) []int {
+		pipe := &pipeListener{conns: make(chan net.Conn, 1)}
+		defer close(pipe.conns)
+
+		listener := smuggling.NewListener(pipe, level, reporter, test_util.NewTestZapLogger("smuggling"))
+		server := &http.Server{Handler: http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+			body, err := ioutil.ReadAll(req.Body)
+			if err != nil {
+				received = append(received, fmt.Sprintf("%s %s error", req.Method, req.URL.Path))
+				return
+			}
+			received = append(received, fmt.Sprintf("%s %s %s X=%s", req.Method, req.URL.Path, body, req.Header.Get("X")))
+		})}
+		go server.Serve(listener)
+
+		client, conn := net.Pipe()
+		defer client.Close()
+		pipe.conns <- conn
+		go client.Write([]byte(raw))
+
+		var codes []int
+		reader := bufio.NewReader(client)
+		for len(codes) < expected {
+			resp, err := http.ReadResponse(reader, nil)
+			if err != nil {
+				break
+			}
+			ioutil.ReadAll(resp.Body)
+			resp.Body.Close()
+			codes = append(codes, resp.StatusCode)
+		}
+		return codes
+	}
+
+	violations := func() []string {
+		var vs []string
+		for i := 0; i < reporter.CaptureInvalidRequestCallCount(); i++ {
+			v, rejected := reporter.CaptureInvalidRequestArgsForCall(i)
+			vs = append(vs, fmt.Sprintf("%s rejected=%t", v, rejected))
+		}
+		return vs
+	}
+
+	BeforeEach(func() {
+		reporter = new(fakes.FakeRequestValidationReporter)
+		received = nil
+	})
+
+	It("does not wrap listeners when validation is off", func() {
+		pipe := &pipeListener{}
+		Expect(smuggling.NewListener(pipe, config.REQUEST_VALIDATION_OFF, reporter, test_util.NewTestZapLogger("smuggling"))).To(BeIdenticalTo(pipe))
+	})
+
+	Context("at the normalize level", func() {
+		BeforeEach(func() {
+			level = config.REQUEST_VALIDATION_NORMALIZE
+		})
+
+		It("passes valid pipelined requests through", func() {
+			codes := send("POST /a HTTP/1.1\r\nHost: a\r\nContent-Length: 3\r\n\r\nabc"+
+				"POST /b HTTP/1.1\r\nHost: a\r\nTransfer-Encoding: chunked\r\n\r\n3;ext=1\r\nabc\r\n0\r\nTrailer: 1\r\n\r\n"+
+				"GET /c HTTP/1.1\r\nHost: a\r\n\r\n", 3)
+
+			Expect(codes).To(Equal([]int{200, 200, 200}))
+			Expect(received).To(Equal([]string{"POST /a abc X=", "POST /b abc X=", "GET /c  X="}))
+			Expect(reporter.CaptureInvalidRequestCallCount()).To(Equal(0))
+		})
+
+		It("drops the Content-Length of chunked requests", func() {
+			codes := send("POST /a HTTP/1.1\r\nHost: a\r\nContent-Length: 4\r\nTransfer-Encoding: chunked\r\n\r\n3\r\nabc\r\n0\r\n\r\n"+
+				"GET /b HTTP/1.1\r\nHost: a\r\n\r\n", 2)
+
+			Expect(codes).To(Equal([]int{200, 200}))
+			Expect(received).To(Equal([]string{"POST /a abc X=", "GET /b  X="}))
+			Expect(violations()).To(Equal([]string{"conflicting_framing rejected=false"}))
+		})
+
+		It("keeps one of several equal Content-Lengths", func() {
+			codes := send("POST /a HTTP/1.1\r\nHost: a\r\nContent-Length: 3\r\nContent-Length: 3\r\n\r\nabc", 1)
+
+			Expect(codes).To(Equal([]int{200}))
+			Expect(received).To(Equal([]string{"POST /a abc X="}))
+			Expect(violations()).To(Equal([]string{"duplicate_content_length rejected=false"}))
+		})
+
+		It("unfolds folded header values", func() {
+			codes := send("GET /a HTTP/1.1\r\nHost: a\r\nX: one\r\n two\r\n\r\n", 1)
+
+			Expect(codes).To(Equal([]int{200}))
+			Expect(received).To(Equal([]string{"GET /a  X=one two"}))
+			Expect(violations()).To(Equal([]string{"obs_fold rejected=false"}))
+		})
+
+		It("strips malformed chunk extensions", func() {
+			codes := send("POST /a HTTP/1.1\r\nHost: a\r\nTransfer-Encoding: chunked\r\n\r\n3;ext=\"open\r\nabc\r\n0\r\n\r\n", 1)
+
+			Expect(codes).To(Equal([]int{200}))
+			Expect(received).To(Equal([]string{"POST /a abc X="}))
+			Expect(violations()).To(Equal([]string{"invalid_chunk_extension rejected=false"}))
+		})
+
+		It("rejects differing Content-Lengths", func() {
+			codes := send("POST /a HTTP/1.1\r\nHost: a\r\nContent-Length: 3\r\nContent-Length: 30\r\n\r\nabc", 1)
+
+			Expect(codes).To(Equal([]int{http.StatusBadRequest}))
+			Expect(received).To(BeEmpty())
+			Expect(violations()).To(Equal([]string{"invalid_content_length rejected=true"}))
+		})
+
+		It("rejects transfer codings other than chunked", func() {
+			codes := send("POST /a HTTP/1.1\r\nHost: a\r\nTransfer-Encoding: identity, chunked\r\n\r\n0\r\n\r\n", 1)
+
+			Expect(codes).To(Equal([]int{http.StatusBadRequest}))
+			Expect(violations()).To(Equal([]string{"invalid_transfer_encoding rejected=true"}))
+		})
+
+		It("rejects header names that are not tokens", func() {
+			codes := send("POST /a HTTP/1.1\r\nHost: a\r\nTransfer-Encoding : chunked\r\n\r\n", 1)
+
+			Expect(codes).To(Equal([]int{http.StatusBadRequest}))
+			Expect(violations()).To(Equal([]string{"invalid_header_name rejected=true"}))
+		})
+
+		It("cuts short bodies with malformed chunks", func() {
+			send("POST /a HTTP/1.1\r\nHost: a\r\nTransfer-Encoding: chunked\r\n\r\n3\nabc\r\n0\r\n\r\n"+
+				"GET /b HTTP/1.1\r\nHost: a\r\n\r\n", 2)
+
+			Expect(received).To(Equal([]string{"POST /a error"}))
+			Expect(violations()).To(Equal([]string{"invalid_chunk rejected=true"}))
+		})
+
+		It("keeps validating the requests following an upgrade that was not switched", func() {
+			codes := send("GET /a HTTP/1.1\r\nHost: a\r\nUpgrade: websocket\r\nConnection: Upgrade\r\n\r\n"+
+				"POST /b HTTP/1.1\r\nHost: a\r\nContent-Length: 4\r\nTransfer-Encoding: chunked\r\n\r\n3\r\nabc\r\n0\r\n\r\n"+
+				"GET /c HTTP/1.1\r\nHost: a\r\n\r\n", 3)
+
+			Expect(codes).To(Equal([]int{200, 200, 200}))
+			Expect(received).To(Equal([]string{"GET /a  X=", "POST /b abc X=", "GET /c  X="}))
+			Expect(violations()).To(Equal([]string{"conflicting_framing rejected=false"}))
+		})
+
+		It("passes conns through once hijacked", func() {
+			pipe := &pipeListener{conns: make(chan net.Conn, 1)}
+			defer close(pipe.conns)
+
+			relayed := make(chan string, 1)
+			listener := smuggling.NewListener(pipe, level, reporter, test_util.NewTestZapLogger("smuggling"))
+			server := &http.Server{Handler: http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+				conn, buf, err := rw.(http.Hijacker).Hijack()
+				if err != nil {
+					return
+				}
+				defer conn.Close()
+				smuggling.Passthrough(conn)
+
+				buf.WriteString("HTTP/1.1 101 Switching Protocols\r\nUpgrade: raw\r\nConnection: Upgrade\r\n\r\n")
+				buf.Flush()
+				line, _ := buf.ReadString('\n')
+				relayed <- line
+			})}
+			go server.Serve(listener)
+
+			client, conn := net.Pipe()
+			defer client.Close()
+			pipe.conns <- conn
+			go client.Write([]byte("GET /a HTTP/1.1\r\nHost: a\r\nUpgrade: raw\r\nConnection: Upgrade\r\n\r\n"))
+
+			resp, err := http.ReadResponse(bufio.NewReader(client), nil)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(resp.StatusCode).To(Equal(http.StatusSwitchingProtocols))
+
+			go client.Write([]byte("Transfer-Encoding : chunked\n"))
+			Eventually(relayed).Should(Receive(Equal("Transfer-Encoding : chunked\n")))
+			Expect(reporter.CaptureInvalidRequestCallCount()).To(Equal(0))
+		})
+	})
+
+	Context("at the monitor level", func() {
+		BeforeEach(func() {
+			level = config.REQUEST_VALIDATION_MONITOR
+		})
+
+		It("counts violations without rejecting them", func() {
+			codes := send("POST /a HTTP/1.1\r\nHost: a\r\nContent-Length: 4\r\nTransfer-Encoding: chunked\r\n\r\n3\r\nabc\r\n0\r\n\r\n", 1)
+
+			Expect(codes).To(Equal([]int{200}))
+			Expect(violations()).To(Equal([]string{"conflicting_framing rejected=false"}))
+		})
+	})
+
+	Context("at the reject level", func() {
+		BeforeEach(func() {
+			level = config.REQUEST_VALIDATION_REJECT
+		})
+
+		It("rejects violations that could be normalized", func() {
+			codes := send("POST /a HTTP/1.1\r\nHost: a\r\nContent-Length: 4\r\nTransfer-Encoding: chunked\r\n\r\n3\r\nabc\r\n0\r\n\r\n"+
+				"GET /b HTTP/1.1\r\nHost: a\r\n\r\n", 2)
+
+			Expect(codes).To(Equal([]int{http.StatusBadRequest}))
+			Expect(received).To(BeEmpty())
+			Expect(violations()).To(Equal([]string{"conflicting_framing rejected=true"}))
+		})
+	})
+})
@@ -0,0 +1,13 @@
+package smuggling_test
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"testing"
+)
+
+func TestSmuggling(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "Smuggling Suite")
+}
@@ -0,0 +1,13 @@
+package staticroutes_test
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"testing"
+)
+
+func TestStaticRoutes(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "StaticRoutes Suite")
+}
@@ -0,0 +1,189 @@
+package staticroutes
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/uber-go/zap"
+	"gopkg.in/yaml.v2"
+
+	"code.cloudfoundry.org/gorouter/logger"
+	"code.cloudfoundry.org/gorouter/registry"
+	"code.cloudfoundry.org/gorouter/route"
+	"code.cloudfoundry.org/routing-api/models"
+)
+
+// File is the content of a static routes file. JSON files are accepted as
+// well, being a subset of YAML.
+type File struct {
+	Routes []Route `yaml:"routes"`
+}
+
+// Route registers a backend under one or more URIs. Its fields mirror those
+// of a NATS registration message.
+type Route struct {
+	Host                 string            `yaml:"host"`
+	Port                 uint16            `yaml:"port"`
+	Uris                 []route.Uri       `yaml:"uris"`
+	Tags                 map[string]string `yaml:"tags"`
+	App                  string            `yaml:"app"`
+	RouteServiceURL      string            `yaml:"route_service_url"`
+	PrivateInstanceID    string            `yaml:"private_instance_id"`
+	PrivateInstanceIndex string            `yaml:"private_instance_index"`
+	IsolationSegment     string            `yaml:"isolation_segment"`
+}
+
+func (r *Route) makeEndpoint() *route.Endpoint {
+	return route.NewEndpoint(
+		r.App,
+		r.Host,
+		r.Port,
+		r.PrivateInstanceID,
+		r.PrivateInstanceIndex,
+		r.Tags,
+		0,
+		r.RouteServiceURL,
+		models.ModificationTag{},
+		r.IsolationSegment,
+	)
+}
+
+// key identifies a registration independently of its attributes, so that a
+// changed route is updated in place rather than removed and added again
+func key(uri route.Uri, r *Route) string {
+	return fmt.Sprintf("%s|%s:%d", uri, r.Host, r.Port)
+}
+
+// Parse parses and validates the content of a static routes file
+func Parse(data []byte) (*File, error) {
+	var file File
+	if err := yaml.Unmarshal(data, &file); err != nil {
+		return nil, err
+	}
+
+	for i, r := range file.Routes {
+		if r.Host == "" || r.Port == 0 {
+			return nil, fmt.Errorf("route %d: host and port are required", i)
+		}
+		if len(r.Uris) == 0 {
+			return nil, fmt.Errorf("route %d: at least one uri is required", i)
+		}
+		if r.RouteServiceURL != "" && !strings.HasPrefix(r.RouteServiceURL, "https") {
+			return nil, fmt.Errorf("route %d: route_service_url must use https", i)
+		}
+	}
+
+	return &file, nil
+}
+
+// Watcher keeps a registry in sync with a static routes file. The file is
+// polled for changes; routes added to it are registered, changed routes are
+// updated and removed routes are unregistered. Every route is re-registered
+// on each poll so that it is never pruned as stale.
+type Watcher struct {
+	registry registry.Registry
+	path     string
+	interval time.Duration
+	logger   logger.Logger
+
+	content []byte
+	routes  map[string]registration
+}
+
+type registration struct {
+	uri   route.Uri
+	route Route
+}
+
+// NewWatcher returns a Watcher applying the routes in path to registry
+func NewWatcher(registry registry.Registry, path string, interval time.Duration, logger logger.Logger) *Watcher {
+	return &Watcher{
+		registry: registry,
+		path:     path,
+		interval: interval,
+		logger:   logger,
+		routes:   map[string]registration{},
+	}
+}
+
+// Run syncs the registry with the file until signaled
+func (w *Watcher) Run(signals <-chan os.Signal, ready chan<- struct{}) error {
+	if err := w.Sync(); err != nil {
+		w.logger.Error("static-routes-sync-failed", zap.String("path", w.path), zap.Error(err))
+	}
+
+	close(ready)
+	w.logger.Info("static-routes-watcher-started", zap.String("path", w.path))
+
+	ticker := time.NewTicker(w.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			if err := w.Sync(); err != nil {
+				w.logger.Error("static-routes-sync-failed", zap.String("path", w.path), zap.Error(err))
+			}
+		case <-signals:
+			w.logger.Info("static-routes-watcher-exited")
+			return nil
+		}
+	}
+}
+
+// Sync reloads the file when it has changed and refreshes every route in the
+// registry. A file that fails to load leaves the previous routes in place.
+func (w *Watcher) Sync() error {
+	data, err := ioutil.ReadFile(w.path)
+	if err != nil {
+		w.refresh()
+		return err
+	}
+
+	if w.content != nil && bytes.Equal(data, w.content) {
+		w.refresh()
+		return nil
+	}
+
+	file, err := Parse(data)
+	if err != nil {
+		w.refresh()
+		return err
+	}
+	w.content = data
+
+	desired := map[string]registration{}
+	for _, r := range file.Routes {
+		for _, uri := range r.Uris {
+			desired[key(uri, &r)] = registration{uri: uri, route: r}
+		}
+	}
+
+	removed := 0
+	for k, reg := range w.routes {
+		if _, ok := desired[k]; !ok {
+			w.registry.Unregister(reg.uri, reg.route.makeEndpoint())
+			removed++
+		}
+	}
+
+	w.logger.Info("static-routes-loaded",
+		zap.String("path", w.path),
+		zap.Int("routes", len(desired)),
+		zap.Int("removed", removed),
+	)
+
+	w.routes = desired
+	w.refresh()
+	return nil
+}
+
+func (w *Watcher) refresh() {
+	for _, reg := range w.routes {
+		w.registry.Register(reg.uri, reg.route.makeEndpoint())
+	}
+}
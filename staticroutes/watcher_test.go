@@ -0,0 +1,120 @@
+package staticroutes_test
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"code.cloudfoundry.org/gorouter/config"
+	"code.cloudfoundry.org/gorouter/metrics/fakes"
+	"code.cloudfoundry.org/gorouter/registry"
+	"code.cloudfoundry.org/gorouter/route"
+	"code.cloudfoundry.org/gorouter/staticroutes"
+	"code.cloudfoundry.org/gorouter/test_util"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Watcher", func() {
+	var (
+		tmpDir  string
+		path    string
+		table   *registry.RouteRegistry
+		watcher *staticroutes.Watcher
+	)
+
+	writeRoutes := func(content string) {
+		Expect(ioutil.WriteFile(path, []byte(content), 0644)).To(Succeed())
+	}
+
+	addresses := func(uri route.Uri) []string {
+		pool := table.Lookup(uri)
+		if pool == nil {
+			return nil
+		}
+		var addrs []string
+		pool.Each(func(e *route.Endpoint) {
+			addrs = append(addrs, e.CanonicalAddr())
+		})
+		return addrs
+	}
+
+	tags := func(uri route.Uri, addr string) map[string]string {
+		var t map[string]string
+		table.Lookup(uri).Each(func(e *route.Endpoint) {
+			if e.CanonicalAddr() == addr {
+				t = e.Tags
+			}
+		})
+		return t
+	}
+
+	BeforeEach(func() {
+		var err error
+		tmpDir, err = ioutil.TempDir("", "staticroutes")
+		Expect(err).NotTo(HaveOccurred())
+		path = filepath.Join(tmpDir, "routes.yml")
+
+		logger := test_util.NewTestZapLogger("staticroutes")
+		table = registry.NewRouteRegistry(logger, config.DefaultConfig(), new(fakes.FakeRouteRegistryReporter))
+		watcher = staticroutes.NewWatcher(table, path, 0, logger)
+
+		writeRoutes(`
+routes:
+- host: 10.0.0.1
+  port: 8080
+  uris: [app.example.com, www.example.com]
+  tags: {component: web}
+- host: 10.0.0.2
+  port: 8080
+  uris: [app.example.com]
+`)
+		Expect(watcher.Sync()).To(Succeed())
+	})
+
+	AfterEach(func() {
+		os.RemoveAll(tmpDir)
+	})
+
+	It("registers the routes in the file", func() {
+		Expect(addresses("app.example.com")).To(ConsistOf("10.0.0.1:8080", "10.0.0.2:8080"))
+		Expect(addresses("www.example.com")).To(ConsistOf("10.0.0.1:8080"))
+		Expect(tags("app.example.com", "10.0.0.1:8080")).To(HaveKeyWithValue("component", "web"))
+	})
+
+	It("applies added, changed and removed routes", func() {
+		writeRoutes(`{"routes": [
+  {"host": "10.0.0.1", "port": 8080, "uris": ["app.example.com"], "tags": {"component": "api"}},
+  {"host": "10.0.0.3", "port": 9090, "uris": ["new.example.com"]}
+]}`)
+		Expect(watcher.Sync()).To(Succeed())
+
+		Expect(addresses("app.example.com")).To(ConsistOf("10.0.0.1:8080"))
+		Expect(addresses("www.example.com")).To(BeEmpty())
+		Expect(addresses("new.example.com")).To(ConsistOf("10.0.0.3:9090"))
+		Expect(tags("app.example.com", "10.0.0.1:8080")).To(HaveKeyWithValue("component", "api"))
+	})
+
+	It("keeps the previous routes when the file is invalid", func() {
+		writeRoutes("routes:\n- host: 10.0.0.4\n  uris: [broken.example.com]\n")
+		Expect(watcher.Sync()).To(MatchError(ContainSubstring("host and port are required")))
+
+		Expect(addresses("app.example.com")).To(ConsistOf("10.0.0.1:8080", "10.0.0.2:8080"))
+		Expect(addresses("broken.example.com")).To(BeEmpty())
+	})
+
+	It("keeps the previous routes when the file disappears", func() {
+		Expect(os.Remove(path)).To(Succeed())
+		Expect(watcher.Sync()).NotTo(Succeed())
+
+		Expect(addresses("www.example.com")).To(ConsistOf("10.0.0.1:8080"))
+	})
+
+	Describe("Parse", func() {
+		It("rejects plain http route services", func() {
+			_, err := staticroutes.Parse([]byte("routes:\n- host: 10.0.0.1\n  port: 80\n  uris: [a.example.com]\n  route_service_url: http://rs.example.com\n"))
+			Expect(err).To(HaveOccurred())
+		})
+	})
+})
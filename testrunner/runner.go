@@ -0,0 +1,269 @@
+// Package testrunner runs the gorouter proxy in-process on ephemeral ports
+// with an in-memory routing table, so integration tests can route real
+// requests through it without NATS or BOSH.
+package testrunner
+
+import (
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+
+	"code.cloudfoundry.org/gorouter/access_log/schema"
+	"code.cloudfoundry.org/gorouter/config"
+	"code.cloudfoundry.org/gorouter/logger"
+	"code.cloudfoundry.org/gorouter/metrics"
+	"code.cloudfoundry.org/gorouter/metrics/fakes"
+	"code.cloudfoundry.org/gorouter/proxy"
+	"code.cloudfoundry.org/gorouter/registry"
+	"code.cloudfoundry.org/gorouter/route"
+	"code.cloudfoundry.org/gorouter/routeservice"
+	"code.cloudfoundry.org/gorouter/varz"
+	"code.cloudfoundry.org/routing-api/models"
+
+	"github.com/uber-go/zap"
+)
+
+// Runner is a started router. The registry is populated directly through
+// RegisterRoute and friends instead of route registration messages.
+type Runner struct {
+	Config   *config.Config
+	Registry *registry.RouteRegistry
+	Logger   logger.Logger
+
+	listener    net.Listener
+	server      *http.Server
+	accessLogs  *accessLogRecorder
+	metrics     *metricRecorder
+	heartbeatOK int32
+	client      *http.Client
+}
+
+// Start runs a router with c, or the default configuration when c is nil.
+// The router listens on an ephemeral port on 127.0.0.1 regardless of c.Port.
+func Start(c *config.Config) (*Runner, error) {
+	if c == nil {
+		c = config.DefaultConfig()
+	}
+
+	lggr := logger.NewLogger("gorouter.testrunner", zap.ErrorLevel)
+	r := &Runner{
+		Config:      c,
+		Logger:      lggr,
+		accessLogs:  &accessLogRecorder{},
+		metrics:     newMetricRecorder(),
+		heartbeatOK: 1,
+		client: &http.Client{
+			CheckRedirect: func(*http.Request, []*http.Request) error {
+				return http.ErrUseLastResponse
+			},
+		},
+	}
+
+	reporter := metrics.NewMetricsReporter(r.metrics.sender, r.metrics)
+	r.Registry = registry.NewRouteRegistry(lggr.Session("registry"), c, reporter)
+	combinedReporter := metrics.NewCompositeReporter(varz.NewVarz(r.Registry), reporter)
+
+	routeServiceConfig := routeservice.NewRouteServiceConfig(lggr, false, c.RouteServiceTimeout, nil, nil, false)
+	p := proxy.NewProxy(lggr.Session("proxy"), r.accessLogs, c, r.Registry, combinedReporter,
+		routeServiceConfig, &tls.Config{InsecureSkipVerify: c.SkipSSLValidation}, &r.heartbeatOK, nil, nil)
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return nil, err
+	}
+	r.listener = l
+	r.server = &http.Server{Handler: p}
+	go func() {
+		_ = r.server.Serve(l)
+	}()
+
+	return r, nil
+}
+
+// Stop closes the router's listener
+func (r *Runner) Stop() error {
+	return r.listener.Close()
+}
+
+// Addr returns the host:port the router listens on
+func (r *Runner) Addr() string {
+	return r.listener.Addr().String()
+}
+
+// RegisterRoute routes uri to the backend at addr (host:port)
+func (r *Runner) RegisterRoute(uri, addr string) error {
+	endpoint, err := NewEndpoint(addr)
+	if err != nil {
+		return err
+	}
+	r.Registry.Register(route.Uri(uri), endpoint)
+	return nil
+}
+
+// UnregisterRoute stops routing uri to the backend at addr
+func (r *Runner) UnregisterRoute(uri, addr string) error {
+	endpoint, err := NewEndpoint(addr)
+	if err != nil {
+		return err
+	}
+	r.Registry.Unregister(route.Uri(uri), endpoint)
+	return nil
+}
+
+// NewEndpoint returns an endpoint for the backend at addr, using addr as its
+// application and instance ids. Use Registry.Register directly to set tags,
+// route services or other endpoint fields.
+func NewEndpoint(addr string) (*route.Endpoint, error) {
+	host, port, err := splitHostPort(addr)
+	if err != nil {
+		return nil, err
+	}
+	return route.NewEndpoint(addr, host, port, addr, "0", nil, -1, "", models.ModificationTag{}, ""), nil
+}
+
+// NewRequest returns a request for path on host, addressed to the router
+func (r *Runner) NewRequest(method, host, path string) (*http.Request, error) {
+	req, err := http.NewRequest(method, "http://"+r.Addr()+path, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Host = host
+	return req, nil
+}
+
+// Get sends a GET request for path on host through the router. Redirects are
+// returned rather than followed.
+func (r *Runner) Get(host, path string) (*http.Response, error) {
+	req, err := r.NewRequest("GET", host, path)
+	if err != nil {
+		return nil, err
+	}
+	return r.client.Do(req)
+}
+
+// AccessLogs returns the access log records written so far, oldest first
+func (r *Runner) AccessLogs() []schema.AccessLogRecord {
+	return r.accessLogs.records()
+}
+
+// WaitForAccessLogs waits until at least n access log records were written.
+// Records are written once the response has been sent, so they may trail
+// the client seeing the response.
+func (r *Runner) WaitForAccessLogs(n int, timeout time.Duration) ([]schema.AccessLogRecord, error) {
+	deadline := time.Now().Add(timeout)
+	for {
+		records := r.AccessLogs()
+		if len(records) >= n {
+			return records, nil
+		}
+		if time.Now().After(deadline) {
+			return records, fmt.Errorf("got %d access log records, expected %d", len(records), n)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+// Counter returns the total emitted for the counter metric name
+func (r *Runner) Counter(name string) uint64 {
+	return r.metrics.counter(name)
+}
+
+// Values returns the values sent for the value metric name, oldest first
+func (r *Runner) Values(name string) []float64 {
+	return r.metrics.values(name)
+}
+
+type accessLogRecorder struct {
+	lock sync.Mutex
+	logs []schema.AccessLogRecord
+}
+
+func (a *accessLogRecorder) Run()  {}
+func (a *accessLogRecorder) Stop() {}
+
+func (a *accessLogRecorder) Log(record schema.AccessLogRecord) {
+	a.lock.Lock()
+	a.logs = append(a.logs, record)
+	a.lock.Unlock()
+}
+
+func (a *accessLogRecorder) records() []schema.AccessLogRecord {
+	a.lock.Lock()
+	defer a.lock.Unlock()
+	return append([]schema.AccessLogRecord(nil), a.logs...)
+}
+
+// metricRecorder implements the dropsonde metric batcher and records what the
+// metric sender emits
+type metricRecorder struct {
+	sender      *fakes.MetricSender
+	lock        sync.Mutex
+	counters    map[string]uint64
+	valueSeries map[string][]float64
+}
+
+func newMetricRecorder() *metricRecorder {
+	m := &metricRecorder{
+		sender:      new(fakes.MetricSender),
+		counters:    make(map[string]uint64),
+		valueSeries: make(map[string][]float64),
+	}
+	m.sender.SendValueStub = func(name string, value float64, _ string) error {
+		m.lock.Lock()
+		m.valueSeries[name] = append(m.valueSeries[name], value)
+		m.lock.Unlock()
+		return nil
+	}
+	m.sender.IncrementCounterStub = func(name string) error {
+		m.BatchAddCounter(name, 1)
+		return nil
+	}
+	m.sender.AddToCounterStub = func(name string, delta uint64) error {
+		m.BatchAddCounter(name, delta)
+		return nil
+	}
+	return m
+}
+
+func (m *metricRecorder) BatchIncrementCounter(name string) {
+	m.BatchAddCounter(name, 1)
+}
+
+func (m *metricRecorder) BatchAddCounter(name string, delta uint64) {
+	m.lock.Lock()
+	m.counters[name] += delta
+	m.lock.Unlock()
+}
+
+func (m *metricRecorder) Close() {}
+
+func (m *metricRecorder) counter(name string) uint64 {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	return m.counters[name]
+}
+
+func (m *metricRecorder) values(name string) []float64 {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	return append([]float64(nil), m.valueSeries[name]...)
+}
+
+func splitHostPort(addr string) (string, uint16, error) {
+	host, portStr, err := net.SplitHostPort(addr)
+	if err != nil {
+		return "", 0, err
+	}
+	port, err := net.LookupPort("tcp", portStr)
+	if err != nil {
+		return "", 0, err
+	}
+	if port <= 0 || port > 65535 {
+		return "", 0, errors.New("invalid port: " + portStr)
+	}
+	return host, uint16(port), nil
+}
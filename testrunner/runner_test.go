@@ -0,0 +1,101 @@
+package testrunner_test
+
+import (
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"time"
+
+	"code.cloudfoundry.org/gorouter/testrunner"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Runner", func() {
+	var (
+		runner  *testrunner.Runner
+		backend *httptest.Server
+	)
+
+	BeforeEach(func() {
+		var err error
+		runner, err = testrunner.Start(nil)
+		Expect(err).NotTo(HaveOccurred())
+
+		backend = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			_, _ = io.WriteString(w, "hello from "+r.Host)
+		}))
+	})
+
+	AfterEach(func() {
+		backend.Close()
+		Expect(runner.Stop()).To(Succeed())
+	})
+
+	backendAddr := func() string {
+		return strings.TrimPrefix(backend.URL, "http://")
+	}
+
+	It("routes requests to registered backends", func() {
+		Expect(runner.RegisterRoute("app.example.com", backendAddr())).To(Succeed())
+
+		resp, err := runner.Get("app.example.com", "/")
+		Expect(err).NotTo(HaveOccurred())
+		defer resp.Body.Close()
+		Expect(resp.StatusCode).To(Equal(http.StatusOK))
+
+		body, err := ioutil.ReadAll(resp.Body)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(string(body)).To(Equal("hello from app.example.com"))
+	})
+
+	It("returns 404 for unknown routes", func() {
+		resp, err := runner.Get("missing.example.com", "/")
+		Expect(err).NotTo(HaveOccurred())
+		resp.Body.Close()
+		Expect(resp.StatusCode).To(Equal(http.StatusNotFound))
+	})
+
+	It("stops routing unregistered backends", func() {
+		Expect(runner.RegisterRoute("app.example.com", backendAddr())).To(Succeed())
+		Expect(runner.UnregisterRoute("app.example.com", backendAddr())).To(Succeed())
+
+		resp, err := runner.Get("app.example.com", "/")
+		Expect(err).NotTo(HaveOccurred())
+		resp.Body.Close()
+		Expect(resp.StatusCode).To(Equal(http.StatusNotFound))
+	})
+
+	It("records access logs", func() {
+		Expect(runner.RegisterRoute("app.example.com", backendAddr())).To(Succeed())
+
+		resp, err := runner.Get("app.example.com", "/foo")
+		Expect(err).NotTo(HaveOccurred())
+		resp.Body.Close()
+
+		records, err := runner.WaitForAccessLogs(1, time.Second)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(records[0].Request.Host).To(Equal("app.example.com"))
+		Expect(records[0].StatusCode).To(Equal(http.StatusOK))
+		Expect(records[0].RouteEndpoint.CanonicalAddr()).To(Equal(backendAddr()))
+	})
+
+	It("records metrics", func() {
+		Expect(runner.RegisterRoute("app.example.com", backendAddr())).To(Succeed())
+
+		resp, err := runner.Get("app.example.com", "/")
+		Expect(err).NotTo(HaveOccurred())
+		resp.Body.Close()
+
+		Eventually(func() uint64 { return runner.Counter("responses.2xx") }).Should(BeNumerically("==", 1))
+		Expect(runner.Counter("total_requests")).To(BeNumerically("==", 1))
+		Expect(runner.Values("latency")).To(HaveLen(1))
+	})
+
+	It("rejects malformed backend addresses", func() {
+		Expect(runner.RegisterRoute("app.example.com", "no-port")).NotTo(Succeed())
+	})
+})
@@ -31,6 +31,8 @@ type varz struct {
 	BadGateways    int     `json:"bad_gateways"`
 	RequestsPerSec float64 `json:"requests_per_sec"`
 
+	InFlightRequests int64 `json:"in_flight_requests"`
+
 	TopApps []topAppsEntry `json:"top10_app_requests"`
 
 	MillisSinceLastRegistryUpdate int64 `json:"ms_since_last_registry_update"`
@@ -275,6 +277,13 @@ func (x *RealVarz) CaptureRoutingResponseLatency(endpoint *route.Endpoint, statu
 	x.Unlock()
 }
 
+// CaptureInFlightRequests keeps the total of requests in flight for varz
+func (x *RealVarz) CaptureInFlightRequests(total int64) {
+	x.Lock()
+	x.InFlightRequests = total
+	x.Unlock()
+}
+
 func transform(x interface{}, y map[string]interface{}) error {
 	var b []byte
 	var err error
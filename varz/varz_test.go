@@ -47,6 +47,7 @@ var _ = Describe("Varz", func() {
 			"bad_requests",
 			"bad_gateways",
 			"requests_per_sec",
+			"in_flight_requests",
 			"top10_app_requests",
 			"ms_since_last_registry_update",
 		}
@@ -94,6 +95,11 @@ var _ = Describe("Varz", func() {
 		Expect(findValue(Varz, "bad_requests")).To(Equal(float64(2)))
 	})
 
+	It("updates requests in flight", func() {
+		Varz.(*RealVarz).CaptureInFlightRequests(3)
+		Expect(findValue(Varz, "in_flight_requests")).To(Equal(float64(3)))
+	})
+
 	It("updates bad gateways", func() {
 		Varz.CaptureBadGateway()
 		Expect(findValue(Varz, "bad_gateways")).To(Equal(float64(1)))
@@ -0,0 +1,303 @@
+package xds
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"net"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"code.cloudfoundry.org/gorouter/route"
+)
+
+// Type URLs of the resources served
+const (
+	ClusterType               = "type.googleapis.com/envoy.config.cluster.v3.Cluster"
+	ClusterLoadAssignmentType = "type.googleapis.com/envoy.config.endpoint.v3.ClusterLoadAssignment"
+	RouteConfigurationType    = "type.googleapis.com/envoy.config.route.v3.RouteConfiguration"
+)
+
+// DiscoveryRequest is an envoy.service.discovery.v3.DiscoveryRequest
+type DiscoveryRequest struct {
+	VersionInfo   string
+	NodeID        string
+	ResourceNames []string
+	TypeURL       string
+	ResponseNonce string
+	// ErrorDetail is the message of the error the client rejected the
+	// previous response with
+	ErrorDetail string
+	Rejected    bool
+}
+
+// Unmarshal decodes a DiscoveryRequest from the protobuf wire format
+func (r *DiscoveryRequest) Unmarshal(buf []byte) error {
+	*r = DiscoveryRequest{}
+	return decode(buf, func(f field) error {
+		switch f.number {
+		case 1:
+			r.VersionInfo = string(f.data)
+		case 2:
+			return decode(f.data, func(f field) error {
+				if f.number == 1 {
+					r.NodeID = string(f.data)
+				}
+				return nil
+			})
+		case 3:
+			r.ResourceNames = append(r.ResourceNames, string(f.data))
+		case 4:
+			r.TypeURL = string(f.data)
+		case 5:
+			r.ResponseNonce = string(f.data)
+		case 6:
+			r.Rejected = true
+			return decode(f.data, func(f field) error {
+				if f.number == 2 {
+					r.ErrorDetail = string(f.data)
+				}
+				return nil
+			})
+		}
+		return nil
+	})
+}
+
+// Marshal encodes the request. gorouter only receives requests; this is used
+// by tests and tools playing the client.
+func (r *DiscoveryRequest) Marshal() ([]byte, error) {
+	var e encoder
+	e.string(1, r.VersionInfo)
+	if r.NodeID != "" {
+		e.message(2, func(e *encoder) { e.string(1, r.NodeID) })
+	}
+	for _, name := range r.ResourceNames {
+		e.bytes(3, []byte(name))
+	}
+	e.string(4, r.TypeURL)
+	e.string(5, r.ResponseNonce)
+	if r.Rejected {
+		e.message(6, func(e *encoder) { e.string(2, r.ErrorDetail) })
+	}
+	return e.buf, nil
+}
+
+// Resource is an encoded xDS resource
+type Resource struct {
+	Name  string
+	Value []byte
+}
+
+// DiscoveryResponse is an envoy.service.discovery.v3.DiscoveryResponse
+type DiscoveryResponse struct {
+	VersionInfo string
+	Resources   []Resource
+	TypeURL     string
+	Nonce       string
+}
+
+// Marshal encodes the response in the protobuf wire format, wrapping each
+// resource in a google.protobuf.Any
+func (r *DiscoveryResponse) Marshal() ([]byte, error) {
+	var e encoder
+	e.string(1, r.VersionInfo)
+	for _, res := range r.Resources {
+		e.message(2, func(e *encoder) {
+			e.string(1, r.TypeURL)
+			e.bytes(2, res.Value)
+		})
+	}
+	e.string(4, r.TypeURL)
+	e.string(5, r.Nonce)
+	return e.buf, nil
+}
+
+// Unmarshal decodes a response. Resources are left encoded and unnamed.
+func (r *DiscoveryResponse) Unmarshal(buf []byte) error {
+	*r = DiscoveryResponse{}
+	return decode(buf, func(f field) error {
+		switch f.number {
+		case 1:
+			r.VersionInfo = string(f.data)
+		case 2:
+			return decode(f.data, func(f field) error {
+				if f.number == 2 {
+					r.Resources = append(r.Resources, Resource{Value: f.data})
+				}
+				return nil
+			})
+		case 4:
+			r.TypeURL = string(f.data)
+		case 5:
+			r.Nonce = string(f.data)
+		}
+		return nil
+	})
+}
+
+// Snapshot is the set of resources built from the routing table at one point
+// in time. Every route is served as an EDS cluster named after the route, and
+// all routes are gathered in a single route configuration.
+type Snapshot struct {
+	Version   string
+	resources map[string][]Resource
+}
+
+// Resources returns the resources of typeURL named in names, or all of them
+// when names is empty
+func (s *Snapshot) Resources(typeURL string, names []string) []Resource {
+	all := s.resources[typeURL]
+	if len(names) == 0 {
+		return all
+	}
+
+	wanted := make(map[string]bool, len(names))
+	for _, name := range names {
+		wanted[name] = true
+	}
+
+	var resources []Resource
+	for _, res := range all {
+		if wanted[res.Name] {
+			resources = append(resources, res)
+		}
+	}
+	return resources
+}
+
+// Table is the part of the route registry a snapshot is built from
+type Table interface {
+	EachEndpoint(f func(uri route.Uri, endpoint *route.Endpoint))
+}
+
+// NewSnapshot builds a snapshot of table. Endpoints registered by host name
+// are left out, as EDS only carries IP addresses.
+func NewSnapshot(table Table, routeConfigName string, connectTimeout time.Duration) *Snapshot {
+	type lbEndpoint struct {
+		ip   string
+		port uint32
+	}
+	clusters := map[string][]lbEndpoint{}
+
+	table.EachEndpoint(func(uri route.Uri, endpoint *route.Endpoint) {
+		host, portStr, err := net.SplitHostPort(endpoint.CanonicalAddr())
+		if err != nil || net.ParseIP(host) == nil {
+			return
+		}
+		port, err := strconv.ParseUint(portStr, 10, 16)
+		if err != nil {
+			return
+		}
+		name := string(uri)
+		clusters[name] = append(clusters[name], lbEndpoint{ip: host, port: uint32(port)})
+	})
+
+	names := make([]string, 0, len(clusters))
+	for name := range clusters {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	s := &Snapshot{resources: map[string][]Resource{}}
+	virtualHosts := map[string][]string{}
+	for _, name := range names {
+		endpoints := clusters[name]
+		sort.Slice(endpoints, func(i, j int) bool {
+			if endpoints[i].ip != endpoints[j].ip {
+				return endpoints[i].ip < endpoints[j].ip
+			}
+			return endpoints[i].port < endpoints[j].port
+		})
+
+		var cluster encoder
+		cluster.string(1, name)
+		// type EDS, with eds_cluster_config pointing at this server
+		cluster.uint(2, 3)
+		cluster.message(3, func(e *encoder) {
+			e.message(1, func(e *encoder) { // eds_config
+				e.message(5, func(e *encoder) { // self
+					e.uint(1, 2) // transport_api_version: V3
+				})
+				e.uint(6, 2) // resource_api_version: V3
+			})
+		})
+		cluster.message(4, func(e *encoder) { // connect_timeout
+			e.uint(1, uint64(connectTimeout/time.Second))
+			e.uint(2, uint64(connectTimeout%time.Second))
+		})
+		s.resources[ClusterType] = append(s.resources[ClusterType], Resource{Name: name, Value: cluster.buf})
+
+		var assignment encoder
+		assignment.string(1, name)
+		assignment.message(2, func(e *encoder) { // endpoints
+			for _, ep := range endpoints {
+				ep := ep
+				e.message(2, func(e *encoder) { // lb_endpoints
+					e.message(1, func(e *encoder) { // endpoint
+						e.message(1, func(e *encoder) { // address
+							e.message(1, func(e *encoder) { // socket_address
+								e.string(2, ep.ip)
+								e.uint(3, uint64(ep.port))
+							})
+						})
+					})
+				})
+			}
+		})
+		s.resources[ClusterLoadAssignmentType] = append(s.resources[ClusterLoadAssignmentType],
+			Resource{Name: name, Value: assignment.buf})
+
+		host := name
+		if i := strings.Index(name, "/"); i >= 0 {
+			host = name[:i]
+		}
+		virtualHosts[host] = append(virtualHosts[host], name)
+	}
+
+	hosts := make([]string, 0, len(virtualHosts))
+	for host := range virtualHosts {
+		hosts = append(hosts, host)
+	}
+	sort.Strings(hosts)
+
+	var routeConfig encoder
+	routeConfig.string(1, routeConfigName)
+	for _, host := range hosts {
+		routes := virtualHosts[host]
+		// match the longest context path first
+		sort.SliceStable(routes, func(i, j int) bool { return len(routes[i]) > len(routes[j]) })
+
+		routeConfig.message(2, func(e *encoder) { // virtual_hosts
+			e.string(1, host)
+			e.bytes(2, []byte(host)) // domains
+			for _, name := range routes {
+				name := name
+				e.message(3, func(e *encoder) { // routes
+					e.message(1, func(e *encoder) { // match
+						if path := strings.TrimSuffix(strings.TrimPrefix(name, host), "/"); path != "" {
+							e.string(14, path) // path_separated_prefix
+						} else {
+							e.string(1, "/") // prefix
+						}
+					})
+					e.message(2, func(e *encoder) { // route
+						e.string(1, name) // cluster
+					})
+				})
+			}
+		})
+	}
+	s.resources[RouteConfigurationType] = []Resource{{Name: routeConfigName, Value: routeConfig.buf}}
+
+	hash := sha256.New()
+	for _, typeURL := range []string{ClusterType, ClusterLoadAssignmentType, RouteConfigurationType} {
+		for _, res := range s.resources[typeURL] {
+			hash.Write(res.Value)
+		}
+	}
+	s.Version = hex.EncodeToString(hash.Sum(nil))[:16]
+
+	return s
+}
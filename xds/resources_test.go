@@ -0,0 +1,101 @@
+package xds_test
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"code.cloudfoundry.org/gorouter/route"
+	"code.cloudfoundry.org/gorouter/xds"
+	"code.cloudfoundry.org/routing-api/models"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+type fakeTable map[route.Uri][]string
+
+func (t fakeTable) EachEndpoint(f func(uri route.Uri, endpoint *route.Endpoint)) {
+	for uri, addrs := range t {
+		for _, addr := range addrs {
+			var host string
+			var port uint16
+			_, err := fmt.Sscanf(strings.Replace(addr, ":", " ", 1), "%s %d", &host, &port)
+			Expect(err).NotTo(HaveOccurred())
+			f(uri, route.NewEndpoint("", host, port, addr, "", nil, 0, "", models.ModificationTag{}, ""))
+		}
+	}
+}
+
+var _ = Describe("Snapshot", func() {
+	var table fakeTable
+
+	BeforeEach(func() {
+		table = fakeTable{
+			"app.example.com":     {"10.0.0.2:8080", "10.0.0.1:8080"},
+			"app.example.com/api": {"10.0.0.3:9000"},
+			"named.example.com":   {"backend.internal:8080"},
+		}
+	})
+
+	It("serves an EDS cluster per route", func() {
+		snapshot := xds.NewSnapshot(table, "gorouter", 5*time.Second)
+
+		clusters := snapshot.Resources(xds.ClusterType, nil)
+		Expect(clusters).To(HaveLen(2))
+		Expect(clusters[0].Name).To(Equal("app.example.com"))
+		Expect(clusters[1].Name).To(Equal("app.example.com/api"))
+
+		cluster := decodeMessage(clusters[0].Value)
+		Expect(cluster.string(1)).To(Equal("app.example.com"))
+		Expect(cluster.varints[2]).To(Equal([]uint64{3}))
+		Expect(cluster.message(4).varints[1]).To(Equal([]uint64{5}))
+		Expect(cluster.message(3).message(1).bytes).To(HaveKey(5))
+	})
+
+	It("serves the IP endpoints of each route", func() {
+		snapshot := xds.NewSnapshot(table, "gorouter", 5*time.Second)
+
+		assignments := snapshot.Resources(xds.ClusterLoadAssignmentType, []string{"app.example.com", "unknown"})
+		Expect(assignments).To(HaveLen(1))
+
+		assignment := decodeMessage(assignments[0].Value)
+		Expect(assignment.string(1)).To(Equal("app.example.com"))
+
+		var addrs []string
+		for _, lbEndpoint := range assignment.message(2).messages(2) {
+			socketAddress := lbEndpoint.message(1).message(1).message(1)
+			addrs = append(addrs, fmt.Sprintf("%s:%d", socketAddress.string(2), socketAddress.varints[3][0]))
+		}
+		Expect(addrs).To(Equal([]string{"10.0.0.1:8080", "10.0.0.2:8080"}))
+	})
+
+	It("routes the longest context path of a host first", func() {
+		snapshot := xds.NewSnapshot(table, "gorouter", 5*time.Second)
+
+		configs := snapshot.Resources(xds.RouteConfigurationType, nil)
+		Expect(configs).To(HaveLen(1))
+
+		config := decodeMessage(configs[0].Value)
+		Expect(config.string(1)).To(Equal("gorouter"))
+
+		virtualHosts := config.messages(2)
+		Expect(virtualHosts).To(HaveLen(1))
+		Expect(virtualHosts[0].string(2)).To(Equal("app.example.com"))
+
+		routes := virtualHosts[0].messages(3)
+		Expect(routes).To(HaveLen(2))
+		Expect(routes[0].message(1).string(14)).To(Equal("/api"))
+		Expect(routes[0].message(2).string(1)).To(Equal("app.example.com/api"))
+		Expect(routes[1].message(1).string(1)).To(Equal("/"))
+		Expect(routes[1].message(2).string(1)).To(Equal("app.example.com"))
+	})
+
+	It("versions the snapshot by its content", func() {
+		version := xds.NewSnapshot(table, "gorouter", 5*time.Second).Version
+		Expect(xds.NewSnapshot(table, "gorouter", 5*time.Second).Version).To(Equal(version))
+
+		table["app.example.com"] = []string{"10.0.0.1:8080"}
+		Expect(xds.NewSnapshot(table, "gorouter", 5*time.Second).Version).NotTo(Equal(version))
+	})
+})
@@ -0,0 +1,312 @@
+// Package xds serves the routing table to Envoy over its discovery (xDS) API.
+// Clusters (CDS), their endpoints (EDS) and a route configuration (RDS) are
+// served with the state of the world protocol, on the aggregated stream as
+// well as on the per-type streams. Messages are encoded by hand, so no Envoy
+// API code has to be generated or vendored.
+package xds
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/uber-go/zap"
+	"google.golang.org/grpc"
+
+	"code.cloudfoundry.org/gorouter/logger"
+)
+
+// Server builds snapshots of the routing table and pushes them to the
+// connected clients whenever the table changes
+type Server struct {
+	table           Table
+	address         string
+	refreshInterval time.Duration
+	routeConfigName string
+	connectTimeout  time.Duration
+	logger          logger.Logger
+
+	lock     sync.Mutex
+	snapshot *Snapshot
+	updated  chan struct{}
+	nonce    uint64
+}
+
+// NewServer returns a Server listening on address
+func NewServer(
+	table Table,
+	address string,
+	refreshInterval time.Duration,
+	routeConfigName string,
+	connectTimeout time.Duration,
+	logger logger.Logger,
+) *Server {
+	return &Server{
+		table:           table,
+		address:         address,
+		refreshInterval: refreshInterval,
+		routeConfigName: routeConfigName,
+		connectTimeout:  connectTimeout,
+		logger:          logger,
+		snapshot:        NewSnapshot(table, routeConfigName, connectTimeout),
+		updated:         make(chan struct{}),
+	}
+}
+
+// Run serves xDS until signaled
+func (s *Server) Run(signals <-chan os.Signal, ready chan<- struct{}) error {
+	listener, err := net.Listen("tcp", s.address)
+	if err != nil {
+		return err
+	}
+
+	server := grpc.NewServer(grpc.CustomCodec(codec{}))
+	for _, desc := range serviceDescs {
+		server.RegisterService(desc, s)
+	}
+
+	errChan := make(chan error, 1)
+	go func() {
+		errChan <- server.Serve(listener)
+	}()
+
+	close(ready)
+	s.logger.Info("xds-server-started", zap.String("address", s.address))
+
+	ticker := time.NewTicker(s.refreshInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			s.Refresh()
+		case err := <-errChan:
+			s.logger.Error("xds-server-failed", zap.Error(err))
+			return err
+		case <-signals:
+			server.Stop()
+			s.logger.Info("xds-server-exited")
+			return nil
+		}
+	}
+}
+
+// Refresh rebuilds the snapshot and notifies the streams when it changed
+func (s *Server) Refresh() {
+	snapshot := NewSnapshot(s.table, s.routeConfigName, s.connectTimeout)
+
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	if snapshot.Version == s.snapshot.Version {
+		return
+	}
+	s.snapshot = snapshot
+	close(s.updated)
+	s.updated = make(chan struct{})
+}
+
+func (s *Server) current() (*Snapshot, <-chan struct{}) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	return s.snapshot, s.updated
+}
+
+func (s *Server) nextNonce() string {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.nonce++
+	return strconv.FormatUint(s.nonce, 10)
+}
+
+// subscription tracks what was last sent on a stream for one resource type
+type subscription struct {
+	names   []string
+	version string
+	nonce   string
+}
+
+// Stream is the part of a grpc.ServerStream a discovery stream is served on
+type Stream interface {
+	Context() context.Context
+	SendMsg(m interface{}) error
+	RecvMsg(m interface{}) error
+}
+
+// Serve serves a discovery stream. typeURL is the resource type of a per-type
+// stream and empty for the aggregated stream.
+func (s *Server) Serve(stream Stream, typeURL string) error {
+	requests := make(chan *DiscoveryRequest)
+	errChan := make(chan error, 1)
+	go func() {
+		for {
+			req := new(DiscoveryRequest)
+			if err := stream.RecvMsg(req); err != nil {
+				errChan <- err
+				return
+			}
+			select {
+			case requests <- req:
+			case <-stream.Context().Done():
+				return
+			}
+		}
+	}()
+
+	subscriptions := map[string]*subscription{}
+	for {
+		snapshot, updated := s.current()
+
+		select {
+		case req := <-requests:
+			if req.TypeURL == "" {
+				req.TypeURL = typeURL
+			}
+			if typeURL != "" && req.TypeURL != typeURL {
+				return fmt.Errorf("unexpected type %q on %s stream", req.TypeURL, typeURL)
+			}
+			if !supported(req.TypeURL) {
+				s.logger.Debug("xds-unsupported-type", zap.String("node", req.NodeID), zap.String("type", req.TypeURL))
+				continue
+			}
+
+			sub := subscriptions[req.TypeURL]
+			if sub != nil && req.ResponseNonce != sub.nonce {
+				// a response to an earlier push, superseded by a newer one
+				continue
+			}
+			if req.Rejected {
+				s.logger.Error("xds-update-rejected",
+					zap.String("node", req.NodeID),
+					zap.String("type", req.TypeURL),
+					zap.String("version", req.VersionInfo),
+					zap.String("error", req.ErrorDetail),
+				)
+			}
+
+			if sub == nil {
+				sub = &subscription{}
+				subscriptions[req.TypeURL] = sub
+			} else if equal(sub.names, req.ResourceNames) && sub.version == snapshot.Version {
+				// an ACK, or a NACK of the current version
+				continue
+			}
+			sub.names = req.ResourceNames
+
+			if err := s.send(stream, req.TypeURL, sub, snapshot); err != nil {
+				return err
+			}
+
+		case <-updated:
+			snapshot, _ = s.current()
+			for t, sub := range subscriptions {
+				if sub.version == snapshot.Version {
+					continue
+				}
+				if err := s.send(stream, t, sub, snapshot); err != nil {
+					return err
+				}
+			}
+
+		case err := <-errChan:
+			if err == io.EOF {
+				return nil
+			}
+			return err
+
+		case <-stream.Context().Done():
+			return stream.Context().Err()
+		}
+	}
+}
+
+func (s *Server) send(stream Stream, typeURL string, sub *subscription, snapshot *Snapshot) error {
+	resp := &DiscoveryResponse{
+		VersionInfo: snapshot.Version,
+		Resources:   snapshot.Resources(typeURL, sub.names),
+		TypeURL:     typeURL,
+		Nonce:       s.nextNonce(),
+	}
+	if err := stream.SendMsg(resp); err != nil {
+		return err
+	}
+	sub.version, sub.nonce = resp.VersionInfo, resp.Nonce
+	return nil
+}
+
+func supported(typeURL string) bool {
+	switch typeURL {
+	case ClusterType, ClusterLoadAssignmentType, RouteConfigurationType:
+		return true
+	}
+	return false
+}
+
+func equal(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// The discovery services are described by hand, like the plugin filter
+// service, as their messages are encoded without generated code
+var serviceDescs = []*grpc.ServiceDesc{
+	streamService("envoy.service.discovery.v3.AggregatedDiscoveryService", "StreamAggregatedResources", ""),
+	streamService("envoy.service.cluster.v3.ClusterDiscoveryService", "StreamClusters", ClusterType),
+	streamService("envoy.service.endpoint.v3.EndpointDiscoveryService", "StreamEndpoints", ClusterLoadAssignmentType),
+	streamService("envoy.service.route.v3.RouteDiscoveryService", "StreamRoutes", RouteConfigurationType),
+}
+
+func streamService(serviceName, streamName, typeURL string) *grpc.ServiceDesc {
+	return &grpc.ServiceDesc{
+		ServiceName: serviceName,
+		HandlerType: (*interface{})(nil),
+		Streams: []grpc.StreamDesc{{
+			StreamName: streamName,
+			Handler: func(srv interface{}, stream grpc.ServerStream) error {
+				return srv.(*Server).Serve(stream, typeURL)
+			},
+			ServerStreams: true,
+			ClientStreams: true,
+		}},
+	}
+}
+
+type message interface {
+	Marshal() ([]byte, error)
+	Unmarshal([]byte) error
+}
+
+// codec encodes the hand written messages. It is only installed on the xDS
+// gRPC server, leaving the global proto codec alone.
+type codec struct{}
+
+func (codec) Marshal(v interface{}) ([]byte, error) {
+	m, ok := v.(message)
+	if !ok {
+		return nil, fmt.Errorf("xds: cannot marshal %T", v)
+	}
+	return m.Marshal()
+}
+
+func (codec) Unmarshal(data []byte, v interface{}) error {
+	m, ok := v.(message)
+	if !ok {
+		return fmt.Errorf("xds: cannot unmarshal into %T", v)
+	}
+	return m.Unmarshal(data)
+}
+
+func (codec) Name() string   { return "proto" }
+func (codec) String() string { return "proto" }
@@ -0,0 +1,157 @@
+package xds_test
+
+import (
+	"context"
+	"io"
+	"time"
+
+	logger_fakes "code.cloudfoundry.org/gorouter/logger/fakes"
+	"code.cloudfoundry.org/gorouter/xds"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+type fakeStream struct {
+	ctx       context.Context
+	requests  chan *xds.DiscoveryRequest
+	responses chan *xds.DiscoveryResponse
+}
+
+func (s *fakeStream) Context() context.Context { return s.ctx }
+
+func (s *fakeStream) SendMsg(m interface{}) error {
+	data, err := m.(*xds.DiscoveryResponse).Marshal()
+	Expect(err).NotTo(HaveOccurred())
+
+	resp := new(xds.DiscoveryResponse)
+	Expect(resp.Unmarshal(data)).To(Succeed())
+	s.responses <- resp
+	return nil
+}
+
+func (s *fakeStream) RecvMsg(m interface{}) error {
+	select {
+	case req, ok := <-s.requests:
+		if !ok {
+			return io.EOF
+		}
+		data, err := req.Marshal()
+		Expect(err).NotTo(HaveOccurred())
+		return m.(*xds.DiscoveryRequest).Unmarshal(data)
+	case <-s.ctx.Done():
+		return s.ctx.Err()
+	}
+}
+
+var _ = Describe("Server", func() {
+	var (
+		table    fakeTable
+		server   *xds.Server
+		stream   *fakeStream
+		cancel   context.CancelFunc
+		done     chan struct{}
+		serveErr error
+	)
+
+	BeforeEach(func() {
+		table = fakeTable{
+			"app.example.com":   {"10.0.0.1:8080"},
+			"other.example.com": {"10.0.0.2:8080"},
+		}
+		server = xds.NewServer(table, "127.0.0.1:0", time.Second, "gorouter", time.Second, new(logger_fakes.FakeLogger))
+
+		var ctx context.Context
+		ctx, cancel = context.WithCancel(context.Background())
+		stream = &fakeStream{
+			ctx:       ctx,
+			requests:  make(chan *xds.DiscoveryRequest, 10),
+			responses: make(chan *xds.DiscoveryResponse, 10),
+		}
+
+		done = make(chan struct{})
+		go func() {
+			serveErr = server.Serve(stream, "")
+			close(done)
+		}()
+	})
+
+	AfterEach(func() {
+		cancel()
+		Eventually(done).Should(BeClosed())
+	})
+
+	It("serves the requested resources and waits for changes once acknowledged", func() {
+		stream.requests <- &xds.DiscoveryRequest{NodeID: "envoy", TypeURL: xds.ClusterType}
+
+		var resp *xds.DiscoveryResponse
+		Eventually(stream.responses).Should(Receive(&resp))
+		Expect(resp.TypeURL).To(Equal(xds.ClusterType))
+		Expect(resp.Resources).To(HaveLen(2))
+
+		stream.requests <- &xds.DiscoveryRequest{TypeURL: xds.ClusterType, VersionInfo: resp.VersionInfo, ResponseNonce: resp.Nonce}
+		Consistently(stream.responses).ShouldNot(Receive())
+
+		table["new.example.com"] = []string{"10.0.0.3:8080"}
+		server.Refresh()
+
+		var update *xds.DiscoveryResponse
+		Eventually(stream.responses).Should(Receive(&update))
+		Expect(update.Resources).To(HaveLen(3))
+		Expect(update.VersionInfo).NotTo(Equal(resp.VersionInfo))
+		Expect(update.Nonce).NotTo(Equal(resp.Nonce))
+	})
+
+	It("serves only the named endpoints", func() {
+		stream.requests <- &xds.DiscoveryRequest{TypeURL: xds.ClusterLoadAssignmentType, ResourceNames: []string{"app.example.com"}}
+
+		var resp *xds.DiscoveryResponse
+		Eventually(stream.responses).Should(Receive(&resp))
+		Expect(resp.Resources).To(HaveLen(1))
+
+		stream.requests <- &xds.DiscoveryRequest{
+			TypeURL:       xds.ClusterLoadAssignmentType,
+			ResourceNames: []string{"app.example.com", "other.example.com"},
+			VersionInfo:   resp.VersionInfo,
+			ResponseNonce: resp.Nonce,
+		}
+		Eventually(stream.responses).Should(Receive(&resp))
+		Expect(resp.Resources).To(HaveLen(2))
+	})
+
+	It("does not resend a rejected version", func() {
+		stream.requests <- &xds.DiscoveryRequest{TypeURL: xds.RouteConfigurationType, ResourceNames: []string{"gorouter"}}
+
+		var resp *xds.DiscoveryResponse
+		Eventually(stream.responses).Should(Receive(&resp))
+
+		stream.requests <- &xds.DiscoveryRequest{
+			TypeURL:       xds.RouteConfigurationType,
+			ResourceNames: []string{"gorouter"},
+			ResponseNonce: resp.Nonce,
+			Rejected:      true,
+			ErrorDetail:   "invalid route",
+		}
+		Consistently(stream.responses).ShouldNot(Receive())
+	})
+
+	It("ignores responses to superseded pushes", func() {
+		stream.requests <- &xds.DiscoveryRequest{TypeURL: xds.ClusterType}
+		var first *xds.DiscoveryResponse
+		Eventually(stream.responses).Should(Receive(&first))
+
+		table["new.example.com"] = []string{"10.0.0.3:8080"}
+		server.Refresh()
+		var second *xds.DiscoveryResponse
+		Eventually(stream.responses).Should(Receive(&second))
+
+		stream.requests <- &xds.DiscoveryRequest{TypeURL: xds.ClusterType, VersionInfo: first.VersionInfo, ResponseNonce: first.Nonce}
+		Consistently(stream.responses).ShouldNot(Receive())
+	})
+
+	It("ends the stream when the client closes it", func() {
+		close(stream.requests)
+		Eventually(done).Should(BeClosed())
+		Expect(serveErr).NotTo(HaveOccurred())
+	})
+})
@@ -0,0 +1,130 @@
+package xds
+
+import (
+	"errors"
+	"math"
+)
+
+// A minimal protobuf wire format encoder and decoder, enough for the handful
+// of xDS messages served here without generated code.
+
+const (
+	wireVarint = 0
+	wire64Bit  = 1
+	wireBytes  = 2
+	wire32Bit  = 5
+)
+
+var errMalformed = errors.New("malformed protobuf message")
+
+type encoder struct {
+	buf []byte
+}
+
+func (e *encoder) varint(v uint64) {
+	for v >= 0x80 {
+		e.buf = append(e.buf, byte(v)|0x80)
+		v >>= 7
+	}
+	e.buf = append(e.buf, byte(v))
+}
+
+func (e *encoder) tag(field int, wireType int) {
+	e.varint(uint64(field)<<3 | uint64(wireType))
+}
+
+func (e *encoder) uint(field int, v uint64) {
+	if v == 0 {
+		return
+	}
+	e.tag(field, wireVarint)
+	e.varint(v)
+}
+
+func (e *encoder) bytes(field int, b []byte) {
+	e.tag(field, wireBytes)
+	e.varint(uint64(len(b)))
+	e.buf = append(e.buf, b...)
+}
+
+func (e *encoder) string(field int, s string) {
+	if s == "" {
+		return
+	}
+	e.bytes(field, []byte(s))
+}
+
+// message encodes a nested message, which is written even when empty as its
+// presence may be significant
+func (e *encoder) message(field int, f func(*encoder)) {
+	var nested encoder
+	f(&nested)
+	e.bytes(field, nested.buf)
+}
+
+// field is a decoded field. For varints value holds the number; for length
+// delimited fields data holds the bytes.
+type field struct {
+	number int
+	value  uint64
+	data   []byte
+}
+
+// decode calls f for every field of the message in buf
+func decode(buf []byte, f func(field) error) error {
+	for len(buf) > 0 {
+		key, n := uvarint(buf)
+		if n <= 0 || key>>3 == 0 || key>>3 > math.MaxInt32 {
+			return errMalformed
+		}
+		buf = buf[n:]
+
+		fld := field{number: int(key >> 3)}
+		switch key & 7 {
+		case wireVarint:
+			fld.value, n = uvarint(buf)
+			if n <= 0 {
+				return errMalformed
+			}
+			buf = buf[n:]
+		case wire64Bit:
+			if len(buf) < 8 {
+				return errMalformed
+			}
+			buf = buf[8:]
+			continue
+		case wire32Bit:
+			if len(buf) < 4 {
+				return errMalformed
+			}
+			buf = buf[4:]
+			continue
+		case wireBytes:
+			length, n := uvarint(buf)
+			if n <= 0 || length > uint64(len(buf)-n) {
+				return errMalformed
+			}
+			fld.data = buf[n : n+int(length)]
+			buf = buf[n+int(length):]
+		default:
+			return errMalformed
+		}
+
+		if err := f(fld); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func uvarint(buf []byte) (uint64, int) {
+	var v uint64
+	for i := 0; i < len(buf) && i < 10; i++ {
+		b := buf[i]
+		v |= uint64(b&0x7f) << (7 * uint(i))
+		if b < 0x80 {
+			return v, i + 1
+		}
+	}
+	return 0, 0
+}
@@ -0,0 +1,76 @@
+package xds_test
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"testing"
+)
+
+func TestXds(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "Xds Suite")
+}
+
+// message holds the fields of a protobuf message, by field number
+type message struct {
+	varints map[int][]uint64
+	bytes   map[int][][]byte
+}
+
+func decodeMessage(buf []byte) message {
+	m := message{varints: map[int][]uint64{}, bytes: map[int][][]byte{}}
+	for len(buf) > 0 {
+		key, n := uvarint(buf)
+		Expect(n).To(BeNumerically(">", 0))
+		buf = buf[n:]
+
+		number := int(key >> 3)
+		switch key & 7 {
+		case 0:
+			v, n := uvarint(buf)
+			Expect(n).To(BeNumerically(">", 0))
+			m.varints[number] = append(m.varints[number], v)
+			buf = buf[n:]
+		case 2:
+			length, n := uvarint(buf)
+			Expect(n).To(BeNumerically(">", 0))
+			m.bytes[number] = append(m.bytes[number], buf[n:n+int(length)])
+			buf = buf[n+int(length):]
+		default:
+			Fail("unexpected wire type")
+		}
+	}
+	return m
+}
+
+func (m message) string(number int) string {
+	if len(m.bytes[number]) == 0 {
+		return ""
+	}
+	return string(m.bytes[number][0])
+}
+
+func (m message) message(number int) message {
+	Expect(m.bytes[number]).NotTo(BeEmpty())
+	return decodeMessage(m.bytes[number][0])
+}
+
+func (m message) messages(number int) []message {
+	var messages []message
+	for _, b := range m.bytes[number] {
+		messages = append(messages, decodeMessage(b))
+	}
+	return messages
+}
+
+func uvarint(buf []byte) (uint64, int) {
+	var v uint64
+	for i := 0; i < len(buf) && i < 10; i++ {
+		v |= uint64(buf[i]&0x7f) << (7 * uint(i))
+		if buf[i] < 0x80 {
+			return v, i + 1
+		}
+	}
+	return 0, 0
+}